@@ -25,6 +25,54 @@ ORDER BY (match_id, timestamp, event_id)
 TTL toDateTime(timestamp) + INTERVAL 90 DAY
 SETTINGS index_granularity = 8192;
 
+-- Human-readable match details registered via POST /api/matches/{matchId}
+-- team_names maps the numeric team_id (as a string) to a display name
+CREATE TABLE IF NOT EXISTS fanfinity.match_info
+(
+    match_id String,
+    competition String DEFAULT '',
+    team_names Map(String, String),
+    updated_at DateTime64(3) DEFAULT now64(3)
+)
+ENGINE = ReplacingMergeTree(updated_at)
+ORDER BY match_id;
+
+-- Final metrics snapshots of closed matches, stored as JSON. The earliest
+-- snapshot per match is authoritative; events arriving after close are kept in
+-- match_events but do not change it.
+CREATE TABLE IF NOT EXISTS fanfinity.match_final
+(
+    match_id String,
+    metrics String,
+    closed_at DateTime64(3)
+)
+ENGINE = MergeTree()
+ORDER BY (match_id, closed_at);
+
+-- Latest consumer lag per match, published by the consumer after each flush
+-- and read by the API to throttle ingestion for matches that fall behind
+CREATE TABLE IF NOT EXISTS fanfinity.match_lag
+(
+    match_id String,
+    lag_ms UInt64,
+    updated_at DateTime64(3)
+)
+ENGINE = ReplacingMergeTree(updated_at)
+ORDER BY match_id
+TTL toDateTime(updated_at) + INTERVAL 1 DAY;
+
+-- Status of events sent with X-Confirm, published by the consumer
+CREATE TABLE IF NOT EXISTS fanfinity.event_status
+(
+    event_id UUID,
+    match_id String,
+    status LowCardinality(String),
+    updated_at DateTime64(3)
+)
+ENGINE = ReplacingMergeTree(updated_at)
+ORDER BY event_id
+TTL toDateTime(updated_at) + INTERVAL 1 DAY;
+
 -- Materialized view for per-minute aggregations (engagement metrics)
 CREATE TABLE IF NOT EXISTS fanfinity.events_per_minute
 (
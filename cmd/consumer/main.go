@@ -6,14 +6,12 @@ import (
 	"log/slog"
 	"net/http"
 	"os"
-	"os/signal"
-	"syscall"
 	"time"
 
-	"github.com/ClickHouse/clickhouse-go/v2"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/client_golang/prometheus"
 	kafkalib "github.com/segmentio/kafka-go"
 
+	"fanfinity/internal/api"
 	"fanfinity/internal/app"
 	"fanfinity/internal/kafka"
 	"fanfinity/internal/repository"
@@ -23,92 +21,46 @@ import (
 var Version = "dev"
 
 func main() {
-	// Initialize JSON logger for structured logging
-	logger := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
-		Level: slog.LevelInfo,
-	}))
+	// Load configuration from environment, overlaid with CONFIG_FILE if set
+	cfg, err := app.LoadConfigFromFile()
+	if err != nil {
+		slog.Error("failed to load configuration",
+			slog.String("error", err.Error()),
+		)
+		os.Exit(1)
+	}
+
+	// Initialize structured logger at LOG_LEVEL, sampling high-frequency debug lines.
+	// The level and sample rate can be changed on SIGHUP.
+	logger, logControl := app.NewLoggerWithControl(os.Stdout, cfg.Log)
 	slog.SetDefault(logger)
 
+	// Register Prometheus metrics under METRICS_NAMESPACE, labelled with METRICS_TENANT if set
+	metricsOpts := cfg.Metrics.PrometheusOptions()
+	api.RegisterMetrics(prometheus.DefaultRegisterer, metricsOpts)
+	kafka.RegisterMetrics(prometheus.DefaultRegisterer, metricsOpts)
+	repository.RegisterMetrics(prometheus.DefaultRegisterer, metricsOpts)
+
 	logger.Info("starting Fanfinity event consumer",
 		slog.String("version", Version),
 		slog.String("component", "consumer"),
 	)
 
-	// Load configuration from environment
-	cfg := app.LoadConfig()
-
-	// Initialize ClickHouse connection directly
-	chAddr := fmt.Sprintf("%s:%d", cfg.ClickHouse.Host, cfg.ClickHouse.Port)
-	chConn, err := clickhouse.Open(&clickhouse.Options{
-		Addr: []string{chAddr},
-		Auth: clickhouse.Auth{
-			Database: cfg.ClickHouse.Database,
-			Username: cfg.ClickHouse.User,
-			Password: cfg.ClickHouse.Password,
-		},
-		Settings: clickhouse.Settings{
-			"max_execution_time": 60,
-		},
-		Compression: &clickhouse.Compression{
-			Method: clickhouse.CompressionLZ4,
-		},
-		DialTimeout:          10 * time.Second,
-		MaxOpenConns:         10,
-		MaxIdleConns:         5,
-		ConnMaxLifetime:      time.Hour,
-		ConnOpenStrategy:     clickhouse.ConnOpenInOrder,
-		BlockBufferSize:      10,
-		MaxCompressionBuffer: 10240,
-	})
+	// Initialize ClickHouse connection and, unless the rebalance-aware group reader
+	// is enabled below, the Kafka reader for the events topic
+	appCtx, err := app.NewContext(cfg, logger, app.ContextOptions{InitConsumer: !cfg.Consumer.RebalanceDrain})
 	if err != nil {
-		logger.Error("failed to connect to ClickHouse",
-			slog.String("address", chAddr),
+		logger.Error("failed to initialize consumer context",
+			slog.String("address", fmt.Sprintf("%s:%d", cfg.ClickHouse.Host, cfg.ClickHouse.Port)),
+			slog.String("protocol", cfg.ClickHouse.Protocol),
 			slog.String("error", err.Error()),
 		)
 		os.Exit(1)
 	}
 
-	// Verify ClickHouse connection
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	if err := chConn.Ping(ctx); err != nil {
-		cancel()
-		logger.Error("failed to ping ClickHouse",
-			slog.String("address", chAddr),
-			slog.String("error", err.Error()),
-		)
-		os.Exit(1)
-	}
-	cancel()
-
-	logger.Info("ClickHouse connection established",
-		slog.String("address", chAddr),
-		slog.String("database", cfg.ClickHouse.Database),
-	)
-
-	// Create Kafka reader for events topic
-	reader := kafkalib.NewReader(kafkalib.ReaderConfig{
-		Brokers:        []string{cfg.Kafka.BootstrapServers},
-		Topic:          cfg.Kafka.TopicEvents,
-		GroupID:        cfg.Consumer.ConsumerGroup,
-		MinBytes:       1,
-		MaxBytes:       10e6, // 10MB
-		MaxWait:        cfg.Consumer.FlushInterval,
-		CommitInterval: time.Second,
-		StartOffset:    kafkalib.FirstOffset,
-		Dialer: &kafkalib.Dialer{
-			Timeout:   10 * time.Second,
-			DualStack: true,
-		},
-	})
-	logger.Info("Kafka reader created",
-		slog.String("brokers", cfg.Kafka.BootstrapServers),
-		slog.String("topic", cfg.Kafka.TopicEvents),
-		slog.String("group_id", cfg.Consumer.ConsumerGroup),
-	)
-
 	// Create Kafka writer for retry topic
 	retryWriter := &kafkalib.Writer{
-		Addr:         kafkalib.TCP(cfg.Kafka.BootstrapServers),
+		Addr:         kafkalib.TCP(cfg.Kafka.BootstrapServers...),
 		Topic:        cfg.Kafka.TopicRetry,
 		Balancer:     &kafkalib.Hash{},
 		BatchSize:    100,
@@ -123,7 +75,7 @@ func main() {
 
 	// Create Kafka writer for dead letter topic
 	deadWriter := &kafkalib.Writer{
-		Addr:         kafkalib.TCP(cfg.Kafka.BootstrapServers),
+		Addr:         kafkalib.TCP(cfg.Kafka.BootstrapServers...),
 		Topic:        cfg.Kafka.TopicDead,
 		Balancer:     &kafkalib.Hash{},
 		BatchSize:    100,
@@ -137,36 +89,151 @@ func main() {
 	)
 
 	// Create ClickHouse repository
-	repo := repository.NewClickHouseRepository(chConn, logger)
-	logger.Info("ClickHouse repository created")
+	repoCfg := repository.DefaultRepositoryConfig()
+	repoCfg.Database = cfg.ClickHouse.Database
+	repoCfg.Table = cfg.ClickHouse.Table
+	repoCfg.Insert = repository.InsertSettings{
+		Quorum:             cfg.ClickHouse.InsertQuorum,
+		Deduplicate:        cfg.ClickHouse.InsertDeduplicate,
+		DeduplicationToken: cfg.ClickHouse.InsertDeduplicationToken,
+	}
+	repoCfg.InsertMaxExecutionTime = cfg.ClickHouse.InsertMaxExecutionTime
+	repoCfg.OptionalColumns = cfg.ClickHouse.OptionalColumns
+	repo, err := repository.NewClickHouseRepositoryWithConfig(appCtx.ClickHouse, logger, repoCfg)
+	if err != nil {
+		logger.Error("invalid ClickHouse repository configuration",
+			slog.String("error", err.Error()),
+		)
+		os.Exit(1)
+	}
+	logger.Info("ClickHouse repository created",
+		slog.String("table", cfg.ClickHouse.Database+"."+cfg.ClickHouse.Table),
+	)
+
+	// Choose the events reader. The group reader exposes partition revocation so
+	// the in-flight batch is drained before a rebalance moves partitions away.
+	var reader kafka.MessageReader = appCtx.Consumer
+	var groupReader *kafka.GroupReader
+	if cfg.Consumer.RebalanceDrain {
+		fetchCfg, err := app.KafkaReaderConfig(cfg)
+		if err != nil {
+			logger.Error("invalid consumer fetch settings",
+				slog.String("error", err.Error()),
+			)
+			os.Exit(1)
+		}
+		readerCfg := kafka.DefaultReaderConfig(cfg.Kafka.BootstrapServers, cfg.Kafka.TopicEvents, cfg.Consumer.ConsumerGroup)
+		readerCfg.MinBytes = fetchCfg.MinBytes
+		readerCfg.MaxBytes = fetchCfg.MaxBytes
+		readerCfg.MaxWait = fetchCfg.MaxWait
+		groupReader, err = kafka.NewGroupReader(readerCfg, logger)
+		if err != nil {
+			logger.Error("failed to create Kafka group reader",
+				slog.String("error", err.Error()),
+			)
+			os.Exit(1)
+		}
+		reader = groupReader
+		logger.Info("Kafka group reader created",
+			slog.String("topic", cfg.Kafka.TopicEvents),
+			slog.String("group", cfg.Consumer.ConsumerGroup),
+		)
+	}
 
 	// Create batch consumer
+	deadLetterRetention, err := kafka.ParseDeadLetterRetention(cfg.Consumer.DeadLetterRetention)
+	if err != nil {
+		logger.Error("invalid dead letter retention",
+			slog.String("error", err.Error()),
+		)
+		os.Exit(1)
+	}
+
+	// Optionally publish per-match lag for the server's backpressure
+	var lagPublisher kafka.MatchLagPublisher
+	if cfg.Consumer.PublishMatchLag {
+		lagPublisher = repo
+	}
+
+	// Optionally publish the status of events sent with X-Confirm
+	var statusPublisher kafka.StatusPublisher
+	if cfg.Consumer.PublishEventStatus {
+		statusPublisher = repo
+	}
+
+	// Optionally flag matches whose event rate spikes or flatlines
+	var rateAnomalies *kafka.RateAnomalyDetector
+	if cfg.Consumer.RateAnomalyWindow > 0 {
+		rateAnomalies = kafka.NewRateAnomalyDetectorWithConfig(logger, kafka.RateAnomalyConfig{
+			Window:     cfg.Consumer.RateAnomalyWindow,
+			Baseline:   cfg.Consumer.RateAnomalyBaseline,
+			Sigma:      cfg.Consumer.RateAnomalySigma,
+			MinChange:  cfg.Consumer.RateAnomalyMinChange,
+			MaxMatches: cfg.Consumer.RateAnomalyMaxMatches,
+		})
+		logger.Info("Match rate anomaly detection enabled",
+			slog.Duration("window", cfg.Consumer.RateAnomalyWindow),
+			slog.Int("baseline", cfg.Consumer.RateAnomalyBaseline),
+			slog.Float64("sigma", cfg.Consumer.RateAnomalySigma),
+		)
+	}
+
 	consumer := kafka.NewBatchConsumer(kafka.BatchConsumerConfig{
 		Reader:        reader,
 		Repository:    repo,
 		RetryWriter:   retryWriter,
 		DeadWriter:    deadWriter,
 		BatchSize:     cfg.Consumer.BatchSize,
+		MaxBatchBytes: cfg.Consumer.MaxBatchBytes,
 		FlushInterval: cfg.Consumer.FlushInterval,
 		MaxRetries:    cfg.Consumer.MaxRetries,
 		Logger:        logger,
+		DryRun:        cfg.Consumer.DryRun,
+		CheckOrdering: cfg.Consumer.CheckOrdering,
+		RateAnomalies: rateAnomalies,
+
+		ReuseBatchBuffers: cfg.Consumer.ReuseBatchBuffers,
+		UseJSONNumber:     cfg.Validation.UseJSONNumber,
+
+		AdaptiveFlush:    cfg.Consumer.AdaptiveFlush,
+		MinFlushInterval: cfg.Consumer.MinFlushInterval,
+		MaxFlushInterval: cfg.Consumer.MaxFlushInterval,
+
+		DeadLetterRetention:       deadLetterRetention,
+		DeadLetterGzipThreshold:   cfg.Consumer.DeadLetterGzipThreshold,
+		LogFailedEventSample:      cfg.Consumer.LogFailedEventSample,
+		FailedEventSampleMaxBytes: cfg.Consumer.FailedEventSampleMaxBytes,
+		LagPublisher:              lagPublisher,
+		StatusPublisher:           statusPublisher,
 	})
 	logger.Info("batch consumer created",
 		slog.Int("batch_size", cfg.Consumer.BatchSize),
+		slog.Int("max_batch_bytes", cfg.Consumer.MaxBatchBytes),
 		slog.Duration("flush_interval", cfg.Consumer.FlushInterval),
 		slog.Int("max_retries", cfg.Consumer.MaxRetries),
 	)
+	if cfg.Consumer.DryRun {
+		logger.Warn("dry run enabled: events are not inserted and offsets are not committed; use a separate CONSUMER_GROUP so production consumers keep their partitions",
+			slog.String("group", cfg.Consumer.ConsumerGroup),
+		)
+	}
 
-	// Start Prometheus metrics server in a goroutine
+	// Start Prometheus metrics server in a goroutine. With pprof enabled the
+	// write timeout is lifted so CPU profiles and traces can run to completion.
+	metricsWriteTimeout := 10 * time.Second
+	if cfg.Server.EnablePprof {
+		metricsWriteTimeout = 0
+	}
 	metricsServer := &http.Server{
 		Addr:         ":9091",
-		Handler:      promhttp.Handler(),
+		Handler:      api.NewMetricsRouter(cfg.Server.EnablePprof),
 		ReadTimeout:  10 * time.Second,
-		WriteTimeout: 10 * time.Second,
+		WriteTimeout: metricsWriteTimeout,
 	}
 	go func() {
 		logger.Info("metrics server starting",
 			slog.String("address", ":9091"),
+			slog.Bool("pprof_enabled", cfg.Server.EnablePprof),
 		)
 		if err := metricsServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			logger.Error("metrics server error",
@@ -176,13 +243,42 @@ func main() {
 	}()
 
 	// Create a context that will be cancelled on shutdown signal
-	ctx, cancel = context.WithCancel(context.Background())
+	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
 	// Start consumer in a goroutine
 	go func() {
 		consumer.Start(ctx)
 	}()
+	if rateAnomalies != nil {
+		go rateAnomalies.Run(ctx)
+	}
+
+	// Re-read CONFIG_FILE and the environment on SIGHUP, applying the log level,
+	// sample rate, batch size and flush interval without a restart
+	reloader := app.NewReloader(cfg, logger, logControl, consumer)
+	go reloader.WatchReload(ctx)
+
+	// Shutdown hooks run in order before AppContext closes the Kafka reader and
+	// ClickHouse, so the final batch is flushed and committed while both are open
+	appCtx.RegisterShutdownHook("batch consumer", func(context.Context) error {
+		cancel()
+		consumer.Stop()
+		return nil
+	})
+	if groupReader != nil {
+		groupReader.OnRevoke(consumer.Drain)
+		appCtx.RegisterShutdownHook("group reader", func(context.Context) error {
+			return groupReader.Close()
+		})
+	}
+	appCtx.RegisterShutdownHook("retry writer", func(context.Context) error {
+		return retryWriter.Close()
+	})
+	appCtx.RegisterShutdownHook("dead letter writer", func(context.Context) error {
+		return deadWriter.Close()
+	})
+	appCtx.RegisterShutdownHook("metrics server", metricsServer.Shutdown)
 
 	logger.Info("Fanfinity event consumer is running",
 		slog.String("events_topic", cfg.Kafka.TopicEvents),
@@ -190,64 +286,7 @@ func main() {
 		slog.String("dead_topic", cfg.Kafka.TopicDead),
 	)
 
-	// Wait for shutdown signal (SIGINT, SIGTERM)
-	sigCh := make(chan os.Signal, 1)
-	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
-	sig := <-sigCh
-
-	logger.Info("shutdown signal received",
-		slog.String("signal", sig.String()),
-	)
-
-	// Create shutdown timeout context
-	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer shutdownCancel()
-
-	// Cancel the consumer context to trigger graceful shutdown
-	cancel()
-
-	// Stop the consumer (will flush remaining batch)
-	consumer.Stop()
-	logger.Info("consumer stopped")
-
-	// Shutdown metrics server
-	if err := metricsServer.Shutdown(shutdownCtx); err != nil {
-		logger.Error("metrics server shutdown error",
-			slog.String("error", err.Error()),
-		)
-	}
-	logger.Info("metrics server stopped")
-
-	// Close Kafka reader
-	if err := reader.Close(); err != nil {
-		logger.Error("failed to close Kafka reader",
-			slog.String("error", err.Error()),
-		)
-	}
-	logger.Info("Kafka reader closed")
-
-	// Close Kafka writers
-	if err := retryWriter.Close(); err != nil {
-		logger.Error("failed to close retry writer",
-			slog.String("error", err.Error()),
-		)
-	}
-	logger.Info("Kafka retry writer closed")
-
-	if err := deadWriter.Close(); err != nil {
-		logger.Error("failed to close dead writer",
-			slog.String("error", err.Error()),
-		)
-	}
-	logger.Info("Kafka dead letter writer closed")
-
-	// Close ClickHouse connection
-	if err := chConn.Close(); err != nil {
-		logger.Error("failed to close ClickHouse connection",
-			slog.String("error", err.Error()),
-		)
-	}
-	logger.Info("ClickHouse connection closed")
-
+	// Wait for shutdown signal (SIGINT, SIGTERM) and shut down in order
+	app.WaitForShutdown(appCtx, 30*time.Second)
 	logger.Info("Fanfinity event consumer shutdown complete")
 }
@@ -1,38 +1,59 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
 	"net/http"
 	"os"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
+
 	"fanfinity/internal/api"
 	"fanfinity/internal/app"
+	"fanfinity/internal/domain"
+	"fanfinity/internal/inmem"
 	"fanfinity/internal/kafka"
 	"fanfinity/internal/repository"
+	"fanfinity/internal/webhook"
 )
 
 // Version is set at build time via ldflags.
 var Version = "dev"
 
 func main() {
-	// Initialize JSON logger for structured logging
-	logger := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
-		Level: slog.LevelInfo,
-	}))
+	// Load configuration from environment
+	cfg := app.LoadConfig()
+
+	// Initialize structured logger at LOG_LEVEL, sampling high-frequency debug lines
+	logger := app.NewLogger(os.Stdout, cfg.Log)
 	slog.SetDefault(logger)
 
+	// Register Prometheus metrics under METRICS_NAMESPACE, labelled with METRICS_TENANT if set
+	metricsOpts := cfg.Metrics.PrometheusOptions()
+	api.RegisterMetrics(prometheus.DefaultRegisterer, metricsOpts)
+	kafka.RegisterMetrics(prometheus.DefaultRegisterer, metricsOpts)
+	repository.RegisterMetrics(prometheus.DefaultRegisterer, metricsOpts)
+	webhook.RegisterMetrics(prometheus.DefaultRegisterer, metricsOpts)
+
 	logger.Info("starting Fanfinity API server",
 		slog.String("version", Version),
 		slog.String("component", "server"),
 	)
 
-	// Load configuration from environment
-	cfg := app.LoadConfig()
+	// Refuse a half-configured TLS setup before connecting to anything
+	useTLS, err := cfg.Server.UseTLS()
+	if err != nil {
+		logger.Error("invalid TLS configuration",
+			slog.String("error", err.Error()),
+		)
+		os.Exit(1)
+	}
 
 	// Initialize application context (ClickHouse, Kafka producer - NO consumer)
-	// The server only produces events to Kafka; consumption is handled by the standalone consumer
+	// The server only produces events to Kafka; consumption is handled by the standalone consumer.
+	// With STORAGE_BACKEND=memory neither is used
 	appCtx, err := app.NewServerContext(cfg, logger)
 	if err != nil {
 		logger.Error("failed to initialize application context",
@@ -41,30 +62,155 @@ func main() {
 		os.Exit(1)
 	}
 
-	// Create Kafka producer for event ingestion
-	producer := kafka.NewEventProducer(appCtx.Producer, logger)
-	logger.Info("Kafka producer created",
-		slog.String("topic", cfg.Kafka.TopicEvents),
-	)
+	// Parse engagement weights used to score the peak minute
+	weights, err := domain.ParseEngagementWeights(cfg.Metrics.EngagementWeights)
+	if err != nil {
+		logger.Error("invalid engagement weights",
+			slog.String("error", err.Error()),
+		)
+		os.Exit(1)
+	}
 
-	// Create ClickHouse repository for metrics queries
-	repo := repository.NewClickHouseRepository(appCtx.ClickHouse, logger)
-	logger.Info("ClickHouse repository created",
-		slog.String("database", cfg.ClickHouse.Database),
-	)
+	// Parse the phase boundaries metrics are split into
+	phases, err := domain.ParseMatchPhases(cfg.Metrics.MatchPhases)
+	if err != nil {
+		logger.Error("invalid match phases",
+			slog.String("error", err.Error()),
+		)
+		os.Exit(1)
+	}
+
+	// Parse legacy event type aliases accepted at ingestion
+	aliases, err := domain.ParseEventTypeAliases(cfg.Validation.EventTypeAliases)
+	if err != nil {
+		logger.Error("invalid event type aliases",
+			slog.String("error", err.Error()),
+		)
+		os.Exit(1)
+	}
+
+	validationMode, err := domain.ParseValidationMode(cfg.Validation.Mode)
+	if err != nil {
+		logger.Error("invalid validation mode",
+			slog.String("error", err.Error()),
+		)
+		os.Exit(1)
+	}
+
+	// Load the required-metadata policy; it can be reloaded via the admin API
+	policy, err := app.LoadMetadataPolicy(cfg.Validation.RequiredMetadataFile)
+	if err != nil {
+		logger.Error("invalid required metadata policy",
+			slog.String("error", err.Error()),
+		)
+		os.Exit(1)
+	}
+	requiredMetadata := domain.NewMetadataRegistry(policy)
 
 	// Create HTTP router with dependencies
-	router := api.NewRouter(producer, repo, logger)
+	handlerCfg := api.DefaultHandlerConfig()
+	handlerCfg.EngagementWeights = weights
+	handlerCfg.AdminToken = cfg.Server.AdminToken
+	handlerCfg.QueryTimeout = cfg.Server.QueryTimeout
+	handlerCfg.Validation = domain.ValidationOptions{
+		ValidateMinute: cfg.Validation.ValidateMinute,
+		MaxMinute:      cfg.Validation.MaxMinute,
+		MaxTeamID:      cfg.Validation.MaxTeamID,
+
+		EventTypeAliases: aliases,
+		RequiredMetadata: requiredMetadata,
+		Mode:             validationMode,
+	}
+	if cfg.Validation.RequiredMetadataFile != "" {
+		handlerCfg.MetadataPolicyLoader = func() (domain.MetadataPolicy, error) {
+			return app.LoadMetadataPolicy(cfg.Validation.RequiredMetadataFile)
+		}
+	}
+	handlerCfg.MaxQueryTimeout = cfg.Server.MaxQueryTimeout
+	handlerCfg.MetricsCacheTTL = cfg.Metrics.CacheTTL
+	handlerCfg.ServeStaleOnError = cfg.Metrics.ServeStaleOnError
+	handlerCfg.MetricsCacheMaxEntries = cfg.Metrics.CacheMaxEntries
+	handlerCfg.ProduceConcurrency = cfg.Server.ProduceConcurrency
+	handlerCfg.ProduceQueueTimeout = cfg.Server.ProduceQueueTimeout
+	handlerCfg.ProduceTimeout = cfg.Server.ProduceTimeout
+	handlerCfg.MaxDecompressedBytes = cfg.Server.MaxDecompressedBytes
+	handlerCfg.MaxImportBytes = cfg.Server.MaxImportBytes
+	handlerCfg.StreamTimeout = cfg.Server.StreamTimeout
+	handlerCfg.BasePath = cfg.Server.BasePath
+	handlerCfg.OpsAtRoot = cfg.Server.OpsAtRoot
+	handlerCfg.EnablePprof = cfg.Server.EnablePprof
+	handlerCfg.AllowPrettyJSON = cfg.Server.AllowPrettyJSON
+	handlerCfg.UseJSONNumber = cfg.Validation.UseJSONNumber
+
+	// Forward selected event types to the partner webhook, if configured
+	if cfg.Webhook.URL != "" {
+		webhookTypes, err := domain.ParseEventTypes(cfg.Webhook.EventTypes)
+		if err != nil {
+			logger.Error("invalid webhook event types",
+				slog.String("error", err.Error()),
+			)
+			os.Exit(1)
+		}
+		sink := webhook.NewSinkWithConfig(cfg.Webhook.URL, logger, webhook.Config{
+			QueueSize:  cfg.Webhook.QueueSize,
+			MaxRetries: cfg.Webhook.MaxRetries,
+			Timeout:    cfg.Webhook.Timeout,
+		})
+		handlerCfg.EventSinks = append(handlerCfg.EventSinks, sink)
+		handlerCfg.SinkEventTypes = webhookTypes
+		appCtx.RegisterShutdownHook("webhook sink", sink.Close)
+		logger.Info("Webhook sink enabled",
+			slog.String("event_types", cfg.Webhook.EventTypes),
+		)
+	}
+
+	// Keep events in Kafka and ClickHouse, or in memory when running standalone
+	var ingestProducer api.EventProducer
+	var repo api.MetricsRepository
+	if cfg.Server.StorageBackend == app.StorageBackendMemory {
+		store, err := inmem.NewStoreWithConfig(inmem.Config{
+			EngagementWeights: weights,
+			Phases:            phases,
+		})
+		if err != nil {
+			logger.Error("invalid in-memory store configuration",
+				slog.String("error", err.Error()),
+			)
+			os.Exit(1)
+		}
+		ingestProducer, repo = store, store
+		logger.Warn("Using in-memory storage backend, events are lost on restart")
+	} else {
+		ingestProducer, repo, err = newKafkaClickHouseBackend(appCtx, cfg, logger, &handlerCfg, weights, phases)
+		if err != nil {
+			logger.Error("failed to set up Kafka and ClickHouse",
+				slog.String("error", err.Error()),
+			)
+			os.Exit(1)
+		}
+	}
+
+	router := api.NewRouterWithConfig(ingestProducer, repo, logger, handlerCfg)
 	logger.Info("HTTP router created")
 
 	// Configure HTTP server with timeouts from config
 	addr := fmt.Sprintf("%s:%d", cfg.Server.Host, cfg.Server.Port)
-	server := &http.Server{
-		Addr:         addr,
-		Handler:      router,
-		ReadTimeout:  cfg.Server.ReadTimeout,
-		WriteTimeout: cfg.Server.WriteTimeout,
-		IdleTimeout:  cfg.Server.IdleTimeout,
+	server, err := api.NewServerWithConfig(addr, router, api.ServerConfig{
+		ReadTimeout:       cfg.Server.ReadTimeout,
+		ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout,
+		WriteTimeout:      cfg.Server.WriteTimeout,
+		IdleTimeout:       cfg.Server.IdleTimeout,
+		DisableKeepAlives: cfg.Server.DisableKeepAlives,
+
+		HTTP2:                cfg.Server.HTTP2,
+		TLS:                  useTLS,
+		MaxConcurrentStreams: uint32(max(cfg.Server.HTTP2MaxConcurrentStreams, 0)),
+	})
+	if err != nil {
+		logger.Error("invalid HTTP server configuration",
+			slog.String("error", err.Error()),
+		)
+		os.Exit(1)
 	}
 
 	// Store server reference in app context for graceful shutdown
@@ -77,9 +223,17 @@ func main() {
 			slog.Duration("read_timeout", cfg.Server.ReadTimeout),
 			slog.Duration("write_timeout", cfg.Server.WriteTimeout),
 			slog.Duration("idle_timeout", cfg.Server.IdleTimeout),
+			slog.Bool("http2", cfg.Server.HTTP2),
+			slog.Bool("tls", useTLS),
 		)
 
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		var err error
+		if useTLS {
+			err = server.ListenAndServeTLS(cfg.Server.TLSCertFile, cfg.Server.TLSKeyFile)
+		} else {
+			err = server.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
 			logger.Error("HTTP server error",
 				slog.String("error", err.Error()),
 			)
@@ -99,3 +253,170 @@ func main() {
 
 	logger.Info("Fanfinity API server shutdown complete")
 }
+
+// newKafkaClickHouseBackend creates the Kafka producer events are ingested
+// through and the ClickHouse repository metrics are read from, and sets the
+// handler options that depend on them.
+func newKafkaClickHouseBackend(appCtx *app.AppContext, cfg *app.Config, logger *slog.Logger, handlerCfg *api.HandlerConfig, weights domain.EngagementWeights, phases domain.MatchPhases) (api.EventProducer, api.MetricsRepository, error) {
+	// Route selected matches or competitions to their own topics, if configured
+	topicRoutes, err := kafka.ParseTopicRoutes(cfg.Kafka.TopicRoutes)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid Kafka topic routes: %w", err)
+	}
+
+	// Spool events to disk while the broker is unreachable, if configured
+	var spool *kafka.Spool
+	if cfg.Kafka.SpoolPath != "" {
+		spool, err = kafka.OpenSpool(cfg.Kafka.SpoolPath, int64(cfg.Kafka.SpoolMaxBytes))
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to open produce spool %s: %w", cfg.Kafka.SpoolPath, err)
+		}
+		logger.Info("Produce spool enabled",
+			slog.String("path", cfg.Kafka.SpoolPath),
+			slog.Int("max_bytes", cfg.Kafka.SpoolMaxBytes),
+			slog.Int("depth", spool.Depth()),
+		)
+	}
+
+	// Create Kafka producer for event ingestion
+	producer := kafka.NewEventProducerWithConfig(appCtx.Producer, logger, kafka.ProducerConfig{
+		MaxMessageBytes:  cfg.Kafka.MaxMessageBytes,
+		FailureThreshold: cfg.Kafka.ProducerFailureThreshold,
+		ProbeInterval:    cfg.Kafka.ProducerProbeInterval,
+		MaxRetries:       cfg.Kafka.ProducerMaxRetries,
+		RetryBackoff:     cfg.Kafka.ProducerRetryBackoff,
+		DedupBatch:       cfg.Kafka.ProducerDedupBatch,
+		TopicRouter:      kafka.NewTopicRouter(cfg.Kafka.TopicRouteBy, topicRoutes),
+		Spool:            spool,
+	})
+	logger.Info("Kafka producer created",
+		slog.String("topic", cfg.Kafka.TopicEvents),
+		slog.Int("topic_routes", len(topicRoutes)),
+	)
+
+	// Create ClickHouse repository for metrics queries
+	repo, err := repository.NewClickHouseRepositoryWithConfig(appCtx.ClickHouse, logger, repository.RepositoryConfig{
+		EngagementWeights: weights,
+		Phases:            phases,
+		Database:          cfg.ClickHouse.Database,
+		Table:             cfg.ClickHouse.Table,
+
+		InsertMaxExecutionTime: cfg.ClickHouse.InsertMaxExecutionTime,
+		ReadMaxExecutionTime:   cfg.ClickHouse.ReadMaxExecutionTime,
+		ReadFinal:              cfg.ClickHouse.ReadFinal,
+
+		UseJSONNumber: cfg.Validation.UseJSONNumber,
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid ClickHouse repository configuration: %w", err)
+	}
+	logger.Info("ClickHouse repository created",
+		slog.String("database", cfg.ClickHouse.Database),
+		slog.String("table", cfg.ClickHouse.Table),
+	)
+
+	handlerCfg.MessageInspector = kafka.NewMessageInspector(cfg.Kafka.BootstrapServers, cfg.Kafka.TopicEvents)
+	handlerCfg.OffsetResetter = kafka.NewOffsetResetter(cfg.Kafka.BootstrapServers, cfg.Consumer.ConsumerGroup, cfg.Kafka.TopicEvents)
+	handlerCfg.ProducedCounter = producer
+	handlerCfg.HealthCheckers = map[string]api.HealthChecker{
+		"kafka": kafka.NewMetadataChecker(cfg.Kafka.BootstrapServers, cfg.Kafka.TopicEvents),
+	}
+
+	// Hold /ready until the events table is queryable, not just reachable
+	warmup := api.NewWarmup(api.HealthCheckFunc(repo.CheckSchema))
+	handlerCfg.Warmup = warmup
+	warmupCtx, stopWarmup := context.WithCancel(context.Background())
+	go func() {
+		if warmup.Run(warmupCtx, cfg.Server.WarmupInterval) {
+			logger.Info("Startup warmup complete, service is ready")
+		}
+	}()
+	appCtx.RegisterShutdownHook("startup warmup", func(context.Context) error {
+		stopWarmup()
+		return nil
+	})
+
+	// Optionally refuse events of matches the consumer has fallen behind on,
+	// using the lag the consumer publishes to ClickHouse
+	if cfg.Server.MaxMatchLag > 0 {
+		backpressure := api.NewMatchBackpressureWithConfig(repo, logger, api.MatchBackpressureConfig{
+			MaxLag:          cfg.Server.MaxMatchLag,
+			RefreshInterval: cfg.Server.MatchLagRefreshInterval,
+			RetryAfter:      cfg.Server.MatchLagRetryAfter,
+		})
+		handlerCfg.MatchBackpressure = backpressure
+		backpressureCtx, stopBackpressure := context.WithCancel(context.Background())
+		go backpressure.Run(backpressureCtx)
+		appCtx.RegisterShutdownHook("match backpressure", func(context.Context) error {
+			stopBackpressure()
+			return nil
+		})
+		logger.Info("Per-match backpressure enabled",
+			slog.Duration("max_lag", cfg.Server.MaxMatchLag),
+		)
+	}
+
+	// Optionally track events sent with X-Confirm, whose persistence the
+	// consumer publishes to ClickHouse
+	if cfg.Server.Confirmations {
+		handlerCfg.Confirmations = api.NewConfirmationTrackerWithConfig(repo, api.ConfirmationTrackerConfig{
+			Window: cfg.Server.ConfirmationWindow,
+		})
+		logger.Info("Event confirmations enabled",
+			slog.Duration("window", cfg.Server.ConfirmationWindow),
+		)
+	}
+
+	// Optionally micro-batch single-event ingestion. Its hook is registered
+	// before theirs so the final batch is flushed after the HTTP server stops
+	// and before the routed writers, spool and producer close
+	var ingest api.EventProducer = producer
+	if cfg.Server.IngestBatchWindow > 0 {
+		priorityTypes, err := domain.ParseEventTypes(cfg.Server.IngestPriorityTypes)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid ingest priority types: %w", err)
+		}
+		batcher := api.NewProduceBatcherWithConfig(producer, logger, api.ProduceBatcherConfig{
+			Window:        cfg.Server.IngestBatchWindow,
+			MaxBatchSize:  cfg.Server.IngestBatchMaxSize,
+			FlushTimeout:  cfg.Server.ProduceTimeout,
+			PriorityTypes: priorityTypes,
+			Confirmations: handlerCfg.Confirmations,
+		})
+		appCtx.RegisterShutdownHook("ingest batcher", batcher.Close)
+		logger.Info("Ingest micro-batching enabled",
+			slog.Duration("window", cfg.Server.IngestBatchWindow),
+			slog.Int("max_batch_size", cfg.Server.IngestBatchMaxSize),
+			slog.String("priority_types", cfg.Server.IngestPriorityTypes),
+		)
+		ingest = batcher
+	}
+
+	if len(topicRoutes) > 0 {
+		// The default writer is closed by the app context
+		appCtx.RegisterShutdownHook("routed Kafka writers", func(context.Context) error {
+			return producer.CloseRoutedWriters()
+		})
+	}
+	if spool != nil {
+		replayCtx, stopReplay := context.WithCancel(context.Background())
+		replayDone := make(chan struct{})
+		go func() {
+			defer close(replayDone)
+			producer.ReplaySpool(replayCtx, cfg.Kafka.SpoolReplayInterval)
+		}()
+		// Messages still spooled at shutdown are replayed after the next start.
+		// The spool is closed only once a replay in progress has stopped.
+		appCtx.RegisterShutdownHook("produce spool", func(ctx context.Context) error {
+			stopReplay()
+			select {
+			case <-replayDone:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			return spool.Close()
+		})
+	}
+
+	return ingest, repo, nil
+}
@@ -0,0 +1,406 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"fanfinity/internal/domain"
+)
+
+// errReplayProduce marks a failure to produce a replayed chunk, as opposed to a read failure.
+var errReplayProduce = errors.New("failed to produce replayed events")
+
+// ReplayResponse represents the response for a completed match replay.
+type ReplayResponse struct {
+	MatchID  string `json:"matchId"`
+	Replayed int64  `json:"replayed"`
+	Status   string `json:"status"`
+}
+
+// ReplayMatch handles POST /api/admin/matches/{matchId}/replay.
+// It streams the match's stored events from the repository and re-produces them
+// to Kafka in chunks. The optional speed query parameter throttles the replay to
+// at most that many events per second.
+//
+// Replayed events are re-inserted by the consumer, so downstream tables must
+// deduplicate by eventId if they are not being rebuilt from scratch.
+func (h *Handler) ReplayMatch(w http.ResponseWriter, r *http.Request) {
+	matchID, ok := matchIDParam(w, r)
+	if !ok {
+		return
+	}
+
+	var speed float64
+	if raw := r.URL.Query().Get("speed"); raw != "" {
+		parsed, err := strconv.ParseFloat(raw, 64)
+		if err != nil || parsed <= 0 {
+			respondError(w, http.StatusBadRequest, "speed must be a positive number of events per second", "speed")
+			return
+		}
+		speed = parsed
+	}
+
+	ctx := r.Context()
+	chunk := make([]*domain.Event, 0, h.config.ReplayChunkSize)
+	var replayed int64
+
+	flush := func() error {
+		if len(chunk) == 0 {
+			return nil
+		}
+		if err := h.producer.ProduceBatch(ctx, chunk); err != nil {
+			RecordKafkaProduceError()
+			return errReplayProduce
+		}
+		replayed += int64(len(chunk))
+
+		if speed > 0 {
+			pause := time.Duration(float64(len(chunk)) / speed * float64(time.Second))
+			if err := sleepContext(ctx, pause); err != nil {
+				return err
+			}
+		}
+
+		chunk = chunk[:0]
+		return nil
+	}
+
+	err := h.repository.StreamEvents(ctx, matchID, func(event *domain.Event) error {
+		chunk = append(chunk, event)
+		if len(chunk) >= h.config.ReplayChunkSize {
+			return flush()
+		}
+		return nil
+	})
+	if err == nil {
+		err = flush()
+	}
+
+	if err != nil {
+		switch {
+		case errors.Is(err, errReplayProduce):
+			respondError(w, http.StatusServiceUnavailable, "failed to replay events after "+strconv.FormatInt(replayed, 10)+" were produced", "")
+		case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+			respondError(w, http.StatusServiceUnavailable, "replay interrupted after "+strconv.FormatInt(replayed, 10)+" events", "")
+		default:
+			RecordClickHouseQueryError()
+			LoggerFromContext(ctx).Error("failed to read events for replay",
+				slog.String("match_id", matchID),
+				slog.String("error", err.Error()),
+			)
+			respondError(w, http.StatusInternalServerError, "failed to read events", "")
+		}
+		return
+	}
+
+	if replayed == 0 {
+		respondError(w, http.StatusNotFound, "match not found", "")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, ReplayResponse{
+		MatchID:  matchID,
+		Replayed: replayed,
+		Status:   "completed",
+	})
+}
+
+// CloseMatch handles POST /api/admin/matches/{matchId}/close.
+// It stores the match's current metrics as its final snapshot, which the
+// metrics endpoints serve from then on. Events arriving after close are still
+// stored but do not change the official metrics.
+func (h *Handler) CloseMatch(w http.ResponseWriter, r *http.Request) {
+	matchID, ok := matchIDParam(w, r)
+	if !ok {
+		return
+	}
+
+	ctx := r.Context()
+	metrics, err := h.repository.CloseMatch(ctx, matchID)
+	switch {
+	case errors.Is(err, domain.ErrMatchNotFound):
+		respondError(w, http.StatusNotFound, "match not found", "")
+		return
+	case errors.Is(err, domain.ErrMatchClosed):
+		respondError(w, http.StatusConflict, "match already closed", "")
+		return
+	case err != nil:
+		RecordClickHouseQueryError()
+		LoggerFromContext(ctx).Error("failed to close match",
+			slog.String("match_id", matchID),
+			slog.String("error", err.Error()),
+		)
+		respondError(w, http.StatusInternalServerError, "failed to close match", "")
+		return
+	}
+
+	if h.cache != nil {
+		h.cache.Set(matchID, metrics)
+	}
+	respondJSON(w, http.StatusOK, metrics)
+}
+
+// sleepContext pauses for d or until ctx is done, whichever comes first.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// MessageInspector reads raw messages from one partition of the events topic
+// for debugging, without joining the consumer group.
+type MessageInspector interface {
+	InspectMessages(ctx context.Context, partition int, offset int64, limit int) ([]domain.InspectedMessage, error)
+}
+
+// Bounds for the limit query parameter of InspectMessages.
+const (
+	DefaultInspectLimit = 20
+	MaxInspectLimit     = 500
+)
+
+// InspectMessages handles GET /api/admin/messages.
+// It reads up to limit messages from the partition query parameter starting at
+// offset and returns them decoded, for chasing a specific problematic message.
+// The response is a PagedResponse whose cursor is the next offset to read; it
+// may be passed as cursor in place of offset.
+func (h *Handler) InspectMessages(w http.ResponseWriter, r *http.Request) {
+	if h.config.MessageInspector == nil {
+		respondError(w, http.StatusNotFound, "message inspection is not configured", "")
+		return
+	}
+
+	query := r.URL.Query()
+	partition, err := strconv.Atoi(query.Get("partition"))
+	if err != nil || partition < 0 {
+		respondErrorWithField(w, http.StatusBadRequest, "must be a non-negative integer", "partition")
+		return
+	}
+	offsetParam := "offset"
+	if query.Has("cursor") {
+		offsetParam = "cursor"
+	}
+	offset, err := strconv.ParseInt(query.Get(offsetParam), 10, 64)
+	if err != nil || offset < 0 {
+		respondErrorWithField(w, http.StatusBadRequest, "must be a non-negative integer", offsetParam)
+		return
+	}
+	limit := DefaultInspectLimit
+	if raw := query.Get("limit"); raw != "" {
+		limit, err = strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > MaxInspectLimit {
+			respondErrorWithField(w, http.StatusBadRequest, "must be between 1 and "+strconv.Itoa(MaxInspectLimit), "limit")
+			return
+		}
+	}
+
+	// Read one message past the page to know whether there is another
+	messages, err := h.config.MessageInspector.InspectMessages(r.Context(), partition, offset, limit+1)
+	if err != nil {
+		LoggerFromContext(r.Context()).Error("failed to inspect messages",
+			slog.Int("partition", partition),
+			slog.Int64("offset", offset),
+			slog.String("error", err.Error()),
+		)
+		respondError(w, http.StatusServiceUnavailable, "failed to read messages", err.Error())
+		return
+	}
+
+	respondJSON(w, http.StatusOK, NewPagedResponse(messages, limit, func(last domain.InspectedMessage) string {
+		return strconv.FormatInt(last.Offset+1, 10)
+	}))
+}
+
+// OffsetResetter moves the consumer group's committed offsets to a point in
+// time, so the consumers reprocess from there.
+type OffsetResetter interface {
+	ResetOffsets(ctx context.Context, at time.Time) ([]domain.PartitionOffset, error)
+}
+
+// OffsetResetResponse lists the offsets committed by ResetConsumerOffsets.
+type OffsetResetResponse struct {
+	Timestamp  time.Time                `json:"timestamp"`
+	Partitions []domain.PartitionOffset `json:"partitions"`
+}
+
+// ResetConsumerOffsets handles POST /api/admin/consumer/offsets/reset.
+// It commits, for each partition of the events topic, the offset of the first
+// message at or after the timestamp query parameter (RFC3339), so the
+// consumers reprocess from that point when they next start. The consumers must
+// be stopped; Kafka rejects the commit while the group has active members.
+func (h *Handler) ResetConsumerOffsets(w http.ResponseWriter, r *http.Request) {
+	if h.config.OffsetResetter == nil {
+		respondError(w, http.StatusNotFound, "offset reset is not configured", "")
+		return
+	}
+
+	at, err := time.Parse(time.RFC3339, r.URL.Query().Get("timestamp"))
+	if err != nil {
+		respondErrorWithField(w, http.StatusBadRequest, "must be a valid RFC3339 timestamp", "timestamp")
+		return
+	}
+
+	ctx := r.Context()
+	offsets, err := h.config.OffsetResetter.ResetOffsets(ctx, at)
+	if err != nil {
+		LoggerFromContext(ctx).Error("failed to reset consumer offsets",
+			slog.Time("timestamp", at),
+			slog.String("error", err.Error()),
+		)
+		respondError(w, http.StatusServiceUnavailable, "failed to reset consumer offsets", err.Error())
+		return
+	}
+
+	LoggerFromContext(ctx).Warn("consumer offsets reset",
+		slog.Time("timestamp", at),
+		slog.Int("partitions", len(offsets)),
+	)
+	respondJSON(w, http.StatusOK, OffsetResetResponse{Timestamp: at, Partitions: offsets})
+}
+
+// ProducedCounter reports how many events were produced for a match, for
+// comparison with the number stored.
+type ProducedCounter interface {
+	// ProducedCount returns the count and false if the match is not tracked.
+	ProducedCount(matchID string) (int64, bool)
+}
+
+// DefaultConsistencyThreshold is the fraction of produced events that may be
+// missing from, or in excess in, ClickHouse before a match is flagged.
+const DefaultConsistencyThreshold = 0.01
+
+// ConsistencyResponse compares a match's produced and stored event counts.
+// Difference is stored minus produced: negative suggests lost or not yet
+// consumed events, positive suggests duplicates or events produced elsewhere.
+type ConsistencyResponse struct {
+	MatchID    string  `json:"matchId"`
+	Produced   int64   `json:"produced"`
+	Stored     int64   `json:"stored"`
+	Difference int64   `json:"difference"`
+	Threshold  float64 `json:"threshold"`
+	Consistent bool    `json:"consistent"`
+}
+
+// CheckConsistency handles GET /api/admin/matches/{matchId}/consistency.
+// It compares the events this instance produced for the match with the rows
+// stored in ClickHouse, flagging the match when they differ by more than the
+// threshold fraction of produced events (query parameter threshold, default
+// ConsistencyThreshold). Recent events may still be in flight to ClickHouse.
+func (h *Handler) CheckConsistency(w http.ResponseWriter, r *http.Request) {
+	if h.config.ProducedCounter == nil {
+		respondError(w, http.StatusNotFound, "consistency check is not configured", "")
+		return
+	}
+
+	matchID, ok := matchIDParam(w, r)
+	if !ok {
+		return
+	}
+
+	threshold := h.config.ConsistencyThreshold
+	if raw := r.URL.Query().Get("threshold"); raw != "" {
+		parsed, err := strconv.ParseFloat(raw, 64)
+		if err != nil || parsed < 0 || parsed > 1 {
+			respondErrorWithField(w, http.StatusBadRequest, "must be a number between 0 and 1", "threshold")
+			return
+		}
+		threshold = parsed
+	}
+
+	produced, ok := h.config.ProducedCounter.ProducedCount(matchID)
+	if !ok {
+		respondError(w, http.StatusNotFound, "no events produced for match on this instance", "")
+		return
+	}
+
+	ctx, cancel, err := h.queryContext(w, r)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error(), QueryTimeoutHeader)
+		return
+	}
+	defer cancel()
+
+	stored, err := h.repository.CountEvents(ctx, matchID)
+	if err != nil {
+		RecordClickHouseQueryError()
+		LoggerFromContext(ctx).Error("failed to count stored events",
+			slog.String("match_id", matchID),
+			slog.String("error", err.Error()),
+		)
+		respondRepositoryError(w, ctx, err, "event count query timed out", "failed to count stored events")
+		return
+	}
+
+	difference := stored - produced
+	allowed := threshold * float64(produced)
+	consistent := math.Abs(float64(difference)) <= allowed
+	if !consistent {
+		LoggerFromContext(ctx).Warn("produced and stored event counts diverge",
+			slog.String("match_id", matchID),
+			slog.Int64("produced", produced),
+			slog.Int64("stored", stored),
+		)
+	}
+
+	respondJSON(w, http.StatusOK, ConsistencyResponse{
+		MatchID:    matchID,
+		Produced:   produced,
+		Stored:     stored,
+		Difference: difference,
+		Threshold:  threshold,
+		Consistent: consistent,
+	})
+}
+
+// MetadataPolicyResponse describes the required-metadata policy now in effect.
+type MetadataPolicyResponse struct {
+	Status           string              `json:"status"`
+	RequiredMetadata map[string][]string `json:"requiredMetadata"`
+}
+
+// ReloadMetadataPolicy handles POST /api/admin/metadata-policy/reload.
+// It re-reads the required-metadata policy and applies it to new ingestion
+// requests. On failure the previous policy stays in effect.
+func (h *Handler) ReloadMetadataPolicy(w http.ResponseWriter, r *http.Request) {
+	registry := h.config.Validation.RequiredMetadata
+	if h.config.MetadataPolicyLoader == nil || registry == nil {
+		respondError(w, http.StatusNotFound, "metadata policy reload is not configured", "")
+		return
+	}
+
+	policy, err := h.config.MetadataPolicyLoader()
+	if err != nil {
+		LoggerFromContext(r.Context()).Error("failed to reload metadata policy",
+			slog.String("error", err.Error()),
+		)
+		respondError(w, http.StatusUnprocessableEntity, "failed to reload metadata policy, previous policy kept", err.Error())
+		return
+	}
+	registry.Set(policy)
+
+	required := make(map[string][]string, len(policy))
+	for eventType, keys := range policy {
+		required[string(eventType)] = keys
+	}
+	LoggerFromContext(r.Context()).Info("metadata policy reloaded",
+		slog.Int("event_types", len(required)),
+	)
+	respondJSON(w, http.StatusOK, MetadataPolicyResponse{
+		Status:           "reloaded",
+		RequiredMetadata: required,
+	})
+}
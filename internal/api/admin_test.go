@@ -0,0 +1,591 @@
+package api_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"fanfinity/internal/api"
+	"fanfinity/internal/domain"
+)
+
+// streamMatchEvents returns a StreamEvents implementation emitting n events.
+func streamMatchEvents(n int) func(ctx context.Context, matchID string, fn func(*domain.Event) error) error {
+	return func(ctx context.Context, matchID string, fn func(*domain.Event) error) error {
+		for i := 0; i < n; i++ {
+			event := &domain.Event{
+				EventID:   uuid.New(),
+				MatchID:   matchID,
+				EventType: domain.EventTypePass,
+				Timestamp: time.Now().UTC(),
+				TeamID:    1,
+			}
+			if err := fn(event); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// ====================
+// ReplayMatch Tests
+// ====================
+
+func TestReplayMatch_CountAndChunking(t *testing.T) {
+	var chunkSizes []int
+	mockProducer := &MockProducer{
+		ProduceBatchFunc: func(ctx context.Context, events []*domain.Event) error {
+			chunkSizes = append(chunkSizes, len(events))
+			return nil
+		},
+	}
+	mockRepo := &MockRepository{
+		StreamEventsFunc: streamMatchEvents(7),
+	}
+
+	cfg := api.DefaultHandlerConfig()
+	cfg.ReplayChunkSize = 3
+	handler := api.NewHandlerWithConfig(mockProducer, mockRepo, cfg)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/admin/matches/match-123/replay", nil)
+	req = withChiURLParams(req, map[string]string{"matchId": "match-123"})
+
+	rr := httptest.NewRecorder()
+	handler.ReplayMatch(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+
+	var resp api.ReplayResponse
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.Replayed != 7 {
+		t.Errorf("expected 7 events replayed, got %d", resp.Replayed)
+	}
+
+	expected := []int{3, 3, 1}
+	if len(chunkSizes) != len(expected) {
+		t.Fatalf("expected %d chunks, got %d (%v)", len(expected), len(chunkSizes), chunkSizes)
+	}
+	for i, size := range expected {
+		if chunkSizes[i] != size {
+			t.Errorf("chunk %d: expected size %d, got %d", i, size, chunkSizes[i])
+		}
+	}
+}
+
+func TestReplayMatch_NoEvents(t *testing.T) {
+	mockRepo := &MockRepository{
+		StreamEventsFunc: streamMatchEvents(0),
+	}
+	handler := api.NewHandler(&MockProducer{}, mockRepo)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/admin/matches/unknown/replay", nil)
+	req = withChiURLParams(req, map[string]string{"matchId": "unknown"})
+
+	rr := httptest.NewRecorder()
+	handler.ReplayMatch(rr, req)
+
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("expected status %d, got %d", http.StatusNotFound, rr.Code)
+	}
+}
+
+func TestReplayMatch_ProduceError(t *testing.T) {
+	mockProducer := &MockProducer{
+		ProduceBatchFunc: func(ctx context.Context, events []*domain.Event) error {
+			return errors.New("kafka unavailable")
+		},
+	}
+	mockRepo := &MockRepository{
+		StreamEventsFunc: streamMatchEvents(5),
+	}
+	handler := api.NewHandler(mockProducer, mockRepo)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/admin/matches/match-123/replay", nil)
+	req = withChiURLParams(req, map[string]string{"matchId": "match-123"})
+
+	rr := httptest.NewRecorder()
+	handler.ReplayMatch(rr, req)
+
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected status %d, got %d", http.StatusServiceUnavailable, rr.Code)
+	}
+}
+
+func TestReplayMatch_InvalidSpeed(t *testing.T) {
+	handler := api.NewHandler(&MockProducer{}, &MockRepository{})
+
+	req := httptest.NewRequest(http.MethodPost, "/api/admin/matches/match-123/replay?speed=fast", nil)
+	req = withChiURLParams(req, map[string]string{"matchId": "match-123"})
+
+	rr := httptest.NewRecorder()
+	handler.ReplayMatch(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d, got %d", http.StatusBadRequest, rr.Code)
+	}
+}
+
+func TestReplayMatch_RequiresAdminToken(t *testing.T) {
+	mockRepo := &MockRepository{
+		StreamEventsFunc: streamMatchEvents(2),
+	}
+
+	cfg := api.DefaultHandlerConfig()
+	cfg.AdminToken = "secret"
+	router := api.NewRouterWithConfig(&MockProducer{}, mockRepo, slog.Default(), cfg)
+
+	tests := []struct {
+		name           string
+		authorization  string
+		expectedStatus int
+	}{
+		{"missing token", "", http.StatusUnauthorized},
+		{"wrong token", "Bearer nope", http.StatusUnauthorized},
+		{"valid token", "Bearer secret", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/admin/matches/match-123/replay", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, rr.Code)
+			}
+		})
+	}
+}
+
+func TestAdminRoutes_NotMountedWithoutToken(t *testing.T) {
+	router := api.NewRouter(&MockProducer{}, &MockRepository{}, slog.Default())
+
+	req := httptest.NewRequest(http.MethodPost, "/api/admin/matches/match-123/replay", nil)
+	req.Header.Set("Authorization", "Bearer anything")
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("expected status %d, got %d", http.StatusNotFound, rr.Code)
+	}
+}
+
+// ====================
+// ReloadMetadataPolicy Tests
+// ====================
+
+func TestReloadMetadataPolicy(t *testing.T) {
+	registry := domain.NewMetadataRegistry(domain.MetadataPolicy{})
+	next := domain.MetadataPolicy{domain.EventTypeGoal: {"scorer"}}
+	var loadErr error
+
+	cfg := api.DefaultHandlerConfig()
+	cfg.AdminToken = "secret"
+	cfg.Validation.RequiredMetadata = registry
+	cfg.MetadataPolicyLoader = func() (domain.MetadataPolicy, error) {
+		return next, loadErr
+	}
+	router := api.NewRouterWithConfig(&MockProducer{}, &MockRepository{}, slog.Default(), cfg)
+
+	reload := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/admin/metadata-policy/reload", nil)
+		req.Header.Set("Authorization", "Bearer secret")
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := reload()
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	if err := registry.Validate(domain.EventTypeGoal, nil); err == nil {
+		t.Error("expected reloaded policy to require metadata.scorer for goals")
+	}
+
+	// A failed reload keeps the previous policy
+	loadErr = errors.New("unknown event type")
+	rr = reload()
+	if rr.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected status %d, got %d", http.StatusUnprocessableEntity, rr.Code)
+	}
+	if len(registry.Policy()) != 1 {
+		t.Errorf("expected previous policy to stay in effect, got %v", registry.Policy())
+	}
+}
+
+// ====================
+// InspectMessages Tests
+// ====================
+
+// inspectorFunc adapts a function to the MessageInspector interface.
+type inspectorFunc func(ctx context.Context, partition int, offset int64, limit int) ([]domain.InspectedMessage, error)
+
+func (f inspectorFunc) InspectMessages(ctx context.Context, partition int, offset int64, limit int) ([]domain.InspectedMessage, error) {
+	return f(ctx, partition, offset, limit)
+}
+
+func TestInspectMessages(t *testing.T) {
+	var gotPartition, gotLimit int
+	var gotOffset int64
+	cfg := api.DefaultHandlerConfig()
+	cfg.AdminToken = "secret"
+	cfg.MessageInspector = inspectorFunc(func(ctx context.Context, partition int, offset int64, limit int) ([]domain.InspectedMessage, error) {
+		gotPartition, gotOffset, gotLimit = partition, offset, limit
+		return []domain.InspectedMessage{{Partition: partition, Offset: offset, Error: "invalid character"}}, nil
+	})
+	router := api.NewRouterWithConfig(&MockProducer{}, &MockRepository{}, slog.Default(), cfg)
+
+	tests := []struct {
+		name           string
+		query          string
+		authorization  string
+		expectedStatus int
+	}{
+		{"missing token", "?partition=1&offset=5", "", http.StatusUnauthorized},
+		{"default limit", "?partition=1&offset=5", "Bearer secret", http.StatusOK},
+		{"cursor", "?partition=1&cursor=5", "Bearer secret", http.StatusOK},
+		{"missing partition", "?offset=5", "Bearer secret", http.StatusBadRequest},
+		{"negative offset", "?partition=1&offset=-1", "Bearer secret", http.StatusBadRequest},
+		{"invalid cursor", "?partition=1&cursor=abc", "Bearer secret", http.StatusBadRequest},
+		{"limit too large", "?partition=1&offset=5&limit=501", "Bearer secret", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/admin/messages"+tt.query, nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.expectedStatus, rr.Code, rr.Body.String())
+			}
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+
+			// One extra message is requested to detect a further page
+			if gotPartition != 1 || gotOffset != 5 || gotLimit != api.DefaultInspectLimit+1 {
+				t.Errorf("unexpected inspector call: partition=%d offset=%d limit=%d", gotPartition, gotOffset, gotLimit)
+			}
+			var page api.PagedResponse[domain.InspectedMessage]
+			if err := json.NewDecoder(rr.Body).Decode(&page); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if page.Count != 1 || len(page.Data) != 1 || page.Data[0].Error == "" {
+				t.Errorf("unexpected page: %+v", page)
+			}
+		})
+	}
+}
+
+func TestInspectMessages_Pagination(t *testing.T) {
+	const available = 5
+	cfg := api.DefaultHandlerConfig()
+	cfg.AdminToken = "secret"
+	cfg.MessageInspector = inspectorFunc(func(ctx context.Context, partition int, offset int64, limit int) ([]domain.InspectedMessage, error) {
+		var messages []domain.InspectedMessage
+		for o := offset; o < available && len(messages) < limit; o++ {
+			messages = append(messages, domain.InspectedMessage{Partition: partition, Offset: o})
+		}
+		return messages, nil
+	})
+	router := api.NewRouterWithConfig(&MockProducer{}, &MockRepository{}, slog.Default(), cfg)
+
+	fetch := func(query string) api.PagedResponse[domain.InspectedMessage] {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "/api/admin/messages"+query, nil)
+		req.Header.Set("Authorization", "Bearer secret")
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+		}
+		var page api.PagedResponse[domain.InspectedMessage]
+		if err := json.NewDecoder(rr.Body).Decode(&page); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		return page
+	}
+
+	// Full page: more messages remain after it
+	page := fetch("?partition=0&offset=0&limit=3")
+	if page.Count != 3 || len(page.Data) != 3 || !page.HasMore || page.NextCursor != "3" {
+		t.Fatalf("unexpected full page: count=%d hasMore=%v nextCursor=%q", page.Count, page.HasMore, page.NextCursor)
+	}
+
+	// Last page: fewer messages than the limit and no cursor
+	page = fetch("?partition=0&cursor=" + page.NextCursor + "&limit=3")
+	if page.Count != 2 || page.HasMore || page.NextCursor != "" {
+		t.Fatalf("unexpected last page: count=%d hasMore=%v nextCursor=%q", page.Count, page.HasMore, page.NextCursor)
+	}
+	if page.Data[0].Offset != 3 || page.Data[1].Offset != 4 {
+		t.Errorf("expected offsets 3 and 4, got %d and %d", page.Data[0].Offset, page.Data[1].Offset)
+	}
+
+	// An empty page still encodes data as an array
+	page = fetch("?partition=0&offset=10")
+	if page.Data == nil || page.Count != 0 || page.HasMore {
+		t.Errorf("unexpected empty page: %+v", page)
+	}
+}
+
+// ====================
+// CloseMatch Tests
+// ====================
+
+func TestCloseMatch(t *testing.T) {
+	tests := []struct {
+		name           string
+		repoErr        error
+		expectedStatus int
+	}{
+		{name: "closed", expectedStatus: http.StatusOK},
+		{name: "unknown match", repoErr: fmt.Errorf("match x: %w", domain.ErrMatchNotFound), expectedStatus: http.StatusNotFound},
+		{name: "already closed", repoErr: fmt.Errorf("match x: %w", domain.ErrMatchClosed), expectedStatus: http.StatusConflict},
+		{name: "repository error", repoErr: errors.New("database error"), expectedStatus: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			closedAt := time.Now().UTC()
+			mockRepo := &MockRepository{
+				CloseMatchFunc: func(ctx context.Context, matchID string) (*domain.MatchMetrics, error) {
+					if tt.repoErr != nil {
+						return nil, tt.repoErr
+					}
+					return &domain.MatchMetrics{MatchID: matchID, TotalEvents: 10, ClosedAt: &closedAt}, nil
+				},
+			}
+			cfg := api.DefaultHandlerConfig()
+			cfg.AdminToken = "secret"
+			router := api.NewRouterWithConfig(&MockProducer{}, mockRepo, slog.Default(), cfg)
+
+			req := httptest.NewRequest(http.MethodPost, "/api/admin/matches/match-123/close", nil)
+			req.Header.Set("Authorization", "Bearer secret")
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.expectedStatus, rr.Code, rr.Body.String())
+			}
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+
+			var metrics domain.MatchMetrics
+			if err := json.NewDecoder(rr.Body).Decode(&metrics); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if metrics.ClosedAt == nil || metrics.TotalEvents != 10 {
+				t.Errorf("expected closed snapshot, got %+v", metrics)
+			}
+		})
+	}
+}
+
+func TestGetMatchMetrics_ClosedMatchKeepsSnapshotPeak(t *testing.T) {
+	closedAt := time.Now().UTC()
+	peak := &domain.PeakEngagement{Minute: closedAt.Truncate(time.Minute), EventCount: 4, Score: 4}
+	mockRepo := &MockRepository{
+		GetMatchMetricsFunc: func(ctx context.Context, matchID string) (*domain.MatchMetrics, error) {
+			return &domain.MatchMetrics{MatchID: matchID, TotalEvents: 10, PeakMinute: peak, ClosedAt: &closedAt}, nil
+		},
+		GetEventsPerMinuteFunc: func(ctx context.Context, matchID string) ([]domain.EventsPerMinute, error) {
+			t.Error("expected a closed match not to recompute its peak from live events")
+			return nil, nil
+		},
+	}
+	router := api.NewRouter(&MockProducer{}, mockRepo, slog.Default())
+
+	req := httptest.NewRequest(http.MethodGet, "/api/matches/match-123/metrics", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+	var metrics domain.MatchMetrics
+	if err := json.NewDecoder(rr.Body).Decode(&metrics); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if metrics.ClosedAt == nil || metrics.PeakMinute == nil || metrics.PeakMinute.EventCount != 4 {
+		t.Errorf("expected the snapshot's peak minute, got %+v", metrics.PeakMinute)
+	}
+}
+
+// ====================
+// CheckConsistency Tests
+// ====================
+
+// producedCounterFunc adapts a function to the ProducedCounter interface.
+type producedCounterFunc func(matchID string) (int64, bool)
+
+func (f producedCounterFunc) ProducedCount(matchID string) (int64, bool) {
+	return f(matchID)
+}
+
+func TestCheckConsistency(t *testing.T) {
+	tests := []struct {
+		name           string
+		query          string
+		produced       int64
+		tracked        bool
+		stored         int64
+		repoErr        error
+		expectedStatus int
+		wantConsistent bool
+	}{
+		{name: "counts match", produced: 1000, tracked: true, stored: 1000, expectedStatus: http.StatusOK, wantConsistent: true},
+		{name: "within threshold", produced: 1000, tracked: true, stored: 995, expectedStatus: http.StatusOK, wantConsistent: true},
+		{name: "events missing", produced: 1000, tracked: true, stored: 900, expectedStatus: http.StatusOK},
+		{name: "duplicates stored", produced: 1000, tracked: true, stored: 1100, expectedStatus: http.StatusOK},
+		{name: "custom threshold", query: "?threshold=0.2", produced: 1000, tracked: true, stored: 900, expectedStatus: http.StatusOK, wantConsistent: true},
+		{name: "invalid threshold", query: "?threshold=2", produced: 1000, tracked: true, expectedStatus: http.StatusBadRequest},
+		{name: "untracked match", expectedStatus: http.StatusNotFound},
+		{name: "repository error", produced: 10, tracked: true, repoErr: errors.New("database error"), expectedStatus: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := &MockRepository{
+				CountEventsFunc: func(ctx context.Context, matchID string) (int64, error) {
+					return tt.stored, tt.repoErr
+				},
+			}
+			cfg := api.DefaultHandlerConfig()
+			cfg.AdminToken = "secret"
+			cfg.ProducedCounter = producedCounterFunc(func(matchID string) (int64, bool) {
+				return tt.produced, tt.tracked
+			})
+			router := api.NewRouterWithConfig(&MockProducer{}, mockRepo, slog.Default(), cfg)
+
+			req := httptest.NewRequest(http.MethodGet, "/api/admin/matches/match-123/consistency"+tt.query, nil)
+			req.Header.Set("Authorization", "Bearer secret")
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.expectedStatus, rr.Code, rr.Body.String())
+			}
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+
+			var resp api.ConsistencyResponse
+			if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if resp.Consistent != tt.wantConsistent {
+				t.Errorf("expected consistent=%v, got %+v", tt.wantConsistent, resp)
+			}
+			if resp.Produced != tt.produced || resp.Stored != tt.stored || resp.Difference != tt.stored-tt.produced {
+				t.Errorf("unexpected counts: %+v", resp)
+			}
+		})
+	}
+}
+
+func TestCheckConsistency_NotMountedWithoutCounter(t *testing.T) {
+	cfg := api.DefaultHandlerConfig()
+	cfg.AdminToken = "secret"
+	router := api.NewRouterWithConfig(&MockProducer{}, &MockRepository{}, slog.Default(), cfg)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/admin/matches/match-123/consistency", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("expected status 404, got %d", rr.Code)
+	}
+}
+
+// ====================
+// ResetConsumerOffsets Tests
+// ====================
+
+// resetterFunc adapts a function to the OffsetResetter interface.
+type resetterFunc func(ctx context.Context, at time.Time) ([]domain.PartitionOffset, error)
+
+func (f resetterFunc) ResetOffsets(ctx context.Context, at time.Time) ([]domain.PartitionOffset, error) {
+	return f(ctx, at)
+}
+
+func TestResetConsumerOffsets(t *testing.T) {
+	var gotAt time.Time
+	cfg := api.DefaultHandlerConfig()
+	cfg.AdminToken = "secret"
+	cfg.OffsetResetter = resetterFunc(func(ctx context.Context, at time.Time) ([]domain.PartitionOffset, error) {
+		gotAt = at
+		if at.Year() == 2020 {
+			return nil, errors.New("group has active members")
+		}
+		return []domain.PartitionOffset{{Partition: 0, Offset: 42}, {Partition: 1, Offset: 7}}, nil
+	})
+	router := api.NewRouterWithConfig(&MockProducer{}, &MockRepository{}, slog.Default(), cfg)
+
+	tests := []struct {
+		name           string
+		query          string
+		authorization  string
+		expectedStatus int
+	}{
+		{"missing token", "?timestamp=2024-01-15T14:00:00Z", "", http.StatusUnauthorized},
+		{"reset", "?timestamp=2024-01-15T14:00:00Z", "Bearer secret", http.StatusOK},
+		{"missing timestamp", "", "Bearer secret", http.StatusBadRequest},
+		{"invalid timestamp", "?timestamp=yesterday", "Bearer secret", http.StatusBadRequest},
+		{"commit rejected", "?timestamp=2020-01-01T00:00:00Z", "Bearer secret", http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/admin/consumer/offsets/reset"+tt.query, nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.expectedStatus, rr.Code, rr.Body.String())
+			}
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+
+			want := time.Date(2024, 1, 15, 14, 0, 0, 0, time.UTC)
+			if !gotAt.Equal(want) {
+				t.Errorf("expected reset to %s, got %s", want, gotAt)
+			}
+			var resp api.OffsetResetResponse
+			if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if len(resp.Partitions) != 2 || resp.Partitions[0].Offset != 42 {
+				t.Errorf("unexpected response: %+v", resp)
+			}
+		})
+	}
+}
@@ -0,0 +1,155 @@
+package api
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Defaults for per-match backpressure.
+const (
+	DefaultMaxMatchLag             = 30 * time.Second
+	DefaultMatchLagRefreshInterval = 5 * time.Second
+	DefaultMatchLagRetryAfter      = 10 * time.Second
+)
+
+// MatchLagSource reports the consumer lag of matches that are falling behind,
+// as published by the consumer.
+type MatchLagSource interface {
+	// LaggingMatches returns each match whose latest consumer lag is at least minLag.
+	LaggingMatches(ctx context.Context, minLag time.Duration) (map[string]time.Duration, error)
+}
+
+// MatchBackpressureConfig holds settings for MatchBackpressure.
+type MatchBackpressureConfig struct {
+	// MaxLag is the consumer lag above which a match's events are refused.
+	MaxLag time.Duration
+
+	// RefreshInterval is how often the lagging matches are re-read.
+	RefreshInterval time.Duration
+
+	// RetryAfter is sent in the Retry-After header of refused events.
+	RetryAfter time.Duration
+}
+
+// DefaultMatchBackpressureConfig returns the default backpressure configuration.
+func DefaultMatchBackpressureConfig() MatchBackpressureConfig {
+	return MatchBackpressureConfig{
+		MaxLag:          DefaultMaxMatchLag,
+		RefreshInterval: DefaultMatchLagRefreshInterval,
+		RetryAfter:      DefaultMatchLagRetryAfter,
+	}
+}
+
+// MatchBackpressure tells the producers of a single flooding match to slow
+// down without throttling other matches. It keeps the set of matches whose
+// consumer lag exceeds MaxLag in memory, refreshed from a MatchLagSource, and
+// ingestion refuses their events with 429 while they stay lagging. If a
+// refresh fails the previous set is kept.
+type MatchBackpressure struct {
+	source MatchLagSource
+	logger *slog.Logger
+	config MatchBackpressureConfig
+
+	mu      sync.RWMutex
+	lagging map[string]time.Duration
+}
+
+// NewMatchBackpressure creates a MatchBackpressure with the default configuration.
+func NewMatchBackpressure(source MatchLagSource, logger *slog.Logger) *MatchBackpressure {
+	return NewMatchBackpressureWithConfig(source, logger, DefaultMatchBackpressureConfig())
+}
+
+// NewMatchBackpressureWithConfig creates a MatchBackpressure. Zero config
+// values use the defaults. No match is throttled until the first Refresh.
+func NewMatchBackpressureWithConfig(source MatchLagSource, logger *slog.Logger, cfg MatchBackpressureConfig) *MatchBackpressure {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	if cfg.MaxLag <= 0 {
+		cfg.MaxLag = DefaultMaxMatchLag
+	}
+	if cfg.RefreshInterval <= 0 {
+		cfg.RefreshInterval = DefaultMatchLagRefreshInterval
+	}
+	if cfg.RetryAfter <= 0 {
+		cfg.RetryAfter = DefaultMatchLagRetryAfter
+	}
+	return &MatchBackpressure{
+		source: source,
+		logger: logger,
+		config: cfg,
+	}
+}
+
+// Refresh re-reads the matches lagging by more than MaxLag.
+func (b *MatchBackpressure) Refresh(ctx context.Context) error {
+	lagging, err := b.source.LaggingMatches(ctx, b.config.MaxLag)
+	if err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for matchID, lag := range lagging {
+		if _, ok := b.lagging[matchID]; !ok {
+			b.logger.Warn("throttling lagging match",
+				slog.String("match_id", matchID),
+				slog.Duration("lag", lag),
+				slog.Duration("max_lag", b.config.MaxLag),
+			)
+		}
+	}
+	for matchID := range b.lagging {
+		if _, ok := lagging[matchID]; !ok {
+			b.logger.Info("match caught up, no longer throttled",
+				slog.String("match_id", matchID),
+			)
+		}
+	}
+	b.lagging = lagging
+	return nil
+}
+
+// Run refreshes immediately and then every RefreshInterval until ctx is done.
+// Failed refreshes are logged.
+func (b *MatchBackpressure) Run(ctx context.Context) {
+	ticker := time.NewTicker(b.config.RefreshInterval)
+	defer ticker.Stop()
+	for {
+		if err := b.Refresh(ctx); err != nil && ctx.Err() == nil {
+			b.logger.Warn("failed to refresh lagging matches",
+				slog.String("error", err.Error()),
+			)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Lag reports the consumer lag of matchID and whether its events should be refused.
+func (b *MatchBackpressure) Lag(matchID string) (time.Duration, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	lag, ok := b.lagging[matchID]
+	return lag, ok
+}
+
+// RetryAfter returns how long refused producers are asked to wait.
+func (b *MatchBackpressure) RetryAfter() time.Duration {
+	return b.config.RetryAfter
+}
+
+// retryAfterSeconds formats d for a Retry-After header: whole seconds, at least 1.
+func retryAfterSeconds(d time.Duration) string {
+	seconds := int((d + time.Second - 1) / time.Second)
+	if seconds < 1 {
+		seconds = 1
+	}
+	return strconv.Itoa(seconds)
+}
@@ -0,0 +1,116 @@
+package api_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"fanfinity/internal/api"
+	"fanfinity/internal/domain"
+)
+
+// fakeLagSource reports a fixed set of match lags.
+type fakeLagSource struct {
+	lags   map[string]time.Duration
+	minLag time.Duration
+}
+
+func (f *fakeLagSource) LaggingMatches(ctx context.Context, minLag time.Duration) (map[string]time.Duration, error) {
+	f.minLag = minLag
+	lagging := make(map[string]time.Duration)
+	for matchID, lag := range f.lags {
+		if lag >= minLag {
+			lagging[matchID] = lag
+		}
+	}
+	return lagging, nil
+}
+
+func eventJSONForMatch(matchID string) []byte {
+	return []byte(`{
+		"eventId": "` + uuid.New().String() + `",
+		"matchId": "` + matchID + `",
+		"eventType": "goal",
+		"timestamp": "` + time.Now().UTC().Format(time.RFC3339) + `",
+		"teamId": 1
+	}`)
+}
+
+func TestIngestEvent_MatchBackpressure(t *testing.T) {
+	source := &fakeLagSource{lags: map[string]time.Duration{
+		"match-lagging": 2 * time.Minute,
+		"match-normal":  time.Second,
+	}}
+	backpressure := api.NewMatchBackpressureWithConfig(source, nil, api.MatchBackpressureConfig{
+		MaxLag:     30 * time.Second,
+		RetryAfter: 10 * time.Second,
+	})
+	if err := backpressure.Refresh(context.Background()); err != nil {
+		t.Fatalf("unexpected refresh error: %v", err)
+	}
+	if source.minLag != 30*time.Second {
+		t.Errorf("expected lagging matches to be read with min lag 30s, got %v", source.minLag)
+	}
+
+	var produced []string
+	mockProducer := &MockProducer{
+		ProduceFunc: func(ctx context.Context, event *domain.Event) error {
+			produced = append(produced, event.MatchID)
+			return nil
+		},
+	}
+	handler := api.NewHandlerWithConfig(mockProducer, &MockRepository{}, api.HandlerConfig{
+		MatchBackpressure: backpressure,
+	})
+
+	ingest := func(matchID string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/events", bytes.NewReader(eventJSONForMatch(matchID)))
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		handler.IngestEvent(rr, req)
+		return rr
+	}
+
+	t.Run("high-lag match is refused", func(t *testing.T) {
+		rr := ingest("match-lagging")
+		if rr.Code != http.StatusTooManyRequests {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusTooManyRequests, rr.Code, rr.Body.String())
+		}
+		if got := rr.Header().Get("Retry-After"); got != "10" {
+			t.Errorf("expected Retry-After 10, got %q", got)
+		}
+		var resp api.ErrorResponse
+		if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		if resp.Code != api.ErrCodeMatchLagging {
+			t.Errorf("expected code %q, got %q", api.ErrCodeMatchLagging, resp.Code)
+		}
+	})
+
+	t.Run("normal match is accepted", func(t *testing.T) {
+		rr := ingest("match-normal")
+		if rr.Code != http.StatusAccepted {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusAccepted, rr.Code, rr.Body.String())
+		}
+	})
+
+	if len(produced) != 1 || produced[0] != "match-normal" {
+		t.Errorf("expected only the normal match to be produced, got %v", produced)
+	}
+
+	// Once the match catches up its events are accepted again
+	source.lags["match-lagging"] = 5 * time.Second
+	if err := backpressure.Refresh(context.Background()); err != nil {
+		t.Fatalf("unexpected refresh error: %v", err)
+	}
+	if rr := ingest("match-lagging"); rr.Code != http.StatusAccepted {
+		t.Errorf("expected status %d after catching up, got %d", http.StatusAccepted, rr.Code)
+	}
+}
@@ -0,0 +1,327 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"fanfinity/internal/domain"
+)
+
+// Defaults for the ingestion micro-batcher.
+const (
+	DefaultBatchWindow       = 20 * time.Millisecond
+	DefaultBatchMaxSize      = 100
+	DefaultBatchFlushTimeout = 5 * time.Second
+)
+
+// ErrBatcherClosed is returned when enqueueing to a closed ProduceBatcher.
+var ErrBatcherClosed = errors.New("produce batcher is closed")
+
+// ProduceBatcherConfig holds settings for a ProduceBatcher.
+type ProduceBatcherConfig struct {
+	// Window is how long the first event of a batch waits for others to join it.
+	Window time.Duration
+
+	// MaxBatchSize flushes a batch early once it holds this many events.
+	MaxBatchSize int
+
+	// QueueSize bounds events waiting to join a batch (0 = 10 * MaxBatchSize).
+	// Enqueueing blocks while the queue is full.
+	QueueSize int
+
+	// FlushTimeout bounds each ProduceBatch call.
+	FlushTimeout time.Duration
+
+	// PriorityTypes are event types, such as goals, that skip the window: they
+	// are produced as soon as they arrive, ahead of any pending batch, rather
+	// than waiting to join one. Nil batches every event.
+	PriorityTypes map[domain.EventType]bool
+
+	// Confirmations, when set, has events sent with X-Confirm marked failed
+	// when their batch fails, so their status does not stay accepted.
+	Confirmations *ConfirmationTracker
+}
+
+// DefaultProduceBatcherConfig returns the default micro-batcher configuration.
+func DefaultProduceBatcherConfig() ProduceBatcherConfig {
+	return ProduceBatcherConfig{
+		Window:       DefaultBatchWindow,
+		MaxBatchSize: DefaultBatchMaxSize,
+		FlushTimeout: DefaultBatchFlushTimeout,
+	}
+}
+
+// pendingEvent is an event waiting in the batcher and where its result goes.
+type pendingEvent struct {
+	event  *domain.Event
+	result chan error
+}
+
+// ProduceBatcher is an EventProducer that buffers single events for a short
+// window and produces them together with ProduceBatch, trading a little
+// latency for far fewer Kafka round trips. Produce waits for the batch its
+// event joined, so a failed batch fails each of its events' requests; failed
+// batches are also logged with their event IDs. Events of the configured
+// priority types take a separate lane that is flushed immediately, so they
+// are not delayed behind routine events.
+type ProduceBatcher struct {
+	producer EventProducer
+	logger   *slog.Logger
+	config   ProduceBatcherConfig
+
+	// mu guards closed. Enqueue holds it only to register in senders, never
+	// while waiting for queue space, so Close cannot be blocked by a full queue.
+	mu      sync.RWMutex
+	closed  bool
+	closing chan struct{}
+	senders sync.WaitGroup
+
+	queue    chan pendingEvent
+	priority chan pendingEvent
+	done     chan struct{}
+}
+
+// NewProduceBatcher creates a ProduceBatcher with the default configuration.
+func NewProduceBatcher(producer EventProducer, logger *slog.Logger) *ProduceBatcher {
+	return NewProduceBatcherWithConfig(producer, logger, DefaultProduceBatcherConfig())
+}
+
+// NewProduceBatcherWithConfig creates a ProduceBatcher and starts its flush loop.
+// Zero config values use the defaults. Call Close to flush and stop it.
+func NewProduceBatcherWithConfig(producer EventProducer, logger *slog.Logger, cfg ProduceBatcherConfig) *ProduceBatcher {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	if cfg.Window <= 0 {
+		cfg.Window = DefaultBatchWindow
+	}
+	if cfg.MaxBatchSize <= 0 {
+		cfg.MaxBatchSize = DefaultBatchMaxSize
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = 10 * cfg.MaxBatchSize
+	}
+	if cfg.FlushTimeout <= 0 {
+		cfg.FlushTimeout = DefaultBatchFlushTimeout
+	}
+
+	b := &ProduceBatcher{
+		producer: producer,
+		logger:   logger,
+		config:   cfg,
+		closing:  make(chan struct{}),
+		queue:    make(chan pendingEvent, cfg.QueueSize),
+		priority: make(chan pendingEvent, cfg.QueueSize),
+		done:     make(chan struct{}),
+	}
+	var lanes sync.WaitGroup
+	lanes.Add(2)
+	go func() {
+		defer lanes.Done()
+		b.run()
+	}()
+	go func() {
+		defer lanes.Done()
+		b.runPriority()
+	}()
+	go func() {
+		lanes.Wait()
+		close(b.done)
+	}()
+	return b
+}
+
+// Produce enqueues event for the next batch and waits for that batch to be
+// produced, returning its error. It also fails if the batcher is closed or
+// ctx is done first.
+func (b *ProduceBatcher) Produce(ctx context.Context, event *domain.Event) error {
+	result, err := b.Enqueue(ctx, event)
+	if err != nil {
+		return err
+	}
+	select {
+	case err := <-result:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// ProduceBatch produces events directly; they are already a batch.
+func (b *ProduceBatcher) ProduceBatch(ctx context.Context, events []*domain.Event) error {
+	return b.producer.ProduceBatch(ctx, events)
+}
+
+// Enqueue adds event to the next batch, or to the priority lane for a
+// priority type, and returns a channel that receives the result of producing
+// the batch it joined.
+func (b *ProduceBatcher) Enqueue(ctx context.Context, event *domain.Event) (<-chan error, error) {
+	b.mu.RLock()
+	if b.closed {
+		b.mu.RUnlock()
+		return nil, ErrBatcherClosed
+	}
+	b.senders.Add(1)
+	b.mu.RUnlock()
+	defer b.senders.Done()
+
+	queue := b.queue
+	if b.config.PriorityTypes[event.EventType] {
+		queue = b.priority
+	}
+	p := pendingEvent{event: event, result: make(chan error, 1)}
+	select {
+	case queue <- p:
+		return p.result, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-b.closing:
+		return nil, ErrBatcherClosed
+	}
+}
+
+// Close stops accepting events and flushes those already enqueued, waiting
+// until the final batch is produced or ctx is done.
+func (b *ProduceBatcher) Close(ctx context.Context) error {
+	b.mu.Lock()
+	if !b.closed {
+		b.closed = true
+		close(b.closing)
+	}
+	b.mu.Unlock()
+
+	select {
+	case <-b.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// run collects enqueued events into batches, flushing each when it reaches
+// MaxBatchSize or its window elapses, and flushes the rest once closed.
+func (b *ProduceBatcher) run() {
+	batch := make([]pendingEvent, 0, b.config.MaxBatchSize)
+	timer := time.NewTimer(b.config.Window)
+	stopTimer(timer)
+	var windowC <-chan time.Time
+
+	for {
+		select {
+		case p := <-b.queue:
+			batch = append(batch, p)
+			if len(batch) == 1 {
+				timer.Reset(b.config.Window)
+				windowC = timer.C
+			}
+			if len(batch) >= b.config.MaxBatchSize {
+				stopTimer(timer)
+				windowC = nil
+				b.flush(batch)
+				batch = batch[:0]
+			}
+		case <-windowC:
+			windowC = nil
+			b.flush(batch)
+			batch = batch[:0]
+		case <-b.closing:
+			// No Enqueue can start after closing, so once those in flight
+			// have returned the queue only shrinks
+			b.senders.Wait()
+			for len(b.queue) > 0 {
+				batch = append(batch, <-b.queue)
+				if len(batch) >= b.config.MaxBatchSize {
+					b.flush(batch)
+					batch = batch[:0]
+				}
+			}
+			b.flush(batch)
+			return
+		}
+	}
+}
+
+// runPriority flushes priority events as they arrive, on its own so they are
+// never held up by a routine batch being produced.
+func (b *ProduceBatcher) runPriority() {
+	for {
+		select {
+		case p := <-b.priority:
+			b.flushPriority(p)
+		case <-b.closing:
+			b.senders.Wait()
+			for len(b.priority) > 0 {
+				b.flushPriority(<-b.priority)
+			}
+			return
+		}
+	}
+}
+
+// stopTimer stops t and drains a fire that raced the stop, so a later Reset
+// starts a full window.
+func stopTimer(t *time.Timer) {
+	if !t.Stop() {
+		select {
+		case <-t.C:
+		default:
+		}
+	}
+}
+
+// flushPriority produces p together with any other priority events already
+// waiting, up to MaxBatchSize.
+func (b *ProduceBatcher) flushPriority(p pendingEvent) {
+	batch := []pendingEvent{p}
+	for len(batch) < b.config.MaxBatchSize {
+		select {
+		case next := <-b.priority:
+			batch = append(batch, next)
+		default:
+			b.flush(batch)
+			return
+		}
+	}
+	b.flush(batch)
+}
+
+// flush produces batch and delivers the result to each of its events.
+func (b *ProduceBatcher) flush(batch []pendingEvent) {
+	if len(batch) == 0 {
+		return
+	}
+	events := make([]*domain.Event, len(batch))
+	for i, p := range batch {
+		events[i] = p.event
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), b.config.FlushTimeout)
+	err := b.producer.ProduceBatch(ctx, events)
+	cancel()
+
+	if err != nil {
+		RecordKafkaProduceError()
+		eventIDs := make([]string, len(events))
+		for i, event := range events {
+			eventIDs[i] = event.EventID.String()
+		}
+		b.logger.Error("failed to produce batched events",
+			slog.Int("batch_size", len(events)),
+			slog.Any("event_ids", eventIDs),
+			slog.String("error", err.Error()),
+		)
+		if confirmations := b.config.Confirmations; confirmations != nil {
+			for _, event := range events {
+				if event.Confirm {
+					confirmations.Fail(event.EventID)
+				}
+			}
+		}
+	}
+	for _, p := range batch {
+		p.result <- err
+	}
+}
@@ -0,0 +1,331 @@
+package api_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"fanfinity/internal/api"
+	"fanfinity/internal/domain"
+)
+
+// batchRecorder is a MockProducer that publishes every ProduceBatch call on a channel.
+func batchRecorder(err error) (*MockProducer, chan []*domain.Event) {
+	batches := make(chan []*domain.Event, 10)
+	return &MockProducer{
+		ProduceBatchFunc: func(ctx context.Context, events []*domain.Event) error {
+			batches <- events
+			return err
+		},
+	}, batches
+}
+
+func newBatcher(producer api.EventProducer, cfg api.ProduceBatcherConfig) *api.ProduceBatcher {
+	return api.NewProduceBatcherWithConfig(producer, slog.New(slog.NewTextHandler(io.Discard, nil)), cfg)
+}
+
+func batchEvent() *domain.Event {
+	return &domain.Event{EventID: uuid.New(), MatchID: "match-123", EventType: domain.EventTypePass, TeamID: 1}
+}
+
+func receiveBatch(t *testing.T, batches <-chan []*domain.Event) []*domain.Event {
+	t.Helper()
+	select {
+	case batch := <-batches:
+		return batch
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for a batch")
+		return nil
+	}
+}
+
+func TestProduceBatcher_FlushBySize(t *testing.T) {
+	producer, batches := batchRecorder(nil)
+	batcher := newBatcher(producer, api.ProduceBatcherConfig{Window: time.Hour, MaxBatchSize: 3})
+	defer batcher.Close(context.Background())
+
+	var results []<-chan error
+	for i := 0; i < 3; i++ {
+		result, err := batcher.Enqueue(context.Background(), batchEvent())
+		if err != nil {
+			t.Fatalf("unexpected enqueue error: %v", err)
+		}
+		results = append(results, result)
+	}
+
+	if batch := receiveBatch(t, batches); len(batch) != 3 {
+		t.Errorf("expected a full batch of 3 events, got %d", len(batch))
+	}
+	for i, result := range results {
+		if err := <-result; err != nil {
+			t.Errorf("event %d: unexpected result %v", i, err)
+		}
+	}
+}
+
+func TestProduceBatcher_FlushByWindow(t *testing.T) {
+	producer, batches := batchRecorder(nil)
+	batcher := newBatcher(producer, api.ProduceBatcherConfig{Window: 20 * time.Millisecond, MaxBatchSize: 100})
+	defer batcher.Close(context.Background())
+
+	start := time.Now()
+	for i := 0; i < 2; i++ {
+		if _, err := batcher.Enqueue(context.Background(), batchEvent()); err != nil {
+			t.Fatalf("unexpected enqueue error: %v", err)
+		}
+	}
+
+	batch := receiveBatch(t, batches)
+	if len(batch) != 2 {
+		t.Errorf("expected the 2 buffered events in one batch, got %d", len(batch))
+	}
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Errorf("expected the batch to wait for its window, flushed after %v", elapsed)
+	}
+}
+
+func TestProduceBatcher_CorrelatesFailures(t *testing.T) {
+	produceErr := errors.New("broker unavailable")
+	producer, batches := batchRecorder(produceErr)
+	batcher := newBatcher(producer, api.ProduceBatcherConfig{Window: time.Hour, MaxBatchSize: 2})
+	defer batcher.Close(context.Background())
+
+	first, _ := batcher.Enqueue(context.Background(), batchEvent())
+	second, _ := batcher.Enqueue(context.Background(), batchEvent())
+	receiveBatch(t, batches)
+
+	for _, result := range []<-chan error{first, second} {
+		if err := <-result; !errors.Is(err, produceErr) {
+			t.Errorf("expected each event to receive the batch error, got %v", err)
+		}
+	}
+}
+
+func TestProduceBatcher_CloseFlushesPending(t *testing.T) {
+	producer, batches := batchRecorder(nil)
+	batcher := newBatcher(producer, api.ProduceBatcherConfig{Window: time.Hour, MaxBatchSize: 100})
+
+	event := batchEvent()
+	result, err := batcher.Enqueue(context.Background(), event)
+	if err != nil {
+		t.Fatalf("unexpected enqueue error: %v", err)
+	}
+	if err := batcher.Close(context.Background()); err != nil {
+		t.Fatalf("unexpected close error: %v", err)
+	}
+
+	if batch := receiveBatch(t, batches); len(batch) != 1 || batch[0].EventID != event.EventID {
+		t.Errorf("expected the pending event to be flushed on close, got %v", batch)
+	}
+	if err := <-result; err != nil {
+		t.Errorf("unexpected result %v", err)
+	}
+	if err := batcher.Produce(context.Background(), batchEvent()); !errors.Is(err, api.ErrBatcherClosed) {
+		t.Errorf("expected ErrBatcherClosed after close, got %v", err)
+	}
+}
+
+func TestProduceBatcher_PriorityLane(t *testing.T) {
+	producer, batches := batchRecorder(nil)
+	batcher := newBatcher(producer, api.ProduceBatcherConfig{
+		Window:        time.Hour,
+		MaxBatchSize:  100,
+		PriorityTypes: map[domain.EventType]bool{domain.EventTypeGoal: true},
+	})
+
+	var passes []<-chan error
+	for i := 0; i < 3; i++ {
+		result, err := batcher.Enqueue(context.Background(), batchEvent())
+		if err != nil {
+			t.Fatalf("unexpected enqueue error: %v", err)
+		}
+		passes = append(passes, result)
+	}
+	goal := batchEvent()
+	goal.EventType = domain.EventTypeGoal
+	goalResult, err := batcher.Enqueue(context.Background(), goal)
+	if err != nil {
+		t.Fatalf("unexpected enqueue error: %v", err)
+	}
+
+	// The goal is produced on its own while the passes wait for their window
+	first := receiveBatch(t, batches)
+	if len(first) != 1 || first[0].EventID != goal.EventID {
+		t.Fatalf("expected the goal to be flushed first on its own, got %d events", len(first))
+	}
+	if err := <-goalResult; err != nil {
+		t.Errorf("unexpected goal result %v", err)
+	}
+	select {
+	case <-passes[0]:
+		t.Fatal("expected the passes to still be waiting for their window")
+	default:
+	}
+
+	if err := batcher.Close(context.Background()); err != nil {
+		t.Fatalf("unexpected close error: %v", err)
+	}
+	if rest := receiveBatch(t, batches); len(rest) != 3 {
+		t.Errorf("expected the 3 passes in one batch, got %d", len(rest))
+	}
+	for i, result := range passes {
+		if err := <-result; err != nil {
+			t.Errorf("pass %d: unexpected result %v", i, err)
+		}
+	}
+}
+
+func TestProduceBatcher_PriorityNotBlockedByFlush(t *testing.T) {
+	release := make(chan struct{})
+	goals := make(chan []*domain.Event, 1)
+	producer := &MockProducer{
+		ProduceBatchFunc: func(ctx context.Context, events []*domain.Event) error {
+			if events[0].EventType == domain.EventTypeGoal {
+				goals <- events
+				return nil
+			}
+			<-release
+			return nil
+		},
+	}
+	batcher := newBatcher(producer, api.ProduceBatcherConfig{
+		Window:        time.Hour,
+		MaxBatchSize:  1,
+		PriorityTypes: map[domain.EventType]bool{domain.EventTypeGoal: true},
+	})
+	defer batcher.Close(context.Background())
+	defer close(release)
+
+	// The pass fills a batch whose produce hangs
+	if _, err := batcher.Enqueue(context.Background(), batchEvent()); err != nil {
+		t.Fatalf("unexpected enqueue error: %v", err)
+	}
+	goal := batchEvent()
+	goal.EventType = domain.EventTypeGoal
+	if _, err := batcher.Enqueue(context.Background(), goal); err != nil {
+		t.Fatalf("unexpected enqueue error: %v", err)
+	}
+
+	if batch := receiveBatch(t, goals); len(batch) != 1 || batch[0].EventID != goal.EventID {
+		t.Errorf("expected the goal to be produced while the pass batch hangs, got %v", batch)
+	}
+}
+
+func TestProduceBatcher_ProduceReturnsBatchError(t *testing.T) {
+	produceErr := errors.New("broker unavailable")
+	producer, _ := batchRecorder(produceErr)
+	batcher := newBatcher(producer, api.ProduceBatcherConfig{Window: time.Millisecond, MaxBatchSize: 100})
+	defer batcher.Close(context.Background())
+
+	if err := batcher.Produce(context.Background(), batchEvent()); !errors.Is(err, produceErr) {
+		t.Errorf("expected Produce to return the batch error, got %v", err)
+	}
+}
+
+func TestProduceBatcher_CloseWithFullQueue(t *testing.T) {
+	release := make(chan struct{})
+	producer := &MockProducer{
+		ProduceBatchFunc: func(ctx context.Context, events []*domain.Event) error {
+			<-release
+			return nil
+		},
+	}
+	batcher := newBatcher(producer, api.ProduceBatcherConfig{Window: time.Hour, MaxBatchSize: 1, QueueSize: 1})
+
+	// The first event is stuck in a flush and the second fills the queue
+	var results []<-chan error
+	for i := 0; i < 2; i++ {
+		result, err := batcher.Enqueue(context.Background(), batchEvent())
+		if err != nil {
+			t.Fatalf("unexpected enqueue error: %v", err)
+		}
+		results = append(results, result)
+	}
+	blocked := make(chan error, 1)
+	go func() {
+		_, err := batcher.Enqueue(context.Background(), batchEvent())
+		blocked <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	// Close gives up with its context rather than waiting on the blocked sender
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	closed := make(chan error, 1)
+	go func() { closed <- batcher.Close(ctx) }()
+	select {
+	case err := <-closed:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("expected Close to return its context error, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Close blocked behind an enqueue waiting on a full queue")
+	}
+	select {
+	case err := <-blocked:
+		if !errors.Is(err, api.ErrBatcherClosed) {
+			t.Errorf("expected the blocked enqueue to get ErrBatcherClosed, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("enqueue still blocked after Close")
+	}
+
+	close(release)
+	if err := batcher.Close(context.Background()); err != nil {
+		t.Fatalf("unexpected close error: %v", err)
+	}
+	for i, result := range results {
+		if err := <-result; err != nil {
+			t.Errorf("event %d: unexpected result %v", i, err)
+		}
+	}
+}
+
+func TestIngestEvent_Batched(t *testing.T) {
+	producer, batches := batchRecorder(nil)
+	batcher := newBatcher(producer, api.ProduceBatcherConfig{Window: 10 * time.Millisecond, MaxBatchSize: 100})
+	defer batcher.Close(context.Background())
+	handler := api.NewHandler(batcher, &MockRepository{})
+
+	req := httptest.NewRequest(http.MethodPost, "/api/events", bytes.NewReader(validEventJSON()))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	handler.IngestEvent(rr, req)
+
+	// Accepted only once its batch is produced
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusAccepted, rr.Code, rr.Body.String())
+	}
+	select {
+	case batch := <-batches:
+		if len(batch) != 1 {
+			t.Errorf("expected the ingested event in the batch, got %d events", len(batch))
+		}
+	default:
+		t.Fatal("expected the batch to be produced before the response")
+	}
+}
+
+func TestIngestEvent_BatchFailure(t *testing.T) {
+	producer, _ := batchRecorder(errors.New("broker unavailable"))
+	batcher := newBatcher(producer, api.ProduceBatcherConfig{Window: time.Millisecond, MaxBatchSize: 100})
+	defer batcher.Close(context.Background())
+	handler := api.NewHandler(batcher, &MockRepository{})
+
+	req := httptest.NewRequest(http.MethodPost, "/api/events", bytes.NewReader(validEventJSON()))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	handler.IngestEvent(rr, req)
+
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected status %d for a failed batch, got %d", http.StatusServiceUnavailable, rr.Code)
+	}
+}
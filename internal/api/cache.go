@@ -0,0 +1,95 @@
+package api
+
+import (
+	"maps"
+	"sync"
+	"time"
+
+	"fanfinity/internal/domain"
+)
+
+// DefaultMetricsCacheMaxEntries bounds the matches the metrics cache holds.
+const DefaultMetricsCacheMaxEntries = 10000
+
+// metricsCacheEntry is a cached metrics response and when it was stored.
+type metricsCacheEntry struct {
+	metrics  domain.MatchMetrics
+	cachedAt time.Time
+}
+
+// metricsCache holds the last successful metrics response per match, for at
+// most maxEntries matches. Once full, storing a new match evicts the match
+// cached longest ago.
+type metricsCache struct {
+	mu         sync.RWMutex
+	entries    map[string]metricsCacheEntry
+	maxEntries int
+	clock      domain.Clock
+}
+
+// newMetricsCache creates an empty metrics cache of at most maxEntries matches
+// that ages entries by clock.
+func newMetricsCache(maxEntries int, clock domain.Clock) *metricsCache {
+	return &metricsCache{
+		entries:    make(map[string]metricsCacheEntry),
+		maxEntries: maxEntries,
+		clock:      domain.ClockOrSystem(clock),
+	}
+}
+
+// Get returns the cached metrics for a match and when they were stored,
+// regardless of age. The returned metrics, maps included, are a copy safe to
+// modify; values behind pointer fields are shared and must not be changed.
+func (c *metricsCache) Get(matchID string) (*domain.MatchMetrics, time.Time, bool) {
+	c.mu.RLock()
+	entry, ok := c.entries[matchID]
+	c.mu.RUnlock()
+	if !ok {
+		return nil, time.Time{}, false
+	}
+	return cloneMetrics(&entry.metrics), entry.cachedAt, true
+}
+
+// GetFresh returns the cached metrics for a match if they are younger than ttl.
+func (c *metricsCache) GetFresh(matchID string, ttl time.Duration) (*domain.MatchMetrics, bool) {
+	metrics, cachedAt, ok := c.Get(matchID)
+	if !ok || c.clock.Now().Sub(cachedAt) >= ttl {
+		return nil, false
+	}
+	return metrics, true
+}
+
+// Set stores a copy of the metrics for a match.
+func (c *metricsCache) Set(matchID string, metrics *domain.MatchMetrics) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.entries[matchID]; !ok && len(c.entries) >= c.maxEntries {
+		c.evictOldest()
+	}
+	c.entries[matchID] = metricsCacheEntry{
+		metrics:  *cloneMetrics(metrics),
+		cachedAt: c.clock.Now(),
+	}
+}
+
+// evictOldest removes the entry cached longest ago. The caller must hold mu.
+func (c *metricsCache) evictOldest() {
+	var oldestID string
+	var oldestAt time.Time
+	for matchID, entry := range c.entries {
+		if oldestID == "" || entry.cachedAt.Before(oldestAt) {
+			oldestID, oldestAt = matchID, entry.cachedAt
+		}
+	}
+	delete(c.entries, oldestID)
+}
+
+// cloneMetrics copies m along with its maps.
+func cloneMetrics(m *domain.MatchMetrics) *domain.MatchMetrics {
+	c := *m
+	c.EventsByType = maps.Clone(m.EventsByType)
+	c.ByPhase = maps.Clone(m.ByPhase)
+	c.TeamNames = maps.Clone(m.TeamNames)
+	return &c
+}
@@ -0,0 +1,51 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"fanfinity/internal/domain"
+)
+
+func TestMetricsCache_EvictsOldestWhenFull(t *testing.T) {
+	clock := domain.NewFixedClock(time.Date(2024, 1, 15, 14, 0, 0, 0, time.UTC))
+	cache := newMetricsCache(2, clock)
+
+	for _, matchID := range []string{"match-1", "match-2"} {
+		cache.Set(matchID, &domain.MatchMetrics{MatchID: matchID})
+		clock.Advance(time.Second)
+	}
+	// Refreshing a cached match does not evict another
+	cache.Set("match-1", &domain.MatchMetrics{MatchID: "match-1"})
+	clock.Advance(time.Second)
+	if _, _, ok := cache.Get("match-2"); !ok {
+		t.Fatal("expected match-2 to stay cached when match-1 is refreshed")
+	}
+
+	cache.Set("match-3", &domain.MatchMetrics{MatchID: "match-3"})
+
+	if _, _, ok := cache.Get("match-2"); ok {
+		t.Error("expected the match cached longest ago to be evicted")
+	}
+	for _, matchID := range []string{"match-1", "match-3"} {
+		if _, _, ok := cache.Get(matchID); !ok {
+			t.Errorf("expected %s to stay cached", matchID)
+		}
+	}
+}
+
+func TestMetricsCache_CopiesMaps(t *testing.T) {
+	cache := newMetricsCache(DefaultMetricsCacheMaxEntries, nil)
+
+	stored := &domain.MatchMetrics{MatchID: "match-1", EventsByType: map[string]int64{"pass": 3}}
+	cache.Set("match-1", stored)
+	stored.EventsByType["pass"] = 4
+
+	got, _, _ := cache.Get("match-1")
+	got.EventsByType["goal"] = 1
+
+	again, _, _ := cache.Get("match-1")
+	if len(again.EventsByType) != 1 || again.EventsByType["pass"] != 3 {
+		t.Errorf("expected the cached map to be unchanged, got %v", again.EventsByType)
+	}
+}
@@ -0,0 +1,225 @@
+package api
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"fanfinity/internal/domain"
+)
+
+// ConfirmHeader asks for an event's persistence to be tracked when set to
+// "true", so its status can be read from GET /api/events/{eventId}/status.
+const ConfirmHeader = "X-Confirm"
+
+// StatusWaitParam is the query parameter bounding how long the status
+// endpoint waits for an event to reach a final status, e.g. ?wait=5s.
+const StatusWaitParam = "wait"
+
+// Defaults for event confirmation tracking.
+const (
+	DefaultConfirmationWindow       = 10 * time.Minute
+	DefaultMaxTrackedConfirmations  = 100000
+	DefaultConfirmationPollInterval = 500 * time.Millisecond
+	DefaultConfirmationMaxWait      = 25 * time.Second
+	DefaultStatusWait               = 10 * time.Second
+)
+
+// EventStatusSource reports the status the consumer published for an event.
+type EventStatusSource interface {
+	// EventStatus returns the latest status of eventID, or an empty status if
+	// none has been published.
+	EventStatus(ctx context.Context, eventID uuid.UUID) (domain.EventStatus, error)
+}
+
+// ConfirmationTrackerConfig holds settings for a ConfirmationTracker.
+type ConfirmationTrackerConfig struct {
+	// Window is how long an accepted event is tracked. Once it has passed,
+	// the event is only reported if the consumer published a status for it.
+	Window time.Duration
+
+	// MaxTracked caps the number of accepted events held in memory; the
+	// oldest are dropped first.
+	MaxTracked int
+
+	// PollInterval is how often a waiting status request re-reads the store.
+	PollInterval time.Duration
+
+	// MaxWait caps the ?wait= duration of a status request. The status
+	// handler extends the write deadline to cover it, but it should stay below
+	// the router's request timeout.
+	MaxWait time.Duration
+
+	// Clock times the tracking window and status waits. Nil means
+	// domain.SystemClock.
+	Clock domain.Clock
+}
+
+// DefaultConfirmationTrackerConfig returns the default confirmation tracking configuration.
+func DefaultConfirmationTrackerConfig() ConfirmationTrackerConfig {
+	return ConfirmationTrackerConfig{
+		Window:       DefaultConfirmationWindow,
+		MaxTracked:   DefaultMaxTrackedConfirmations,
+		PollInterval: DefaultConfirmationPollInterval,
+		MaxWait:      DefaultConfirmationMaxWait,
+	}
+}
+
+// trackedEvent is an accepted event and when it was accepted.
+type trackedEvent struct {
+	id         uuid.UUID
+	acceptedAt time.Time
+}
+
+// trackedStatus is what the tracker knows of an event locally: when it was
+// accepted and whether producing it failed.
+type trackedStatus struct {
+	acceptedAt time.Time
+	failed     bool
+}
+
+// ConfirmationTracker correlates events accepted with X-Confirm with the
+// statuses the consumer publishes once it has inserted or dead-lettered
+// them. Accepted events are remembered in memory for Window, so an event
+// still in flight reads as accepted rather than unknown; persisted and failed
+// come from the shared EventStatusSource. An event whose batch failed to
+// reach Kafka is marked failed locally, since the consumer never sees it.
+type ConfirmationTracker struct {
+	source EventStatusSource
+	config ConfirmationTrackerConfig
+
+	mu       sync.Mutex
+	accepted map[uuid.UUID]trackedStatus
+	order    []trackedEvent // oldest first
+}
+
+// NewConfirmationTracker creates a ConfirmationTracker with the default configuration.
+func NewConfirmationTracker(source EventStatusSource) *ConfirmationTracker {
+	return NewConfirmationTrackerWithConfig(source, DefaultConfirmationTrackerConfig())
+}
+
+// NewConfirmationTrackerWithConfig creates a ConfirmationTracker. Zero config
+// values use the defaults.
+func NewConfirmationTrackerWithConfig(source EventStatusSource, cfg ConfirmationTrackerConfig) *ConfirmationTracker {
+	if cfg.Window <= 0 {
+		cfg.Window = DefaultConfirmationWindow
+	}
+	if cfg.MaxTracked <= 0 {
+		cfg.MaxTracked = DefaultMaxTrackedConfirmations
+	}
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = DefaultConfirmationPollInterval
+	}
+	if cfg.MaxWait <= 0 {
+		cfg.MaxWait = DefaultConfirmationMaxWait
+	}
+	cfg.Clock = domain.ClockOrSystem(cfg.Clock)
+	return &ConfirmationTracker{
+		source:   source,
+		config:   cfg,
+		accepted: make(map[uuid.UUID]trackedStatus),
+	}
+}
+
+// Track records that eventID was produced and awaits confirmation.
+func (t *ConfirmationTracker) Track(eventID uuid.UUID) {
+	t.record(eventID, false)
+}
+
+// Fail records that producing eventID failed, so its status reads as failed
+// for the tracking window.
+func (t *ConfirmationTracker) Fail(eventID uuid.UUID) {
+	t.record(eventID, true)
+}
+
+// record tracks eventID from now, replacing any earlier entry.
+func (t *ConfirmationTracker) record(eventID uuid.UUID, failed bool) {
+	now := t.config.Clock.Now()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.evictLocked(now)
+	for len(t.order) >= t.config.MaxTracked {
+		t.dropOldestLocked()
+	}
+	t.accepted[eventID] = trackedStatus{acceptedAt: now, failed: failed}
+	t.order = append(t.order, trackedEvent{id: eventID, acceptedAt: now})
+}
+
+// Tracked reports whether eventID was accepted within the tracking window.
+func (t *ConfirmationTracker) Tracked(eventID uuid.UUID) bool {
+	_, ok := t.tracked(eventID)
+	return ok
+}
+
+// tracked returns the local status of eventID if it was accepted within the
+// tracking window.
+func (t *ConfirmationTracker) tracked(eventID uuid.UUID) (trackedStatus, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	status, ok := t.accepted[eventID]
+	if !ok || t.config.Clock.Now().Sub(status.acceptedAt) >= t.config.Window {
+		return trackedStatus{}, false
+	}
+	return status, true
+}
+
+// evictLocked drops events accepted longer ago than the window.
+func (t *ConfirmationTracker) evictLocked(now time.Time) {
+	for len(t.order) > 0 && now.Sub(t.order[0].acceptedAt) >= t.config.Window {
+		t.dropOldestLocked()
+	}
+}
+
+// dropOldestLocked forgets the oldest tracked event. An event tracked twice
+// keeps its latest entry in the map.
+func (t *ConfirmationTracker) dropOldestLocked() {
+	oldest := t.order[0]
+	t.order = t.order[1:]
+	if status, ok := t.accepted[oldest.id]; ok && status.acceptedAt.Equal(oldest.acceptedAt) {
+		delete(t.accepted, oldest.id)
+	}
+}
+
+// MaxWait returns the longest a status request may wait.
+func (t *ConfirmationTracker) MaxWait() time.Duration {
+	return t.config.MaxWait
+}
+
+// Status returns the status of eventID, polling the store every PollInterval
+// for up to wait until it is final. An event with no published status reads
+// as accepted while tracked and as an empty status otherwise, unless it was
+// marked failed; untracked events are not waited on.
+func (t *ConfirmationTracker) Status(ctx context.Context, eventID uuid.UUID, wait time.Duration) (domain.EventStatus, error) {
+	deadline := t.config.Clock.Now().Add(wait)
+	for {
+		status, err := t.source.EventStatus(ctx, eventID)
+		if err != nil {
+			return "", err
+		}
+		if status.IsFinal() {
+			return status, nil
+		}
+		local, ok := t.tracked(eventID)
+		if !ok {
+			return status, nil
+		}
+		if local.failed {
+			return domain.EventStatusFailed, nil
+		}
+
+		remaining := deadline.Sub(t.config.Clock.Now())
+		if remaining <= 0 {
+			return domain.EventStatusAccepted, nil
+		}
+		timer := time.NewTimer(min(remaining, t.config.PollInterval))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return domain.EventStatusAccepted, nil
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,246 @@
+package api_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"fanfinity/internal/api"
+	"fanfinity/internal/domain"
+)
+
+// fakeStatusStore stands in for the statuses the consumer publishes.
+type fakeStatusStore struct {
+	mu       sync.Mutex
+	statuses map[uuid.UUID]domain.EventStatus
+}
+
+func (s *fakeStatusStore) EventStatus(ctx context.Context, eventID uuid.UUID) (domain.EventStatus, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.statuses[eventID], nil
+}
+
+func (s *fakeStatusStore) publish(eventID uuid.UUID, status domain.EventStatus) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.statuses[eventID] = status
+}
+
+func TestEventStatus_Transitions(t *testing.T) {
+	store := &fakeStatusStore{statuses: make(map[uuid.UUID]domain.EventStatus)}
+	tracker := api.NewConfirmationTrackerWithConfig(store, api.ConfirmationTrackerConfig{
+		PollInterval: 5 * time.Millisecond,
+		MaxWait:      time.Second,
+	})
+
+	produced := make(map[uuid.UUID]bool)
+	mockProducer := &MockProducer{
+		ProduceFunc: func(ctx context.Context, event *domain.Event) error {
+			produced[event.EventID] = event.Confirm
+			return nil
+		},
+	}
+	cfg := api.DefaultHandlerConfig()
+	cfg.Confirmations = tracker
+	router := api.NewRouterWithConfig(mockProducer, &MockRepository{}, slog.Default(), cfg)
+
+	ingest := func(confirm bool) uuid.UUID {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, "/api/events", bytes.NewReader(eventJSONForMatch("match-123")))
+		req.Header.Set("Content-Type", "application/json")
+		if confirm {
+			req.Header.Set(api.ConfirmHeader, "true")
+		}
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		if rr.Code != http.StatusAccepted {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusAccepted, rr.Code, rr.Body.String())
+		}
+		var resp api.IngestEventResponse
+		if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		return uuid.MustParse(resp.EventID)
+	}
+	status := func(eventID uuid.UUID, wait string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/events/"+eventID.String()+"/status?wait="+wait, nil)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+	expectStatus := func(t *testing.T, rr *httptest.ResponseRecorder, want domain.EventStatus) {
+		t.Helper()
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+		}
+		var resp api.EventStatusResponse
+		if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		if resp.Status != want {
+			t.Errorf("expected event status %q, got %q", want, resp.Status)
+		}
+	}
+
+	t.Run("accepted then persisted", func(t *testing.T) {
+		eventID := ingest(true)
+		if !produced[eventID] {
+			t.Fatal("expected the event to be produced with Confirm set")
+		}
+		expectStatus(t, status(eventID, "0s"), domain.EventStatusAccepted)
+
+		store.publish(eventID, domain.EventStatusPersisted)
+		expectStatus(t, status(eventID, "0s"), domain.EventStatusPersisted)
+	})
+
+	t.Run("failed", func(t *testing.T) {
+		eventID := ingest(true)
+		store.publish(eventID, domain.EventStatusFailed)
+		expectStatus(t, status(eventID, "0s"), domain.EventStatusFailed)
+	})
+
+	t.Run("long poll returns once persisted", func(t *testing.T) {
+		eventID := ingest(true)
+		go func() {
+			time.Sleep(20 * time.Millisecond)
+			store.publish(eventID, domain.EventStatusPersisted)
+		}()
+		start := time.Now()
+		expectStatus(t, status(eventID, "1s"), domain.EventStatusPersisted)
+		if elapsed := time.Since(start); elapsed >= time.Second {
+			t.Errorf("expected the long poll to return before the wait elapsed, took %v", elapsed)
+		}
+	})
+
+	t.Run("unconfirmed event is not tracked", func(t *testing.T) {
+		eventID := ingest(false)
+		if produced[eventID] {
+			t.Error("expected the event to be produced without Confirm")
+		}
+		if rr := status(eventID, "0s"); rr.Code != http.StatusNotFound {
+			t.Errorf("expected status %d, got %d", http.StatusNotFound, rr.Code)
+		}
+	})
+
+	t.Run("invalid wait", func(t *testing.T) {
+		if rr := status(uuid.New(), "soon"); rr.Code != http.StatusBadRequest {
+			t.Errorf("expected status %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+	})
+}
+
+func TestConfirmationTracker_MaxTracked(t *testing.T) {
+	tracker := api.NewConfirmationTrackerWithConfig(&fakeStatusStore{}, api.ConfirmationTrackerConfig{MaxTracked: 2})
+
+	first, second, third := uuid.New(), uuid.New(), uuid.New()
+	tracker.Track(first)
+	tracker.Track(second)
+	tracker.Track(third)
+
+	if tracker.Tracked(first) {
+		t.Error("expected the oldest event to be dropped once MaxTracked is reached")
+	}
+	if !tracker.Tracked(second) || !tracker.Tracked(third) {
+		t.Error("expected the newest events to stay tracked")
+	}
+}
+
+func TestEventStatus_NotMountedWithoutTracker(t *testing.T) {
+	router := api.NewRouter(&MockProducer{}, &MockRepository{}, slog.Default())
+
+	req := httptest.NewRequest(http.MethodGet, "/api/events/"+uuid.New().String()+"/status", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code == http.StatusOK {
+		t.Errorf("expected the status route to be unmounted, got %d", rr.Code)
+	}
+}
+
+func TestConfirmationTracker_UsesClock(t *testing.T) {
+	clock := domain.NewFixedClock(time.Date(2024, 1, 15, 14, 0, 0, 0, time.UTC))
+	tracker := api.NewConfirmationTrackerWithConfig(&fakeStatusStore{}, api.ConfirmationTrackerConfig{
+		Window: time.Minute,
+		Clock:  clock,
+	})
+
+	eventID := uuid.New()
+	tracker.Track(eventID)
+	clock.Advance(59 * time.Second)
+	if !tracker.Tracked(eventID) {
+		t.Fatal("expected the event to be tracked within the window")
+	}
+	clock.Advance(time.Second)
+	if tracker.Tracked(eventID) {
+		t.Error("expected the event to be dropped once the clock passes the window")
+	}
+}
+
+func TestConfirmationTracker_FailedBatch(t *testing.T) {
+	tracker := api.NewConfirmationTrackerWithConfig(&fakeStatusStore{}, api.ConfirmationTrackerConfig{})
+	producer, _ := batchRecorder(errors.New("broker unavailable"))
+	batcher := newBatcher(producer, api.ProduceBatcherConfig{
+		Window:        time.Millisecond,
+		MaxBatchSize:  100,
+		Confirmations: tracker,
+	})
+	defer batcher.Close(context.Background())
+
+	event := batchEvent()
+	event.Confirm = true
+	if err := batcher.Produce(context.Background(), event); err == nil {
+		t.Fatal("expected the batch to fail")
+	}
+
+	status, err := tracker.Status(context.Background(), event.EventID, 0)
+	if err != nil {
+		t.Fatalf("unexpected status error: %v", err)
+	}
+	if status != domain.EventStatusFailed {
+		t.Errorf("expected the event of a failed batch to read as failed, got %q", status)
+	}
+}
+
+func TestEventStatus_LongPollOutlastsWriteTimeout(t *testing.T) {
+	store := &fakeStatusStore{statuses: make(map[uuid.UUID]domain.EventStatus)}
+	tracker := api.NewConfirmationTrackerWithConfig(store, api.ConfirmationTrackerConfig{
+		PollInterval: 10 * time.Millisecond,
+		MaxWait:      time.Second,
+	})
+	eventID := uuid.New()
+	tracker.Track(eventID)
+
+	cfg := api.DefaultHandlerConfig()
+	cfg.Confirmations = tracker
+	ts := httptest.NewUnstartedServer(api.NewRouterWithConfig(&MockProducer{}, &MockRepository{}, slog.Default(), cfg))
+	ts.Config.WriteTimeout = 100 * time.Millisecond
+	ts.Start()
+	defer ts.Close()
+
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		store.publish(eventID, domain.EventStatusPersisted)
+	}()
+	resp, err := http.Get(ts.URL + "/api/events/" + eventID.String() + "/status?wait=1s")
+	if err != nil {
+		t.Fatalf("expected the long poll to outlast the write timeout, got %v", err)
+	}
+	defer resp.Body.Close()
+
+	var body api.EventStatusResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if body.Status != domain.EventStatusPersisted {
+		t.Errorf("expected status %q, got %q", domain.EventStatusPersisted, body.Status)
+	}
+}
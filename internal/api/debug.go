@@ -0,0 +1,90 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// RuntimeStats is a point-in-time snapshot of Go runtime statistics.
+type RuntimeStats struct {
+	Goroutines      int    `json:"goroutines"`
+	HeapAllocBytes  uint64 `json:"heapAllocBytes"`
+	HeapInuseBytes  uint64 `json:"heapInuseBytes"`
+	HeapObjects     uint64 `json:"heapObjects"`
+	HeapSysBytes    uint64 `json:"heapSysBytes"`
+	NumGC           uint32 `json:"numGC"`
+	PauseTotalNanos uint64 `json:"pauseTotalNs"`
+}
+
+// NewDebugRouter returns a router serving net/http/pprof profiles under
+// /debug/pprof/ and a runtime snapshot under /debug/runtime. It must be
+// mounted at /debug, since pprof resolves named profiles from the full path.
+func NewDebugRouter() chi.Router {
+	r := chi.NewRouter()
+	r.Use(middleware.Recoverer)
+
+	r.HandleFunc("/pprof/*", pprof.Index)
+	r.HandleFunc("/pprof/cmdline", pprof.Cmdline)
+	r.HandleFunc("/pprof/profile", outlastWriteTimeout(pprof.Profile))
+	r.HandleFunc("/pprof/symbol", pprof.Symbol)
+	r.HandleFunc("/pprof/trace", outlastWriteTimeout(pprof.Trace))
+	r.Get("/runtime", handleRuntimeStats)
+
+	return r
+}
+
+// defaultProfileSeconds is the duration assumed when the seconds parameter is
+// absent, the longer of pprof's profile and trace defaults.
+const defaultProfileSeconds = 30
+
+// outlastWriteTimeout lets a profile or trace record for longer than the
+// server WriteTimeout. It extends the write deadline past the requested
+// seconds, and hands pprof a server without WriteTimeout, since the pprof of
+// Go 1.22 rejects durations longer than the server's.
+func outlastWriteTimeout(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		seconds, err := strconv.ParseFloat(r.FormValue("seconds"), 64)
+		if err != nil || seconds <= 0 {
+			seconds = defaultProfileSeconds
+		}
+		extendWriteDeadline(w, time.Duration(seconds*float64(time.Second)))
+
+		ctx := context.WithValue(r.Context(), http.ServerContextKey, &http.Server{})
+		next(w, r.WithContext(ctx))
+	}
+}
+
+// NewMetricsRouter returns the handler for a standalone metrics listener:
+// Prometheus metrics at /metrics, plus the debug routes when enableDebug is set.
+func NewMetricsRouter(enableDebug bool) http.Handler {
+	r := chi.NewRouter()
+	r.Handle("/metrics", promhttp.Handler())
+	if enableDebug {
+		r.Mount("/debug", NewDebugRouter())
+	}
+	return r
+}
+
+// handleRuntimeStats reports goroutine and heap statistics.
+func handleRuntimeStats(w http.ResponseWriter, r *http.Request) {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	respondJSON(w, http.StatusOK, RuntimeStats{
+		Goroutines:      runtime.NumGoroutine(),
+		HeapAllocBytes:  mem.HeapAlloc,
+		HeapInuseBytes:  mem.HeapInuse,
+		HeapObjects:     mem.HeapObjects,
+		HeapSysBytes:    mem.HeapSys,
+		NumGC:           mem.NumGC,
+		PauseTotalNanos: mem.PauseTotalNs,
+	})
+}
@@ -0,0 +1,105 @@
+package api_test
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"fanfinity/internal/api"
+)
+
+func TestRouter_PprofRoutesOnlyWhenEnabled(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	tests := []struct {
+		name       string
+		enabled    bool
+		wantStatus int
+	}{
+		{"disabled by default", false, http.StatusNotFound},
+		{"enabled", true, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := api.DefaultHandlerConfig()
+			cfg.EnablePprof = tt.enabled
+			router := api.NewRouterWithConfig(&MockProducer{}, &MockRepository{}, logger, cfg)
+
+			for _, path := range []string{"/debug/pprof/", "/debug/pprof/heap", "/debug/pprof/cmdline", "/debug/runtime"} {
+				rr := httptest.NewRecorder()
+				router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, path, nil))
+				if rr.Code != tt.wantStatus {
+					t.Errorf("GET %s: expected status %d, got %d", path, tt.wantStatus, rr.Code)
+				}
+			}
+		})
+	}
+}
+
+func TestMetricsRouter_PprofRoutesOnlyWhenEnabled(t *testing.T) {
+	for _, enabled := range []bool{false, true} {
+		router := api.NewMetricsRouter(enabled)
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+		if rr.Code != http.StatusOK {
+			t.Errorf("enabled=%v: expected /metrics status 200, got %d", enabled, rr.Code)
+		}
+
+		rr = httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/debug/pprof/goroutine", nil))
+		want := http.StatusNotFound
+		if enabled {
+			want = http.StatusOK
+		}
+		if rr.Code != want {
+			t.Errorf("enabled=%v: expected /debug/pprof/goroutine status %d, got %d", enabled, want, rr.Code)
+		}
+	}
+}
+
+func TestDebugRouter_RuntimeStats(t *testing.T) {
+	router := api.NewMetricsRouter(true)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/debug/runtime", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rr.Code)
+	}
+
+	var stats api.RuntimeStats
+	if err := json.NewDecoder(rr.Body).Decode(&stats); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if stats.Goroutines < 1 {
+		t.Errorf("expected at least one goroutine, got %d", stats.Goroutines)
+	}
+	if stats.HeapAllocBytes == 0 {
+		t.Error("expected non-zero heap allocation")
+	}
+}
+
+func TestDebugRouter_TraceOutlastsWriteTimeout(t *testing.T) {
+	ts := httptest.NewUnstartedServer(api.NewMetricsRouter(true))
+	ts.Config.WriteTimeout = 500 * time.Millisecond
+	ts.Start()
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/debug/pprof/trace?seconds=1")
+	if err != nil {
+		t.Fatalf("trace request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("trace was cut off: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK || len(body) == 0 {
+		t.Errorf("expected a trace, got status %d with %d bytes: %s", resp.StatusCode, len(body), body)
+	}
+}
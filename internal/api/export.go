@@ -0,0 +1,116 @@
+package api
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"fanfinity/internal/domain"
+)
+
+// ExportContentType is the media type of match exports: gzipped JSON lines.
+const ExportContentType = "application/gzip"
+
+// ExportMatch handles GET /api/matches/{matchId}/export.
+// It streams every stored event of the match as gzipped newline-delimited
+// JSON, one event per line in the stored-event form, as a file download.
+// Events are written as they are read, so the match is never held in memory.
+// If reading fails after the download has started the gzip stream is left
+// unterminated, so the client sees a truncated archive rather than a complete
+// one. It is served under StreamDeadlines, which lets a large match outlast
+// the server write timeout while still bounding the export.
+func (h *Handler) ExportMatch(w http.ResponseWriter, r *http.Request) {
+	matchID, ok := matchIDParam(w, r)
+	if !ok {
+		return
+	}
+
+	ctx := r.Context()
+	var (
+		gz       *gzip.Writer
+		enc      *json.Encoder
+		exported int64
+		writeErr error
+	)
+	err := h.repository.StreamEvents(ctx, matchID, func(event *domain.Event) error {
+		// Headers wait for the first event so an unknown match or a failed
+		// query still gets a JSON error response
+		if gz == nil {
+			w.Header().Set("Content-Type", ExportContentType)
+			w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.jsonl.gz"`, exportFilename(matchID)))
+			w.Header().Set("Cache-Control", "no-store")
+			w.WriteHeader(http.StatusOK)
+			gz = gzip.NewWriter(w)
+			enc = json.NewEncoder(gz)
+		}
+		if err := enc.Encode(event.AsKafkaMessage()); err != nil {
+			writeErr = fmt.Errorf("failed to write export: %w", err)
+			return writeErr
+		}
+		exported++
+		return nil
+	})
+
+	if gz == nil {
+		switch {
+		case err == nil:
+			respondError(w, http.StatusNotFound, "match not found", "")
+		case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+			respondError(w, http.StatusServiceUnavailable, "export interrupted", "")
+		default:
+			RecordClickHouseQueryError()
+			LoggerFromContext(ctx).Error("failed to read events for export",
+				slog.String("match_id", matchID),
+				slog.String("error", err.Error()),
+			)
+			respondError(w, http.StatusInternalServerError, "failed to read events", "")
+		}
+		return
+	}
+
+	if err != nil {
+		// Leave the gzip stream unterminated so the archive fails to decompress.
+		// A failed write or cancelled context means the client went away.
+		if writeErr != nil || ctx.Err() != nil {
+			LoggerFromContext(ctx).Info("match export interrupted",
+				slog.String("match_id", matchID),
+				slog.Int64("exported", exported),
+				slog.String("error", err.Error()),
+			)
+			return
+		}
+		RecordClickHouseQueryError()
+		LoggerFromContext(ctx).Error("match export failed",
+			slog.String("match_id", matchID),
+			slog.Int64("exported", exported),
+			slog.String("error", err.Error()),
+		)
+		return
+	}
+
+	if err := gz.Close(); err != nil {
+		LoggerFromContext(ctx).Info("match export interrupted",
+			slog.String("match_id", matchID),
+			slog.Int64("exported", exported),
+			slog.String("error", err.Error()),
+		)
+	}
+}
+
+// exportFilename makes matchID safe to use as a download file name by
+// replacing anything other than letters, digits, '.', '_' and '-'.
+func exportFilename(matchID string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '_', r == '-':
+			return r
+		default:
+			return '_'
+		}
+	}, matchID)
+}
@@ -0,0 +1,181 @@
+package api_test
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"fanfinity/internal/api"
+	"fanfinity/internal/domain"
+)
+
+// exportEvents returns n stored events for match-123.
+func exportEvents(n int) []*domain.Event {
+	ts := time.Date(2024, 1, 15, 14, 0, 0, 0, time.UTC)
+	events := make([]*domain.Event, n)
+	for i := range events {
+		events[i] = &domain.Event{
+			EventID:   uuid.New(),
+			MatchID:   "match-123",
+			EventType: domain.EventTypePass,
+			Timestamp: ts.Add(time.Duration(i) * time.Second),
+			TeamID:    1 + i%2,
+			Metadata:  map[string]interface{}{"minute": float64(i)},
+		}
+	}
+	return events
+}
+
+// streamFunc streams events, then returns err.
+func streamFunc(events []*domain.Event, err error) func(context.Context, string, func(*domain.Event) error) error {
+	return func(ctx context.Context, matchID string, fn func(*domain.Event) error) error {
+		for _, event := range events {
+			if err := fn(event); err != nil {
+				return err
+			}
+		}
+		return err
+	}
+}
+
+func exportMatch(repo *MockRepository, matchID string) *httptest.ResponseRecorder {
+	handler := api.NewHandler(&MockProducer{}, repo)
+	req := httptest.NewRequest(http.MethodGet, "/api/matches/"+url.PathEscape(matchID)+"/export", nil)
+	req = withChiURLParams(req, map[string]string{"matchId": matchID})
+	rr := httptest.NewRecorder()
+	handler.ExportMatch(rr, req)
+	return rr
+}
+
+func TestExportMatch(t *testing.T) {
+	events := exportEvents(3)
+	rr := exportMatch(&MockRepository{StreamEventsFunc: streamFunc(events, nil)}, "match-123")
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	if got := rr.Header().Get("Content-Type"); got != api.ExportContentType {
+		t.Errorf("expected Content-Type %q, got %q", api.ExportContentType, got)
+	}
+	if got := rr.Header().Get("Content-Disposition"); got != `attachment; filename="match-123.jsonl.gz"` {
+		t.Errorf("unexpected Content-Disposition %q", got)
+	}
+
+	gz, err := gzip.NewReader(rr.Body)
+	if err != nil {
+		t.Fatalf("export is not gzipped: %v", err)
+	}
+	scanner := bufio.NewScanner(gz)
+	var lines []domain.KafkaMessage
+	for scanner.Scan() {
+		var msg domain.KafkaMessage
+		if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil {
+			t.Fatalf("line %d is not JSON: %v", len(lines)+1, err)
+		}
+		lines = append(lines, msg)
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatalf("failed to decompress export: %v", err)
+	}
+
+	if len(lines) != len(events) {
+		t.Fatalf("expected %d lines, got %d", len(events), len(lines))
+	}
+	for i, msg := range lines {
+		if msg.EventID != events[i].EventID.String() || msg.TeamID != events[i].TeamID || msg.Metadata["minute"] != float64(i) {
+			t.Errorf("line %d: unexpected event %+v", i+1, msg)
+		}
+	}
+}
+
+func TestExportMatch_Errors(t *testing.T) {
+	t.Run("unknown match", func(t *testing.T) {
+		rr := exportMatch(&MockRepository{StreamEventsFunc: streamFunc(nil, nil)}, "match-123")
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("expected status %d, got %d", http.StatusNotFound, rr.Code)
+		}
+	})
+
+	t.Run("query fails before the first event", func(t *testing.T) {
+		rr := exportMatch(&MockRepository{StreamEventsFunc: streamFunc(nil, errors.New("database error"))}, "match-123")
+		if rr.Code != http.StatusInternalServerError {
+			t.Errorf("expected status %d, got %d", http.StatusInternalServerError, rr.Code)
+		}
+		if got := rr.Header().Get("Content-Type"); got != "application/json" {
+			t.Errorf("expected a JSON error, got Content-Type %q", got)
+		}
+	})
+
+	t.Run("query fails mid-export", func(t *testing.T) {
+		rr := exportMatch(&MockRepository{StreamEventsFunc: streamFunc(exportEvents(2), errors.New("connection reset"))}, "match-123")
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected the download to have started, got %d", rr.Code)
+		}
+		gz, err := gzip.NewReader(rr.Body)
+		if err != nil {
+			t.Fatalf("expected the gzip header to have been sent: %v", err)
+		}
+		if _, err := io.ReadAll(gz); err == nil {
+			t.Error("expected a truncated archive to fail to decompress")
+		}
+	})
+
+	t.Run("match id with path characters", func(t *testing.T) {
+		rr := exportMatch(&MockRepository{StreamEventsFunc: streamFunc(exportEvents(1), nil)}, `cup/final "2024"`)
+		if got := rr.Header().Get("Content-Disposition"); got != `attachment; filename="cup_final__2024_.jsonl.gz"` {
+			t.Errorf("unexpected Content-Disposition %q", got)
+		}
+	})
+}
+
+func TestExportMatch_OutlastsWriteTimeout(t *testing.T) {
+	events := exportEvents(2)
+	repo := &MockRepository{
+		StreamEventsFunc: func(ctx context.Context, matchID string, fn func(*domain.Event) error) error {
+			for _, event := range events {
+				if err := fn(event); err != nil {
+					return err
+				}
+				time.Sleep(150 * time.Millisecond)
+			}
+			return nil
+		},
+	}
+	ts := httptest.NewUnstartedServer(api.NewRouter(&MockProducer{}, repo, slog.Default()))
+	ts.Config.WriteTimeout = 100 * time.Millisecond
+	ts.Start()
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/api/matches/match-123/export")
+	if err != nil {
+		t.Fatalf("failed to request export: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+
+	gz, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		t.Fatalf("export is not gzipped: %v", err)
+	}
+	body, err := io.ReadAll(gz)
+	if err != nil {
+		t.Fatalf("expected the export to outlast the write timeout, got %v", err)
+	}
+	if lines := bytes.Count(body, []byte("\n")); lines != len(events) {
+		t.Errorf("expected %d lines, got %d", len(events), lines)
+	}
+}
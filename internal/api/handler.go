@@ -1,12 +1,24 @@
 package api
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
 	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
 
 	"fanfinity/internal/domain"
 )
@@ -14,12 +26,25 @@ import (
 // EventProducer defines the interface for producing events to Kafka.
 type EventProducer interface {
 	Produce(ctx context.Context, event *domain.Event) error
+	ProduceBatch(ctx context.Context, events []*domain.Event) error
 }
 
 // MetricsRepository defines the interface for querying metrics from ClickHouse.
 type MetricsRepository interface {
 	GetMatchMetrics(ctx context.Context, matchID string) (*domain.MatchMetrics, error)
+	GetMatchMetricsWithOptions(ctx context.Context, matchID string, opts domain.MetricsOptions) (*domain.MatchMetrics, error)
 	GetEventsPerMinute(ctx context.Context, matchID string) ([]domain.EventsPerMinute, error)
+	GetTeamEventsPerMinute(ctx context.Context, matchID string, teamID int) ([]domain.EventsPerMinute, error)
+	GetEventsPerSecond(ctx context.Context, matchID string, since time.Time) ([]domain.EventsPerSecond, error)
+	GetEventMatrix(ctx context.Context, matchID string) (domain.EventMatrix, error)
+	GetConversionStats(ctx context.Context, matchID string) (domain.ConversionStats, error)
+	GetLeaderboard(ctx context.Context, eventType string, from, to time.Time, limit int) ([]domain.PlayerStat, error)
+	StreamEvents(ctx context.Context, matchID string, fn func(*domain.Event) error) error
+	SearchEvents(ctx context.Context, matchID string, metadataFilters map[string]string) ([]*domain.Event, error)
+	MatchExists(ctx context.Context, matchID string) (bool, error)
+	UpsertMatchInfo(ctx context.Context, info *domain.MatchInfo) error
+	CloseMatch(ctx context.Context, matchID string) (*domain.MatchMetrics, error)
+	CountEvents(ctx context.Context, matchID string) (int64, error)
 	Ping(ctx context.Context) error
 }
 
@@ -27,14 +52,342 @@ type MetricsRepository interface {
 type Handler struct {
 	producer   EventProducer
 	repository MetricsRepository
+	config     HandlerConfig
+	cache      *metricsCache
 }
 
+// HandlerConfig holds optional handler behavior settings.
+type HandlerConfig struct {
+	// EngagementWeights weights each event type when computing the peak minute.
+	EngagementWeights domain.EngagementWeights
+
+	// AdminToken guards the /api/admin routes. Admin routes are not mounted when empty.
+	AdminToken string
+
+	// ReplayChunkSize is the number of events produced per ProduceBatch call during replay.
+	ReplayChunkSize int
+
+	// MetadataPolicyLoader re-reads the required-metadata policy for the admin
+	// reload endpoint, which applies it to Validation.RequiredMetadata. The
+	// endpoint is only mounted when both are set.
+	MetadataPolicyLoader func() (domain.MetadataPolicy, error)
+
+	// MessageInspector serves the admin message inspection endpoint, which is
+	// only mounted when it is set.
+	MessageInspector MessageInspector
+
+	// OffsetResetter serves the admin consumer offset reset endpoint, which
+	// is only mounted when it is set.
+	OffsetResetter OffsetResetter
+
+	// EventSinks receive each event accepted by IngestEvent whose type is in
+	// SinkEventTypes, or every event when SinkEventTypes is empty.
+	EventSinks     []EventSink
+	SinkEventTypes map[domain.EventType]bool
+
+	// ProducedCounter serves the admin consistency endpoint, which is only
+	// mounted when it is set. ConsistencyThreshold is the default fraction of
+	// produced events the stored count may differ by.
+	ProducedCounter      ProducedCounter
+	ConsistencyThreshold float64
+
+	// HealthCheckers are probed by /healthz/deep, keyed by dependency name.
+	// The repository is always included as "clickhouse" unless overridden.
+	HealthCheckers map[string]HealthChecker
+
+	// Warmup, when set, keeps /ready failing until its startup check has succeeded.
+	Warmup *Warmup
+
+	// Validation enables optional event validation rules.
+	Validation domain.ValidationOptions
+
+	// Clock stamps responses, ages cached metrics and ends the
+	// events-per-second window. Nil means domain.SystemClock. Validation uses
+	// it too unless it sets its own.
+	Clock domain.Clock
+
+	// UseJSONNumber decodes metadata numbers as json.Number rather than
+	// float64, so integers are produced exactly as they were sent.
+	UseJSONNumber bool
+
+	// QueryTimeout bounds each metrics request's repository queries.
+	QueryTimeout time.Duration
+
+	// MaxQueryTimeout caps the per-request override supplied via the X-Query-Timeout header.
+	MaxQueryTimeout time.Duration
+
+	// MetricsCacheTTL serves match metrics from memory while younger than the TTL.
+	// Zero disables fresh cache hits.
+	MetricsCacheTTL time.Duration
+
+	// ServeStaleOnError returns the last cached metrics, however old, when the
+	// repository query fails instead of responding 500.
+	ServeStaleOnError bool
+
+	// MetricsCacheMaxEntries bounds the matches whose metrics are cached; the
+	// match cached longest ago is evicted first. Zero uses
+	// DefaultMetricsCacheMaxEntries.
+	MetricsCacheMaxEntries int
+
+	// ProduceConcurrency bounds concurrent produce calls from the ingestion path.
+	// Zero leaves them unbounded.
+	ProduceConcurrency int
+
+	// ProduceQueueTimeout is how long a request waits for a produce slot before
+	// it is shed with 503.
+	ProduceQueueTimeout time.Duration
+
+	// ProduceLimiter overrides the semaphore built from ProduceConcurrency.
+	ProduceLimiter ProduceLimiter
+
+	// MatchBackpressure, when set, refuses events of matches whose consumer
+	// lag is too high with 429 and a Retry-After header.
+	MatchBackpressure *MatchBackpressure
+
+	// Confirmations, when set, tracks events sent with X-Confirm: true and
+	// serves GET /api/events/{eventId}/status, which is only mounted when it
+	// is set.
+	Confirmations *ConfirmationTracker
+
+	// ProduceTimeout bounds each produce call made while ingesting an event, so
+	// a slow broker fails the request promptly with 503 instead of holding it
+	// until the router timeout.
+	ProduceTimeout time.Duration
+
+	// AllowPrettyJSON honours ?pretty=true and X-Pretty: true on GET requests by
+	// indenting the JSON response. Leave it off in production.
+	AllowPrettyJSON bool
+
+	// MaxDecompressedBytes caps the size of gzip or deflate request bodies after decoding.
+	MaxDecompressedBytes int64
+
+	// MaxImportBytes caps CSV import bodies; ImportBatchSize is the number of
+	// events produced per batch during an import.
+	MaxImportBytes  int64
+	ImportBatchSize int
+
+	// StreamTimeout bounds CSV imports and match exports, which run longer
+	// than the request timeout and outlast the server write timeout.
+	StreamTimeout time.Duration
+
+	// BasePath prefixes every route, e.g. "/fanfinity" serves /fanfinity/api/events.
+	// Empty serves routes at the root. OpsAtRoot keeps /health, /ready,
+	// /healthz/deep and /metrics at the root instead of under BasePath.
+	BasePath  string
+	OpsAtRoot bool
+
+	// EnablePprof mounts pprof profiles and runtime stats under /debug.
+	// Off by default; only enable where /debug is not publicly reachable.
+	EnablePprof bool
+}
+
+// QueryTimeoutHeader lets callers request a longer per-query timeout, up to MaxQueryTimeout.
+const QueryTimeoutHeader = "X-Query-Timeout"
+
+// Stale response headers set when cached metrics are served after a repository error.
+const (
+	DataStaleHeader    = "X-Data-Stale"
+	DataCachedAtHeader = "X-Data-Cached-At"
+)
+
+// DefaultHandlerConfig returns the default handler configuration.
+func DefaultHandlerConfig() HandlerConfig {
+	return HandlerConfig{
+		EngagementWeights: domain.DefaultEngagementWeights(),
+		ReplayChunkSize:   500,
+		Validation:        domain.DefaultValidationOptions(),
+		QueryTimeout:      10 * time.Second,
+		MaxQueryTimeout:   30 * time.Second,
+
+		MetricsCacheMaxEntries: DefaultMetricsCacheMaxEntries,
+
+		ProduceQueueTimeout: 100 * time.Millisecond,
+		ProduceTimeout:      DefaultProduceTimeout,
+
+		MaxDecompressedBytes: DefaultMaxDecompressedBytes,
+
+		MaxImportBytes:  DefaultMaxImportBytes,
+		ImportBatchSize: DefaultImportBatchSize,
+		StreamTimeout:   DefaultStreamTimeout,
+
+		ConsistencyThreshold: DefaultConsistencyThreshold,
+
+		OpsAtRoot: true,
+	}
+}
+
+// DefaultProduceTimeout bounds a produce call made while ingesting an event.
+const DefaultProduceTimeout = 5 * time.Second
+
+// DefaultMaxDecompressedBytes bounds decoded request bodies to guard against zip bombs.
+const DefaultMaxDecompressedBytes = 10 << 20
+
 // NewHandler creates a new Handler with the given producer and repository.
 func NewHandler(producer EventProducer, repository MetricsRepository) *Handler {
-	return &Handler{
+	return NewHandlerWithConfig(producer, repository, DefaultHandlerConfig())
+}
+
+// NewHandlerWithConfig creates a new Handler with custom configuration.
+func NewHandlerWithConfig(producer EventProducer, repository MetricsRepository, cfg HandlerConfig) *Handler {
+	if cfg.EngagementWeights == nil {
+		cfg.EngagementWeights = domain.DefaultEngagementWeights()
+	}
+	if cfg.ReplayChunkSize <= 0 {
+		cfg.ReplayChunkSize = 500
+	}
+	checkers := make(map[string]HealthChecker, len(cfg.HealthCheckers)+1)
+	if repository != nil {
+		checkers["clickhouse"] = repository
+	}
+	for name, checker := range cfg.HealthCheckers {
+		checkers[name] = checker
+	}
+	cfg.HealthCheckers = checkers
+	if cfg.Validation.MaxMinute <= 0 {
+		cfg.Validation.MaxMinute = domain.DefaultMaxMinute
+	}
+	if cfg.Validation.MaxTeamID <= 0 {
+		cfg.Validation.MaxTeamID = domain.DefaultMaxTeamID
+	}
+	cfg.Clock = domain.ClockOrSystem(cfg.Clock)
+	if cfg.Validation.Clock == nil {
+		cfg.Validation.Clock = cfg.Clock
+	}
+	if cfg.QueryTimeout <= 0 {
+		cfg.QueryTimeout = 10 * time.Second
+	}
+	if cfg.MaxQueryTimeout < cfg.QueryTimeout {
+		cfg.MaxQueryTimeout = cfg.QueryTimeout
+	}
+	if cfg.MetricsCacheTTL < 0 {
+		cfg.MetricsCacheTTL = 0
+	}
+	if cfg.MetricsCacheMaxEntries <= 0 {
+		cfg.MetricsCacheMaxEntries = DefaultMetricsCacheMaxEntries
+	}
+	if cfg.ProduceTimeout <= 0 {
+		cfg.ProduceTimeout = DefaultProduceTimeout
+	}
+	if cfg.MaxDecompressedBytes <= 0 {
+		cfg.MaxDecompressedBytes = DefaultMaxDecompressedBytes
+	}
+	if cfg.MaxImportBytes <= 0 {
+		cfg.MaxImportBytes = DefaultMaxImportBytes
+	}
+	if cfg.ImportBatchSize <= 0 {
+		cfg.ImportBatchSize = DefaultImportBatchSize
+	}
+	if cfg.StreamTimeout <= 0 {
+		cfg.StreamTimeout = DefaultStreamTimeout
+	}
+	if cfg.ConsistencyThreshold <= 0 {
+		cfg.ConsistencyThreshold = DefaultConsistencyThreshold
+	}
+	if cfg.ProduceLimiter == nil && cfg.ProduceConcurrency > 0 {
+		cfg.ProduceLimiter = NewProduceSemaphore(cfg.ProduceConcurrency, cfg.ProduceQueueTimeout)
+	}
+	h := &Handler{
 		producer:   producer,
 		repository: repository,
+		config:     cfg,
+	}
+	if cfg.MetricsCacheTTL > 0 || cfg.ServeStaleOnError {
+		h.cache = newMetricsCache(cfg.MetricsCacheMaxEntries, cfg.Clock)
+	}
+	return h
+}
+
+// isTimeout reports whether a repository error was caused by the query or request
+// deadline passing, which is answered with 504 rather than 500.
+func isTimeout(ctx context.Context, err error) bool {
+	if re := domain.AsRepositoryError(err); re != nil && re.Category == domain.RepositoryErrorTimeout {
+		return true
+	}
+	return errors.Is(err, context.DeadlineExceeded) || errors.Is(ctx.Err(), context.DeadlineExceeded)
+}
+
+// repositoryErrorStatus maps a repository error to a response status: 504 for
+// timeouts, 503 when the store is unreachable and 500 for anything else.
+func repositoryErrorStatus(ctx context.Context, err error) int {
+	if isTimeout(ctx, err) {
+		return http.StatusGatewayTimeout
 	}
+	if re := domain.AsRepositoryError(err); re != nil && re.Category == domain.RepositoryErrorConnection {
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
+
+// respondRepositoryError answers a failed repository call with the status from
+// repositoryErrorStatus, using timeoutMessage for 504 and message for 500.
+func respondRepositoryError(w http.ResponseWriter, ctx context.Context, err error, timeoutMessage, message string) {
+	switch status := repositoryErrorStatus(ctx, err); status {
+	case http.StatusGatewayTimeout:
+		respondError(w, status, timeoutMessage, "")
+	case http.StatusServiceUnavailable:
+		w.Header().Set("Retry-After", "5")
+		respondError(w, status, "storage unavailable, retry shortly", "")
+	default:
+		respondError(w, status, message, "")
+	}
+}
+
+// isEmptyBody reports whether a request body is empty, whitespace-only, or a JSON null.
+func isEmptyBody(body []byte) bool {
+	trimmed := bytes.TrimSpace(body)
+	return len(trimmed) == 0 || bytes.Equal(trimmed, []byte("null"))
+}
+
+// matchIDParam returns the decoded matchId path parameter, writing a 400 and
+// returning false if it is missing or malformed. A matchId containing "/"
+// must be sent as %2F; chi then matches routes on the raw path, so the
+// parameter arrives still encoded.
+func matchIDParam(w http.ResponseWriter, r *http.Request) (string, bool) {
+	matchID := chi.URLParam(r, "matchId")
+	if r.URL.RawPath != "" {
+		decoded, err := url.PathUnescape(matchID)
+		if err != nil {
+			respondErrorWithField(w, http.StatusBadRequest, "must be a valid percent-encoded path segment", "matchId")
+			return "", false
+		}
+		matchID = decoded
+	}
+	if matchID == "" {
+		respondErrorWithField(w, http.StatusBadRequest, "is required; percent-encode any '/' in the match ID as %2F", "matchId")
+		return "", false
+	}
+	return matchID, true
+}
+
+// queryContext derives the context for a metrics request's repository queries.
+// The X-Query-Timeout header, either a Go duration ("45s") or whole seconds ("45"),
+// overrides the configured QueryTimeout but may not exceed MaxQueryTimeout. An
+// override also extends the write deadline, so a query allowed to run past the
+// server WriteTimeout can still be answered.
+func (h *Handler) queryContext(w http.ResponseWriter, r *http.Request) (context.Context, context.CancelFunc, error) {
+	timeout := h.config.QueryTimeout
+
+	if raw := strings.TrimSpace(r.Header.Get(QueryTimeoutHeader)); raw != "" {
+		override, err := time.ParseDuration(raw)
+		if err != nil {
+			seconds, convErr := strconv.Atoi(raw)
+			if convErr != nil {
+				return nil, nil, fmt.Errorf("%s must be a duration such as 45s", QueryTimeoutHeader)
+			}
+			override = time.Duration(seconds) * time.Second
+		}
+		if override <= 0 {
+			return nil, nil, fmt.Errorf("%s must be positive", QueryTimeoutHeader)
+		}
+		if override > h.config.MaxQueryTimeout {
+			return nil, nil, fmt.Errorf("%s exceeds maximum of %s", QueryTimeoutHeader, h.config.MaxQueryTimeout)
+		}
+		timeout = override
+		extendWriteDeadline(w, timeout)
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	return ctx, cancel, nil
 }
 
 // IngestEventResponse represents the response for a successful event ingestion.
@@ -44,33 +397,165 @@ type IngestEventResponse struct {
 	Timestamp time.Time `json:"timestamp"`
 }
 
+// EventStatusResponse represents the response for an event status request.
+type EventStatusResponse struct {
+	EventID string             `json:"eventId"`
+	Status  domain.EventStatus `json:"status"`
+}
+
 // IngestEvent handles POST /api/events.
 // It validates the incoming event, produces it to Kafka, and returns 202 Accepted.
 func (h *Handler) IngestEvent(w http.ResponseWriter, r *http.Request) {
 	start := time.Now()
 
+	event, ok := h.decodeEvent(w, r)
+	if !ok {
+		return
+	}
+	h.acceptEvent(w, r, start, event)
+}
+
+// UpsertEvent handles PUT /api/events/{eventId}.
+// It replaces a previously sent event: the body's eventId must match the path,
+// and the event is produced marked as an upsert so the consumer keeps only its
+// latest version.
+func (h *Handler) UpsertEvent(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+
+	pathID, err := uuid.Parse(chi.URLParam(r, "eventId"))
+	if err != nil {
+		respondErrorWithField(w, http.StatusBadRequest, "eventId must be a valid UUID", "eventId")
+		return
+	}
+
+	event, ok := h.decodeEvent(w, r)
+	if !ok {
+		return
+	}
+	if event.EventID != pathID {
+		RecordEventRejected("eventId")
+		respondErrorWithField(w, http.StatusUnprocessableEntity, "eventId must match the eventId in the path", "eventId")
+		return
+	}
+
+	event.Op = domain.EventOpUpsert
+	h.acceptEvent(w, r, start, event)
+}
+
+// decodeEvent reads and validates the JSON event in the request body. On
+// failure it writes the error response and returns false.
+func (h *Handler) decodeEvent(w http.ResponseWriter, r *http.Request) (*domain.Event, bool) {
+	if !requireContentType(w, r, "application/json") {
+		return nil, false
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "failed to read request body", err.Error())
+		return nil, false
+	}
+
+	// Whitespace or a bare null would decode to a zero-value request and fail
+	// validation with a misleading field error, so reject them up front.
+	if isEmptyBody(body) {
+		respondErrorWithCode(w, http.StatusBadRequest, "request body is required", ErrCodeEmptyBody)
+		return nil, false
+	}
+
 	// Parse JSON body
 	var req domain.EventRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	if h.config.Validation.RejectUnknownFields() {
+		dec.DisallowUnknownFields()
+	}
+	if h.config.UseJSONNumber {
+		dec.UseNumber()
+	}
+	if err := dec.Decode(&req); err != nil {
+		// encoding/json has no typed error for unknown fields, so the field
+		// is read from the error text; TestIngestEvent_ValidationMode fails if
+		// that text changes
+		if field, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+			field, _ = strconv.Unquote(field)
+			RecordEventRejected(field)
+			respondErrorWithField(w, http.StatusUnprocessableEntity, "unknown field", field)
+			return nil, false
+		}
 		respondError(w, http.StatusBadRequest, "invalid JSON body", err.Error())
-		return
+		return nil, false
 	}
 
-	// Validate and convert to domain Event
-	event, err := req.ToEvent()
+	// Validate and convert to domain Event. The body is well-formed JSON from
+	// here on, so failures are 422 rather than 400.
+	event, err := req.ToEventWithOptions(h.config.Validation)
 	if err != nil {
 		if ve := domain.AsValidationError(err); ve != nil {
-			respondErrorWithField(w, http.StatusBadRequest, ve.Message, ve.Field)
+			RecordEventRejected(ve.Field)
+			respondErrorWithField(w, http.StatusUnprocessableEntity, ve.Message, ve.Field)
+			return nil, false
+		}
+		respondError(w, http.StatusUnprocessableEntity, "validation failed", err.Error())
+		return nil, false
+	}
+	return event, true
+}
+
+// acceptEvent produces a validated event to Kafka, forwards it to the sinks
+// and returns 202 Accepted. start is when the request began.
+func (h *Handler) acceptEvent(w http.ResponseWriter, r *http.Request, start time.Time, event *domain.Event) {
+	// Ask producers of a match the consumer is behind on to slow down
+	if bp := h.config.MatchBackpressure; bp != nil {
+		if _, lagging := bp.Lag(event.MatchID); lagging {
+			w.Header().Set("Retry-After", retryAfterSeconds(bp.RetryAfter()))
+			respondErrorWithCode(w, http.StatusTooManyRequests, "match is lagging behind, retry later", ErrCodeMatchLagging)
 			return
 		}
-		respondError(w, http.StatusBadRequest, "validation failed", err.Error())
-		return
 	}
 
-	// Produce to Kafka
+	// Ask the consumer to publish the event's status once it is stored
+	confirmations := h.config.Confirmations
+	event.Confirm = confirmations != nil && strings.EqualFold(r.Header.Get(ConfirmHeader), "true")
+
+	// Produce to Kafka, shedding load when the produce pool is saturated
 	ctx := r.Context()
-	if err := h.producer.Produce(ctx, event); err != nil {
+	if limiter := h.config.ProduceLimiter; limiter != nil {
+		if !limiter.Acquire(ctx) {
+			RecordIngestShed()
+			w.Header().Set("Retry-After", "1")
+			respondError(w, http.StatusServiceUnavailable, "ingestion is saturated, retry shortly", "")
+			return
+		}
+		defer limiter.Release()
+	}
+	produceCtx, cancel := context.WithTimeout(ctx, h.config.ProduceTimeout)
+	defer cancel()
+	if err := h.producer.Produce(produceCtx, event); err != nil {
+		if errors.Is(err, domain.ErrMessageTooLarge) {
+			respondError(w, http.StatusRequestEntityTooLarge, "event exceeds maximum message size", "metadata")
+			return
+		}
 		RecordKafkaProduceError()
+		// The produce deadline ran out while the client was still waiting
+		if ctx.Err() == nil && errors.Is(produceCtx.Err(), context.DeadlineExceeded) {
+			LoggerFromContext(ctx).Warn("produce timed out",
+				slog.String("event_id", event.EventID.String()),
+				slog.String("match_id", event.MatchID),
+				slog.Duration("timeout", h.config.ProduceTimeout),
+			)
+			respondErrorWithCode(w, http.StatusServiceUnavailable, "timed out queueing event, retry shortly", ErrCodeProducerTimeout)
+			return
+		}
+		if errors.Is(err, domain.ErrBrokerUnavailable) {
+			// The producer already logged the outage; avoid a log line per request
+			w.Header().Set("Retry-After", "5")
+			respondError(w, http.StatusServiceUnavailable, "message broker unavailable, retry shortly", "")
+			return
+		}
+		LoggerFromContext(ctx).Error("failed to produce event",
+			slog.String("event_id", event.EventID.String()),
+			slog.String("match_id", event.MatchID),
+			slog.String("error", err.Error()),
+		)
 		respondError(w, http.StatusServiceUnavailable, "failed to queue event", "")
 		return
 	}
@@ -81,36 +566,156 @@ func (h *Handler) IngestEvent(w http.ResponseWriter, r *http.Request) {
 	RecordEventIngestDuration(duration)
 	RecordEventResponseTime(duration)
 
+	h.forwardToSinks(event)
+	if event.Confirm {
+		confirmations.Track(event.EventID)
+	}
+
 	// Return 202 Accepted
 	response := IngestEventResponse{
 		EventID:   event.EventID.String(),
 		Status:    "accepted",
-		Timestamp: time.Now().UTC(),
+		Timestamp: h.config.Clock.Now().UTC(),
 	}
 	respondJSON(w, http.StatusAccepted, response)
 }
 
+// EventStatus handles GET /api/events/{eventId}/status.
+// It reports whether an event sent with X-Confirm has been persisted,
+// waiting up to ?wait= (default 10s, capped by the tracker) for a final
+// status. Events that were not tracked and have no published status are 404.
+func (h *Handler) EventStatus(w http.ResponseWriter, r *http.Request) {
+	eventID, err := uuid.Parse(chi.URLParam(r, "eventId"))
+	if err != nil {
+		respondErrorWithField(w, http.StatusBadRequest, "eventId must be a valid UUID", "eventId")
+		return
+	}
+
+	confirmations := h.config.Confirmations
+	wait := min(DefaultStatusWait, confirmations.MaxWait())
+	if raw := r.URL.Query().Get(StatusWaitParam); raw != "" {
+		wait, err = time.ParseDuration(raw)
+		if err != nil || wait < 0 {
+			respondErrorWithField(w, http.StatusBadRequest, "wait must be a non-negative duration", StatusWaitParam)
+			return
+		}
+		wait = min(wait, confirmations.MaxWait())
+	}
+
+	// The long poll may outlast the server write timeout
+	extendWriteDeadline(w, wait)
+
+	ctx := r.Context()
+	status, err := confirmations.Status(ctx, eventID, wait)
+	if err != nil {
+		RecordClickHouseQueryError()
+		LoggerFromContext(ctx).Error("failed to fetch event status",
+			slog.String("event_id", eventID.String()),
+			slog.String("error", err.Error()),
+		)
+		respondRepositoryError(w, ctx, err, "event status query timed out", "failed to fetch event status")
+		return
+	}
+	if status == "" {
+		respondError(w, http.StatusNotFound, "event status not found", "")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, EventStatusResponse{
+		EventID: eventID.String(),
+		Status:  status,
+	})
+}
+
 // GetMatchMetrics handles GET /api/matches/{matchId}/metrics.
-// It queries the repository for match metrics and returns them.
+// It queries the repository for match metrics and returns them. With
+// includePeak=false the peak engagement minute is neither queried nor returned.
 func (h *Handler) GetMatchMetrics(w http.ResponseWriter, r *http.Request) {
-	matchID := chi.URLParam(r, "matchId")
-	if matchID == "" {
-		respondError(w, http.StatusBadRequest, "matchId is required", "")
+	matchID, ok := matchIDParam(w, r)
+	if !ok {
 		return
 	}
 
-	ctx := r.Context()
+	naming := r.URL.Query().Get(FieldNamingParam)
+	if naming != "" && naming != FieldNamingCamelCase && naming != FieldNamingSnakeCase {
+		respondErrorWithField(w, http.StatusBadRequest,
+			fmt.Sprintf("naming must be %q or %q", FieldNamingCamelCase, FieldNamingSnakeCase), FieldNamingParam)
+		return
+	}
+
+	opts := domain.MetricsOptions{}
+	if raw := r.URL.Query().Get(IncludePeakParam); raw != "" {
+		includePeak, err := strconv.ParseBool(raw)
+		if err != nil {
+			respondErrorWithField(w, http.StatusBadRequest, "includePeak must be true or false", IncludePeakParam)
+			return
+		}
+		opts.SkipPeak = !includePeak
+	}
+
+	ctx, cancel, err := h.queryContext(w, r)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error(), QueryTimeoutHeader)
+		return
+	}
+	defer cancel()
+
+	if h.cache != nil && h.config.MetricsCacheTTL > 0 {
+		if cached, ok := h.cache.GetFresh(matchID, h.config.MetricsCacheTTL); ok {
+			cached.ResponseTimePercentiles = GetEventResponseTimePercentiles()
+			cached = applyMetricsOptions(cached, opts)
+			respondJSONWithETag(w, r, metricsBody(cached, naming), metricsETag(cached, naming), metricsCacheControl)
+			return
+		}
+	}
 
 	// Get base metrics
-	metrics, err := h.repository.GetMatchMetrics(ctx, matchID)
+	metrics, err := h.repository.GetMatchMetricsWithOptions(ctx, matchID, opts)
+	if errors.Is(err, domain.ErrMatchNotFound) {
+		respondError(w, http.StatusNotFound, "match not found", "")
+		return
+	}
 	if err != nil {
 		RecordClickHouseQueryError()
-		respondError(w, http.StatusInternalServerError, "failed to fetch metrics", "")
+		LoggerFromContext(ctx).Error("failed to fetch match metrics",
+			slog.String("match_id", matchID),
+			slog.String("error", err.Error()),
+		)
+		if h.cache != nil && h.config.ServeStaleOnError {
+			if cached, cachedAt, ok := h.cache.Get(matchID); ok {
+				w.Header().Set(DataStaleHeader, "true")
+				w.Header().Set(DataCachedAtHeader, cachedAt.UTC().Format(time.RFC3339))
+				respondJSON(w, http.StatusOK, metricsBody(applyMetricsOptions(cached, opts), naming))
+				return
+			}
+		}
+		respondRepositoryError(w, ctx, err, "metrics query timed out", "failed to fetch metrics")
 		return
 	}
 
-	if metrics == nil || metrics.TotalEvents == 0 {
-		respondError(w, http.StatusNotFound, "match not found", "")
+	// An empty result without an error is still a valid, empty response
+	if metrics == nil {
+		metrics = &domain.MatchMetrics{
+			MatchID:      matchID,
+			EventsByType: make(map[string]int64),
+		}
+	}
+
+	// A closed match's snapshot is final, including its peak minute
+	if metrics.ClosedAt != nil {
+		metrics.ResponseTimePercentiles = GetEventResponseTimePercentiles()
+		if h.cache != nil {
+			h.cache.Set(matchID, metrics)
+		}
+		metrics = applyMetricsOptions(metrics, opts)
+		respondJSONWithETag(w, r, metricsBody(metrics, naming), metricsETag(metrics, naming), metricsCacheControl)
+		return
+	}
+
+	// Without the peak the metrics are incomplete, so they are not cached
+	if opts.SkipPeak {
+		metrics.ResponseTimePercentiles = GetEventResponseTimePercentiles()
+		respondJSONWithETag(w, r, metricsBody(metrics, naming), metricsETag(metrics, naming), metricsCacheControl)
 		return
 	}
 
@@ -118,45 +723,601 @@ func (h *Handler) GetMatchMetrics(w http.ResponseWriter, r *http.Request) {
 	eventsPerMinute, err := h.repository.GetEventsPerMinute(ctx, matchID)
 	if err != nil {
 		RecordClickHouseQueryError()
+		LoggerFromContext(ctx).Warn("failed to fetch events per minute, omitting peak",
+			slog.String("match_id", matchID),
+			slog.String("error", err.Error()),
+		)
 		// Continue without peak engagement data
-		respondJSON(w, http.StatusOK, metrics)
+		respondJSONWithETag(w, r, metricsBody(metrics, naming), metricsETag(metrics, naming), metricsCacheControl)
 		return
 	}
 
-	// Calculate peak engagement
-	var peak *domain.PeakEngagement
-	if len(eventsPerMinute) > 0 {
-		// Aggregate events per minute across all event types
-		minuteTotals := make(map[time.Time]int64)
-		for _, epm := range eventsPerMinute {
-			minuteTotals[epm.Minute] += epm.EventCount
+	// Update metrics with peak engagement
+	metrics.PeakMinute = peakEngagement(eventsPerMinute, h.config.EngagementWeights)
+
+	// Add response time percentiles
+	metrics.ResponseTimePercentiles = GetEventResponseTimePercentiles()
+
+	if h.cache != nil {
+		h.cache.Set(matchID, metrics)
+	}
+
+	respondJSONWithETag(w, r, metricsBody(metrics, naming), metricsETag(metrics, naming), metricsCacheControl)
+}
+
+// IncludePeakParam is the query parameter that, set to false, skips computing
+// the peak engagement minute.
+const IncludePeakParam = "includePeak"
+
+// applyMetricsOptions returns metrics without the parts opts skips, copying
+// rather than modifying metrics when anything is removed.
+func applyMetricsOptions(metrics *domain.MatchMetrics, opts domain.MetricsOptions) *domain.MatchMetrics {
+	if !opts.SkipPeak || metrics.PeakMinute == nil {
+		return metrics
+	}
+	stripped := *metrics
+	stripped.PeakMinute = nil
+	return &stripped
+}
+
+// FieldNamingParam selects the JSON field naming of a metrics response.
+const FieldNamingParam = "naming"
+
+// Supported values for FieldNamingParam; camelCase is the default.
+const (
+	FieldNamingCamelCase = "camelCase"
+	FieldNamingSnakeCase = "snake_case"
+)
+
+// metricsBody returns the representation of m for the requested field naming.
+func metricsBody(m *domain.MatchMetrics, naming string) interface{} {
+	if naming == FieldNamingSnakeCase {
+		return m.SnakeCase()
+	}
+	return m
+}
+
+// metricsCacheControl lets clients and proxies reuse a metrics response for a second,
+// matching the dashboards' polling interval.
+const metricsCacheControl = "max-age=1"
+
+// metricsETag derives a weak ETag from the fields that change whenever new events
+// are stored or match info is registered, plus the field naming so each
+// representation is tagged separately.
+// It is weak because percentiles may differ between equal tags.
+func metricsETag(m *domain.MatchMetrics, naming string) string {
+	var lastEventAt int64
+	if m.LastEventAt != nil {
+		lastEventAt = m.LastEventAt.UnixNano()
+	}
+	key := fmt.Sprintf("%s|%d|%d|%s", m.MatchID, m.TotalEvents, lastEventAt, m.Competition)
+	if m.ClosedAt != nil {
+		key += fmt.Sprintf("|closed=%d", m.ClosedAt.UnixNano())
+	}
+	teamIDs := make([]int, 0, len(m.TeamNames))
+	for teamID := range m.TeamNames {
+		teamIDs = append(teamIDs, teamID)
+	}
+	sort.Ints(teamIDs)
+	for _, teamID := range teamIDs {
+		key += fmt.Sprintf("|%d=%s", teamID, m.TeamNames[teamID])
+	}
+	if naming == FieldNamingSnakeCase {
+		key += "|" + naming
+	}
+	sum := sha256.Sum256([]byte(key))
+	return `W/"` + hex.EncodeToString(sum[:8]) + `"`
+}
+
+// HeadMatchMetrics handles HEAD /api/matches/{matchId}/metrics.
+// It returns 200 if the match has any events its metrics count, corrected
+// events excluded, and 404 otherwise, with no body.
+func (h *Handler) HeadMatchMetrics(w http.ResponseWriter, r *http.Request) {
+	matchID, ok := matchIDParam(w, r)
+	if !ok {
+		return
+	}
+
+	ctx, cancel, err := h.queryContext(w, r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	defer cancel()
+
+	exists, err := h.repository.MatchExists(ctx, matchID)
+	if err != nil {
+		RecordClickHouseQueryError()
+		LoggerFromContext(ctx).Error("failed to check match existence",
+			slog.String("match_id", matchID),
+			slog.String("error", err.Error()),
+		)
+		w.WriteHeader(repositoryErrorStatus(ctx, err))
+		return
+	}
+
+	if !exists {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// TeamTimelineResponse represents a team's per-minute event breakdown.
+type TeamTimelineResponse struct {
+	MatchID  string                   `json:"matchId"`
+	TeamID   int                      `json:"teamId"`
+	Timeline []domain.EventsPerMinute `json:"timeline"`
+}
+
+// GetTeamTimeline handles GET /api/matches/{matchId}/timeline/{teamId}.
+// It returns per-minute event counts for one team, for momentum charts.
+func (h *Handler) GetTeamTimeline(w http.ResponseWriter, r *http.Request) {
+	matchID, ok := matchIDParam(w, r)
+	if !ok {
+		return
+	}
+
+	teamID, err := strconv.Atoi(chi.URLParam(r, "teamId"))
+	if err != nil {
+		respondErrorWithField(w, http.StatusBadRequest, "must be an integer", "teamId")
+		return
+	}
+	if err := domain.ValidateTeamID(teamID, h.config.Validation.MaxTeamID); err != nil {
+		ve := domain.AsValidationError(err)
+		respondErrorWithField(w, http.StatusBadRequest, ve.Message, ve.Field)
+		return
+	}
+
+	ctx, cancel, err := h.queryContext(w, r)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error(), QueryTimeoutHeader)
+		return
+	}
+	defer cancel()
+
+	timeline, err := h.repository.GetTeamEventsPerMinute(ctx, matchID, teamID)
+	if err != nil {
+		RecordClickHouseQueryError()
+		LoggerFromContext(ctx).Error("failed to fetch team timeline",
+			slog.String("match_id", matchID),
+			slog.Int("team_id", teamID),
+			slog.String("error", err.Error()),
+		)
+		respondRepositoryError(w, ctx, err, "team timeline query timed out", "failed to fetch team timeline")
+		return
+	}
+
+	if len(timeline) == 0 {
+		respondError(w, http.StatusNotFound, "no events for team", "")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, TeamTimelineResponse{
+		MatchID:  matchID,
+		TeamID:   teamID,
+		Timeline: timeline,
+	})
+}
+
+// RateWindowParam is the query parameter selecting the rate window in seconds.
+const RateWindowParam = "window"
+
+// Rate window bounds, in seconds. Larger windows are capped at
+// MaxRateWindowSeconds to keep the scan small.
+const (
+	DefaultRateWindowSeconds = 60
+	MaxRateWindowSeconds     = 300
+)
+
+// EventRateResponse represents a match's per-second event counts over the
+// last WindowSeconds seconds, oldest first, with one entry per second.
+type EventRateResponse struct {
+	MatchID       string                   `json:"matchId"`
+	WindowSeconds int                      `json:"windowSeconds"`
+	Rate          []domain.EventsPerSecond `json:"rate"`
+}
+
+// GetEventRate handles GET /api/matches/{matchId}/rate.
+// It returns per-second event counts for the last window seconds, for live graphs.
+func (h *Handler) GetEventRate(w http.ResponseWriter, r *http.Request) {
+	matchID, ok := matchIDParam(w, r)
+	if !ok {
+		return
+	}
+
+	window := DefaultRateWindowSeconds
+	if raw := r.URL.Query().Get(RateWindowParam); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 {
+			respondErrorWithField(w, http.StatusBadRequest, "must be a positive integer", RateWindowParam)
+			return
 		}
+		window = min(n, MaxRateWindowSeconds)
+	}
 
-		// Find the peak minute
-		var peakMinute time.Time
-		var peakCount int64
-		for minute, count := range minuteTotals {
-			if count > peakCount {
-				peakCount = count
-				peakMinute = minute
-			}
+	ctx, cancel, err := h.queryContext(w, r)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error(), QueryTimeoutHeader)
+		return
+	}
+	defer cancel()
+
+	// The window ends with the current, partial second
+	end := h.config.Clock.Now().UTC().Truncate(time.Second)
+	start := end.Add(-time.Duration(window-1) * time.Second)
+
+	counts, err := h.repository.GetEventsPerSecond(ctx, matchID, start)
+	if err != nil {
+		RecordClickHouseQueryError()
+		LoggerFromContext(ctx).Error("failed to fetch event rate",
+			slog.String("match_id", matchID),
+			slog.Int("window_seconds", window),
+			slog.String("error", err.Error()),
+		)
+		respondRepositoryError(w, ctx, err, "event rate query timed out", "failed to fetch event rate")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, EventRateResponse{
+		MatchID:       matchID,
+		WindowSeconds: window,
+		Rate:          fillSeconds(counts, start, window),
+	})
+}
+
+// fillSeconds returns one entry per second from start, taking counts from
+// the sparse per-second rows and zero for seconds without events.
+func fillSeconds(counts []domain.EventsPerSecond, start time.Time, seconds int) []domain.EventsPerSecond {
+	bySecond := make(map[int64]int64, len(counts))
+	for _, c := range counts {
+		bySecond[c.Second.Unix()] += c.EventCount
+	}
+
+	rate := make([]domain.EventsPerSecond, seconds)
+	for i := range rate {
+		second := start.Add(time.Duration(i) * time.Second)
+		rate[i] = domain.EventsPerSecond{Second: second, EventCount: bySecond[second.Unix()]}
+	}
+	return rate
+}
+
+// RegisterMatchInfo handles POST /api/matches/{matchId}.
+// It stores the competition and team names shown alongside the match's metrics.
+func (h *Handler) RegisterMatchInfo(w http.ResponseWriter, r *http.Request) {
+	matchID, ok := matchIDParam(w, r)
+	if !ok {
+		return
+	}
+
+	var req domain.MatchInfoRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid JSON body", err.Error())
+		return
+	}
+
+	info, err := req.ToMatchInfo(matchID, h.config.Validation.MaxTeamID)
+	if err != nil {
+		if ve := domain.AsValidationError(err); ve != nil {
+			respondErrorWithField(w, http.StatusBadRequest, ve.Message, ve.Field)
+			return
 		}
+		respondError(w, http.StatusBadRequest, "validation failed", err.Error())
+		return
+	}
 
-		if peakCount > 0 {
-			peak = &domain.PeakEngagement{
-				Minute:     peakMinute,
-				EventCount: peakCount,
-			}
+	ctx, cancel, err := h.queryContext(w, r)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error(), QueryTimeoutHeader)
+		return
+	}
+	defer cancel()
+
+	if err := h.repository.UpsertMatchInfo(ctx, info); err != nil {
+		RecordClickHouseQueryError()
+		LoggerFromContext(ctx).Error("failed to store match info",
+			slog.String("match_id", matchID),
+			slog.String("error", err.Error()),
+		)
+		respondRepositoryError(w, ctx, err, "storing match info timed out", "failed to store match info")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, info)
+}
+
+// GetEventMatrix handles GET /api/matches/{matchId}/matrix.
+// It returns event counts by minute and event type as a dense matrix for heatmaps.
+func (h *Handler) GetEventMatrix(w http.ResponseWriter, r *http.Request) {
+	matchID, ok := matchIDParam(w, r)
+	if !ok {
+		return
+	}
+
+	ctx, cancel, err := h.queryContext(w, r)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error(), QueryTimeoutHeader)
+		return
+	}
+	defer cancel()
+
+	matrix, err := h.repository.GetEventMatrix(ctx, matchID)
+	if errors.Is(err, domain.ErrMatchNotFound) {
+		respondError(w, http.StatusNotFound, "match not found", "")
+		return
+	}
+	if err != nil {
+		RecordClickHouseQueryError()
+		LoggerFromContext(ctx).Error("failed to fetch event matrix",
+			slog.String("match_id", matchID),
+			slog.String("error", err.Error()),
+		)
+		respondRepositoryError(w, ctx, err, "event matrix query timed out", "failed to fetch event matrix")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, matrix)
+}
+
+// GetEventDistribution handles GET /api/matches/{matchId}/distribution.
+// It returns each event type's count and percentage of the match's events.
+func (h *Handler) GetEventDistribution(w http.ResponseWriter, r *http.Request) {
+	matchID, ok := matchIDParam(w, r)
+	if !ok {
+		return
+	}
+
+	ctx, cancel, err := h.queryContext(w, r)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error(), QueryTimeoutHeader)
+		return
+	}
+	defer cancel()
+
+	metrics, err := h.repository.GetMatchMetrics(ctx, matchID)
+	if errors.Is(err, domain.ErrMatchNotFound) {
+		respondError(w, http.StatusNotFound, "match not found", "")
+		return
+	}
+	if err != nil {
+		RecordClickHouseQueryError()
+		LoggerFromContext(ctx).Error("failed to fetch event distribution",
+			slog.String("match_id", matchID),
+			slog.String("error", err.Error()),
+		)
+		respondRepositoryError(w, ctx, err, "event distribution query timed out", "failed to fetch event distribution")
+		return
+	}
+
+	var eventsByType map[string]int64
+	if metrics != nil {
+		eventsByType = metrics.EventsByType
+	}
+	respondJSON(w, http.StatusOK, domain.NewEventDistribution(matchID, eventsByType))
+}
+
+// GetConversionStats handles GET /api/matches/{matchId}/conversion.
+// It returns the match's shots, shots on target and goals with the on-target
+// percentage and goals per shot.
+func (h *Handler) GetConversionStats(w http.ResponseWriter, r *http.Request) {
+	matchID, ok := matchIDParam(w, r)
+	if !ok {
+		return
+	}
+
+	ctx, cancel, err := h.queryContext(w, r)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error(), QueryTimeoutHeader)
+		return
+	}
+	defer cancel()
+
+	stats, err := h.repository.GetConversionStats(ctx, matchID)
+	if errors.Is(err, domain.ErrMatchNotFound) {
+		respondError(w, http.StatusNotFound, "match not found", "")
+		return
+	}
+	if err != nil {
+		RecordClickHouseQueryError()
+		LoggerFromContext(ctx).Error("failed to fetch conversion stats",
+			slog.String("match_id", matchID),
+			slog.String("error", err.Error()),
+		)
+		respondRepositoryError(w, ctx, err, "conversion stats query timed out", "failed to fetch conversion stats")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, stats)
+}
+
+// Leaderboard defaults. Without from, a leaderboard covers DefaultLeaderboardRange
+// up to to, which itself defaults to now.
+const (
+	DefaultLeaderboardLimit = 10
+	DefaultLeaderboardRange = 30 * 24 * time.Hour
+)
+
+// Leaderboard range headers echo the resolved [from, to) of a leaderboard as
+// RFC3339 timestamps with any fractional seconds, since from and to may be defaulted.
+const (
+	LeaderboardFromHeader = "X-Leaderboard-From"
+	LeaderboardToHeader   = "X-Leaderboard-To"
+)
+
+// GetLeaderboard handles GET /api/leaderboard.
+// It ranks players by their events of the type query parameter, e.g. goal,
+// across every match, for competition pages. from and to are RFC3339
+// timestamps bounding the events counted, and limit caps the players returned
+// at MaxLeaderboardLimit. The players are returned as a single PagedResponse
+// page, with hasMore set when more players qualified, and the resolved range
+// in the leaderboard range headers.
+func (h *Handler) GetLeaderboard(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	eventType := query.Get("type")
+	if eventType == "" {
+		respondErrorWithField(w, http.StatusBadRequest, "event type is required", "type")
+		return
+	}
+
+	to := h.config.Clock.Now().UTC()
+	if raw := query.Get("to"); raw != "" {
+		parsed, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			respondErrorWithField(w, http.StatusBadRequest, "must be an RFC3339 timestamp", "to")
+			return
+		}
+		to = parsed.UTC()
+	}
+	from := to.Add(-DefaultLeaderboardRange)
+	if raw := query.Get("from"); raw != "" {
+		parsed, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			respondErrorWithField(w, http.StatusBadRequest, "must be an RFC3339 timestamp", "from")
+			return
+		}
+		from = parsed.UTC()
+	}
+	limit := DefaultLeaderboardLimit
+	if raw := query.Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			respondErrorWithField(w, http.StatusBadRequest, "must be an integer", "limit")
+			return
+		}
+		limit = n
+	}
+	if err := domain.ValidateLeaderboardQuery(eventType, from, to, limit); err != nil {
+		if ve := domain.AsValidationError(err); ve != nil {
+			respondErrorWithField(w, http.StatusBadRequest, ve.Message, ve.Field)
+			return
 		}
+		respondError(w, http.StatusBadRequest, err.Error(), "")
+		return
 	}
 
-	// Update metrics with peak engagement
-	metrics.PeakMinute = peak
+	ctx, cancel, err := h.queryContext(w, r)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error(), QueryTimeoutHeader)
+		return
+	}
+	defer cancel()
 
-	// Add response time percentiles
-	metrics.ResponseTimePercentiles = GetEventResponseTimePercentiles()
+	// Fetch one player past the page to know whether more qualified
+	players, err := h.repository.GetLeaderboard(ctx, eventType, from, to, limit+1)
+	if err != nil {
+		RecordClickHouseQueryError()
+		LoggerFromContext(ctx).Error("failed to fetch leaderboard",
+			slog.String("event_type", eventType),
+			slog.String("error", err.Error()),
+		)
+		respondRepositoryError(w, ctx, err, "leaderboard query timed out", "failed to fetch leaderboard")
+		return
+	}
 
-	respondJSON(w, http.StatusOK, metrics)
+	w.Header().Set(LeaderboardFromHeader, from.Format(time.RFC3339Nano))
+	w.Header().Set(LeaderboardToHeader, to.Format(time.RFC3339Nano))
+	respondJSON(w, http.StatusOK, NewPagedResponse(players, limit, nil))
+}
+
+// metadataFilterPrefix marks the query parameters of an event search that filter on metadata.
+const metadataFilterPrefix = "meta."
+
+// SearchEvents handles GET /api/matches/{matchId}/events/search.
+// Each meta.<key>=<value> query parameter keeps only events whose metadata has
+// that value for key; at least one filter is required. Results are in timestamp
+// order and returned as a PagedResponse capped at domain.MaxSearchResults, with
+// hasMore set when more matched.
+func (h *Handler) SearchEvents(w http.ResponseWriter, r *http.Request) {
+	matchID, ok := matchIDParam(w, r)
+	if !ok {
+		return
+	}
+
+	filters := make(map[string]string)
+	for param, values := range r.URL.Query() {
+		key, ok := strings.CutPrefix(param, metadataFilterPrefix)
+		if !ok {
+			continue
+		}
+		if len(values) > 1 {
+			respondErrorWithField(w, http.StatusBadRequest, "must be given once", param)
+			return
+		}
+		filters[key] = values[0]
+	}
+	if len(filters) == 0 {
+		respondErrorWithField(w, http.StatusBadRequest, "at least one meta.<key> filter is required", "meta")
+		return
+	}
+	if err := domain.ValidateMetadataFilters(filters); err != nil {
+		ve := domain.AsValidationError(err)
+		respondErrorWithField(w, http.StatusBadRequest, ve.Message, ve.Field)
+		return
+	}
+
+	ctx, cancel, err := h.queryContext(w, r)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error(), QueryTimeoutHeader)
+		return
+	}
+	defer cancel()
+
+	events, err := h.repository.SearchEvents(ctx, matchID, filters)
+	if err != nil {
+		RecordClickHouseQueryError()
+		LoggerFromContext(ctx).Error("failed to search events",
+			slog.String("match_id", matchID),
+			slog.String("error", err.Error()),
+		)
+		respondRepositoryError(w, ctx, err, "event search timed out", "failed to search events")
+		return
+	}
+	messages := make([]domain.KafkaMessage, 0, len(events))
+	for _, event := range events {
+		messages = append(messages, event.AsKafkaMessage())
+	}
+	respondJSON(w, http.StatusOK, NewPagedResponse(messages, domain.MaxSearchResults, nil))
+}
+
+// peakEngagement finds the minute with the highest weighted engagement score.
+// Ties are broken by the earliest minute. Returns nil if there are no events.
+func peakEngagement(eventsPerMinute []domain.EventsPerMinute, weights domain.EngagementWeights) *domain.PeakEngagement {
+	if len(eventsPerMinute) == 0 {
+		return nil
+	}
+
+	// Aggregate counts and weighted scores per minute across all event types
+	minuteCounts := make(map[time.Time]int64)
+	minuteScores := make(map[time.Time]float64)
+	for _, epm := range eventsPerMinute {
+		minuteCounts[epm.Minute] += epm.EventCount
+		minuteScores[epm.Minute] += float64(epm.EventCount) * weights.Weight(domain.EventType(epm.EventType))
+	}
+
+	// Find the peak minute
+	var peakMinute time.Time
+	var peakScore float64
+	found := false
+	for minute, score := range minuteScores {
+		if minuteCounts[minute] == 0 {
+			continue
+		}
+		if !found || score > peakScore || (score == peakScore && minute.Before(peakMinute)) {
+			peakMinute = minute
+			peakScore = score
+			found = true
+		}
+	}
+
+	if !found {
+		return nil
+	}
+
+	return &domain.PeakEngagement{
+		Minute:     peakMinute,
+		EventCount: minuteCounts[peakMinute],
+		Score:      peakScore,
+	}
 }
 
 // HealthResponse represents the response for health check endpoints.
@@ -170,7 +1331,7 @@ type HealthResponse struct {
 func (h *Handler) HealthCheck(w http.ResponseWriter, r *http.Request) {
 	response := HealthResponse{
 		Status:    "healthy",
-		Timestamp: time.Now().UTC(),
+		Timestamp: h.config.Clock.Now().UTC(),
 	}
 	respondJSON(w, http.StatusOK, response)
 }
@@ -183,17 +1344,34 @@ type ReadinessResponse struct {
 }
 
 // ReadinessCheck handles GET /ready.
-// It verifies that dependencies (repository) are available.
+// It verifies that startup warmup has completed and that dependencies
+// (repository) are available.
 func (h *Handler) ReadinessCheck(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	checks := make(map[string]string)
 
+	if warmup := h.config.Warmup; warmup != nil {
+		if !warmup.Ready() {
+			checks["warmup"] = "warming up"
+			if err := warmup.Err(); err != nil {
+				checks["warmup"] += ": " + err.Error()
+			}
+			respondJSON(w, http.StatusServiceUnavailable, ReadinessResponse{
+				Status:    "not ready",
+				Timestamp: h.config.Clock.Now().UTC(),
+				Checks:    checks,
+			})
+			return
+		}
+		checks["warmup"] = "complete"
+	}
+
 	// Check repository connectivity
 	if err := h.repository.Ping(ctx); err != nil {
 		checks["clickhouse"] = "unhealthy: " + err.Error()
 		response := ReadinessResponse{
 			Status:    "not ready",
-			Timestamp: time.Now().UTC(),
+			Timestamp: h.config.Clock.Now().UTC(),
 			Checks:    checks,
 		}
 		respondJSON(w, http.StatusServiceUnavailable, response)
@@ -203,7 +1381,7 @@ func (h *Handler) ReadinessCheck(w http.ResponseWriter, r *http.Request) {
 
 	response := ReadinessResponse{
 		Status:    "ready",
-		Timestamp: time.Now().UTC(),
+		Timestamp: h.config.Clock.Now().UTC(),
 		Checks:    checks,
 	}
 	respondJSON(w, http.StatusOK, response)
@@ -2,11 +2,15 @@ package api_test
 
 import (
 	"bytes"
+	"compress/gzip"
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
@@ -19,7 +23,8 @@ import (
 
 // MockProducer implements api.EventProducer for testing.
 type MockProducer struct {
-	ProduceFunc func(ctx context.Context, event *domain.Event) error
+	ProduceFunc      func(ctx context.Context, event *domain.Event) error
+	ProduceBatchFunc func(ctx context.Context, events []*domain.Event) error
 }
 
 func (m *MockProducer) Produce(ctx context.Context, event *domain.Event) error {
@@ -29,11 +34,30 @@ func (m *MockProducer) Produce(ctx context.Context, event *domain.Event) error {
 	return nil
 }
 
+func (m *MockProducer) ProduceBatch(ctx context.Context, events []*domain.Event) error {
+	if m.ProduceBatchFunc != nil {
+		return m.ProduceBatchFunc(ctx, events)
+	}
+	return nil
+}
+
 // MockRepository implements api.MetricsRepository for testing.
 type MockRepository struct {
-	GetMatchMetricsFunc    func(ctx context.Context, matchID string) (*domain.MatchMetrics, error)
-	GetEventsPerMinuteFunc func(ctx context.Context, matchID string) ([]domain.EventsPerMinute, error)
-	PingFunc               func(ctx context.Context) error
+	GetMatchMetricsFunc        func(ctx context.Context, matchID string) (*domain.MatchMetrics, error)
+	GetMatchMetricsOptionsFunc func(ctx context.Context, matchID string, opts domain.MetricsOptions) (*domain.MatchMetrics, error)
+	GetEventsPerMinuteFunc     func(ctx context.Context, matchID string) ([]domain.EventsPerMinute, error)
+	GetEventMatrixFunc         func(ctx context.Context, matchID string) (domain.EventMatrix, error)
+	GetConversionStatsFunc     func(ctx context.Context, matchID string) (domain.ConversionStats, error)
+	GetLeaderboardFunc         func(ctx context.Context, eventType string, from, to time.Time, limit int) ([]domain.PlayerStat, error)
+	GetTeamEventsPerMinuteFunc func(ctx context.Context, matchID string, teamID int) ([]domain.EventsPerMinute, error)
+	GetEventsPerSecondFunc     func(ctx context.Context, matchID string, since time.Time) ([]domain.EventsPerSecond, error)
+	StreamEventsFunc           func(ctx context.Context, matchID string, fn func(*domain.Event) error) error
+	SearchEventsFunc           func(ctx context.Context, matchID string, metadataFilters map[string]string) ([]*domain.Event, error)
+	MatchExistsFunc            func(ctx context.Context, matchID string) (bool, error)
+	UpsertMatchInfoFunc        func(ctx context.Context, info *domain.MatchInfo) error
+	CloseMatchFunc             func(ctx context.Context, matchID string) (*domain.MatchMetrics, error)
+	CountEventsFunc            func(ctx context.Context, matchID string) (int64, error)
+	PingFunc                   func(ctx context.Context) error
 }
 
 func (m *MockRepository) GetMatchMetrics(ctx context.Context, matchID string) (*domain.MatchMetrics, error) {
@@ -43,6 +67,15 @@ func (m *MockRepository) GetMatchMetrics(ctx context.Context, matchID string) (*
 	return nil, nil
 }
 
+// GetMatchMetricsWithOptions falls back to GetMatchMetricsFunc when no
+// options-aware mock is set.
+func (m *MockRepository) GetMatchMetricsWithOptions(ctx context.Context, matchID string, opts domain.MetricsOptions) (*domain.MatchMetrics, error) {
+	if m.GetMatchMetricsOptionsFunc != nil {
+		return m.GetMatchMetricsOptionsFunc(ctx, matchID, opts)
+	}
+	return m.GetMatchMetrics(ctx, matchID)
+}
+
 func (m *MockRepository) GetEventsPerMinute(ctx context.Context, matchID string) ([]domain.EventsPerMinute, error) {
 	if m.GetEventsPerMinuteFunc != nil {
 		return m.GetEventsPerMinuteFunc(ctx, matchID)
@@ -50,6 +83,83 @@ func (m *MockRepository) GetEventsPerMinute(ctx context.Context, matchID string)
 	return nil, nil
 }
 
+func (m *MockRepository) GetTeamEventsPerMinute(ctx context.Context, matchID string, teamID int) ([]domain.EventsPerMinute, error) {
+	if m.GetTeamEventsPerMinuteFunc != nil {
+		return m.GetTeamEventsPerMinuteFunc(ctx, matchID, teamID)
+	}
+	return nil, nil
+}
+
+func (m *MockRepository) GetEventsPerSecond(ctx context.Context, matchID string, since time.Time) ([]domain.EventsPerSecond, error) {
+	if m.GetEventsPerSecondFunc != nil {
+		return m.GetEventsPerSecondFunc(ctx, matchID, since)
+	}
+	return nil, nil
+}
+
+func (m *MockRepository) GetEventMatrix(ctx context.Context, matchID string) (domain.EventMatrix, error) {
+	if m.GetEventMatrixFunc != nil {
+		return m.GetEventMatrixFunc(ctx, matchID)
+	}
+	return domain.EventMatrix{}, nil
+}
+
+func (m *MockRepository) GetConversionStats(ctx context.Context, matchID string) (domain.ConversionStats, error) {
+	if m.GetConversionStatsFunc != nil {
+		return m.GetConversionStatsFunc(ctx, matchID)
+	}
+	return domain.ConversionStats{}, nil
+}
+
+func (m *MockRepository) GetLeaderboard(ctx context.Context, eventType string, from, to time.Time, limit int) ([]domain.PlayerStat, error) {
+	if m.GetLeaderboardFunc != nil {
+		return m.GetLeaderboardFunc(ctx, eventType, from, to, limit)
+	}
+	return nil, nil
+}
+
+func (m *MockRepository) StreamEvents(ctx context.Context, matchID string, fn func(*domain.Event) error) error {
+	if m.StreamEventsFunc != nil {
+		return m.StreamEventsFunc(ctx, matchID, fn)
+	}
+	return nil
+}
+
+func (m *MockRepository) SearchEvents(ctx context.Context, matchID string, metadataFilters map[string]string) ([]*domain.Event, error) {
+	if m.SearchEventsFunc != nil {
+		return m.SearchEventsFunc(ctx, matchID, metadataFilters)
+	}
+	return nil, nil
+}
+
+func (m *MockRepository) MatchExists(ctx context.Context, matchID string) (bool, error) {
+	if m.MatchExistsFunc != nil {
+		return m.MatchExistsFunc(ctx, matchID)
+	}
+	return false, nil
+}
+
+func (m *MockRepository) UpsertMatchInfo(ctx context.Context, info *domain.MatchInfo) error {
+	if m.UpsertMatchInfoFunc != nil {
+		return m.UpsertMatchInfoFunc(ctx, info)
+	}
+	return nil
+}
+
+func (m *MockRepository) CloseMatch(ctx context.Context, matchID string) (*domain.MatchMetrics, error) {
+	if m.CloseMatchFunc != nil {
+		return m.CloseMatchFunc(ctx, matchID)
+	}
+	return nil, nil
+}
+
+func (m *MockRepository) CountEvents(ctx context.Context, matchID string) (int64, error) {
+	if m.CountEventsFunc != nil {
+		return m.CountEventsFunc(ctx, matchID)
+	}
+	return 0, nil
+}
+
 func (m *MockRepository) Ping(ctx context.Context) error {
 	if m.PingFunc != nil {
 		return m.PingFunc(ctx)
@@ -132,6 +242,92 @@ func TestIngestEvent_Success(t *testing.T) {
 	}
 }
 
+func TestIngestEvent_EventTypeAlias(t *testing.T) {
+	var capturedEvent *domain.Event
+
+	mockProducer := &MockProducer{
+		ProduceFunc: func(ctx context.Context, event *domain.Event) error {
+			capturedEvent = event
+			return nil
+		},
+	}
+	handler := api.NewHandler(mockProducer, &MockRepository{})
+
+	body := []byte(`{
+		"eventId": "` + uuid.New().String() + `",
+		"matchId": "match-123",
+		"eventType": "freekick",
+		"timestamp": "2024-01-15T14:30:00Z",
+		"teamId": 1
+	}`)
+	req := httptest.NewRequest(http.MethodPost, "/api/events", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	rr := httptest.NewRecorder()
+	handler.IngestEvent(rr, req)
+
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusAccepted, rr.Code, rr.Body.String())
+	}
+	if capturedEvent == nil {
+		t.Fatal("expected producer to receive event, got nil")
+	}
+	if capturedEvent.EventType != domain.EventTypeFreeKick {
+		t.Errorf("expected produced EventType 'free_kick', got '%s'", capturedEvent.EventType)
+	}
+}
+
+// TestIngestEvent_ValidationMode tests that a payload with an unknown field and
+// an out-of-range minute is rejected in strict mode and accepted in lenient mode.
+func TestIngestEvent_ValidationMode(t *testing.T) {
+	body := []byte(`{
+		"eventId": "` + uuid.New().String() + `",
+		"matchId": "match-123",
+		"eventType": "goal",
+		"timestamp": "` + time.Now().UTC().Format(time.RFC3339) + `",
+		"teamId": 1,
+		"venue": "stadium",
+		"metadata": {"minute": 200}
+	}`)
+
+	testCases := []struct {
+		mode       domain.ValidationMode
+		wantStatus int
+		wantField  string
+	}{
+		{domain.ValidationModeStrict, http.StatusUnprocessableEntity, "venue"},
+		{domain.ValidationModeLenient, http.StatusAccepted, ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(string(tc.mode), func(t *testing.T) {
+			cfg := api.DefaultHandlerConfig()
+			cfg.Validation.ValidateMinute = true
+			cfg.Validation.Mode = tc.mode
+			handler := api.NewHandlerWithConfig(&MockProducer{}, &MockRepository{}, cfg)
+
+			req := httptest.NewRequest(http.MethodPost, "/api/events", bytes.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			rr := httptest.NewRecorder()
+			handler.IngestEvent(rr, req)
+
+			if rr.Code != tc.wantStatus {
+				t.Fatalf("expected status %d, got %d: %s", tc.wantStatus, rr.Code, rr.Body.String())
+			}
+			if tc.wantField == "" {
+				return
+			}
+			var errResp api.ErrorResponse
+			if err := json.NewDecoder(rr.Body).Decode(&errResp); err != nil {
+				t.Fatalf("failed to decode error response: %v", err)
+			}
+			if errResp.Field != tc.wantField {
+				t.Errorf("expected field '%s', got '%s'", tc.wantField, errResp.Field)
+			}
+		})
+	}
+}
+
 func TestIngestEvent_InvalidJSON(t *testing.T) {
 	mockProducer := &MockProducer{}
 	mockRepo := &MockRepository{}
@@ -160,6 +356,44 @@ func TestIngestEvent_InvalidJSON(t *testing.T) {
 	}
 }
 
+func TestIngestEvent_MalformedVersusInvalid(t *testing.T) {
+	testCases := []struct {
+		name       string
+		body       string
+		wantStatus int
+		wantField  string
+	}{
+		{"truncated JSON", `{"eventId": "550e8400-e29b-41d4-a716-446655440000", "teamId": `, http.StatusBadRequest, ""},
+		{"not an object", `[1, 2, 3]`, http.StatusBadRequest, ""},
+		{"team out of range", `{"eventId": "550e8400-e29b-41d4-a716-446655440000", "matchId": "match-123", "eventType": "goal", "timestamp": "2024-01-15T14:30:00Z", "teamId": 7}`, http.StatusUnprocessableEntity, "teamId"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			handler := api.NewHandler(&MockProducer{}, &MockRepository{})
+
+			req := httptest.NewRequest(http.MethodPost, "/api/events", strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", "application/json")
+			rr := httptest.NewRecorder()
+			handler.IngestEvent(rr, req)
+
+			if rr.Code != tc.wantStatus {
+				t.Fatalf("expected status %d, got %d: %s", tc.wantStatus, rr.Code, rr.Body.String())
+			}
+			var errResp api.ErrorResponse
+			if err := json.NewDecoder(rr.Body).Decode(&errResp); err != nil {
+				t.Fatalf("failed to decode error response: %v", err)
+			}
+			if errResp.Error != http.StatusText(tc.wantStatus) {
+				t.Errorf("expected error %q, got %q", http.StatusText(tc.wantStatus), errResp.Error)
+			}
+			if tc.wantField != "" && errResp.Field != tc.wantField {
+				t.Errorf("expected field %q, got %q", tc.wantField, errResp.Field)
+			}
+		})
+	}
+}
+
 func TestIngestEvent_ValidationError_InvalidUUID(t *testing.T) {
 	mockProducer := &MockProducer{}
 	mockRepo := &MockRepository{}
@@ -182,9 +416,9 @@ func TestIngestEvent_ValidationError_InvalidUUID(t *testing.T) {
 	rr := httptest.NewRecorder()
 	handler.IngestEvent(rr, req)
 
-	// Assert 400 status
-	if rr.Code != http.StatusBadRequest {
-		t.Errorf("expected status %d, got %d", http.StatusBadRequest, rr.Code)
+	// Assert 422 status
+	if rr.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected status %d, got %d", http.StatusUnprocessableEntity, rr.Code)
 	}
 
 	// Verify error response mentions the field
@@ -219,9 +453,9 @@ func TestIngestEvent_ValidationError_InvalidEventType(t *testing.T) {
 	rr := httptest.NewRecorder()
 	handler.IngestEvent(rr, req)
 
-	// Assert 400 status
-	if rr.Code != http.StatusBadRequest {
-		t.Errorf("expected status %d, got %d", http.StatusBadRequest, rr.Code)
+	// Assert 422 status
+	if rr.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected status %d, got %d", http.StatusUnprocessableEntity, rr.Code)
 	}
 
 	// Verify error response mentions the field
@@ -255,9 +489,9 @@ func TestIngestEvent_ValidationError_InvalidTimestamp(t *testing.T) {
 	rr := httptest.NewRecorder()
 	handler.IngestEvent(rr, req)
 
-	// Assert 400 status
-	if rr.Code != http.StatusBadRequest {
-		t.Errorf("expected status %d, got %d", http.StatusBadRequest, rr.Code)
+	// Assert 422 status
+	if rr.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected status %d, got %d", http.StatusUnprocessableEntity, rr.Code)
 	}
 }
 
@@ -282,9 +516,9 @@ func TestIngestEvent_ValidationError_EmptyMatchID(t *testing.T) {
 	rr := httptest.NewRecorder()
 	handler.IngestEvent(rr, req)
 
-	// Assert 400 status
-	if rr.Code != http.StatusBadRequest {
-		t.Errorf("expected status %d, got %d", http.StatusBadRequest, rr.Code)
+	// Assert 422 status
+	if rr.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected status %d, got %d", http.StatusUnprocessableEntity, rr.Code)
 	}
 
 	var errResp api.ErrorResponse
@@ -328,8 +562,8 @@ func TestIngestEvent_ValidationError_InvalidTeamID(t *testing.T) {
 			rr := httptest.NewRecorder()
 			handler.IngestEvent(rr, req)
 
-			if rr.Code != http.StatusBadRequest {
-				t.Errorf("expected status %d for teamId=%d, got %d", http.StatusBadRequest, tc.teamID, rr.Code)
+			if rr.Code != http.StatusUnprocessableEntity {
+				t.Errorf("expected status %d for teamId=%d, got %d", http.StatusUnprocessableEntity, tc.teamID, rr.Code)
 			}
 		})
 	}
@@ -367,148 +601,688 @@ func TestIngestEvent_KafkaError(t *testing.T) {
 	}
 }
 
-func TestIngestEvent_EmptyBody(t *testing.T) {
-	mockProducer := &MockProducer{}
-	mockRepo := &MockRepository{}
-
-	handler := api.NewHandler(mockProducer, mockRepo)
+func TestIngestEvent_MessageTooLarge(t *testing.T) {
+	mockProducer := &MockProducer{
+		ProduceFunc: func(ctx context.Context, event *domain.Event) error {
+			return fmt.Errorf("event too big: %w", domain.ErrMessageTooLarge)
+		},
+	}
+	handler := api.NewHandler(mockProducer, &MockRepository{})
 
-	req := httptest.NewRequest(http.MethodPost, "/api/events", bytes.NewReader([]byte("")))
+	req := httptest.NewRequest(http.MethodPost, "/api/events", bytes.NewReader(validEventJSON()))
 	req.Header.Set("Content-Type", "application/json")
 
 	rr := httptest.NewRecorder()
 	handler.IngestEvent(rr, req)
 
-	// Assert 400 status for empty body
-	if rr.Code != http.StatusBadRequest {
-		t.Errorf("expected status %d, got %d", http.StatusBadRequest, rr.Code)
+	if rr.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected status %d, got %d", http.StatusRequestEntityTooLarge, rr.Code)
 	}
 }
 
-// ====================
-// GetMatchMetrics Tests
-// ====================
-
-func TestGetMatchMetrics_Success(t *testing.T) {
-	now := time.Now().UTC()
-	expectedMetrics := &domain.MatchMetrics{
-		MatchID:     "match-123",
-		TotalEvents: 100,
-		EventsByType: map[string]int64{
-			"goal":        2,
-			"shot":        15,
-			"pass":        70,
-			"yellow_card": 3,
+func TestIngestEvent_ProduceConcurrencyShed(t *testing.T) {
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	mockProducer := &MockProducer{
+		ProduceFunc: func(ctx context.Context, event *domain.Event) error {
+			started <- struct{}{}
+			<-release
+			return nil
 		},
-		Goals:        2,
-		YellowCards:  3,
-		RedCards:     0,
-		FirstEventAt: &now,
-		LastEventAt:  &now,
 	}
+	handler := api.NewHandlerWithConfig(mockProducer, &MockRepository{}, api.HandlerConfig{
+		ProduceLimiter: api.NewProduceSemaphore(1, 10*time.Millisecond),
+	})
 
-	mockProducer := &MockProducer{}
-	mockRepo := &MockRepository{
-		GetMatchMetricsFunc: func(ctx context.Context, matchID string) (*domain.MatchMetrics, error) {
-			if matchID == "match-123" {
-				return expectedMetrics, nil
-			}
-			return nil, nil
-		},
-		GetEventsPerMinuteFunc: func(ctx context.Context, matchID string) ([]domain.EventsPerMinute, error) {
-			return []domain.EventsPerMinute{
-				{Minute: now, EventType: "goal", EventCount: 1},
-				{Minute: now, EventType: "shot", EventCount: 5},
-			}, nil
-		},
+	ingest := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/events", bytes.NewReader(validEventJSON()))
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		handler.IngestEvent(rr, req)
+		return rr
 	}
 
-	handler := api.NewHandler(mockProducer, mockRepo)
-
-	req := httptest.NewRequest(http.MethodGet, "/api/matches/match-123/metrics", nil)
-	req = withChiURLParams(req, map[string]string{"matchId": "match-123"})
-
-	rr := httptest.NewRecorder()
-	handler.GetMatchMetrics(rr, req)
+	// Hold the only slot with a blocked produce call
+	done := make(chan *httptest.ResponseRecorder)
+	go func() { done <- ingest() }()
+	<-started
 
-	// Assert 200 status
-	if rr.Code != http.StatusOK {
-		t.Errorf("expected status %d, got %d", http.StatusOK, rr.Code)
+	shed := ingest()
+	if shed.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected status %d beyond the limit, got %d", http.StatusServiceUnavailable, shed.Code)
+	}
+	if shed.Header().Get("Retry-After") == "" {
+		t.Error("expected Retry-After header on shed request")
 	}
 
-	// Parse response
-	var metrics domain.MatchMetrics
-	if err := json.NewDecoder(rr.Body).Decode(&metrics); err != nil {
-		t.Fatalf("failed to decode response: %v", err)
+	close(release)
+	if first := <-done; first.Code != http.StatusAccepted {
+		t.Errorf("expected in-flight request to succeed with %d, got %d", http.StatusAccepted, first.Code)
 	}
 
-	// Assert JSON contains expected fields
-	if metrics.MatchID != "match-123" {
-		t.Errorf("expected matchId 'match-123', got '%s'", metrics.MatchID)
+	// The slot is released, so the next request goes through
+	started = make(chan struct{}, 1)
+	if rr := ingest(); rr.Code != http.StatusAccepted {
+		t.Errorf("expected status %d after release, got %d", http.StatusAccepted, rr.Code)
 	}
-	if metrics.TotalEvents != 100 {
-		t.Errorf("expected totalEvents 100, got %d", metrics.TotalEvents)
+}
+
+// gzipBytes compresses data for Content-Encoding: gzip tests.
+func gzipBytes(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		t.Fatalf("gzip write: %v", err)
 	}
-	if metrics.Goals != 2 {
-		t.Errorf("expected goals 2, got %d", metrics.Goals)
+	if err := zw.Close(); err != nil {
+		t.Fatalf("gzip close: %v", err)
 	}
+	return buf.Bytes()
 }
 
-func TestGetMatchMetrics_NotFound(t *testing.T) {
-	mockProducer := &MockProducer{}
-	mockRepo := &MockRepository{
-		GetMatchMetricsFunc: func(ctx context.Context, matchID string) (*domain.MatchMetrics, error) {
-			return nil, nil // Return nil for not found
+func TestIngestEvent_CompressedBody(t *testing.T) {
+	var produced *domain.Event
+	mockProducer := &MockProducer{
+		ProduceFunc: func(ctx context.Context, event *domain.Event) error {
+			produced = event
+			return nil
 		},
 	}
 
-	handler := api.NewHandler(mockProducer, mockRepo)
-
-	req := httptest.NewRequest(http.MethodGet, "/api/matches/nonexistent/metrics", nil)
-	req = withChiURLParams(req, map[string]string{"matchId": "nonexistent"})
+	tests := []struct {
+		name           string
+		body           []byte
+		maxBytes       int64
+		expectedStatus int
+	}{
+		{"valid gzip body", gzipBytes(t, validEventJSON()), 0, http.StatusAccepted},
+		{"malformed gzip stream", []byte("definitely not gzip"), 0, http.StatusBadRequest},
+		{"truncated gzip stream", gzipBytes(t, validEventJSON())[:20], 0, http.StatusBadRequest},
+		{"decompressed size over cap", gzipBytes(t, validEventJSON()), 16, http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			produced = nil
+			cfg := api.DefaultHandlerConfig()
+			if tt.maxBytes > 0 {
+				cfg.MaxDecompressedBytes = tt.maxBytes
+			}
+			router := api.NewRouterWithConfig(mockProducer, &MockRepository{}, slog.Default(), cfg)
 
-	rr := httptest.NewRecorder()
-	handler.GetMatchMetrics(rr, req)
+			req := httptest.NewRequest(http.MethodPost, "/api/events", bytes.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Content-Encoding", "gzip")
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
 
-	// Assert 404 status
-	if rr.Code != http.StatusNotFound {
-		t.Errorf("expected status %d, got %d", http.StatusNotFound, rr.Code)
+			if rr.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.expectedStatus, rr.Code, rr.Body.String())
+			}
+			if tt.expectedStatus == http.StatusAccepted && (produced == nil || produced.MatchID == "") {
+				t.Error("expected decompressed event to be produced")
+			}
+			if tt.expectedStatus != http.StatusAccepted && produced != nil {
+				t.Error("expected no event to be produced")
+			}
+		})
 	}
 }
 
-func TestGetMatchMetrics_EmptyMatchID(t *testing.T) {
+func TestIngestEvent_EmptyBody(t *testing.T) {
 	mockProducer := &MockProducer{}
 	mockRepo := &MockRepository{}
 
 	handler := api.NewHandler(mockProducer, mockRepo)
 
-	req := httptest.NewRequest(http.MethodGet, "/api/matches//metrics", nil)
-	req = withChiURLParams(req, map[string]string{"matchId": ""})
+	req := httptest.NewRequest(http.MethodPost, "/api/events", bytes.NewReader([]byte("")))
+	req.Header.Set("Content-Type", "application/json")
 
 	rr := httptest.NewRecorder()
-	handler.GetMatchMetrics(rr, req)
+	handler.IngestEvent(rr, req)
 
-	// Assert 400 status
+	// Assert 400 status for empty body
 	if rr.Code != http.StatusBadRequest {
 		t.Errorf("expected status %d, got %d", http.StatusBadRequest, rr.Code)
 	}
 }
 
-func TestGetMatchMetrics_RepositoryError(t *testing.T) {
-	mockProducer := &MockProducer{}
-	mockRepo := &MockRepository{
-		GetMatchMetricsFunc: func(ctx context.Context, matchID string) (*domain.MatchMetrics, error) {
-			return nil, errors.New("database error")
-		},
-	}
-
-	handler := api.NewHandler(mockProducer, mockRepo)
+func TestIngestEvent_EmptyBodyVariants(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+	}{
+		{name: "empty string", body: ""},
+		{name: "whitespace only", body: "  \n\t  "},
+		{name: "literal null", body: "null"},
+		{name: "padded null", body: " null\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			produced := false
+			mockProducer := &MockProducer{
+				ProduceFunc: func(ctx context.Context, event *domain.Event) error {
+					produced = true
+					return nil
+				},
+			}
+			handler := api.NewHandler(mockProducer, &MockRepository{})
 
-	req := httptest.NewRequest(http.MethodGet, "/api/matches/match-123/metrics", nil)
-	req = withChiURLParams(req, map[string]string{"matchId": "match-123"})
+			req := httptest.NewRequest(http.MethodPost, "/api/events", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
 
-	rr := httptest.NewRecorder()
-	handler.GetMatchMetrics(rr, req)
+			rr := httptest.NewRecorder()
+			handler.IngestEvent(rr, req)
+
+			if rr.Code != http.StatusBadRequest {
+				t.Fatalf("expected status %d, got %d", http.StatusBadRequest, rr.Code)
+			}
+			if produced {
+				t.Error("expected no event to be produced")
+			}
+
+			var errResp api.ErrorResponse
+			if err := json.NewDecoder(rr.Body).Decode(&errResp); err != nil {
+				t.Fatalf("failed to decode error response: %v", err)
+			}
+			if errResp.Code != api.ErrCodeEmptyBody {
+				t.Errorf("expected code %s, got %q", api.ErrCodeEmptyBody, errResp.Code)
+			}
+			if errResp.Message != "request body is required" {
+				t.Errorf("expected message 'request body is required', got %q", errResp.Message)
+			}
+		})
+	}
+}
+
+func TestIngestEvent_ProduceTimeout(t *testing.T) {
+	mockProducer := &MockProducer{
+		ProduceFunc: func(ctx context.Context, event *domain.Event) error {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(5 * time.Second):
+				return nil
+			}
+		},
+	}
+	cfg := api.DefaultHandlerConfig()
+	cfg.ProduceTimeout = 20 * time.Millisecond
+	handler := api.NewHandlerWithConfig(mockProducer, &MockRepository{}, cfg)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/events", bytes.NewReader(validEventJSON()))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+
+	start := time.Now()
+	handler.IngestEvent(rr, req)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("expected produce to be cut off by the deadline, took %s", elapsed)
+	}
+
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected status %d, got %d", http.StatusServiceUnavailable, rr.Code)
+	}
+	var errResp api.ErrorResponse
+	if err := json.NewDecoder(rr.Body).Decode(&errResp); err != nil {
+		t.Fatalf("failed to decode error response: %v", err)
+	}
+	if errResp.Code != api.ErrCodeProducerTimeout {
+		t.Errorf("expected code %s, got %q", api.ErrCodeProducerTimeout, errResp.Code)
+	}
+}
+
+func TestIngestEvent_ClientCancelIsNotProducerTimeout(t *testing.T) {
+	mockProducer := &MockProducer{
+		ProduceFunc: func(ctx context.Context, event *domain.Event) error {
+			<-ctx.Done()
+			return ctx.Err()
+		},
+	}
+	handler := api.NewHandler(mockProducer, &MockRepository{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req := httptest.NewRequest(http.MethodPost, "/api/events", bytes.NewReader(validEventJSON())).WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	handler.IngestEvent(rr, req)
+
+	var errResp api.ErrorResponse
+	if err := json.NewDecoder(rr.Body).Decode(&errResp); err != nil {
+		t.Fatalf("failed to decode error response: %v", err)
+	}
+	if errResp.Code == api.ErrCodeProducerTimeout {
+		t.Error("expected a cancelled client not to be reported as a producer timeout")
+	}
+}
+
+func TestIngestEvent_ContentType(t *testing.T) {
+	tests := []struct {
+		name           string
+		contentType    string
+		expectedStatus int
+	}{
+		{name: "json", contentType: "application/json", expectedStatus: http.StatusAccepted},
+		{name: "json with charset", contentType: "application/json; charset=utf-8", expectedStatus: http.StatusAccepted},
+		{name: "missing", contentType: "", expectedStatus: http.StatusUnsupportedMediaType},
+		{name: "plain text", contentType: "text/plain", expectedStatus: http.StatusUnsupportedMediaType},
+		{name: "form encoded", contentType: "application/x-www-form-urlencoded", expectedStatus: http.StatusUnsupportedMediaType},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			produced := false
+			mockProducer := &MockProducer{
+				ProduceFunc: func(ctx context.Context, event *domain.Event) error {
+					produced = true
+					return nil
+				},
+			}
+			handler := api.NewHandler(mockProducer, &MockRepository{})
+
+			req := httptest.NewRequest(http.MethodPost, "/api/events", bytes.NewReader(validEventJSON()))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+
+			rr := httptest.NewRecorder()
+			handler.IngestEvent(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.expectedStatus, rr.Code, rr.Body.String())
+			}
+			if tt.expectedStatus == http.StatusAccepted {
+				return
+			}
+			if produced {
+				t.Error("expected no event to be produced")
+			}
+
+			var errResp api.ErrorResponse
+			if err := json.NewDecoder(rr.Body).Decode(&errResp); err != nil {
+				t.Fatalf("failed to decode error response: %v", err)
+			}
+			if errResp.Code != api.ErrCodeUnsupportedMediaType {
+				t.Errorf("expected code %s, got %q", api.ErrCodeUnsupportedMediaType, errResp.Code)
+			}
+		})
+	}
+}
+
+// ====================
+// UpsertEvent Tests
+// ====================
+
+func TestUpsertEvent(t *testing.T) {
+	eventID := uuid.New().String()
+
+	tests := []struct {
+		name       string
+		pathID     string
+		bodyID     string
+		wantStatus int
+		wantField  string
+	}{
+		{name: "matching ids", pathID: eventID, bodyID: eventID, wantStatus: http.StatusAccepted},
+		{name: "mismatched ids", pathID: eventID, bodyID: uuid.New().String(), wantStatus: http.StatusUnprocessableEntity, wantField: "eventId"},
+		{name: "invalid path id", pathID: "not-a-uuid", bodyID: eventID, wantStatus: http.StatusBadRequest, wantField: "eventId"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var produced *domain.Event
+			mockProducer := &MockProducer{
+				ProduceFunc: func(ctx context.Context, event *domain.Event) error {
+					produced = event
+					return nil
+				},
+			}
+			handler := api.NewHandler(mockProducer, &MockRepository{})
+
+			body, _ := json.Marshal(map[string]interface{}{
+				"eventId":   tt.bodyID,
+				"matchId":   "match-123",
+				"eventType": "goal",
+				"timestamp": time.Now().UTC().Format(time.RFC3339),
+				"teamId":    1,
+			})
+			req := httptest.NewRequest(http.MethodPut, "/api/events/"+tt.pathID, bytes.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			req = withChiURLParams(req, map[string]string{"eventId": tt.pathID})
+
+			rr := httptest.NewRecorder()
+			handler.UpsertEvent(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.wantStatus, rr.Code, rr.Body.String())
+			}
+
+			if tt.wantStatus != http.StatusAccepted {
+				if produced != nil {
+					t.Error("expected no event to be produced")
+				}
+				var errResp api.ErrorResponse
+				if err := json.NewDecoder(rr.Body).Decode(&errResp); err != nil {
+					t.Fatalf("failed to decode error response: %v", err)
+				}
+				if errResp.Field != tt.wantField {
+					t.Errorf("expected field %q, got %q", tt.wantField, errResp.Field)
+				}
+				return
+			}
+
+			if produced == nil {
+				t.Fatal("expected event to be produced")
+			}
+			if produced.EventID.String() != eventID {
+				t.Errorf("expected event ID %s, got %s", eventID, produced.EventID)
+			}
+			if !produced.IsUpsert() {
+				t.Errorf("expected upsert op, got %q", produced.Op)
+			}
+		})
+	}
+}
+
+// ====================
+// GetMatchMetrics Tests
+// ====================
+
+func TestGetMatchMetrics_Success(t *testing.T) {
+	now := time.Now().UTC()
+	expectedMetrics := &domain.MatchMetrics{
+		MatchID:     "match-123",
+		TotalEvents: 100,
+		EventsByType: map[string]int64{
+			"goal":        2,
+			"shot":        15,
+			"pass":        70,
+			"yellow_card": 3,
+		},
+		Goals:        2,
+		YellowCards:  3,
+		RedCards:     0,
+		FirstEventAt: &now,
+		LastEventAt:  &now,
+	}
+
+	mockProducer := &MockProducer{}
+	mockRepo := &MockRepository{
+		GetMatchMetricsFunc: func(ctx context.Context, matchID string) (*domain.MatchMetrics, error) {
+			if matchID == "match-123" {
+				return expectedMetrics, nil
+			}
+			return nil, nil
+		},
+		GetEventsPerMinuteFunc: func(ctx context.Context, matchID string) ([]domain.EventsPerMinute, error) {
+			return []domain.EventsPerMinute{
+				{Minute: now, EventType: "goal", EventCount: 1},
+				{Minute: now, EventType: "shot", EventCount: 5},
+			}, nil
+		},
+	}
+
+	handler := api.NewHandler(mockProducer, mockRepo)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/matches/match-123/metrics", nil)
+	req = withChiURLParams(req, map[string]string{"matchId": "match-123"})
+
+	rr := httptest.NewRecorder()
+	handler.GetMatchMetrics(rr, req)
+
+	// Assert 200 status
+	if rr.Code != http.StatusOK {
+		t.Errorf("expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+
+	// Parse response
+	var metrics domain.MatchMetrics
+	if err := json.NewDecoder(rr.Body).Decode(&metrics); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+
+	// Assert JSON contains expected fields
+	if metrics.MatchID != "match-123" {
+		t.Errorf("expected matchId 'match-123', got '%s'", metrics.MatchID)
+	}
+	if metrics.TotalEvents != 100 {
+		t.Errorf("expected totalEvents 100, got %d", metrics.TotalEvents)
+	}
+	if metrics.Goals != 2 {
+		t.Errorf("expected goals 2, got %d", metrics.Goals)
+	}
+}
+
+func TestGetMatchMetrics_DistinctPlayers(t *testing.T) {
+	mockProducer := &MockProducer{}
+	mockRepo := &MockRepository{
+		GetMatchMetricsFunc: func(ctx context.Context, matchID string) (*domain.MatchMetrics, error) {
+			return &domain.MatchMetrics{
+				MatchID:         "match-123",
+				TotalEvents:     40,
+				EventsByType:    map[string]int64{"pass": 40},
+				DistinctPlayers: 22,
+			}, nil
+		},
+	}
+
+	handler := api.NewHandler(mockProducer, mockRepo)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/matches/match-123/metrics", nil)
+	req = withChiURLParams(req, map[string]string{"matchId": "match-123"})
+
+	rr := httptest.NewRecorder()
+	handler.GetMatchMetrics(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+
+	var body map[string]interface{}
+	if err := json.NewDecoder(rr.Body).Decode(&body); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if body["distinctPlayers"] != float64(22) {
+		t.Errorf("expected distinctPlayers 22 in JSON, got %v", body["distinctPlayers"])
+	}
+}
+
+func TestGetMatchMetrics_QueryTimeoutHeader(t *testing.T) {
+	tests := []struct {
+		name           string
+		header         string
+		expectedStatus int
+		expectedWithin time.Duration
+	}{
+		{name: "default timeout", header: "", expectedStatus: http.StatusOK, expectedWithin: 10 * time.Second},
+		{name: "valid duration override", header: "25s", expectedStatus: http.StatusOK, expectedWithin: 25 * time.Second},
+		{name: "valid seconds override", header: "20", expectedStatus: http.StatusOK, expectedWithin: 20 * time.Second},
+		{name: "over cap", header: "5m", expectedStatus: http.StatusBadRequest},
+		{name: "unparseable", header: "soon", expectedStatus: http.StatusBadRequest},
+		{name: "non-positive", header: "0s", expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var remaining time.Duration
+			called := false
+			mockRepo := &MockRepository{
+				GetMatchMetricsFunc: func(ctx context.Context, matchID string) (*domain.MatchMetrics, error) {
+					called = true
+					if deadline, ok := ctx.Deadline(); ok {
+						remaining = time.Until(deadline)
+					}
+					return &domain.MatchMetrics{MatchID: matchID, TotalEvents: 1}, nil
+				},
+			}
+
+			handler := api.NewHandler(&MockProducer{}, mockRepo)
+
+			req := httptest.NewRequest(http.MethodGet, "/api/matches/match-123/metrics", nil)
+			if tt.header != "" {
+				req.Header.Set(api.QueryTimeoutHeader, tt.header)
+			}
+			req = withChiURLParams(req, map[string]string{"matchId": "match-123"})
+
+			rr := httptest.NewRecorder()
+			handler.GetMatchMetrics(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.expectedStatus, rr.Code, rr.Body.String())
+			}
+			if tt.expectedStatus != http.StatusOK {
+				if called {
+					t.Error("expected repository not to be called")
+				}
+				var errResp api.ErrorResponse
+				if err := json.NewDecoder(rr.Body).Decode(&errResp); err != nil {
+					t.Fatalf("failed to decode response: %v", err)
+				}
+				if errResp.Field != api.QueryTimeoutHeader {
+					t.Errorf("expected field %s, got %s", api.QueryTimeoutHeader, errResp.Field)
+				}
+				return
+			}
+			if remaining <= tt.expectedWithin-time.Second || remaining > tt.expectedWithin {
+				t.Errorf("expected deadline about %v away, got %v", tt.expectedWithin, remaining)
+			}
+		})
+	}
+}
+
+func TestGetMatchMetrics_QueryTimeoutOutlastsWriteTimeout(t *testing.T) {
+	mockRepo := &MockRepository{
+		GetMatchMetricsFunc: func(ctx context.Context, matchID string) (*domain.MatchMetrics, error) {
+			time.Sleep(300 * time.Millisecond)
+			return &domain.MatchMetrics{MatchID: matchID, TotalEvents: 1}, nil
+		},
+	}
+	ts := httptest.NewUnstartedServer(api.NewRouter(&MockProducer{}, mockRepo, slog.Default()))
+	ts.Config.WriteTimeout = 100 * time.Millisecond
+	ts.Start()
+	defer ts.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+"/api/matches/match-123/metrics", nil)
+	req.Header.Set(api.QueryTimeoutHeader, "2s")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("expected the raised query timeout to lift the write deadline, got %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected status %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+}
+
+func TestGetMatchMetrics_NotFound(t *testing.T) {
+	mockProducer := &MockProducer{}
+	mockRepo := &MockRepository{
+		GetMatchMetricsFunc: func(ctx context.Context, matchID string) (*domain.MatchMetrics, error) {
+			return nil, fmt.Errorf("match %s: %w", matchID, domain.ErrMatchNotFound)
+		},
+	}
+
+	handler := api.NewHandler(mockProducer, mockRepo)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/matches/nonexistent/metrics", nil)
+	req = withChiURLParams(req, map[string]string{"matchId": "nonexistent"})
+
+	rr := httptest.NewRecorder()
+	handler.GetMatchMetrics(rr, req)
+
+	// Assert 404 status
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("expected status %d, got %d", http.StatusNotFound, rr.Code)
+	}
+}
+
+func TestGetMatchMetrics_EmptyMatchID(t *testing.T) {
+	mockProducer := &MockProducer{}
+	mockRepo := &MockRepository{}
+
+	handler := api.NewHandler(mockProducer, mockRepo)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/matches//metrics", nil)
+	req = withChiURLParams(req, map[string]string{"matchId": ""})
+
+	rr := httptest.NewRecorder()
+	handler.GetMatchMetrics(rr, req)
+
+	// Assert 400 status
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d, got %d", http.StatusBadRequest, rr.Code)
+	}
+}
+
+// TestGetMatchMetrics_EncodedSlashMatchID tests that a matchId containing an
+// encoded "/" resolves through the router, and that an unencoded one gets an
+// error explaining how to send it.
+func TestGetMatchMetrics_EncodedSlashMatchID(t *testing.T) {
+	tests := []struct {
+		name           string
+		path           string
+		expectedStatus int
+		expectedID     string
+	}{
+		{name: "encoded slash", path: "/api/matches/group%2Fa/metrics", expectedStatus: http.StatusOK, expectedID: "group/a"},
+		{name: "encoded percent", path: "/api/matches/100%25/metrics", expectedStatus: http.StatusOK, expectedID: "100%"},
+		{name: "encoded slash and percent", path: "/api/matches/a%2F100%25/metrics", expectedStatus: http.StatusOK, expectedID: "a/100%"},
+		{name: "empty segment", path: "/api/matches//metrics", expectedStatus: http.StatusBadRequest},
+		{name: "unencoded slash", path: "/api/matches/group/a/metrics", expectedStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotID string
+			mockRepo := &MockRepository{
+				GetMatchMetricsFunc: func(ctx context.Context, matchID string) (*domain.MatchMetrics, error) {
+					gotID = matchID
+					return &domain.MatchMetrics{MatchID: matchID, TotalEvents: 1}, nil
+				},
+			}
+			router := api.NewRouter(&MockProducer{}, mockRepo, slog.Default())
+
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			if rr.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.expectedStatus, rr.Code, rr.Body.String())
+			}
+			if gotID != tt.expectedID {
+				t.Errorf("expected repository to get matchId %q, got %q", tt.expectedID, gotID)
+			}
+			if tt.expectedStatus != http.StatusBadRequest {
+				return
+			}
+			var errResp api.ErrorResponse
+			if err := json.NewDecoder(rr.Body).Decode(&errResp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if errResp.Field != "matchId" || !strings.Contains(errResp.Message, "%2F") {
+				t.Errorf("expected matchId error mentioning %%2F, got %+v", errResp)
+			}
+		})
+	}
+}
+
+func TestGetMatchMetrics_RepositoryError(t *testing.T) {
+	mockProducer := &MockProducer{}
+	mockRepo := &MockRepository{
+		GetMatchMetricsFunc: func(ctx context.Context, matchID string) (*domain.MatchMetrics, error) {
+			return nil, errors.New("database error")
+		},
+	}
+
+	handler := api.NewHandler(mockProducer, mockRepo)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/matches/match-123/metrics", nil)
+	req = withChiURLParams(req, map[string]string{"matchId": "match-123"})
+
+	rr := httptest.NewRecorder()
+	handler.GetMatchMetrics(rr, req)
 
 	// Assert 500 status
 	if rr.Code != http.StatusInternalServerError {
@@ -516,19 +1290,385 @@ func TestGetMatchMetrics_RepositoryError(t *testing.T) {
 	}
 }
 
-func TestGetMatchMetrics_ZeroTotalEvents(t *testing.T) {
-	mockProducer := &MockProducer{}
+func TestGetMatchMetrics_RepositoryErrorCategories(t *testing.T) {
+	tests := []struct {
+		category   domain.RepositoryErrorCategory
+		wantStatus int
+	}{
+		{domain.RepositoryErrorConnection, http.StatusServiceUnavailable},
+		{domain.RepositoryErrorTimeout, http.StatusGatewayTimeout},
+		{domain.RepositoryErrorQuery, http.StatusInternalServerError},
+		{domain.RepositoryErrorScan, http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.category.String(), func(t *testing.T) {
+			mockRepo := &MockRepository{
+				GetMatchMetricsFunc: func(ctx context.Context, matchID string) (*domain.MatchMetrics, error) {
+					return nil, fmt.Errorf("metrics: %w", domain.NewRepositoryError(tt.category, "failed to query match metrics", errors.New("boom")))
+				},
+			}
+			handler := api.NewHandler(&MockProducer{}, mockRepo)
+
+			req := httptest.NewRequest(http.MethodGet, "/api/matches/match-123/metrics", nil)
+			req = withChiURLParams(req, map[string]string{"matchId": "match-123"})
+			rr := httptest.NewRecorder()
+			handler.GetMatchMetrics(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Errorf("expected status %d, got %d", tt.wantStatus, rr.Code)
+			}
+			if tt.wantStatus == http.StatusServiceUnavailable && rr.Header().Get("Retry-After") == "" {
+				t.Error("expected Retry-After header when storage is unavailable")
+			}
+		})
+	}
+}
+
+func TestGetMatchMetrics_QueryDeadlineReturns504(t *testing.T) {
+	mockRepo := &MockRepository{
+		GetMatchMetricsFunc: func(ctx context.Context, matchID string) (*domain.MatchMetrics, error) {
+			// Simulate a slow ClickHouse query that outlives the deadline
+			<-ctx.Done()
+			return nil, fmt.Errorf("query: %w", ctx.Err())
+		},
+	}
+	handler := api.NewHandlerWithConfig(&MockProducer{}, mockRepo, api.HandlerConfig{
+		QueryTimeout: 20 * time.Millisecond,
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/matches/match-123/metrics", nil)
+	req = withChiURLParams(req, map[string]string{"matchId": "match-123"})
+	rr := httptest.NewRecorder()
+	handler.GetMatchMetrics(rr, req)
+
+	if rr.Code != http.StatusGatewayTimeout {
+		t.Fatalf("expected status %d, got %d", http.StatusGatewayTimeout, rr.Code)
+	}
+	var errResp api.ErrorResponse
+	if err := json.NewDecoder(rr.Body).Decode(&errResp); err != nil {
+		t.Fatalf("expected clean JSON error body: %v", err)
+	}
+	if errResp.Error != "Gateway Timeout" {
+		t.Errorf("expected Gateway Timeout error, got %q", errResp.Error)
+	}
+}
+
+func TestGetMatchMetrics_ZeroTotalEvents(t *testing.T) {
+	mockProducer := &MockProducer{}
+	mockRepo := &MockRepository{
+		GetMatchMetricsFunc: func(ctx context.Context, matchID string) (*domain.MatchMetrics, error) {
+			return &domain.MatchMetrics{
+				MatchID:      "match-123",
+				TotalEvents:  0,
+				EventsByType: make(map[string]int64),
+			}, nil
+		},
+	}
+
+	handler := api.NewHandler(mockProducer, mockRepo)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/matches/match-123/metrics", nil)
+	req = withChiURLParams(req, map[string]string{"matchId": "match-123"})
+
+	rr := httptest.NewRecorder()
+	handler.GetMatchMetrics(rr, req)
+
+	// Only ErrMatchNotFound maps to 404; an empty result is still 200
+	if rr.Code != http.StatusOK {
+		t.Errorf("expected status %d for zero events, got %d", http.StatusOK, rr.Code)
+	}
+}
+
+func TestGetMatchMetrics_NilMetricsWithoutError(t *testing.T) {
+	mockRepo := &MockRepository{
+		GetMatchMetricsFunc: func(ctx context.Context, matchID string) (*domain.MatchMetrics, error) {
+			return nil, nil
+		},
+	}
+
+	handler := api.NewHandler(&MockProducer{}, mockRepo)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/matches/match-123/metrics", nil)
+	req = withChiURLParams(req, map[string]string{"matchId": "match-123"})
+
+	rr := httptest.NewRecorder()
+	handler.GetMatchMetrics(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+
+	var metrics domain.MatchMetrics
+	if err := json.NewDecoder(rr.Body).Decode(&metrics); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if metrics.MatchID != "match-123" || metrics.TotalEvents != 0 {
+		t.Errorf("expected empty metrics for match-123, got %+v", metrics)
+	}
+}
+
+func TestGetMatchMetrics_WithPeakEngagement(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Minute)
+
+	mockProducer := &MockProducer{}
+	mockRepo := &MockRepository{
+		GetMatchMetricsFunc: func(ctx context.Context, matchID string) (*domain.MatchMetrics, error) {
+			return &domain.MatchMetrics{
+				MatchID:      "match-123",
+				TotalEvents:  50,
+				EventsByType: map[string]int64{"goal": 2, "shot": 10},
+				Goals:        2,
+			}, nil
+		},
+		GetEventsPerMinuteFunc: func(ctx context.Context, matchID string) ([]domain.EventsPerMinute, error) {
+			// Return events per minute data
+			return []domain.EventsPerMinute{
+				{Minute: now, EventType: "goal", EventCount: 2},
+				{Minute: now, EventType: "shot", EventCount: 8},
+				{Minute: now.Add(time.Minute), EventType: "shot", EventCount: 3},
+			}, nil
+		},
+	}
+
+	handler := api.NewHandler(mockProducer, mockRepo)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/matches/match-123/metrics", nil)
+	req = withChiURLParams(req, map[string]string{"matchId": "match-123"})
+
+	rr := httptest.NewRecorder()
+	handler.GetMatchMetrics(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+
+	var metrics domain.MatchMetrics
+	if err := json.NewDecoder(rr.Body).Decode(&metrics); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+
+	// Verify peak engagement is calculated
+	if metrics.PeakMinute == nil {
+		t.Error("expected peakMinute to be set")
+	} else {
+		// First minute has 10 events (2 goal + 8 shot), second has 3
+		if metrics.PeakMinute.EventCount != 10 {
+			t.Errorf("expected peak event count 10, got %d", metrics.PeakMinute.EventCount)
+		}
+	}
+}
+
+func TestGetMatchMetrics_IncludePeakFalse(t *testing.T) {
+	var gotOpts domain.MetricsOptions
+	perMinuteCalled := false
+	mockRepo := &MockRepository{
+		GetMatchMetricsOptionsFunc: func(ctx context.Context, matchID string, opts domain.MetricsOptions) (*domain.MatchMetrics, error) {
+			gotOpts = opts
+			return &domain.MatchMetrics{
+				MatchID:      matchID,
+				TotalEvents:  50,
+				EventsByType: map[string]int64{"goal": 2},
+			}, nil
+		},
+		GetEventsPerMinuteFunc: func(ctx context.Context, matchID string) ([]domain.EventsPerMinute, error) {
+			perMinuteCalled = true
+			return []domain.EventsPerMinute{{Minute: time.Now(), EventType: "goal", EventCount: 2}}, nil
+		},
+	}
+
+	handler := api.NewHandler(&MockProducer{}, mockRepo)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/matches/match-123/metrics?includePeak=false", nil)
+	req = withChiURLParams(req, map[string]string{"matchId": "match-123"})
+
+	rr := httptest.NewRecorder()
+	handler.GetMatchMetrics(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+	if !gotOpts.SkipPeak {
+		t.Error("expected repository to be asked to skip the peak")
+	}
+	if perMinuteCalled {
+		t.Error("expected events per minute not to be queried")
+	}
+	if strings.Contains(rr.Body.String(), "peakMinute") {
+		t.Errorf("expected peakMinute to be omitted, got %s", rr.Body.String())
+	}
+}
+
+func TestGetMatchMetrics_InvalidIncludePeak(t *testing.T) {
+	handler := api.NewHandler(&MockProducer{}, &MockRepository{})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/matches/match-123/metrics?includePeak=maybe", nil)
+	req = withChiURLParams(req, map[string]string{"matchId": "match-123"})
+
+	rr := httptest.NewRecorder()
+	handler.GetMatchMetrics(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d, got %d", http.StatusBadRequest, rr.Code)
+	}
+}
+
+func TestGetMatchMetrics_CacheFreshHit(t *testing.T) {
+	calls := 0
 	mockRepo := &MockRepository{
 		GetMatchMetricsFunc: func(ctx context.Context, matchID string) (*domain.MatchMetrics, error) {
+			calls++
 			return &domain.MatchMetrics{
-				MatchID:      "match-123",
-				TotalEvents:  0,
+				MatchID:      matchID,
+				TotalEvents:  int64(calls),
 				EventsByType: make(map[string]int64),
 			}, nil
 		},
 	}
 
-	handler := api.NewHandler(mockProducer, mockRepo)
+	handler := api.NewHandlerWithConfig(&MockProducer{}, mockRepo, api.HandlerConfig{
+		MetricsCacheTTL: time.Minute,
+	})
+
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest(http.MethodGet, "/api/matches/match-123/metrics", nil)
+		req = withChiURLParams(req, map[string]string{"matchId": "match-123"})
+
+		rr := httptest.NewRecorder()
+		handler.GetMatchMetrics(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("request %d: expected status %d, got %d", i, http.StatusOK, rr.Code)
+		}
+		if rr.Header().Get(api.DataStaleHeader) != "" {
+			t.Errorf("request %d: fresh response should not set %s", i, api.DataStaleHeader)
+		}
+
+		var metrics domain.MatchMetrics
+		if err := json.NewDecoder(rr.Body).Decode(&metrics); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		if metrics.TotalEvents != 1 {
+			t.Errorf("request %d: expected cached totalEvents 1, got %d", i, metrics.TotalEvents)
+		}
+	}
+
+	if calls != 1 {
+		t.Errorf("expected 1 repository call, got %d", calls)
+	}
+}
+
+func TestGetMatchMetrics_CacheExpiresByClock(t *testing.T) {
+	calls := 0
+	mockRepo := &MockRepository{
+		GetMatchMetricsFunc: func(ctx context.Context, matchID string) (*domain.MatchMetrics, error) {
+			calls++
+			return &domain.MatchMetrics{
+				MatchID:      matchID,
+				TotalEvents:  int64(calls),
+				EventsByType: make(map[string]int64),
+			}, nil
+		},
+	}
+
+	clock := domain.NewFixedClock(time.Date(2024, 1, 15, 14, 0, 0, 0, time.UTC))
+	handler := api.NewHandlerWithConfig(&MockProducer{}, mockRepo, api.HandlerConfig{
+		MetricsCacheTTL: time.Minute,
+		Clock:           clock,
+	})
+
+	request := func() {
+		req := httptest.NewRequest(http.MethodGet, "/api/matches/match-123/metrics", nil)
+		req = withChiURLParams(req, map[string]string{"matchId": "match-123"})
+		rr := httptest.NewRecorder()
+		handler.GetMatchMetrics(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, rr.Code)
+		}
+	}
+
+	request()
+	clock.Advance(59 * time.Second)
+	request()
+	if calls != 1 {
+		t.Fatalf("expected the entry to be fresh before the TTL, got %d repository calls", calls)
+	}
+
+	clock.Advance(time.Second)
+	request()
+	if calls != 2 {
+		t.Errorf("expected the entry to expire at the TTL, got %d repository calls", calls)
+	}
+}
+
+func TestGetMatchMetrics_ServeStaleOnError(t *testing.T) {
+	failing := false
+	mockRepo := &MockRepository{
+		GetMatchMetricsFunc: func(ctx context.Context, matchID string) (*domain.MatchMetrics, error) {
+			if failing {
+				return nil, errors.New("clickhouse unavailable")
+			}
+			return &domain.MatchMetrics{
+				MatchID:      matchID,
+				TotalEvents:  42,
+				EventsByType: map[string]int64{"pass": 42},
+			}, nil
+		},
+	}
+
+	// No TTL: every request queries the repository, but the last result is kept for fallback
+	cachedAt := time.Date(2024, 1, 15, 14, 0, 0, 0, time.UTC)
+	clock := domain.NewFixedClock(cachedAt)
+	handler := api.NewHandlerWithConfig(&MockProducer{}, mockRepo, api.HandlerConfig{
+		ServeStaleOnError: true,
+		Clock:             clock,
+	})
+
+	request := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/matches/match-123/metrics", nil)
+		req = withChiURLParams(req, map[string]string{"matchId": "match-123"})
+		rr := httptest.NewRecorder()
+		handler.GetMatchMetrics(rr, req)
+		return rr
+	}
+
+	if rr := request(); rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+
+	failing = true
+	clock.Advance(10 * time.Minute)
+	rr := request()
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected stale status %d, got %d", http.StatusOK, rr.Code)
+	}
+	if got := rr.Header().Get(api.DataStaleHeader); got != "true" {
+		t.Errorf("expected %s: true, got %q", api.DataStaleHeader, got)
+	}
+	if got := rr.Header().Get(api.DataCachedAtHeader); got != cachedAt.Format(time.RFC3339) {
+		t.Errorf("expected %s %s, got %q", api.DataCachedAtHeader, cachedAt.Format(time.RFC3339), got)
+	}
+
+	var metrics domain.MatchMetrics
+	if err := json.NewDecoder(rr.Body).Decode(&metrics); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if metrics.TotalEvents != 42 {
+		t.Errorf("expected stale totalEvents 42, got %d", metrics.TotalEvents)
+	}
+}
+
+func TestGetMatchMetrics_StaleOnErrorWithoutCache(t *testing.T) {
+	mockRepo := &MockRepository{
+		GetMatchMetricsFunc: func(ctx context.Context, matchID string) (*domain.MatchMetrics, error) {
+			return nil, errors.New("clickhouse unavailable")
+		},
+	}
+
+	handler := api.NewHandlerWithConfig(&MockProducer{}, mockRepo, api.HandlerConfig{
+		ServeStaleOnError: true,
+	})
 
 	req := httptest.NewRequest(http.MethodGet, "/api/matches/match-123/metrics", nil)
 	req = withChiURLParams(req, map[string]string{"matchId": "match-123"})
@@ -536,60 +1676,437 @@ func TestGetMatchMetrics_ZeroTotalEvents(t *testing.T) {
 	rr := httptest.NewRecorder()
 	handler.GetMatchMetrics(rr, req)
 
-	// Should return 404 when total events is 0
-	if rr.Code != http.StatusNotFound {
-		t.Errorf("expected status %d for zero events, got %d", http.StatusNotFound, rr.Code)
+	if rr.Code != http.StatusInternalServerError {
+		t.Errorf("expected status %d, got %d", http.StatusInternalServerError, rr.Code)
+	}
+	if rr.Header().Get(api.DataStaleHeader) != "" {
+		t.Errorf("error response should not set %s", api.DataStaleHeader)
 	}
 }
 
-func TestGetMatchMetrics_WithPeakEngagement(t *testing.T) {
+func TestGetMatchMetrics_ErrorLogCarriesRequestID(t *testing.T) {
+	mockRepo := &MockRepository{
+		GetMatchMetricsFunc: func(ctx context.Context, matchID string) (*domain.MatchMetrics, error) {
+			return nil, errors.New("database error")
+		},
+	}
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	router := api.NewRouter(&MockProducer{}, mockRepo, logger)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/matches/match-123/metrics", nil)
+	req.Header.Set("X-Request-Id", "req-abc")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusInternalServerError {
+		t.Fatalf("expected status %d, got %d", http.StatusInternalServerError, rr.Code)
+	}
+
+	found := false
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var entry map[string]any
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("invalid log line %q: %v", line, err)
+		}
+		if entry["msg"] != "failed to fetch match metrics" {
+			continue
+		}
+		found = true
+		if entry["request_id"] != "req-abc" {
+			t.Errorf("expected request_id req-abc, got %v", entry["request_id"])
+		}
+		if entry["match_id"] != "match-123" {
+			t.Errorf("expected match_id match-123, got %v", entry["match_id"])
+		}
+	}
+	if !found {
+		t.Errorf("expected handler error log line, got %s", buf.String())
+	}
+}
+
+func TestGetMatchMetrics_ETag(t *testing.T) {
+	lastEventAt := time.Date(2024, 1, 15, 15, 45, 0, 0, time.UTC)
+	totalEvents := int64(100)
+	mockRepo := &MockRepository{
+		GetMatchMetricsFunc: func(ctx context.Context, matchID string) (*domain.MatchMetrics, error) {
+			return &domain.MatchMetrics{
+				MatchID:      matchID,
+				TotalEvents:  totalEvents,
+				EventsByType: map[string]int64{"pass": totalEvents},
+				LastEventAt:  &lastEventAt,
+			}, nil
+		},
+	}
+	handler := api.NewHandler(&MockProducer{}, mockRepo)
+
+	request := func(ifNoneMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/matches/match-123/metrics", nil)
+		req = withChiURLParams(req, map[string]string{"matchId": "match-123"})
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		rr := httptest.NewRecorder()
+		handler.GetMatchMetrics(rr, req)
+		return rr
+	}
+
+	first := request("")
+	if first.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, first.Code)
+	}
+	etag := first.Header().Get("ETag")
+	if !strings.HasPrefix(etag, `W/"`) {
+		t.Fatalf("expected weak ETag, got %q", etag)
+	}
+	if first.Header().Get("Cache-Control") == "" {
+		t.Error("expected Cache-Control header")
+	}
+
+	notModified := request(etag)
+	if notModified.Code != http.StatusNotModified {
+		t.Fatalf("expected status %d, got %d", http.StatusNotModified, notModified.Code)
+	}
+	if notModified.Body.Len() != 0 {
+		t.Errorf("expected empty 304 body, got %q", notModified.Body.String())
+	}
+	if notModified.Header().Get("ETag") != etag {
+		t.Errorf("expected 304 to repeat ETag %q, got %q", etag, notModified.Header().Get("ETag"))
+	}
+
+	// New events change the tag, so the stale one no longer matches
+	totalEvents = 101
+	changed := request(etag)
+	if changed.Code != http.StatusOK {
+		t.Fatalf("expected status %d after new events, got %d", http.StatusOK, changed.Code)
+	}
+	if changed.Header().Get("ETag") == etag {
+		t.Error("expected ETag to change after new events")
+	}
+}
+
+func TestGetMatchMetrics_FieldNaming(t *testing.T) {
+	mockRepo := &MockRepository{
+		GetMatchMetricsFunc: func(ctx context.Context, matchID string) (*domain.MatchMetrics, error) {
+			return &domain.MatchMetrics{
+				MatchID:      matchID,
+				TotalEvents:  3,
+				EventsByType: map[string]int64{"yellow_card": 3},
+				YellowCards:  3,
+			}, nil
+		},
+	}
+	handler := api.NewHandler(&MockProducer{}, mockRepo)
+
+	request := func(query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/matches/match-123/metrics"+query, nil)
+		req = withChiURLParams(req, map[string]string{"matchId": "match-123"})
+		rr := httptest.NewRecorder()
+		handler.GetMatchMetrics(rr, req)
+		return rr
+	}
+
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+		wantKeys   []string
+		absentKeys []string
+	}{
+		{"default camelCase", "", http.StatusOK, []string{"matchId", "totalEvents", "yellowCards"}, []string{"total_events"}},
+		{"explicit camelCase", "?naming=camelCase", http.StatusOK, []string{"totalEvents"}, []string{"total_events"}},
+		{"snake_case", "?naming=snake_case", http.StatusOK, []string{"match_id", "total_events", "yellow_cards"}, []string{"totalEvents"}},
+		{"unknown naming", "?naming=kebab", http.StatusBadRequest, nil, nil},
+	}
+
+	etags := make(map[string]string)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := request(tt.query)
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d", tt.wantStatus, rr.Code)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			var body map[string]interface{}
+			if err := json.NewDecoder(rr.Body).Decode(&body); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			for _, key := range tt.wantKeys {
+				if _, ok := body[key]; !ok {
+					t.Errorf("expected key %q in response, got %v", key, body)
+				}
+			}
+			for _, key := range tt.absentKeys {
+				if _, ok := body[key]; ok {
+					t.Errorf("expected no key %q in response", key)
+				}
+			}
+			etags[tt.name] = rr.Header().Get("ETag")
+		})
+	}
+
+	if etags["default camelCase"] == etags["snake_case"] {
+		t.Error("expected camelCase and snake_case responses to have different ETags")
+	}
+}
+
+func TestRegisterMatchInfo(t *testing.T) {
+	var stored *domain.MatchInfo
+	mockRepo := &MockRepository{
+		UpsertMatchInfoFunc: func(ctx context.Context, info *domain.MatchInfo) error {
+			stored = info
+			return nil
+		},
+	}
+	handler := api.NewHandler(&MockProducer{}, mockRepo)
+
+	request := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/matches/match-123", strings.NewReader(body))
+		req = withChiURLParams(req, map[string]string{"matchId": "match-123"})
+		rr := httptest.NewRecorder()
+		handler.RegisterMatchInfo(rr, req)
+		return rr
+	}
+
+	rr := request(`{"competition": "Saudi Pro League", "teamNames": {"1": "Al Hilal", "2": "Al Nassr"}}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	if stored == nil {
+		t.Fatal("expected match info to be stored")
+	}
+	if stored.MatchID != "match-123" || stored.Competition != "Saudi Pro League" {
+		t.Errorf("unexpected stored info: %+v", stored)
+	}
+	if stored.TeamNames[1] != "Al Hilal" || stored.TeamNames[2] != "Al Nassr" {
+		t.Errorf("unexpected team names: %v", stored.TeamNames)
+	}
+
+	invalid := []struct {
+		name      string
+		body      string
+		wantField string
+	}{
+		{"unknown team", `{"teamNames": {"3": "Al Ittihad"}}`, "teamNames"},
+		{"empty name", `{"teamNames": {"1": " "}}`, "teamNames"},
+		{"nothing to register", `{}`, "competition"},
+	}
+	for _, tt := range invalid {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := request(tt.body)
+			if rr.Code != http.StatusBadRequest {
+				t.Fatalf("expected status %d, got %d", http.StatusBadRequest, rr.Code)
+			}
+			var resp api.ErrorResponse
+			if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if resp.Field != tt.wantField {
+				t.Errorf("expected field %q, got %q", tt.wantField, resp.Field)
+			}
+		})
+	}
+}
+
+func TestGetMatchMetrics_MatchInfo(t *testing.T) {
+	tests := []struct {
+		name   string
+		info   *domain.MatchInfo
+		absent bool
+	}{
+		{"with registered info", &domain.MatchInfo{Competition: "Saudi Pro League", TeamNames: map[int]string{1: "Al Hilal", 2: "Al Nassr"}}, false},
+		{"without registered info", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := &MockRepository{
+				GetMatchMetricsFunc: func(ctx context.Context, matchID string) (*domain.MatchMetrics, error) {
+					metrics := &domain.MatchMetrics{
+						MatchID:      matchID,
+						TotalEvents:  1,
+						EventsByType: map[string]int64{"pass": 1},
+					}
+					metrics.ApplyMatchInfo(tt.info)
+					return metrics, nil
+				},
+			}
+			handler := api.NewHandler(&MockProducer{}, mockRepo)
+
+			req := httptest.NewRequest(http.MethodGet, "/api/matches/match-123/metrics", nil)
+			req = withChiURLParams(req, map[string]string{"matchId": "match-123"})
+			rr := httptest.NewRecorder()
+			handler.GetMatchMetrics(rr, req)
+
+			if rr.Code != http.StatusOK {
+				t.Fatalf("expected status %d, got %d", http.StatusOK, rr.Code)
+			}
+			var body map[string]interface{}
+			if err := json.NewDecoder(rr.Body).Decode(&body); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			_, hasCompetition := body["competition"]
+			_, hasTeamNames := body["teamNames"]
+			if tt.absent {
+				if hasCompetition || hasTeamNames {
+					t.Errorf("expected no match info in response, got %v", body)
+				}
+				return
+			}
+			if body["competition"] != "Saudi Pro League" {
+				t.Errorf("expected competition, got %v", body["competition"])
+			}
+			teamNames, _ := body["teamNames"].(map[string]interface{})
+			if teamNames["1"] != "Al Hilal" || teamNames["2"] != "Al Nassr" {
+				t.Errorf("expected team names, got %v", body["teamNames"])
+			}
+		})
+	}
+}
+
+func TestGetMatchMetrics_WeightedPeakChangesPeakMinute(t *testing.T) {
 	now := time.Now().UTC().Truncate(time.Minute)
+	passMinute := now
+	goalMinute := now.Add(time.Minute)
 
-	mockProducer := &MockProducer{}
 	mockRepo := &MockRepository{
 		GetMatchMetricsFunc: func(ctx context.Context, matchID string) (*domain.MatchMetrics, error) {
 			return &domain.MatchMetrics{
 				MatchID:      "match-123",
-				TotalEvents:  50,
-				EventsByType: map[string]int64{"goal": 2, "shot": 10},
+				TotalEvents:  12,
+				EventsByType: map[string]int64{"pass": 10, "goal": 2},
 				Goals:        2,
 			}, nil
 		},
 		GetEventsPerMinuteFunc: func(ctx context.Context, matchID string) ([]domain.EventsPerMinute, error) {
-			// Return events per minute data
 			return []domain.EventsPerMinute{
-				{Minute: now, EventType: "goal", EventCount: 2},
-				{Minute: now, EventType: "shot", EventCount: 8},
-				{Minute: now.Add(time.Minute), EventType: "shot", EventCount: 3},
+				{Minute: passMinute, EventType: "pass", EventCount: 10},
+				{Minute: goalMinute, EventType: "goal", EventCount: 2},
 			}, nil
 		},
 	}
 
-	handler := api.NewHandler(mockProducer, mockRepo)
-
-	req := httptest.NewRequest(http.MethodGet, "/api/matches/match-123/metrics", nil)
-	req = withChiURLParams(req, map[string]string{"matchId": "match-123"})
+	tests := []struct {
+		name          string
+		weights       domain.EngagementWeights
+		expectedPeak  time.Time
+		expectedCount int64
+		expectedScore float64
+	}{
+		{
+			name:          "default weights pick busiest minute",
+			weights:       domain.DefaultEngagementWeights(),
+			expectedPeak:  passMinute,
+			expectedCount: 10,
+			expectedScore: 10,
+		},
+		{
+			name:          "goal weight moves peak to goal minute",
+			weights:       domain.EngagementWeights{domain.EventTypeGoal: 10, domain.EventTypePass: 1},
+			expectedPeak:  goalMinute,
+			expectedCount: 2,
+			expectedScore: 20,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := api.NewHandlerWithConfig(&MockProducer{}, mockRepo, api.HandlerConfig{
+				EngagementWeights: tt.weights,
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/api/matches/match-123/metrics", nil)
+			req = withChiURLParams(req, map[string]string{"matchId": "match-123"})
+
+			rr := httptest.NewRecorder()
+			handler.GetMatchMetrics(rr, req)
+
+			if rr.Code != http.StatusOK {
+				t.Fatalf("expected status %d, got %d", http.StatusOK, rr.Code)
+			}
+
+			var metrics domain.MatchMetrics
+			if err := json.NewDecoder(rr.Body).Decode(&metrics); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+
+			if metrics.PeakMinute == nil {
+				t.Fatal("expected peakMinute to be set")
+			}
+			if !metrics.PeakMinute.Minute.Equal(tt.expectedPeak) {
+				t.Errorf("expected peak minute %v, got %v", tt.expectedPeak, metrics.PeakMinute.Minute)
+			}
+			if metrics.PeakMinute.EventCount != tt.expectedCount {
+				t.Errorf("expected peak event count %d, got %d", tt.expectedCount, metrics.PeakMinute.EventCount)
+			}
+			if metrics.PeakMinute.Score != tt.expectedScore {
+				t.Errorf("expected peak score %f, got %f", tt.expectedScore, metrics.PeakMinute.Score)
+			}
+		})
+	}
+}
+
+// ====================
+// HeadMatchMetrics Tests
+// ====================
+
+func TestHeadMatchMetrics_Router(t *testing.T) {
+	mockRepo := &MockRepository{
+		MatchExistsFunc: func(ctx context.Context, matchID string) (bool, error) {
+			return matchID == "match-123", nil
+		},
+		GetMatchMetricsFunc: func(ctx context.Context, matchID string) (*domain.MatchMetrics, error) {
+			t.Error("HEAD must not compute full metrics")
+			return nil, nil
+		},
+	}
+	router := api.NewRouter(&MockProducer{}, mockRepo, slog.Default())
+
+	tests := []struct {
+		name           string
+		matchID        string
+		expectedStatus int
+	}{
+		{"existing match", "match-123", http.StatusOK},
+		{"unknown match", "unknown", http.StatusNotFound},
+	}
 
-	rr := httptest.NewRecorder()
-	handler.GetMatchMetrics(rr, req)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodHead, "/api/matches/"+tt.matchID+"/metrics", nil)
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
 
-	if rr.Code != http.StatusOK {
-		t.Errorf("expected status %d, got %d", http.StatusOK, rr.Code)
+			if rr.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, rr.Code)
+			}
+			if rr.Body.Len() != 0 {
+				t.Errorf("expected empty body, got %q", rr.Body.String())
+			}
+		})
 	}
+}
 
-	var metrics domain.MatchMetrics
-	if err := json.NewDecoder(rr.Body).Decode(&metrics); err != nil {
-		t.Fatalf("failed to decode response: %v", err)
+func TestHeadMatchMetrics_RepositoryError(t *testing.T) {
+	mockRepo := &MockRepository{
+		MatchExistsFunc: func(ctx context.Context, matchID string) (bool, error) {
+			return false, errors.New("database error")
+		},
 	}
+	handler := api.NewHandler(&MockProducer{}, mockRepo)
 
-	// Verify peak engagement is calculated
-	if metrics.PeakMinute == nil {
-		t.Error("expected peakMinute to be set")
-	} else {
-		// First minute has 10 events (2 goal + 8 shot), second has 3
-		if metrics.PeakMinute.EventCount != 10 {
-			t.Errorf("expected peak event count 10, got %d", metrics.PeakMinute.EventCount)
-		}
+	req := httptest.NewRequest(http.MethodHead, "/api/matches/match-123/metrics", nil)
+	req = withChiURLParams(req, map[string]string{"matchId": "match-123"})
+
+	rr := httptest.NewRecorder()
+	handler.HeadMatchMetrics(rr, req)
+
+	if rr.Code != http.StatusInternalServerError {
+		t.Errorf("expected status %d, got %d", http.StatusInternalServerError, rr.Code)
 	}
 }
 
@@ -647,6 +2164,38 @@ func TestHealthCheck_HasTimestamp(t *testing.T) {
 	}
 }
 
+func TestHandler_ClockStampsResponses(t *testing.T) {
+	now := time.Date(2024, 1, 15, 15, 0, 0, 0, time.UTC)
+	cfg := api.DefaultHandlerConfig()
+	cfg.Clock = domain.NewFixedClock(now)
+	handler := api.NewHandlerWithConfig(&MockProducer{}, &MockRepository{}, cfg)
+
+	rr := httptest.NewRecorder()
+	handler.HealthCheck(rr, httptest.NewRequest(http.MethodGet, "/health", nil))
+	var health api.HealthResponse
+	if err := json.NewDecoder(rr.Body).Decode(&health); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if !health.Timestamp.Equal(now) {
+		t.Errorf("expected health timestamp %v, got %v", now, health.Timestamp)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/events", bytes.NewReader(eventJSONForMatch("match-123")))
+	req.Header.Set("Content-Type", "application/json")
+	rr = httptest.NewRecorder()
+	handler.IngestEvent(rr, req)
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusAccepted, rr.Code, rr.Body.String())
+	}
+	var ingest api.IngestEventResponse
+	if err := json.NewDecoder(rr.Body).Decode(&ingest); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if !ingest.Timestamp.Equal(now) {
+		t.Errorf("expected ingest timestamp %v, got %v", now, ingest.Timestamp)
+	}
+}
+
 // ====================
 // ReadinessCheck Tests
 // ====================
@@ -754,6 +2303,80 @@ func TestReadinessCheck_HasTimestamp(t *testing.T) {
 	}
 }
 
+func TestReadinessCheck_Warmup(t *testing.T) {
+	schemaErr := errors.New("table does not exist")
+	schemaChecks := 0
+	warmup := api.NewWarmup(api.HealthCheckFunc(func(ctx context.Context) error {
+		schemaChecks++
+		return schemaErr
+	}))
+
+	cfg := api.DefaultHandlerConfig()
+	cfg.Warmup = warmup
+	handler := api.NewHandlerWithConfig(&MockProducer{}, &MockRepository{}, cfg)
+
+	ready := func() (int, api.ReadinessResponse) {
+		rr := httptest.NewRecorder()
+		handler.ReadinessCheck(rr, httptest.NewRequest(http.MethodGet, "/ready", nil))
+		var resp api.ReadinessResponse
+		if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		return rr.Code, resp
+	}
+
+	// Before any check has run the service is not ready, even though ClickHouse pings
+	if code, resp := ready(); code != http.StatusServiceUnavailable || resp.Checks["warmup"] != "warming up" {
+		t.Errorf("expected 503 warming up before the first check, got %d %v", code, resp.Checks)
+	}
+
+	if warmup.Check(context.Background()) {
+		t.Fatal("expected warmup to fail while the schema check fails")
+	}
+	code, resp := ready()
+	if code != http.StatusServiceUnavailable || resp.Status != "not ready" {
+		t.Errorf("expected 503 not ready, got %d %q", code, resp.Status)
+	}
+	if !strings.Contains(resp.Checks["warmup"], "table does not exist") {
+		t.Errorf("expected the schema error in the warmup check, got %q", resp.Checks["warmup"])
+	}
+
+	schemaErr = nil
+	if !warmup.Check(context.Background()) {
+		t.Fatal("expected warmup to succeed once the schema check passes")
+	}
+	code, resp = ready()
+	if code != http.StatusOK || resp.Checks["warmup"] != "complete" || resp.Checks["clickhouse"] != "healthy" {
+		t.Errorf("expected 200 ready after warmup, got %d %v", code, resp.Checks)
+	}
+
+	// The warmed state is cached; the schema check does not run again
+	schemaErr = errors.New("should not be called")
+	if !warmup.Check(context.Background()) || schemaChecks != 2 {
+		t.Errorf("expected cached ready state after %d checks, got %d checks", 2, schemaChecks)
+	}
+}
+
+func TestWarmup_RunRetriesUntilReady(t *testing.T) {
+	attempts := 0
+	warmup := api.NewWarmup(api.HealthCheckFunc(func(ctx context.Context) error {
+		attempts++
+		if attempts < 3 {
+			return errors.New("not yet")
+		}
+		return nil
+	}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if !warmup.Run(ctx, time.Millisecond) {
+		t.Fatal("expected warmup to complete")
+	}
+	if attempts != 3 || !warmup.Ready() || warmup.Err() != nil {
+		t.Errorf("expected ready after 3 attempts, got attempts=%d ready=%v err=%v", attempts, warmup.Ready(), warmup.Err())
+	}
+}
+
 // ====================
 // Table-Driven Tests for Validation
 // ====================
@@ -845,8 +2468,8 @@ func TestIngestEvent_ValidationErrors_TableDriven(t *testing.T) {
 			rr := httptest.NewRecorder()
 			handler.IngestEvent(rr, req)
 
-			if rr.Code != http.StatusBadRequest {
-				t.Errorf("expected status %d, got %d", http.StatusBadRequest, rr.Code)
+			if rr.Code != http.StatusUnprocessableEntity {
+				t.Errorf("expected status %d, got %d", http.StatusUnprocessableEntity, rr.Code)
 			}
 
 			var errResp api.ErrorResponse
@@ -956,3 +2579,572 @@ func BenchmarkReadinessCheck(b *testing.B) {
 		handler.ReadinessCheck(rr, req)
 	}
 }
+
+func TestGetEventMatrix(t *testing.T) {
+	minute := time.Date(2024, 1, 15, 14, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name           string
+		repoErr        error
+		expectedStatus int
+	}{
+		{name: "success", expectedStatus: http.StatusOK},
+		{name: "not found", repoErr: fmt.Errorf("match x: %w", domain.ErrMatchNotFound), expectedStatus: http.StatusNotFound},
+		{name: "repository error", repoErr: errors.New("database error"), expectedStatus: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := &MockRepository{
+				GetEventMatrixFunc: func(ctx context.Context, matchID string) (domain.EventMatrix, error) {
+					if tt.repoErr != nil {
+						return domain.EventMatrix{}, tt.repoErr
+					}
+					return domain.NewEventMatrix(matchID, []domain.EventsPerMinute{
+						{Minute: minute, EventType: "goal", EventCount: 1},
+					}), nil
+				},
+			}
+
+			router := api.NewRouter(&MockProducer{}, mockRepo, slog.Default())
+
+			req := httptest.NewRequest(http.MethodGet, "/api/matches/match-123/matrix", nil)
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d", tt.expectedStatus, rr.Code)
+			}
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+
+			var matrix domain.EventMatrix
+			if err := json.NewDecoder(rr.Body).Decode(&matrix); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if matrix.MatchID != "match-123" || len(matrix.Minutes) != 1 || len(matrix.Counts[0]) != len(matrix.EventTypes) {
+				t.Errorf("unexpected matrix: %+v", matrix)
+			}
+		})
+	}
+}
+
+func TestGetConversionStats(t *testing.T) {
+	tests := []struct {
+		name           string
+		repoErr        error
+		expectedStatus int
+	}{
+		{name: "success", expectedStatus: http.StatusOK},
+		{name: "not found", repoErr: fmt.Errorf("match x: %w", domain.ErrMatchNotFound), expectedStatus: http.StatusNotFound},
+		{name: "repository error", repoErr: errors.New("database error"), expectedStatus: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := &MockRepository{
+				GetConversionStatsFunc: func(ctx context.Context, matchID string) (domain.ConversionStats, error) {
+					if tt.repoErr != nil {
+						return domain.ConversionStats{}, tt.repoErr
+					}
+					return domain.NewConversionStats(matchID, 10, 4, 2), nil
+				},
+			}
+
+			router := api.NewRouter(&MockProducer{}, mockRepo, slog.Default())
+
+			req := httptest.NewRequest(http.MethodGet, "/api/matches/match-123/conversion", nil)
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d", tt.expectedStatus, rr.Code)
+			}
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+
+			var stats domain.ConversionStats
+			if err := json.NewDecoder(rr.Body).Decode(&stats); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if stats.MatchID != "match-123" || stats.OnTargetPercentage != 40 || stats.GoalsPerShot != 0.2 {
+				t.Errorf("unexpected conversion stats: %+v", stats)
+			}
+		})
+	}
+}
+
+func TestGetLeaderboard(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 250_000_000, time.UTC)
+
+	tests := []struct {
+		name           string
+		query          string
+		expectedStatus int
+		expectedField  string
+		expectedFrom   time.Time
+		expectedTo     time.Time
+		expectedLimit  int
+	}{
+		{
+			name:           "explicit range",
+			query:          "type=goal&from=2024-05-01T00:00:00Z&to=2024-05-08T00:00:00Z&limit=3",
+			expectedStatus: http.StatusOK,
+			expectedFrom:   time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC),
+			expectedTo:     time.Date(2024, 5, 8, 0, 0, 0, 0, time.UTC),
+			expectedLimit:  3,
+		},
+		{
+			name:           "defaults",
+			query:          "type=goal",
+			expectedStatus: http.StatusOK,
+			expectedFrom:   now.Add(-api.DefaultLeaderboardRange),
+			expectedTo:     now,
+			expectedLimit:  api.DefaultLeaderboardLimit,
+		},
+		{name: "missing type", query: "", expectedStatus: http.StatusBadRequest, expectedField: "type"},
+		{name: "unknown type", query: "type=header", expectedStatus: http.StatusBadRequest, expectedField: "type"},
+		{name: "unparseable from", query: "type=goal&from=yesterday", expectedStatus: http.StatusBadRequest, expectedField: "from"},
+		{name: "from after to", query: "type=goal&from=2024-05-08T00:00:00Z&to=2024-05-01T00:00:00Z", expectedStatus: http.StatusBadRequest, expectedField: "to"},
+		{name: "limit over max", query: "type=goal&limit=101", expectedStatus: http.StatusBadRequest, expectedField: "limit"},
+		{name: "non-numeric limit", query: "type=goal&limit=all", expectedStatus: http.StatusBadRequest, expectedField: "limit"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotFrom, gotTo time.Time
+			var gotLimit int
+			mockRepo := &MockRepository{
+				GetLeaderboardFunc: func(ctx context.Context, eventType string, from, to time.Time, limit int) ([]domain.PlayerStat, error) {
+					gotFrom, gotTo, gotLimit = from, to, limit
+					return []domain.PlayerStat{{PlayerID: "player-9", Count: 7, Matches: 4}}, nil
+				},
+			}
+			cfg := api.DefaultHandlerConfig()
+			cfg.Clock = domain.NewFixedClock(now)
+			handler := api.NewHandlerWithConfig(&MockProducer{}, mockRepo, cfg)
+
+			req := httptest.NewRequest(http.MethodGet, "/api/leaderboard?"+tt.query, nil)
+			rr := httptest.NewRecorder()
+			handler.GetLeaderboard(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.expectedStatus, rr.Code, rr.Body.String())
+			}
+			if tt.expectedStatus != http.StatusOK {
+				var errResp api.ErrorResponse
+				if err := json.NewDecoder(rr.Body).Decode(&errResp); err != nil {
+					t.Fatalf("failed to decode error response: %v", err)
+				}
+				if errResp.Field != tt.expectedField {
+					t.Errorf("expected field %q, got %q", tt.expectedField, errResp.Field)
+				}
+				return
+			}
+
+			// One player past the page is fetched to detect another page
+			if !gotFrom.Equal(tt.expectedFrom) || !gotTo.Equal(tt.expectedTo) || gotLimit != tt.expectedLimit+1 {
+				t.Errorf("expected query [%v, %v) limit %d, got [%v, %v) limit %d", tt.expectedFrom, tt.expectedTo, tt.expectedLimit+1, gotFrom, gotTo, gotLimit)
+			}
+			if got, want := rr.Header().Get(api.LeaderboardFromHeader), tt.expectedFrom.Format(time.RFC3339Nano); got != want {
+				t.Errorf("expected %s %s, got %q", api.LeaderboardFromHeader, want, got)
+			}
+			if got, want := rr.Header().Get(api.LeaderboardToHeader), tt.expectedTo.Format(time.RFC3339Nano); got != want {
+				t.Errorf("expected %s %s, got %q", api.LeaderboardToHeader, want, got)
+			}
+			var resp api.PagedResponse[domain.PlayerStat]
+			if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if resp.Count != 1 || len(resp.Data) != 1 || resp.Data[0].PlayerID != "player-9" {
+				t.Errorf("unexpected leaderboard: %+v", resp)
+			}
+			if resp.HasMore || resp.NextCursor != "" {
+				t.Errorf("expected a single page, got hasMore %v and nextCursor %q", resp.HasMore, resp.NextCursor)
+			}
+		})
+	}
+}
+
+func TestGetLeaderboard_HasMore(t *testing.T) {
+	mockRepo := &MockRepository{
+		GetLeaderboardFunc: func(ctx context.Context, eventType string, from, to time.Time, limit int) ([]domain.PlayerStat, error) {
+			return []domain.PlayerStat{
+				{PlayerID: "player-9", Count: 7, Matches: 4},
+				{PlayerID: "player-10", Count: 5, Matches: 5},
+				{PlayerID: "player-11", Count: 3, Matches: 2},
+			}[:limit], nil
+		},
+	}
+	handler := api.NewHandlerWithConfig(&MockProducer{}, mockRepo, api.DefaultHandlerConfig())
+
+	req := httptest.NewRequest(http.MethodGet, "/api/leaderboard?type=goal&limit=2", nil)
+	rr := httptest.NewRecorder()
+	handler.GetLeaderboard(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	var resp api.PagedResponse[domain.PlayerStat]
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if !resp.HasMore || resp.Count != 2 || len(resp.Data) != 2 || resp.Data[1].PlayerID != "player-10" {
+		t.Errorf("expected the top 2 players and hasMore, got %+v", resp)
+	}
+}
+
+func TestGetEventDistribution(t *testing.T) {
+	tests := []struct {
+		name           string
+		metrics        *domain.MatchMetrics
+		repoErr        error
+		expectedStatus int
+		expectedTypes  int
+	}{
+		{
+			name:           "success",
+			metrics:        &domain.MatchMetrics{MatchID: "match-123", TotalEvents: 4, EventsByType: map[string]int64{"pass": 3, "goal": 1}},
+			expectedStatus: http.StatusOK,
+			expectedTypes:  2,
+		},
+		{name: "no events", expectedStatus: http.StatusOK},
+		{name: "not found", repoErr: fmt.Errorf("match x: %w", domain.ErrMatchNotFound), expectedStatus: http.StatusNotFound},
+		{name: "repository error", repoErr: errors.New("database error"), expectedStatus: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := &MockRepository{
+				GetMatchMetricsFunc: func(ctx context.Context, matchID string) (*domain.MatchMetrics, error) {
+					return tt.metrics, tt.repoErr
+				},
+			}
+
+			router := api.NewRouter(&MockProducer{}, mockRepo, slog.Default())
+
+			req := httptest.NewRequest(http.MethodGet, "/api/matches/match-123/distribution", nil)
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d", tt.expectedStatus, rr.Code)
+			}
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+
+			var dist domain.EventDistribution
+			if err := json.NewDecoder(rr.Body).Decode(&dist); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if dist.MatchID != "match-123" || len(dist.Types) != tt.expectedTypes {
+				t.Fatalf("unexpected distribution: %+v", dist)
+			}
+			if tt.expectedTypes > 0 && (dist.Types[0].EventType != "pass" || dist.Types[0].Percentage != 75) {
+				t.Errorf("expected pass at 75%%, got %+v", dist.Types[0])
+			}
+		})
+	}
+}
+
+func TestIngestEvent_UseJSONNumber(t *testing.T) {
+	for _, useNumber := range []bool{false, true} {
+		var produced *domain.Event
+		mockProducer := &MockProducer{
+			ProduceFunc: func(ctx context.Context, event *domain.Event) error {
+				produced = event
+				return nil
+			},
+		}
+		cfg := api.DefaultHandlerConfig()
+		cfg.UseJSONNumber = useNumber
+		handler := api.NewHandlerWithConfig(mockProducer, &MockRepository{}, cfg)
+
+		body := `{"eventId":"` + uuid.New().String() + `","matchId":"match-123","eventType":"goal","timestamp":"` +
+			time.Now().UTC().Format(time.RFC3339) + `","teamId":1,"metadata":{"minute":45}}`
+		req := httptest.NewRequest(http.MethodPost, "/api/events", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		handler.IngestEvent(rr, req)
+
+		if rr.Code != http.StatusAccepted {
+			t.Fatalf("useNumber=%v: expected status %d, got %d: %s", useNumber, http.StatusAccepted, rr.Code, rr.Body.String())
+		}
+		var want interface{} = float64(45)
+		if useNumber {
+			want = json.Number("45")
+		}
+		if produced.Metadata["minute"] != want {
+			t.Errorf("useNumber=%v: expected minute %T %v, got %T %v", useNumber, want, want, produced.Metadata["minute"], produced.Metadata["minute"])
+		}
+	}
+}
+
+func TestSearchEvents(t *testing.T) {
+	event := &domain.Event{
+		EventID:   uuid.New(),
+		MatchID:   "match-123",
+		EventType: domain.EventTypeGoal,
+		Timestamp: time.Date(2024, 1, 15, 14, 0, 0, 0, time.UTC),
+		TeamID:    1,
+		Metadata:  map[string]interface{}{"position": "penalty", "minute": float64(45)},
+	}
+
+	tests := []struct {
+		name           string
+		query          string
+		repoErr        error
+		expectedStatus int
+		expectedField  string
+		wantFilters    map[string]string
+	}{
+		{
+			name:           "single filter",
+			query:          "meta.position=penalty",
+			expectedStatus: http.StatusOK,
+			wantFilters:    map[string]string{"position": "penalty"},
+		},
+		{
+			name:           "multiple filters",
+			query:          "meta.position=penalty&meta.minute=45&unrelated=1",
+			expectedStatus: http.StatusOK,
+			wantFilters:    map[string]string{"position": "penalty", "minute": "45"},
+		},
+		{name: "no filters", query: "position=penalty", expectedStatus: http.StatusBadRequest, expectedField: "meta"},
+		{name: "repeated filter", query: "meta.position=penalty&meta.position=open", expectedStatus: http.StatusBadRequest, expectedField: "meta.position"},
+		{name: "invalid key", query: "meta.pos%27ition=penalty", expectedStatus: http.StatusBadRequest, expectedField: "meta.pos'ition"},
+		{
+			name:           "too many filters",
+			query:          "meta.a=1&meta.b=2&meta.c=3&meta.d=4&meta.e=5&meta.f=6",
+			expectedStatus: http.StatusBadRequest,
+			expectedField:  "meta",
+		},
+		{name: "repository error", query: "meta.position=penalty", repoErr: errors.New("database error"), expectedStatus: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotFilters map[string]string
+			mockRepo := &MockRepository{
+				SearchEventsFunc: func(ctx context.Context, matchID string, filters map[string]string) ([]*domain.Event, error) {
+					gotFilters = filters
+					if tt.repoErr != nil {
+						return nil, tt.repoErr
+					}
+					return []*domain.Event{event}, nil
+				},
+			}
+
+			router := api.NewRouter(&MockProducer{}, mockRepo, slog.Default())
+
+			req := httptest.NewRequest(http.MethodGet, "/api/matches/match-123/events/search?"+tt.query, nil)
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.expectedStatus, rr.Code, rr.Body.String())
+			}
+			if tt.expectedField != "" {
+				var errResp api.ErrorResponse
+				json.NewDecoder(rr.Body).Decode(&errResp)
+				if errResp.Field != tt.expectedField {
+					t.Errorf("expected field %q, got %q", tt.expectedField, errResp.Field)
+				}
+				if gotFilters != nil {
+					t.Error("repository should not be queried for invalid filters")
+				}
+				return
+			}
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+
+			if len(gotFilters) != len(tt.wantFilters) {
+				t.Fatalf("expected filters %v, got %v", tt.wantFilters, gotFilters)
+			}
+			for key, value := range tt.wantFilters {
+				if gotFilters[key] != value {
+					t.Errorf("expected filter %s=%s, got %q", key, value, gotFilters[key])
+				}
+			}
+
+			var resp api.PagedResponse[domain.KafkaMessage]
+			if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if resp.Count != 1 || len(resp.Data) != 1 {
+				t.Fatalf("unexpected response: %+v", resp)
+			}
+			if resp.Data[0].EventID != event.EventID.String() || resp.Data[0].Metadata["position"] != "penalty" {
+				t.Errorf("unexpected event: %+v", resp.Data[0])
+			}
+			if resp.HasMore || resp.NextCursor != "" {
+				t.Errorf("expected a single page, got hasMore %v and nextCursor %q", resp.HasMore, resp.NextCursor)
+			}
+		})
+	}
+}
+
+func TestSearchEvents_HasMore(t *testing.T) {
+	events := make([]*domain.Event, domain.MaxSearchResults+1)
+	for i := range events {
+		events[i] = &domain.Event{EventID: uuid.New(), MatchID: "match-123", EventType: domain.EventTypePass, TeamID: 1}
+	}
+	mockRepo := &MockRepository{
+		SearchEventsFunc: func(ctx context.Context, matchID string, filters map[string]string) ([]*domain.Event, error) {
+			return events, nil
+		},
+	}
+	router := api.NewRouter(&MockProducer{}, mockRepo, slog.Default())
+
+	req := httptest.NewRequest(http.MethodGet, "/api/matches/match-123/events/search?meta.position=penalty", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+	var resp api.PagedResponse[domain.KafkaMessage]
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if !resp.HasMore || resp.Count != domain.MaxSearchResults || len(resp.Data) != domain.MaxSearchResults {
+		t.Errorf("expected %d events and hasMore, got count %d and hasMore %v", domain.MaxSearchResults, resp.Count, resp.HasMore)
+	}
+}
+
+func TestGetTeamTimeline(t *testing.T) {
+	minute := time.Date(2024, 1, 15, 14, 0, 0, 0, time.UTC)
+	byTeam := map[int][]domain.EventsPerMinute{
+		1: {{Minute: minute, EventType: "pass", EventCount: 10}},
+		2: {{Minute: minute, EventType: "shot", EventCount: 2}, {Minute: minute.Add(time.Minute), EventType: "goal", EventCount: 1}},
+	}
+
+	tests := []struct {
+		name           string
+		matchID        string
+		teamID         string
+		expectedStatus int
+		expectedRows   int
+	}{
+		{name: "team 1", matchID: "match-123", teamID: "1", expectedStatus: http.StatusOK, expectedRows: 1},
+		{name: "team 2", matchID: "match-123", teamID: "2", expectedStatus: http.StatusOK, expectedRows: 2},
+		{name: "invalid team", matchID: "match-123", teamID: "3", expectedStatus: http.StatusBadRequest},
+		{name: "non-numeric team", matchID: "match-123", teamID: "home", expectedStatus: http.StatusBadRequest},
+		{name: "team with no events", matchID: "quiet-match", teamID: "1", expectedStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotTeam int
+			mockRepo := &MockRepository{
+				GetTeamEventsPerMinuteFunc: func(ctx context.Context, matchID string, teamID int) ([]domain.EventsPerMinute, error) {
+					gotTeam = teamID
+					if matchID == "quiet-match" {
+						return nil, nil
+					}
+					return byTeam[teamID], nil
+				},
+			}
+
+			router := api.NewRouter(&MockProducer{}, mockRepo, slog.Default())
+
+			req := httptest.NewRequest(http.MethodGet, "/api/matches/"+tt.matchID+"/timeline/"+tt.teamID, nil)
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d", tt.expectedStatus, rr.Code)
+			}
+			if tt.expectedStatus == http.StatusBadRequest {
+				var errResp api.ErrorResponse
+				if err := json.NewDecoder(rr.Body).Decode(&errResp); err != nil {
+					t.Fatalf("failed to decode response: %v", err)
+				}
+				if errResp.Field != "teamId" {
+					t.Errorf("expected field teamId, got %s", errResp.Field)
+				}
+				return
+			}
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+
+			var resp api.TeamTimelineResponse
+			if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if resp.TeamID != gotTeam || len(resp.Timeline) != tt.expectedRows {
+				t.Errorf("expected team %d with %d rows, got %+v", gotTeam, tt.expectedRows, resp)
+			}
+		})
+	}
+}
+
+func TestGetEventRate(t *testing.T) {
+	tests := []struct {
+		name           string
+		query          string
+		expectedStatus int
+		expectedWindow int
+	}{
+		{name: "default window", query: "", expectedStatus: http.StatusOK, expectedWindow: api.DefaultRateWindowSeconds},
+		{name: "explicit window", query: "?window=10", expectedStatus: http.StatusOK, expectedWindow: 10},
+		{name: "window capped", query: "?window=86400", expectedStatus: http.StatusOK, expectedWindow: api.MaxRateWindowSeconds},
+		{name: "zero window", query: "?window=0", expectedStatus: http.StatusBadRequest},
+		{name: "non-numeric window", query: "?window=1m", expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotSince time.Time
+			mockRepo := &MockRepository{
+				GetEventsPerSecondFunc: func(ctx context.Context, matchID string, since time.Time) ([]domain.EventsPerSecond, error) {
+					gotSince = since
+					return []domain.EventsPerSecond{{Second: since, EventCount: 4}}, nil
+				},
+			}
+
+			router := api.NewRouter(&MockProducer{}, mockRepo, slog.Default())
+
+			req := httptest.NewRequest(http.MethodGet, "/api/matches/match-123/rate"+tt.query, nil)
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.expectedStatus, rr.Code, rr.Body.String())
+			}
+			if tt.expectedStatus == http.StatusBadRequest {
+				var errResp api.ErrorResponse
+				if err := json.NewDecoder(rr.Body).Decode(&errResp); err != nil {
+					t.Fatalf("failed to decode response: %v", err)
+				}
+				if errResp.Field != api.RateWindowParam {
+					t.Errorf("expected field window, got %s", errResp.Field)
+				}
+				return
+			}
+
+			var resp api.EventRateResponse
+			if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if resp.WindowSeconds != tt.expectedWindow || len(resp.Rate) != tt.expectedWindow {
+				t.Fatalf("expected %d seconds, got window %d with %d entries", tt.expectedWindow, resp.WindowSeconds, len(resp.Rate))
+			}
+			if age := time.Since(gotSince); age > time.Duration(tt.expectedWindow+1)*time.Second {
+				t.Errorf("expected query to scan at most %ds, since is %s ago", tt.expectedWindow, age)
+			}
+			// Seconds without rows are filled with zero counts
+			if resp.Rate[0].EventCount != 4 || !resp.Rate[0].Second.Equal(gotSince) {
+				t.Errorf("expected first second to carry the repository count, got %+v", resp.Rate[0])
+			}
+			if resp.Rate[1].EventCount != 0 || resp.Rate[1].Second.Sub(resp.Rate[0].Second) != time.Second {
+				t.Errorf("expected zero-filled next second, got %+v", resp.Rate[1])
+			}
+		})
+	}
+}
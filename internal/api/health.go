@@ -0,0 +1,91 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// HealthChecker probes a single dependency for the deep health check.
+type HealthChecker interface {
+	Ping(ctx context.Context) error
+}
+
+// HealthCheckFunc adapts an ordinary function to the HealthChecker interface.
+type HealthCheckFunc func(ctx context.Context) error
+
+// Ping calls f(ctx).
+func (f HealthCheckFunc) Ping(ctx context.Context) error {
+	return f(ctx)
+}
+
+// DependencyStatus is the result of probing one dependency.
+type DependencyStatus struct {
+	Status    string  `json:"status"`
+	LatencyMs float64 `json:"latencyMs"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// DeepHealthResponse represents the response for the deep health check.
+type DeepHealthResponse struct {
+	Status    string                      `json:"status"`
+	Timestamp time.Time                   `json:"timestamp"`
+	Checks    map[string]DependencyStatus `json:"checks"`
+}
+
+// deepHealthTimeout bounds each dependency probe.
+const deepHealthTimeout = 5 * time.Second
+
+// DeepHealthCheck handles GET /healthz/deep.
+// It probes every configured dependency concurrently and reports each one's
+// status and latency. Returns 200 only if all dependencies are healthy.
+func (h *Handler) DeepHealthCheck(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), deepHealthTimeout)
+	defer cancel()
+
+	var (
+		mu     sync.Mutex
+		wg     sync.WaitGroup
+		checks = make(map[string]DependencyStatus, len(h.config.HealthCheckers))
+	)
+
+	for name, checker := range h.config.HealthCheckers {
+		wg.Add(1)
+		go func(name string, checker HealthChecker) {
+			defer wg.Done()
+
+			start := time.Now()
+			err := checker.Ping(ctx)
+			status := DependencyStatus{
+				Status:    "healthy",
+				LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+			}
+			if err != nil {
+				status.Status = "unhealthy"
+				status.Error = err.Error()
+			}
+
+			mu.Lock()
+			checks[name] = status
+			mu.Unlock()
+		}(name, checker)
+	}
+	wg.Wait()
+
+	response := DeepHealthResponse{
+		Status:    "healthy",
+		Timestamp: h.config.Clock.Now().UTC(),
+		Checks:    checks,
+	}
+	httpStatus := http.StatusOK
+	for _, check := range checks {
+		if check.Status != "healthy" {
+			response.Status = "unhealthy"
+			httpStatus = http.StatusServiceUnavailable
+			break
+		}
+	}
+
+	respondJSON(w, httpStatus, response)
+}
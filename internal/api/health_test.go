@@ -0,0 +1,90 @@
+package api_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"fanfinity/internal/api"
+)
+
+func TestDeepHealthCheck(t *testing.T) {
+	healthy := api.HealthCheckFunc(func(ctx context.Context) error { return nil })
+	unhealthy := api.HealthCheckFunc(func(ctx context.Context) error { return errors.New("broker unreachable") })
+
+	tests := []struct {
+		name           string
+		repoErr        error
+		checkers       map[string]api.HealthChecker
+		expectedStatus int
+		expectedChecks map[string]string
+	}{
+		{
+			name:           "all healthy",
+			checkers:       map[string]api.HealthChecker{"kafka": healthy, "producer_buffer": healthy},
+			expectedStatus: http.StatusOK,
+			expectedChecks: map[string]string{"clickhouse": "healthy", "kafka": "healthy", "producer_buffer": "healthy"},
+		},
+		{
+			name:           "kafka unhealthy",
+			checkers:       map[string]api.HealthChecker{"kafka": unhealthy},
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedChecks: map[string]string{"clickhouse": "healthy", "kafka": "unhealthy"},
+		},
+		{
+			name:           "clickhouse unhealthy",
+			repoErr:        errors.New("connection refused"),
+			checkers:       map[string]api.HealthChecker{"kafka": healthy},
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedChecks: map[string]string{"clickhouse": "unhealthy", "kafka": "healthy"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := &MockRepository{
+				PingFunc: func(ctx context.Context) error { return tt.repoErr },
+			}
+			cfg := api.DefaultHandlerConfig()
+			cfg.HealthCheckers = tt.checkers
+
+			router := api.NewRouterWithConfig(&MockProducer{}, mockRepo, slog.Default(), cfg)
+
+			req := httptest.NewRequest(http.MethodGet, "/healthz/deep", nil)
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d", tt.expectedStatus, rr.Code)
+			}
+
+			var resp api.DeepHealthResponse
+			if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if len(resp.Checks) != len(tt.expectedChecks) {
+				t.Fatalf("expected %d checks, got %d: %+v", len(tt.expectedChecks), len(resp.Checks), resp.Checks)
+			}
+			for name, status := range tt.expectedChecks {
+				check, ok := resp.Checks[name]
+				if !ok {
+					t.Errorf("missing check %s", name)
+					continue
+				}
+				if check.Status != status {
+					t.Errorf("expected %s to be %s, got %s", name, status, check.Status)
+				}
+				if status == "unhealthy" && check.Error == "" {
+					t.Errorf("expected error message for %s", name)
+				}
+				if check.LatencyMs < 0 {
+					t.Errorf("expected non-negative latency for %s", name)
+				}
+			}
+		})
+	}
+}
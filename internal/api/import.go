@@ -0,0 +1,275 @@
+package api
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"fanfinity/internal/domain"
+)
+
+// ImportColumns is the column order expected by POST /api/events/import. The
+// first row of the CSV must be this header. metadata holds a JSON object and
+// may be empty, as may playerId.
+var ImportColumns = []string{"eventId", "matchId", "eventType", "timestamp", "teamId", "playerId", "metadata"}
+
+// DefaultMaxImportBytes caps the size of a CSV import body.
+const DefaultMaxImportBytes = 64 << 20
+
+// DefaultStreamTimeout bounds a CSV import or match export.
+const DefaultStreamTimeout = 10 * time.Minute
+
+// DefaultImportBatchSize is the number of events produced per ProduceBatch call during an import.
+const DefaultImportBatchSize = 500
+
+// maxImportRejections bounds the rejected rows listed in an import response;
+// the rejected count stays exact.
+const maxImportRejections = 100
+
+// ImportRejection describes a CSV row that was not imported. Row is the line
+// number in the file, counting the header as line 1.
+type ImportRejection struct {
+	Row    int    `json:"row"`
+	Field  string `json:"field,omitempty"`
+	Reason string `json:"reason"`
+}
+
+// ImportResponse reports the outcome of a CSV import.
+type ImportResponse struct {
+	Accepted   int               `json:"accepted"`
+	Rejected   int               `json:"rejected"`
+	Rejections []ImportRejection `json:"rejections"`
+	// Truncated is set when more rows were rejected than are listed in Rejections.
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// importBatch accumulates events with the CSV rows they came from.
+type importBatch struct {
+	events []*domain.Event
+	rows   []int
+}
+
+// ImportEvents handles POST /api/events/import.
+// It streams a text/csv body in ImportColumns order, validates each row like a
+// single ingested event, and produces valid events in batches. Invalid rows are
+// reported by line number and do not stop the import.
+func (h *Handler) ImportEvents(w http.ResponseWriter, r *http.Request) {
+	if !requireContentType(w, r, "text/csv") {
+		return
+	}
+
+	reader := csv.NewReader(http.MaxBytesReader(w, r.Body, h.config.MaxImportBytes))
+	reader.FieldsPerRecord = len(ImportColumns)
+
+	// Imports load past matches, so strict mode's timestamp range is not applied
+	opts := h.config.Validation
+	opts.AllowHistorical = true
+
+	header, err := reader.Read()
+	if err != nil {
+		respondImportHeaderError(w, err)
+		return
+	}
+	if !equalColumns(header, ImportColumns) {
+		respondError(w, http.StatusBadRequest, "CSV header must be "+strings.Join(ImportColumns, ","), "header")
+		return
+	}
+
+	ctx := r.Context()
+	resp := ImportResponse{Rejections: []ImportRejection{}}
+	reject := func(row int, field, reason string) {
+		resp.Rejected++
+		if len(resp.Rejections) < maxImportRejections {
+			resp.Rejections = append(resp.Rejections, ImportRejection{Row: row, Field: field, Reason: reason})
+		} else {
+			resp.Truncated = true
+		}
+	}
+
+	batch := importBatch{
+		events: make([]*domain.Event, 0, h.config.ImportBatchSize),
+		rows:   make([]int, 0, h.config.ImportBatchSize),
+	}
+	flush := func() error {
+		if len(batch.events) == 0 {
+			return nil
+		}
+		accepted, err := h.produceImportBatch(ctx, batch, reject)
+		resp.Accepted += accepted
+		batch.events = batch.events[:0]
+		batch.rows = batch.rows[:0]
+		return err
+	}
+
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			// Malformed rows are reported and skipped; anything else, such as
+			// the body exceeding the size cap, ends the import
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				reject(parseErr.StartLine, "", parseErr.Err.Error())
+				continue
+			}
+			if flushErr := flush(); flushErr != nil {
+				err = flushErr
+			}
+			h.respondImportError(ctx, w, err, resp)
+			return
+		}
+
+		row, _ := reader.FieldPos(0)
+		req, field, reason := importRecord(record, h.config.UseJSONNumber)
+		if req == nil {
+			RecordEventRejected(field)
+			reject(row, field, reason)
+			continue
+		}
+		event, err := req.ToEventWithOptions(opts)
+		if err != nil {
+			if ve := domain.AsValidationError(err); ve != nil {
+				RecordEventRejected(ve.Field)
+				reject(row, ve.Field, ve.Message)
+			} else {
+				reject(row, "", err.Error())
+			}
+			continue
+		}
+
+		batch.events = append(batch.events, event)
+		batch.rows = append(batch.rows, row)
+		if len(batch.events) >= h.config.ImportBatchSize {
+			if err := flush(); err != nil {
+				h.respondImportError(ctx, w, err, resp)
+				return
+			}
+		}
+	}
+
+	if err := flush(); err != nil {
+		h.respondImportError(ctx, w, err, resp)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, resp)
+}
+
+// produceImportBatch produces batch and returns the number of events accepted.
+// A batch rejected for an oversized event is retried one event at a time so
+// only the oversized rows are rejected.
+func (h *Handler) produceImportBatch(ctx context.Context, batch importBatch, reject func(row int, field, reason string)) (int, error) {
+	err := h.producer.ProduceBatch(ctx, batch.events)
+	if err == nil {
+		for _, event := range batch.events {
+			RecordEventIngested(string(event.EventType))
+		}
+		return len(batch.events), nil
+	}
+	if !errors.Is(err, domain.ErrMessageTooLarge) {
+		RecordKafkaProduceError()
+		return 0, err
+	}
+
+	accepted := 0
+	for i, event := range batch.events {
+		if err := h.producer.Produce(ctx, event); err != nil {
+			if errors.Is(err, domain.ErrMessageTooLarge) {
+				reject(batch.rows[i], "metadata", "event exceeds maximum message size")
+				continue
+			}
+			RecordKafkaProduceError()
+			return accepted, err
+		}
+		RecordEventIngested(string(event.EventType))
+		accepted++
+	}
+	return accepted, nil
+}
+
+// respondImportError ends an import that could not run to completion, reporting
+// how many events were accepted before it stopped.
+func (h *Handler) respondImportError(ctx context.Context, w http.ResponseWriter, err error, resp ImportResponse) {
+	progress := " after " + strconv.Itoa(resp.Accepted) + " events were accepted"
+
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.As(err, &maxBytesErr):
+		respondError(w, http.StatusRequestEntityTooLarge, "import exceeds "+strconv.FormatInt(maxBytesErr.Limit, 10)+" bytes"+progress, "")
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		respondError(w, http.StatusServiceUnavailable, "import interrupted"+progress, "")
+	case errors.Is(err, domain.ErrBrokerUnavailable):
+		w.Header().Set("Retry-After", "5")
+		respondError(w, http.StatusServiceUnavailable, "message broker unavailable"+progress, "")
+	default:
+		LoggerFromContext(ctx).Error("failed to import events",
+			slog.Int("accepted", resp.Accepted),
+			slog.String("error", err.Error()),
+		)
+		respondError(w, http.StatusServiceUnavailable, "failed to import events"+progress, "")
+	}
+}
+
+// respondImportHeaderError reports a failure to read the header row.
+func respondImportHeaderError(w http.ResponseWriter, err error) {
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case err == io.EOF:
+		respondErrorWithCode(w, http.StatusBadRequest, "CSV header row is required", ErrCodeEmptyBody)
+	case errors.As(err, &maxBytesErr):
+		respondError(w, http.StatusRequestEntityTooLarge, "import exceeds "+strconv.FormatInt(maxBytesErr.Limit, 10)+" bytes", "")
+	default:
+		respondError(w, http.StatusBadRequest, "invalid CSV header", err.Error())
+	}
+}
+
+// importRecord converts a CSV record in ImportColumns order to an EventRequest.
+// On failure it returns nil with the offending field and reason.
+func importRecord(record []string, useNumber bool) (*domain.EventRequest, string, string) {
+	req := &domain.EventRequest{
+		EventID:   strings.TrimSpace(record[0]),
+		MatchID:   strings.TrimSpace(record[1]),
+		EventType: strings.TrimSpace(record[2]),
+		Timestamp: strings.TrimSpace(record[3]),
+		PlayerID:  strings.TrimSpace(record[5]),
+	}
+
+	teamID, err := strconv.Atoi(strings.TrimSpace(record[4]))
+	if err != nil {
+		return nil, "teamId", "must be an integer"
+	}
+	req.TeamID = teamID
+
+	if raw := strings.TrimSpace(record[6]); raw != "" {
+		if err := domain.DecodeJSON([]byte(raw), &req.Metadata, useNumber); err != nil {
+			return nil, "metadata", "must be a JSON object"
+		}
+	}
+
+	return req, "", ""
+}
+
+// equalColumns reports whether header matches want, ignoring surrounding whitespace
+// and a UTF-8 byte order mark on the first column.
+func equalColumns(header, want []string) bool {
+	if len(header) != len(want) {
+		return false
+	}
+	for i, column := range header {
+		if i == 0 {
+			column = strings.TrimPrefix(column, "\ufeff")
+		}
+		if strings.TrimSpace(column) != want[i] {
+			return false
+		}
+	}
+	return true
+}
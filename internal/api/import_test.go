@@ -0,0 +1,220 @@
+package api_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"fanfinity/internal/api"
+	"fanfinity/internal/domain"
+)
+
+const importHeader = "eventId,matchId,eventType,timestamp,teamId,playerId,metadata\n"
+
+func importRow(eventType, teamID, metadata string) string {
+	return uuid.New().String() + ",match-123," + eventType + ",2024-01-15T14:30:00Z," + teamID + ",player-1," + metadata + "\n"
+}
+
+func postImport(t *testing.T, producer *MockProducer, cfg api.HandlerConfig, body string) (*httptest.ResponseRecorder, api.ImportResponse) {
+	t.Helper()
+	router := api.NewRouterWithConfig(producer, &MockRepository{}, slog.Default(), cfg)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/events/import", strings.NewReader(body))
+	req.Header.Set("Content-Type", "text/csv; charset=utf-8")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	var resp api.ImportResponse
+	if rr.Code == http.StatusOK {
+		if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+	}
+	return rr, resp
+}
+
+func TestImportEvents_WellFormed(t *testing.T) {
+	var batches [][]*domain.Event
+	producer := &MockProducer{
+		ProduceBatchFunc: func(ctx context.Context, events []*domain.Event) error {
+			batches = append(batches, append([]*domain.Event(nil), events...))
+			return nil
+		},
+	}
+	cfg := api.DefaultHandlerConfig()
+	cfg.ImportBatchSize = 2
+
+	body := importHeader +
+		importRow("goal", "1", `"{""minute"": 12}"`) +
+		importRow("pass", "2", "") +
+		importRow("shot", "1", "")
+
+	rr, resp := postImport(t, producer, cfg, body)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	if resp.Accepted != 3 || resp.Rejected != 0 {
+		t.Errorf("expected 3 accepted and 0 rejected, got %d and %d", resp.Accepted, resp.Rejected)
+	}
+	if len(batches) != 2 || len(batches[0]) != 2 || len(batches[1]) != 1 {
+		t.Fatalf("expected batches of 2 and 1 events, got %d batches", len(batches))
+	}
+	if batches[0][0].Metadata["minute"] != float64(12) {
+		t.Errorf("expected metadata.minute 12, got %v", batches[0][0].Metadata["minute"])
+	}
+}
+
+func TestImportEvents_MalformedRows(t *testing.T) {
+	var produced int
+	producer := &MockProducer{
+		ProduceBatchFunc: func(ctx context.Context, events []*domain.Event) error {
+			produced += len(events)
+			return nil
+		},
+	}
+
+	body := importHeader +
+		importRow("goal", "1", "") + // line 2
+		importRow("goal", "one", "") + // line 3
+		importRow("throw_in", "1", "") + // line 4
+		"not,enough,columns\n" + // line 5
+		importRow("pass", "2", "{bad json") + // line 6
+		importRow("pass", "2", "") // line 7
+
+	rr, resp := postImport(t, producer, api.DefaultHandlerConfig(), body)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	if resp.Accepted != 2 || produced != 2 {
+		t.Errorf("expected 2 accepted and produced, got %d and %d", resp.Accepted, produced)
+	}
+	if resp.Rejected != 4 {
+		t.Fatalf("expected 4 rejected, got %d", resp.Rejected)
+	}
+
+	expected := []api.ImportRejection{
+		{Row: 3, Field: "teamId"},
+		{Row: 4, Field: "eventType"},
+		{Row: 5},
+		{Row: 6, Field: "metadata"},
+	}
+	for i, want := range expected {
+		got := resp.Rejections[i]
+		if got.Row != want.Row || got.Field != want.Field || got.Reason == "" {
+			t.Errorf("rejection %d: expected row %d field '%s' with a reason, got %+v", i, want.Row, want.Field, got)
+		}
+	}
+}
+
+func TestImportEvents_RejectsBadRequests(t *testing.T) {
+	testCases := []struct {
+		name        string
+		contentType string
+		body        string
+		maxBytes    int64
+		wantStatus  int
+	}{
+		{"wrong content type", "application/json", importHeader, 0, http.StatusUnsupportedMediaType},
+		{"empty body", "text/csv", "", 0, http.StatusBadRequest},
+		{"wrong header", "text/csv", "matchId,eventId,eventType,timestamp,teamId,playerId,metadata\n", 0, http.StatusBadRequest},
+		{"too large", "text/csv", importHeader + strings.Repeat(importRow("goal", "1", ""), 10), 512, http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := api.DefaultHandlerConfig()
+			cfg.MaxImportBytes = tc.maxBytes
+			router := api.NewRouterWithConfig(&MockProducer{}, &MockRepository{}, slog.Default(), cfg)
+
+			req := httptest.NewRequest(http.MethodPost, "/api/events/import", strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", tc.contentType)
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			if rr.Code != tc.wantStatus {
+				t.Errorf("expected status %d, got %d: %s", tc.wantStatus, rr.Code, rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestImportEvents_StrictModeAllowsPastTimestamps(t *testing.T) {
+	var produced int
+	producer := &MockProducer{
+		ProduceBatchFunc: func(ctx context.Context, events []*domain.Event) error {
+			produced += len(events)
+			return nil
+		},
+	}
+	cfg := api.DefaultHandlerConfig()
+	cfg.Validation.Mode = domain.ValidationModeStrict
+
+	rr, resp := postImport(t, producer, cfg, importHeader+importRow("goal", "1", "")+importRow("pass", "3", ""))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	if resp.Accepted != 1 || produced != 1 {
+		t.Errorf("expected the 2024 goal to be imported, got %d accepted and %d produced", resp.Accepted, produced)
+	}
+	if resp.Rejected != 1 || resp.Rejections[0].Field != "teamId" {
+		t.Errorf("expected the bad teamId to still be rejected, got %+v", resp.Rejections)
+	}
+}
+
+func TestImportEvents_CompressedBodyUsesImportLimit(t *testing.T) {
+	var produced int
+	producer := &MockProducer{
+		ProduceBatchFunc: func(ctx context.Context, events []*domain.Event) error {
+			produced += len(events)
+			return nil
+		},
+	}
+	cfg := api.DefaultHandlerConfig()
+	cfg.MaxDecompressedBytes = 512
+	router := api.NewRouterWithConfig(producer, &MockRepository{}, slog.Default(), cfg)
+
+	body := importHeader + strings.Repeat(importRow("goal", "1", ""), 20)
+	req := httptest.NewRequest(http.MethodPost, "/api/events/import", bytes.NewReader(gzipBytes(t, []byte(body))))
+	req.Header.Set("Content-Type", "text/csv")
+	req.Header.Set("Content-Encoding", "gzip")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	if produced != 20 {
+		t.Errorf("expected 20 events produced, got %d", produced)
+	}
+}
+
+func TestImportEvents_OutlastsWriteTimeout(t *testing.T) {
+	producer := &MockProducer{
+		ProduceBatchFunc: func(ctx context.Context, events []*domain.Event) error {
+			time.Sleep(300 * time.Millisecond)
+			return nil
+		},
+	}
+	ts := httptest.NewUnstartedServer(api.NewRouter(producer, &MockRepository{}, slog.Default()))
+	ts.Config.WriteTimeout = 100 * time.Millisecond
+	ts.Start()
+	defer ts.Close()
+
+	body := importHeader + importRow("goal", "1", "")
+	resp, err := http.Post(ts.URL+"/api/events/import", "text/csv", strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("expected the import to lift the write deadline, got %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected status %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+}
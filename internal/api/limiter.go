@@ -0,0 +1,64 @@
+package api
+
+import (
+	"context"
+	"time"
+)
+
+// ProduceLimiter bounds the number of concurrent produce calls made by the ingestion path.
+type ProduceLimiter interface {
+	// Acquire reserves a slot, reporting false if none frees up before the
+	// limiter's wait elapses or ctx is done.
+	Acquire(ctx context.Context) bool
+	// Release frees a slot reserved by a successful Acquire.
+	Release()
+}
+
+// ProduceSemaphore is a ProduceLimiter backed by a buffered channel.
+type ProduceSemaphore struct {
+	slots chan struct{}
+	wait  time.Duration
+}
+
+// NewProduceSemaphore creates a semaphore allowing limit concurrent produce calls.
+// Callers beyond the limit wait up to wait for a slot before being shed.
+func NewProduceSemaphore(limit int, wait time.Duration) *ProduceSemaphore {
+	if limit <= 0 {
+		limit = 1
+	}
+	if wait < 0 {
+		wait = 0
+	}
+	return &ProduceSemaphore{
+		slots: make(chan struct{}, limit),
+		wait:  wait,
+	}
+}
+
+// Acquire reserves a slot, waiting up to the configured wait.
+func (s *ProduceSemaphore) Acquire(ctx context.Context) bool {
+	select {
+	case s.slots <- struct{}{}:
+		return true
+	default:
+	}
+	if s.wait == 0 {
+		return false
+	}
+
+	timer := time.NewTimer(s.wait)
+	defer timer.Stop()
+	select {
+	case s.slots <- struct{}{}:
+		return true
+	case <-timer.C:
+		return false
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// Release frees a slot.
+func (s *ProduceSemaphore) Release() {
+	<-s.slots
+}
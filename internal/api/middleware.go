@@ -1,16 +1,27 @@
 package api
 
 import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"crypto/subtle"
+	"errors"
+	"io"
 	"log/slog"
 	"math"
 	"net/http"
 	"sort"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	"fanfinity/internal/domain"
+	"fanfinity/internal/metrics"
 
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 )
@@ -18,61 +29,132 @@ import (
 // Prometheus metrics for HTTP and event processing.
 var (
 	// HTTP request metrics
-	httpRequestsTotal = promauto.NewCounterVec(
+	httpRequestsTotal   *prometheus.CounterVec
+	httpRequestDuration *prometheus.HistogramVec
+	httpResponseSize    *prometheus.HistogramVec
+
+	// Event ingestion metrics
+	eventsIngestedTotal *prometheus.CounterVec
+	eventsRejectedTotal *prometheus.CounterVec
+	eventsShedTotal     prometheus.Counter
+	eventIngestDuration prometheus.Histogram
+
+	// Error metrics
+	kafkaProduceErrorsTotal    prometheus.Counter
+	clickhouseQueryErrorsTotal prometheus.Counter
+)
+
+var metricSet metrics.Set
+
+func init() {
+	RegisterMetrics(prometheus.DefaultRegisterer, metrics.Options{})
+}
+
+// RegisterMetrics creates the HTTP and ingestion metrics under opts and
+// registers them with reg, replacing any registered by an earlier call. The
+// package registers defaults at init; call it again at startup, before serving
+// requests, to change the namespace or add constant labels.
+func RegisterMetrics(reg prometheus.Registerer, opts metrics.Options) {
+	registerMetrics(metricSet.Factory(reg), opts)
+}
+
+// registerMetrics creates the HTTP and ingestion metrics under opts.
+func registerMetrics(f promauto.Factory, opts metrics.Options) {
+	ns := opts.NamespaceOrDefault()
+
+	httpRequestsTotal = f.NewCounterVec(
 		prometheus.CounterOpts{
-			Name: "http_requests_total",
-			Help: "Total number of HTTP requests",
+			Name:        "http_requests_total",
+			Help:        "Total number of HTTP requests",
+			ConstLabels: opts.ConstLabels,
 		},
 		[]string{"method", "path", "status"},
 	)
 
-	httpRequestDuration = promauto.NewHistogramVec(
+	httpRequestDuration = f.NewHistogramVec(
 		prometheus.HistogramOpts{
-			Name:    "http_request_duration_seconds",
-			Help:    "HTTP request duration in seconds",
-			Buckets: prometheus.DefBuckets,
+			Name:        "http_request_duration_seconds",
+			Help:        "HTTP request duration in seconds",
+			ConstLabels: opts.ConstLabels,
+			Buckets:     prometheus.DefBuckets,
+		},
+		[]string{"method", "path"},
+	)
+
+	// 100 B to ~1.6 MB, so oversized metrics responses for busy matches stand out
+	httpResponseSize = f.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:        "http_response_size_bytes",
+			Help:        "HTTP response body size in bytes",
+			ConstLabels: opts.ConstLabels,
+			Buckets:     prometheus.ExponentialBuckets(100, 4, 8),
 		},
 		[]string{"method", "path"},
 	)
 
 	// Event ingestion metrics
-	eventsIngestedTotal = promauto.NewCounterVec(
+	eventsIngestedTotal = f.NewCounterVec(
 		prometheus.CounterOpts{
-			Name: "events_ingested_total",
-			Help: "Total number of events ingested",
+			Name:        "events_ingested_total",
+			Help:        "Total number of events ingested",
+			ConstLabels: opts.ConstLabels,
 		},
 		[]string{"event_type"},
 	)
 
-	eventIngestDuration = promauto.NewHistogram(
+	eventsRejectedTotal = f.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace:   ns,
+			Name:        "events_rejected_total",
+			Help:        "Total number of events rejected by validation, by field",
+			ConstLabels: opts.ConstLabels,
+		},
+		[]string{"field"},
+	)
+
+	eventsShedTotal = f.NewCounter(
+		prometheus.CounterOpts{
+			Namespace:   ns,
+			Name:        "events_shed_total",
+			Help:        "Total number of events rejected with 503 because the produce pool was saturated",
+			ConstLabels: opts.ConstLabels,
+		},
+	)
+
+	eventIngestDuration = f.NewHistogram(
 		prometheus.HistogramOpts{
-			Name:    "event_ingest_duration_seconds",
-			Help:    "Event ingestion duration in seconds",
-			Buckets: prometheus.DefBuckets,
+			Name:        "event_ingest_duration_seconds",
+			Help:        "Event ingestion duration in seconds",
+			ConstLabels: opts.ConstLabels,
+			Buckets:     prometheus.DefBuckets,
 		},
 	)
 
 	// Error metrics
-	kafkaProduceErrorsTotal = promauto.NewCounter(
+	kafkaProduceErrorsTotal = f.NewCounter(
 		prometheus.CounterOpts{
-			Name: "kafka_produce_errors_total",
-			Help: "Total number of Kafka produce errors",
+			Name:        "kafka_produce_errors_total",
+			Help:        "Total number of Kafka produce errors",
+			ConstLabels: opts.ConstLabels,
 		},
 	)
 
-	clickhouseQueryErrorsTotal = promauto.NewCounter(
+	clickhouseQueryErrorsTotal = f.NewCounter(
 		prometheus.CounterOpts{
-			Name: "clickhouse_query_errors_total",
-			Help: "Total number of ClickHouse query errors",
+			Name:        "clickhouse_query_errors_total",
+			Help:        "Total number of ClickHouse query errors",
+			ConstLabels: opts.ConstLabels,
 		},
 	)
-)
+}
 
-// responseWriter wraps http.ResponseWriter to capture the status code.
+// responseWriter wraps http.ResponseWriter to capture the status code and
+// the number of body bytes written.
 type responseWriter struct {
 	http.ResponseWriter
-	statusCode int
-	written    bool
+	statusCode   int
+	written      bool
+	bytesWritten int64
 }
 
 // newResponseWriter creates a new responseWriter with a default 200 status.
@@ -92,12 +174,15 @@ func (rw *responseWriter) WriteHeader(code int) {
 	}
 }
 
-// Write ensures WriteHeader is called before writing the body.
+// Write ensures WriteHeader is called before writing the body and counts the
+// bytes written.
 func (rw *responseWriter) Write(b []byte) (int, error) {
 	if !rw.written {
 		rw.WriteHeader(http.StatusOK)
 	}
-	return rw.ResponseWriter.Write(b)
+	n, err := rw.ResponseWriter.Write(b)
+	rw.bytesWritten += int64(n)
+	return n, err
 }
 
 // Unwrap returns the underlying ResponseWriter for middleware compatibility.
@@ -105,6 +190,86 @@ func (rw *responseWriter) Unwrap() http.ResponseWriter {
 	return rw.ResponseWriter
 }
 
+// timeoutWriter guards a handler's writes against the request deadline. The first
+// write after the deadline is replaced by a clean JSON 504 and later writes are
+// dropped, so a slow handler can never produce a garbled or doubled response.
+type timeoutWriter struct {
+	http.ResponseWriter
+	ctx         context.Context
+	wroteHeader bool
+	timedOut    bool
+}
+
+// WriteHeader writes code, or a 504 if the request deadline has already passed.
+func (tw *timeoutWriter) WriteHeader(code int) {
+	if tw.wroteHeader {
+		return
+	}
+	tw.wroteHeader = true
+	if errors.Is(tw.ctx.Err(), context.DeadlineExceeded) {
+		tw.timedOut = true
+		// Drop headers the handler set for the response it no longer gets to send
+		for key := range tw.ResponseWriter.Header() {
+			tw.ResponseWriter.Header().Del(key)
+		}
+		respondError(tw.ResponseWriter, http.StatusGatewayTimeout, "request timed out", "")
+		return
+	}
+	tw.ResponseWriter.WriteHeader(code)
+}
+
+// Write writes b unless the response was replaced by a timeout.
+func (tw *timeoutWriter) Write(b []byte) (int, error) {
+	if !tw.wroteHeader {
+		tw.WriteHeader(http.StatusOK)
+	}
+	if tw.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	return tw.ResponseWriter.Write(b)
+}
+
+// Unwrap returns the underlying ResponseWriter for middleware compatibility.
+func (tw *timeoutWriter) Unwrap() http.ResponseWriter {
+	return tw.ResponseWriter
+}
+
+// RequestTimeout returns middleware that cancels the request context after timeout.
+// Unlike chi's Timeout it never writes over a response the handler already started:
+// a handler that returns after the deadline without writing, or writes only after
+// it, yields a single JSON 504.
+func RequestTimeout(timeout time.Duration) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithTimeout(r.Context(), timeout)
+			defer cancel()
+
+			tw := &timeoutWriter{ResponseWriter: w, ctx: ctx}
+			next.ServeHTTP(tw, r.WithContext(ctx))
+
+			if !tw.wroteHeader && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				tw.WriteHeader(http.StatusGatewayTimeout)
+			}
+		})
+	}
+}
+
+// StreamDeadlines bounds long-running transfers such as CSV imports and
+// exports by timeout instead of the request timeout: the request context gets
+// the timeout, and the connection's read and write deadlines are moved past it
+// so the server ReadTimeout and WriteTimeout do not cut the transfer off.
+func StreamDeadlines(timeout time.Duration) func(next http.Handler) http.Handler {
+	timeoutMiddleware := RequestTimeout(timeout)
+	return func(next http.Handler) http.Handler {
+		inner := timeoutMiddleware(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			extendReadDeadline(w, timeout)
+			extendWriteDeadline(w, timeout)
+			inner.ServeHTTP(w, r)
+		})
+	}
+}
+
 // PrometheusMiddleware records HTTP request metrics.
 func PrometheusMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -121,9 +286,22 @@ func PrometheusMiddleware(next http.Handler) http.Handler {
 
 		httpRequestsTotal.WithLabelValues(r.Method, path, status).Inc()
 		httpRequestDuration.WithLabelValues(r.Method, path).Observe(duration)
+		httpResponseSize.WithLabelValues(r.Method, routePattern(r)).Observe(float64(wrapped.bytesWritten))
 	})
 }
 
+// routePattern returns the chi route pattern r matched, such as
+// /api/matches/{matchId}/metrics, or "unmatched" if it matched none, so
+// per-match paths share one series.
+func routePattern(r *http.Request) string {
+	if rctx := chi.RouteContext(r.Context()); rctx != nil {
+		if pattern := rctx.RoutePattern(); pattern != "" {
+			return pattern
+		}
+	}
+	return "unmatched"
+}
+
 // RequestLogger returns middleware that logs HTTP requests using structured logging.
 func RequestLogger(logger *slog.Logger) func(next http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
@@ -149,16 +327,191 @@ func RequestLogger(logger *slog.Logger) func(next http.Handler) http.Handler {
 	}
 }
 
+// loggerKey is the context key for the request-scoped logger.
+type loggerKey struct{}
+
+// WithLogger returns a copy of ctx carrying logger.
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// LoggerFromContext returns the request-scoped logger stored in ctx,
+// or slog.Default if none was set.
+func LoggerFromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
+
+// ContextLogger returns middleware that stores a child of logger tagged with the
+// request id in the request context. It must run after middleware.RequestID.
+func ContextLogger(logger *slog.Logger) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			child := logger.With(slog.String("request_id", middleware.GetReqID(r.Context())))
+			next.ServeHTTP(w, r.WithContext(WithLogger(r.Context(), child)))
+		})
+	}
+}
+
+// PrettyQueryParam and PrettyHeader request an indented JSON response.
+const (
+	PrettyQueryParam = "pretty"
+	PrettyHeader     = "X-Pretty"
+)
+
+// prettyIndent is the indentation used for pretty-printed responses.
+const prettyIndent = "  "
+
+// prettyWriter marks a response whose JSON body should be indented.
+type prettyWriter struct {
+	http.ResponseWriter
+}
+
+// Unwrap returns the underlying ResponseWriter for middleware compatibility.
+func (pw *prettyWriter) Unwrap() http.ResponseWriter {
+	return pw.ResponseWriter
+}
+
+// isPretty reports whether w, or a ResponseWriter it wraps, was marked by PrettyJSON.
+func isPretty(w http.ResponseWriter) bool {
+	for {
+		switch rw := w.(type) {
+		case *prettyWriter:
+			return true
+		case interface{ Unwrap() http.ResponseWriter }:
+			w = rw.Unwrap()
+		default:
+			return false
+		}
+	}
+}
+
+// PrettyJSON is middleware that indents the JSON response to a GET request
+// carrying ?pretty=true or an X-Pretty: true header, for reading responses
+// while debugging an integration. Other requests are left compact.
+func PrettyJSON(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet && wantsPretty(r) {
+			w = &prettyWriter{ResponseWriter: w}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// wantsPretty reports whether r asks for an indented response.
+func wantsPretty(r *http.Request) bool {
+	value := r.URL.Query().Get(PrettyQueryParam)
+	if value == "" {
+		value = r.Header.Get(PrettyHeader)
+	}
+	pretty, _ := strconv.ParseBool(value)
+	return pretty
+}
+
+// DecompressRequest returns middleware that decodes gzip or deflate request bodies
+// indicated by Content-Encoding. Bodies are decompressed up front, up to maxBytes,
+// so malformed streams get 400 and oversized ones 413 before reaching the handler.
+// Unsupported codings get 415.
+func DecompressRequest(maxBytes int64) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			encoding := strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding")))
+			if encoding == "" || encoding == "identity" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			var reader io.ReadCloser
+			var err error
+			switch encoding {
+			case "gzip", "x-gzip":
+				reader, err = gzip.NewReader(r.Body)
+			case "deflate":
+				reader, err = zlib.NewReader(r.Body)
+			default:
+				respondError(w, http.StatusUnsupportedMediaType, "unsupported Content-Encoding "+encoding, "Content-Encoding")
+				return
+			}
+			if err != nil {
+				respondError(w, http.StatusBadRequest, "malformed "+encoding+" body", "")
+				return
+			}
+			defer reader.Close()
+
+			// Read one byte past the cap to tell an exact fit from an overflow
+			body, err := io.ReadAll(io.LimitReader(reader, maxBytes+1))
+			if err != nil {
+				respondError(w, http.StatusBadRequest, "malformed "+encoding+" body", "")
+				return
+			}
+			if int64(len(body)) > maxBytes {
+				respondError(w, http.StatusRequestEntityTooLarge, "decompressed body exceeds "+strconv.FormatInt(maxBytes, 10)+" bytes", "")
+				return
+			}
+
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			r.ContentLength = int64(len(body))
+			r.Header.Del("Content-Encoding")
+			r.Header.Del("Content-Length")
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireAdminToken returns middleware that rejects requests whose bearer token
+// does not match the configured admin token. Tokens are compared in constant time.
+func RequireAdminToken(token string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			provided := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if token == "" || subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+				respondError(w, http.StatusUnauthorized, "invalid or missing admin token", "")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
 // RecordEventIngested increments the event ingestion counter.
 func RecordEventIngested(eventType string) {
 	eventsIngestedTotal.WithLabelValues(eventType).Inc()
 }
 
+// rejectionFields bounds the field label of eventsRejectedTotal.
+var rejectionFields = map[string]bool{
+	"eventId":   true,
+	"matchId":   true,
+	"eventType": true,
+	"timestamp": true,
+	"teamId":    true,
+
+	"metadata.minute":          true,
+	"metadata.correctsEventId": true,
+	"metadata.action":          true,
+}
+
+// RecordEventRejected increments the rejection counter for a validation field.
+// Fields outside the known set are recorded as "other" to keep cardinality bounded.
+func RecordEventRejected(field string) {
+	if !rejectionFields[field] {
+		field = "other"
+	}
+	eventsRejectedTotal.WithLabelValues(field).Inc()
+}
+
 // RecordEventIngestDuration records the duration of event ingestion.
 func RecordEventIngestDuration(duration time.Duration) {
 	eventIngestDuration.Observe(duration.Seconds())
 }
 
+// RecordIngestShed increments the counter of events shed by the produce limiter.
+func RecordIngestShed() {
+	eventsShedTotal.Inc()
+}
+
 // RecordKafkaProduceError increments the Kafka produce error counter.
 func RecordKafkaProduceError() {
 	kafkaProduceErrorsTotal.Inc()
@@ -1,8 +1,22 @@
 package api
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+
+	"fanfinity/internal/domain"
 )
 
 func TestNewResponseTimeTracker(t *testing.T) {
@@ -205,3 +219,205 @@ func BenchmarkPercentile(b *testing.B) {
 		_ = percentile(sorted, 95)
 	}
 }
+
+func TestIngestEvent_RecordsRejectionByField(t *testing.T) {
+	valid := func() map[string]interface{} {
+		return map[string]interface{}{
+			"eventId":   "550e8400-e29b-41d4-a716-446655440000",
+			"matchId":   "match-123",
+			"eventType": "goal",
+			"timestamp": "2024-01-15T14:30:00Z",
+			"teamId":    1,
+		}
+	}
+
+	tests := []struct {
+		field string
+		value interface{}
+	}{
+		{field: "eventId", value: "not-a-uuid"},
+		{field: "matchId", value: ""},
+		{field: "eventType", value: "dance"},
+		{field: "timestamp", value: "yesterday"},
+		{field: "teamId", value: 3},
+	}
+
+	// Validation fails before the producer is used, so no producer is needed
+	handler := NewHandler(nil, nil)
+
+	for _, tt := range tests {
+		t.Run(tt.field, func(t *testing.T) {
+			body := valid()
+			body[tt.field] = tt.value
+			data, err := json.Marshal(body)
+			if err != nil {
+				t.Fatalf("failed to marshal body: %v", err)
+			}
+
+			before := testutil.ToFloat64(eventsRejectedTotal.WithLabelValues(tt.field))
+
+			req := httptest.NewRequest(http.MethodPost, "/api/events", bytes.NewReader(data))
+			req.Header.Set("Content-Type", "application/json")
+			rr := httptest.NewRecorder()
+			handler.IngestEvent(rr, req)
+
+			if rr.Code != http.StatusUnprocessableEntity {
+				t.Fatalf("expected status %d, got %d", http.StatusUnprocessableEntity, rr.Code)
+			}
+			if got := testutil.ToFloat64(eventsRejectedTotal.WithLabelValues(tt.field)) - before; got != 1 {
+				t.Errorf("expected %s rejection counter to increase by 1, got %v", tt.field, got)
+			}
+		})
+	}
+}
+
+func TestRecordEventRejected_UnknownFieldBucketed(t *testing.T) {
+	before := testutil.ToFloat64(eventsRejectedTotal.WithLabelValues("other"))
+
+	RecordEventRejected("someUnexpectedField")
+
+	if got := testutil.ToFloat64(eventsRejectedTotal.WithLabelValues("other")) - before; got != 1 {
+		t.Errorf("expected other counter to increase by 1, got %v", got)
+	}
+}
+
+func TestPrometheusMiddleware_RecordsResponseSize(t *testing.T) {
+	const pattern = "/api/matches/{matchId}/size-metrics"
+	metrics := &domain.MatchMetrics{
+		MatchID:      "match-size",
+		TotalEvents:  42,
+		EventsByType: map[string]int64{"pass": 30, "shot": 12},
+	}
+	router := chi.NewRouter()
+	router.Use(PrometheusMiddleware)
+	router.Get(pattern, func(w http.ResponseWriter, r *http.Request) {
+		respondJSON(w, http.StatusOK, metrics)
+	})
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/matches/match-size/size-metrics", nil))
+
+	// Labelled with the route pattern, not the per-match path
+	var m dto.Metric
+	if err := httpResponseSize.WithLabelValues(http.MethodGet, pattern).(prometheus.Histogram).Write(&m); err != nil {
+		t.Fatalf("failed to read histogram: %v", err)
+	}
+	if got := m.GetHistogram().GetSampleCount(); got != 1 {
+		t.Fatalf("expected 1 observation, got %d", got)
+	}
+	if got := m.GetHistogram().GetSampleSum(); got == 0 || got != float64(rr.Body.Len()) {
+		t.Errorf("expected the %d-byte body to be observed, got %v", rr.Body.Len(), got)
+	}
+}
+
+func TestLoggerFromContext(t *testing.T) {
+	if got := LoggerFromContext(context.Background()); got != slog.Default() {
+		t.Error("expected default logger when none is set")
+	}
+
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+	if got := LoggerFromContext(WithLogger(context.Background(), logger)); got != logger {
+		t.Error("expected logger stored in context")
+	}
+}
+
+func TestRequestTimeout_LateWriteBecomesClean504(t *testing.T) {
+	handler := RequestTimeout(20 * time.Millisecond)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(50 * time.Millisecond)
+		w.Header().Set("ETag", `W/"late"`)
+		respondJSON(w, http.StatusOK, map[string]string{"status": "late"})
+	}))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/slow", nil))
+
+	if rr.Code != http.StatusGatewayTimeout {
+		t.Fatalf("expected status %d, got %d", http.StatusGatewayTimeout, rr.Code)
+	}
+	if rr.Header().Get("ETag") != "" {
+		t.Error("expected late handler headers to be dropped")
+	}
+
+	var errResp ErrorResponse
+	decoder := json.NewDecoder(rr.Body)
+	if err := decoder.Decode(&errResp); err != nil {
+		t.Fatalf("expected JSON error body: %v", err)
+	}
+	if decoder.More() {
+		t.Errorf("expected only the 504 body, got trailing data")
+	}
+}
+
+func TestRequestTimeout_HandlerReturnsWithoutWriting(t *testing.T) {
+	handler := RequestTimeout(20 * time.Millisecond)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/slow", nil))
+
+	if rr.Code != http.StatusGatewayTimeout {
+		t.Fatalf("expected status %d, got %d", http.StatusGatewayTimeout, rr.Code)
+	}
+	if !strings.Contains(rr.Body.String(), "request timed out") {
+		t.Errorf("expected JSON timeout message, got %q", rr.Body.String())
+	}
+}
+
+func TestRequestTimeout_FastHandlerUnaffected(t *testing.T) {
+	handler := RequestTimeout(time.Second)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		respondJSON(w, http.StatusCreated, map[string]string{"status": "ok"})
+	}))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/fast", nil))
+
+	if rr.Code != http.StatusCreated {
+		t.Errorf("expected status %d, got %d", http.StatusCreated, rr.Code)
+	}
+}
+
+func TestPrettyJSON(t *testing.T) {
+	tests := []struct {
+		name       string
+		allow      bool
+		target     string
+		header     string
+		wantPretty bool
+	}{
+		{name: "query param", allow: true, target: "/health?pretty=true", wantPretty: true},
+		{name: "header", allow: true, target: "/health", header: "true", wantPretty: true},
+		{name: "not requested", allow: true, target: "/health"},
+		{name: "explicitly off", allow: true, target: "/health?pretty=false"},
+		{name: "not allowed", target: "/health?pretty=true", header: "true"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultHandlerConfig()
+			cfg.AllowPrettyJSON = tt.allow
+			router := NewRouterWithConfig(nil, nil, slog.Default(), cfg)
+
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			if tt.header != "" {
+				req.Header.Set(PrettyHeader, tt.header)
+			}
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			if rr.Code != http.StatusOK {
+				t.Fatalf("expected status 200, got %d", rr.Code)
+			}
+			body := rr.Body.String()
+			if !json.Valid(rr.Body.Bytes()) {
+				t.Fatalf("expected valid JSON, got %q", body)
+			}
+			if pretty := strings.Contains(body, "\n"+prettyIndent+`"`); pretty != tt.wantPretty {
+				t.Errorf("expected pretty=%v, got body %q", tt.wantPretty, body)
+			}
+			if !tt.wantPretty && strings.Count(body, "\n") != 1 {
+				t.Errorf("expected compact single-line JSON, got %q", body)
+			}
+		})
+	}
+}
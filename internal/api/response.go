@@ -2,7 +2,10 @@ package api
 
 import (
 	"encoding/json"
+	"mime"
 	"net/http"
+	"strings"
+	"time"
 )
 
 // ErrorResponse represents a standardized error response.
@@ -10,14 +13,64 @@ type ErrorResponse struct {
 	Error   string `json:"error"`
 	Message string `json:"message"`
 	Field   string `json:"field,omitempty"`
+	Code    string `json:"code,omitempty"`
+}
+
+// Machine-readable error codes returned in ErrorResponse.Code.
+const (
+	ErrCodeEmptyBody            = "EMPTY_BODY"
+	ErrCodeUnsupportedMediaType = "UNSUPPORTED_MEDIA_TYPE"
+	ErrCodeProducerTimeout      = "PRODUCER_TIMEOUT"
+	ErrCodeNotFound             = "NOT_FOUND"
+	ErrCodeMethodNotAllowed     = "METHOD_NOT_ALLOWED"
+	ErrCodeMatchLagging         = "MATCH_LAGGING"
+)
+
+// PagedResponse is the envelope for list endpoints. Count is the number of
+// items in Data. When HasMore is set, passing NextCursor as the cursor query
+// parameter fetches the next page.
+type PagedResponse[T any] struct {
+	Data       []T    `json:"data"`
+	Count      int    `json:"count"`
+	NextCursor string `json:"nextCursor,omitempty"`
+	HasMore    bool   `json:"hasMore"`
+}
+
+// NewPagedResponse builds a page of at most limit items. Handlers fetch
+// limit+1 items so an extra item signals that another page exists; next
+// derives the cursor of the following page from the last item returned. next
+// may be nil for lists that cannot be continued, leaving NextCursor empty.
+func NewPagedResponse[T any](items []T, limit int, next func(last T) string) PagedResponse[T] {
+	page := PagedResponse[T]{Data: items}
+	if page.Data == nil {
+		page.Data = []T{}
+	}
+	if limit > 0 && len(page.Data) > limit {
+		page.Data = page.Data[:limit]
+		page.HasMore = true
+		if next != nil {
+			page.NextCursor = next(page.Data[limit-1])
+		}
+	}
+	page.Count = len(page.Data)
+	return page
 }
 
 // respondJSON writes a JSON response with the given status code and data.
+// The body is indented when the response was marked by PrettyJSON.
 func respondJSON(w http.ResponseWriter, status int, data interface{}) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
 
 	if data != nil {
+		if isPretty(w) {
+			body, err := json.MarshalIndent(data, "", prettyIndent)
+			if err != nil {
+				return
+			}
+			_, _ = w.Write(append(body, '\n'))
+			return
+		}
 		if err := json.NewEncoder(w).Encode(data); err != nil {
 			// If encoding fails, we've already written the header,
 			// so we can only log this error (handled by caller's middleware)
@@ -26,6 +79,49 @@ func respondJSON(w http.ResponseWriter, status int, data interface{}) {
 	}
 }
 
+// respondJSONWithETag writes data as a 200 JSON response tagged with etag, or a
+// bodyless 304 when the request's If-None-Match already matches it.
+func respondJSONWithETag(w http.ResponseWriter, r *http.Request, data interface{}, etag, cacheControl string) {
+	w.Header().Set("ETag", etag)
+	if cacheControl != "" {
+		w.Header().Set("Cache-Control", cacheControl)
+	}
+
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	marshal := json.Marshal
+	if isPretty(w) {
+		marshal = func(v any) ([]byte, error) { return json.MarshalIndent(v, "", prettyIndent) }
+	}
+	body, err := marshal(data)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to encode response", "")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(append(body, '\n'))
+}
+
+// etagMatches reports whether an If-None-Match header value matches etag using
+// weak comparison, as required for If-None-Match (RFC 9110 section 13.1.2).
+func etagMatches(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	want := strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == want {
+			return true
+		}
+	}
+	return false
+}
+
 // respondError creates an ErrorResponse and sends it as JSON.
 func respondError(w http.ResponseWriter, status int, message, detail string) {
 	resp := ErrorResponse{
@@ -47,3 +143,42 @@ func respondErrorWithField(w http.ResponseWriter, status int, message, field str
 	}
 	respondJSON(w, status, resp)
 }
+
+// writeDeadlineMargin is the time allowed to write a response after the
+// work a handler waits on is done.
+const writeDeadlineMargin = 5 * time.Second
+
+// extendWriteDeadline moves the connection's write deadline to d plus
+// writeDeadlineMargin from now, for handlers that may legitimately take longer
+// than the server WriteTimeout. It is a no-op for writers that cannot set
+// deadlines, such as test recorders.
+func extendWriteDeadline(w http.ResponseWriter, d time.Duration) {
+	_ = http.NewResponseController(w).SetWriteDeadline(time.Now().Add(d + writeDeadlineMargin))
+}
+
+// extendReadDeadline moves the connection's read deadline to d from now, for
+// request bodies that may take longer to upload than the server ReadTimeout.
+func extendReadDeadline(w http.ResponseWriter, d time.Duration) {
+	_ = http.NewResponseController(w).SetReadDeadline(time.Now().Add(d))
+}
+
+// requireContentType responds 415 and returns false unless the request's
+// Content-Type is mediaType. Parameters such as charset are allowed.
+func requireContentType(w http.ResponseWriter, r *http.Request, mediaType string) bool {
+	got, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if got == mediaType {
+		return true
+	}
+	respondErrorWithCode(w, http.StatusUnsupportedMediaType, "request body must be "+mediaType, ErrCodeUnsupportedMediaType)
+	return false
+}
+
+// respondErrorWithCode creates an ErrorResponse with a machine-readable code and sends it as JSON.
+func respondErrorWithCode(w http.ResponseWriter, status int, message, code string) {
+	resp := ErrorResponse{
+		Error:   http.StatusText(status),
+		Message: message,
+		Code:    code,
+	}
+	respondJSON(w, status, resp)
+}
@@ -1,58 +1,269 @@
 package api
 
 import (
+	"crypto/tls"
+	"fmt"
 	"log/slog"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 )
 
+// requestTimeout bounds every public route except imports and exports.
+const requestTimeout = 30 * time.Second
+
 // NewRouter creates and configures a new chi router with all routes and middleware.
 func NewRouter(producer EventProducer, repository MetricsRepository, logger *slog.Logger) *chi.Mux {
+	return NewRouterWithConfig(producer, repository, logger, DefaultHandlerConfig())
+}
+
+// NewRouterWithConfig creates a chi router whose handler uses the given configuration.
+func NewRouterWithConfig(producer EventProducer, repository MetricsRepository, logger *slog.Logger, cfg HandlerConfig) *chi.Mux {
 	r := chi.NewRouter()
 
+	// JSON errors for unknown routes and methods, set before any route so
+	// every subrouter inherits them
+	r.NotFound(routeNotFound)
+	r.MethodNotAllowed(methodNotAllowed(r))
+
 	// Apply middleware stack
 	r.Use(middleware.RequestID)
+	r.Use(ContextLogger(logger))
 	r.Use(middleware.RealIP)
 	r.Use(RequestLogger(logger))
-	r.Use(PrometheusMiddleware)
-	r.Use(middleware.Recoverer)
-	r.Use(middleware.Timeout(30 * time.Second))
 
 	// Create handler
-	h := NewHandler(producer, repository)
+	h := NewHandlerWithConfig(producer, repository, cfg)
 
-	// Health check endpoints (outside /api prefix)
-	r.Get("/health", h.HealthCheck)
-	r.Get("/ready", h.ReadinessCheck)
+	basePath := normalizeBasePath(cfg.BasePath)
+	opsAtRoot := basePath == "" || cfg.OpsAtRoot
 
-	// Prometheus metrics endpoint
-	r.Handle("/metrics", promhttp.Handler())
+	// Health, readiness and Prometheus endpoints (outside the /api prefix)
+	opsRoutes := func(r chi.Router) {
+		r.Group(func(r chi.Router) {
+			r.Use(RequestTimeout(requestTimeout))
+			r.Get("/health", h.HealthCheck)
+			r.Get("/ready", h.ReadinessCheck)
+			r.Get("/healthz/deep", h.DeepHealthCheck)
+			r.Handle("/metrics", promhttp.Handler())
+		})
+	}
 
 	// API routes
-	r.Route("/api", func(r chi.Router) {
-		// Event ingestion
-		r.Post("/events", h.IngestEvent)
+	apiRoutes := func(r chi.Router) {
+		r.Route("/api", func(r chi.Router) {
+			// Bulk transfers run for as long as a large file takes, bounded by
+			// the stream timeout instead of the request timeout. Imports may
+			// decompress up to the import size limit.
+			r.Group(func(r chi.Router) {
+				r.Use(StreamDeadlines(h.config.StreamTimeout))
+				r.With(DecompressRequest(h.config.MaxImportBytes)).Post("/events/import", h.ImportEvents)
+				r.Get("/matches/{matchId}/export", h.ExportMatch)
+			})
+
+			r.Group(func(r chi.Router) {
+				r.Use(RequestTimeout(requestTimeout))
+				r.Use(DecompressRequest(h.config.MaxDecompressedBytes))
 
-		// Match metrics
-		r.Get("/matches/{matchId}/metrics", h.GetMatchMetrics)
+				// Event ingestion
+				r.Post("/events", h.IngestEvent)
+				r.Put("/events/{eventId}", h.UpsertEvent)
+				if cfg.Confirmations != nil {
+					r.Get("/events/{eventId}/status", h.EventStatus)
+				}
+
+				// Match details shown alongside metrics
+				r.Post("/matches/{matchId}", h.RegisterMatchInfo)
+
+				// Match metrics
+				r.Get("/matches/{matchId}/metrics", h.GetMatchMetrics)
+				r.Head("/matches/{matchId}/metrics", h.HeadMatchMetrics)
+				r.Get("/matches/{matchId}/matrix", h.GetEventMatrix)
+				r.Get("/matches/{matchId}/distribution", h.GetEventDistribution)
+				r.Get("/matches/{matchId}/conversion", h.GetConversionStats)
+				r.Get("/matches/{matchId}/timeline/{teamId}", h.GetTeamTimeline)
+				r.Get("/matches/{matchId}/rate", h.GetEventRate)
+				r.Get("/matches/{matchId}/events/search", h.SearchEvents)
+
+				// Rankings across matches
+				r.Get("/leaderboard", h.GetLeaderboard)
+
+				// Admin operations, only mounted when an admin token is configured
+				if cfg.AdminToken != "" {
+					r.Route("/admin", func(r chi.Router) {
+						r.Use(RequireAdminToken(cfg.AdminToken))
+						r.Post("/matches/{matchId}/replay", h.ReplayMatch)
+						r.Post("/matches/{matchId}/close", h.CloseMatch)
+						if cfg.ProducedCounter != nil {
+							r.Get("/matches/{matchId}/consistency", h.CheckConsistency)
+						}
+						if cfg.MessageInspector != nil {
+							r.Get("/messages", h.InspectMessages)
+						}
+						if cfg.OffsetResetter != nil {
+							r.Post("/consumer/offsets/reset", h.ResetConsumerOffsets)
+						}
+						if cfg.MetadataPolicyLoader != nil && cfg.Validation.RequiredMetadata != nil {
+							r.Post("/metadata-policy/reload", h.ReloadMetadataPolicy)
+						}
+					})
+				}
+			})
+		})
+	}
+
+	// Public routes share request metrics; each route group applies its own
+	// timeout
+	r.Group(func(r chi.Router) {
+		r.Use(PrometheusMiddleware)
+		r.Use(middleware.Recoverer)
+		if cfg.AllowPrettyJSON {
+			r.Use(PrettyJSON)
+		}
+
+		if opsAtRoot {
+			opsRoutes(r)
+		}
+
+		if basePath == "" {
+			apiRoutes(r)
+			return
+		}
+		r.Route(basePath, func(r chi.Router) {
+			if !opsAtRoot {
+				opsRoutes(r)
+			}
+			apiRoutes(r)
+		})
 	})
 
+	// Profiling routes sit outside the public middleware so long-running CPU
+	// profiles and traces are not cut off by the request timeout
+	if cfg.EnablePprof {
+		r.Mount("/debug", NewDebugRouter())
+	}
+
 	return r
 }
 
+// routeMethods are the methods checked when listing a route's Allow header.
+var routeMethods = []string{
+	http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut,
+	http.MethodPatch, http.MethodDelete, http.MethodOptions,
+}
+
+// routeNotFound responds to requests matching no route with a JSON 404.
+func routeNotFound(w http.ResponseWriter, r *http.Request) {
+	respondErrorWithCode(w, http.StatusNotFound, "no route for "+r.URL.Path, ErrCodeNotFound)
+}
+
+// methodNotAllowed returns a handler responding with a JSON 405 and an Allow
+// header listing the methods mux routes for the request path.
+func methodNotAllowed(mux *chi.Mux) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		path := r.URL.RawPath
+		if path == "" {
+			path = r.URL.Path
+		}
+		var allowed []string
+		for _, method := range routeMethods {
+			if mux.Match(chi.NewRouteContext(), method, path) {
+				allowed = append(allowed, method)
+			}
+		}
+		w.Header().Set("Allow", strings.Join(allowed, ", "))
+		respondErrorWithCode(w, http.StatusMethodNotAllowed,
+			r.Method+" is not allowed on "+r.URL.Path, ErrCodeMethodNotAllowed)
+	}
+}
+
+// normalizeBasePath turns a configured base path such as "fanfinity/" into
+// "/fanfinity". An empty path or "/" mounts routes at the root and yields "".
+func normalizeBasePath(path string) string {
+	path = strings.Trim(strings.TrimSpace(path), "/")
+	if path == "" {
+		return ""
+	}
+	return "/" + path
+}
+
 // NewServer creates a new HTTP server with the configured router.
 func NewServer(addr string, producer EventProducer, repository MetricsRepository, logger *slog.Logger) *http.Server {
-	router := NewRouter(producer, repository, logger)
+	// The default configuration serves plain HTTP/1 and cannot fail
+	server, _ := NewServerWithConfig(addr, NewRouter(producer, repository, logger), DefaultServerConfig())
+	return server
+}
+
+// ServerConfig holds HTTP server connection settings.
+type ServerConfig struct {
+	ReadTimeout       time.Duration
+	ReadHeaderTimeout time.Duration
+	WriteTimeout      time.Duration
+
+	// IdleTimeout closes keep-alive connections, HTTP/1 or HTTP/2, after this
+	// long without a request.
+	IdleTimeout time.Duration
+
+	// DisableKeepAlives closes each HTTP/1 connection after one request.
+	DisableKeepAlives bool
+
+	// HTTP2 serves HTTP/2 alongside HTTP/1. Over TLS it is negotiated with
+	// ALPN; without TLS the server accepts cleartext HTTP/2 (h2c), for use
+	// behind a proxy that speaks h2c to its backends. When false, only HTTP/1
+	// is served, even over TLS.
+	HTTP2 bool
+
+	// TLS reports that the server will be started with ListenAndServeTLS.
+	TLS bool
 
-	return &http.Server{
-		Addr:         addr,
-		Handler:      router,
+	// MaxConcurrentStreams bounds the requests in flight on one HTTP/2
+	// connection. Zero uses the http2 package default.
+	MaxConcurrentStreams uint32
+}
+
+// DefaultServerConfig returns the default server settings, serving HTTP/1 only.
+func DefaultServerConfig() ServerConfig {
+	return ServerConfig{
 		ReadTimeout:  15 * time.Second,
 		WriteTimeout: 30 * time.Second,
 		IdleTimeout:  60 * time.Second,
 	}
 }
+
+// NewServerWithConfig creates an HTTP server for handler with the given settings.
+func NewServerWithConfig(addr string, handler http.Handler, cfg ServerConfig) (*http.Server, error) {
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadTimeout:       cfg.ReadTimeout,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+	}
+	server.SetKeepAlivesEnabled(!cfg.DisableKeepAlives)
+
+	if !cfg.HTTP2 {
+		// A non-nil empty map stops net/http enabling HTTP/2 over TLS
+		server.TLSNextProto = map[string]func(*http.Server, *tls.Conn, http.Handler){}
+		return server, nil
+	}
+
+	h2 := &http2.Server{
+		MaxConcurrentStreams: cfg.MaxConcurrentStreams,
+		IdleTimeout:          cfg.IdleTimeout,
+	}
+	if cfg.TLS {
+		if err := http2.ConfigureServer(server, h2); err != nil {
+			return nil, fmt.Errorf("failed to configure HTTP/2: %w", err)
+		}
+		return server, nil
+	}
+	server.Handler = h2c.NewHandler(handler, h2)
+	return server, nil
+}
@@ -0,0 +1,217 @@
+package api_test
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"golang.org/x/net/http2"
+
+	"fanfinity/internal/api"
+	"fanfinity/internal/domain"
+)
+
+func TestRouter_BasePath(t *testing.T) {
+	tests := []struct {
+		name      string
+		basePath  string
+		opsAtRoot bool
+		want      map[string]int
+	}{
+		{
+			name:     "default serves at the root",
+			basePath: "",
+			want: map[string]int{
+				"/api/matches/match-123/metrics": http.StatusOK,
+				"/health":                        http.StatusOK,
+			},
+		},
+		{
+			name:      "prefixed with ops at the root",
+			basePath:  "/fanfinity/",
+			opsAtRoot: true,
+			want: map[string]int{
+				"/fanfinity/api/matches/match-123/metrics": http.StatusOK,
+				"/api/matches/match-123/metrics":           http.StatusNotFound,
+				"/health":                                  http.StatusOK,
+				"/fanfinity/health":                        http.StatusNotFound,
+			},
+		},
+		{
+			name:      "prefixed with ops under the prefix",
+			basePath:  "fanfinity",
+			opsAtRoot: false,
+			want: map[string]int{
+				"/fanfinity/api/matches/match-123/metrics": http.StatusOK,
+				"/api/matches/match-123/metrics":           http.StatusNotFound,
+				"/fanfinity/health":                        http.StatusOK,
+				"/fanfinity/metrics":                       http.StatusOK,
+				"/health":                                  http.StatusNotFound,
+			},
+		},
+	}
+
+	repo := &MockRepository{
+		GetMatchMetricsFunc: func(ctx context.Context, matchID string) (*domain.MatchMetrics, error) {
+			return &domain.MatchMetrics{MatchID: matchID}, nil
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := api.DefaultHandlerConfig()
+			cfg.BasePath = tt.basePath
+			cfg.OpsAtRoot = tt.opsAtRoot
+			router := api.NewRouterWithConfig(&MockProducer{}, repo, slog.Default(), cfg)
+
+			for path, wantStatus := range tt.want {
+				rr := httptest.NewRecorder()
+				router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, path, nil))
+				if rr.Code != wantStatus {
+					t.Errorf("GET %s: expected status %d, got %d", path, wantStatus, rr.Code)
+				}
+			}
+		})
+	}
+}
+
+func TestRouter_JSONRoutingErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		basePath   string
+		method     string
+		path       string
+		wantStatus int
+		wantCode   string
+		wantAllow  string
+	}{
+		{name: "wrong method", method: http.MethodGet, path: "/api/events", wantStatus: http.StatusMethodNotAllowed, wantCode: api.ErrCodeMethodNotAllowed, wantAllow: "POST"},
+		{name: "wrong method with path parameter", method: http.MethodDelete, path: "/api/matches/match-123/metrics", wantStatus: http.StatusMethodNotAllowed, wantCode: api.ErrCodeMethodNotAllowed, wantAllow: "GET, HEAD"},
+		{name: "wrong method under base path", basePath: "/fanfinity", method: http.MethodGet, path: "/fanfinity/api/events", wantStatus: http.StatusMethodNotAllowed, wantCode: api.ErrCodeMethodNotAllowed, wantAllow: "POST"},
+		{name: "unknown route", method: http.MethodGet, path: "/api/unknown", wantStatus: http.StatusNotFound, wantCode: api.ErrCodeNotFound},
+		{name: "unknown route under base path", basePath: "/fanfinity", method: http.MethodGet, path: "/fanfinity/api/unknown", wantStatus: http.StatusNotFound, wantCode: api.ErrCodeNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := api.DefaultHandlerConfig()
+			cfg.BasePath = tt.basePath
+			router := api.NewRouterWithConfig(&MockProducer{}, &MockRepository{}, slog.Default(), cfg)
+
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, httptest.NewRequest(tt.method, tt.path, nil))
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d", tt.wantStatus, rr.Code)
+			}
+			if got := rr.Header().Get("Content-Type"); got != "application/json" {
+				t.Errorf("expected a JSON body, got Content-Type %q", got)
+			}
+			if got := rr.Header().Get("Allow"); got != tt.wantAllow {
+				t.Errorf("expected Allow %q, got %q", tt.wantAllow, got)
+			}
+			var resp api.ErrorResponse
+			if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+				t.Fatalf("failed to decode error response: %v", err)
+			}
+			if resp.Code != tt.wantCode || resp.Message == "" {
+				t.Errorf("expected code %s with a message, got %+v", tt.wantCode, resp)
+			}
+		})
+	}
+}
+
+// h2cClient speaks cleartext HTTP/2 with prior knowledge.
+func h2cClient() *http.Client {
+	return &http.Client{Transport: &http2.Transport{
+		AllowHTTP: true,
+		DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network, addr)
+		},
+	}}
+}
+
+func TestNewServerWithConfig_HTTP2(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	t.Run("h2c", func(t *testing.T) {
+		cfg := api.DefaultServerConfig()
+		cfg.HTTP2 = true
+		server, err := api.NewServerWithConfig("", handler, cfg)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		ts := httptest.NewUnstartedServer(nil)
+		ts.Config = server
+		ts.Start()
+		defer ts.Close()
+
+		resp, err := h2cClient().Get(ts.URL)
+		if err != nil {
+			t.Fatalf("h2c request failed: %v", err)
+		}
+		resp.Body.Close()
+		if resp.ProtoMajor != 2 {
+			t.Errorf("expected HTTP/2, got %s", resp.Proto)
+		}
+
+		// HTTP/1 clients are still served
+		resp, err = http.Get(ts.URL)
+		if err != nil {
+			t.Fatalf("HTTP/1 request failed: %v", err)
+		}
+		resp.Body.Close()
+		if resp.ProtoMajor != 1 {
+			t.Errorf("expected HTTP/1, got %s", resp.Proto)
+		}
+	})
+
+	t.Run("tls", func(t *testing.T) {
+		cfg := api.DefaultServerConfig()
+		cfg.HTTP2 = true
+		cfg.TLS = true
+		server, err := api.NewServerWithConfig("", handler, cfg)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		ts := httptest.NewUnstartedServer(nil)
+		ts.Config = server
+		ts.TLS = server.TLSConfig
+		ts.EnableHTTP2 = true
+		ts.StartTLS()
+		defer ts.Close()
+
+		resp, err := ts.Client().Get(ts.URL)
+		if err != nil {
+			t.Fatalf("TLS request failed: %v", err)
+		}
+		resp.Body.Close()
+		if resp.ProtoMajor != 2 {
+			t.Errorf("expected HTTP/2 to be negotiated, got %s", resp.Proto)
+		}
+	})
+
+	t.Run("disabled by default", func(t *testing.T) {
+		server, err := api.NewServerWithConfig("", handler, api.DefaultServerConfig())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		ts := httptest.NewUnstartedServer(nil)
+		ts.Config = server
+		ts.Start()
+		defer ts.Close()
+
+		if resp, err := h2cClient().Get(ts.URL); err == nil {
+			resp.Body.Close()
+			t.Errorf("expected h2c to be rejected, got %s", resp.Proto)
+		}
+	})
+}
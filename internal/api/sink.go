@@ -0,0 +1,25 @@
+package api
+
+import "fanfinity/internal/domain"
+
+// EventSink receives events accepted by IngestEvent, e.g. to notify a partner
+// webhook. Send is called on the request path, so it must not block: it queues
+// the event for delivery, dropping it if the queue is full.
+type EventSink interface {
+	Send(event *domain.Event)
+}
+
+// forwardToSinks hands event to every configured sink if its type is selected.
+// Sinks are called inline; they queue without blocking, so a slow sink never
+// delays the response.
+func (h *Handler) forwardToSinks(event *domain.Event) {
+	if len(h.config.EventSinks) == 0 {
+		return
+	}
+	if types := h.config.SinkEventTypes; len(types) > 0 && !types[event.EventType] {
+		return
+	}
+	for _, sink := range h.config.EventSinks {
+		sink.Send(event)
+	}
+}
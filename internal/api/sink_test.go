@@ -0,0 +1,139 @@
+package api_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"fanfinity/internal/api"
+	"fanfinity/internal/domain"
+)
+
+// MockSink implements api.EventSink, publishing each event it receives on a
+// channel.
+type MockSink struct {
+	events chan *domain.Event
+}
+
+func newMockSink() *MockSink {
+	return &MockSink{events: make(chan *domain.Event, 10)}
+}
+
+func (s *MockSink) Send(event *domain.Event) {
+	s.events <- event
+}
+
+func typedEventRequest(eventType string) *http.Request {
+	body, _ := json.Marshal(map[string]interface{}{
+		"eventId":   uuid.New().String(),
+		"matchId":   "match-123",
+		"eventType": eventType,
+		"timestamp": time.Now().UTC().Format(time.RFC3339),
+		"teamId":    1,
+	})
+	req := httptest.NewRequest(http.MethodPost, "/api/events", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	return req
+}
+
+func postTypedEvent(t *testing.T, router http.Handler, eventType string) *httptest.ResponseRecorder {
+	t.Helper()
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, typedEventRequest(eventType))
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("expected status 202, got %d: %s", rr.Code, rr.Body.String())
+	}
+	return rr
+}
+
+func TestIngestEvent_ForwardsSelectedTypesToSinks(t *testing.T) {
+	sink := newMockSink()
+	cfg := api.DefaultHandlerConfig()
+	cfg.EventSinks = []api.EventSink{sink}
+	cfg.SinkEventTypes = map[domain.EventType]bool{domain.EventTypeGoal: true}
+	router := api.NewRouterWithConfig(&MockProducer{}, &MockRepository{}, slog.Default(), cfg)
+
+	postTypedEvent(t, router, "pass")
+	postTypedEvent(t, router, "goal")
+	postTypedEvent(t, router, "shot")
+
+	select {
+	case event := <-sink.events:
+		if event.EventType != domain.EventTypeGoal {
+			t.Errorf("expected only goals to be forwarded, got %s", event.EventType)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the goal to be forwarded")
+	}
+	select {
+	case event := <-sink.events:
+		t.Errorf("expected a single forwarded event, also got %s", event.EventType)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestIngestEvent_ForwardsAllTypesWithoutFilter(t *testing.T) {
+	sink := newMockSink()
+	cfg := api.DefaultHandlerConfig()
+	cfg.EventSinks = []api.EventSink{sink}
+	router := api.NewRouterWithConfig(&MockProducer{}, &MockRepository{}, slog.Default(), cfg)
+
+	postTypedEvent(t, router, "pass")
+	postTypedEvent(t, router, "goal")
+
+	for i := 0; i < 2; i++ {
+		select {
+		case <-sink.events:
+		case <-time.After(time.Second):
+			t.Fatalf("expected 2 forwarded events, got %d", i)
+		}
+	}
+}
+
+func TestIngestEvent_ForwardsBeforeResponding(t *testing.T) {
+	sink := newMockSink()
+	cfg := api.DefaultHandlerConfig()
+	cfg.EventSinks = []api.EventSink{sink}
+	router := api.NewRouterWithConfig(&MockProducer{}, &MockRepository{}, slog.Default(), cfg)
+
+	postTypedEvent(t, router, "goal")
+
+	// Sinks are called inline, so the event is queued once the response is written
+	select {
+	case <-sink.events:
+	default:
+		t.Fatal("expected the event to reach the sink before the response")
+	}
+}
+
+func TestIngestEvent_FailedProduceIsNotForwarded(t *testing.T) {
+	sink := newMockSink()
+	cfg := api.DefaultHandlerConfig()
+	cfg.EventSinks = []api.EventSink{sink}
+	producer := &MockProducer{ProduceFunc: func(ctx context.Context, event *domain.Event) error {
+		return errors.New("broker down")
+	}}
+	router := api.NewRouterWithConfig(producer, &MockRepository{}, slog.Default(), cfg)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/events", bytes.NewReader(validEventJSON()))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected status 503, got %d", rr.Code)
+	}
+
+	select {
+	case event := <-sink.events:
+		t.Errorf("expected no forwarded event, got %s", event.EventID)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
@@ -0,0 +1,81 @@
+package api
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultWarmupInterval is how often a failing warmup check is retried.
+const DefaultWarmupInterval = time.Second
+
+// Warmup gates readiness on a startup self-check, such as a query against the
+// events table. Until the check has succeeded once, GET /ready reports not
+// ready; after that the warmed state is cached and the check is not run again.
+type Warmup struct {
+	check HealthChecker
+	ready atomic.Bool
+
+	mu      sync.Mutex
+	lastErr error
+}
+
+// NewWarmup creates a Warmup that becomes ready once check succeeds.
+func NewWarmup(check HealthChecker) *Warmup {
+	return &Warmup{check: check}
+}
+
+// Ready reports whether the warmup check has succeeded.
+func (w *Warmup) Ready() bool {
+	return w.ready.Load()
+}
+
+// Err returns the error from the last failed check, or nil.
+func (w *Warmup) Err() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.lastErr
+}
+
+// Check runs the warmup check once unless it has already succeeded, and
+// reports whether the service is warmed up.
+func (w *Warmup) Check(ctx context.Context) bool {
+	if w.Ready() {
+		return true
+	}
+	err := w.check.Ping(ctx)
+
+	w.mu.Lock()
+	w.lastErr = err
+	w.mu.Unlock()
+	if err != nil {
+		return false
+	}
+	w.ready.Store(true)
+	return true
+}
+
+// Run checks immediately and then every interval until the check succeeds or
+// ctx is done. It reports whether the service warmed up.
+func (w *Warmup) Run(ctx context.Context, interval time.Duration) bool {
+	if interval <= 0 {
+		interval = DefaultWarmupInterval
+	}
+	if w.Check(ctx) {
+		return true
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-ticker.C:
+			if w.Check(ctx) {
+				return true
+			}
+		}
+	}
+}
@@ -1,9 +1,16 @@
 package app
 
 import (
+	"errors"
 	"os"
 	"strconv"
+	"strings"
 	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"fanfinity/internal/kafka"
+	"fanfinity/internal/metrics"
 )
 
 // Config holds all application configuration.
@@ -12,6 +19,10 @@ type Config struct {
 	Kafka      KafkaConfig
 	ClickHouse ClickHouseConfig
 	Consumer   ConsumerConfig
+	Metrics    MetricsConfig
+	Validation ValidationConfig
+	Webhook    WebhookConfig
+	Log        LogConfig
 }
 
 // ServerConfig holds HTTP server settings.
@@ -21,25 +32,172 @@ type ServerConfig struct {
 	ReadTimeout  time.Duration
 	WriteTimeout time.Duration
 	IdleTimeout  time.Duration
+	AdminToken   string
+
+	// StorageBackend selects where events are kept: StorageBackendClickHouse
+	// produces them to Kafka and reads metrics from ClickHouse, while
+	// StorageBackendMemory keeps them in process memory so the server runs
+	// without either, for local development. In-memory events are lost on restart.
+	StorageBackend string
+
+	// ReadHeaderTimeout bounds reading request headers (0 = ReadTimeout);
+	// DisableKeepAlives closes HTTP/1 connections after each request.
+	ReadHeaderTimeout time.Duration
+	DisableKeepAlives bool
+
+	// HTTP2 serves HTTP/2: negotiated over TLS when TLSCertFile and TLSKeyFile
+	// are set, otherwise as cleartext h2c. HTTP2MaxConcurrentStreams bounds
+	// requests per connection (0 = library default).
+	HTTP2                     bool
+	HTTP2MaxConcurrentStreams int
+	TLSCertFile               string
+	TLSKeyFile                string
+
+	// WarmupInterval is how often the startup schema check is retried until
+	// /ready reports ready.
+	WarmupInterval time.Duration
+
+	// QueryTimeout bounds metrics queries; MaxQueryTimeout caps the X-Query-Timeout override.
+	QueryTimeout    time.Duration
+	MaxQueryTimeout time.Duration
+
+	// ProduceConcurrency bounds concurrent ingestion produce calls (0 = unbounded);
+	// requests wait up to ProduceQueueTimeout for a slot before a 503.
+	ProduceConcurrency  int
+	ProduceQueueTimeout time.Duration
+
+	// ProduceTimeout bounds each ingestion produce call; a produce that overruns
+	// it fails the request with 503 PRODUCER_TIMEOUT.
+	ProduceTimeout time.Duration
+
+	// IngestBatchWindow buffers single ingested events for this long and
+	// produces them together (0 = produce each event as it arrives); a batch
+	// flushes early at IngestBatchMaxSize events.
+	IngestBatchWindow  time.Duration
+	IngestBatchMaxSize int
+	// IngestPriorityTypes is a comma-separated list of event types (e.g.
+	// "goal,red_card") produced at once instead of waiting for the batch window.
+	IngestPriorityTypes string
+
+	// MaxMatchLag refuses events with 429 for matches whose published consumer
+	// lag exceeds it (0 = never); lagging matches are re-read every
+	// MatchLagRefreshInterval and told to retry after MatchLagRetryAfter.
+	MaxMatchLag             time.Duration
+	MatchLagRefreshInterval time.Duration
+	MatchLagRetryAfter      time.Duration
+
+	// Confirmations tracks events sent with X-Confirm: true for
+	// ConfirmationWindow and serves their persistence status. Requires the
+	// consumer to publish event status.
+	Confirmations      bool
+	ConfirmationWindow time.Duration
+
+	// MaxDecompressedBytes caps gzip or deflate request bodies after decoding.
+	MaxDecompressedBytes int64
+
+	// MaxImportBytes caps CSV bodies sent to the bulk import endpoint.
+	MaxImportBytes int64
+
+	// StreamTimeout bounds CSV imports and match exports in place of the
+	// request timeout and the server read and write timeouts.
+	StreamTimeout time.Duration
+
+	// BasePath serves all routes under a prefix, e.g. "/fanfinity" behind a shared
+	// gateway. OpsAtRoot keeps health, readiness and metrics at the root.
+	BasePath  string
+	OpsAtRoot bool
+
+	// EnablePprof exposes pprof and runtime stats under /debug on the API router
+	// and the consumer's metrics server.
+	EnablePprof bool
+
+	// AllowPrettyJSON lets GET requests ask for indented JSON with ?pretty=true
+	// or an X-Pretty header. Intended for debugging integrations, not production.
+	AllowPrettyJSON bool
+}
+
+// UseTLS reports whether the server serves TLS. It returns an error when only
+// one of TLSCertFile and TLSKeyFile is set, rather than silently serving
+// plain HTTP.
+func (s ServerConfig) UseTLS() (bool, error) {
+	switch {
+	case s.TLSCertFile != "" && s.TLSKeyFile != "":
+		return true, nil
+	case s.TLSCertFile != "":
+		return false, errors.New("SERVER_TLS_CERT_FILE is set without SERVER_TLS_KEY_FILE")
+	case s.TLSKeyFile != "":
+		return false, errors.New("SERVER_TLS_KEY_FILE is set without SERVER_TLS_CERT_FILE")
+	}
+	return false, nil
 }
 
 // KafkaConfig holds Kafka connection and topic settings.
 type KafkaConfig struct {
-	BootstrapServers string
+	BootstrapServers []string
 	TopicPrefix      string
 	TopicEvents      string
 	TopicRetry       string
 	TopicDead        string
 	ProducerTimeout  time.Duration
+	MaxMessageBytes  int
+
+	// ProducerFailureThreshold consecutive write failures mark the broker down,
+	// after which ingestion fails fast and probes every ProducerProbeInterval.
+	ProducerFailureThreshold int
+	ProducerProbeInterval    time.Duration
+
+	// ProducerMaxRetries bounds retries of transient broker errors, backing off
+	// from ProducerRetryBackoff. A negative value disables retries.
+	ProducerMaxRetries   int
+	ProducerRetryBackoff time.Duration
+
+	// ProducerDedupBatch drops events repeating an eventId within one batch.
+	ProducerDedupBatch bool
+
+	// TopicRoutes sends matching events to other topics than TopicEvents, as
+	// comma-separated value=topic pairs. TopicRouteBy names what the value is
+	// compared with: "matchId" or a metadata key such as a competition ID.
+	TopicRoutes  string
+	TopicRouteBy string
+
+	// SpoolPath enables spooling events to a local file while the broker is
+	// unreachable, bounded by SpoolMaxBytes and replayed every
+	// SpoolReplayInterval once it recovers. Empty disables the spool.
+	SpoolPath           string
+	SpoolMaxBytes       int
+	SpoolReplayInterval time.Duration
 }
 
 // ClickHouseConfig holds ClickHouse connection settings.
 type ClickHouseConfig struct {
-	Host     string
-	Port     int
+	Host string
+	Port int
+	// Protocol selects the wire protocol: "native" (default, port 9000) or "http" (port 8123).
+	Protocol string
 	Database string
+	// Table is the events table within Database, e.g. a staging or Distributed table.
+	Table    string
 	User     string
 	Password string
+
+	// InsertQuorum, InsertDeduplicate and InsertDeduplicationToken set per-insert
+	// settings for replicated tables. The zero values leave server defaults in place.
+	InsertQuorum             int
+	InsertDeduplicate        bool
+	InsertDeduplicationToken bool
+
+	// InsertMaxExecutionTime and ReadMaxExecutionTime bound writes and metrics
+	// reads separately, overriding the connection-wide max_execution_time.
+	InsertMaxExecutionTime time.Duration
+	ReadMaxExecutionTime   time.Duration
+
+	// ReadFinal reads the events table with FINAL in the metrics queries, for
+	// ReplacingMergeTree tables. It slows those reads considerably.
+	ReadFinal bool
+
+	// OptionalColumns are events table columns the consumer writes only when
+	// the table has them, e.g. schema_version during a migration.
+	OptionalColumns []string
 }
 
 // ConsumerConfig holds Kafka consumer and batch processing settings.
@@ -49,40 +207,488 @@ type ConsumerConfig struct {
 	MaxRetries    int
 	RetryBackoff  time.Duration
 	ConsumerGroup string
+
+	// MaxBatchBytes flushes a batch once its events total this many bytes;
+	// zero disables the limit.
+	MaxBatchBytes int
+
+	// ReuseBatchBuffers reuses batch slices across flushes instead of
+	// allocating new ones, reducing GC pressure at high throughput.
+	ReuseBatchBuffers bool
+
+	// AdaptiveFlush lets the flush interval float between the min and max bounds.
+	AdaptiveFlush    bool
+	MinFlushInterval time.Duration
+	MaxFlushInterval time.Duration
+
+	// FetchMinBytes and FetchMaxBytes bound each fetch from the events topic,
+	// and FetchMaxWait is how long the broker may wait to gather FetchMinBytes.
+	// A zero FetchMaxWait uses FlushInterval.
+	FetchMinBytes int
+	FetchMaxBytes int
+	FetchMaxWait  time.Duration
+
+	// RebalanceDrain consumes through consumer group generations so the in-flight
+	// batch is flushed and committed before partitions are revoked on rebalance.
+	RebalanceDrain bool
+
+	// DryRun reads and batches events without inserting them or committing offsets.
+	DryRun bool
+
+	// CheckOrdering flags events that arrive earlier than the last seen for
+	// their match within a batch.
+	CheckOrdering bool
+
+	// RateAnomalyWindow enables event rate anomaly detection: each match's
+	// events are counted per window (0 = off), and a window whose count is more
+	// than RateAnomalySigma standard deviations and RateAnomalyMinChange (a
+	// fraction of the mean) away from the match's previous RateAnomalyBaseline
+	// windows is counted as a spike or drop. At most RateAnomalyMaxMatches
+	// matches are tracked, evicting the least recently seen.
+	RateAnomalyWindow     time.Duration
+	RateAnomalyBaseline   int
+	RateAnomalySigma      float64
+	RateAnomalyMinChange  float64
+	RateAnomalyMaxMatches int
+
+	// PublishMatchLag publishes each match's lag after every flush, for the
+	// server's per-match backpressure.
+	PublishMatchLag bool
+
+	// PublishEventStatus publishes the status of events sent with X-Confirm
+	// once they are inserted or dead-lettered, for the server's status endpoint.
+	PublishEventStatus bool
+
+	// DeadLetterRetention overrides the retention class tagged on dead letter
+	// messages per failure reason, as comma-separated reason=class pairs.
+	DeadLetterRetention string
+
+	// DeadLetterGzipThreshold gzips dead letter payloads larger than this many
+	// bytes (0 = never).
+	DeadLetterGzipThreshold int
+
+	// LogFailedEventSample adds one serialized event, cut to
+	// FailedEventSampleMaxBytes, to each batch insert failure log. Off by
+	// default to keep event bodies, which may carry personal data, out of logs.
+	LogFailedEventSample      bool
+	FailedEventSampleMaxBytes int
 }
 
+// ValidationConfig holds optional event validation settings.
+type ValidationConfig struct {
+	// ValidateMinute enables range checks on metadata.minute when present.
+	ValidateMinute bool
+	MaxMinute      int
+
+	// MaxTeamID allows teamId values from 1 to MaxTeamID (default 2).
+	MaxTeamID int
+
+	// EventTypeAliases is a comma-separated list of alias=type pairs accepted in
+	// addition to the built-in aliases (freekick, penalty_kick).
+	EventTypeAliases string
+
+	// RequiredMetadataFile is a JSON file mapping event types to required metadata
+	// keys. Empty enforces no required keys.
+	RequiredMetadataFile string
+
+	// Mode is "strict" or "lenient". Strict rejects unknown fields, always
+	// checks metadata and rejects out-of-range timestamps; lenient skips all
+	// of these. Empty keeps the individual settings above.
+	Mode string
+
+	// UseJSONNumber decodes metadata numbers as json.Number so integers keep
+	// their exact value through ingestion, Kafka and ClickHouse.
+	UseJSONNumber bool
+}
+
+// MetricsConfig holds settings for match metrics computation and the exported
+// Prometheus metrics.
+type MetricsConfig struct {
+	// EngagementWeights is a comma-separated list of type=weight pairs
+	// (e.g. "goal=10,shot=3") used to score the peak engagement minute.
+	// Unlisted event types default to a weight of 1.0.
+	EngagementWeights string
+
+	// MatchPhases is a comma-separated list of name=start pairs (e.g.
+	// "first_half=0,second_half=45m") splitting metrics into phases by offset
+	// from the match's first event. Empty uses halves and extra time.
+	MatchPhases string
+
+	// CacheTTL serves repeat metrics requests from memory for this long. Zero disables it.
+	CacheTTL time.Duration
+	// ServeStaleOnError returns the last cached metrics when ClickHouse queries fail.
+	ServeStaleOnError bool
+	// CacheMaxEntries bounds the matches whose metrics are cached. Zero uses the handler default.
+	CacheMaxEntries int
+
+	// Namespace prefixes Prometheus metric names. Tenant, when set, is added to
+	// every metric as a constant tenant label.
+	Namespace string
+	Tenant    string
+}
+
+// PrometheusOptions returns the options for registering Prometheus metrics.
+func (c MetricsConfig) PrometheusOptions() metrics.Options {
+	opts := metrics.Options{Namespace: c.Namespace}
+	if c.Tenant != "" {
+		opts.ConstLabels = prometheus.Labels{"tenant": c.Tenant}
+	}
+	return opts
+}
+
+// WebhookConfig holds settings for forwarding ingested events to a webhook.
+type WebhookConfig struct {
+	// URL receives a POST per forwarded event. Empty disables the webhook.
+	URL string
+	// EventTypes is a comma-separated list of event types to forward, e.g.
+	// "goal,red_card". Empty forwards every type.
+	EventTypes string
+
+	// QueueSize bounds events awaiting delivery; more are dropped. Failed
+	// deliveries are retried up to MaxRetries times, each POST bounded by Timeout.
+	QueueSize  int
+	MaxRetries int
+	Timeout    time.Duration
+}
+
+// LogConfig holds structured logging settings.
+type LogConfig struct {
+	// SampleRate emits 1-in-N of the high-frequency debug log lines. 1 disables sampling.
+	SampleRate int
+
+	// Level is the minimum level logged: debug, info, warn or error.
+	Level string
+	// Format selects the output encoding: json or text.
+	Format string
+}
+
+// Storage backends selected by ServerConfig.StorageBackend.
+const (
+	StorageBackendClickHouse = "clickhouse"
+	StorageBackendMemory     = "memory"
+)
+
+// Defaults used by LoadConfig for unset environment variables and by
+// Config.WithDefaults for zero-valued fields. Settings not listed here
+// default to their zero value.
+const (
+	DefaultServerHost           = "0.0.0.0"
+	DefaultServerPort           = 8080
+	DefaultReadTimeout          = 10 * time.Second
+	DefaultWriteTimeout         = 10 * time.Second
+	DefaultIdleTimeout          = 60 * time.Second
+	DefaultWarmupInterval       = time.Second
+	DefaultQueryTimeout         = 10 * time.Second
+	DefaultMaxQueryTimeout      = 30 * time.Second
+	DefaultProduceQueueTimeout  = 100 * time.Millisecond
+	DefaultProduceTimeout       = 5 * time.Second
+	DefaultIngestBatchMaxSize   = 100
+	DefaultMatchLagRefresh      = 5 * time.Second
+	DefaultMatchLagRetryAfter   = 10 * time.Second
+	DefaultConfirmationWindow   = 10 * time.Minute
+	DefaultMaxDecompressedBytes = 10 << 20
+	DefaultMaxImportBytes       = 64 << 20
+	DefaultStreamTimeout        = 10 * time.Minute
+	DefaultOpsAtRoot            = true
+	DefaultStorageBackend       = StorageBackendClickHouse
+
+	DefaultKafkaBootstrapServer     = "kafka:29092"
+	DefaultTopicPrefix              = "fanfinity"
+	DefaultTopicEvents              = "fanfinity.events"
+	DefaultTopicRetry               = "fanfinity.retry"
+	DefaultTopicDead                = "fanfinity.dead"
+	DefaultProducerTimeout          = 10 * time.Second
+	DefaultMaxMessageBytes          = 1048576
+	DefaultProducerFailureThreshold = 5
+	DefaultProducerProbeInterval    = 5 * time.Second
+	DefaultProducerMaxRetries       = 2
+	DefaultProducerRetryBackoff     = 100 * time.Millisecond
+	DefaultTopicRouteBy             = "matchId"
+	DefaultSpoolMaxBytes            = 256 << 20
+	DefaultSpoolReplayInterval      = 5 * time.Second
+
+	DefaultClickHouseHost         = "clickhouse"
+	DefaultClickHousePort         = 9000
+	DefaultClickHouseProtocol     = "native"
+	DefaultClickHouseDatabase     = "fanfinity"
+	DefaultClickHouseTable        = "match_events"
+	DefaultClickHouseUser         = "default"
+	DefaultInsertMaxExecutionTime = 60 * time.Second
+	DefaultReadMaxExecutionTime   = 60 * time.Second
+
+	DefaultBatchSize          = 1000
+	DefaultFlushInterval      = 5 * time.Second
+	DefaultConsumerMaxRetries = 3
+	DefaultRetryBackoff       = time.Second
+	DefaultConsumerGroup      = "fanfinity-consumers"
+	DefaultMinFlushInterval   = 500 * time.Millisecond
+	DefaultMaxFlushInterval   = 30 * time.Second
+
+	DefaultFailedEventSampleMaxBytes = 1024
+
+	DefaultMaxMinute = 130
+	DefaultMaxTeamID = 2
+
+	DefaultWebhookQueueSize  = 1000
+	DefaultWebhookMaxRetries = 3
+	DefaultWebhookTimeout    = 5 * time.Second
+
+	DefaultLogSampleRate = 1
+	DefaultLogLevel      = "info"
+	DefaultLogFormat     = "json"
+)
+
 // LoadConfig reads configuration from environment variables with sensible defaults.
 func LoadConfig() *Config {
 	return &Config{
 		Server: ServerConfig{
-			Host:         getEnv("SERVER_HOST", "0.0.0.0"),
-			Port:         getEnvInt("SERVER_PORT", 8080),
-			ReadTimeout:  getEnvDuration("SERVER_READ_TIMEOUT", 10*time.Second),
-			WriteTimeout: getEnvDuration("SERVER_WRITE_TIMEOUT", 10*time.Second),
-			IdleTimeout:  getEnvDuration("SERVER_IDLE_TIMEOUT", 60*time.Second),
+			Host:         getEnv("SERVER_HOST", DefaultServerHost),
+			Port:         getEnvInt("SERVER_PORT", DefaultServerPort),
+			ReadTimeout:  getEnvDuration("SERVER_READ_TIMEOUT", DefaultReadTimeout),
+			WriteTimeout: getEnvDuration("SERVER_WRITE_TIMEOUT", DefaultWriteTimeout),
+			IdleTimeout:  getEnvDuration("SERVER_IDLE_TIMEOUT", DefaultIdleTimeout),
+			AdminToken:   getEnv("ADMIN_TOKEN", ""),
+
+			StorageBackend: getEnv("STORAGE_BACKEND", DefaultStorageBackend),
+
+			ReadHeaderTimeout:         getEnvDuration("SERVER_READ_HEADER_TIMEOUT", 0),
+			DisableKeepAlives:         getEnvBool("SERVER_DISABLE_KEEP_ALIVES", false),
+			HTTP2:                     getEnvBool("SERVER_HTTP2", false),
+			HTTP2MaxConcurrentStreams: getEnvInt("SERVER_HTTP2_MAX_CONCURRENT_STREAMS", 0),
+			TLSCertFile:               getEnv("SERVER_TLS_CERT_FILE", ""),
+			TLSKeyFile:                getEnv("SERVER_TLS_KEY_FILE", ""),
+
+			WarmupInterval: getEnvDuration("SERVER_WARMUP_INTERVAL", DefaultWarmupInterval),
+
+			QueryTimeout:    getEnvDuration("SERVER_QUERY_TIMEOUT", DefaultQueryTimeout),
+			MaxQueryTimeout: getEnvDuration("SERVER_MAX_QUERY_TIMEOUT", DefaultMaxQueryTimeout),
+
+			ProduceConcurrency:  getEnvInt("SERVER_PRODUCE_CONCURRENCY", 0),
+			ProduceQueueTimeout: getEnvDuration("SERVER_PRODUCE_QUEUE_TIMEOUT", DefaultProduceQueueTimeout),
+			ProduceTimeout:      getEnvDuration("SERVER_PRODUCE_TIMEOUT", DefaultProduceTimeout),
+
+			IngestBatchWindow:   getEnvDuration("SERVER_INGEST_BATCH_WINDOW", 0),
+			IngestBatchMaxSize:  getEnvInt("SERVER_INGEST_BATCH_MAX_SIZE", DefaultIngestBatchMaxSize),
+			IngestPriorityTypes: getEnv("SERVER_INGEST_PRIORITY_TYPES", ""),
+
+			MaxMatchLag:             getEnvDuration("SERVER_MAX_MATCH_LAG", 0),
+			MatchLagRefreshInterval: getEnvDuration("SERVER_MATCH_LAG_REFRESH_INTERVAL", DefaultMatchLagRefresh),
+			MatchLagRetryAfter:      getEnvDuration("SERVER_MATCH_LAG_RETRY_AFTER", DefaultMatchLagRetryAfter),
+
+			Confirmations:      getEnvBool("SERVER_CONFIRMATIONS", false),
+			ConfirmationWindow: getEnvDuration("SERVER_CONFIRMATION_WINDOW", DefaultConfirmationWindow),
+
+			MaxDecompressedBytes: int64(getEnvInt("SERVER_MAX_DECOMPRESSED_BYTES", DefaultMaxDecompressedBytes)),
+			MaxImportBytes:       int64(getEnvInt("SERVER_MAX_IMPORT_BYTES", DefaultMaxImportBytes)),
+			StreamTimeout:        getEnvDuration("SERVER_STREAM_TIMEOUT", DefaultStreamTimeout),
+			BasePath:             getEnv("SERVER_BASE_PATH", ""),
+			OpsAtRoot:            getEnvBool("SERVER_OPS_AT_ROOT", DefaultOpsAtRoot),
+			EnablePprof:          getEnvBool("ENABLE_PPROF", false),
+			AllowPrettyJSON:      getEnvBool("SERVER_ALLOW_PRETTY_JSON", false),
 		},
 		Kafka: KafkaConfig{
-			BootstrapServers: getEnv("KAFKA_BOOTSTRAP_SERVERS", "kafka:29092"),
-			TopicPrefix:      getEnv("KAFKA_TOPIC_PREFIX", "fanfinity"),
-			TopicEvents:      getEnv("KAFKA_TOPIC_EVENTS", "fanfinity.events"),
-			TopicRetry:       getEnv("KAFKA_TOPIC_RETRY", "fanfinity.retry"),
-			TopicDead:        getEnv("KAFKA_TOPIC_DEAD", "fanfinity.dead"),
-			ProducerTimeout:  getEnvDuration("KAFKA_PRODUCER_TIMEOUT", 10*time.Second),
+			BootstrapServers: getEnvList("KAFKA_BOOTSTRAP_SERVERS", []string{DefaultKafkaBootstrapServer}),
+			TopicPrefix:      getEnv("KAFKA_TOPIC_PREFIX", DefaultTopicPrefix),
+			TopicEvents:      getEnv("KAFKA_TOPIC_EVENTS", DefaultTopicEvents),
+			TopicRetry:       getEnv("KAFKA_TOPIC_RETRY", DefaultTopicRetry),
+			TopicDead:        getEnv("KAFKA_TOPIC_DEAD", DefaultTopicDead),
+			ProducerTimeout:  getEnvDuration("KAFKA_PRODUCER_TIMEOUT", DefaultProducerTimeout),
+			MaxMessageBytes:  getEnvInt("KAFKA_MAX_MESSAGE_BYTES", DefaultMaxMessageBytes),
+
+			ProducerFailureThreshold: getEnvInt("KAFKA_PRODUCER_FAILURE_THRESHOLD", DefaultProducerFailureThreshold),
+			ProducerProbeInterval:    getEnvDuration("KAFKA_PRODUCER_PROBE_INTERVAL", DefaultProducerProbeInterval),
+			ProducerMaxRetries:       getEnvInt("KAFKA_PRODUCER_MAX_RETRIES", DefaultProducerMaxRetries),
+			ProducerRetryBackoff:     getEnvDuration("KAFKA_PRODUCER_RETRY_BACKOFF", DefaultProducerRetryBackoff),
+			ProducerDedupBatch:       getEnvBool("KAFKA_PRODUCER_DEDUP_BATCH", false),
+
+			TopicRoutes:  getEnv("KAFKA_TOPIC_ROUTES", ""),
+			TopicRouteBy: getEnv("KAFKA_TOPIC_ROUTE_BY", DefaultTopicRouteBy),
+
+			SpoolPath:           getEnv("KAFKA_SPOOL_PATH", ""),
+			SpoolMaxBytes:       getEnvInt("KAFKA_SPOOL_MAX_BYTES", DefaultSpoolMaxBytes),
+			SpoolReplayInterval: getEnvDuration("KAFKA_SPOOL_REPLAY_INTERVAL", DefaultSpoolReplayInterval),
 		},
 		ClickHouse: ClickHouseConfig{
-			Host:     getEnv("CLICKHOUSE_HOST", "clickhouse"),
-			Port:     getEnvInt("CLICKHOUSE_PORT", 9000),
-			Database: getEnv("CLICKHOUSE_DATABASE", "fanfinity"),
-			User:     getEnv("CLICKHOUSE_USER", "default"),
+			Host:     getEnv("CLICKHOUSE_HOST", DefaultClickHouseHost),
+			Port:     getEnvInt("CLICKHOUSE_PORT", DefaultClickHousePort),
+			Protocol: getEnv("CLICKHOUSE_PROTOCOL", DefaultClickHouseProtocol),
+			Database: getEnv("CLICKHOUSE_DATABASE", DefaultClickHouseDatabase),
+			Table:    getEnv("CLICKHOUSE_TABLE", DefaultClickHouseTable),
+			User:     getEnv("CLICKHOUSE_USER", DefaultClickHouseUser),
 			Password: getEnv("CLICKHOUSE_PASSWORD", ""),
+
+			InsertQuorum:             getEnvInt("CLICKHOUSE_INSERT_QUORUM", 0),
+			InsertDeduplicate:        getEnvBool("CLICKHOUSE_INSERT_DEDUPLICATE", false),
+			InsertDeduplicationToken: getEnvBool("CLICKHOUSE_INSERT_DEDUPLICATION_TOKEN", false),
+
+			InsertMaxExecutionTime: getEnvDuration("CLICKHOUSE_INSERT_MAX_EXECUTION_TIME", DefaultInsertMaxExecutionTime),
+			ReadMaxExecutionTime:   getEnvDuration("CLICKHOUSE_READ_MAX_EXECUTION_TIME", DefaultReadMaxExecutionTime),
+			ReadFinal:              getEnvBool("CLICKHOUSE_READ_FINAL", false),
+			OptionalColumns:        getEnvList("CLICKHOUSE_OPTIONAL_COLUMNS", nil),
 		},
 		Consumer: ConsumerConfig{
-			BatchSize:     getEnvInt("CONSUMER_BATCH_SIZE", 1000),
-			FlushInterval: getEnvDuration("CONSUMER_FLUSH_INTERVAL", 5*time.Second),
-			MaxRetries:    getEnvInt("CONSUMER_MAX_RETRIES", 3),
-			RetryBackoff:  getEnvDuration("CONSUMER_RETRY_BACKOFF", 1*time.Second),
-			ConsumerGroup: getEnv("CONSUMER_GROUP", "fanfinity-consumers"),
+			BatchSize:     getEnvInt("CONSUMER_BATCH_SIZE", DefaultBatchSize),
+			FlushInterval: getEnvDuration("CONSUMER_FLUSH_INTERVAL", DefaultFlushInterval),
+			MaxRetries:    getEnvInt("CONSUMER_MAX_RETRIES", DefaultConsumerMaxRetries),
+			RetryBackoff:  getEnvDuration("CONSUMER_RETRY_BACKOFF", DefaultRetryBackoff),
+			ConsumerGroup: getEnv("CONSUMER_GROUP", DefaultConsumerGroup),
+			MaxBatchBytes: getEnvInt("CONSUMER_MAX_BATCH_BYTES", 0),
+
+			ReuseBatchBuffers: getEnvBool("CONSUMER_REUSE_BATCH_BUFFERS", false),
+
+			AdaptiveFlush:      getEnvBool("CONSUMER_ADAPTIVE_FLUSH", false),
+			MinFlushInterval:   getEnvDuration("CONSUMER_MIN_FLUSH_INTERVAL", DefaultMinFlushInterval),
+			MaxFlushInterval:   getEnvDuration("CONSUMER_MAX_FLUSH_INTERVAL", DefaultMaxFlushInterval),
+			FetchMinBytes:      getEnvInt("CONSUMER_FETCH_MIN_BYTES", kafka.DefaultFetchMinBytes),
+			FetchMaxBytes:      getEnvInt("CONSUMER_FETCH_MAX_BYTES", kafka.DefaultFetchMaxBytes),
+			FetchMaxWait:       getEnvDuration("CONSUMER_FETCH_MAX_WAIT", 0),
+			RebalanceDrain:     getEnvBool("CONSUMER_REBALANCE_DRAIN", false),
+			DryRun:             getEnvBool("CONSUMER_DRY_RUN", false),
+			PublishMatchLag:    getEnvBool("CONSUMER_PUBLISH_MATCH_LAG", false),
+			PublishEventStatus: getEnvBool("CONSUMER_PUBLISH_EVENT_STATUS", false),
+			CheckOrdering:      getEnvBool("CONSUMER_CHECK_ORDERING", false),
+
+			RateAnomalyWindow:     getEnvDuration("CONSUMER_RATE_ANOMALY_WINDOW", 0),
+			RateAnomalyBaseline:   getEnvInt("CONSUMER_RATE_ANOMALY_BASELINE", kafka.DefaultRateAnomalyBaseline),
+			RateAnomalySigma:      getEnvFloat("CONSUMER_RATE_ANOMALY_SIGMA", kafka.DefaultRateAnomalySigma),
+			RateAnomalyMinChange:  getEnvFloat("CONSUMER_RATE_ANOMALY_MIN_CHANGE", kafka.DefaultRateAnomalyMinChange),
+			RateAnomalyMaxMatches: getEnvInt("CONSUMER_RATE_ANOMALY_MAX_MATCHES", kafka.DefaultMaxTrackedMatches),
+
+			DeadLetterRetention:     getEnv("CONSUMER_DEAD_LETTER_RETENTION", ""),
+			DeadLetterGzipThreshold: getEnvInt("CONSUMER_DEAD_LETTER_GZIP_THRESHOLD", 0),
+
+			LogFailedEventSample:      getEnvBool("CONSUMER_LOG_FAILED_EVENT_SAMPLE", false),
+			FailedEventSampleMaxBytes: getEnvInt("CONSUMER_FAILED_EVENT_SAMPLE_MAX_BYTES", DefaultFailedEventSampleMaxBytes),
+		},
+		Metrics: MetricsConfig{
+			EngagementWeights: getEnv("METRICS_ENGAGEMENT_WEIGHTS", ""),
+			MatchPhases:       getEnv("METRICS_MATCH_PHASES", ""),
+			CacheTTL:          getEnvDuration("METRICS_CACHE_TTL", 0),
+			ServeStaleOnError: getEnvBool("METRICS_SERVE_STALE_ON_ERROR", false),
+			CacheMaxEntries:   getEnvInt("METRICS_CACHE_MAX_ENTRIES", 0),
+			Namespace:         getEnv("METRICS_NAMESPACE", metrics.DefaultNamespace),
+			Tenant:            getEnv("METRICS_TENANT", ""),
+		},
+		Validation: ValidationConfig{
+			ValidateMinute: getEnvBool("VALIDATION_METADATA_MINUTE", false),
+			MaxMinute:      getEnvInt("VALIDATION_MAX_MINUTE", DefaultMaxMinute),
+			MaxTeamID:      getEnvInt("VALIDATION_MAX_TEAM_ID", DefaultMaxTeamID),
+
+			EventTypeAliases:     getEnv("VALIDATION_EVENT_TYPE_ALIASES", ""),
+			RequiredMetadataFile: getEnv("REQUIRED_METADATA_FILE", ""),
+			Mode:                 getEnv("VALIDATION_MODE", ""),
+			UseJSONNumber:        getEnvBool("METADATA_USE_NUMBER", false),
+		},
+		Webhook: WebhookConfig{
+			URL:        getEnv("WEBHOOK_URL", ""),
+			EventTypes: getEnv("WEBHOOK_EVENT_TYPES", ""),
+			QueueSize:  getEnvInt("WEBHOOK_QUEUE_SIZE", DefaultWebhookQueueSize),
+			MaxRetries: getEnvInt("WEBHOOK_MAX_RETRIES", DefaultWebhookMaxRetries),
+			Timeout:    getEnvDuration("WEBHOOK_TIMEOUT", DefaultWebhookTimeout),
 		},
+		Log: LogConfig{
+			SampleRate: getEnvInt("LOG_SAMPLE_RATE", DefaultLogSampleRate),
+			Level:      getEnv("LOG_LEVEL", DefaultLogLevel),
+			Format:     getEnv("LOG_FORMAT", DefaultLogFormat),
+		},
+	}
+}
+
+// WithDefaults returns a copy of c with every zero-valued field that has a
+// default set to it, so a Config built in code gets the same values LoadConfig
+// uses for unset variables. A zero field cannot be told apart from an unset
+// one: a setting whose zero value is meaningful, such as no producer retries,
+// is replaced by its default. Server.OpsAtRoot is turned on when no
+// Server.BasePath is set; set it explicitly alongside a base path.
+func (c Config) WithDefaults() *Config {
+	s := &c.Server
+	setDefault(&s.Host, DefaultServerHost)
+	setDefault(&s.Port, DefaultServerPort)
+	setDefault(&s.ReadTimeout, DefaultReadTimeout)
+	setDefault(&s.WriteTimeout, DefaultWriteTimeout)
+	setDefault(&s.IdleTimeout, DefaultIdleTimeout)
+	setDefault(&s.StorageBackend, DefaultStorageBackend)
+	setDefault(&s.WarmupInterval, DefaultWarmupInterval)
+	setDefault(&s.QueryTimeout, DefaultQueryTimeout)
+	setDefault(&s.MaxQueryTimeout, DefaultMaxQueryTimeout)
+	setDefault(&s.ProduceQueueTimeout, DefaultProduceQueueTimeout)
+	setDefault(&s.ProduceTimeout, DefaultProduceTimeout)
+	setDefault(&s.IngestBatchMaxSize, DefaultIngestBatchMaxSize)
+	setDefault(&s.MatchLagRefreshInterval, DefaultMatchLagRefresh)
+	setDefault(&s.MatchLagRetryAfter, DefaultMatchLagRetryAfter)
+	setDefault(&s.ConfirmationWindow, DefaultConfirmationWindow)
+	setDefault(&s.MaxDecompressedBytes, DefaultMaxDecompressedBytes)
+	setDefault(&s.MaxImportBytes, DefaultMaxImportBytes)
+	setDefault(&s.StreamTimeout, DefaultStreamTimeout)
+	if s.BasePath == "" {
+		s.OpsAtRoot = DefaultOpsAtRoot
+	}
+
+	k := &c.Kafka
+	if len(k.BootstrapServers) == 0 {
+		k.BootstrapServers = []string{DefaultKafkaBootstrapServer}
+	}
+	setDefault(&k.TopicPrefix, DefaultTopicPrefix)
+	setDefault(&k.TopicEvents, DefaultTopicEvents)
+	setDefault(&k.TopicRetry, DefaultTopicRetry)
+	setDefault(&k.TopicDead, DefaultTopicDead)
+	setDefault(&k.ProducerTimeout, DefaultProducerTimeout)
+	setDefault(&k.MaxMessageBytes, DefaultMaxMessageBytes)
+	setDefault(&k.ProducerFailureThreshold, DefaultProducerFailureThreshold)
+	setDefault(&k.ProducerProbeInterval, DefaultProducerProbeInterval)
+	setDefault(&k.ProducerMaxRetries, DefaultProducerMaxRetries)
+	setDefault(&k.ProducerRetryBackoff, DefaultProducerRetryBackoff)
+	setDefault(&k.TopicRouteBy, DefaultTopicRouteBy)
+	setDefault(&k.SpoolMaxBytes, DefaultSpoolMaxBytes)
+	setDefault(&k.SpoolReplayInterval, DefaultSpoolReplayInterval)
+
+	ch := &c.ClickHouse
+	setDefault(&ch.Host, DefaultClickHouseHost)
+	setDefault(&ch.Port, DefaultClickHousePort)
+	setDefault(&ch.Protocol, DefaultClickHouseProtocol)
+	setDefault(&ch.Database, DefaultClickHouseDatabase)
+	setDefault(&ch.Table, DefaultClickHouseTable)
+	setDefault(&ch.User, DefaultClickHouseUser)
+	setDefault(&ch.InsertMaxExecutionTime, DefaultInsertMaxExecutionTime)
+	setDefault(&ch.ReadMaxExecutionTime, DefaultReadMaxExecutionTime)
+
+	cons := &c.Consumer
+	setDefault(&cons.BatchSize, DefaultBatchSize)
+	setDefault(&cons.FlushInterval, DefaultFlushInterval)
+	setDefault(&cons.MaxRetries, DefaultConsumerMaxRetries)
+	setDefault(&cons.RetryBackoff, DefaultRetryBackoff)
+	setDefault(&cons.ConsumerGroup, DefaultConsumerGroup)
+	setDefault(&cons.MinFlushInterval, DefaultMinFlushInterval)
+	setDefault(&cons.MaxFlushInterval, DefaultMaxFlushInterval)
+	setDefault(&cons.FetchMinBytes, kafka.DefaultFetchMinBytes)
+	setDefault(&cons.FetchMaxBytes, kafka.DefaultFetchMaxBytes)
+	setDefault(&cons.FailedEventSampleMaxBytes, DefaultFailedEventSampleMaxBytes)
+	setDefault(&cons.RateAnomalyBaseline, kafka.DefaultRateAnomalyBaseline)
+	setDefault(&cons.RateAnomalySigma, kafka.DefaultRateAnomalySigma)
+	setDefault(&cons.RateAnomalyMinChange, kafka.DefaultRateAnomalyMinChange)
+	setDefault(&cons.RateAnomalyMaxMatches, kafka.DefaultMaxTrackedMatches)
+
+	setDefault(&c.Metrics.Namespace, metrics.DefaultNamespace)
+
+	setDefault(&c.Validation.MaxMinute, DefaultMaxMinute)
+	setDefault(&c.Validation.MaxTeamID, DefaultMaxTeamID)
+
+	setDefault(&c.Webhook.QueueSize, DefaultWebhookQueueSize)
+	setDefault(&c.Webhook.MaxRetries, DefaultWebhookMaxRetries)
+	setDefault(&c.Webhook.Timeout, DefaultWebhookTimeout)
+
+	setDefault(&c.Log.SampleRate, DefaultLogSampleRate)
+	setDefault(&c.Log.Level, DefaultLogLevel)
+	setDefault(&c.Log.Format, DefaultLogFormat)
+
+	return &c
+}
+
+// setDefault sets *field to value if it holds its zero value.
+func setDefault[T comparable](field *T, value T) {
+	var zero T
+	if *field == zero {
+		*field = value
 	}
 }
 
@@ -94,6 +700,23 @@ func getEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
+// getEnvList retrieves a comma-separated environment variable as a list or returns a default value.
+// Surrounding whitespace and empty entries are dropped.
+func getEnvList(key string, defaultValue []string) []string {
+	if value, exists := os.LookupEnv(key); exists {
+		var items []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		if len(items) > 0 {
+			return items
+		}
+	}
+	return defaultValue
+}
+
 // getEnvInt retrieves an environment variable as an integer or returns a default value.
 func getEnvInt(key string, defaultValue int) int {
 	if value, exists := os.LookupEnv(key); exists {
@@ -104,6 +727,27 @@ func getEnvInt(key string, defaultValue int) int {
 	return defaultValue
 }
 
+// getEnvFloat retrieves an environment variable as a float or returns a default value.
+func getEnvFloat(key string, defaultValue float64) float64 {
+	if value, exists := os.LookupEnv(key); exists {
+		if floatValue, err := strconv.ParseFloat(value, 64); err == nil {
+			return floatValue
+		}
+	}
+	return defaultValue
+}
+
+// getEnvBool retrieves an environment variable as a boolean or returns a default value.
+// Accepts any value understood by strconv.ParseBool.
+func getEnvBool(key string, defaultValue bool) bool {
+	if value, exists := os.LookupEnv(key); exists {
+		if boolValue, err := strconv.ParseBool(value); err == nil {
+			return boolValue
+		}
+	}
+	return defaultValue
+}
+
 // getEnvDuration retrieves an environment variable as a duration or returns a default value.
 // Accepts formats like "10s", "5m", "1h".
 func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
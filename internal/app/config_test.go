@@ -0,0 +1,81 @@
+package app
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// unsetConfigEnv unsets every configuration variable for the duration of the
+// test, so LoadConfig returns its defaults.
+func unsetConfigEnv(t *testing.T) {
+	t.Helper()
+	prefixes := []string{"SERVER_", "KAFKA_", "CLICKHOUSE_", "CONSUMER_", "METRICS_", "VALIDATION_", "WEBHOOK_", "LOG_"}
+	others := map[string]bool{"ADMIN_TOKEN": true, "ENABLE_PPROF": true, "REQUIRED_METADATA_FILE": true, "METADATA_USE_NUMBER": true}
+	for _, kv := range os.Environ() {
+		key, _, _ := strings.Cut(kv, "=")
+		match := others[key]
+		for _, prefix := range prefixes {
+			match = match || strings.HasPrefix(key, prefix)
+		}
+		if match {
+			t.Setenv(key, "") // restores the value after the test
+			os.Unsetenv(key)
+		}
+	}
+}
+
+func TestConfig_WithDefaultsMatchesEnvDefaults(t *testing.T) {
+	unsetConfigEnv(t)
+
+	got := Config{}.WithDefaults()
+	want := LoadConfig()
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("zero Config with defaults differs from LoadConfig defaults:\ngot  %+v\nwant %+v", got, want)
+	}
+}
+
+func TestConfig_WithDefaultsKeepsSetFields(t *testing.T) {
+	cfg := Config{
+		Server:   ServerConfig{Port: 9090, BasePath: "/fanfinity"},
+		Consumer: ConsumerConfig{BatchSize: 250},
+	}.WithDefaults()
+
+	if cfg.Server.Port != 9090 || cfg.Consumer.BatchSize != 250 {
+		t.Errorf("expected set fields to be kept, got port %d and batch size %d", cfg.Server.Port, cfg.Consumer.BatchSize)
+	}
+	if cfg.Server.OpsAtRoot {
+		t.Error("expected OpsAtRoot to be left off when a base path is set")
+	}
+	if cfg.Server.Host != DefaultServerHost || cfg.Consumer.FlushInterval != DefaultFlushInterval {
+		t.Errorf("expected unset fields to take defaults, got host %q and flush interval %v", cfg.Server.Host, cfg.Consumer.FlushInterval)
+	}
+}
+
+func TestServerConfig_UseTLS(t *testing.T) {
+	tests := []struct {
+		name    string
+		cert    string
+		key     string
+		want    bool
+		wantErr bool
+	}{
+		{"neither", "", "", false, false},
+		{"both", "tls.crt", "tls.key", true, false},
+		{"cert only", "tls.crt", "", false, true},
+		{"key only", "", "tls.key", false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ServerConfig{TLSCertFile: tt.cert, TLSKeyFile: tt.key}.UseTLS()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+			if got != tt.want {
+				t.Errorf("expected UseTLS %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
@@ -6,11 +6,15 @@ import (
 	"log/slog"
 	"net"
 	"net/http"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/ClickHouse/clickhouse-go/v2"
 	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
 	"github.com/segmentio/kafka-go"
+
+	"fanfinity/internal/repository"
 )
 
 // AppContext is the central dependency injection container for the application.
@@ -24,12 +28,23 @@ type AppContext struct {
 	Server     *http.Server
 
 	shutdownCh chan struct{}
+
+	hooksMu sync.Mutex
+	hooks   []shutdownHook
+}
+
+// shutdownHook is a named function run during Shutdown.
+type shutdownHook struct {
+	name string
+	fn   func(context.Context) error
 }
 
 // ContextOptions configures which components to initialize.
 type ContextOptions struct {
 	InitProducer bool // Initialize Kafka producer (for API server)
 	InitConsumer bool // Initialize Kafka consumer (for consumer service)
+
+	SkipClickHouse bool // Do not connect to ClickHouse (in-memory storage backend)
 }
 
 // NewContext creates and initializes a new AppContext with all dependencies.
@@ -42,29 +57,33 @@ func NewContext(cfg *Config, logger *slog.Logger, opts ContextOptions) (*AppCont
 	}
 
 	// Initialize ClickHouse connection
-	if err := ctx.initClickHouse(); err != nil {
-		return nil, fmt.Errorf("failed to initialize ClickHouse: %w", err)
+	if !opts.SkipClickHouse {
+		if err := ctx.initClickHouse(); err != nil {
+			return nil, fmt.Errorf("failed to initialize ClickHouse: %w", err)
+		}
+		logger.Info("ClickHouse connection established",
+			slog.String("host", cfg.ClickHouse.Host),
+			slog.Int("port", cfg.ClickHouse.Port),
+			slog.String("database", cfg.ClickHouse.Database),
+		)
 	}
-	logger.Info("ClickHouse connection established",
-		slog.String("host", cfg.ClickHouse.Host),
-		slog.Int("port", cfg.ClickHouse.Port),
-		slog.String("database", cfg.ClickHouse.Database),
-	)
 
 	// Initialize Kafka producer (only for API server)
 	if opts.InitProducer {
 		ctx.initKafkaProducer()
 		logger.Info("Kafka producer initialized",
-			slog.String("brokers", cfg.Kafka.BootstrapServers),
+			slog.String("brokers", strings.Join(cfg.Kafka.BootstrapServers, ",")),
 			slog.String("topic", cfg.Kafka.TopicEvents),
 		)
 	}
 
 	// Initialize Kafka consumer (only for consumer service)
 	if opts.InitConsumer {
-		ctx.initKafkaConsumer()
+		if err := ctx.initKafkaConsumer(); err != nil {
+			return nil, fmt.Errorf("failed to initialize Kafka consumer: %w", err)
+		}
 		logger.Info("Kafka consumer initialized",
-			slog.String("brokers", cfg.Kafka.BootstrapServers),
+			slog.String("brokers", strings.Join(cfg.Kafka.BootstrapServers, ",")),
 			slog.String("topic", cfg.Kafka.TopicEvents),
 			slog.String("group", cfg.Consumer.ConsumerGroup),
 		)
@@ -74,8 +93,17 @@ func NewContext(cfg *Config, logger *slog.Logger, opts ContextOptions) (*AppCont
 }
 
 // NewServerContext creates an AppContext for the API server (producer only, no consumer).
+// With the in-memory storage backend neither ClickHouse nor Kafka is used.
 func NewServerContext(cfg *Config, logger *slog.Logger) (*AppContext, error) {
-	return NewContext(cfg, logger, ContextOptions{InitProducer: true, InitConsumer: false})
+	switch cfg.Server.StorageBackend {
+	case StorageBackendClickHouse:
+		return NewContext(cfg, logger, ContextOptions{InitProducer: true, InitConsumer: false})
+	case StorageBackendMemory:
+		return NewContext(cfg, logger, ContextOptions{SkipClickHouse: true})
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q, expected %s or %s",
+			cfg.Server.StorageBackend, StorageBackendClickHouse, StorageBackendMemory)
+	}
 }
 
 // NewConsumerContext creates an AppContext for the consumer service (consumer only, no producer).
@@ -85,12 +113,46 @@ func NewConsumerContext(cfg *Config, logger *slog.Logger) (*AppContext, error) {
 
 // initClickHouse establishes a connection to ClickHouse with appropriate settings.
 func (c *AppContext) initClickHouse() error {
-	conn, err := clickhouse.Open(&clickhouse.Options{
-		Addr: []string{fmt.Sprintf("%s:%d", c.Config.ClickHouse.Host, c.Config.ClickHouse.Port)},
+	opts, err := ClickHouseOptions(c.Config.ClickHouse)
+	if err != nil {
+		return err
+	}
+
+	conn, err := clickhouse.Open(opts)
+	if err != nil {
+		return fmt.Errorf("failed to open ClickHouse connection: %w", err)
+	}
+
+	// Verify the connection
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := conn.Ping(ctx); err != nil {
+		return fmt.Errorf("failed to ping ClickHouse: %w", err)
+	}
+
+	c.ClickHouse = conn
+	return nil
+}
+
+// ClickHouseOptions builds the ClickHouse driver options shared by the server and consumer.
+// It returns an error if the protocol is unknown or clearly mismatched with the port.
+func ClickHouseOptions(cfg ClickHouseConfig) (*clickhouse.Options, error) {
+	protocol, err := repository.ParseProtocol(cfg.Protocol)
+	if err != nil {
+		return nil, err
+	}
+	if err := repository.ValidateProtocolPort(protocol, cfg.Port); err != nil {
+		return nil, err
+	}
+
+	return &clickhouse.Options{
+		Protocol: protocol,
+		Addr:     []string{fmt.Sprintf("%s:%d", cfg.Host, cfg.Port)},
 		Auth: clickhouse.Auth{
-			Database: c.Config.ClickHouse.Database,
-			Username: c.Config.ClickHouse.User,
-			Password: c.Config.ClickHouse.Password,
+			Database: cfg.Database,
+			Username: cfg.User,
+			Password: cfg.Password,
 		},
 		Settings: clickhouse.Settings{
 			"max_execution_time": 60,
@@ -105,27 +167,13 @@ func (c *AppContext) initClickHouse() error {
 		ConnOpenStrategy:     clickhouse.ConnOpenInOrder,
 		BlockBufferSize:      10,
 		MaxCompressionBuffer: 10240,
-	})
-	if err != nil {
-		return fmt.Errorf("failed to open ClickHouse connection: %w", err)
-	}
-
-	// Verify the connection
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	if err := conn.Ping(ctx); err != nil {
-		return fmt.Errorf("failed to ping ClickHouse: %w", err)
-	}
-
-	c.ClickHouse = conn
-	return nil
+	}, nil
 }
 
 // initKafkaProducer creates and configures the Kafka writer for producing events.
 func (c *AppContext) initKafkaProducer() {
 	c.Producer = &kafka.Writer{
-		Addr:         kafka.TCP(c.Config.Kafka.BootstrapServers),
+		Addr:         kafka.TCP(c.Config.Kafka.BootstrapServers...),
 		Topic:        c.Config.Kafka.TopicEvents,
 		Balancer:     &kafka.LeastBytes{},
 		BatchSize:    100,
@@ -143,22 +191,68 @@ func (c *AppContext) initKafkaProducer() {
 	}
 }
 
-// initKafkaConsumer creates and configures the Kafka reader for consuming events.
-func (c *AppContext) initKafkaConsumer() {
-	c.Consumer = kafka.NewReader(kafka.ReaderConfig{
-		Brokers:        []string{c.Config.Kafka.BootstrapServers},
-		Topic:          c.Config.Kafka.TopicEvents,
-		GroupID:        c.Config.Consumer.ConsumerGroup,
-		MinBytes:       1,
-		MaxBytes:       10e6, // 10MB
-		MaxWait:        c.Config.Consumer.FlushInterval,
+// Sane ranges for the events reader's fetch settings.
+const (
+	MaxFetchBytes   = 1 << 30 // 1GiB
+	MinFetchMaxWait = 10 * time.Millisecond
+	MaxFetchMaxWait = 5 * time.Minute
+)
+
+// KafkaReaderConfig builds the events topic reader configuration from cfg.
+// Returns an error if the fetch settings are outside sane ranges, which
+// kafka.NewReader would otherwise panic on or silently misbehave with.
+func KafkaReaderConfig(cfg *Config) (kafka.ReaderConfig, error) {
+	cons := cfg.Consumer
+	maxWait := cons.FetchMaxWait
+	if maxWait == 0 {
+		maxWait = cons.FlushInterval
+	}
+
+	switch {
+	case cons.FetchMinBytes < 1:
+		return kafka.ReaderConfig{}, fmt.Errorf("consumer fetch min bytes must be at least 1, got %d", cons.FetchMinBytes)
+	case cons.FetchMaxBytes < cons.FetchMinBytes:
+		return kafka.ReaderConfig{}, fmt.Errorf("consumer fetch max bytes (%d) must be at least fetch min bytes (%d)", cons.FetchMaxBytes, cons.FetchMinBytes)
+	case cons.FetchMaxBytes > MaxFetchBytes:
+		return kafka.ReaderConfig{}, fmt.Errorf("consumer fetch max bytes must be at most %d, got %d", MaxFetchBytes, cons.FetchMaxBytes)
+	case maxWait < MinFetchMaxWait || maxWait > MaxFetchMaxWait:
+		return kafka.ReaderConfig{}, fmt.Errorf("consumer fetch max wait must be between %v and %v, got %v", MinFetchMaxWait, MaxFetchMaxWait, maxWait)
+	}
+
+	return kafka.ReaderConfig{
+		Brokers:        cfg.Kafka.BootstrapServers,
+		Topic:          cfg.Kafka.TopicEvents,
+		GroupID:        cons.ConsumerGroup,
+		MinBytes:       cons.FetchMinBytes,
+		MaxBytes:       cons.FetchMaxBytes,
+		MaxWait:        maxWait,
 		CommitInterval: time.Second,
 		StartOffset:    kafka.FirstOffset,
 		Dialer: &kafka.Dialer{
 			Timeout:   10 * time.Second,
 			DualStack: true,
 		},
-	})
+	}, nil
+}
+
+// initKafkaConsumer creates and configures the Kafka reader for consuming events.
+func (c *AppContext) initKafkaConsumer() error {
+	readerCfg, err := KafkaReaderConfig(c.Config)
+	if err != nil {
+		return err
+	}
+	c.Consumer = kafka.NewReader(readerCfg)
+	return nil
+}
+
+// RegisterShutdownHook adds fn to run during Shutdown, after the HTTP server stops
+// and before the Kafka producer, Kafka consumer and ClickHouse connection close.
+// Hooks run in registration order with their own sub-timeout; an error or
+// timeout is logged and collected, and the remaining hooks still run.
+func (c *AppContext) RegisterShutdownHook(name string, fn func(context.Context) error) {
+	c.hooksMu.Lock()
+	defer c.hooksMu.Unlock()
+	c.hooks = append(c.hooks, shutdownHook{name: name, fn: fn})
 }
 
 // ShutdownChan returns the channel that signals application shutdown.
@@ -166,12 +260,29 @@ func (c *AppContext) ShutdownChan() <-chan struct{} {
 	return c.shutdownCh
 }
 
+// closeReserve is the time Shutdown keeps back for each step still to run, so
+// one blocking Close cannot use up the whole shutdown budget.
+const closeReserve = time.Second
+
+// shutdownStep is one resource stopped by Shutdown. msg and attrs are logged
+// when the step starts; name prefixes its errors.
+type shutdownStep struct {
+	name  string
+	msg   string
+	attrs []any
+	fn    func(context.Context) error
+}
+
 // Shutdown gracefully closes all connections in the proper order.
 // It ensures that:
 // 1. HTTP server stops accepting new requests
-// 2. Kafka producer flushes remaining messages
-// 3. Kafka consumer commits offsets and closes
-// 4. ClickHouse connection is closed
+// 2. Registered shutdown hooks run in order
+// 3. Kafka producer flushes remaining messages
+// 4. Kafka consumer commits offsets and closes
+// 5. ClickHouse connection is closed
+//
+// When ctx has a deadline each step runs with its own sub-timeout; a step that
+// overruns it is abandoned and logged so the remaining steps still run.
 func (c *AppContext) Shutdown(ctx context.Context) error {
 	c.Logger.Info("Starting graceful shutdown")
 
@@ -180,47 +291,115 @@ func (c *AppContext) Shutdown(ctx context.Context) error {
 	// Signal shutdown to any listeners
 	close(c.shutdownCh)
 
+	steps := c.shutdownSteps()
+	for i, step := range steps {
+		if err := c.runShutdownStep(ctx, step, len(steps)-i); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", step.name, err))
+		}
+	}
+
+	if len(errs) > 0 {
+		c.Logger.Error("Shutdown completed with errors", slog.Int("error_count", len(errs)))
+		return fmt.Errorf("shutdown errors: %v", errs)
+	}
+
+	c.Logger.Info("Graceful shutdown completed successfully")
+	return nil
+}
+
+// shutdownSteps lists the steps Shutdown runs, in order.
+func (c *AppContext) shutdownSteps() []shutdownStep {
+	var steps []shutdownStep
+
 	// 1. Shutdown HTTP server first to stop accepting new requests
 	if c.Server != nil {
-		c.Logger.Info("Shutting down HTTP server")
-		if err := c.Server.Shutdown(ctx); err != nil {
-			c.Logger.Error("HTTP server shutdown error", slog.String("error", err.Error()))
-			errs = append(errs, fmt.Errorf("HTTP server shutdown: %w", err))
-		}
+		steps = append(steps, shutdownStep{name: "HTTP server shutdown", msg: "Shutting down HTTP server", fn: c.Server.Shutdown})
+	}
+
+	// 2. Run registered hooks, e.g. flushing a batch consumer before its reader closes
+	c.hooksMu.Lock()
+	for _, hook := range c.hooks {
+		steps = append(steps, shutdownStep{
+			name:  "shutdown hook " + hook.name,
+			msg:   "Running shutdown hook",
+			attrs: []any{slog.String("hook", hook.name)},
+			fn:    hook.fn,
+		})
 	}
+	c.hooksMu.Unlock()
 
-	// 2. Close Kafka producer to flush remaining messages
+	// 3. Close Kafka producer to flush remaining messages
 	if c.Producer != nil {
-		c.Logger.Info("Closing Kafka producer")
-		if err := c.Producer.Close(); err != nil {
-			c.Logger.Error("Kafka producer close error", slog.String("error", err.Error()))
-			errs = append(errs, fmt.Errorf("Kafka producer close: %w", err))
-		}
+		steps = append(steps, shutdownStep{name: "Kafka producer close", msg: "Closing Kafka producer", fn: func(context.Context) error {
+			return c.Producer.Close()
+		}})
 	}
 
-	// 3. Close Kafka consumer to commit offsets
+	// 4. Close Kafka consumer to commit offsets
 	if c.Consumer != nil {
-		c.Logger.Info("Closing Kafka consumer")
-		if err := c.Consumer.Close(); err != nil {
-			c.Logger.Error("Kafka consumer close error", slog.String("error", err.Error()))
-			errs = append(errs, fmt.Errorf("Kafka consumer close: %w", err))
-		}
+		steps = append(steps, shutdownStep{name: "Kafka consumer close", msg: "Closing Kafka consumer", fn: func(context.Context) error {
+			return c.Consumer.Close()
+		}})
 	}
 
-	// 4. Close ClickHouse connection last
+	// 5. Close ClickHouse connection last
 	if c.ClickHouse != nil {
-		c.Logger.Info("Closing ClickHouse connection")
-		if err := c.ClickHouse.Close(); err != nil {
-			c.Logger.Error("ClickHouse close error", slog.String("error", err.Error()))
-			errs = append(errs, fmt.Errorf("ClickHouse close: %w", err))
+		steps = append(steps, shutdownStep{name: "ClickHouse close", msg: "Closing ClickHouse connection", fn: func(context.Context) error {
+			return c.ClickHouse.Close()
+		}})
+	}
+
+	return steps
+}
+
+// runShutdownStep runs step within its share of ctx's remaining time, with
+// stepsLeft counting this step and those after it. A step still running when
+// its sub-timeout expires is abandoned: its goroutine is left behind and
+// Shutdown moves on.
+func (c *AppContext) runShutdownStep(ctx context.Context, step shutdownStep, stepsLeft int) error {
+	c.Logger.Info(step.msg, step.attrs...)
+
+	stepCtx, cancel, budget := shutdownStepContext(ctx, stepsLeft)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- step.fn(stepCtx)
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			c.Logger.Error("Shutdown step failed",
+				slog.String("step", step.name),
+				slog.String("error", err.Error()),
+			)
 		}
+		return err
+	case <-stepCtx.Done():
+		c.Logger.Error("Shutdown step abandoned after timeout",
+			slog.String("step", step.name),
+			slog.Duration("timeout", budget),
+		)
+		return fmt.Errorf("abandoned after %s: %w", budget, stepCtx.Err())
 	}
+}
 
-	if len(errs) > 0 {
-		c.Logger.Error("Shutdown completed with errors", slog.Int("error_count", len(errs)))
-		return fmt.Errorf("shutdown errors: %v", errs)
+// shutdownStepContext derives a step's context from the shutdown context. The
+// step may use the remaining time less closeReserve for each later step, but
+// never less than an even share of it. Without a deadline the step is unbounded.
+func shutdownStepContext(ctx context.Context, stepsLeft int) (context.Context, context.CancelFunc, time.Duration) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		ctx, cancel := context.WithCancel(ctx)
+		return ctx, cancel, 0
 	}
 
-	c.Logger.Info("Graceful shutdown completed successfully")
-	return nil
+	remaining := time.Until(deadline)
+	budget := remaining - time.Duration(stepsLeft-1)*closeReserve
+	if share := remaining / time.Duration(stepsLeft); budget < share {
+		budget = share
+	}
+	ctx, cancel := context.WithTimeout(ctx, budget)
+	return ctx, cancel, budget
 }
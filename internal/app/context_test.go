@@ -0,0 +1,283 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+	kafkalib "github.com/segmentio/kafka-go"
+
+	"fanfinity/internal/kafka"
+)
+
+func TestClickHouseOptions_Protocol(t *testing.T) {
+	tests := []struct {
+		name     string
+		protocol string
+		port     int
+		expected clickhouse.Protocol
+		wantErr  bool
+	}{
+		{name: "native", protocol: "native", port: 9000, expected: clickhouse.Native},
+		{name: "http", protocol: "http", port: 8123, expected: clickhouse.HTTP},
+		{name: "http behind load balancer", protocol: "http", port: 80, expected: clickhouse.HTTP},
+		{name: "native on http port", protocol: "native", port: 8123, wantErr: true},
+		{name: "http on native port", protocol: "http", port: 9000, wantErr: true},
+		{name: "unknown protocol", protocol: "tcp", port: 9000, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts, err := ClickHouseOptions(ClickHouseConfig{
+				Host:     "clickhouse",
+				Port:     tt.port,
+				Protocol: tt.protocol,
+				Database: "fanfinity",
+			})
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if opts.Protocol != tt.expected {
+				t.Errorf("expected protocol %v, got %v", tt.expected, opts.Protocol)
+			}
+		})
+	}
+}
+
+func TestKafkaBootstrapServers_MultipleBrokers(t *testing.T) {
+	t.Setenv("KAFKA_BOOTSTRAP_SERVERS", "kafka-1:9092, kafka-2:9092,,kafka-3:9092")
+
+	cfg := LoadConfig()
+	expected := []string{"kafka-1:9092", "kafka-2:9092", "kafka-3:9092"}
+	if !reflect.DeepEqual(cfg.Kafka.BootstrapServers, expected) {
+		t.Fatalf("expected brokers %v, got %v", expected, cfg.Kafka.BootstrapServers)
+	}
+
+	ctx := &AppContext{Config: cfg}
+
+	ctx.initKafkaProducer()
+	defer ctx.Producer.Close()
+	if got := ctx.Producer.Addr.String(); got != strings.Join(expected, ",") {
+		t.Errorf("expected writer addr %s, got %s", strings.Join(expected, ","), got)
+	}
+
+	if err := ctx.initKafkaConsumer(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer ctx.Consumer.Close()
+	if got := ctx.Consumer.Config().Brokers; !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected reader brokers %v, got %v", expected, got)
+	}
+}
+
+func TestKafkaReaderConfig_FetchSettings(t *testing.T) {
+	t.Setenv("CONSUMER_FETCH_MIN_BYTES", "65536")
+	t.Setenv("CONSUMER_FETCH_MAX_BYTES", "52428800")
+	t.Setenv("CONSUMER_FETCH_MAX_WAIT", "250ms")
+
+	cfg := LoadConfig()
+	readerCfg, err := KafkaReaderConfig(cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if readerCfg.MinBytes != 65536 || readerCfg.MaxBytes != 52428800 || readerCfg.MaxWait != 250*time.Millisecond {
+		t.Errorf("expected min 65536, max 52428800, wait 250ms; got min %d, max %d, wait %v",
+			readerCfg.MinBytes, readerCfg.MaxBytes, readerCfg.MaxWait)
+	}
+
+	ctx := &AppContext{Config: cfg}
+	if err := ctx.initKafkaConsumer(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer ctx.Consumer.Close()
+	if got := ctx.Consumer.Config(); got.MinBytes != 65536 || got.MaxBytes != 52428800 || got.MaxWait != 250*time.Millisecond {
+		t.Errorf("expected the reader to use the fetch settings, got min %d, max %d, wait %v", got.MinBytes, got.MaxBytes, got.MaxWait)
+	}
+}
+
+func TestKafkaReaderConfig_DefaultMaxWaitIsFlushInterval(t *testing.T) {
+	cfg := Config{Consumer: ConsumerConfig{FlushInterval: 2 * time.Second}}.WithDefaults()
+	readerCfg, err := KafkaReaderConfig(cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if readerCfg.MinBytes != kafka.DefaultFetchMinBytes || readerCfg.MaxBytes != kafka.DefaultFetchMaxBytes {
+		t.Errorf("expected default fetch bytes, got min %d, max %d", readerCfg.MinBytes, readerCfg.MaxBytes)
+	}
+	if readerCfg.MaxWait != 2*time.Second {
+		t.Errorf("expected max wait to follow the flush interval, got %v", readerCfg.MaxWait)
+	}
+}
+
+func TestKafkaReaderConfig_InvalidFetchSettings(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*ConsumerConfig)
+	}{
+		{name: "zero min bytes", modify: func(c *ConsumerConfig) { c.FetchMinBytes = 0 }},
+		{name: "max below min", modify: func(c *ConsumerConfig) { c.FetchMinBytes, c.FetchMaxBytes = 1<<20, 1<<10 }},
+		{name: "max too large", modify: func(c *ConsumerConfig) { c.FetchMaxBytes = MaxFetchBytes + 1 }},
+		{name: "max wait too short", modify: func(c *ConsumerConfig) { c.FetchMaxWait = time.Millisecond }},
+		{name: "max wait too long", modify: func(c *ConsumerConfig) { c.FetchMaxWait = time.Hour }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Config{}.WithDefaults()
+			tt.modify(&cfg.Consumer)
+			if _, err := KafkaReaderConfig(cfg); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestKafkaBootstrapServers_SingleBroker(t *testing.T) {
+	t.Setenv("KAFKA_BOOTSTRAP_SERVERS", "localhost:9092")
+
+	cfg := LoadConfig()
+	if !reflect.DeepEqual(cfg.Kafka.BootstrapServers, []string{"localhost:9092"}) {
+		t.Fatalf("expected single broker, got %v", cfg.Kafka.BootstrapServers)
+	}
+
+	ctx := &AppContext{Config: cfg}
+	ctx.initKafkaProducer()
+	defer ctx.Producer.Close()
+	if got := ctx.Producer.Addr.String(); got != "localhost:9092" {
+		t.Errorf("expected writer addr localhost:9092, got %s", got)
+	}
+}
+
+func TestShutdown_HooksRunInOrderBeforeKafkaClose(t *testing.T) {
+	var logs bytes.Buffer
+	appCtx := &AppContext{
+		Logger:     slog.New(slog.NewTextHandler(&logs, nil)),
+		Producer:   &kafkalib.Writer{},
+		shutdownCh: make(chan struct{}),
+	}
+
+	var order []string
+	appCtx.RegisterShutdownHook("first", func(context.Context) error {
+		order = append(order, "first")
+		return nil
+	})
+	appCtx.RegisterShutdownHook("failing", func(context.Context) error {
+		order = append(order, "failing")
+		return errors.New("flush failed")
+	})
+	appCtx.RegisterShutdownHook("last", func(context.Context) error {
+		order = append(order, "last")
+		return nil
+	})
+
+	err := appCtx.Shutdown(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "shutdown hook failing") {
+		t.Errorf("expected collected hook error, got %v", err)
+	}
+
+	if !reflect.DeepEqual(order, []string{"first", "failing", "last"}) {
+		t.Errorf("expected hooks in registration order, got %v", order)
+	}
+
+	output := logs.String()
+	lastHook := strings.Index(output, "hook=last")
+	producerClose := strings.Index(output, "Closing Kafka producer")
+	if lastHook < 0 || producerClose < 0 || lastHook > producerClose {
+		t.Errorf("expected hooks to run before the producer closes, logs:\n%s", output)
+	}
+}
+
+// blockingConn is a ClickHouse connection whose Close blocks until released.
+type blockingConn struct {
+	driver.Conn
+	release chan struct{}
+}
+
+func (c *blockingConn) Close() error {
+	<-c.release
+	return nil
+}
+
+func TestShutdown_AbandonsBlockingCloseWithinBudget(t *testing.T) {
+	var logs bytes.Buffer
+	conn := &blockingConn{release: make(chan struct{})}
+	defer close(conn.release)
+
+	appCtx := &AppContext{
+		Logger:     slog.New(slog.NewTextHandler(&logs, nil)),
+		Producer:   &kafkalib.Writer{},
+		ClickHouse: conn,
+		shutdownCh: make(chan struct{}),
+	}
+	hookRan := false
+	appCtx.RegisterShutdownHook("stuck flush", func(ctx context.Context) error {
+		<-ctx.Done()
+		select {} // ignores cancellation, like a Close flushing to a dead broker
+	})
+	appCtx.RegisterShutdownHook("after stuck", func(context.Context) error {
+		hookRan = true
+		return nil
+	})
+
+	budget := 300 * time.Millisecond
+	ctx, cancel := context.WithTimeout(context.Background(), budget)
+	defer cancel()
+
+	start := time.Now()
+	err := appCtx.Shutdown(ctx)
+	elapsed := time.Since(start)
+
+	if elapsed > budget+200*time.Millisecond {
+		t.Errorf("expected shutdown within %s, took %s", budget, elapsed)
+	}
+	if !hookRan {
+		t.Error("expected steps after the stuck hook to still run")
+	}
+	if err == nil || !strings.Contains(err.Error(), "shutdown hook stuck flush") || !strings.Contains(err.Error(), "ClickHouse close") {
+		t.Errorf("expected both abandoned steps to be reported, got %v", err)
+	}
+	if !strings.Contains(logs.String(), "Shutdown step abandoned after timeout") {
+		t.Errorf("expected abandoned steps to be logged, logs:\n%s", logs.String())
+	}
+}
+
+func TestShutdownStepContext(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// A long budget leaves closeReserve for each later step
+	_, stepCancel, budget := shutdownStepContext(ctx, 3)
+	stepCancel()
+	if budget > 8*time.Second || budget < 7*time.Second {
+		t.Errorf("expected about 8s with two steps left, got %s", budget)
+	}
+
+	// A short budget is shared evenly
+	short, cancelShort := context.WithTimeout(context.Background(), time.Second)
+	defer cancelShort()
+	_, stepCancel, budget = shutdownStepContext(short, 4)
+	stepCancel()
+	if budget > 250*time.Millisecond || budget < 200*time.Millisecond {
+		t.Errorf("expected about a quarter of the budget, got %s", budget)
+	}
+
+	// Without a deadline the step is unbounded
+	stepCtx, stepCancel, budget := shutdownStepContext(context.Background(), 2)
+	defer stepCancel()
+	if _, ok := stepCtx.Deadline(); ok || budget != 0 {
+		t.Errorf("expected no deadline, got budget %s", budget)
+	}
+}
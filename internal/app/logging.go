@@ -0,0 +1,164 @@
+package app
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// ParseLogLevel maps debug, info, warn or error to a slog.Level,
+// case-insensitively. Unknown values default to info.
+func ParseLogLevel(s string) slog.Level {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "debug":
+		return slog.LevelDebug
+	case "warn", "warning":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// NewLogHandler builds the handler shared by both binaries: JSON, or text when
+// cfg.Format is "text", at cfg.Level, sampling the DefaultSampledMessages.
+func NewLogHandler(w io.Writer, cfg LogConfig) slog.Handler {
+	handler, _ := newLogHandler(w, cfg)
+	return handler
+}
+
+// NewLogger returns a logger writing to w using NewLogHandler.
+func NewLogger(w io.Writer, cfg LogConfig) *slog.Logger {
+	return slog.New(NewLogHandler(w, cfg))
+}
+
+// NewLoggerWithControl is NewLogger, also returning a LogControl that changes
+// the logger's level and sample rate while it is in use.
+func NewLoggerWithControl(w io.Writer, cfg LogConfig) (*slog.Logger, *LogControl) {
+	handler, control := newLogHandler(w, cfg)
+	return slog.New(handler), control
+}
+
+func newLogHandler(w io.Writer, cfg LogConfig) (*SamplingHandler, *LogControl) {
+	level := &slog.LevelVar{}
+	level.Set(ParseLogLevel(cfg.Level))
+	opts := &slog.HandlerOptions{Level: level}
+
+	var handler slog.Handler
+	if strings.EqualFold(strings.TrimSpace(cfg.Format), "text") {
+		handler = slog.NewTextHandler(w, opts)
+	} else {
+		handler = slog.NewJSONHandler(w, opts)
+	}
+	sampler := NewSamplingHandler(handler, cfg.SampleRate, DefaultSampledMessages)
+	return sampler, &LogControl{level: level, sampler: sampler}
+}
+
+// LogControl changes the level and sample rate of a logger built by
+// NewLoggerWithControl, including loggers derived from it with With.
+type LogControl struct {
+	level   *slog.LevelVar
+	sampler *SamplingHandler
+}
+
+// Level returns the minimum level currently logged.
+func (c *LogControl) Level() slog.Level {
+	return c.level.Level()
+}
+
+// SetLevel changes the minimum level logged.
+func (c *LogControl) SetLevel(level slog.Level) {
+	c.level.Set(level)
+}
+
+// SetSampleRate changes the 1-in-N rate for sampled debug lines.
+func (c *LogControl) SetSampleRate(rate int) {
+	c.sampler.SetRate(rate)
+}
+
+// DefaultSampledMessages are the high-frequency debug log messages that are
+// sampled by SamplingHandler. All other records pass through unchanged.
+var DefaultSampledMessages = []string{
+	"message added to batch",
+	"successfully produced event to Kafka",
+	"successfully produced batch to Kafka",
+}
+
+// SamplingHandler is a slog.Handler wrapper that emits only 1-in-N debug
+// records for a fixed set of high-frequency messages. Records at Info level
+// and above, and debug records with other messages, are always emitted.
+type SamplingHandler struct {
+	next     slog.Handler
+	rate     *atomic.Uint64 // shared across derived handlers
+	messages map[string]bool
+	counters *sync.Map // message -> *atomic.Uint64, shared across derived handlers
+}
+
+// NewSamplingHandler wraps next so that the given debug messages are emitted
+// once every rate records. A rate of 1 or less disables sampling.
+func NewSamplingHandler(next slog.Handler, rate int, messages []string) *SamplingHandler {
+	if rate < 1 {
+		rate = 1
+	}
+	set := make(map[string]bool, len(messages))
+	for _, msg := range messages {
+		set[msg] = true
+	}
+	h := &SamplingHandler{
+		next:     next,
+		rate:     &atomic.Uint64{},
+		messages: set,
+		counters: &sync.Map{},
+	}
+	h.rate.Store(uint64(rate))
+	return h
+}
+
+// SetRate changes the sample rate of h and every handler derived from it.
+// A rate of 1 or less disables sampling.
+func (h *SamplingHandler) SetRate(rate int) {
+	if rate < 1 {
+		rate = 1
+	}
+	h.rate.Store(uint64(rate))
+}
+
+// Enabled reports whether the wrapped handler handles records at the given level.
+func (h *SamplingHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+// Handle drops sampled debug records that fall outside the 1-in-N window.
+func (h *SamplingHandler) Handle(ctx context.Context, record slog.Record) error {
+	if rate := h.rate.Load(); rate > 1 && record.Level < slog.LevelInfo && h.messages[record.Message] {
+		counter, _ := h.counters.LoadOrStore(record.Message, &atomic.Uint64{})
+		if counter.(*atomic.Uint64).Add(1)%rate != 1 {
+			return nil
+		}
+	}
+	return h.next.Handle(ctx, record)
+}
+
+// WithAttrs returns a SamplingHandler whose wrapped handler has the given attributes.
+func (h *SamplingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &SamplingHandler{
+		next:     h.next.WithAttrs(attrs),
+		rate:     h.rate,
+		messages: h.messages,
+		counters: h.counters,
+	}
+}
+
+// WithGroup returns a SamplingHandler whose wrapped handler uses the given group.
+func (h *SamplingHandler) WithGroup(name string) slog.Handler {
+	return &SamplingHandler{
+		next:     h.next.WithGroup(name),
+		rate:     h.rate,
+		messages: h.messages,
+		counters: h.counters,
+	}
+}
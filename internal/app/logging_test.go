@@ -0,0 +1,136 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func newTestSamplingLogger(buf *bytes.Buffer, rate int) *slog.Logger {
+	base := slog.NewJSONHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug})
+	return slog.New(NewSamplingHandler(base, rate, DefaultSampledMessages))
+}
+
+func TestSamplingHandler_SamplesOneInN(t *testing.T) {
+	var buf bytes.Buffer
+	logger := newTestSamplingLogger(&buf, 10)
+
+	for i := 0; i < 1000; i++ {
+		logger.Debug("message added to batch", slog.Int("i", i))
+	}
+
+	emitted := strings.Count(buf.String(), "message added to batch")
+	if emitted < 90 || emitted > 110 {
+		t.Errorf("expected roughly 100 of 1000 records, got %d", emitted)
+	}
+}
+
+func TestSamplingHandler_AlwaysLogsWarningsAndErrors(t *testing.T) {
+	var buf bytes.Buffer
+	logger := newTestSamplingLogger(&buf, 10)
+
+	for i := 0; i < 20; i++ {
+		logger.Warn("message added to batch")
+		logger.Error("message added to batch")
+	}
+
+	if emitted := strings.Count(buf.String(), "message added to batch"); emitted != 40 {
+		t.Errorf("expected all 40 warning/error records, got %d", emitted)
+	}
+}
+
+func TestSamplingHandler_UnsampledDebugMessagesPassThrough(t *testing.T) {
+	var buf bytes.Buffer
+	logger := newTestSamplingLogger(&buf, 10)
+
+	for i := 0; i < 20; i++ {
+		logger.Debug("flushing batch")
+	}
+
+	if emitted := strings.Count(buf.String(), "flushing batch"); emitted != 20 {
+		t.Errorf("expected all 20 unsampled debug records, got %d", emitted)
+	}
+}
+
+func TestSamplingHandler_SharesCountersAcrossDerivedLoggers(t *testing.T) {
+	var buf bytes.Buffer
+	logger := newTestSamplingLogger(&buf, 10)
+	child := logger.With(slog.String("component", "consumer"))
+
+	for i := 0; i < 50; i++ {
+		logger.Debug("message added to batch")
+		child.Debug("message added to batch")
+	}
+
+	if emitted := strings.Count(buf.String(), "message added to batch"); emitted != 10 {
+		t.Errorf("expected 10 of 100 records across derived loggers, got %d", emitted)
+	}
+}
+
+func TestSamplingHandler_RateOneDisablesSampling(t *testing.T) {
+	var buf bytes.Buffer
+	logger := newTestSamplingLogger(&buf, 1)
+
+	for i := 0; i < 15; i++ {
+		logger.Debug("successfully produced event to Kafka")
+	}
+
+	if emitted := strings.Count(buf.String(), "successfully produced event to Kafka"); emitted != 15 {
+		t.Errorf("expected all 15 records with rate 1, got %d", emitted)
+	}
+}
+
+func TestSamplingHandler_Enabled(t *testing.T) {
+	base := slog.NewJSONHandler(&bytes.Buffer{}, &slog.HandlerOptions{Level: slog.LevelInfo})
+	h := NewSamplingHandler(base, 10, DefaultSampledMessages)
+
+	if h.Enabled(context.Background(), slog.LevelDebug) {
+		t.Error("expected debug to be disabled when wrapped handler is at info")
+	}
+	if !h.Enabled(context.Background(), slog.LevelInfo) {
+		t.Error("expected info to be enabled")
+	}
+}
+
+func TestParseLogLevel(t *testing.T) {
+	testCases := map[string]slog.Level{
+		"debug":   slog.LevelDebug,
+		"INFO":    slog.LevelInfo,
+		" warn ":  slog.LevelWarn,
+		"error":   slog.LevelError,
+		"":        slog.LevelInfo,
+		"verbose": slog.LevelInfo,
+	}
+	for input, want := range testCases {
+		if got := ParseLogLevel(input); got != want {
+			t.Errorf("ParseLogLevel(%q) = %v, want %v", input, got, want)
+		}
+	}
+}
+
+func TestNewLogHandler_ConfiguredLevel(t *testing.T) {
+	ctx := context.Background()
+
+	handler := NewLogHandler(&bytes.Buffer{}, LogConfig{Level: "warn"})
+	if handler.Enabled(ctx, slog.LevelInfo) {
+		t.Error("expected info to be disabled at warn level")
+	}
+	if !handler.Enabled(ctx, slog.LevelWarn) {
+		t.Error("expected warn to be enabled at warn level")
+	}
+
+	var buf bytes.Buffer
+	logger := NewLogger(&buf, LogConfig{Level: "debug", Format: "text"})
+	logger.Debug("debug record emitted")
+	if out := buf.String(); !strings.Contains(out, "level=DEBUG") || !strings.Contains(out, "debug record emitted") {
+		t.Errorf("expected a text debug record, got %q", out)
+	}
+
+	buf.Reset()
+	NewLogger(&buf, LogConfig{Level: "info"}).Debug("debug record dropped")
+	if buf.Len() != 0 {
+		t.Errorf("expected no debug output at info level, got %q", buf.String())
+	}
+}
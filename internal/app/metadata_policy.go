@@ -0,0 +1,27 @@
+package app
+
+import (
+	"fmt"
+	"os"
+
+	"fanfinity/internal/domain"
+)
+
+// LoadMetadataPolicy reads the required-metadata policy from a JSON file mapping
+// event types to required keys. An empty path yields an empty policy.
+func LoadMetadataPolicy(path string) (domain.MetadataPolicy, error) {
+	if path == "" {
+		return domain.MetadataPolicy{}, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read metadata policy: %w", err)
+	}
+
+	policy, err := domain.ParseMetadataPolicy(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid metadata policy %s: %w", path, err)
+	}
+	return policy, nil
+}
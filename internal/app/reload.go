@@ -0,0 +1,246 @@
+package app
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"reflect"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// ConfigFileEnv names the environment variable holding the path of an
+// optional KEY=VALUE config file, applied over the environment at startup and
+// re-read on SIGHUP.
+const ConfigFileEnv = "CONFIG_FILE"
+
+// LoadConfigFromFile applies the file named by CONFIG_FILE, if set, to the
+// environment and then loads the configuration with LoadConfig.
+func LoadConfigFromFile() (*Config, error) {
+	if path := os.Getenv(ConfigFileEnv); path != "" {
+		if err := LoadEnvFile(path); err != nil {
+			return nil, err
+		}
+	}
+	return LoadConfig(), nil
+}
+
+// envFile records each key LoadEnvFile has set and the value it had before
+// the file first set it, so a key later removed from the file is restored.
+var envFile = struct {
+	mu    sync.Mutex
+	prior map[string]*string
+}{prior: make(map[string]*string)}
+
+// LoadEnvFile sets an environment variable for each KEY=VALUE line of the file
+// at path. Blank lines and lines starting with '#' are skipped. A key set by an
+// earlier load but no longer in the file gets back the value it had before,
+// or is unset if it had none. Nothing is applied if the file is malformed.
+func LoadEnvFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open config file: %w", err)
+	}
+	defer f.Close()
+
+	values := make(map[string]string)
+	var keys []string
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(text, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return fmt.Errorf("config file %s line %d: expected KEY=VALUE", path, line)
+		}
+		if _, seen := values[key]; !seen {
+			keys = append(keys, key)
+		}
+		values[key] = strings.TrimSpace(value)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	envFile.mu.Lock()
+	defer envFile.mu.Unlock()
+	for key, prior := range envFile.prior {
+		if _, ok := values[key]; ok {
+			continue
+		}
+		if prior != nil {
+			os.Setenv(key, *prior)
+		} else {
+			os.Unsetenv(key)
+		}
+		delete(envFile.prior, key)
+	}
+	for _, key := range keys {
+		if _, ok := envFile.prior[key]; !ok {
+			var prior *string
+			if value, set := os.LookupEnv(key); set {
+				prior = &value
+			}
+			envFile.prior[key] = prior
+		}
+		if err := os.Setenv(key, values[key]); err != nil {
+			return fmt.Errorf("config file %s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// ReloadableConsumer is the subset of the batch consumer whose settings can
+// change while it runs.
+type ReloadableConsumer interface {
+	SetBatchSize(n int)
+	SetFlushInterval(d time.Duration)
+}
+
+// Reloader applies the hot-reloadable subset of a new configuration to the
+// running process: the log level and sample rate, and the consumer's batch
+// size and flush interval. Other changes, such as connection settings, need
+// a restart and are logged and ignored.
+type Reloader struct {
+	logger   *slog.Logger
+	log      *LogControl
+	consumer ReloadableConsumer
+
+	// load reads the new configuration; LoadConfigFromFile by default.
+	load func() (*Config, error)
+
+	mu      sync.Mutex
+	current *Config
+}
+
+// NewReloader creates a Reloader for a process started with cfg. log and
+// consumer may be nil when the process has no such component.
+func NewReloader(cfg *Config, logger *slog.Logger, log *LogControl, consumer ReloadableConsumer) *Reloader {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &Reloader{
+		logger:   logger,
+		log:      log,
+		consumer: consumer,
+		load:     LoadConfigFromFile,
+		current:  cfg,
+	}
+}
+
+// Reload re-reads the configuration and applies it. The running settings are
+// left unchanged if the configuration cannot be read.
+func (r *Reloader) Reload() error {
+	next, err := r.load()
+	if err != nil {
+		return err
+	}
+	r.Apply(next)
+	return nil
+}
+
+// Apply applies the hot-reloadable settings of next that differ from the
+// current configuration, logging each change.
+func (r *Reloader) Apply(next *Config) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	old := r.current
+
+	if r.log != nil {
+		if level := ParseLogLevel(next.Log.Level); level != ParseLogLevel(old.Log.Level) {
+			r.log.SetLevel(level)
+			r.logChange("LOG_LEVEL", old.Log.Level, next.Log.Level)
+		}
+		if next.Log.SampleRate != old.Log.SampleRate {
+			r.log.SetSampleRate(next.Log.SampleRate)
+			r.logChange("LOG_SAMPLE_RATE", old.Log.SampleRate, next.Log.SampleRate)
+		}
+	}
+	if r.consumer != nil {
+		if next.Consumer.BatchSize != old.Consumer.BatchSize && next.Consumer.BatchSize > 0 {
+			r.consumer.SetBatchSize(next.Consumer.BatchSize)
+			r.logChange("CONSUMER_BATCH_SIZE", old.Consumer.BatchSize, next.Consumer.BatchSize)
+		}
+		if next.Consumer.FlushInterval != old.Consumer.FlushInterval && next.Consumer.FlushInterval > 0 {
+			r.consumer.SetFlushInterval(next.Consumer.FlushInterval)
+			r.logChange("CONSUMER_FLUSH_INTERVAL", old.Consumer.FlushInterval.String(), next.Consumer.FlushInterval.String())
+		}
+	}
+
+	if sections := restartOnlyChanges(old, next); len(sections) > 0 {
+		r.logger.Warn("ignoring config changes that require a restart",
+			slog.Any("sections", sections),
+		)
+	}
+
+	// Only the applied settings move forward, so a later reload that reverts
+	// an ignored change is not reported again.
+	applied := *old
+	applied.Log.Level = next.Log.Level
+	applied.Log.SampleRate = next.Log.SampleRate
+	if next.Consumer.BatchSize > 0 {
+		applied.Consumer.BatchSize = next.Consumer.BatchSize
+	}
+	if next.Consumer.FlushInterval > 0 {
+		applied.Consumer.FlushInterval = next.Consumer.FlushInterval
+	}
+	r.current = &applied
+}
+
+func (r *Reloader) logChange(setting string, from, to any) {
+	r.logger.Info("config reloaded",
+		slog.String("setting", setting),
+		slog.Any("from", from),
+		slog.Any("to", to),
+	)
+}
+
+// restartOnlyChanges returns the names of the Config sections that differ
+// between old and next once the hot-reloadable settings are ignored.
+func restartOnlyChanges(old, next *Config) []string {
+	rest := *next
+	rest.Log.Level = old.Log.Level
+	rest.Log.SampleRate = old.Log.SampleRate
+	rest.Consumer.BatchSize = old.Consumer.BatchSize
+	rest.Consumer.FlushInterval = old.Consumer.FlushInterval
+
+	oldValue, nextValue := reflect.ValueOf(*old), reflect.ValueOf(rest)
+	var sections []string
+	for i := 0; i < oldValue.NumField(); i++ {
+		if !reflect.DeepEqual(oldValue.Field(i).Interface(), nextValue.Field(i).Interface()) {
+			sections = append(sections, oldValue.Type().Field(i).Name)
+		}
+	}
+	return sections
+}
+
+// WatchReload reloads the configuration each time the process receives
+// SIGHUP, until ctx is done. Failed reloads are logged and leave the running
+// settings unchanged.
+func (r *Reloader) WatchReload(ctx context.Context) {
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGHUP)
+	defer signal.Stop(sigCh)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-sigCh:
+			r.logger.Info("reload signal received, re-reading config")
+			if err := r.Reload(); err != nil {
+				r.logger.Error("config reload failed",
+					slog.String("error", err.Error()),
+				)
+			}
+		}
+	}
+}
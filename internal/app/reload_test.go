@@ -0,0 +1,127 @@
+package app
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"fanfinity/internal/kafka"
+)
+
+func writeConfigFile(t *testing.T, lines ...string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "fanfinity.env")
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0o600); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+	t.Setenv(ConfigFileEnv, path)
+}
+
+func TestReloader_AppliesConsumerAndLogSettings(t *testing.T) {
+	// Restore whatever the config file sets once the test ends
+	for _, key := range []string{"CONSUMER_BATCH_SIZE", "CONSUMER_FLUSH_INTERVAL", "LOG_LEVEL", "KAFKA_BOOTSTRAP_SERVERS"} {
+		t.Setenv(key, os.Getenv(key))
+	}
+	t.Setenv("CONSUMER_BATCH_SIZE", "100")
+	t.Setenv("CONSUMER_FLUSH_INTERVAL", "5s")
+	t.Setenv("LOG_LEVEL", "info")
+	cfg := LoadConfig()
+
+	var buf bytes.Buffer
+	logger, logControl := NewLoggerWithControl(&buf, cfg.Log)
+	consumer := kafka.NewBatchConsumer(kafka.BatchConsumerConfig{
+		BatchSize:     cfg.Consumer.BatchSize,
+		FlushInterval: cfg.Consumer.FlushInterval,
+		Logger:        slog.New(slog.NewTextHandler(io.Discard, nil)),
+	})
+
+	writeConfigFile(t,
+		"# tuned for the derby",
+		"CONSUMER_BATCH_SIZE=250",
+		"CONSUMER_FLUSH_INTERVAL=2s",
+		"LOG_LEVEL=debug",
+		"KAFKA_BOOTSTRAP_SERVERS=other:9092",
+	)
+	reloader := NewReloader(cfg, logger, logControl, consumer)
+	if err := reloader.Reload(); err != nil {
+		t.Fatalf("unexpected reload error: %v", err)
+	}
+
+	if got := consumer.BatchSize(); got != 250 {
+		t.Errorf("expected batch size 250 after reload, got %d", got)
+	}
+	if got := consumer.FlushInterval(); got != 2*time.Second {
+		t.Errorf("expected flush interval 2s after reload, got %v", got)
+	}
+	if got := logControl.Level(); got != slog.LevelDebug {
+		t.Errorf("expected log level debug after reload, got %v", got)
+	}
+
+	logs := buf.String()
+	if !strings.Contains(logs, `"setting":"CONSUMER_BATCH_SIZE","from":100,"to":250`) {
+		t.Errorf("expected the batch size change to be logged, got %s", logs)
+	}
+	if !strings.Contains(logs, "ignoring config changes that require a restart") || !strings.Contains(logs, `"Kafka"`) {
+		t.Errorf("expected the Kafka change to be logged as ignored, got %s", logs)
+	}
+}
+
+func TestReloader_KeepsSettingsOnBadConfigFile(t *testing.T) {
+	t.Setenv("CONSUMER_BATCH_SIZE", "100")
+	cfg := LoadConfig()
+	consumer := kafka.NewBatchConsumer(kafka.BatchConsumerConfig{
+		BatchSize: cfg.Consumer.BatchSize,
+		Logger:    slog.New(slog.NewTextHandler(io.Discard, nil)),
+	})
+
+	writeConfigFile(t, "CONSUMER_BATCH_SIZE")
+	reloader := NewReloader(cfg, slog.New(slog.NewTextHandler(io.Discard, nil)), nil, consumer)
+	if err := reloader.Reload(); err == nil {
+		t.Fatal("expected an error for a line without '='")
+	}
+	if got := consumer.BatchSize(); got != 100 {
+		t.Errorf("expected batch size to stay 100, got %d", got)
+	}
+}
+
+func TestLoadEnvFile_RestoresRemovedKeys(t *testing.T) {
+	t.Setenv("FANFINITY_TEST_KEPT", "")
+	t.Setenv("FANFINITY_TEST_PRESET", "from-env")
+	os.Unsetenv("FANFINITY_TEST_ADDED")
+	t.Cleanup(func() { os.Unsetenv("FANFINITY_TEST_ADDED") })
+
+	path := filepath.Join(t.TempDir(), "fanfinity.env")
+	write := func(lines ...string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0o600); err != nil {
+			t.Fatalf("failed to write config file: %v", err)
+		}
+	}
+
+	write("FANFINITY_TEST_KEPT=1", "FANFINITY_TEST_PRESET=from-file", "FANFINITY_TEST_ADDED=1")
+	if err := LoadEnvFile(path); err != nil {
+		t.Fatalf("unexpected load error: %v", err)
+	}
+	if got := os.Getenv("FANFINITY_TEST_PRESET"); got != "from-file" {
+		t.Fatalf("expected the file to override the environment, got %q", got)
+	}
+
+	write("FANFINITY_TEST_KEPT=2")
+	if err := LoadEnvFile(path); err != nil {
+		t.Fatalf("unexpected load error: %v", err)
+	}
+	if got := os.Getenv("FANFINITY_TEST_KEPT"); got != "2" {
+		t.Errorf("expected the kept key to be updated, got %q", got)
+	}
+	if got := os.Getenv("FANFINITY_TEST_PRESET"); got != "from-env" {
+		t.Errorf("expected the removed key to get its environment value back, got %q", got)
+	}
+	if _, set := os.LookupEnv("FANFINITY_TEST_ADDED"); set {
+		t.Error("expected the removed key the file added to be unset")
+	}
+}
@@ -0,0 +1,61 @@
+package domain
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock tells the current time. Components that stamp or bound times take a
+// Clock so tests can pin it; nil means SystemClock.
+type Clock interface {
+	Now() time.Time
+}
+
+// SystemClock reads the system time.
+type SystemClock struct{}
+
+// Now returns time.Now().
+func (SystemClock) Now() time.Time {
+	return time.Now()
+}
+
+// ClockOrSystem returns c, or SystemClock if c is nil.
+func ClockOrSystem(c Clock) Clock {
+	if c == nil {
+		return SystemClock{}
+	}
+	return c
+}
+
+// FixedClock is a Clock that only moves when told to, for deterministic tests.
+// It is safe for concurrent use.
+type FixedClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// NewFixedClock creates a FixedClock stopped at now.
+func NewFixedClock(now time.Time) *FixedClock {
+	return &FixedClock{now: now}
+}
+
+// Now returns the clock's current time.
+func (c *FixedClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Set moves the clock to now.
+func (c *FixedClock) Set(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = now
+}
+
+// Advance moves the clock forward by d.
+func (c *FixedClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
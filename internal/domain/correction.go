@@ -0,0 +1,61 @@
+package domain
+
+import (
+	"github.com/google/uuid"
+)
+
+// EventTypeCorrection retracts or supersedes a previously ingested event.
+// It is accepted at ingestion but is not a match event, so it is absent from
+// ValidEventTypes and never counted in metrics.
+const EventTypeCorrection EventType = "correction"
+
+// CorrectionAction describes what a correction does to the event it references.
+type CorrectionAction string
+
+// Supported correction actions. Both exclude the referenced event from metrics;
+// an amend is followed by the corrected event ingested under a new eventId.
+const (
+	CorrectionActionDelete CorrectionAction = "delete"
+	CorrectionActionAmend  CorrectionAction = "amend"
+)
+
+// Correction is the parsed metadata of a correction event.
+type Correction struct {
+	CorrectsEventID uuid.UUID
+	Action          CorrectionAction
+}
+
+// IsCorrection reports whether the event is a correction.
+func (e *Event) IsCorrection() bool {
+	return e.EventType == EventTypeCorrection
+}
+
+// Correction parses the correction metadata of a correction event.
+// Returns a ValidationError if metadata.correctsEventId or metadata.action is missing or invalid.
+func (e *Event) Correction() (*Correction, error) {
+	return parseCorrection(e.EventID, e.Metadata)
+}
+
+// parseCorrection validates correction metadata for the event with the given ID.
+func parseCorrection(eventID uuid.UUID, metadata map[string]interface{}) (*Correction, error) {
+	raw, _ := metadataString(metadata, "correctsEventId")
+	target, err := uuid.Parse(raw)
+	if err != nil {
+		return nil, NewValidationError("metadata.correctsEventId", "must be a valid UUID")
+	}
+	if target == eventID {
+		return nil, NewValidationError("metadata.correctsEventId", "must reference a different event")
+	}
+
+	action, _ := metadataString(metadata, "action")
+	switch CorrectionAction(action) {
+	case CorrectionActionDelete, CorrectionActionAmend:
+	default:
+		return nil, NewValidationError("metadata.action", "must be delete or amend")
+	}
+
+	return &Correction{
+		CorrectsEventID: target,
+		Action:          CorrectionAction(action),
+	}, nil
+}
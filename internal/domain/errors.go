@@ -1,6 +1,136 @@
 package domain
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrMessageTooLarge is returned when a serialized event exceeds the maximum
+// message size accepted by the message broker.
+var ErrMessageTooLarge = errors.New("message exceeds maximum size")
+
+// ErrBrokerUnavailable is returned when the message broker is considered down
+// and events are rejected without attempting a write.
+var ErrBrokerUnavailable = errors.New("message broker unavailable")
+
+// ErrMatchNotFound is returned by the repository when a match has no stored events.
+var ErrMatchNotFound = errors.New("match not found")
+
+// ErrMatchClosed is returned when closing a match that already has a final snapshot.
+var ErrMatchClosed = errors.New("match already closed")
+
+// RepositoryErrorCategory classifies why a repository call failed.
+type RepositoryErrorCategory int
+
+const (
+	// RepositoryErrorQuery is a query the store rejected or failed to run.
+	RepositoryErrorQuery RepositoryErrorCategory = iota
+	// RepositoryErrorConnection is a store that could not be reached.
+	RepositoryErrorConnection
+	// RepositoryErrorTimeout is a call that ran past its deadline.
+	RepositoryErrorTimeout
+	// RepositoryErrorScan is a result row that could not be decoded.
+	RepositoryErrorScan
+)
+
+// String returns the category name used in logs.
+func (c RepositoryErrorCategory) String() string {
+	switch c {
+	case RepositoryErrorConnection:
+		return "connection"
+	case RepositoryErrorTimeout:
+		return "timeout"
+	case RepositoryErrorScan:
+		return "scan"
+	default:
+		return "query"
+	}
+}
+
+// RepositoryError is a repository failure with its category, so callers can
+// tell an unreachable store from a slow query or a bad row without matching
+// on the message. Use errors.As to extract it from a wrapped error.
+type RepositoryError struct {
+	Category RepositoryErrorCategory
+	// Op describes the failed operation, e.g. "failed to query match metrics".
+	Op  string
+	Err error
+}
+
+// Error implements the error interface.
+func (e *RepositoryError) Error() string {
+	if e.Op == "" {
+		return e.Err.Error()
+	}
+	return e.Op + ": " + e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *RepositoryError) Unwrap() error {
+	return e.Err
+}
+
+// NewRepositoryError creates a RepositoryError of the given category.
+func NewRepositoryError(category RepositoryErrorCategory, op string, err error) *RepositoryError {
+	return &RepositoryError{
+		Category: category,
+		Op:       op,
+		Err:      err,
+	}
+}
+
+// AsRepositoryError extracts a RepositoryError from anywhere in err's chain.
+// Returns nil if there is none.
+func AsRepositoryError(err error) *RepositoryError {
+	var re *RepositoryError
+	if errors.As(err, &re) {
+		return re
+	}
+	return nil
+}
+
+// UnstorableEventError reports an event the store cannot hold as-is, caught
+// before the event is written.
+type UnstorableEventError struct {
+	Event *Event
+	// Field names the offending value, e.g. "metadata" or "timestamp".
+	Field string
+	Err   error
+}
+
+// Error implements the error interface.
+func (e *UnstorableEventError) Error() string {
+	return fmt.Sprintf("event %s has unstorable %s: %v", e.Event.EventID, e.Field, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *UnstorableEventError) Unwrap() error {
+	return e.Err
+}
+
+// PartialInsertError is returned by a batch write that stored every event
+// except Rejected, which were refused before the write.
+type PartialInsertError struct {
+	Rejected []*UnstorableEventError
+}
+
+// Error implements the error interface.
+func (e *PartialInsertError) Error() string {
+	if len(e.Rejected) == 1 {
+		return "1 event not stored: " + e.Rejected[0].Error()
+	}
+	return fmt.Sprintf("%d events not stored, first: %v", len(e.Rejected), e.Rejected[0])
+}
+
+// AsPartialInsertError extracts a PartialInsertError from anywhere in err's
+// chain. Returns nil if there is none.
+func AsPartialInsertError(err error) *PartialInsertError {
+	var pe *PartialInsertError
+	if errors.As(err, &pe) {
+		return pe
+	}
+	return nil
+}
 
 // ValidationError represents a field validation failure.
 type ValidationError struct {
@@ -2,6 +2,9 @@ package domain
 
 import (
 	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -40,6 +43,77 @@ var ValidEventTypes = map[EventType]bool{
 	EventTypeInterception: true,
 }
 
+// defaultEventTypeAliases maps legacy event type names to their canonical types.
+var defaultEventTypeAliases = map[string]EventType{
+	"freekick":     EventTypeFreeKick,
+	"penalty_kick": EventTypeFreeKick,
+}
+
+// DefaultEventTypeAliases returns a copy of the legacy event type names accepted at ingestion.
+func DefaultEventTypeAliases() map[string]EventType {
+	aliases := make(map[string]EventType, len(defaultEventTypeAliases))
+	for alias, eventType := range defaultEventTypeAliases {
+		aliases[alias] = eventType
+	}
+	return aliases
+}
+
+// ParseEventTypeAliases parses a comma-separated list of alias=type pairs,
+// e.g. "freekick=free_kick", adding them to the default aliases.
+// Returns a ValidationError if a target is not a valid event type.
+func ParseEventTypeAliases(s string) (map[string]EventType, error) {
+	aliases := DefaultEventTypeAliases()
+	if strings.TrimSpace(s) == "" {
+		return aliases, nil
+	}
+
+	for _, pair := range strings.Split(s, ",") {
+		alias, target, found := strings.Cut(strings.TrimSpace(pair), "=")
+		alias = strings.TrimSpace(alias)
+		if !found || alias == "" {
+			return nil, NewValidationError("eventTypeAliases", fmt.Sprintf("invalid pair %q, expected alias=type", pair))
+		}
+
+		eventType := EventType(strings.TrimSpace(target))
+		if !ValidEventTypes[eventType] {
+			return nil, NewValidationError("eventTypeAliases", fmt.Sprintf("unknown event type %q", eventType))
+		}
+		aliases[alias] = eventType
+	}
+
+	return aliases, nil
+}
+
+// ParseEventTypes parses a comma-separated list of event types, e.g. "goal,red_card".
+// An empty list returns nil. Returns a ValidationError for an unknown type.
+func ParseEventTypes(s string) (map[EventType]bool, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+
+	types := make(map[EventType]bool)
+	for _, name := range strings.Split(s, ",") {
+		eventType := EventType(strings.TrimSpace(name))
+		if eventType == "" {
+			continue
+		}
+		if !ValidEventTypes[eventType] {
+			return nil, NewValidationError("eventTypes", fmt.Sprintf("unknown event type %q", eventType))
+		}
+		types[eventType] = true
+	}
+	return types, nil
+}
+
+// NormalizeEventType returns the canonical event type for name, resolving aliases.
+// Names that are not aliases are returned unchanged.
+func NormalizeEventType(name string, aliases map[string]EventType) EventType {
+	if eventType, ok := aliases[name]; ok {
+		return eventType
+	}
+	return EventType(name)
+}
+
 // Event represents a validated match event in the domain layer.
 type Event struct {
 	EventID   uuid.UUID
@@ -49,6 +123,26 @@ type Event struct {
 	TeamID    int
 	PlayerID  string
 	Metadata  map[string]interface{}
+
+	// Op is how the event is stored. It travels in a Kafka message header
+	// rather than the payload; the zero value is a plain insert.
+	Op EventOp
+
+	// Confirm asks the consumer to publish the event's EventStatus once it is
+	// stored or given up on. Like Op it travels in a Kafka message header.
+	Confirm bool
+}
+
+// EventOp selects how the consumer stores an event.
+type EventOp string
+
+// EventOpUpsert replaces any stored event with the same EventID, so only the
+// latest version of the event is kept.
+const EventOpUpsert EventOp = "upsert"
+
+// IsUpsert reports whether the event replaces earlier versions of itself.
+func (e *Event) IsUpsert() bool {
+	return e.Op == EventOpUpsert
 }
 
 // EventRequest represents the incoming JSON request for an event.
@@ -62,9 +156,109 @@ type EventRequest struct {
 	Metadata  map[string]interface{} `json:"metadata,omitempty"`
 }
 
+// ValidationOptions toggles optional validation rules applied by ToEventWithOptions.
+type ValidationOptions struct {
+	// ValidateMinute checks metadata.minute, when present, is an integer in [0, MaxMinute].
+	ValidateMinute bool
+	MaxMinute      int
+
+	// MaxTeamID is the highest allowed teamId; valid IDs run from 1 to MaxTeamID.
+	// Zero means DefaultMaxTeamID.
+	MaxTeamID int
+
+	// EventTypeAliases maps legacy event type names to canonical types before
+	// validation. Nil means the default aliases; an empty map disables aliasing.
+	EventTypeAliases map[string]EventType
+
+	// RequiredMetadata enforces per-event-type required metadata keys.
+	// Nil enforces nothing.
+	RequiredMetadata *MetadataRegistry
+
+	// Mode overrides the metadata checks above and toggles unknown-field
+	// rejection and timestamp range checks together. The zero value applies
+	// the options as set.
+	Mode ValidationMode
+
+	// Clock is the time timestamps are checked against in strict mode. Nil
+	// means SystemClock.
+	Clock Clock
+
+	// AllowHistorical skips the strict timestamp range check, for bulk loads
+	// of past matches. The other strict checks still apply.
+	AllowHistorical bool
+}
+
+// ValidationMode selects how strictly incoming events are validated.
+type ValidationMode string
+
+// Validation modes. Strict rejects unknown fields, always checks
+// metadata.minute and required metadata, and rejects timestamps outside
+// [now-MaxTimestampAge, now+MaxTimestampSkew]. Lenient skips all of these.
+const (
+	ValidationModeDefault ValidationMode = ""
+	ValidationModeStrict  ValidationMode = "strict"
+	ValidationModeLenient ValidationMode = "lenient"
+)
+
+// Timestamp range enforced in strict mode.
+const (
+	MaxTimestampSkew = 5 * time.Minute
+	MaxTimestampAge  = 7 * 24 * time.Hour
+)
+
+// ParseValidationMode parses "strict" or "lenient". An empty string returns
+// ValidationModeDefault. Returns a ValidationError for any other value.
+func ParseValidationMode(s string) (ValidationMode, error) {
+	switch mode := ValidationMode(strings.ToLower(strings.TrimSpace(s))); mode {
+	case ValidationModeDefault, ValidationModeStrict, ValidationModeLenient:
+		return mode, nil
+	default:
+		return "", NewValidationError("validationMode", fmt.Sprintf("unknown mode %q, expected strict or lenient", s))
+	}
+}
+
+// RejectUnknownFields reports whether requests with fields not in
+// EventRequest should be rejected.
+func (o ValidationOptions) RejectUnknownFields() bool {
+	return o.Mode == ValidationModeStrict
+}
+
+// validateMinute reports whether metadata.minute is range checked.
+func (o ValidationOptions) validateMinute() bool {
+	switch o.Mode {
+	case ValidationModeStrict:
+		return true
+	case ValidationModeLenient:
+		return false
+	default:
+		return o.ValidateMinute
+	}
+}
+
+// DefaultMaxMinute covers regulation time, stoppage time and extra time.
+const DefaultMaxMinute = 130
+
+// DefaultMaxTeamID restricts teamId to 1 or 2 for two-team sports.
+const DefaultMaxTeamID = 2
+
+// DefaultValidationOptions returns the default options, with optional rules disabled.
+func DefaultValidationOptions() ValidationOptions {
+	return ValidationOptions{
+		ValidateMinute: false,
+		MaxMinute:      DefaultMaxMinute,
+		MaxTeamID:      DefaultMaxTeamID,
+	}
+}
+
 // ToEvent validates and converts an EventRequest to a domain Event.
 // Returns a ValidationError if any validation fails.
 func (r *EventRequest) ToEvent() (*Event, error) {
+	return r.ToEventWithOptions(DefaultValidationOptions())
+}
+
+// ToEventWithOptions validates and converts an EventRequest to a domain Event,
+// additionally applying the optional rules enabled in opts.
+func (r *EventRequest) ToEventWithOptions(opts ValidationOptions) (*Event, error) {
 	// Parse and validate UUID
 	eventUUID, err := uuid.Parse(r.EventID)
 	if err != nil {
@@ -76,9 +270,13 @@ func (r *EventRequest) ToEvent() (*Event, error) {
 		return nil, NewValidationError("matchId", "is required")
 	}
 
-	// Validate event type
-	eventType := EventType(r.EventType)
-	if !ValidEventTypes[eventType] {
+	// Validate event type, resolving legacy aliases first
+	aliases := opts.EventTypeAliases
+	if aliases == nil {
+		aliases = defaultEventTypeAliases
+	}
+	eventType := NormalizeEventType(r.EventType, aliases)
+	if !ValidEventTypes[eventType] && eventType != EventTypeCorrection {
 		return nil, NewValidationError("eventType", "must be a valid event type")
 	}
 
@@ -87,10 +285,37 @@ func (r *EventRequest) ToEvent() (*Event, error) {
 	if err != nil {
 		return nil, NewValidationError("timestamp", "must be a valid RFC3339 timestamp")
 	}
+	if opts.Mode == ValidationModeStrict && !opts.AllowHistorical {
+		if err := validateTimestampRange(timestamp, ClockOrSystem(opts.Clock).Now()); err != nil {
+			return nil, err
+		}
+	}
+
+	// Validate teamId is within the configured range
+	if err := ValidateTeamID(r.TeamID, opts.MaxTeamID); err != nil {
+		return nil, err
+	}
+
+	if eventType == EventTypeCorrection {
+		if _, err := parseCorrection(eventUUID, r.Metadata); err != nil {
+			return nil, err
+		}
+	}
 
-	// Validate teamId is 1 or 2
-	if r.TeamID != 1 && r.TeamID != 2 {
-		return nil, NewValidationError("teamId", "must be 1 or 2")
+	if opts.validateMinute() {
+		maxMinute := opts.MaxMinute
+		if maxMinute <= 0 {
+			maxMinute = DefaultMaxMinute
+		}
+		if err := ValidateMetadataMinute(r.Metadata, maxMinute); err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.Mode != ValidationModeLenient {
+		if err := opts.RequiredMetadata.Validate(eventType, r.Metadata); err != nil {
+			return nil, err
+		}
 	}
 
 	return &Event{
@@ -104,6 +329,74 @@ func (r *EventRequest) ToEvent() (*Event, error) {
 	}, nil
 }
 
+// ValidateTeamID checks that teamID is between 1 and maxTeamID, defaulting
+// maxTeamID to DefaultMaxTeamID when it is not positive.
+func ValidateTeamID(teamID, maxTeamID int) error {
+	if maxTeamID <= 0 {
+		maxTeamID = DefaultMaxTeamID
+	}
+	if teamID >= 1 && teamID <= maxTeamID {
+		return nil
+	}
+	if maxTeamID == 2 {
+		return NewValidationError("teamId", "must be 1 or 2")
+	}
+	return NewValidationError("teamId", fmt.Sprintf("must be between 1 and %d", maxTeamID))
+}
+
+// validateTimestampRange checks that ts is no older than MaxTimestampAge and
+// no further ahead than MaxTimestampSkew relative to now.
+func validateTimestampRange(ts, now time.Time) error {
+	if ts.After(now.Add(MaxTimestampSkew)) {
+		return NewValidationError("timestamp", fmt.Sprintf("must not be more than %s in the future", describeDuration(MaxTimestampSkew)))
+	}
+	if ts.Before(now.Add(-MaxTimestampAge)) {
+		return NewValidationError("timestamp", fmt.Sprintf("must not be more than %s in the past", describeDuration(MaxTimestampAge)))
+	}
+	return nil
+}
+
+// describeDuration spells d in its largest whole unit of days, hours or
+// minutes, e.g. "7 days", falling back to d.String().
+func describeDuration(d time.Duration) string {
+	units := []struct {
+		size time.Duration
+		name string
+	}{
+		{24 * time.Hour, "day"},
+		{time.Hour, "hour"},
+		{time.Minute, "minute"},
+	}
+	for _, unit := range units {
+		if d <= 0 || d%unit.size != 0 {
+			continue
+		}
+		n := int64(d / unit.size)
+		if n == 1 {
+			return "1 " + unit.name
+		}
+		return fmt.Sprintf("%d %ss", n, unit.name)
+	}
+	return d.String()
+}
+
+// ValidateMetadataMinute checks that metadata.minute, if present, is a whole
+// number between 0 and maxMinute. An absent minute is valid.
+func ValidateMetadataMinute(metadata map[string]interface{}, maxMinute int) error {
+	if _, ok := metadata["minute"]; !ok {
+		return nil
+	}
+
+	minute, ok := metadataFloat(metadata, "minute")
+	if !ok || minute != math.Trunc(minute) {
+		return NewValidationError("metadata.minute", "must be an integer")
+	}
+	if minute < 0 || minute > float64(maxMinute) {
+		return NewValidationError("metadata.minute", fmt.Sprintf("must be between 0 and %d", maxMinute))
+	}
+	return nil
+}
+
 // MetadataJSON serializes the event metadata to a JSON string.
 // Returns an empty JSON object "{}" if metadata is nil or serialization fails.
 func (e *Event) MetadataJSON() string {
@@ -117,6 +410,35 @@ func (e *Event) MetadataJSON() string {
 	return string(data)
 }
 
+// Range of the events table's DateTime64 timestamp column.
+var (
+	minStorableTimestamp = time.Date(1900, 1, 1, 0, 0, 0, 0, time.UTC)
+	maxStorableTimestamp = time.Date(2300, 1, 1, 0, 0, 0, 0, time.UTC)
+)
+
+// StorableMetadata checks that the event fits the events table and returns
+// its serialized metadata. Values that would fail or be silently altered on
+// insert are reported as an *UnstorableEventError naming the field: metadata
+// that cannot be serialized (e.g. NaN or channel values) and timestamps
+// outside the DateTime64 range.
+func (e *Event) StorableMetadata() (string, error) {
+	if e.Timestamp.Before(minStorableTimestamp) || !e.Timestamp.Before(maxStorableTimestamp) {
+		return "", &UnstorableEventError{
+			Event: e,
+			Field: "timestamp",
+			Err:   fmt.Errorf("%s is outside the storable range", e.Timestamp.Format(time.RFC3339)),
+		}
+	}
+	if e.Metadata == nil {
+		return "{}", nil
+	}
+	data, err := json.Marshal(e.Metadata)
+	if err != nil {
+		return "", &UnstorableEventError{Event: e, Field: "metadata", Err: err}
+	}
+	return string(data), nil
+}
+
 // KafkaMessage represents the serialized form of an Event for Kafka.
 type KafkaMessage struct {
 	EventID   string                 `json:"eventId"`
@@ -128,9 +450,29 @@ type KafkaMessage struct {
 	Metadata  map[string]interface{} `json:"metadata,omitempty"`
 }
 
-// ToKafkaMessage converts an Event to a JSON byte slice for Kafka.
-func (e *Event) ToKafkaMessage() ([]byte, error) {
-	msg := KafkaMessage{
+// InspectedMessage is a raw Kafka message read for debugging, with its payload
+// decoded when possible. Error explains why a payload could not be decoded.
+type InspectedMessage struct {
+	Partition int               `json:"partition"`
+	Offset    int64             `json:"offset"`
+	Key       string            `json:"key"`
+	Time      time.Time         `json:"time"`
+	Headers   map[string]string `json:"headers,omitempty"`
+	Event     *KafkaMessage     `json:"event,omitempty"`
+	Raw       string            `json:"raw,omitempty"`
+	Error     string            `json:"error,omitempty"`
+}
+
+// PartitionOffset is a consumer group offset for one partition.
+type PartitionOffset struct {
+	Partition int   `json:"partition"`
+	Offset    int64 `json:"offset"`
+}
+
+// AsKafkaMessage returns the serializable form of an Event, also used to
+// return stored events from the API.
+func (e *Event) AsKafkaMessage() KafkaMessage {
+	return KafkaMessage{
 		EventID:   e.EventID.String(),
 		MatchID:   e.MatchID,
 		EventType: string(e.EventType),
@@ -139,13 +481,23 @@ func (e *Event) ToKafkaMessage() ([]byte, error) {
 		PlayerID:  e.PlayerID,
 		Metadata:  e.Metadata,
 	}
-	return json.Marshal(msg)
+}
+
+// ToKafkaMessage converts an Event to a JSON byte slice for Kafka.
+func (e *Event) ToKafkaMessage() ([]byte, error) {
+	return json.Marshal(e.AsKafkaMessage())
 }
 
 // EventFromKafkaMessage deserializes a Kafka message into an Event.
 func EventFromKafkaMessage(data []byte) (*Event, error) {
+	return DecodeKafkaMessage(data, false)
+}
+
+// DecodeKafkaMessage deserializes a Kafka message into an Event. With
+// useNumber, metadata numbers decode as json.Number; see DecodeJSON.
+func DecodeKafkaMessage(data []byte, useNumber bool) (*Event, error) {
 	var msg KafkaMessage
-	if err := json.Unmarshal(data, &msg); err != nil {
+	if err := DecodeJSON(data, &msg, useNumber); err != nil {
 		return nil, err
 	}
 
@@ -0,0 +1,30 @@
+package domain
+
+import "github.com/google/uuid"
+
+// EventStatus is how far an event sent with X-Confirm has got through the
+// pipeline.
+type EventStatus string
+
+// Event statuses reported by GET /api/events/{eventId}/status.
+const (
+	// EventStatusAccepted is an event produced to Kafka but not yet stored.
+	EventStatusAccepted EventStatus = "accepted"
+	// EventStatusPersisted is an event inserted into ClickHouse.
+	EventStatusPersisted EventStatus = "persisted"
+	// EventStatusFailed is an event the consumer gave up on and sent to the
+	// dead letter topic.
+	EventStatusFailed EventStatus = "failed"
+)
+
+// IsFinal reports whether the status can no longer change.
+func (s EventStatus) IsFinal() bool {
+	return s == EventStatusPersisted || s == EventStatusFailed
+}
+
+// EventStatusUpdate is a status the consumer publishes for a confirmed event.
+type EventStatusUpdate struct {
+	EventID uuid.UUID
+	MatchID string
+	Status  EventStatus
+}
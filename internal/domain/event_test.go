@@ -3,6 +3,7 @@ package domain_test
 import (
 	"encoding/json"
 	"errors"
+	"math"
 	"testing"
 	"time"
 
@@ -254,6 +255,198 @@ func TestEventRequest_ToEvent_InvalidEventType(t *testing.T) {
 	}
 }
 
+// TestEventRequest_ToEvent_EventTypeAliases tests that legacy names map to canonical types.
+func TestEventRequest_ToEvent_EventTypeAliases(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected domain.EventType
+	}{
+		{"freekick", domain.EventTypeFreeKick},
+		{"penalty_kick", domain.EventTypeFreeKick},
+		{"free_kick", domain.EventTypeFreeKick},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.input, func(t *testing.T) {
+			req := &domain.EventRequest{
+				EventID:   uuid.New().String(),
+				MatchID:   "match-123",
+				EventType: tc.input,
+				Timestamp: time.Now().UTC().Format(time.RFC3339),
+				TeamID:    1,
+			}
+
+			event, err := req.ToEvent()
+			if err != nil {
+				t.Fatalf("expected no error for alias '%s', got: %v", tc.input, err)
+			}
+			if event.EventType != tc.expected {
+				t.Errorf("expected EventType '%s', got '%s'", tc.expected, event.EventType)
+			}
+		})
+	}
+}
+
+// TestEventRequest_ToEventWithOptions_EventTypeAliases tests configured and disabled aliases.
+func TestEventRequest_ToEventWithOptions_EventTypeAliases(t *testing.T) {
+	newReq := func(eventType string) *domain.EventRequest {
+		return &domain.EventRequest{
+			EventID:   uuid.New().String(),
+			MatchID:   "match-123",
+			EventType: eventType,
+			Timestamp: time.Now().UTC().Format(time.RFC3339),
+			TeamID:    1,
+		}
+	}
+
+	aliases, err := domain.ParseEventTypeAliases("fk=free_kick, card=yellow_card")
+	if err != nil {
+		t.Fatalf("expected no error parsing aliases, got: %v", err)
+	}
+	opts := domain.ValidationOptions{EventTypeAliases: aliases}
+
+	event, err := newReq("card").ToEventWithOptions(opts)
+	if err != nil {
+		t.Fatalf("expected configured alias to be accepted, got: %v", err)
+	}
+	if event.EventType != domain.EventTypeYellowCard {
+		t.Errorf("expected EventType 'yellow_card', got '%s'", event.EventType)
+	}
+
+	// Parsed aliases keep the built-in ones
+	if _, err := newReq("freekick").ToEventWithOptions(opts); err != nil {
+		t.Errorf("expected built-in alias to be accepted, got: %v", err)
+	}
+
+	// Unknown types still fail
+	if _, err := newReq("penalty").ToEventWithOptions(opts); err == nil {
+		t.Error("expected error for unknown event type, got nil")
+	}
+
+	// An empty map disables aliasing
+	disabled := domain.ValidationOptions{EventTypeAliases: map[string]domain.EventType{}}
+	_, err = newReq("freekick").ToEventWithOptions(disabled)
+	if ve := domain.AsValidationError(err); ve == nil || ve.Field != "eventType" {
+		t.Errorf("expected eventType ValidationError with aliases disabled, got: %v", err)
+	}
+}
+
+// TestParseEventTypeAliases_Invalid tests that malformed pairs and unknown targets are rejected.
+func TestParseEventTypeAliases_Invalid(t *testing.T) {
+	for _, input := range []string{"fk", "=free_kick", "fk=penalty"} {
+		t.Run(input, func(t *testing.T) {
+			if _, err := domain.ParseEventTypeAliases(input); !domain.IsValidationError(err) {
+				t.Errorf("expected ValidationError for %q, got: %v", input, err)
+			}
+		})
+	}
+}
+
+func TestParseEventTypes(t *testing.T) {
+	types, err := domain.ParseEventTypes(" goal, red_card,,")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if len(types) != 2 || !types[domain.EventTypeGoal] || !types[domain.EventTypeRedCard] {
+		t.Errorf("expected goal and red_card, got %v", types)
+	}
+
+	if types, err := domain.ParseEventTypes(""); err != nil || types != nil {
+		t.Errorf("expected nil for an empty list, got %v, %v", types, err)
+	}
+
+	if _, err := domain.ParseEventTypes("goal,penalty"); !domain.IsValidationError(err) {
+		t.Errorf("expected ValidationError for an unknown type, got: %v", err)
+	}
+}
+
+// TestEventRequest_ToEventWithOptions_RequiredMetadata tests that a loaded policy
+// enforces required keys per event type.
+func TestEventRequest_ToEventWithOptions_RequiredMetadata(t *testing.T) {
+	policy, err := domain.ParseMetadataPolicy([]byte(`{"goal": ["scorer", "minute"], "substitution": ["playerOut"]}`))
+	if err != nil {
+		t.Fatalf("expected no error parsing policy, got: %v", err)
+	}
+	opts := domain.ValidationOptions{RequiredMetadata: domain.NewMetadataRegistry(policy)}
+
+	testCases := []struct {
+		name      string
+		eventType string
+		metadata  map[string]interface{}
+		wantField string
+	}{
+		{"all keys present", "goal", map[string]interface{}{"scorer": "p9", "minute": 12}, ""},
+		{"missing key", "goal", map[string]interface{}{"scorer": "p9"}, "metadata.minute"},
+		{"no metadata", "substitution", nil, "metadata.playerOut"},
+		{"type without requirements", "pass", nil, ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := &domain.EventRequest{
+				EventID:   uuid.New().String(),
+				MatchID:   "match-123",
+				EventType: tc.eventType,
+				Timestamp: time.Now().UTC().Format(time.RFC3339),
+				TeamID:    1,
+				Metadata:  tc.metadata,
+			}
+
+			_, err := req.ToEventWithOptions(opts)
+			if tc.wantField == "" {
+				if err != nil {
+					t.Errorf("expected no error, got: %v", err)
+				}
+				return
+			}
+			ve := domain.AsValidationError(err)
+			if ve == nil {
+				t.Fatalf("expected ValidationError, got: %v", err)
+			}
+			if ve.Field != tc.wantField {
+				t.Errorf("expected field '%s', got '%s'", tc.wantField, ve.Field)
+			}
+		})
+	}
+}
+
+// TestEventRequest_ToEventWithOptions_EmptyMetadataPolicy tests that an empty policy enforces nothing.
+func TestEventRequest_ToEventWithOptions_EmptyMetadataPolicy(t *testing.T) {
+	policy, err := domain.ParseMetadataPolicy(nil)
+	if err != nil {
+		t.Fatalf("expected no error parsing empty policy, got: %v", err)
+	}
+
+	for _, opts := range []domain.ValidationOptions{
+		{RequiredMetadata: domain.NewMetadataRegistry(policy)},
+		{},
+	} {
+		for eventType := range domain.ValidEventTypes {
+			req := &domain.EventRequest{
+				EventID:   uuid.New().String(),
+				MatchID:   "match-123",
+				EventType: string(eventType),
+				Timestamp: time.Now().UTC().Format(time.RFC3339),
+				TeamID:    1,
+			}
+			if _, err := req.ToEventWithOptions(opts); err != nil {
+				t.Errorf("expected no error for %s with an empty policy, got: %v", eventType, err)
+			}
+		}
+	}
+}
+
+// TestParseMetadataPolicy_Invalid tests that malformed policies are rejected.
+func TestParseMetadataPolicy_Invalid(t *testing.T) {
+	for _, input := range []string{`not json`, `{"penalty": ["taker"]}`, `{"goal": [""]}`} {
+		t.Run(input, func(t *testing.T) {
+			if _, err := domain.ParseMetadataPolicy([]byte(input)); !domain.IsValidationError(err) {
+				t.Errorf("expected ValidationError for %s, got: %v", input, err)
+			}
+		})
+	}
+}
+
 // TestEventRequest_ToEvent_EmptyMatchID tests that empty matchId is rejected.
 func TestEventRequest_ToEvent_EmptyMatchID(t *testing.T) {
 	req := &domain.EventRequest{
@@ -353,6 +546,329 @@ func TestEventRequest_ToEvent_ValidTeamIDs(t *testing.T) {
 	}
 }
 
+// TestEventRequest_ToEventWithOptions_MaxTeamID tests the configurable teamId range.
+func TestEventRequest_ToEventWithOptions_MaxTeamID(t *testing.T) {
+	testCases := []struct {
+		name        string
+		maxTeamID   int
+		teamID      int
+		wantMessage string
+	}{
+		{"default allows two", 0, 2, ""},
+		{"default rejects three", 0, 3, "must be 1 or 2"},
+		{"four teams allows four", 4, 4, ""},
+		{"four teams allows three", 4, 3, ""},
+		{"four teams rejects five", 4, 5, "must be between 1 and 4"},
+		{"four teams rejects zero", 4, 0, "must be between 1 and 4"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := domain.DefaultValidationOptions()
+			opts.MaxTeamID = tc.maxTeamID
+
+			req := &domain.EventRequest{
+				EventID:   uuid.New().String(),
+				MatchID:   "match-123",
+				EventType: "goal",
+				Timestamp: time.Now().UTC().Format(time.RFC3339),
+				TeamID:    tc.teamID,
+			}
+
+			_, err := req.ToEventWithOptions(opts)
+			if tc.wantMessage == "" {
+				if err != nil {
+					t.Fatalf("expected no error, got: %v", err)
+				}
+				return
+			}
+
+			ve := domain.AsValidationError(err)
+			if ve == nil {
+				t.Fatalf("expected ValidationError, got: %v", err)
+			}
+			if ve.Field != "teamId" || ve.Message != tc.wantMessage {
+				t.Errorf("expected teamId error %q, got %s: %q", tc.wantMessage, ve.Field, ve.Message)
+			}
+		})
+	}
+}
+
+// TestEventRequest_ToEventWithOptions_MetadataMinute tests the optional metadata.minute range check.
+func TestEventRequest_ToEventWithOptions_MetadataMinute(t *testing.T) {
+	testCases := []struct {
+		name     string
+		metadata map[string]interface{}
+		wantErr  bool
+	}{
+		{"absent", nil, false},
+		{"absent with other metadata", map[string]interface{}{"scorer": "Player"}, false},
+		{"valid minute", map[string]interface{}{"minute": float64(45)}, false},
+		{"kickoff", map[string]interface{}{"minute": float64(0)}, false},
+		{"at max", map[string]interface{}{"minute": float64(130)}, false},
+		{"negative minute", map[string]interface{}{"minute": float64(-1)}, true},
+		{"out of range minute", map[string]interface{}{"minute": float64(131)}, true},
+		{"fractional minute", map[string]interface{}{"minute": 45.5}, true},
+		{"string minute", map[string]interface{}{"minute": "45"}, true},
+	}
+
+	opts := domain.DefaultValidationOptions()
+	opts.ValidateMinute = true
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := &domain.EventRequest{
+				EventID:   uuid.New().String(),
+				MatchID:   "match-123",
+				EventType: "goal",
+				Timestamp: time.Now().UTC().Format(time.RFC3339),
+				TeamID:    1,
+				Metadata:  tc.metadata,
+			}
+
+			_, err := req.ToEventWithOptions(opts)
+			if !tc.wantErr {
+				if err != nil {
+					t.Fatalf("expected no error, got: %v", err)
+				}
+				return
+			}
+
+			ve := domain.AsValidationError(err)
+			if ve == nil {
+				t.Fatalf("expected ValidationError, got: %v", err)
+			}
+			if ve.Field != "metadata.minute" {
+				t.Errorf("expected field 'metadata.minute', got '%s'", ve.Field)
+			}
+		})
+	}
+}
+
+// TestEventRequest_ToEvent_MinuteNotValidatedByDefault tests that the minute check is opt-in.
+func TestEventRequest_ToEvent_MinuteNotValidatedByDefault(t *testing.T) {
+	req := &domain.EventRequest{
+		EventID:   uuid.New().String(),
+		MatchID:   "match-123",
+		EventType: "goal",
+		Timestamp: time.Now().UTC().Format(time.RFC3339),
+		TeamID:    1,
+		Metadata:  map[string]interface{}{"minute": float64(-5)},
+	}
+
+	if _, err := req.ToEvent(); err != nil {
+		t.Fatalf("expected no error with default options, got: %v", err)
+	}
+}
+
+// TestEventRequest_ToEventWithOptions_ValidationMode tests that the same
+// payload passes or fails depending on the validation mode.
+func TestEventRequest_ToEventWithOptions_ValidationMode(t *testing.T) {
+	policy, err := domain.ParseMetadataPolicy([]byte(`{"goal": ["scorer"]}`))
+	if err != nil {
+		t.Fatalf("expected no error parsing policy, got: %v", err)
+	}
+
+	testCases := []struct {
+		name        string
+		timestamp   time.Time
+		metadata    map[string]interface{}
+		wantStrict  string
+		wantLenient string
+	}{
+		{"valid", time.Now(), map[string]interface{}{"scorer": "p9", "minute": float64(45)}, "", ""},
+		{"out of range minute", time.Now(), map[string]interface{}{"scorer": "p9", "minute": float64(200)}, "metadata.minute", ""},
+		{"missing required key", time.Now(), nil, "metadata.scorer", ""},
+		{"too far in the future", time.Now().Add(time.Hour), map[string]interface{}{"scorer": "p9"}, "timestamp", ""},
+		{"too old", time.Now().Add(-30 * 24 * time.Hour), map[string]interface{}{"scorer": "p9"}, "timestamp", ""},
+	}
+
+	for _, tc := range testCases {
+		for mode, wantField := range map[domain.ValidationMode]string{
+			domain.ValidationModeStrict:  tc.wantStrict,
+			domain.ValidationModeLenient: tc.wantLenient,
+		} {
+			t.Run(tc.name+"/"+string(mode), func(t *testing.T) {
+				req := &domain.EventRequest{
+					EventID:   uuid.New().String(),
+					MatchID:   "match-123",
+					EventType: "goal",
+					Timestamp: tc.timestamp.UTC().Format(time.RFC3339),
+					TeamID:    1,
+					Metadata:  tc.metadata,
+				}
+				opts := domain.ValidationOptions{
+					RequiredMetadata: domain.NewMetadataRegistry(policy),
+					Mode:             mode,
+				}
+
+				_, err := req.ToEventWithOptions(opts)
+				if wantField == "" {
+					if err != nil {
+						t.Fatalf("expected no error, got: %v", err)
+					}
+					return
+				}
+				ve := domain.AsValidationError(err)
+				if ve == nil {
+					t.Fatalf("expected ValidationError, got: %v", err)
+				}
+				if ve.Field != wantField {
+					t.Errorf("expected field '%s', got '%s'", wantField, ve.Field)
+				}
+			})
+		}
+	}
+}
+
+// TestParseValidationMode tests parsing of the VALIDATION_MODE values.
+func TestParseValidationMode(t *testing.T) {
+	for input, want := range map[string]domain.ValidationMode{
+		"":         domain.ValidationModeDefault,
+		"strict":   domain.ValidationModeStrict,
+		" Lenient": domain.ValidationModeLenient,
+	} {
+		got, err := domain.ParseValidationMode(input)
+		if err != nil {
+			t.Fatalf("expected no error for %q, got: %v", input, err)
+		}
+		if got != want {
+			t.Errorf("ParseValidationMode(%q) = %q, want %q", input, got, want)
+		}
+	}
+
+	if _, err := domain.ParseValidationMode("paranoid"); err == nil {
+		t.Error("expected error for unknown mode")
+	}
+}
+
+// TestEventRequest_ToEvent_Correction tests validation of correction event metadata.
+func TestEventRequest_ToEvent_Correction(t *testing.T) {
+	ownID := uuid.New()
+	target := uuid.New().String()
+
+	testCases := []struct {
+		name      string
+		metadata  map[string]interface{}
+		wantField string
+	}{
+		{"delete", map[string]interface{}{"correctsEventId": target, "action": "delete"}, ""},
+		{"amend", map[string]interface{}{"correctsEventId": target, "action": "amend"}, ""},
+		{"missing target", map[string]interface{}{"action": "delete"}, "metadata.correctsEventId"},
+		{"invalid target", map[string]interface{}{"correctsEventId": "nope", "action": "delete"}, "metadata.correctsEventId"},
+		{"self reference", map[string]interface{}{"correctsEventId": ownID.String(), "action": "delete"}, "metadata.correctsEventId"},
+		{"unknown action", map[string]interface{}{"correctsEventId": target, "action": "undo"}, "metadata.action"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := &domain.EventRequest{
+				EventID:   ownID.String(),
+				MatchID:   "match-123",
+				EventType: "correction",
+				Timestamp: time.Now().UTC().Format(time.RFC3339),
+				TeamID:    1,
+				Metadata:  tc.metadata,
+			}
+
+			event, err := req.ToEvent()
+			if tc.wantField == "" {
+				if err != nil {
+					t.Fatalf("expected no error, got: %v", err)
+				}
+				correction, err := event.Correction()
+				if err != nil {
+					t.Fatalf("expected parsed correction, got: %v", err)
+				}
+				if !event.IsCorrection() || correction.CorrectsEventID.String() != target {
+					t.Errorf("unexpected correction %+v", correction)
+				}
+				return
+			}
+
+			ve := domain.AsValidationError(err)
+			if ve == nil {
+				t.Fatalf("expected ValidationError, got: %v", err)
+			}
+			if ve.Field != tc.wantField {
+				t.Errorf("expected field '%s', got '%s'", tc.wantField, ve.Field)
+			}
+		})
+	}
+}
+
+// TestEvent_StorableMetadata tests that values the events table cannot hold
+// are reported with the offending field.
+func TestEvent_StorableMetadata(t *testing.T) {
+	tests := []struct {
+		name      string
+		metadata  map[string]interface{}
+		timestamp time.Time
+		wantJSON  string
+		wantField string
+	}{
+		{
+			name:      "serializable metadata",
+			metadata:  map[string]interface{}{"minute": 45},
+			timestamp: time.Now().UTC(),
+			wantJSON:  `{"minute":45}`,
+		},
+		{
+			name:      "nil metadata",
+			timestamp: time.Now().UTC(),
+			wantJSON:  "{}",
+		},
+		{
+			name:      "NaN metadata",
+			metadata:  map[string]interface{}{"xg": math.NaN()},
+			timestamp: time.Now().UTC(),
+			wantField: "metadata",
+		},
+		{
+			name:      "channel metadata",
+			metadata:  map[string]interface{}{"feed": make(chan int)},
+			timestamp: time.Now().UTC(),
+			wantField: "metadata",
+		},
+		{
+			name:      "zero timestamp",
+			wantField: "timestamp",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event := &domain.Event{
+				EventID:   uuid.New(),
+				MatchID:   "match-123",
+				EventType: domain.EventTypeShot,
+				Timestamp: tt.timestamp,
+				TeamID:    1,
+				Metadata:  tt.metadata,
+			}
+
+			got, err := event.StorableMetadata()
+			if tt.wantField == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if got != tt.wantJSON {
+					t.Errorf("expected %s, got %s", tt.wantJSON, got)
+				}
+				return
+			}
+
+			var ue *domain.UnstorableEventError
+			if !errors.As(err, &ue) {
+				t.Fatalf("expected an UnstorableEventError, got %v", err)
+			}
+			if ue.Field != tt.wantField || ue.Event != event {
+				t.Errorf("expected field %q for the event, got %q", tt.wantField, ue.Field)
+			}
+		})
+	}
+}
+
 // TestEvent_MetadataJSON_Empty tests that nil metadata returns "{}".
 func TestEvent_MetadataJSON_Empty(t *testing.T) {
 	event := &domain.Event{
@@ -727,3 +1243,87 @@ func BenchmarkEvent_MetadataJSON(b *testing.B) {
 		_ = event.MetadataJSON()
 	}
 }
+
+// TestEventRequest_ToEventWithOptions_TimestampRangeClock tests that strict
+// timestamp bounds are measured from the configured clock.
+// TestEventRequest_ToEventWithOptions_AllowHistorical tests that
+// AllowHistorical lifts only the strict timestamp range.
+func TestEventRequest_ToEventWithOptions_AllowHistorical(t *testing.T) {
+	now := time.Date(2024, 1, 15, 15, 0, 0, 0, time.UTC)
+	req := &domain.EventRequest{
+		EventID:   uuid.New().String(),
+		MatchID:   "match-123",
+		EventType: "pass",
+		Timestamp: now.AddDate(-1, 0, 0).Format(time.RFC3339),
+		TeamID:    1,
+	}
+	opts := domain.ValidationOptions{Mode: domain.ValidationModeStrict, Clock: domain.NewFixedClock(now), AllowHistorical: true}
+	if _, err := req.ToEventWithOptions(opts); err != nil {
+		t.Fatalf("expected a year-old event to be allowed, got: %v", err)
+	}
+
+	req.TeamID = 3
+	if _, err := req.ToEventWithOptions(opts); err == nil {
+		t.Error("expected other strict checks to still apply")
+	}
+}
+
+func TestEventRequest_ToEventWithOptions_TimestampRangeClock(t *testing.T) {
+	now := time.Date(2024, 1, 15, 15, 0, 0, 0, time.UTC)
+	clock := domain.NewFixedClock(now)
+
+	testCases := []struct {
+		name        string
+		timestamp   time.Time
+		wantErr     bool
+		wantMessage string
+	}{
+		{"at now", now, false, ""},
+		{"at the future limit", now.Add(domain.MaxTimestampSkew), false, ""},
+		{"past the future limit", now.Add(domain.MaxTimestampSkew + time.Second), true, "must not be more than 5 minutes in the future"},
+		{"at the age limit", now.Add(-domain.MaxTimestampAge), false, ""},
+		{"past the age limit", now.Add(-domain.MaxTimestampAge - time.Second), true, "must not be more than 7 days in the past"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := &domain.EventRequest{
+				EventID:   uuid.New().String(),
+				MatchID:   "match-123",
+				EventType: "pass",
+				Timestamp: tc.timestamp.Format(time.RFC3339),
+				TeamID:    1,
+			}
+			_, err := req.ToEventWithOptions(domain.ValidationOptions{
+				Mode:  domain.ValidationModeStrict,
+				Clock: clock,
+			})
+			if tc.wantErr {
+				ve := domain.AsValidationError(err)
+				if ve == nil || ve.Field != "timestamp" {
+					t.Fatalf("expected timestamp ValidationError, got: %v", err)
+				}
+				if ve.Message != tc.wantMessage {
+					t.Errorf("expected message %q, got %q", tc.wantMessage, ve.Message)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("expected no error, got: %v", err)
+			}
+		})
+	}
+
+	// Moving the clock moves the window
+	clock.Advance(domain.MaxTimestampAge + time.Hour)
+	req := &domain.EventRequest{
+		EventID:   uuid.New().String(),
+		MatchID:   "match-123",
+		EventType: "pass",
+		Timestamp: now.Format(time.RFC3339),
+		TeamID:    1,
+	}
+	if _, err := req.ToEventWithOptions(domain.ValidationOptions{Mode: domain.ValidationModeStrict, Clock: clock}); err == nil {
+		t.Error("expected the timestamp to be too old once the clock has advanced")
+	}
+}
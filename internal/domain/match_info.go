@@ -0,0 +1,62 @@
+package domain
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MaxMatchInfoNameLength bounds competition and team names.
+const MaxMatchInfoNameLength = 128
+
+// MatchInfo holds human-readable details registered for a match.
+// TeamNames is keyed by the numeric teamId used in events.
+type MatchInfo struct {
+	MatchID     string         `json:"matchId"`
+	Competition string         `json:"competition,omitempty"`
+	TeamNames   map[int]string `json:"teamNames,omitempty"`
+}
+
+// MatchInfoRequest is the payload for registering match details.
+type MatchInfoRequest struct {
+	Competition string         `json:"competition"`
+	TeamNames   map[int]string `json:"teamNames"`
+}
+
+// ToMatchInfo validates the request and converts it to a MatchInfo for matchID.
+// Team IDs must be between 1 and maxTeamID; names are trimmed and must not be empty.
+func (r *MatchInfoRequest) ToMatchInfo(matchID string, maxTeamID int) (*MatchInfo, error) {
+	if matchID == "" {
+		return nil, NewValidationError("matchId", "is required")
+	}
+
+	info := &MatchInfo{
+		MatchID:     matchID,
+		Competition: strings.TrimSpace(r.Competition),
+	}
+	if len(info.Competition) > MaxMatchInfoNameLength {
+		return nil, NewValidationError("competition", fmt.Sprintf("must be at most %d characters", MaxMatchInfoNameLength))
+	}
+
+	if len(r.TeamNames) > 0 {
+		info.TeamNames = make(map[int]string, len(r.TeamNames))
+	}
+	for teamID, name := range r.TeamNames {
+		if err := ValidateTeamID(teamID, maxTeamID); err != nil {
+			return nil, NewValidationError("teamNames", fmt.Sprintf("key %d: %s", teamID, AsValidationError(err).Message))
+		}
+		name = strings.TrimSpace(name)
+		if name == "" {
+			return nil, NewValidationError("teamNames", fmt.Sprintf("name for team %d is required", teamID))
+		}
+		if len(name) > MaxMatchInfoNameLength {
+			return nil, NewValidationError("teamNames", fmt.Sprintf("name for team %d must be at most %d characters", teamID, MaxMatchInfoNameLength))
+		}
+		info.TeamNames[teamID] = name
+	}
+
+	if info.Competition == "" && len(info.TeamNames) == 0 {
+		return nil, NewValidationError("competition", "competition or teamNames is required")
+	}
+
+	return info, nil
+}
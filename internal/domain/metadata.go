@@ -0,0 +1,110 @@
+package domain
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// DecodeJSON unmarshals data into v. With useNumber, numbers held in
+// interface{} values, such as event metadata, decode as json.Number instead of
+// float64, so integers keep their exact value and serialize back unchanged.
+func DecodeJSON(data []byte, v any, useNumber bool) error {
+	if !useNumber {
+		return json.Unmarshal(data, v)
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+	// Match json.Unmarshal, which rejects data after the first value
+	if _, err := dec.Token(); !errors.Is(err, io.EOF) {
+		return fmt.Errorf("invalid character after top-level value at offset %d", dec.InputOffset())
+	}
+	return nil
+}
+
+// MetadataString returns metadata[key] if it is a string.
+func (e *Event) MetadataString(key string) (string, bool) {
+	return metadataString(e.Metadata, key)
+}
+
+// MetadataBool returns metadata[key] if it is a boolean.
+func (e *Event) MetadataBool(key string) (bool, bool) {
+	return metadataBool(e.Metadata, key)
+}
+
+// MetadataFloat returns metadata[key] if it is a number. Decoded JSON holds
+// numbers as float64, or json.Number with UseNumber; Go integer types set in
+// code are accepted too.
+func (e *Event) MetadataFloat(key string) (float64, bool) {
+	return metadataFloat(e.Metadata, key)
+}
+
+// MetadataInt returns metadata[key] if it is a whole number that fits in an
+// int, so a decoded 45 (float64 45.0 or json.Number "45") reads as 45 while
+// 45.5 does not match.
+func (e *Event) MetadataInt(key string) (int, bool) {
+	return metadataInt(e.Metadata, key)
+}
+
+// metadataString returns metadata[key] if it is a string.
+func metadataString(metadata map[string]interface{}, key string) (string, bool) {
+	v, ok := metadata[key].(string)
+	return v, ok
+}
+
+// metadataBool returns metadata[key] if it is a boolean.
+func metadataBool(metadata map[string]interface{}, key string) (bool, bool) {
+	v, ok := metadata[key].(bool)
+	return v, ok
+}
+
+// metadataFloat returns metadata[key] as a float64 if it is a number.
+func metadataFloat(metadata map[string]interface{}, key string) (float64, bool) {
+	switch v := metadata[key].(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	default:
+		return 0, false
+	}
+}
+
+// metadataInt returns metadata[key] as an int if it is a whole number in range.
+func metadataInt(metadata map[string]interface{}, key string) (int, bool) {
+	switch v := metadata[key].(type) {
+	case int:
+		return v, true
+	case int64:
+		if v < math.MinInt || v > math.MaxInt {
+			return 0, false
+		}
+		return int(v), true
+	case json.Number:
+		// Integers beyond float64 precision stay exact
+		if n, err := v.Int64(); err == nil && n >= math.MinInt && n <= math.MaxInt {
+			return int(n), true
+		}
+	}
+
+	f, ok := metadataFloat(metadata, key)
+	if !ok || f != math.Trunc(f) || f < math.MinInt || f >= math.MaxInt {
+		return 0, false
+	}
+	return int(f), true
+}
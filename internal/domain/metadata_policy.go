@@ -0,0 +1,101 @@
+package domain
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync/atomic"
+)
+
+// MetadataPolicy maps event types to the metadata keys every event of that type
+// must carry. Event types without an entry have no required keys.
+type MetadataPolicy map[EventType][]string
+
+// ParseMetadataPolicy parses a JSON object mapping event types to required keys,
+// e.g. {"goal": ["scorer", "minute"]}. Empty input yields an empty policy.
+// Returns a ValidationError for unknown event types or empty keys.
+func ParseMetadataPolicy(data []byte) (MetadataPolicy, error) {
+	policy := MetadataPolicy{}
+	if len(data) == 0 {
+		return policy, nil
+	}
+
+	var raw map[string][]string
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, NewValidationError("requiredMetadata", fmt.Sprintf("invalid policy JSON: %v", err))
+	}
+
+	for name, keys := range raw {
+		eventType := EventType(name)
+		if !ValidEventTypes[eventType] {
+			return nil, NewValidationError("requiredMetadata", fmt.Sprintf("unknown event type %q", name))
+		}
+		for _, key := range keys {
+			if key == "" {
+				return nil, NewValidationError("requiredMetadata", fmt.Sprintf("empty key for event type %q", name))
+			}
+		}
+		if len(keys) > 0 {
+			policy[eventType] = keys
+		}
+	}
+
+	return policy, nil
+}
+
+// Validate checks that metadata carries every key required for eventType.
+// The first missing key, in sorted order, is reported as metadata.<key>.
+func (p MetadataPolicy) Validate(eventType EventType, metadata map[string]interface{}) error {
+	keys := p[eventType]
+	if len(keys) == 0 {
+		return nil
+	}
+
+	var missing []string
+	for _, key := range keys {
+		if _, ok := metadata[key]; !ok {
+			missing = append(missing, key)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	sort.Strings(missing)
+	return NewValidationError("metadata."+missing[0], fmt.Sprintf("is required for %s events", eventType))
+}
+
+// MetadataRegistry holds the active MetadataPolicy. The policy can be replaced
+// at runtime, e.g. after an operator edits the policy file, without restarting.
+// A nil registry enforces nothing.
+type MetadataRegistry struct {
+	policy atomic.Pointer[MetadataPolicy]
+}
+
+// NewMetadataRegistry creates a registry enforcing policy.
+func NewMetadataRegistry(policy MetadataPolicy) *MetadataRegistry {
+	r := &MetadataRegistry{}
+	r.Set(policy)
+	return r
+}
+
+// Policy returns the active policy.
+func (r *MetadataRegistry) Policy() MetadataPolicy {
+	if r == nil {
+		return nil
+	}
+	if p := r.policy.Load(); p != nil {
+		return *p
+	}
+	return nil
+}
+
+// Set replaces the active policy.
+func (r *MetadataRegistry) Set(policy MetadataPolicy) {
+	r.policy.Store(&policy)
+}
+
+// Validate checks metadata against the active policy.
+func (r *MetadataRegistry) Validate(eventType EventType, metadata map[string]interface{}) error {
+	return r.Policy().Validate(eventType, metadata)
+}
@@ -0,0 +1,179 @@
+package domain_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"fanfinity/internal/domain"
+)
+
+// decodeMetadata decodes a JSON object the way incoming event requests are.
+func decodeMetadata(t *testing.T, raw string) map[string]interface{} {
+	t.Helper()
+	var metadata map[string]interface{}
+	if err := json.Unmarshal([]byte(raw), &metadata); err != nil {
+		t.Fatalf("failed to decode metadata: %v", err)
+	}
+	return metadata
+}
+
+// TestEvent_MetadataInt tests integer access across the numeric types metadata
+// can hold.
+func TestEvent_MetadataInt(t *testing.T) {
+	event := &domain.Event{Metadata: decodeMetadata(t, `{"minute": 45, "xg": 0.35, "position": "penalty"}`)}
+	event.Metadata["count"] = 3
+	event.Metadata["number"] = json.Number("90")
+
+	tests := []struct {
+		key    string
+		want   int
+		wantOK bool
+	}{
+		{key: "minute", want: 45, wantOK: true},
+		{key: "count", want: 3, wantOK: true},
+		{key: "number", want: 90, wantOK: true},
+		{key: "xg", wantOK: false},
+		{key: "position", wantOK: false},
+		{key: "missing", wantOK: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			got, ok := event.MetadataInt(tt.key)
+			if ok != tt.wantOK || got != tt.want {
+				t.Errorf("MetadataInt(%q) = %d, %v; want %d, %v", tt.key, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+// TestEvent_MetadataFloat tests float access from decoded and Go numbers.
+func TestEvent_MetadataFloat(t *testing.T) {
+	event := &domain.Event{Metadata: decodeMetadata(t, `{"xg": 0.35, "minute": 45, "onTarget": true}`)}
+	event.Metadata["count"] = int64(3)
+	event.Metadata["number"] = json.Number("1.5")
+	event.Metadata["bad"] = json.Number("abc")
+
+	tests := []struct {
+		key    string
+		want   float64
+		wantOK bool
+	}{
+		{key: "xg", want: 0.35, wantOK: true},
+		{key: "minute", want: 45, wantOK: true},
+		{key: "count", want: 3, wantOK: true},
+		{key: "number", want: 1.5, wantOK: true},
+		{key: "bad", wantOK: false},
+		{key: "onTarget", wantOK: false},
+		{key: "missing", wantOK: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			got, ok := event.MetadataFloat(tt.key)
+			if ok != tt.wantOK || got != tt.want {
+				t.Errorf("MetadataFloat(%q) = %v, %v; want %v, %v", tt.key, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+// TestEvent_MetadataStringAndBool tests that string and bool access does not
+// coerce other types.
+func TestEvent_MetadataStringAndBool(t *testing.T) {
+	event := &domain.Event{Metadata: decodeMetadata(t, `{"position": "penalty", "onTarget": true, "minute": 45, "flag": "true"}`)}
+
+	if got, ok := event.MetadataString("position"); !ok || got != "penalty" {
+		t.Errorf("MetadataString(position) = %q, %v; want penalty, true", got, ok)
+	}
+	if got, ok := event.MetadataString("minute"); ok || got != "" {
+		t.Errorf("MetadataString(minute) = %q, %v; want \"\", false", got, ok)
+	}
+	if _, ok := event.MetadataString("missing"); ok {
+		t.Error("MetadataString(missing) reported ok")
+	}
+
+	if got, ok := event.MetadataBool("onTarget"); !ok || !got {
+		t.Errorf("MetadataBool(onTarget) = %v, %v; want true, true", got, ok)
+	}
+	if got, ok := event.MetadataBool("flag"); ok || got {
+		t.Errorf("MetadataBool(flag) = %v, %v; want false, false", got, ok)
+	}
+	if _, ok := event.MetadataBool("missing"); ok {
+		t.Error("MetadataBool(missing) reported ok")
+	}
+}
+
+// TestDecodeJSON_UseNumber tests that integer metadata round-trips exactly
+// through a Kafka message when decoded with useNumber.
+func TestDecodeJSON_UseNumber(t *testing.T) {
+	const raw = `{"minute":45,"sequence":9007199254740993,"xg":0.35}`
+
+	var metadata map[string]interface{}
+	if err := domain.DecodeJSON([]byte(raw), &metadata, true); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if metadata["minute"] != json.Number("45") {
+		t.Errorf("expected minute as json.Number 45, got %T %v", metadata["minute"], metadata["minute"])
+	}
+
+	event := &domain.Event{EventID: uuid.New(), MatchID: "match-123", EventType: domain.EventTypeGoal, TeamID: 1, Timestamp: time.Now().UTC(), Metadata: metadata}
+	data, err := event.ToKafkaMessage()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	decoded, err := domain.DecodeKafkaMessage(data, true)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := decoded.MetadataJSON(); got != raw {
+		t.Errorf("MetadataJSON() = %s; want %s", got, raw)
+	}
+	if got, ok := decoded.MetadataInt("sequence"); !ok || got != 9007199254740993 {
+		t.Errorf("MetadataInt(sequence) = %v, %v; want 9007199254740993, true", got, ok)
+	}
+	if got, ok := decoded.MetadataFloat("xg"); !ok || got != 0.35 {
+		t.Errorf("MetadataFloat(xg) = %v, %v; want 0.35, true", got, ok)
+	}
+
+	// Without useNumber the large integer is rounded through float64
+	decoded, err = domain.DecodeKafkaMessage(data, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, _ := decoded.MetadataInt("sequence"); got == 9007199254740993 {
+		t.Error("expected float64 decoding to lose precision")
+	}
+}
+
+// TestDecodeJSON_TrailingData tests that useNumber decoding rejects trailing
+// data like json.Unmarshal does.
+func TestDecodeJSON_TrailingData(t *testing.T) {
+	for _, useNumber := range []bool{false, true} {
+		var metadata map[string]interface{}
+		if err := domain.DecodeJSON([]byte(`{"minute":45}}`), &metadata, useNumber); err == nil {
+			t.Errorf("useNumber=%v: expected error for trailing data", useNumber)
+		}
+	}
+}
+
+// TestEvent_MetadataNil tests that accessors are safe on events without metadata.
+func TestEvent_MetadataNil(t *testing.T) {
+	event := &domain.Event{}
+
+	if _, ok := event.MetadataInt("minute"); ok {
+		t.Error("MetadataInt reported ok on nil metadata")
+	}
+	if _, ok := event.MetadataFloat("minute"); ok {
+		t.Error("MetadataFloat reported ok on nil metadata")
+	}
+	if _, ok := event.MetadataString("position"); ok {
+		t.Error("MetadataString reported ok on nil metadata")
+	}
+	if _, ok := event.MetadataBool("onTarget"); ok {
+		t.Error("MetadataBool reported ok on nil metadata")
+	}
+}
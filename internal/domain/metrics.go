@@ -1,6 +1,13 @@
 package domain
 
-import "time"
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
 
 // MatchMetrics represents the aggregated metrics for a match.
 // Used as the response for GET /api/matches/{matchId}/metrics.
@@ -11,10 +18,43 @@ type MatchMetrics struct {
 	Goals                   int64                    `json:"goals"`
 	YellowCards             int64                    `json:"yellowCards"`
 	RedCards                int64                    `json:"redCards"`
+	DistinctPlayers         int64                    `json:"distinctPlayers,omitempty"`
 	FirstEventAt            *time.Time               `json:"firstEventAt,omitempty"`
 	LastEventAt             *time.Time               `json:"lastEventAt,omitempty"`
+	AvgEventsPerMinute      float64                  `json:"avgEventsPerMinute"`
 	PeakMinute              *PeakEngagement          `json:"peakMinute,omitempty"`
 	ResponseTimePercentiles *ResponseTimePercentiles `json:"responseTimePercentiles,omitempty"`
+
+	// LongestScorelessStreakSeconds is the longest gap between consecutive goals.
+	// Zero, and omitted, when the match has fewer than two goals.
+	LongestScorelessStreakSeconds int64 `json:"longestScorelessStreakSeconds,omitempty"`
+
+	// ByPhase holds totals per match phase, keyed by phase name. Only phases
+	// the match has reached are present.
+	ByPhase map[string]PhaseMetrics `json:"byPhase,omitempty"`
+
+	// Competition and TeamNames come from registered MatchInfo, when present.
+	Competition string         `json:"competition,omitempty"`
+	TeamNames   map[int]string `json:"teamNames,omitempty"`
+
+	// ClosedAt is set when these metrics are the match's final snapshot, taken
+	// when it was closed. Events stored after that do not change them.
+	ClosedAt *time.Time `json:"closedAt,omitempty"`
+}
+
+// PhaseMetrics holds the event totals of one phase of a match.
+type PhaseMetrics struct {
+	TotalEvents int64 `json:"totalEvents"`
+	Goals       int64 `json:"goals"`
+	YellowCards int64 `json:"yellowCards"`
+	RedCards    int64 `json:"redCards"`
+}
+
+// MetricsOptions selects the optional parts of a match metrics query. The zero
+// value computes everything.
+type MetricsOptions struct {
+	// SkipPeak omits the peak engagement minute, saving its per-minute query.
+	SkipPeak bool
 }
 
 // ResponseTimePercentiles represents response time latency percentiles in milliseconds.
@@ -24,10 +64,12 @@ type ResponseTimePercentiles struct {
 	P99 float64 `json:"p99"`
 }
 
-// PeakEngagement represents the minute with the highest event count.
+// PeakEngagement represents the minute with the highest engagement score.
+// Score is the weighted event count; with default weights it equals EventCount.
 type PeakEngagement struct {
 	Minute     time.Time `json:"minute"`
 	EventCount int64     `json:"eventCount"`
+	Score      float64   `json:"score"`
 }
 
 // EventsPerMinute represents the count of events per minute and type.
@@ -37,6 +79,104 @@ type EventsPerMinute struct {
 	EventCount int64     `json:"eventCount"`
 }
 
+// EventsPerSecond represents the count of events in one second of a match.
+type EventsPerSecond struct {
+	Second     time.Time `json:"second"`
+	EventCount int64     `json:"eventCount"`
+}
+
+// SnakeCaseMatchMetrics is the snake_case JSON representation of MatchMetrics
+// for clients that cannot consume the default camelCase field names.
+type SnakeCaseMatchMetrics struct {
+	MatchID                 string                            `json:"match_id"`
+	TotalEvents             int64                             `json:"total_events"`
+	EventsByType            map[string]int64                  `json:"events_by_type"`
+	Goals                   int64                             `json:"goals"`
+	YellowCards             int64                             `json:"yellow_cards"`
+	RedCards                int64                             `json:"red_cards"`
+	DistinctPlayers         int64                             `json:"distinct_players,omitempty"`
+	FirstEventAt            *time.Time                        `json:"first_event_at,omitempty"`
+	LastEventAt             *time.Time                        `json:"last_event_at,omitempty"`
+	AvgEventsPerMinute      float64                           `json:"avg_events_per_minute"`
+	PeakMinute              *SnakeCasePeakEngagement          `json:"peak_minute,omitempty"`
+	ResponseTimePercentiles *SnakeCaseResponseTimePercentiles `json:"response_time_percentiles,omitempty"`
+
+	LongestScorelessStreakSeconds int64 `json:"longest_scoreless_streak_seconds,omitempty"`
+
+	ByPhase map[string]SnakeCasePhaseMetrics `json:"by_phase,omitempty"`
+
+	Competition string         `json:"competition,omitempty"`
+	TeamNames   map[int]string `json:"team_names,omitempty"`
+	ClosedAt    *time.Time     `json:"closed_at,omitempty"`
+}
+
+// SnakeCasePhaseMetrics is the snake_case JSON representation of PhaseMetrics.
+type SnakeCasePhaseMetrics struct {
+	TotalEvents int64 `json:"total_events"`
+	Goals       int64 `json:"goals"`
+	YellowCards int64 `json:"yellow_cards"`
+	RedCards    int64 `json:"red_cards"`
+}
+
+// SnakeCasePeakEngagement is the snake_case JSON representation of PeakEngagement.
+type SnakeCasePeakEngagement struct {
+	Minute     time.Time `json:"minute"`
+	EventCount int64     `json:"event_count"`
+	Score      float64   `json:"score"`
+}
+
+// SnakeCaseResponseTimePercentiles is the snake_case JSON representation of
+// ResponseTimePercentiles. The field names are already lowercase.
+type SnakeCaseResponseTimePercentiles ResponseTimePercentiles
+
+// SnakeCase converts the metrics to their snake_case JSON representation.
+func (m *MatchMetrics) SnakeCase() *SnakeCaseMatchMetrics {
+	s := &SnakeCaseMatchMetrics{
+		MatchID:            m.MatchID,
+		TotalEvents:        m.TotalEvents,
+		EventsByType:       m.EventsByType,
+		Goals:              m.Goals,
+		YellowCards:        m.YellowCards,
+		RedCards:           m.RedCards,
+		DistinctPlayers:    m.DistinctPlayers,
+		FirstEventAt:       m.FirstEventAt,
+		LastEventAt:        m.LastEventAt,
+		AvgEventsPerMinute: m.AvgEventsPerMinute,
+		Competition:        m.Competition,
+		TeamNames:          m.TeamNames,
+		ClosedAt:           m.ClosedAt,
+
+		LongestScorelessStreakSeconds: m.LongestScorelessStreakSeconds,
+	}
+	if m.PeakMinute != nil {
+		s.PeakMinute = &SnakeCasePeakEngagement{
+			Minute:     m.PeakMinute.Minute,
+			EventCount: m.PeakMinute.EventCount,
+			Score:      m.PeakMinute.Score,
+		}
+	}
+	if m.ResponseTimePercentiles != nil {
+		p := SnakeCaseResponseTimePercentiles(*m.ResponseTimePercentiles)
+		s.ResponseTimePercentiles = &p
+	}
+	if m.ByPhase != nil {
+		s.ByPhase = make(map[string]SnakeCasePhaseMetrics, len(m.ByPhase))
+		for name, p := range m.ByPhase {
+			s.ByPhase[name] = SnakeCasePhaseMetrics(p)
+		}
+	}
+	return s
+}
+
+// ApplyMatchInfo copies registered match details onto the metrics. A nil info is a no-op.
+func (m *MatchMetrics) ApplyMatchInfo(info *MatchInfo) {
+	if info == nil {
+		return
+	}
+	m.Competition = info.Competition
+	m.TeamNames = info.TeamNames
+}
+
 // NewMatchMetrics creates a new MatchMetrics with initialized maps.
 func NewMatchMetrics(matchID string) *MatchMetrics {
 	return &MatchMetrics{
@@ -44,3 +184,421 @@ func NewMatchMetrics(matchID string) *MatchMetrics {
 		EventsByType: make(map[string]int64),
 	}
 }
+
+// AvgEventsPerMinute returns the average event rate between the first and last event.
+// Spans shorter than a minute, including a single instant, count as one minute.
+func AvgEventsPerMinute(totalEvents int64, first, last time.Time) float64 {
+	if totalEvents <= 0 {
+		return 0
+	}
+	minutes := last.Sub(first).Minutes()
+	if minutes < 1 {
+		minutes = 1
+	}
+	return float64(totalEvents) / minutes
+}
+
+// LongestScorelessStreakSeconds returns the longest gap, in whole seconds,
+// between consecutive goal timestamps. Fewer than two goals have no streak and
+// return zero.
+func LongestScorelessStreakSeconds(goals []time.Time) int64 {
+	if len(goals) < 2 {
+		return 0
+	}
+	sorted := make([]time.Time, len(goals))
+	copy(sorted, goals)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Before(sorted[j]) })
+
+	var longest time.Duration
+	for i := 1; i < len(sorted); i++ {
+		if gap := sorted[i].Sub(sorted[i-1]); gap > longest {
+			longest = gap
+		}
+	}
+	return int64(longest / time.Second)
+}
+
+// EngagementWeights maps event types to the weight they contribute to the
+// engagement score used when finding the peak minute.
+type EngagementWeights map[EventType]float64
+
+// DefaultEngagementWeights returns weights of 1.0 for every valid event type,
+// which makes the engagement score equal to the raw event count.
+func DefaultEngagementWeights() EngagementWeights {
+	weights := make(EngagementWeights, len(ValidEventTypes))
+	for eventType := range ValidEventTypes {
+		weights[eventType] = 1.0
+	}
+	return weights
+}
+
+// Weight returns the weight for the given event type, defaulting to 1.0
+// for types without an explicit weight.
+func (w EngagementWeights) Weight(eventType EventType) float64 {
+	if weight, ok := w[eventType]; ok {
+		return weight
+	}
+	return 1.0
+}
+
+// ParseEngagementWeights parses a comma-separated list of type=weight pairs,
+// e.g. "goal=10,shot=3". Types not listed keep the default weight of 1.0.
+// Returns a ValidationError for unknown event types or invalid weights.
+func ParseEngagementWeights(s string) (EngagementWeights, error) {
+	weights := DefaultEngagementWeights()
+	if strings.TrimSpace(s) == "" {
+		return weights, nil
+	}
+
+	for _, pair := range strings.Split(s, ",") {
+		name, value, found := strings.Cut(strings.TrimSpace(pair), "=")
+		if !found {
+			return nil, NewValidationError("engagementWeights", fmt.Sprintf("invalid pair %q, expected type=weight", pair))
+		}
+
+		eventType := EventType(strings.TrimSpace(name))
+		if !ValidEventTypes[eventType] {
+			return nil, NewValidationError("engagementWeights", fmt.Sprintf("unknown event type %q", eventType))
+		}
+
+		weight, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil || weight < 0 {
+			return nil, NewValidationError("engagementWeights", fmt.Sprintf("invalid weight %q for %s", value, eventType))
+		}
+		weights[eventType] = weight
+	}
+
+	return weights, nil
+}
+
+// MatchPhase is a named period of a match, starting at an offset from kickoff.
+type MatchPhase struct {
+	Name  string
+	Start time.Duration
+}
+
+// MatchPhases are the phases of a match in order of their start. Each phase
+// lasts until the next one starts; the last one never ends. The first phase
+// starts at kickoff.
+type MatchPhases []MatchPhase
+
+// DefaultMatchPhases returns the regular football phases: 45 minute halves
+// followed by extra time.
+func DefaultMatchPhases() MatchPhases {
+	return MatchPhases{
+		{Name: "first_half", Start: 0},
+		{Name: "second_half", Start: 45 * time.Minute},
+		{Name: "extra_time", Start: 90 * time.Minute},
+	}
+}
+
+// PhaseAt returns the name of the phase an event elapsed after kickoff falls
+// in. Offsets before kickoff belong to the first phase.
+func (p MatchPhases) PhaseAt(elapsed time.Duration) string {
+	if len(p) == 0 {
+		return ""
+	}
+	name := p[0].Name
+	for _, phase := range p[1:] {
+		if elapsed < phase.Start {
+			break
+		}
+		name = phase.Name
+	}
+	return name
+}
+
+// Reached returns the phases that have started once elapsed has passed since
+// kickoff. A match shorter than a phase boundary has not reached the later phases.
+func (p MatchPhases) Reached(elapsed time.Duration) MatchPhases {
+	for i, phase := range p {
+		if i > 0 && elapsed < phase.Start {
+			return p[:i]
+		}
+	}
+	return p
+}
+
+// phaseNamePattern limits phase names to what is safe to inline in queries.
+var phaseNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+// ParseMatchPhases parses a comma-separated list of name=start pairs, where
+// start is a duration after kickoff, e.g. "first_half=0,second_half=45m".
+// An empty string yields DefaultMatchPhases. The first phase must start at 0
+// and each later one after the previous. Returns a ValidationError otherwise.
+func ParseMatchPhases(s string) (MatchPhases, error) {
+	if strings.TrimSpace(s) == "" {
+		return DefaultMatchPhases(), nil
+	}
+
+	var phases MatchPhases
+	for _, pair := range strings.Split(s, ",") {
+		name, value, found := strings.Cut(strings.TrimSpace(pair), "=")
+		if !found {
+			return nil, NewValidationError("matchPhases", fmt.Sprintf("invalid pair %q, expected name=start", pair))
+		}
+
+		name = strings.TrimSpace(name)
+		start, err := time.ParseDuration(strings.TrimSpace(value))
+		if err != nil {
+			return nil, NewValidationError("matchPhases", fmt.Sprintf("invalid start %q for %s", value, name))
+		}
+		phases = append(phases, MatchPhase{Name: name, Start: start})
+	}
+
+	if err := phases.Validate(); err != nil {
+		return nil, err
+	}
+	return phases, nil
+}
+
+// Validate returns a ValidationError unless the phases have distinct,
+// lowercase names, the first starts at 0 and each later one after the previous.
+func (p MatchPhases) Validate() error {
+	seen := make(map[string]bool, len(p))
+	for i, phase := range p {
+		if !phaseNamePattern.MatchString(phase.Name) {
+			return NewValidationError("matchPhases", fmt.Sprintf("invalid phase name %q", phase.Name))
+		}
+		if seen[phase.Name] {
+			return NewValidationError("matchPhases", fmt.Sprintf("duplicate phase %q", phase.Name))
+		}
+		seen[phase.Name] = true
+
+		if i == 0 && phase.Start != 0 {
+			return NewValidationError("matchPhases", fmt.Sprintf("first phase %s must start at 0", phase.Name))
+		}
+		if i > 0 && phase.Start <= p[i-1].Start {
+			return NewValidationError("matchPhases", fmt.Sprintf("phase %s must start after %s", phase.Name, p[i-1].Name))
+		}
+	}
+	return nil
+}
+
+// MaxEventMatrixMinutes bounds the number of zero-filled rows in an EventMatrix.
+const MaxEventMatrixMinutes = 24 * 60
+
+// EventMatrix holds event counts bucketed by minute (rows) and event type (columns),
+// suitable for heatmap rendering. The matrix is dense: every minute between the
+// first and last active minute has a row and every cell has a count, zero if none.
+// Counts[i][j] is the count for Minutes[i] and EventTypes[j].
+type EventMatrix struct {
+	MatchID    string      `json:"matchId"`
+	Minutes    []time.Time `json:"minutes"`
+	EventTypes []string    `json:"eventTypes"`
+	Counts     [][]int64   `json:"counts"`
+}
+
+// NewEventMatrix pivots a sparse per-minute breakdown into a dense EventMatrix.
+// Columns cover all valid event types in sorted order, followed by any unknown
+// types present in the input.
+func NewEventMatrix(matchID string, perMinute []EventsPerMinute) EventMatrix {
+	matrix := EventMatrix{
+		MatchID:    matchID,
+		Minutes:    []time.Time{},
+		EventTypes: []string{},
+		Counts:     [][]int64{},
+	}
+
+	columns := make(map[string]int)
+	for eventType := range ValidEventTypes {
+		matrix.EventTypes = append(matrix.EventTypes, string(eventType))
+	}
+	sort.Strings(matrix.EventTypes)
+
+	var extra []string
+	var first, last time.Time
+	for i, epm := range perMinute {
+		if !ValidEventTypes[EventType(epm.EventType)] && !containsString(extra, epm.EventType) {
+			extra = append(extra, epm.EventType)
+		}
+		minute := epm.Minute.Truncate(time.Minute)
+		if i == 0 || minute.Before(first) {
+			first = minute
+		}
+		if i == 0 || minute.After(last) {
+			last = minute
+		}
+	}
+	sort.Strings(extra)
+	matrix.EventTypes = append(matrix.EventTypes, extra...)
+	for i, eventType := range matrix.EventTypes {
+		columns[eventType] = i
+	}
+
+	if len(perMinute) == 0 {
+		return matrix
+	}
+
+	// Fill every minute between first and last, unless a stray timestamp would
+	// blow the matrix up; then keep only the minutes that have events.
+	rowIndex := make(map[time.Time]int)
+	if span := int(last.Sub(first)/time.Minute) + 1; span <= MaxEventMatrixMinutes {
+		for i := 0; i < span; i++ {
+			matrix.Minutes = append(matrix.Minutes, first.Add(time.Duration(i)*time.Minute))
+		}
+	} else {
+		for _, epm := range perMinute {
+			minute := epm.Minute.Truncate(time.Minute)
+			if _, seen := rowIndex[minute]; !seen {
+				rowIndex[minute] = -1
+				matrix.Minutes = append(matrix.Minutes, minute)
+			}
+		}
+		sort.Slice(matrix.Minutes, func(i, j int) bool { return matrix.Minutes[i].Before(matrix.Minutes[j]) })
+	}
+	for i, minute := range matrix.Minutes {
+		rowIndex[minute] = i
+		matrix.Counts = append(matrix.Counts, make([]int64, len(matrix.EventTypes)))
+	}
+
+	for _, epm := range perMinute {
+		row := rowIndex[epm.Minute.Truncate(time.Minute)]
+		matrix.Counts[row][columns[epm.EventType]] += epm.EventCount
+	}
+
+	return matrix
+}
+
+// EventTypeShare is one event type's count and share of a match's events.
+type EventTypeShare struct {
+	EventType  string  `json:"eventType"`
+	Count      int64   `json:"count"`
+	Percentage float64 `json:"percentage"`
+}
+
+// EventDistribution breaks a match's events down by type as percentages, for
+// pie charts. Types are ordered by count, largest first.
+type EventDistribution struct {
+	MatchID     string           `json:"matchId"`
+	TotalEvents int64            `json:"totalEvents"`
+	Types       []EventTypeShare `json:"types"`
+}
+
+// NewEventDistribution computes percentages from per-type counts. Percentages
+// are rounded to one decimal place with the largest remainder method, so they
+// always sum to exactly 100 when there are events. Types with no events are
+// omitted.
+func NewEventDistribution(matchID string, eventsByType map[string]int64) EventDistribution {
+	dist := EventDistribution{
+		MatchID: matchID,
+		Types:   []EventTypeShare{},
+	}
+	for eventType, count := range eventsByType {
+		if count <= 0 {
+			continue
+		}
+		dist.TotalEvents += count
+		dist.Types = append(dist.Types, EventTypeShare{EventType: eventType, Count: count})
+	}
+	sort.Slice(dist.Types, func(i, j int) bool {
+		if dist.Types[i].Count != dist.Types[j].Count {
+			return dist.Types[i].Count > dist.Types[j].Count
+		}
+		return dist.Types[i].EventType < dist.Types[j].EventType
+	})
+	if dist.TotalEvents == 0 {
+		return dist
+	}
+
+	// Work in tenths of a percent: floor every share, then hand the tenths
+	// lost to rounding to the types with the largest remainders.
+	const scale = 1000
+	tenths := make([]int64, len(dist.Types))
+	remainders := make([]int64, len(dist.Types))
+	order := make([]int, len(dist.Types))
+	var assigned int64
+	for i, share := range dist.Types {
+		tenths[i] = share.Count * scale / dist.TotalEvents
+		remainders[i] = share.Count * scale % dist.TotalEvents
+		assigned += tenths[i]
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return remainders[order[a]] > remainders[order[b]] })
+	for i := int64(0); i < scale-assigned; i++ {
+		tenths[order[i]]++
+	}
+	for i := range dist.Types {
+		dist.Types[i].Percentage = float64(tenths[i]) / 10
+	}
+
+	return dist
+}
+
+// containsString reports whether s is present in values.
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// ConversionStats summarizes a match's finishing: shots taken, shots with
+// metadata.on_target set, and goals scored.
+type ConversionStats struct {
+	MatchID       string `json:"matchId"`
+	Shots         int64  `json:"shots"`
+	ShotsOnTarget int64  `json:"shotsOnTarget"`
+	Goals         int64  `json:"goals"`
+
+	// OnTargetPercentage is ShotsOnTarget as a percentage of Shots.
+	OnTargetPercentage float64 `json:"onTargetPercentage"`
+	// GoalsPerShot is Goals divided by Shots.
+	GoalsPerShot float64 `json:"goalsPerShot"`
+}
+
+// NewConversionStats computes the conversion ratios from shot and goal counts.
+// Both ratios are zero when no shots were taken.
+func NewConversionStats(matchID string, shots, shotsOnTarget, goals int64) ConversionStats {
+	stats := ConversionStats{
+		MatchID:       matchID,
+		Shots:         shots,
+		ShotsOnTarget: shotsOnTarget,
+		Goals:         goals,
+	}
+	if shots > 0 {
+		stats.OnTargetPercentage = float64(shotsOnTarget) / float64(shots) * 100
+		stats.GoalsPerShot = float64(goals) / float64(shots)
+	}
+	return stats
+}
+
+// PlayerStat is one row of a leaderboard: a player's count of an event type
+// across matches, and how many matches those events came from.
+type PlayerStat struct {
+	PlayerID string `json:"playerId"`
+	Count    int64  `json:"count"`
+	Matches  int64  `json:"matches"`
+}
+
+// MaxLeaderboardLimit bounds the number of players a leaderboard returns.
+const MaxLeaderboardLimit = 100
+
+// ValidateLeaderboardQuery checks the arguments of a leaderboard query: a
+// valid event type, a non-empty [from, to) range and a limit from 1 to
+// MaxLeaderboardLimit. Returns a ValidationError naming the bad argument.
+func ValidateLeaderboardQuery(eventType string, from, to time.Time, limit int) error {
+	if !ValidEventTypes[EventType(eventType)] {
+		return NewValidationError("type", fmt.Sprintf("unknown event type %q", eventType))
+	}
+	if !to.After(from) {
+		return NewValidationError("to", "must be after from")
+	}
+	if limit < 1 || limit > MaxLeaderboardLimit {
+		return NewValidationError("limit", fmt.Sprintf("must be between 1 and %d", MaxLeaderboardLimit))
+	}
+	return nil
+}
+
+// ValidateLeaderboardFetch checks the arguments a repository receives for a
+// leaderboard. It is ValidateLeaderboardQuery, except that the limit may
+// exceed MaxLeaderboardLimit by one: handlers fetch a player past the page to
+// know whether more qualified.
+func ValidateLeaderboardFetch(eventType string, from, to time.Time, limit int) error {
+	if limit == MaxLeaderboardLimit+1 {
+		limit = MaxLeaderboardLimit
+	}
+	return ValidateLeaderboardQuery(eventType, from, to, limit)
+}
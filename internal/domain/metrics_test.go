@@ -0,0 +1,434 @@
+package domain_test
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"fanfinity/internal/domain"
+)
+
+// TestDefaultEngagementWeights tests that all valid event types default to a weight of 1.0.
+func TestDefaultEngagementWeights(t *testing.T) {
+	weights := domain.DefaultEngagementWeights()
+
+	for eventType := range domain.ValidEventTypes {
+		if weights.Weight(eventType) != 1.0 {
+			t.Errorf("expected weight 1.0 for %s, got %f", eventType, weights.Weight(eventType))
+		}
+	}
+}
+
+// TestParseEngagementWeights tests parsing of type=weight pairs.
+func TestParseEngagementWeights(t *testing.T) {
+	weights, err := domain.ParseEngagementWeights("goal=10, shot=2.5")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if weights.Weight(domain.EventTypeGoal) != 10 {
+		t.Errorf("expected goal weight 10, got %f", weights.Weight(domain.EventTypeGoal))
+	}
+	if weights.Weight(domain.EventTypeShot) != 2.5 {
+		t.Errorf("expected shot weight 2.5, got %f", weights.Weight(domain.EventTypeShot))
+	}
+	if weights.Weight(domain.EventTypePass) != 1.0 {
+		t.Errorf("expected unlisted pass weight 1.0, got %f", weights.Weight(domain.EventTypePass))
+	}
+}
+
+// TestParseEngagementWeights_Empty tests that an empty string yields default weights.
+func TestParseEngagementWeights_Empty(t *testing.T) {
+	weights, err := domain.ParseEngagementWeights("")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if weights.Weight(domain.EventTypeGoal) != 1.0 {
+		t.Errorf("expected default goal weight 1.0, got %f", weights.Weight(domain.EventTypeGoal))
+	}
+}
+
+// TestParseEngagementWeights_Invalid tests rejection of malformed input.
+func TestParseEngagementWeights_Invalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"missing weight", "goal"},
+		{"unknown type", "penalty=5"},
+		{"non-numeric weight", "goal=high"},
+		{"negative weight", "goal=-1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := domain.ParseEngagementWeights(tt.input)
+			if err == nil {
+				t.Fatalf("expected error for %q", tt.input)
+			}
+			if !domain.IsValidationError(err) {
+				t.Errorf("expected ValidationError, got %T", err)
+			}
+		})
+	}
+}
+
+// TestParseMatchPhases tests parsing of phase boundaries and the empty default.
+func TestParseMatchPhases(t *testing.T) {
+	phases, err := domain.ParseMatchPhases("first_half=0, second_half=45m")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	want := domain.MatchPhases{{Name: "first_half", Start: 0}, {Name: "second_half", Start: 45 * time.Minute}}
+	if !reflect.DeepEqual(phases, want) {
+		t.Errorf("expected %v, got %v", want, phases)
+	}
+
+	phases, err = domain.ParseMatchPhases("")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if !reflect.DeepEqual(phases, domain.DefaultMatchPhases()) {
+		t.Errorf("expected default phases for an empty string, got %v", phases)
+	}
+}
+
+// TestParseMatchPhases_Invalid tests rejection of malformed phase boundaries.
+func TestParseMatchPhases_Invalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"missing start", "first_half"},
+		{"invalid duration", "first_half=soon"},
+		{"first phase after kickoff", "first_half=1m"},
+		{"out of order", "first_half=0,extra_time=90m,second_half=45m"},
+		{"duplicate name", "half=0,half=45m"},
+		{"unsafe name", "first'half=0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := domain.ParseMatchPhases(tt.input)
+			if err == nil {
+				t.Fatalf("expected error for %q", tt.input)
+			}
+			if !domain.IsValidationError(err) {
+				t.Errorf("expected ValidationError, got %T", err)
+			}
+		})
+	}
+}
+
+// TestMatchPhases_PhaseAtAndReached tests bucketing of offsets from kickoff
+// into phases, and which phases a match of a given length has reached.
+func TestMatchPhases_PhaseAtAndReached(t *testing.T) {
+	phases := domain.DefaultMatchPhases()
+
+	tests := []struct {
+		elapsed     time.Duration
+		wantPhase   string
+		wantReached int
+	}{
+		{-time.Second, "first_half", 1},
+		{0, "first_half", 1},
+		{44*time.Minute + 59*time.Second, "first_half", 1},
+		{45 * time.Minute, "second_half", 2},
+		{89 * time.Minute, "second_half", 2},
+		{90 * time.Minute, "extra_time", 3},
+		{130 * time.Minute, "extra_time", 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.elapsed.String(), func(t *testing.T) {
+			if got := phases.PhaseAt(tt.elapsed); got != tt.wantPhase {
+				t.Errorf("expected phase %q, got %q", tt.wantPhase, got)
+			}
+			if got := len(phases.Reached(tt.elapsed)); got != tt.wantReached {
+				t.Errorf("expected %d reached phases, got %d", tt.wantReached, got)
+			}
+		})
+	}
+}
+
+// TestNewEventMatrix_DenseZeroFill tests that sparse per-minute input is pivoted
+// into a dense matrix with zero-filled cells and gap minutes.
+func TestNewEventMatrix_DenseZeroFill(t *testing.T) {
+	start := time.Date(2024, 1, 15, 14, 0, 0, 0, time.UTC)
+	perMinute := []domain.EventsPerMinute{
+		{Minute: start, EventType: "pass", EventCount: 12},
+		{Minute: start, EventType: "goal", EventCount: 1},
+		{Minute: start.Add(3 * time.Minute), EventType: "shot", EventCount: 2},
+	}
+
+	matrix := domain.NewEventMatrix("match-123", perMinute)
+
+	if len(matrix.EventTypes) != len(domain.ValidEventTypes) {
+		t.Fatalf("expected %d columns, got %d", len(domain.ValidEventTypes), len(matrix.EventTypes))
+	}
+	if len(matrix.Minutes) != 4 {
+		t.Fatalf("expected 4 rows (minutes 0-3), got %d", len(matrix.Minutes))
+	}
+	if len(matrix.Counts) != len(matrix.Minutes) {
+		t.Fatalf("expected %d count rows, got %d", len(matrix.Minutes), len(matrix.Counts))
+	}
+
+	col := make(map[string]int)
+	for i, eventType := range matrix.EventTypes {
+		col[eventType] = i
+	}
+
+	expected := map[int]map[string]int64{
+		0: {"pass": 12, "goal": 1},
+		3: {"shot": 2},
+	}
+	for row, counts := range matrix.Counts {
+		if len(counts) != len(matrix.EventTypes) {
+			t.Fatalf("row %d has %d cells, expected %d", row, len(counts), len(matrix.EventTypes))
+		}
+		if !matrix.Minutes[row].Equal(start.Add(time.Duration(row) * time.Minute)) {
+			t.Errorf("row %d has minute %v", row, matrix.Minutes[row])
+		}
+		for eventType, c := range col {
+			want := expected[row][eventType]
+			if counts[c] != want {
+				t.Errorf("cell [%d][%s] = %d, expected %d", row, eventType, counts[c], want)
+			}
+		}
+	}
+}
+
+// TestNewEventMatrix_Empty tests that an empty input yields an empty, non-nil matrix.
+func TestNewEventMatrix_Empty(t *testing.T) {
+	matrix := domain.NewEventMatrix("match-123", nil)
+
+	if matrix.Minutes == nil || matrix.Counts == nil {
+		t.Error("expected non-nil slices for JSON encoding")
+	}
+	if len(matrix.Minutes) != 0 || len(matrix.Counts) != 0 {
+		t.Errorf("expected no rows, got %d", len(matrix.Minutes))
+	}
+}
+
+// TestNewEventMatrix_StrayTimestamp tests that a far outlier does not zero-fill
+// every minute in between.
+func TestNewEventMatrix_StrayTimestamp(t *testing.T) {
+	start := time.Date(2024, 1, 15, 14, 0, 0, 0, time.UTC)
+	perMinute := []domain.EventsPerMinute{
+		{Minute: start, EventType: "pass", EventCount: 1},
+		{Minute: start.Add(30 * 24 * time.Hour), EventType: "pass", EventCount: 1},
+	}
+
+	matrix := domain.NewEventMatrix("match-123", perMinute)
+
+	if len(matrix.Minutes) != 2 {
+		t.Errorf("expected 2 rows for distant minutes, got %d", len(matrix.Minutes))
+	}
+}
+
+// TestNewEventDistribution tests percentages, ordering and rounding.
+func TestNewEventDistribution(t *testing.T) {
+	tests := []struct {
+		name         string
+		eventsByType map[string]int64
+		expected     []domain.EventTypeShare
+	}{
+		{
+			name:         "exact percentages",
+			eventsByType: map[string]int64{"pass": 6, "shot": 3, "goal": 1},
+			expected: []domain.EventTypeShare{
+				{EventType: "pass", Count: 6, Percentage: 60},
+				{EventType: "shot", Count: 3, Percentage: 30},
+				{EventType: "goal", Count: 1, Percentage: 10},
+			},
+		},
+		{
+			name:         "thirds sum to 100",
+			eventsByType: map[string]int64{"pass": 1, "shot": 1, "goal": 1},
+			expected: []domain.EventTypeShare{
+				{EventType: "goal", Count: 1, Percentage: 33.4},
+				{EventType: "pass", Count: 1, Percentage: 33.3},
+				{EventType: "shot", Count: 1, Percentage: 33.3},
+			},
+		},
+		{
+			name:         "single type",
+			eventsByType: map[string]int64{"pass": 42},
+			expected: []domain.EventTypeShare{
+				{EventType: "pass", Count: 42, Percentage: 100},
+			},
+		},
+		{
+			name:         "zero counts omitted",
+			eventsByType: map[string]int64{"pass": 2, "goal": 0},
+			expected: []domain.EventTypeShare{
+				{EventType: "pass", Count: 2, Percentage: 100},
+			},
+		},
+		{
+			name:         "no events",
+			eventsByType: nil,
+			expected:     []domain.EventTypeShare{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dist := domain.NewEventDistribution("match-123", tt.eventsByType)
+
+			if !reflect.DeepEqual(dist.Types, tt.expected) {
+				t.Errorf("expected %+v, got %+v", tt.expected, dist.Types)
+			}
+		})
+	}
+}
+
+// TestNewEventDistribution_SumsTo100 tests that rounded percentages always add up.
+func TestNewEventDistribution_SumsTo100(t *testing.T) {
+	dist := domain.NewEventDistribution("match-123", map[string]int64{
+		"pass": 517, "shot": 41, "foul": 23, "corner": 11, "goal": 3, "yellow_card": 2, "red_card": 1,
+	})
+
+	var tenths int64
+	for _, share := range dist.Types {
+		tenths += int64(math.Round(share.Percentage * 10))
+	}
+	if tenths != 1000 {
+		t.Errorf("expected percentages to sum to 100, got %.1f", float64(tenths)/10)
+	}
+	if dist.TotalEvents != 598 {
+		t.Errorf("expected 598 total events, got %d", dist.TotalEvents)
+	}
+}
+
+// TestAvgEventsPerMinute tests the average rate, including spans under a minute.
+func TestAvgEventsPerMinute(t *testing.T) {
+	first := time.Date(2024, 1, 15, 14, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name     string
+		total    int64
+		last     time.Time
+		expected float64
+	}{
+		{"multi-minute", 900, first.Add(90 * time.Minute), 10},
+		{"single instant", 3, first, 3},
+		{"under a minute", 4, first.Add(30 * time.Second), 4},
+		{"no events", 0, first, 0},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := domain.AvgEventsPerMinute(tc.total, first, tc.last); got != tc.expected {
+				t.Errorf("expected %v, got %v", tc.expected, got)
+			}
+		})
+	}
+}
+
+// TestLongestScorelessStreakSeconds tests the longest gap between consecutive goals.
+func TestLongestScorelessStreakSeconds(t *testing.T) {
+	kickoff := time.Date(2024, 1, 15, 14, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name     string
+		goals    []time.Time
+		expected int64
+	}{
+		{"no goals", nil, 0},
+		{"one goal", []time.Time{kickoff.Add(10 * time.Minute)}, 0},
+		{
+			"several goals",
+			[]time.Time{
+				kickoff.Add(5 * time.Minute),
+				kickoff.Add(12 * time.Minute),
+				kickoff.Add(61*time.Minute + 30*time.Second),
+				kickoff.Add(70 * time.Minute),
+			},
+			49*60 + 30,
+		},
+		{
+			"unordered goals",
+			[]time.Time{kickoff.Add(30 * time.Minute), kickoff, kickoff.Add(40 * time.Minute)},
+			30 * 60,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := domain.LongestScorelessStreakSeconds(tc.goals); got != tc.expected {
+				t.Errorf("expected %d seconds, got %d", tc.expected, got)
+			}
+		})
+	}
+}
+
+// TestMatchMetrics_FieldNaming tests the camelCase and snake_case JSON for the same metrics.
+func TestMatchMetrics_FieldNaming(t *testing.T) {
+	at := time.Date(2024, 1, 15, 14, 30, 0, 0, time.UTC)
+	metrics := &domain.MatchMetrics{
+		MatchID:            "match-123",
+		TotalEvents:        10,
+		EventsByType:       map[string]int64{"yellow_card": 1, "pass": 9},
+		YellowCards:        1,
+		FirstEventAt:       &at,
+		LastEventAt:        &at,
+		AvgEventsPerMinute: 10,
+		PeakMinute:         &domain.PeakEngagement{Minute: at, EventCount: 10, Score: 10},
+		ResponseTimePercentiles: &domain.ResponseTimePercentiles{
+			P50: 1, P95: 2, P99: 3,
+		},
+	}
+
+	camel, err := json.Marshal(metrics)
+	if err != nil {
+		t.Fatalf("failed to marshal camelCase: %v", err)
+	}
+	wantCamel := `{"matchId":"match-123","totalEvents":10,"eventsByType":{"pass":9,"yellow_card":1},` +
+		`"goals":0,"yellowCards":1,"redCards":0,"firstEventAt":"2024-01-15T14:30:00Z",` +
+		`"lastEventAt":"2024-01-15T14:30:00Z","avgEventsPerMinute":10,` +
+		`"peakMinute":{"minute":"2024-01-15T14:30:00Z","eventCount":10,"score":10},` +
+		`"responseTimePercentiles":{"p50":1,"p95":2,"p99":3}}`
+	if string(camel) != wantCamel {
+		t.Errorf("unexpected camelCase JSON:\n got: %s\nwant: %s", camel, wantCamel)
+	}
+
+	snake, err := json.Marshal(metrics.SnakeCase())
+	if err != nil {
+		t.Fatalf("failed to marshal snake_case: %v", err)
+	}
+	wantSnake := `{"match_id":"match-123","total_events":10,"events_by_type":{"pass":9,"yellow_card":1},` +
+		`"goals":0,"yellow_cards":1,"red_cards":0,"first_event_at":"2024-01-15T14:30:00Z",` +
+		`"last_event_at":"2024-01-15T14:30:00Z","avg_events_per_minute":10,` +
+		`"peak_minute":{"minute":"2024-01-15T14:30:00Z","event_count":10,"score":10},` +
+		`"response_time_percentiles":{"p50":1,"p95":2,"p99":3}}`
+	if string(snake) != wantSnake {
+		t.Errorf("unexpected snake_case JSON:\n got: %s\nwant: %s", snake, wantSnake)
+	}
+}
+
+// TestMatchInfoRequest_ToMatchInfo tests validation of registered match details.
+func TestMatchInfoRequest_ToMatchInfo(t *testing.T) {
+	req := &domain.MatchInfoRequest{
+		Competition: "  Saudi Pro League ",
+		TeamNames:   map[int]string{1: "Al Hilal", 2: " Al Nassr"},
+	}
+	info, err := req.ToMatchInfo("match-123", 0)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if info.Competition != "Saudi Pro League" || info.TeamNames[2] != "Al Nassr" {
+		t.Errorf("expected trimmed names, got %+v", info)
+	}
+
+	// Team IDs follow the configured range
+	req = &domain.MatchInfoRequest{TeamNames: map[int]string{3: "Al Ittihad"}}
+	if _, err := req.ToMatchInfo("match-123", 2); !domain.IsValidationError(err) {
+		t.Errorf("expected ValidationError for team 3 with max 2, got: %v", err)
+	}
+	if _, err := req.ToMatchInfo("match-123", 4); err != nil {
+		t.Errorf("expected team 3 to be valid with max 4, got: %v", err)
+	}
+}
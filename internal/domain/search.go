@@ -0,0 +1,43 @@
+package domain
+
+import "fmt"
+
+// Limits on event searches.
+const (
+	// MaxMetadataFilters bounds the metadata filters in one search.
+	MaxMetadataFilters = 5
+	// MaxMetadataKeyLength bounds the length of a filtered metadata key.
+	MaxMetadataKeyLength = 64
+	// MaxSearchResults caps the events returned by a search.
+	MaxSearchResults = 1000
+)
+
+// ValidateMetadataFilters checks the metadata key/value filters of an event
+// search. Keys must be 1 to MaxMetadataKeyLength letters, digits, underscores
+// or hyphens, and at most MaxMetadataFilters filters are allowed.
+func ValidateMetadataFilters(filters map[string]string) error {
+	if len(filters) > MaxMetadataFilters {
+		return NewValidationError("meta", fmt.Sprintf("must have at most %d filters", MaxMetadataFilters))
+	}
+	for key := range filters {
+		if !validMetadataKey(key) {
+			return NewValidationError("meta."+key, fmt.Sprintf("key must be 1 to %d letters, digits, '_' or '-'", MaxMetadataKeyLength))
+		}
+	}
+	return nil
+}
+
+// validMetadataKey reports whether key is a non-empty, bounded identifier.
+func validMetadataKey(key string) bool {
+	if key == "" || len(key) > MaxMetadataKeyLength {
+		return false
+	}
+	for _, c := range key {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '_', c == '-':
+		default:
+			return false
+		}
+	}
+	return true
+}
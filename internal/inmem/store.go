@@ -0,0 +1,641 @@
+// Package inmem holds events in process memory, standing in for Kafka and
+// ClickHouse so the API server can run standalone for local development.
+package inmem
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"fanfinity/internal/domain"
+)
+
+// Config holds in-memory store settings.
+type Config struct {
+	// EngagementWeights weights each event type when computing the peak minute.
+	EngagementWeights domain.EngagementWeights
+
+	// Phases are the match phases live metrics are split into, by offset from
+	// the match's first event.
+	Phases domain.MatchPhases
+
+	// Clock stamps the closing time of closed matches. Nil means
+	// domain.SystemClock.
+	Clock domain.Clock
+}
+
+// DefaultConfig returns the default in-memory store configuration.
+func DefaultConfig() Config {
+	return Config{
+		EngagementWeights: domain.DefaultEngagementWeights(),
+		Phases:            domain.DefaultMatchPhases(),
+	}
+}
+
+// Store is both the event producer and the metrics repository of a server
+// running without Kafka and ClickHouse. Produced events are stored at once and
+// aggregated the same way the ClickHouse repository aggregates them, including
+// the exclusion of corrections and the events they correct. Events are keyed
+// by eventId, so an upsert or a redelivered event replaces the stored one.
+// Nothing is persisted: the store is empty after a restart.
+type Store struct {
+	config Config
+
+	mu     sync.RWMutex
+	events map[string][]*domain.Event // by match ID, in produce order
+	byID   map[uuid.UUID]*domain.Event
+	info   map[string]*domain.MatchInfo
+	final  map[string]*domain.MatchMetrics
+}
+
+// NewStore creates a Store with the default configuration.
+func NewStore() *Store {
+	store, _ := NewStoreWithConfig(DefaultConfig())
+	return store
+}
+
+// NewStoreWithConfig creates a Store with custom configuration. Zero config
+// values use the defaults. Returns an error if the match phases are invalid.
+func NewStoreWithConfig(cfg Config) (*Store, error) {
+	if cfg.EngagementWeights == nil {
+		cfg.EngagementWeights = domain.DefaultEngagementWeights()
+	}
+	if len(cfg.Phases) == 0 {
+		cfg.Phases = domain.DefaultMatchPhases()
+	}
+	if err := cfg.Phases.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid match phases: %w", err)
+	}
+	cfg.Clock = domain.ClockOrSystem(cfg.Clock)
+
+	return &Store{
+		config: cfg,
+		events: make(map[string][]*domain.Event),
+		byID:   make(map[uuid.UUID]*domain.Event),
+		info:   make(map[string]*domain.MatchInfo),
+		final:  make(map[string]*domain.MatchMetrics),
+	}, nil
+}
+
+// Produce stores a single event.
+func (s *Store) Produce(ctx context.Context, event *domain.Event) error {
+	if event == nil {
+		return fmt.Errorf("event cannot be nil")
+	}
+	return s.ProduceBatch(ctx, []*domain.Event{event})
+}
+
+// ProduceBatch stores a batch of events. An event whose eventId is already
+// stored replaces it, keeping its place in produce order unless it moved to
+// another match.
+func (s *Store) ProduceBatch(ctx context.Context, events []*domain.Event) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, event := range events {
+		if event == nil {
+			continue
+		}
+		stored := *event
+		stored.Timestamp = event.Timestamp.UTC()
+		existing, ok := s.byID[event.EventID]
+		s.byID[event.EventID] = &stored
+		if ok && s.replaceLocked(existing, &stored) {
+			continue
+		}
+		s.events[event.MatchID] = append(s.events[event.MatchID], &stored)
+	}
+	return nil
+}
+
+// replaceLocked puts stored in place of existing if both belong to the same
+// match, and otherwise drops existing from its match, reporting whether it
+// replaced it. Stored events are never modified, since readers may still hold
+// them. The caller must hold s.mu.
+func (s *Store) replaceLocked(existing, stored *domain.Event) bool {
+	events := s.events[existing.MatchID]
+	for i, candidate := range events {
+		if candidate != existing {
+			continue
+		}
+		if existing.MatchID == stored.MatchID {
+			events[i] = stored
+			return true
+		}
+		s.events[existing.MatchID] = append(events[:i:i], events[i+1:]...)
+		if len(s.events[existing.MatchID]) == 0 {
+			delete(s.events, existing.MatchID)
+		}
+		break
+	}
+	return false
+}
+
+// validEvents returns a match's events in timestamp order, without corrections
+// and the events they reference. The caller must hold s.mu.
+func (s *Store) validEvents(matchID string) []*domain.Event {
+	all := s.events[matchID]
+
+	corrected := make(map[uuid.UUID]bool)
+	for _, event := range all {
+		if !event.IsCorrection() {
+			continue
+		}
+		if raw, ok := event.Metadata["correctsEventId"].(string); ok {
+			if target, err := uuid.Parse(raw); err == nil {
+				corrected[target] = true
+			}
+		}
+	}
+
+	valid := make([]*domain.Event, 0, len(all))
+	for _, event := range all {
+		if event.IsCorrection() || corrected[event.EventID] {
+			continue
+		}
+		valid = append(valid, event)
+	}
+	sortByTimestamp(valid)
+	return valid
+}
+
+// sortByTimestamp orders events by timestamp, keeping produce order for ties.
+func sortByTimestamp(events []*domain.Event) {
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Timestamp.Before(events[j].Timestamp)
+	})
+}
+
+// GetMatchMetrics returns a closed match's final snapshot, or its metrics
+// computed from the stored events.
+func (s *Store) GetMatchMetrics(ctx context.Context, matchID string) (*domain.MatchMetrics, error) {
+	return s.GetMatchMetricsWithOptions(ctx, matchID, domain.MetricsOptions{})
+}
+
+// GetMatchMetricsWithOptions is GetMatchMetrics with optional parts of the live
+// metrics skipped per opts. A closed match's snapshot is returned whole.
+func (s *Store) GetMatchMetricsWithOptions(ctx context.Context, matchID string, opts domain.MetricsOptions) (*domain.MatchMetrics, error) {
+	if matchID == "" {
+		return nil, fmt.Errorf("matchID cannot be empty")
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if final, ok := s.final[matchID]; ok {
+		return copyMetrics(final), nil
+	}
+	return s.liveMatchMetrics(matchID, opts)
+}
+
+// liveMatchMetrics aggregates a match's metrics from its stored events. The
+// caller must hold s.mu.
+func (s *Store) liveMatchMetrics(matchID string, opts domain.MetricsOptions) (*domain.MatchMetrics, error) {
+	events := s.validEvents(matchID)
+	if len(events) == 0 {
+		return nil, fmt.Errorf("match %s: %w", matchID, domain.ErrMatchNotFound)
+	}
+
+	metrics := domain.NewMatchMetrics(matchID)
+	players := make(map[string]bool)
+	var goals []time.Time
+	for _, event := range events {
+		metrics.TotalEvents++
+		metrics.EventsByType[string(event.EventType)]++
+		switch event.EventType {
+		case domain.EventTypeGoal:
+			metrics.Goals++
+			goals = append(goals, event.Timestamp)
+		case domain.EventTypeYellowCard:
+			metrics.YellowCards++
+		case domain.EventTypeRedCard:
+			metrics.RedCards++
+		}
+		if event.PlayerID != "" {
+			players[event.PlayerID] = true
+		}
+	}
+	metrics.DistinctPlayers = int64(len(players))
+
+	first, last := events[0].Timestamp, events[len(events)-1].Timestamp
+	metrics.FirstEventAt = &first
+	metrics.LastEventAt = &last
+	metrics.AvgEventsPerMinute = domain.AvgEventsPerMinute(metrics.TotalEvents, first, last)
+
+	if !opts.SkipPeak {
+		metrics.PeakMinute = s.peakEngagement(events)
+	}
+	metrics.ByPhase = s.phaseMetrics(events, first, last)
+	if len(goals) >= 2 {
+		metrics.LongestScorelessStreakSeconds = domain.LongestScorelessStreakSeconds(goals)
+	}
+	metrics.ApplyMatchInfo(s.info[matchID])
+
+	return metrics, nil
+}
+
+// peakEngagement returns the minute with the highest weighted engagement
+// score, the earliest one on ties.
+func (s *Store) peakEngagement(events []*domain.Event) *domain.PeakEngagement {
+	var peak *domain.PeakEngagement
+	for _, minute := range groupByMinute(events) {
+		var score float64
+		for _, event := range minute.events {
+			score += s.config.EngagementWeights.Weight(event.EventType)
+		}
+		if peak == nil || score > peak.Score {
+			peak = &domain.PeakEngagement{
+				Minute:     minute.start,
+				EventCount: int64(len(minute.events)),
+				Score:      score,
+			}
+		}
+	}
+	return peak
+}
+
+// phaseMetrics totals events per phase, with kickoff taken as the first event.
+// Every phase reached by the last event is present, with zero totals if it
+// has no events.
+func (s *Store) phaseMetrics(events []*domain.Event, kickoff, last time.Time) map[string]domain.PhaseMetrics {
+	byPhase := make(map[string]domain.PhaseMetrics)
+	for _, phase := range s.config.Phases.Reached(last.Sub(kickoff)) {
+		byPhase[phase.Name] = domain.PhaseMetrics{}
+	}
+	for _, event := range events {
+		name := s.config.Phases.PhaseAt(event.Timestamp.Sub(kickoff))
+		phase := byPhase[name]
+		phase.TotalEvents++
+		switch event.EventType {
+		case domain.EventTypeGoal:
+			phase.Goals++
+		case domain.EventTypeYellowCard:
+			phase.YellowCards++
+		case domain.EventTypeRedCard:
+			phase.RedCards++
+		}
+		byPhase[name] = phase
+	}
+	return byPhase
+}
+
+// minuteEvents is the events of one minute of a match.
+type minuteEvents struct {
+	start  time.Time
+	events []*domain.Event
+}
+
+// groupByMinute splits events sorted by timestamp into the minutes they fall
+// in, like ClickHouse's toStartOfMinute.
+func groupByMinute(events []*domain.Event) []minuteEvents {
+	var minutes []minuteEvents
+	for _, event := range events {
+		start := event.Timestamp.Truncate(time.Minute)
+		if len(minutes) == 0 || !minutes[len(minutes)-1].start.Equal(start) {
+			minutes = append(minutes, minuteEvents{start: start})
+		}
+		current := &minutes[len(minutes)-1]
+		current.events = append(current.events, event)
+	}
+	return minutes
+}
+
+// GetEventsPerMinute returns a match's event counts per minute and type,
+// ordered by minute then type. Corrected events are excluded.
+func (s *Store) GetEventsPerMinute(ctx context.Context, matchID string) ([]domain.EventsPerMinute, error) {
+	if matchID == "" {
+		return nil, fmt.Errorf("matchID cannot be empty")
+	}
+	return s.eventsPerMinute(matchID, func(*domain.Event) bool { return true }), nil
+}
+
+// GetTeamEventsPerMinute returns GetEventsPerMinute for one team of a match.
+func (s *Store) GetTeamEventsPerMinute(ctx context.Context, matchID string, teamID int) ([]domain.EventsPerMinute, error) {
+	if matchID == "" {
+		return nil, fmt.Errorf("matchID cannot be empty")
+	}
+	if teamID < 1 {
+		return nil, fmt.Errorf("teamID must be positive, got %d", teamID)
+	}
+	return s.eventsPerMinute(matchID, func(event *domain.Event) bool { return event.TeamID == teamID }), nil
+}
+
+// eventsPerMinute counts the match's valid events accepted by keep per minute and type.
+func (s *Store) eventsPerMinute(matchID string, keep func(*domain.Event) bool) []domain.EventsPerMinute {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var results []domain.EventsPerMinute
+	for _, minute := range groupByMinute(s.validEvents(matchID)) {
+		counts := make(map[string]int64)
+		for _, event := range minute.events {
+			if keep(event) {
+				counts[string(event.EventType)]++
+			}
+		}
+		types := make([]string, 0, len(counts))
+		for eventType := range counts {
+			types = append(types, eventType)
+		}
+		sort.Strings(types)
+		for _, eventType := range types {
+			results = append(results, domain.EventsPerMinute{
+				Minute:     minute.start,
+				EventType:  eventType,
+				EventCount: counts[eventType],
+			})
+		}
+	}
+	return results
+}
+
+// GetEventsPerSecond returns a match's event counts per second for events
+// timestamped at or after since. Seconds without events are omitted.
+// Corrected events are excluded.
+func (s *Store) GetEventsPerSecond(ctx context.Context, matchID string, since time.Time) ([]domain.EventsPerSecond, error) {
+	if matchID == "" {
+		return nil, fmt.Errorf("matchID cannot be empty")
+	}
+	if since.IsZero() {
+		return nil, fmt.Errorf("since cannot be zero")
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var results []domain.EventsPerSecond
+	for _, event := range s.validEvents(matchID) {
+		if event.Timestamp.Before(since) {
+			continue
+		}
+		second := event.Timestamp.Truncate(time.Second)
+		if n := len(results); n > 0 && results[n-1].Second.Equal(second) {
+			results[n-1].EventCount++
+			continue
+		}
+		results = append(results, domain.EventsPerSecond{Second: second, EventCount: 1})
+	}
+	return results, nil
+}
+
+// GetEventMatrix returns a dense minute-by-event-type count matrix for a match.
+// Returns domain.ErrMatchNotFound if the match has no events.
+func (s *Store) GetEventMatrix(ctx context.Context, matchID string) (domain.EventMatrix, error) {
+	perMinute, err := s.GetEventsPerMinute(ctx, matchID)
+	if err != nil {
+		return domain.EventMatrix{}, err
+	}
+	if len(perMinute) == 0 {
+		return domain.EventMatrix{}, fmt.Errorf("match %s: %w", matchID, domain.ErrMatchNotFound)
+	}
+	return domain.NewEventMatrix(matchID, perMinute), nil
+}
+
+// GetConversionStats counts a match's shots, shots whose metadata has
+// on_target set to true, and goals. Returns domain.ErrMatchNotFound if the
+// match has no events.
+func (s *Store) GetConversionStats(ctx context.Context, matchID string) (domain.ConversionStats, error) {
+	if matchID == "" {
+		return domain.ConversionStats{}, fmt.Errorf("matchID cannot be empty")
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	events := s.validEvents(matchID)
+	if len(events) == 0 {
+		return domain.ConversionStats{}, fmt.Errorf("match %s: %w", matchID, domain.ErrMatchNotFound)
+	}
+
+	var shots, shotsOnTarget, goals int64
+	for _, event := range events {
+		switch event.EventType {
+		case domain.EventTypeShot:
+			shots++
+			if onTarget, _ := event.Metadata["on_target"].(bool); onTarget {
+				shotsOnTarget++
+			}
+		case domain.EventTypeGoal:
+			goals++
+		}
+	}
+	return domain.NewConversionStats(matchID, shots, shotsOnTarget, goals), nil
+}
+
+// GetLeaderboard ranks players by their events of eventType timestamped in
+// [from, to), across all matches, returning at most limit players with the
+// highest counts first. Ties are ordered by player ID. Corrected events and
+// events without a player are excluded.
+func (s *Store) GetLeaderboard(ctx context.Context, eventType string, from, to time.Time, limit int) ([]domain.PlayerStat, error) {
+	if err := domain.ValidateLeaderboardFetch(eventType, from, to, limit); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	byPlayer := make(map[string]*domain.PlayerStat)
+	for matchID := range s.events {
+		counted := make(map[string]bool)
+		for _, event := range s.validEvents(matchID) {
+			if string(event.EventType) != eventType || event.PlayerID == "" ||
+				event.Timestamp.Before(from) || !event.Timestamp.Before(to) {
+				continue
+			}
+			stat, ok := byPlayer[event.PlayerID]
+			if !ok {
+				stat = &domain.PlayerStat{PlayerID: event.PlayerID}
+				byPlayer[event.PlayerID] = stat
+			}
+			stat.Count++
+			if !counted[event.PlayerID] {
+				counted[event.PlayerID] = true
+				stat.Matches++
+			}
+		}
+	}
+
+	stats := make([]domain.PlayerStat, 0, len(byPlayer))
+	for _, stat := range byPlayer {
+		stats = append(stats, *stat)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Count != stats[j].Count {
+			return stats[i].Count > stats[j].Count
+		}
+		return stats[i].PlayerID < stats[j].PlayerID
+	})
+	if len(stats) > limit {
+		stats = stats[:limit]
+	}
+	return stats, nil
+}
+
+// StreamEvents invokes fn for every stored event of a match, corrections
+// included, in timestamp order. Iteration stops at the first error returned by fn.
+func (s *Store) StreamEvents(ctx context.Context, matchID string, fn func(*domain.Event) error) error {
+	if matchID == "" {
+		return fmt.Errorf("matchID cannot be empty")
+	}
+
+	// Copy the events so fn may call back into the store
+	s.mu.RLock()
+	events := make([]*domain.Event, len(s.events[matchID]))
+	copy(events, s.events[matchID])
+	s.mu.RUnlock()
+	sortByTimestamp(events)
+
+	for _, event := range events {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		stored := *event
+		if err := fn(&stored); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SearchEvents returns a match's events whose metadata matches every filter,
+// in timestamp order. At most domain.MaxSearchResults+1 events are returned so
+// callers can tell when results were cut off. A filter matches a
+// string value exactly, or the JSON text of any other value (e.g. "45",
+// "true"). Corrected events are excluded.
+func (s *Store) SearchEvents(ctx context.Context, matchID string, metadataFilters map[string]string) ([]*domain.Event, error) {
+	if matchID == "" {
+		return nil, fmt.Errorf("matchID cannot be empty")
+	}
+	if err := domain.ValidateMetadataFilters(metadataFilters); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	events := []*domain.Event{}
+	for _, event := range s.validEvents(matchID) {
+		if len(events) > domain.MaxSearchResults {
+			break
+		}
+		if matchesMetadata(event.Metadata, metadataFilters) {
+			stored := *event
+			events = append(events, &stored)
+		}
+	}
+	return events, nil
+}
+
+// matchesMetadata reports whether metadata matches every filter.
+func matchesMetadata(metadata map[string]interface{}, filters map[string]string) bool {
+	for key, want := range filters {
+		value, ok := metadata[key]
+		if !ok {
+			return false
+		}
+		if str, isString := value.(string); isString {
+			if str != want {
+				return false
+			}
+			continue
+		}
+		raw, err := json.Marshal(value)
+		if err != nil || string(raw) != want {
+			return false
+		}
+	}
+	return true
+}
+
+// MatchExists reports whether the match has any events its metrics count, so
+// a match whose events were all retracted by corrections does not exist.
+func (s *Store) MatchExists(ctx context.Context, matchID string) (bool, error) {
+	if matchID == "" {
+		return false, fmt.Errorf("matchID cannot be empty")
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.validEvents(matchID)) > 0, nil
+}
+
+// CountEvents returns the number of events stored for a match, including
+// corrections and the events they correct.
+func (s *Store) CountEvents(ctx context.Context, matchID string) (int64, error) {
+	if matchID == "" {
+		return 0, fmt.Errorf("matchID cannot be empty")
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return int64(len(s.events[matchID])), nil
+}
+
+// UpsertMatchInfo stores the details registered for a match, replacing any
+// registered before.
+func (s *Store) UpsertMatchInfo(ctx context.Context, info *domain.MatchInfo) error {
+	if info == nil || info.MatchID == "" {
+		return fmt.Errorf("matchID cannot be empty")
+	}
+
+	stored := *info
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.info[info.MatchID] = &stored
+	return nil
+}
+
+// CloseMatch computes the match's metrics and keeps them as its final
+// snapshot, which GetMatchMetrics returns from then on. Closing a closed
+// match returns the existing snapshot with ErrMatchClosed.
+func (s *Store) CloseMatch(ctx context.Context, matchID string) (*domain.MatchMetrics, error) {
+	if matchID == "" {
+		return nil, fmt.Errorf("matchID cannot be empty")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if existing, ok := s.final[matchID]; ok {
+		return copyMetrics(existing), fmt.Errorf("match %s: %w", matchID, domain.ErrMatchClosed)
+	}
+
+	metrics, err := s.liveMatchMetrics(matchID, domain.MetricsOptions{})
+	if err != nil {
+		return nil, err
+	}
+	closedAt := s.config.Clock.Now().UTC()
+	metrics.ClosedAt = &closedAt
+	metrics.ResponseTimePercentiles = nil
+
+	s.final[matchID] = metrics
+	return copyMetrics(metrics), nil
+}
+
+// copyMetrics returns a copy of a snapshot whose maps the caller may modify.
+func copyMetrics(m *domain.MatchMetrics) *domain.MatchMetrics {
+	c := *m
+	c.EventsByType = make(map[string]int64, len(m.EventsByType))
+	for eventType, count := range m.EventsByType {
+		c.EventsByType[eventType] = count
+	}
+	if m.ByPhase != nil {
+		c.ByPhase = make(map[string]domain.PhaseMetrics, len(m.ByPhase))
+		for name, phase := range m.ByPhase {
+			c.ByPhase[name] = phase
+		}
+	}
+	return &c
+}
+
+// Ping always succeeds; the store has no connection to lose.
+func (s *Store) Ping(ctx context.Context) error {
+	return nil
+}
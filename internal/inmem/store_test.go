@@ -0,0 +1,357 @@
+package inmem_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"fanfinity/internal/domain"
+	"fanfinity/internal/inmem"
+)
+
+var kickoff = time.Date(2024, 5, 1, 18, 0, 0, 0, time.UTC)
+
+func newEvent(eventType domain.EventType, offset time.Duration, playerID string, metadata map[string]interface{}) *domain.Event {
+	return &domain.Event{
+		EventID:   uuid.New(),
+		MatchID:   "match-1",
+		EventType: eventType,
+		TeamID:    1,
+		PlayerID:  playerID,
+		Metadata:  metadata,
+		Timestamp: kickoff.Add(offset),
+	}
+}
+
+// produceMatch stores a match spanning both halves, with one goal retracted
+// by a correction, and returns the store.
+func produceMatch(t *testing.T, cfg inmem.Config) *inmem.Store {
+	t.Helper()
+	store, err := inmem.NewStoreWithConfig(cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	retracted := newEvent(domain.EventTypeGoal, 30*time.Minute, "p9", nil)
+	events := []*domain.Event{
+		newEvent(domain.EventTypePass, 0, "p1", nil),
+		newEvent(domain.EventTypePass, 10*time.Second, "p2", nil),
+		newEvent(domain.EventTypePass, 20*time.Second, "p1", nil),
+		newEvent(domain.EventTypeShot, 10*time.Minute, "p3", map[string]interface{}{"on_target": true}),
+		newEvent(domain.EventTypeGoal, 10*time.Minute+5*time.Second, "p3", nil),
+		newEvent(domain.EventTypeYellowCard, 20*time.Minute, "p4", nil),
+		retracted,
+		newEvent(domain.EventTypeShot, 50*time.Minute, "p5", map[string]interface{}{"on_target": false}),
+		newEvent(domain.EventTypeGoal, 70*time.Minute, "p5", nil),
+		newEvent(domain.EventTypeRedCard, 80*time.Minute, "", nil),
+		newEvent(domain.EventTypeCorrection, 31*time.Minute, "", map[string]interface{}{
+			"correctsEventId": retracted.EventID.String(),
+			"action":          "delete",
+		}),
+	}
+	if err := store.ProduceBatch(context.Background(), events); err != nil {
+		t.Fatalf("unexpected produce error: %v", err)
+	}
+	return store
+}
+
+func TestStore_GetMatchMetrics(t *testing.T) {
+	weights := domain.DefaultEngagementWeights()
+	weights[domain.EventTypeGoal] = 10
+	store := produceMatch(t, inmem.Config{EngagementWeights: weights})
+
+	metrics, err := store.GetMatchMetrics(context.Background(), "match-1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if metrics.TotalEvents != 9 {
+		t.Errorf("expected 9 events, got %d", metrics.TotalEvents)
+	}
+	wantByType := map[string]int64{"pass": 3, "shot": 2, "goal": 2, "yellow_card": 1, "red_card": 1}
+	for eventType, want := range wantByType {
+		if got := metrics.EventsByType[eventType]; got != want {
+			t.Errorf("expected %d %s events, got %d", want, eventType, got)
+		}
+	}
+	if len(metrics.EventsByType) != len(wantByType) {
+		t.Errorf("expected event types %v, got %v", wantByType, metrics.EventsByType)
+	}
+	if metrics.Goals != 2 || metrics.YellowCards != 1 || metrics.RedCards != 1 {
+		t.Errorf("expected 2 goals, 1 yellow and 1 red card, got %d, %d and %d", metrics.Goals, metrics.YellowCards, metrics.RedCards)
+	}
+	if metrics.DistinctPlayers != 5 {
+		t.Errorf("expected 5 distinct players, got %d", metrics.DistinctPlayers)
+	}
+	if metrics.FirstEventAt == nil || !metrics.FirstEventAt.Equal(kickoff) {
+		t.Errorf("expected first event at %v, got %v", kickoff, metrics.FirstEventAt)
+	}
+	if last := kickoff.Add(80 * time.Minute); metrics.LastEventAt == nil || !metrics.LastEventAt.Equal(last) {
+		t.Errorf("expected last event at %v, got %v", last, metrics.LastEventAt)
+	}
+	if want := 9.0 / 80; metrics.AvgEventsPerMinute != want {
+		t.Errorf("expected %v events per minute, got %v", want, metrics.AvgEventsPerMinute)
+	}
+
+	// The goal's weight puts the peak in its minute rather than the opening passes'
+	wantPeak := domain.PeakEngagement{Minute: kickoff.Add(10 * time.Minute), EventCount: 2, Score: 11}
+	if metrics.PeakMinute == nil || *metrics.PeakMinute != wantPeak {
+		t.Errorf("expected peak minute %+v, got %+v", wantPeak, metrics.PeakMinute)
+	}
+
+	wantPhases := map[string]domain.PhaseMetrics{
+		"first_half":  {TotalEvents: 6, Goals: 1, YellowCards: 1},
+		"second_half": {TotalEvents: 3, Goals: 1, RedCards: 1},
+	}
+	if len(metrics.ByPhase) != len(wantPhases) {
+		t.Errorf("expected phases %v, got %v", wantPhases, metrics.ByPhase)
+	}
+	for name, want := range wantPhases {
+		if got := metrics.ByPhase[name]; got != want {
+			t.Errorf("expected %s totals %+v, got %+v", name, want, got)
+		}
+	}
+
+	if want := int64((60*time.Minute - 5*time.Second) / time.Second); metrics.LongestScorelessStreakSeconds != want {
+		t.Errorf("expected longest scoreless streak %ds, got %ds", want, metrics.LongestScorelessStreakSeconds)
+	}
+}
+
+func TestStore_GetMatchMetrics_PeakTieTakesEarliestMinute(t *testing.T) {
+	store := inmem.NewStore()
+	events := []*domain.Event{
+		newEvent(domain.EventTypePass, 5*time.Minute, "", nil),
+		newEvent(domain.EventTypePass, 2*time.Minute, "", nil),
+	}
+	if err := store.ProduceBatch(context.Background(), events); err != nil {
+		t.Fatalf("unexpected produce error: %v", err)
+	}
+
+	metrics, err := store.GetMatchMetrics(context.Background(), "match-1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if metrics.PeakMinute == nil || !metrics.PeakMinute.Minute.Equal(kickoff.Add(2*time.Minute)) {
+		t.Errorf("expected the earliest minute to win a tie, got %+v", metrics.PeakMinute)
+	}
+
+	metrics, err = store.GetMatchMetricsWithOptions(context.Background(), "match-1", domain.MetricsOptions{SkipPeak: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if metrics.PeakMinute != nil {
+		t.Errorf("expected no peak minute with SkipPeak, got %+v", metrics.PeakMinute)
+	}
+}
+
+func TestStore_GetMatchMetrics_NotFound(t *testing.T) {
+	store := inmem.NewStore()
+
+	_, err := store.GetMatchMetrics(context.Background(), "match-unknown")
+	if !errors.Is(err, domain.ErrMatchNotFound) {
+		t.Errorf("expected ErrMatchNotFound, got %v", err)
+	}
+	if _, err := store.GetConversionStats(context.Background(), "match-unknown"); !errors.Is(err, domain.ErrMatchNotFound) {
+		t.Errorf("expected ErrMatchNotFound for conversion stats, got %v", err)
+	}
+}
+
+func TestStore_MatchExists_IgnoresRetractedEvents(t *testing.T) {
+	store := inmem.NewStore()
+	ctx := context.Background()
+
+	goal := newEvent(domain.EventTypeGoal, 0, "p9", nil)
+	if err := store.Produce(ctx, goal); err != nil {
+		t.Fatalf("unexpected produce error: %v", err)
+	}
+	if exists, err := store.MatchExists(ctx, "match-1"); err != nil || !exists {
+		t.Fatalf("expected the match to exist, got %v, %v", exists, err)
+	}
+
+	// Once its only event is retracted the match has nothing to report
+	correction := newEvent(domain.EventTypeCorrection, time.Minute, "", map[string]interface{}{
+		"correctsEventId": goal.EventID.String(),
+		"action":          "delete",
+	})
+	if err := store.Produce(ctx, correction); err != nil {
+		t.Fatalf("unexpected produce error: %v", err)
+	}
+	if exists, err := store.MatchExists(ctx, "match-1"); err != nil || exists {
+		t.Errorf("expected a match with only retracted events not to exist, got %v, %v", exists, err)
+	}
+}
+
+func TestStore_GetEventsPerMinute(t *testing.T) {
+	store := produceMatch(t, inmem.DefaultConfig())
+
+	perMinute, err := store.GetEventsPerMinute(context.Background(), "match-1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []domain.EventsPerMinute{
+		{Minute: kickoff, EventType: "pass", EventCount: 3},
+		{Minute: kickoff.Add(10 * time.Minute), EventType: "goal", EventCount: 1},
+		{Minute: kickoff.Add(10 * time.Minute), EventType: "shot", EventCount: 1},
+		{Minute: kickoff.Add(20 * time.Minute), EventType: "yellow_card", EventCount: 1},
+		{Minute: kickoff.Add(50 * time.Minute), EventType: "shot", EventCount: 1},
+		{Minute: kickoff.Add(70 * time.Minute), EventType: "goal", EventCount: 1},
+		{Minute: kickoff.Add(80 * time.Minute), EventType: "red_card", EventCount: 1},
+	}
+	if len(perMinute) != len(want) {
+		t.Fatalf("expected %d rows, got %d: %+v", len(want), len(perMinute), perMinute)
+	}
+	for i := range want {
+		if !perMinute[i].Minute.Equal(want[i].Minute) || perMinute[i].EventType != want[i].EventType || perMinute[i].EventCount != want[i].EventCount {
+			t.Errorf("row %d: expected %+v, got %+v", i, want[i], perMinute[i])
+		}
+	}
+
+	perSecond, err := store.GetEventsPerSecond(context.Background(), "match-1", kickoff)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(perSecond) != 9 || perSecond[0].EventCount != 1 {
+		t.Errorf("expected 9 seconds with one event each, got %+v", perSecond)
+	}
+}
+
+func TestStore_GetConversionStats(t *testing.T) {
+	store := produceMatch(t, inmem.DefaultConfig())
+
+	stats, err := store.GetConversionStats(context.Background(), "match-1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := domain.NewConversionStats("match-1", 2, 1, 2)
+	if stats != want {
+		t.Errorf("expected %+v, got %+v", want, stats)
+	}
+}
+
+func TestStore_SearchEvents(t *testing.T) {
+	store := produceMatch(t, inmem.DefaultConfig())
+
+	events, err := store.SearchEvents(context.Background(), "match-1", map[string]string{"on_target": "true"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(events) != 1 || events[0].PlayerID != "p3" {
+		t.Errorf("expected the on-target shot, got %+v", events)
+	}
+
+	if _, err := store.SearchEvents(context.Background(), "match-1", map[string]string{"bad key": "x"}); err == nil {
+		t.Error("expected an invalid filter key to be rejected")
+	}
+}
+
+func TestStore_ProduceReplacesByEventID(t *testing.T) {
+	store := inmem.NewStore()
+	ctx := context.Background()
+
+	pass := newEvent(domain.EventTypePass, time.Minute, "p1", nil)
+	goal := newEvent(domain.EventTypeGoal, 10*time.Minute, "p2", nil)
+	if err := store.ProduceBatch(ctx, []*domain.Event{pass, goal}); err != nil {
+		t.Fatalf("unexpected produce error: %v", err)
+	}
+
+	// A redelivery and an upsert both replace the stored event
+	if err := store.Produce(ctx, pass); err != nil {
+		t.Fatalf("unexpected produce error: %v", err)
+	}
+	upserted := *goal
+	upserted.PlayerID = "p3"
+	upserted.Op = domain.EventOpUpsert
+	if err := store.Produce(ctx, &upserted); err != nil {
+		t.Fatalf("unexpected produce error: %v", err)
+	}
+
+	if count, _ := store.CountEvents(ctx, "match-1"); count != 2 {
+		t.Errorf("expected 2 stored events, got %d", count)
+	}
+	var players []string
+	if err := store.StreamEvents(ctx, "match-1", func(event *domain.Event) error {
+		players = append(players, event.PlayerID)
+		return nil
+	}); err != nil {
+		t.Fatalf("unexpected stream error: %v", err)
+	}
+	if len(players) != 2 || players[0] != "p1" || players[1] != "p3" {
+		t.Errorf("expected the upserted goal to replace the original, got players %v", players)
+	}
+
+	// An event moved to another match leaves the first
+	moved := *pass
+	moved.MatchID = "match-2"
+	if err := store.Produce(ctx, &moved); err != nil {
+		t.Fatalf("unexpected produce error: %v", err)
+	}
+	if count, _ := store.CountEvents(ctx, "match-1"); count != 1 {
+		t.Errorf("expected 1 event left in match-1, got %d", count)
+	}
+	if count, _ := store.CountEvents(ctx, "match-2"); count != 1 {
+		t.Errorf("expected the moved event in match-2, got %d", count)
+	}
+}
+
+func TestStore_CloseMatch(t *testing.T) {
+	store := produceMatch(t, inmem.DefaultConfig())
+	ctx := context.Background()
+
+	closed, err := store.CloseMatch(ctx, "match-1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if closed.ClosedAt == nil {
+		t.Error("expected ClosedAt to be set")
+	}
+
+	// Events stored after closing do not change the official metrics
+	if err := store.Produce(ctx, newEvent(domain.EventTypeGoal, 85*time.Minute, "p6", nil)); err != nil {
+		t.Fatalf("unexpected produce error: %v", err)
+	}
+	metrics, err := store.GetMatchMetrics(ctx, "match-1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if metrics.TotalEvents != closed.TotalEvents || metrics.ClosedAt == nil {
+		t.Errorf("expected the final snapshot, got %d events closed at %v", metrics.TotalEvents, metrics.ClosedAt)
+	}
+	if count, _ := store.CountEvents(ctx, "match-1"); count != 12 {
+		t.Errorf("expected 12 stored events, got %d", count)
+	}
+
+	if _, err := store.CloseMatch(ctx, "match-1"); !errors.Is(err, domain.ErrMatchClosed) {
+		t.Errorf("expected ErrMatchClosed, got %v", err)
+	}
+}
+
+func TestStore_GetLeaderboard(t *testing.T) {
+	store := produceMatch(t, inmem.DefaultConfig())
+	ctx := context.Background()
+
+	// The retracted goal does not count, and the tie is broken by player ID
+	players, err := store.GetLeaderboard(ctx, "goal", kickoff, kickoff.Add(2*time.Hour), 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []domain.PlayerStat{{PlayerID: "p3", Count: 1, Matches: 1}}
+	if len(players) != 1 || players[0] != want[0] {
+		t.Errorf("expected %+v, got %+v", want, players)
+	}
+
+	// Only the goal after the first hour is in range
+	players, err = store.GetLeaderboard(ctx, "goal", kickoff.Add(time.Hour), kickoff.Add(2*time.Hour), 10)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(players) != 1 || players[0].PlayerID != "p5" {
+		t.Errorf("expected only p5's goal in the second hour, got %+v", players)
+	}
+
+	if _, err := store.GetLeaderboard(ctx, "goal", kickoff, kickoff.Add(time.Hour), domain.MaxLeaderboardLimit+2); err == nil {
+		t.Error("expected a limit past the lookahead player to be rejected")
+	}
+}
@@ -0,0 +1,279 @@
+package kafka
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"fanfinity/internal/metrics"
+)
+
+var matchRateAnomalies *prometheus.CounterVec
+
+// registerAnomalyMetrics creates the rate anomaly metrics under opts.
+func registerAnomalyMetrics(f promauto.Factory, opts metrics.Options) {
+	matchRateAnomalies = f.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace:   opts.NamespaceOrDefault(),
+			Name:        "match_rate_anomaly_total",
+			Help:        "Total number of windows in which a match's event rate deviated from its recent baseline, by direction (spike, drop)",
+			ConstLabels: opts.ConstLabels,
+		},
+		[]string{"direction"},
+	)
+}
+
+// Directions of a match rate anomaly.
+const (
+	RateAnomalySpike = "spike"
+	RateAnomalyDrop  = "drop"
+)
+
+// Defaults for the rate anomaly detector.
+const (
+	DefaultRateAnomalyWindow    = time.Minute
+	DefaultRateAnomalyBaseline  = 10
+	DefaultRateAnomalySigma     = 3.0
+	DefaultRateAnomalyMinChange = 0.5
+)
+
+// RateAnomalyConfig holds rate anomaly detector settings.
+type RateAnomalyConfig struct {
+	// Window is the period each match's events are counted over.
+	Window time.Duration
+
+	// Baseline is the number of preceding windows a window is compared with.
+	// A match is only judged once it has a full baseline, and is forgotten
+	// after Baseline windows without events.
+	Baseline int
+
+	// Sigma is how many standard deviations from the baseline mean a window's
+	// count must be to be anomalous.
+	Sigma float64
+
+	// MinChange is the smallest deviation, as a fraction of the baseline mean,
+	// that is anomalous. It keeps a perfectly steady baseline, whose standard
+	// deviation is zero, from flagging every small wobble.
+	MinChange float64
+
+	// MaxMatches bounds the matches tracked; the least recently seen is
+	// evicted to make room for a new one.
+	MaxMatches int
+}
+
+// DefaultRateAnomalyConfig returns the default rate anomaly detector configuration.
+func DefaultRateAnomalyConfig() RateAnomalyConfig {
+	return RateAnomalyConfig{
+		Window:     DefaultRateAnomalyWindow,
+		Baseline:   DefaultRateAnomalyBaseline,
+		Sigma:      DefaultRateAnomalySigma,
+		MinChange:  DefaultRateAnomalyMinChange,
+		MaxMatches: DefaultMaxTrackedMatches,
+	}
+}
+
+// matchRate is the rolling event rate of one match.
+type matchRate struct {
+	windowStart time.Time
+	count       int
+	history     []int // counts of the completed windows, oldest first
+	lastSeen    time.Time
+}
+
+// RateAnomalyDetector flags matches whose event rate suddenly spikes or
+// flatlines, which usually means a misbehaving producer or an outage
+// upstream. Events are counted per match in fixed windows of the time they
+// were produced, so a backlog consumed in a burst is not mistaken for a
+// spike; when a window closes its count is compared with the match's
+// preceding windows and counted in the match_rate_anomaly_total metric if it
+// deviates too far. Run closes windows that pass without events, so a match
+// that stops sending is reported as a drop, but not while the consumer is
+// behind, since the events of those windows may not have been read yet.
+type RateAnomalyDetector struct {
+	logger *slog.Logger
+	config RateAnomalyConfig
+
+	mu      sync.Mutex
+	matches map[string]*matchRate
+
+	// newest is the latest produce time observed, and observedAt when it
+	// was consumed.
+	newest     time.Time
+	observedAt time.Time
+}
+
+// NewRateAnomalyDetector creates a RateAnomalyDetector with the default configuration.
+func NewRateAnomalyDetector(logger *slog.Logger) *RateAnomalyDetector {
+	return NewRateAnomalyDetectorWithConfig(logger, DefaultRateAnomalyConfig())
+}
+
+// NewRateAnomalyDetectorWithConfig creates a RateAnomalyDetector. Zero config
+// values use the defaults.
+func NewRateAnomalyDetectorWithConfig(logger *slog.Logger, cfg RateAnomalyConfig) *RateAnomalyDetector {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	if cfg.Window <= 0 {
+		cfg.Window = DefaultRateAnomalyWindow
+	}
+	if cfg.Baseline <= 0 {
+		cfg.Baseline = DefaultRateAnomalyBaseline
+	}
+	if cfg.Sigma <= 0 {
+		cfg.Sigma = DefaultRateAnomalySigma
+	}
+	if cfg.MinChange <= 0 {
+		cfg.MinChange = DefaultRateAnomalyMinChange
+	}
+	if cfg.MaxMatches <= 0 {
+		cfg.MaxMatches = DefaultMaxTrackedMatches
+	}
+	return &RateAnomalyDetector{
+		logger:  logger,
+		config:  cfg,
+		matches: make(map[string]*matchRate),
+	}
+}
+
+// Observe counts an event of matchID produced at at and consumed at now.
+func (d *RateAnomalyDetector) Observe(matchID string, at, now time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if at.After(d.newest) {
+		d.newest = at
+	}
+	d.observedAt = now
+
+	rate, ok := d.matches[matchID]
+	if ok && at.Sub(rate.lastSeen) >= d.idleTimeout() {
+		// Back after a long silence: start a fresh baseline
+		ok = false
+	}
+	if !ok {
+		if _, tracked := d.matches[matchID]; !tracked && len(d.matches) >= d.config.MaxMatches {
+			d.evictLeastRecentLocked()
+		}
+		rate = &matchRate{windowStart: at}
+		d.matches[matchID] = rate
+	}
+
+	d.rollLocked(matchID, rate, at)
+	rate.count++
+	rate.lastSeen = at
+}
+
+// Evaluate closes every window that ended by now, judging each, and forgets
+// matches idle for Baseline windows. It does nothing while the consumer is
+// lagging: events are still arriving but were produced a Window or more ago.
+func (d *RateAnomalyDetector) Evaluate(now time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if now.Sub(d.observedAt) < d.config.Window && now.Sub(d.newest) >= d.config.Window {
+		return
+	}
+
+	for matchID, rate := range d.matches {
+		if now.Sub(rate.lastSeen) >= d.idleTimeout() {
+			delete(d.matches, matchID)
+			continue
+		}
+		d.rollLocked(matchID, rate, now)
+	}
+}
+
+// Run evaluates the tracked matches every Window until ctx is cancelled.
+func (d *RateAnomalyDetector) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.config.Window)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			d.Evaluate(now)
+		}
+	}
+}
+
+// Tracked returns the number of matches currently tracked.
+func (d *RateAnomalyDetector) Tracked() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return len(d.matches)
+}
+
+// idleTimeout is how long a match may go without events before it is forgotten.
+func (d *RateAnomalyDetector) idleTimeout() time.Duration {
+	return time.Duration(d.config.Baseline) * d.config.Window
+}
+
+// rollLocked closes the match's windows that ended by now. The caller must hold d.mu.
+func (d *RateAnomalyDetector) rollLocked(matchID string, rate *matchRate, now time.Time) {
+	for now.Sub(rate.windowStart) >= d.config.Window {
+		d.closeWindowLocked(matchID, rate)
+		rate.windowStart = rate.windowStart.Add(d.config.Window)
+		rate.count = 0
+	}
+}
+
+// closeWindowLocked judges the match's current window against its baseline
+// and adds it to the baseline. The caller must hold d.mu.
+func (d *RateAnomalyDetector) closeWindowLocked(matchID string, rate *matchRate) {
+	if len(rate.history) == d.config.Baseline {
+		mean, stddev := meanStddev(rate.history)
+		deviation := float64(rate.count) - mean
+		if mean > 0 && math.Abs(deviation) > d.config.Sigma*stddev && math.Abs(deviation) >= d.config.MinChange*mean {
+			direction := RateAnomalySpike
+			if deviation < 0 {
+				direction = RateAnomalyDrop
+			}
+			matchRateAnomalies.WithLabelValues(direction).Inc()
+			d.logger.Warn("match event rate anomaly",
+				slog.String("match_id", matchID),
+				slog.String("direction", direction),
+				slog.Int("events", rate.count),
+				slog.Float64("baseline_mean", mean),
+				slog.Float64("baseline_stddev", stddev),
+				slog.Duration("window", d.config.Window),
+			)
+		}
+		rate.history = rate.history[1:]
+	}
+	rate.history = append(rate.history, rate.count)
+}
+
+// evictLeastRecentLocked forgets the match seen least recently. The caller must hold d.mu.
+func (d *RateAnomalyDetector) evictLeastRecentLocked() {
+	var oldestID string
+	var oldest time.Time
+	for matchID, rate := range d.matches {
+		if oldestID == "" || rate.lastSeen.Before(oldest) {
+			oldestID, oldest = matchID, rate.lastSeen
+		}
+	}
+	delete(d.matches, oldestID)
+}
+
+// meanStddev returns the mean and population standard deviation of counts.
+func meanStddev(counts []int) (float64, float64) {
+	var sum float64
+	for _, count := range counts {
+		sum += float64(count)
+	}
+	mean := sum / float64(len(counts))
+
+	var squares float64
+	for _, count := range counts {
+		diff := float64(count) - mean
+		squares += diff * diff
+	}
+	return mean, math.Sqrt(squares / float64(len(counts)))
+}
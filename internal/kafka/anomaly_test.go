@@ -0,0 +1,107 @@
+package kafka
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// feedWindows observes perWindow events of match-1 in each of n consecutive
+// windows from start, returning the start of the window after them.
+func feedWindows(d *RateAnomalyDetector, start time.Time, n, perWindow int) time.Time {
+	for w := 0; w < n; w++ {
+		windowStart := start.Add(time.Duration(w) * d.config.Window)
+		for i := 0; i < perWindow; i++ {
+			at := windowStart.Add(time.Duration(i) * time.Millisecond)
+			d.Observe("match-1", at, at)
+		}
+	}
+	return start.Add(time.Duration(n) * d.config.Window)
+}
+
+func TestRateAnomalyDetector_Spike(t *testing.T) {
+	d := NewRateAnomalyDetectorWithConfig(nil, RateAnomalyConfig{Window: time.Minute, Baseline: 5})
+	spikes := testutil.ToFloat64(matchRateAnomalies.WithLabelValues(RateAnomalySpike))
+	drops := testutil.ToFloat64(matchRateAnomalies.WithLabelValues(RateAnomalyDrop))
+
+	start := time.Date(2024, 5, 1, 18, 0, 0, 0, time.UTC)
+	next := feedWindows(d, start, 6, 10)
+	d.Evaluate(next)
+	if got := testutil.ToFloat64(matchRateAnomalies.WithLabelValues(RateAnomalySpike)); got != spikes {
+		t.Fatalf("expected no anomaly for a steady rate, spike counter moved by %v", got-spikes)
+	}
+
+	next = feedWindows(d, next, 1, 100)
+	d.Evaluate(next)
+	if got := testutil.ToFloat64(matchRateAnomalies.WithLabelValues(RateAnomalySpike)); got != spikes+1 {
+		t.Errorf("expected the spike counter to increment once, moved by %v", got-spikes)
+	}
+	if got := testutil.ToFloat64(matchRateAnomalies.WithLabelValues(RateAnomalyDrop)); got != drops {
+		t.Errorf("expected no drop, drop counter moved by %v", got-drops)
+	}
+}
+
+func TestRateAnomalyDetector_Flatline(t *testing.T) {
+	d := NewRateAnomalyDetectorWithConfig(nil, RateAnomalyConfig{Window: time.Minute, Baseline: 5})
+	drops := testutil.ToFloat64(matchRateAnomalies.WithLabelValues(RateAnomalyDrop))
+
+	start := time.Date(2024, 5, 1, 18, 0, 0, 0, time.UTC)
+	next := feedWindows(d, start, 6, 10)
+
+	// A window passing without events is reported by Evaluate alone
+	d.Evaluate(next.Add(time.Minute))
+	if got := testutil.ToFloat64(matchRateAnomalies.WithLabelValues(RateAnomalyDrop)); got != drops+1 {
+		t.Errorf("expected the drop counter to increment once, moved by %v", got-drops)
+	}
+
+	// A match silent for a whole baseline is forgotten
+	d.Evaluate(next.Add(10 * time.Minute))
+	if d.Tracked() != 0 {
+		t.Errorf("expected the idle match to be evicted, %d tracked", d.Tracked())
+	}
+}
+
+func TestRateAnomalyDetector_MaxMatches(t *testing.T) {
+	d := NewRateAnomalyDetectorWithConfig(nil, RateAnomalyConfig{MaxMatches: 2})
+
+	now := time.Now()
+	d.Observe("match-1", now, now)
+	d.Observe("match-2", now.Add(time.Second), now.Add(time.Second))
+	d.Observe("match-3", now.Add(2*time.Second), now.Add(2*time.Second))
+
+	if d.Tracked() != 2 {
+		t.Fatalf("expected 2 tracked matches, got %d", d.Tracked())
+	}
+	if _, ok := d.matches["match-1"]; ok {
+		t.Error("expected the least recently seen match to be evicted")
+	}
+}
+
+func TestRateAnomalyDetector_Backlog(t *testing.T) {
+	d := NewRateAnomalyDetectorWithConfig(nil, RateAnomalyConfig{Window: time.Minute, Baseline: 5})
+	spikes := testutil.ToFloat64(matchRateAnomalies.WithLabelValues(RateAnomalySpike))
+	drops := testutil.ToFloat64(matchRateAnomalies.WithLabelValues(RateAnomalyDrop))
+
+	// An hour behind, a steady backlog of windows is consumed in one burst
+	start := time.Date(2024, 5, 1, 18, 0, 0, 0, time.UTC)
+	consumedAt := start.Add(time.Hour)
+	for w := 0; w < 8; w++ {
+		windowStart := start.Add(time.Duration(w) * time.Minute)
+		for i := 0; i < 10; i++ {
+			d.Observe("match-1", windowStart.Add(time.Duration(i)*time.Second), consumedAt)
+		}
+	}
+
+	// Windows are not closed against the wall clock while lagging
+	d.Evaluate(consumedAt.Add(time.Second))
+	if got := testutil.ToFloat64(matchRateAnomalies.WithLabelValues(RateAnomalySpike)); got != spikes {
+		t.Errorf("expected no spike for a backlog consumed in a burst, spike counter moved by %v", got-spikes)
+	}
+	if got := testutil.ToFloat64(matchRateAnomalies.WithLabelValues(RateAnomalyDrop)); got != drops {
+		t.Errorf("expected no drop while lagging, drop counter moved by %v", got-drops)
+	}
+	if d.Tracked() != 1 {
+		t.Errorf("expected the match to stay tracked while lagging, %d tracked", d.Tracked())
+	}
+}
@@ -5,6 +5,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"log/slog"
+	"math/rand/v2"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 
@@ -13,86 +16,220 @@ import (
 	"github.com/segmentio/kafka-go"
 
 	"fanfinity/internal/domain"
+	"fanfinity/internal/metrics"
 )
 
 var (
 	// Prometheus metrics for Kafka consumer
-	kafkaConsumerLag = promauto.NewGaugeVec(
+	kafkaConsumerLag        *prometheus.GaugeVec
+	kafkaBatchesProcessed   *prometheus.CounterVec
+	kafkaEventsConsumed     *prometheus.CounterVec
+	kafkaConsumeDuration    *prometheus.HistogramVec
+	kafkaRetryEvents        *prometheus.CounterVec
+	kafkaDeadLetterEvents   prometheus.Counter
+	kafkaFlushInterval      prometheus.Gauge
+	eventProcessingDelay    prometheus.Histogram
+	kafkaCorrectionsApplied *prometheus.CounterVec
+	kafkaParseDeadLetters   prometheus.Counter
+	kafkaFetchWait          prometheus.Histogram
+	kafkaBatchPeakLength    prometheus.Gauge
+)
+
+// registerConsumerMetrics creates the consumer metrics under opts.
+func registerConsumerMetrics(f promauto.Factory, opts metrics.Options) {
+	ns := opts.NamespaceOrDefault()
+
+	kafkaConsumerLag = f.NewGaugeVec(
 		prometheus.GaugeOpts{
-			Namespace: "fanfinity",
-			Subsystem: "kafka_consumer",
-			Name:      "lag",
-			Help:      "Current consumer lag (difference between latest offset and committed offset)",
+			Namespace:   ns,
+			Subsystem:   "kafka_consumer",
+			Name:        "lag",
+			Help:        "Current consumer lag (difference between latest offset and committed offset)",
+			ConstLabels: opts.ConstLabels,
 		},
 		[]string{"topic", "partition"},
 	)
 
-	kafkaBatchesProcessed = promauto.NewCounterVec(
+	kafkaBatchesProcessed = f.NewCounterVec(
 		prometheus.CounterOpts{
-			Namespace: "fanfinity",
-			Subsystem: "kafka_consumer",
-			Name:      "batches_processed_total",
-			Help:      "Total number of batches processed",
+			Namespace:   ns,
+			Subsystem:   "kafka_consumer",
+			Name:        "batches_processed_total",
+			Help:        "Total number of batches processed",
+			ConstLabels: opts.ConstLabels,
 		},
 		[]string{"status"},
 	)
 
-	kafkaEventsConsumed = promauto.NewCounterVec(
+	kafkaEventsConsumed = f.NewCounterVec(
 		prometheus.CounterOpts{
-			Namespace: "fanfinity",
-			Subsystem: "kafka_consumer",
-			Name:      "events_consumed_total",
-			Help:      "Total number of events consumed from Kafka",
+			Namespace:   ns,
+			Subsystem:   "kafka_consumer",
+			Name:        "events_consumed_total",
+			Help:        "Total number of events consumed from Kafka",
+			ConstLabels: opts.ConstLabels,
 		},
 		[]string{"status"},
 	)
 
-	kafkaConsumeDuration = promauto.NewHistogramVec(
+	kafkaConsumeDuration = f.NewHistogramVec(
 		prometheus.HistogramOpts{
-			Namespace: "fanfinity",
-			Subsystem: "kafka_consumer",
-			Name:      "consume_duration_seconds",
-			Help:      "Histogram of batch processing duration in seconds",
-			Buckets:   []float64{0.01, 0.05, 0.1, 0.25, 0.5, 1.0, 2.5, 5.0, 10.0},
+			Namespace:   ns,
+			Subsystem:   "kafka_consumer",
+			Name:        "consume_duration_seconds",
+			Help:        "Histogram of batch processing duration in seconds",
+			ConstLabels: opts.ConstLabels,
+			Buckets:     []float64{0.01, 0.05, 0.1, 0.25, 0.5, 1.0, 2.5, 5.0, 10.0},
 		},
 		[]string{"operation"},
 	)
 
-	kafkaRetryEvents = promauto.NewCounterVec(
+	kafkaRetryEvents = f.NewCounterVec(
 		prometheus.CounterOpts{
-			Namespace: "fanfinity",
-			Subsystem: "kafka_consumer",
-			Name:      "retry_events_total",
-			Help:      "Total number of events sent to retry topic",
+			Namespace:   ns,
+			Subsystem:   "kafka_consumer",
+			Name:        "retry_events_total",
+			Help:        "Total number of events sent to retry topic",
+			ConstLabels: opts.ConstLabels,
 		},
 		[]string{"status"},
 	)
 
-	kafkaDeadLetterEvents = promauto.NewCounter(
+	kafkaDeadLetterEvents = f.NewCounter(
 		prometheus.CounterOpts{
-			Namespace: "fanfinity",
-			Subsystem: "kafka_consumer",
-			Name:      "dead_letter_events_total",
-			Help:      "Total number of events sent to dead letter queue",
+			Namespace:   ns,
+			Subsystem:   "kafka_consumer",
+			Name:        "dead_letter_events_total",
+			Help:        "Total number of events sent to dead letter queue",
+			ConstLabels: opts.ConstLabels,
+		},
+	)
+
+	kafkaFlushInterval = f.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace:   ns,
+			Subsystem:   "kafka_consumer",
+			Name:        "flush_interval_seconds",
+			Help:        "Current effective time-based flush interval",
+			ConstLabels: opts.ConstLabels,
+		},
+	)
+
+	eventProcessingDelay = f.NewHistogram(
+		prometheus.HistogramOpts{
+			Namespace:   ns,
+			Name:        "event_processing_delay_seconds",
+			Help:        "Delay between event time and insertion into ClickHouse",
+			ConstLabels: opts.ConstLabels,
+			Buckets:     []float64{0.5, 1, 2.5, 5, 10, 30, 60, 120, 300, 600, 1800},
+		},
+	)
+
+	kafkaCorrectionsApplied = f.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace:   ns,
+			Subsystem:   "kafka_consumer",
+			Name:        "corrections_applied_total",
+			Help:        "Total number of correction events stored, by action",
+			ConstLabels: opts.ConstLabels,
+		},
+		[]string{"action"},
+	)
+
+	kafkaParseDeadLetters = f.NewCounter(
+		prometheus.CounterOpts{
+			Namespace:   ns,
+			Subsystem:   "kafka",
+			Name:        "parse_dead_letter_total",
+			Help:        "Total number of unparseable messages sent to the dead letter queue",
+			ConstLabels: opts.ConstLabels,
+		},
+	)
+
+	// Fetch wait and consume_duration_seconds{operation="insert_batch"}
+	// together give the consumer's busy ratio: mostly fetch wait means it is
+	// starved, mostly insert time that ClickHouse is the bottleneck.
+	kafkaFetchWait = f.NewHistogram(
+		prometheus.HistogramOpts{
+			Namespace:   ns,
+			Subsystem:   "kafka_consumer",
+			Name:        "fetch_wait_seconds",
+			Help:        "Time spent waiting in FetchMessage, including polls that time out with no message",
+			ConstLabels: opts.ConstLabels,
+			Buckets:     []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.075, 0.1, 0.25},
 		},
 	)
-)
+
+	kafkaBatchPeakLength = f.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace:   ns,
+			Subsystem:   "kafka_consumer",
+			Name:        "batch_peak_length",
+			Help:        "Largest number of events flushed in one batch since the consumer started",
+			ConstLabels: opts.ConstLabels,
+		},
+	)
+}
 
 // Repository defines the interface for batch event insertion.
 type Repository interface {
 	InsertBatch(ctx context.Context, events []*domain.Event) error
 }
 
+// Upserter is implemented by repositories that can replace stored events.
+// UpsertBatch stores events like InsertBatch, but leaves only the latest
+// version of each upserted event, within the batch and already stored.
+type Upserter interface {
+	UpsertBatch(ctx context.Context, events []*domain.Event) error
+}
+
+// MatchLagPublisher shares per-match consumer lag with the ingestion API, so it
+// can slow down producers of matches that fall behind.
+type MatchLagPublisher interface {
+	PublishMatchLag(ctx context.Context, lags map[string]time.Duration) error
+}
+
+// StatusPublisher records whether events sent with X-Confirm were stored, for
+// the ingestion API's event status endpoint.
+type StatusPublisher interface {
+	PublishEventStatus(ctx context.Context, updates []domain.EventStatusUpdate) error
+}
+
+// MessageReader defines the subset of kafka.Reader used by the consumer.
+type MessageReader interface {
+	FetchMessage(ctx context.Context) (kafka.Message, error)
+	CommitMessages(ctx context.Context, msgs ...kafka.Message) error
+	Stats() kafka.ReaderStats
+}
+
+// MessageWriter defines the subset of kafka.Writer used to write retry and dead letter messages.
+type MessageWriter interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+}
+
 // BatchConsumer consumes events from Kafka and batch inserts them into ClickHouse.
 type BatchConsumer struct {
-	reader        *kafka.Reader
+	reader        MessageReader
 	repository    Repository
-	retryWriter   *kafka.Writer
-	deadWriter    *kafka.Writer
-	batchSize     int
-	flushInterval time.Duration
+	retryWriter   MessageWriter
+	deadWriter    MessageWriter
+	batchSize     int // guarded by batchLock
+	maxBatchBytes int
+	flushInterval time.Duration // guarded by batchLock
 	maxRetries    int
 	logger        *slog.Logger
+	dryRun        bool
+	useJSONNumber bool
+
+	// Adaptive flush state. currentInterval is only touched by the Start goroutine.
+	adaptiveFlush    bool
+	minFlushInterval time.Duration
+	maxFlushInterval time.Duration
+	currentInterval  time.Duration
+
+	// intervalUpdates hands a flush interval set by SetFlushInterval to the
+	// Start goroutine, which owns the ticker.
+	intervalUpdates chan time.Duration
 
 	batch     []*domain.Event
 	messages  []kafka.Message
@@ -100,20 +237,133 @@ type BatchConsumer struct {
 	ticker    *time.Ticker
 	done      chan struct{}
 	wg        sync.WaitGroup
+
+	// flushMu serializes flushes, so a Drain waits for a flush begun by Start
+	// to insert and commit before the partitions are released.
+	flushMu sync.Mutex
+
+	// batchBytes is the total payload size of messages; guarded by batchLock.
+	batchBytes int
+
+	// With reuseBuffers, a flushed batch's backing arrays are kept in
+	// spareBatch and spareMessages for the next batch. These and
+	// peakBatchLen are guarded by batchLock.
+	reuseBuffers  bool
+	spareBatch    []*domain.Event
+	spareMessages []kafka.Message
+	peakBatchLen  int
+
+	// ordering is nil unless the ordering check is enabled; guarded by batchLock.
+	ordering *orderingTracker
+
+	rateAnomalies *RateAnomalyDetector
+	clock         domain.Clock
+
+	deadLetterRetention     map[DeadLetterReason]string
+	deadLetterGzipThreshold int
+
+	sampleFailedEvents   bool
+	failedSampleMaxBytes int
+
+	lagPublisher    MatchLagPublisher
+	statusPublisher StatusPublisher
+
+	// lagMu guards lagReported, the matches published with a lag since the
+	// consumer last caught up.
+	lagMu       sync.Mutex
+	lagReported map[string]struct{}
 }
 
 // BatchConsumerConfig holds configuration for the batch consumer.
 type BatchConsumerConfig struct {
-	Reader        *kafka.Reader
+	Reader        MessageReader
 	Repository    Repository
-	RetryWriter   *kafka.Writer
-	DeadWriter    *kafka.Writer
+	RetryWriter   MessageWriter
+	DeadWriter    MessageWriter
 	BatchSize     int
 	FlushInterval time.Duration
 	MaxRetries    int
 	Logger        *slog.Logger
+
+	// MaxBatchBytes flushes the batch once the serialized size of its events
+	// reaches this many bytes, even below BatchSize. Zero disables the limit.
+	MaxBatchBytes int
+
+	// ReuseBatchBuffers reuses the batch's backing arrays once a flush has
+	// finished with them, instead of allocating new ones for every batch.
+	// Arrays grown past twice BatchSize by a burst are released rather than
+	// kept. The Repository must not retain the events slice after InsertBatch
+	// or UpsertBatch returns.
+	ReuseBatchBuffers bool
+
+	// UseJSONNumber decodes metadata numbers as json.Number rather than
+	// float64, so integers are stored exactly as they were produced.
+	UseJSONNumber bool
+
+	// Clock times processing delay, lag and dead letter failures. Nil means
+	// domain.SystemClock.
+	Clock domain.Clock
+
+	// DryRun parses and batches events but only logs what would be inserted:
+	// nothing is written to the repository, retry or dead letter topics, and no
+	// offsets are committed, so the messages remain unconsumed for the group.
+	DryRun bool
+
+	// CheckOrdering counts and logs events whose timestamp is earlier than the
+	// last seen for their match in the same batch. Such events are still inserted.
+	// MaxTrackedMatches bounds the matches tracked per batch.
+	CheckOrdering     bool
+	MaxTrackedMatches int
+
+	// RateAnomalies, when set, counts every consumed event towards its match's
+	// event rate. The caller runs the detector.
+	RateAnomalies *RateAnomalyDetector
+
+	// AdaptiveFlush enables adjusting the flush interval to observed throughput.
+	// FlushInterval is used as the starting point and the interval stays within
+	// [MinFlushInterval, MaxFlushInterval].
+	AdaptiveFlush    bool
+	MinFlushInterval time.Duration
+	MaxFlushInterval time.Duration
+
+	// DeadLetterRetention maps each dead letter reason to the retention class
+	// set in the message's retention header, so tooling can expire noise.
+	// Reasons missing from the map use DefaultDeadLetterRetention.
+	DeadLetterRetention map[DeadLetterReason]string
+
+	// DeadLetterGzipThreshold gzips dead letter values larger than this many
+	// bytes and marks them with a content-encoding: gzip header; read them back
+	// with DecodeDeadLetter. 0 never compresses.
+	DeadLetterGzipThreshold int
+
+	// LogFailedEventSample adds one randomly chosen event of a batch that failed
+	// to insert to the failure log, serialized and cut to
+	// FailedEventSampleMaxBytes. Off by default, since event bodies may carry
+	// personal data.
+	LogFailedEventSample      bool
+	FailedEventSampleMaxBytes int
+
+	// LagPublisher, when set, receives the lag of each match in a batch after
+	// it is inserted: the time since its oldest message was produced. Once a
+	// flush interval passes with nothing fetched, those matches are published
+	// with zero lag. Nil publishes nothing.
+	LagPublisher MatchLagPublisher
+
+	// StatusPublisher, when set, receives the status of events that asked for
+	// confirmation: persisted once inserted, failed once dead-lettered. Nil
+	// publishes nothing.
+	StatusPublisher StatusPublisher
 }
 
+// Default bounds for adaptive flushing.
+const (
+	DefaultMinFlushInterval = 500 * time.Millisecond
+	DefaultMaxFlushInterval = 30 * time.Second
+)
+
+// DefaultFailedEventSampleMaxBytes bounds the event sampled into a batch failure log.
+const DefaultFailedEventSampleMaxBytes = 1024
+
 // NewBatchConsumer creates a new BatchConsumer instance.
 func NewBatchConsumer(cfg BatchConsumerConfig) *BatchConsumer {
 	if cfg.BatchSize <= 0 {
@@ -128,6 +378,26 @@ func NewBatchConsumer(cfg BatchConsumerConfig) *BatchConsumer {
 	if cfg.Logger == nil {
 		cfg.Logger = slog.Default()
 	}
+	if cfg.MinFlushInterval <= 0 {
+		cfg.MinFlushInterval = DefaultMinFlushInterval
+	}
+	if cfg.MaxFlushInterval <= 0 {
+		cfg.MaxFlushInterval = DefaultMaxFlushInterval
+	}
+	if cfg.FailedEventSampleMaxBytes <= 0 {
+		cfg.FailedEventSampleMaxBytes = DefaultFailedEventSampleMaxBytes
+	}
+	if cfg.MinFlushInterval > cfg.FlushInterval {
+		cfg.MinFlushInterval = cfg.FlushInterval
+	}
+	if cfg.MaxFlushInterval < cfg.FlushInterval {
+		cfg.MaxFlushInterval = cfg.FlushInterval
+	}
+
+	var ordering *orderingTracker
+	if cfg.CheckOrdering {
+		ordering = newOrderingTracker(cfg.MaxTrackedMatches)
+	}
 
 	return &BatchConsumer{
 		reader:        cfg.Reader,
@@ -135,24 +405,55 @@ func NewBatchConsumer(cfg BatchConsumerConfig) *BatchConsumer {
 		retryWriter:   cfg.RetryWriter,
 		deadWriter:    cfg.DeadWriter,
 		batchSize:     cfg.BatchSize,
+		maxBatchBytes: cfg.MaxBatchBytes,
 		flushInterval: cfg.FlushInterval,
 		maxRetries:    cfg.MaxRetries,
 		logger:        cfg.Logger,
-		batch:         make([]*domain.Event, 0, cfg.BatchSize),
-		messages:      make([]kafka.Message, 0, cfg.BatchSize),
-		done:          make(chan struct{}),
+		dryRun:        cfg.DryRun,
+		useJSONNumber: cfg.UseJSONNumber,
+
+		adaptiveFlush:    cfg.AdaptiveFlush,
+		minFlushInterval: cfg.MinFlushInterval,
+		maxFlushInterval: cfg.MaxFlushInterval,
+		currentInterval:  cfg.FlushInterval,
+		intervalUpdates:  make(chan time.Duration, 1),
+
+		batch:        make([]*domain.Event, 0, cfg.BatchSize),
+		messages:     make([]kafka.Message, 0, cfg.BatchSize),
+		reuseBuffers: cfg.ReuseBatchBuffers,
+		ordering:     ordering,
+		done:         make(chan struct{}),
+
+		rateAnomalies: cfg.RateAnomalies,
+		clock:         domain.ClockOrSystem(cfg.Clock),
+
+		deadLetterRetention:     cfg.DeadLetterRetention,
+		deadLetterGzipThreshold: cfg.DeadLetterGzipThreshold,
+		sampleFailedEvents:      cfg.LogFailedEventSample,
+		failedSampleMaxBytes:    cfg.FailedEventSampleMaxBytes,
+		lagPublisher:            cfg.LagPublisher,
+		statusPublisher:         cfg.StatusPublisher,
 	}
 }
 
 // Start begins consuming messages from Kafka.
 // This method blocks until Stop() is called or the context is cancelled.
 func (c *BatchConsumer) Start(ctx context.Context) {
+	c.batchLock.Lock()
+	batchSize, flushInterval := c.batchSize, c.flushInterval
+	c.batchLock.Unlock()
+
 	c.logger.Info("starting batch consumer",
-		slog.Int("batch_size", c.batchSize),
-		slog.Duration("flush_interval", c.flushInterval),
+		slog.Int("batch_size", batchSize),
+		slog.Int("max_batch_bytes", c.maxBatchBytes),
+		slog.Duration("flush_interval", flushInterval),
+		slog.Bool("adaptive_flush", c.adaptiveFlush),
+		slog.Bool("dry_run", c.dryRun),
 	)
 
-	c.ticker = time.NewTicker(c.flushInterval)
+	c.currentInterval = c.clampFlushInterval(flushInterval)
+	kafkaFlushInterval.Set(c.currentInterval.Seconds())
+	c.ticker = time.NewTicker(c.currentInterval)
 	defer c.ticker.Stop()
 
 	c.wg.Add(1)
@@ -171,12 +472,26 @@ func (c *BatchConsumer) Start(ctx context.Context) {
 			return
 
 		case <-c.ticker.C:
+			c.batchLock.Lock()
+			pending := len(c.batch)
+			c.batchLock.Unlock()
+
 			c.flushWithContext(ctx)
+			c.adaptFlushInterval(false, pending)
+			if pending == 0 {
+				// Nothing arrived for a whole interval, so the backlog has drained
+				c.publishCaughtUp(ctx)
+			}
+
+		case interval := <-c.intervalUpdates:
+			c.resetFlushInterval(interval)
 
 		default:
 			// Fetch message with a short timeout to allow checking for shutdown
 			fetchCtx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+			fetchStart := time.Now()
 			msg, err := c.reader.FetchMessage(fetchCtx)
+			kafkaFetchWait.Observe(time.Since(fetchStart).Seconds())
 			cancel()
 
 			if err != nil {
@@ -198,43 +513,224 @@ func (c *BatchConsumer) Start(ctx context.Context) {
 			c.updateLagMetric(msg)
 
 			// Parse the message
-			event, err := domain.EventFromKafkaMessage(msg.Value)
+			event, err := domain.DecodeKafkaMessage(msg.Value, c.useJSONNumber)
 			if err != nil {
-				c.logger.Error("failed to parse message",
-					slog.String("error", err.Error()),
-					slog.Int64("offset", msg.Offset),
-					slog.Int("partition", msg.Partition),
-				)
-				kafkaEventsConsumed.WithLabelValues("parse_error").Inc()
-				// Commit the message even if parsing failed to avoid reprocessing
-				if commitErr := c.reader.CommitMessages(ctx, msg); commitErr != nil {
-					c.logger.Error("failed to commit message after parse error",
-						slog.String("error", commitErr.Error()),
-					)
-				}
+				c.handleParseError(ctx, msg, err)
 				continue
 			}
+			event.Op = eventOp(msg)
+			event.Confirm = eventConfirm(msg)
+			if c.rateAnomalies != nil {
+				now := c.clock.Now()
+				producedAt := msg.Time
+				if producedAt.IsZero() {
+					producedAt = now
+				}
+				c.rateAnomalies.Observe(event.MatchID, producedAt, now)
+			}
 
 			// Add to batch
 			c.batchLock.Lock()
+			c.checkOrdering(event, msg)
 			c.batch = append(c.batch, event)
 			c.messages = append(c.messages, msg)
+			c.batchBytes += len(msg.Value)
 			batchLen := len(c.batch)
+			batchBytes := c.batchBytes
+			full := c.batchFull(batchLen, batchBytes)
 			c.batchLock.Unlock()
 
 			c.logger.Debug("message added to batch",
 				slog.String("event_id", event.EventID.String()),
 				slog.Int("batch_size", batchLen),
+				slog.Int("batch_bytes", batchBytes),
 			)
 
-			// Flush if batch is full
-			if batchLen >= c.batchSize {
+			// Flush if batch is full by count or size
+			if full {
 				c.flushWithContext(ctx)
+				c.adaptFlushInterval(true, batchLen)
 			}
 		}
 	}
 }
 
+// batchFull reports whether a batch of n events totalling size bytes should be
+// flushed. The caller must hold batchLock.
+func (c *BatchConsumer) batchFull(n, size int) bool {
+	return n >= c.batchSize || (c.maxBatchBytes > 0 && size >= c.maxBatchBytes)
+}
+
+// checkOrdering flags event when it is earlier than the last event batched for
+// its match. The event is kept either way. The caller must hold batchLock.
+func (c *BatchConsumer) checkOrdering(event *domain.Event, msg kafka.Message) {
+	if c.ordering == nil {
+		return
+	}
+	last, outOfOrder := c.ordering.observe(event)
+	if !outOfOrder {
+		return
+	}
+
+	outOfOrderEvents.Inc()
+	c.logger.Warn("out-of-order event for match",
+		slog.String("event_id", event.EventID.String()),
+		slog.String("match_id", event.MatchID),
+		slog.Time("timestamp", event.Timestamp),
+		slog.Time("last_seen", last),
+		slog.Int("partition", msg.Partition),
+		slog.Int64("offset", msg.Offset),
+	)
+}
+
+// adaptFlushInterval adjusts the time-based flush interval after a flush when
+// adaptive flushing is enabled. Size-triggered flushes and idle ticks double the
+// interval, since the timer is either redundant or waking up for nothing. Timer
+// flushes of a partial batch halve it to keep end-to-end latency low.
+func (c *BatchConsumer) adaptFlushInterval(sizeTriggered bool, pending int) {
+	if !c.adaptiveFlush {
+		return
+	}
+
+	next := c.nextFlushInterval(sizeTriggered, pending)
+	if next == c.currentInterval {
+		return
+	}
+
+	c.logger.Debug("adjusting flush interval",
+		slog.Duration("from", c.currentInterval),
+		slog.Duration("to", next),
+		slog.Bool("size_triggered", sizeTriggered),
+		slog.Int("pending", pending),
+	)
+
+	c.currentInterval = next
+	kafkaFlushInterval.Set(next.Seconds())
+	if c.ticker != nil {
+		c.ticker.Reset(next)
+	}
+}
+
+// SetBatchSize changes the number of events that fills a batch. It is safe to
+// call while the consumer is running and applies from the next message; values
+// below 1 are ignored.
+func (c *BatchConsumer) SetBatchSize(n int) {
+	if n <= 0 {
+		return
+	}
+	c.batchLock.Lock()
+	defer c.batchLock.Unlock()
+	c.batchSize = n
+}
+
+// BatchSize returns the number of events that fills a batch.
+func (c *BatchConsumer) BatchSize() int {
+	c.batchLock.Lock()
+	defer c.batchLock.Unlock()
+	return c.batchSize
+}
+
+// SetFlushInterval changes the interval between time-based flushes. It is safe
+// to call while the consumer is running; the ticker is reset to the new
+// interval straight away. With adaptive flushing the interval becomes the new
+// starting point, kept within the adaptive bounds. Values of zero or less are
+// ignored.
+func (c *BatchConsumer) SetFlushInterval(d time.Duration) {
+	if d <= 0 {
+		return
+	}
+	c.batchLock.Lock()
+	c.flushInterval = d
+	c.batchLock.Unlock()
+
+	// Replace any update the Start goroutine has not picked up yet
+	select {
+	case <-c.intervalUpdates:
+	default:
+	}
+	select {
+	case c.intervalUpdates <- d:
+	default:
+	}
+}
+
+// FlushInterval returns the configured interval between time-based flushes.
+func (c *BatchConsumer) FlushInterval() time.Duration {
+	c.batchLock.Lock()
+	defer c.batchLock.Unlock()
+	return c.flushInterval
+}
+
+// resetFlushInterval switches the running ticker to interval. It is only
+// called from the Start goroutine.
+func (c *BatchConsumer) resetFlushInterval(interval time.Duration) {
+	interval = c.clampFlushInterval(interval)
+	if interval == c.currentInterval {
+		return
+	}
+	c.currentInterval = interval
+	kafkaFlushInterval.Set(interval.Seconds())
+	if c.ticker != nil {
+		c.ticker.Reset(interval)
+	}
+}
+
+// clampFlushInterval keeps d within the adaptive bounds when adaptive flushing
+// is enabled.
+func (c *BatchConsumer) clampFlushInterval(d time.Duration) time.Duration {
+	if !c.adaptiveFlush {
+		return d
+	}
+	if d < c.minFlushInterval {
+		return c.minFlushInterval
+	}
+	if d > c.maxFlushInterval {
+		return c.maxFlushInterval
+	}
+	return d
+}
+
+// nextFlushInterval computes the next flush interval within the configured bounds.
+func (c *BatchConsumer) nextFlushInterval(sizeTriggered bool, pending int) time.Duration {
+	next := c.currentInterval
+	if sizeTriggered || pending == 0 {
+		next *= 2
+	} else {
+		next /= 2
+	}
+
+	if next < c.minFlushInterval {
+		next = c.minFlushInterval
+	}
+	if next > c.maxFlushInterval {
+		next = c.maxFlushInterval
+	}
+	return next
+}
+
+// handleParseError dead-letters an unparseable message with its raw payload and
+// the parse error, then commits it so the consumer does not reprocess it.
+func (c *BatchConsumer) handleParseError(ctx context.Context, msg kafka.Message, parseErr error) {
+	c.logger.Error("failed to parse message",
+		slog.String("error", parseErr.Error()),
+		slog.Int64("offset", msg.Offset),
+		slog.Int("partition", msg.Partition),
+	)
+	kafkaEventsConsumed.WithLabelValues("parse_error").Inc()
+	if c.dryRun {
+		return
+	}
+
+	c.sendRawToDead(ctx, msg, parseErr)
+
+	// Commit the message even if parsing failed to avoid reprocessing
+	if commitErr := c.reader.CommitMessages(ctx, msg); commitErr != nil {
+		c.logger.Error("failed to commit message after parse error",
+			slog.String("error", commitErr.Error()),
+		)
+	}
+}
+
 // updateLagMetric updates the consumer lag Prometheus metric.
 func (c *BatchConsumer) updateLagMetric(msg kafka.Message) {
 	// Get the current lag stats from the reader
@@ -245,8 +741,18 @@ func (c *BatchConsumer) updateLagMetric(msg kafka.Message) {
 	).Set(float64(stats.Lag))
 }
 
-// flushWithContext flushes the current batch to the repository.
+// flushWithContext flushes the current batch to the repository. When the
+// reader implements PartitionOwner, messages from partitions it no longer owns
+// are dropped rather than inserted: their new owner re-reads them from the last
+// committed offset.
 func (c *BatchConsumer) flushWithContext(ctx context.Context) {
+	c.flushMu.Lock()
+	defer c.flushMu.Unlock()
+
+	if owner, ok := c.reader.(PartitionOwner); ok {
+		c.dropUnowned(owner)
+	}
+
 	c.batchLock.Lock()
 	if len(c.batch) == 0 {
 		c.batchLock.Unlock()
@@ -256,9 +762,22 @@ func (c *BatchConsumer) flushWithContext(ctx context.Context) {
 	// Take ownership of the current batch
 	events := c.batch
 	messages := c.messages
-	c.batch = make([]*domain.Event, 0, c.batchSize)
-	c.messages = make([]kafka.Message, 0, c.batchSize)
+	c.batch, c.messages = c.nextBuffers()
+	c.batchBytes = 0
+	if c.ordering != nil {
+		c.ordering.reset()
+	}
+	if len(events) > c.peakBatchLen {
+		c.peakBatchLen = len(events)
+		kafkaBatchPeakLength.Set(float64(c.peakBatchLen))
+	}
 	c.batchLock.Unlock()
+	defer c.recycleBuffers(events, messages)
+
+	if c.dryRun {
+		c.logDryRunBatch(events, messages)
+		return
+	}
 
 	startTime := time.Now()
 	c.logger.Debug("flushing batch",
@@ -266,16 +785,29 @@ func (c *BatchConsumer) flushWithContext(ctx context.Context) {
 	)
 
 	// Insert batch into ClickHouse
-	err := c.repository.InsertBatch(ctx, events)
+	err := c.insertBatch(ctx, events)
 	duration := time.Since(startTime)
 	kafkaConsumeDuration.WithLabelValues("insert_batch").Observe(duration.Seconds())
 
+	if pe := domain.AsPartialInsertError(err); pe != nil {
+		// The rest of the batch was stored: dead-letter the rejected events
+		// and commit every message as for a successful insert
+		events = c.sendRejectedToDead(ctx, events, pe)
+		err = nil
+	}
+
 	if err != nil {
-		c.logger.Error("failed to insert batch",
+		attrs := []any{
 			slog.Int("batch_size", len(events)),
 			slog.Duration("duration", duration),
 			slog.String("error", err.Error()),
-		)
+		}
+		if c.sampleFailedEvents {
+			if sample, ok := c.sampleEvent(events); ok {
+				attrs = append(attrs, slog.String("sampled_event", sample))
+			}
+		}
+		c.logger.Error("failed to insert batch", attrs...)
 		kafkaBatchesProcessed.WithLabelValues("error").Inc()
 
 		// Send failed events to retry topic
@@ -294,6 +826,12 @@ func (c *BatchConsumer) flushWithContext(ctx context.Context) {
 		}
 	}
 
+	now := c.clock.Now()
+	observeProcessingDelay(events, now)
+	c.publishMatchLag(ctx, messages, now)
+	c.publishStatus(ctx, events, domain.EventStatusPersisted)
+	c.recordCorrections(events)
+
 	c.logger.Info("batch flushed successfully",
 		slog.Int("batch_size", len(events)),
 		slog.Duration("duration", duration),
@@ -302,13 +840,231 @@ func (c *BatchConsumer) flushWithContext(ctx context.Context) {
 	kafkaEventsConsumed.WithLabelValues("success").Add(float64(len(events)))
 }
 
+// nextBuffers returns empty slices for the next batch, reusing the spare
+// arrays when there are any. The caller must hold batchLock.
+func (c *BatchConsumer) nextBuffers() ([]*domain.Event, []kafka.Message) {
+	if c.spareBatch != nil {
+		events, messages := c.spareBatch, c.spareMessages
+		c.spareBatch, c.spareMessages = nil, nil
+		return events, messages
+	}
+	return make([]*domain.Event, 0, c.batchSize), make([]kafka.Message, 0, c.batchSize)
+}
+
+// recycleBuffers keeps a flushed batch's arrays as the spares for a later
+// batch when buffer reuse is enabled. The arrays are cleared first so they do
+// not pin the flushed events, and dropped if a burst grew them past twice the
+// batch size.
+func (c *BatchConsumer) recycleBuffers(events []*domain.Event, messages []kafka.Message) {
+	if !c.reuseBuffers {
+		return
+	}
+
+	c.batchLock.Lock()
+	defer c.batchLock.Unlock()
+	if cap(events) > 2*c.batchSize || cap(messages) > 2*c.batchSize {
+		return
+	}
+	clear(events[:cap(events)])
+	clear(messages[:cap(messages)])
+	c.spareBatch, c.spareMessages = events[:0], messages[:0]
+}
+
+// logDryRunBatch logs the batch a dry run would have inserted, leaving the
+// messages uncommitted.
+func (c *BatchConsumer) logDryRunBatch(events []*domain.Event, messages []kafka.Message) {
+	matches := make(map[string]int)
+	for _, event := range events {
+		matches[event.MatchID]++
+	}
+
+	attrs := []any{
+		slog.Int("batch_size", len(events)),
+		slog.Int("match_count", len(matches)),
+	}
+	if len(messages) > 0 {
+		first, last := messages[0], messages[len(messages)-1]
+		attrs = append(attrs,
+			slog.Int64("first_offset", first.Offset),
+			slog.Int64("last_offset", last.Offset),
+		)
+	}
+	c.logger.Info("dry run: would insert batch", attrs...)
+	kafkaEventsConsumed.WithLabelValues("would_insert").Add(float64(len(events)))
+}
+
+// sampleEvent serializes one randomly chosen event of a failed batch for the
+// failure log, cut to failedSampleMaxBytes.
+func (c *BatchConsumer) sampleEvent(events []*domain.Event) (string, bool) {
+	if len(events) == 0 {
+		return "", false
+	}
+	event := events[rand.IntN(len(events))]
+	if event == nil {
+		return "", false
+	}
+	value, err := event.ToKafkaMessage()
+	if err != nil {
+		return "", false
+	}
+	if len(value) <= c.failedSampleMaxBytes {
+		return string(value), true
+	}
+	// Drop a rune split by the cut rather than logging invalid UTF-8
+	return strings.ToValidUTF8(string(value[:c.failedSampleMaxBytes]), "") + "...(truncated)", true
+}
+
+// observeProcessingDelay records how far behind event time each inserted event is.
+// Negative delays caused by producer clock skew are clamped to zero.
+func observeProcessingDelay(events []*domain.Event, now time.Time) {
+	for _, event := range events {
+		if event == nil {
+			continue
+		}
+		delay := now.Sub(event.Timestamp)
+		if delay < 0 {
+			delay = 0
+		}
+		eventProcessingDelay.Observe(delay.Seconds())
+	}
+}
+
+// publishMatchLag publishes the lag of each match in an inserted batch, the
+// longest time since one of its messages was produced. Messages are keyed by
+// match ID; those without a produce time are skipped. Failures are logged and
+// do not affect the batch.
+func (c *BatchConsumer) publishMatchLag(ctx context.Context, messages []kafka.Message, now time.Time) {
+	if c.lagPublisher == nil {
+		return
+	}
+	lags := make(map[string]time.Duration)
+	for _, msg := range messages {
+		if msg.Time.IsZero() || len(msg.Key) == 0 {
+			continue
+		}
+		delay := now.Sub(msg.Time)
+		if delay < 0 {
+			delay = 0
+		}
+		matchID := string(msg.Key)
+		if lag, ok := lags[matchID]; !ok || delay > lag {
+			lags[matchID] = delay
+		}
+	}
+	if len(lags) == 0 {
+		return
+	}
+
+	if err := c.lagPublisher.PublishMatchLag(ctx, lags); err != nil {
+		c.logger.Warn("failed to publish match lag",
+			slog.Int("match_count", len(lags)),
+			slog.String("error", err.Error()),
+		)
+		return
+	}
+	c.lagMu.Lock()
+	if c.lagReported == nil {
+		c.lagReported = make(map[string]struct{}, len(lags))
+	}
+	for matchID := range lags {
+		c.lagReported[matchID] = struct{}{}
+	}
+	c.lagMu.Unlock()
+}
+
+// publishCaughtUp publishes zero lag for every match reported since the
+// consumer last caught up, so the API stops throttling them once the backlog
+// drains rather than when their last report expires. A failed publish is
+// retried after the next idle interval.
+func (c *BatchConsumer) publishCaughtUp(ctx context.Context) {
+	if c.lagPublisher == nil {
+		return
+	}
+	c.lagMu.Lock()
+	if len(c.lagReported) == 0 {
+		c.lagMu.Unlock()
+		return
+	}
+	lags := make(map[string]time.Duration, len(c.lagReported))
+	for matchID := range c.lagReported {
+		lags[matchID] = 0
+	}
+	c.lagMu.Unlock()
+
+	if err := c.lagPublisher.PublishMatchLag(ctx, lags); err != nil {
+		c.logger.Warn("failed to publish match lag",
+			slog.Int("match_count", len(lags)),
+			slog.String("error", err.Error()),
+		)
+		return
+	}
+	c.lagMu.Lock()
+	for matchID := range lags {
+		delete(c.lagReported, matchID)
+	}
+	c.lagMu.Unlock()
+}
+
+// publishStatus publishes status for the events that asked for confirmation.
+// Failures are logged; the events' status then stays accepted until the
+// tracking window ends.
+func (c *BatchConsumer) publishStatus(ctx context.Context, events []*domain.Event, status domain.EventStatus) {
+	if c.statusPublisher == nil {
+		return
+	}
+	var updates []domain.EventStatusUpdate
+	for _, event := range events {
+		if event != nil && event.Confirm {
+			updates = append(updates, domain.EventStatusUpdate{EventID: event.EventID, MatchID: event.MatchID, Status: status})
+		}
+	}
+	if len(updates) == 0 {
+		return
+	}
+
+	if err := c.statusPublisher.PublishEventStatus(ctx, updates); err != nil {
+		c.logger.Warn("failed to publish event status",
+			slog.String("status", string(status)),
+			slog.Int("event_count", len(updates)),
+			slog.String("error", err.Error()),
+		)
+	}
+}
+
+// recordCorrections logs and counts the correction events in an inserted batch.
+// Corrections are applied by storing them as tombstone rows: metrics queries
+// exclude every event referenced by a stored correction.
+func (c *BatchConsumer) recordCorrections(events []*domain.Event) {
+	for _, event := range events {
+		if event == nil || !event.IsCorrection() {
+			continue
+		}
+		correction, err := event.Correction()
+		if err != nil {
+			// Validated at ingestion; a malformed correction here came from another producer
+			c.logger.Warn("stored correction has invalid metadata",
+				slog.String("event_id", event.EventID.String()),
+				slog.String("error", err.Error()),
+			)
+			continue
+		}
+		kafkaCorrectionsApplied.WithLabelValues(string(correction.Action)).Inc()
+		c.logger.Info("correction applied",
+			slog.String("event_id", event.EventID.String()),
+			slog.String("match_id", event.MatchID),
+			slog.String("corrects_event_id", correction.CorrectsEventID.String()),
+			slog.String("action", string(correction.Action)),
+		)
+	}
+}
+
 // sendToRetry sends failed events to the retry topic.
 func (c *BatchConsumer) sendToRetry(ctx context.Context, events []*domain.Event, originalMessages []kafka.Message) {
 	if c.retryWriter == nil {
 		c.logger.Warn("retry writer not configured, sending to dead letter",
 			slog.Int("event_count", len(events)),
 		)
-		c.sendToDead(ctx, events)
+		c.sendToDead(ctx, events, DeadLetterPermanentInsertError)
 		return
 	}
 
@@ -344,7 +1100,7 @@ func (c *BatchConsumer) sendToRetry(ctx context.Context, events []*domain.Event,
 				slog.String("event_id", event.EventID.String()),
 				slog.Int("retry_count", retryCount),
 			)
-			c.sendSingleToDead(ctx, event)
+			c.sendSingleToDead(ctx, event, DeadLetterMaxRetriesExceeded)
 			continue
 		}
 
@@ -358,6 +1114,12 @@ func (c *BatchConsumer) sendToRetry(ctx context.Context, events []*domain.Event,
 				{Key: "original_timestamp", Value: []byte(event.Timestamp.Format(time.RFC3339Nano))},
 			},
 		}
+		if event.Op != "" {
+			msg.Headers = append(msg.Headers, kafka.Header{Key: opHeader, Value: []byte(event.Op)})
+		}
+		if event.Confirm {
+			msg.Headers = append(msg.Headers, kafka.Header{Key: confirmHeader, Value: []byte("true")})
+		}
 		retryMessages = append(retryMessages, msg)
 	}
 
@@ -372,7 +1134,7 @@ func (c *BatchConsumer) sendToRetry(ctx context.Context, events []*domain.Event,
 			slog.String("error", err.Error()),
 		)
 		kafkaRetryEvents.WithLabelValues("error").Add(float64(len(retryMessages)))
-		c.sendToDead(ctx, events)
+		c.sendToDead(ctx, events, DeadLetterPermanentInsertError)
 		return
 	}
 
@@ -392,14 +1154,42 @@ func (c *BatchConsumer) sendToRetry(ctx context.Context, events []*domain.Event,
 }
 
 // sendToDead sends events to the dead letter queue.
-func (c *BatchConsumer) sendToDead(ctx context.Context, events []*domain.Event) {
+func (c *BatchConsumer) sendToDead(ctx context.Context, events []*domain.Event, reason DeadLetterReason) {
+	for _, event := range events {
+		c.sendSingleToDead(ctx, event, reason)
+	}
+}
+
+// sendRejectedToDead dead-letters the events a partial insert rejected and
+// returns the batch's remaining, stored events.
+func (c *BatchConsumer) sendRejectedToDead(ctx context.Context, events []*domain.Event, pe *domain.PartialInsertError) []*domain.Event {
+	rejected := make(map[*domain.Event]bool, len(pe.Rejected))
+	for _, ue := range pe.Rejected {
+		rejected[ue.Event] = true
+		c.sendSingleToDeadWithCause(ctx, ue.Event, DeadLetterUnstorableEvent, ue)
+	}
+
+	stored := make([]*domain.Event, 0, len(events))
 	for _, event := range events {
-		c.sendSingleToDead(ctx, event)
+		if !rejected[event] {
+			stored = append(stored, event)
+		}
 	}
+	return stored
+}
+
+// sendSingleToDead sends a single event to the dead letter queue, tagged with
+// reason and the retention class configured for it.
+func (c *BatchConsumer) sendSingleToDead(ctx context.Context, event *domain.Event, reason DeadLetterReason) {
+	c.sendSingleToDeadWithCause(ctx, event, reason, nil)
 }
 
-// sendSingleToDead sends a single event to the dead letter queue.
-func (c *BatchConsumer) sendSingleToDead(ctx context.Context, event *domain.Event) {
+// sendSingleToDeadWithCause is sendSingleToDead with the error that made the
+// event undeliverable, recorded in the dead letter payload when non-nil.
+func (c *BatchConsumer) sendSingleToDeadWithCause(ctx context.Context, event *domain.Event, reason DeadLetterReason, cause error) {
+	// The event will not be inserted whether or not the dead letter write succeeds
+	c.publishStatus(ctx, []*domain.Event{event}, domain.EventStatusFailed)
+
 	if c.deadWriter == nil {
 		c.logger.Error("dead letter writer not configured, event lost",
 			slog.String("event_id", event.EventID.String()),
@@ -408,6 +1198,14 @@ func (c *BatchConsumer) sendSingleToDead(ctx context.Context, event *domain.Even
 	}
 
 	value, err := event.ToKafkaMessage()
+	metadataDropped := false
+	if err != nil {
+		// Keep the rest of the event reviewable when its metadata cannot be serialized
+		stripped := *event
+		stripped.Metadata = nil
+		value, err = stripped.ToKafkaMessage()
+		metadataDropped = true
+	}
 	if err != nil {
 		c.logger.Error("failed to serialize event for dead letter",
 			slog.String("event_id", event.EventID.String()),
@@ -417,14 +1215,22 @@ func (c *BatchConsumer) sendSingleToDead(ctx context.Context, event *domain.Even
 	}
 
 	// Include failure metadata
+	retention := c.retentionFor(reason)
 	failureInfo := map[string]interface{}{
 		"event":      json.RawMessage(value),
-		"failed_at":  time.Now().Format(time.RFC3339Nano),
-		"reason":     "max_retries_exceeded_or_permanent_failure",
+		"failed_at":  c.clock.Now().Format(time.RFC3339Nano),
+		"reason":     string(reason),
+		"retention":  retention,
 		"event_id":   event.EventID.String(),
 		"match_id":   event.MatchID,
 		"event_type": string(event.EventType),
 	}
+	if cause != nil {
+		failureInfo["error"] = cause.Error()
+	}
+	if metadataDropped {
+		failureInfo["metadata_dropped"] = true
+	}
 
 	deadValue, err := json.Marshal(failureInfo)
 	if err != nil {
@@ -435,15 +1241,13 @@ func (c *BatchConsumer) sendSingleToDead(ctx context.Context, event *domain.Even
 		deadValue = value // Fall back to just the event
 	}
 
-	msg := kafka.Message{
-		Key:   []byte(event.MatchID),
-		Value: deadValue,
-		Headers: []kafka.Header{
-			{Key: "event_type", Value: []byte(string(event.EventType))},
-			{Key: "event_id", Value: []byte(event.EventID.String())},
-			{Key: "failed_at", Value: []byte(time.Now().Format(time.RFC3339Nano))},
-		},
-	}
+	msg := c.deadLetterMessage([]byte(event.MatchID), deadValue, []kafka.Header{
+		{Key: "event_type", Value: []byte(string(event.EventType))},
+		{Key: "event_id", Value: []byte(event.EventID.String())},
+		{Key: "failed_at", Value: []byte(c.clock.Now().Format(time.RFC3339Nano))},
+		{Key: "reason", Value: []byte(reason)},
+		{Key: "retention", Value: []byte(retention)},
+	})
 
 	err = c.deadWriter.WriteMessages(ctx, msg)
 	if err != nil {
@@ -461,6 +1265,109 @@ func (c *BatchConsumer) sendSingleToDead(ctx context.Context, event *domain.Even
 	kafkaDeadLetterEvents.Inc()
 }
 
+// sendRawToDead sends an unparseable message to the dead letter queue.
+// The raw payload is preserved byte-for-byte (base64-encoded in JSON) for forensics.
+func (c *BatchConsumer) sendRawToDead(ctx context.Context, msg kafka.Message, parseErr error) {
+	if c.deadWriter == nil {
+		c.logger.Error("dead letter writer not configured, unparseable message lost",
+			slog.Int64("offset", msg.Offset),
+			slog.Int("partition", msg.Partition),
+		)
+		return
+	}
+
+	failedAt := c.clock.Now().Format(time.RFC3339Nano)
+	retention := c.retentionFor(DeadLetterParseError)
+	failureInfo := map[string]interface{}{
+		"raw_payload": msg.Value,
+		"failed_at":   failedAt,
+		"reason":      string(DeadLetterParseError),
+		"retention":   retention,
+		"error":       parseErr.Error(),
+		"topic":       msg.Topic,
+		"partition":   msg.Partition,
+		"offset":      msg.Offset,
+	}
+
+	deadValue, err := json.Marshal(failureInfo)
+	if err != nil {
+		c.logger.Error("failed to marshal dead letter metadata for unparseable message",
+			slog.Int64("offset", msg.Offset),
+			slog.String("error", err.Error()),
+		)
+		deadValue = msg.Value // Fall back to just the raw payload
+	}
+
+	deadMsg := c.deadLetterMessage(msg.Key, deadValue, []kafka.Header{
+		{Key: "failed_at", Value: []byte(failedAt)},
+		{Key: "reason", Value: []byte(DeadLetterParseError)},
+		{Key: "retention", Value: []byte(retention)},
+	})
+
+	if err := c.deadWriter.WriteMessages(ctx, deadMsg); err != nil {
+		c.logger.Error("failed to write unparseable message to dead letter queue",
+			slog.Int64("offset", msg.Offset),
+			slog.Int("partition", msg.Partition),
+			slog.String("error", err.Error()),
+		)
+		return
+	}
+
+	c.logger.Warn("unparseable message sent to dead letter queue",
+		slog.Int64("offset", msg.Offset),
+		slog.Int("partition", msg.Partition),
+	)
+	kafkaParseDeadLetters.Inc()
+}
+
+// Drain flushes the in-flight batch ahead of a partition revocation, so events
+// from partitions that are about to move are inserted and committed while this
+// consumer still owns them. A flush already under way is waited for first, so
+// its commit also lands before the partitions are released.
+func (c *BatchConsumer) Drain(ctx context.Context) {
+	c.flushWithContext(ctx)
+}
+
+// insertBatch stores events, going through UpsertBatch when the batch holds an
+// upsert and the repository supports it. Otherwise upserts are inserted like
+// any other event, leaving earlier versions stored.
+func (c *BatchConsumer) insertBatch(ctx context.Context, events []*domain.Event) error {
+	if upserter, ok := c.repository.(Upserter); ok && slices.ContainsFunc(events, (*domain.Event).IsUpsert) {
+		return upserter.UpsertBatch(ctx, events)
+	}
+	return c.repository.InsertBatch(ctx, events)
+}
+
+// dropUnowned removes batched events whose partition the owner no longer holds.
+func (c *BatchConsumer) dropUnowned(owner PartitionOwner) {
+	c.batchLock.Lock()
+	defer c.batchLock.Unlock()
+
+	kept, keptBytes := 0, 0
+	for i, msg := range c.messages {
+		if !owner.Owns(msg.Partition) {
+			continue
+		}
+		c.batch[kept] = c.batch[i]
+		c.messages[kept] = msg
+		kept++
+		keptBytes += len(msg.Value)
+	}
+
+	dropped := len(c.messages) - kept
+	if dropped == 0 {
+		return
+	}
+	c.batch = c.batch[:kept]
+	c.messages = c.messages[:kept]
+	c.batchBytes = keptBytes
+
+	c.logger.Info("dropped in-flight events from revoked partitions",
+		slog.Int("event_count", dropped),
+	)
+	kafkaEventsConsumed.WithLabelValues("revoked").Add(float64(dropped))
+}
+
 // Stop signals the consumer to stop and waits for it to finish.
 func (c *BatchConsumer) Stop() {
 	c.logger.Info("stopping batch consumer")
@@ -499,15 +1406,22 @@ type ReaderConfig struct {
 	StartOffset    int64
 }
 
+// Default fetch settings for the events topic consumer.
+const (
+	DefaultFetchMinBytes = 1
+	DefaultFetchMaxBytes = 10e6 // 10MB
+	DefaultFetchMaxWait  = 5 * time.Second
+)
+
 // DefaultReaderConfig returns default configuration for the events topic consumer.
 func DefaultReaderConfig(brokers []string, topic, groupID string) ReaderConfig {
 	return ReaderConfig{
 		Brokers:        brokers,
 		Topic:          topic,
 		GroupID:        groupID,
-		MinBytes:       1,
-		MaxBytes:       10e6, // 10MB
-		MaxWait:        5 * time.Second,
+		MinBytes:       DefaultFetchMinBytes,
+		MaxBytes:       DefaultFetchMaxBytes,
+		MaxWait:        DefaultFetchMaxWait,
 		CommitInterval: time.Second,
 		StartOffset:    kafka.FirstOffset,
 	}
@@ -1,13 +1,22 @@
 package kafka
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
+	"io"
+	"log/slog"
+	"math"
+	"slices"
+	"strings"
 	"sync"
 	"testing"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
 	"github.com/segmentio/kafka-go"
 
 	"fanfinity/internal/domain"
@@ -38,6 +47,62 @@ func (m *mockRepository) getInsertedEvents() []*domain.Event {
 	return m.insertedBatch
 }
 
+// mockReader is a mock implementation of MessageReader that serves queued messages.
+type mockReader struct {
+	mu        sync.Mutex
+	messages  []kafka.Message
+	committed []kafka.Message
+}
+
+func (m *mockReader) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	m.mu.Lock()
+	if len(m.messages) > 0 {
+		msg := m.messages[0]
+		m.messages = m.messages[1:]
+		m.mu.Unlock()
+		return msg, nil
+	}
+	m.mu.Unlock()
+	<-ctx.Done()
+	return kafka.Message{}, ctx.Err()
+}
+
+func (m *mockReader) CommitMessages(ctx context.Context, msgs ...kafka.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.committed = append(m.committed, msgs...)
+	return nil
+}
+
+func (m *mockReader) Stats() kafka.ReaderStats {
+	return kafka.ReaderStats{Topic: "events"}
+}
+
+func (m *mockReader) getCommitted() []kafka.Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]kafka.Message(nil), m.committed...)
+}
+
+// mockWriter is a mock implementation of MessageWriter that records written messages.
+type mockWriter struct {
+	mu       sync.Mutex
+	messages []kafka.Message
+}
+
+func (m *mockWriter) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.messages = append(m.messages, msgs...)
+	return nil
+}
+
+func (m *mockWriter) getMessages() []kafka.Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]kafka.Message(nil), m.messages...)
+}
+
 func TestNewBatchConsumer_DefaultValues(t *testing.T) {
 	cfg := BatchConsumerConfig{
 		// Leave defaults
@@ -97,6 +162,88 @@ func TestNewBatchConsumer_InitializesBatchSlices(t *testing.T) {
 	}
 }
 
+func TestBatchConsumer_AdaptiveFlush_DisabledByDefault(t *testing.T) {
+	consumer := NewBatchConsumer(BatchConsumerConfig{
+		FlushInterval: 2 * time.Second,
+	})
+
+	for i := 0; i < 5; i++ {
+		consumer.adaptFlushInterval(true, consumer.batchSize)
+	}
+
+	if consumer.currentInterval != 2*time.Second {
+		t.Errorf("expected fixed interval 2s, got %v", consumer.currentInterval)
+	}
+}
+
+func TestBatchConsumer_AdaptiveFlush(t *testing.T) {
+	tests := []struct {
+		name          string
+		sizeTriggered bool
+		pending       int
+		expected      time.Duration
+	}{
+		{
+			name:          "high arrival rate lengthens up to max",
+			sizeTriggered: true,
+			pending:       100,
+			expected:      8 * time.Second,
+		},
+		{
+			name:     "low arrival rate shortens down to min",
+			pending:  3,
+			expected: 500 * time.Millisecond,
+		},
+		{
+			name:     "idle ticks lengthen up to max",
+			pending:  0,
+			expected: 8 * time.Second,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			consumer := NewBatchConsumer(BatchConsumerConfig{
+				BatchSize:        100,
+				FlushInterval:    2 * time.Second,
+				AdaptiveFlush:    true,
+				MinFlushInterval: 500 * time.Millisecond,
+				MaxFlushInterval: 8 * time.Second,
+			})
+
+			prev := consumer.currentInterval
+			for i := 0; i < 10; i++ {
+				consumer.adaptFlushInterval(tt.sizeTriggered, tt.pending)
+				if consumer.currentInterval < 500*time.Millisecond || consumer.currentInterval > 8*time.Second {
+					t.Fatalf("interval %v escaped bounds", consumer.currentInterval)
+				}
+				if i == 0 && consumer.currentInterval == prev {
+					t.Error("expected interval to change after first flush")
+				}
+			}
+
+			if consumer.currentInterval != tt.expected {
+				t.Errorf("expected interval %v, got %v", tt.expected, consumer.currentInterval)
+			}
+		})
+	}
+}
+
+func TestBatchConsumer_AdaptiveFlush_ClampsBoundsToInterval(t *testing.T) {
+	consumer := NewBatchConsumer(BatchConsumerConfig{
+		FlushInterval:    100 * time.Millisecond,
+		AdaptiveFlush:    true,
+		MaxFlushInterval: 50 * time.Millisecond,
+	})
+
+	if consumer.minFlushInterval != 100*time.Millisecond {
+		t.Errorf("expected min clamped to 100ms, got %v", consumer.minFlushInterval)
+	}
+	if consumer.maxFlushInterval != 100*time.Millisecond {
+		t.Errorf("expected max clamped to 100ms, got %v", consumer.maxFlushInterval)
+	}
+}
+
 func TestNewReader(t *testing.T) {
 	cfg := ReaderConfig{
 		Brokers:        []string{"localhost:9092"},
@@ -197,6 +344,137 @@ func TestBatchConsumer_FlushWithEvents(t *testing.T) {
 	}
 }
 
+// recordingLagPublisher records the lags published after each flush.
+type recordingLagPublisher struct {
+	lags map[string]time.Duration
+}
+
+func (p *recordingLagPublisher) PublishMatchLag(ctx context.Context, lags map[string]time.Duration) error {
+	p.lags = lags
+	return nil
+}
+
+func TestBatchConsumer_FlushPublishesMatchLag(t *testing.T) {
+	publisher := &recordingLagPublisher{}
+	consumer := NewBatchConsumer(BatchConsumerConfig{
+		Reader:       &mockReader{},
+		Repository:   &mockRepository{},
+		BatchSize:    10,
+		LagPublisher: publisher,
+	})
+
+	now := time.Now()
+	for _, event := range []struct {
+		matchID string
+		age     time.Duration
+	}{
+		{"match-busy", 90 * time.Second},
+		{"match-busy", 10 * time.Second},
+		{"match-quiet", time.Second},
+	} {
+		// The client timestamp is far older; lag counts from when it was produced
+		consumer.batch = append(consumer.batch, &domain.Event{
+			EventID:   uuid.New(),
+			MatchID:   event.matchID,
+			EventType: domain.EventTypeGoal,
+			Timestamp: now.Add(-time.Hour),
+			TeamID:    1,
+		})
+		consumer.messages = append(consumer.messages, kafka.Message{
+			Key:  []byte(event.matchID),
+			Time: now.Add(-event.age),
+		})
+	}
+
+	consumer.flushWithContext(context.Background())
+
+	if len(publisher.lags) != 2 {
+		t.Fatalf("expected lag for 2 matches, got %v", publisher.lags)
+	}
+	// A match's lag is its oldest message's delay
+	if lag := publisher.lags["match-busy"]; lag < 90*time.Second || lag > 95*time.Second {
+		t.Errorf("expected match-busy lag of about 90s, got %v", lag)
+	}
+	if lag := publisher.lags["match-quiet"]; lag < time.Second || lag > 6*time.Second {
+		t.Errorf("expected match-quiet lag of about 1s, got %v", lag)
+	}
+
+	// Once caught up the reported matches are published with zero lag, once
+	consumer.publishCaughtUp(context.Background())
+	if len(publisher.lags) != 2 || publisher.lags["match-busy"] != 0 || publisher.lags["match-quiet"] != 0 {
+		t.Errorf("expected zero lag for both matches once caught up, got %v", publisher.lags)
+	}
+	publisher.lags = nil
+	consumer.publishCaughtUp(context.Background())
+	if publisher.lags != nil {
+		t.Errorf("expected nothing more to publish, got %v", publisher.lags)
+	}
+}
+
+// recordingStatusPublisher records the event statuses published by the consumer.
+type recordingStatusPublisher struct {
+	updates []domain.EventStatusUpdate
+}
+
+func (p *recordingStatusPublisher) PublishEventStatus(ctx context.Context, updates []domain.EventStatusUpdate) error {
+	p.updates = append(p.updates, updates...)
+	return nil
+}
+
+func TestBatchConsumer_PublishesEventStatus(t *testing.T) {
+	newEvent := func(confirm bool) *domain.Event {
+		return &domain.Event{
+			EventID:   uuid.New(),
+			MatchID:   "match-123",
+			EventType: domain.EventTypeGoal,
+			Timestamp: time.Now(),
+			TeamID:    1,
+			Confirm:   confirm,
+		}
+	}
+
+	t.Run("persisted after insert", func(t *testing.T) {
+		publisher := &recordingStatusPublisher{}
+		consumer := NewBatchConsumer(BatchConsumerConfig{
+			Repository:      &mockRepository{},
+			BatchSize:       10,
+			StatusPublisher: publisher,
+		})
+		confirmed := newEvent(true)
+		consumer.batch = append(consumer.batch, newEvent(false), confirmed)
+
+		consumer.flushWithContext(context.Background())
+
+		if len(publisher.updates) != 1 {
+			t.Fatalf("expected 1 status update for the confirmed event, got %v", publisher.updates)
+		}
+		update := publisher.updates[0]
+		if update.EventID != confirmed.EventID || update.MatchID != "match-123" || update.Status != domain.EventStatusPersisted {
+			t.Errorf("unexpected status update: %+v", update)
+		}
+	})
+
+	t.Run("failed when dead-lettered", func(t *testing.T) {
+		publisher := &recordingStatusPublisher{}
+		consumer := NewBatchConsumer(BatchConsumerConfig{
+			Repository:      &mockRepository{},
+			DeadWriter:      &mockWriter{},
+			BatchSize:       10,
+			StatusPublisher: publisher,
+		})
+		confirmed := newEvent(true)
+		consumer.sendSingleToDead(context.Background(), newEvent(false), DeadLetterMaxRetriesExceeded)
+		consumer.sendSingleToDead(context.Background(), confirmed, DeadLetterMaxRetriesExceeded)
+
+		if len(publisher.updates) != 1 {
+			t.Fatalf("expected 1 status update for the confirmed event, got %v", publisher.updates)
+		}
+		if update := publisher.updates[0]; update.EventID != confirmed.EventID || update.Status != domain.EventStatusFailed {
+			t.Errorf("unexpected status update: %+v", update)
+		}
+	})
+}
+
 func TestBatchConsumer_FlushError(t *testing.T) {
 	repo := &mockRepository{
 		insertErr: errors.New("insert failed"),
@@ -224,6 +502,66 @@ func TestBatchConsumer_FlushError(t *testing.T) {
 	}
 }
 
+func TestBatchConsumer_FlushErrorSampledEvent(t *testing.T) {
+	newEvent := func() *domain.Event {
+		return &domain.Event{
+			EventID:   uuid.New(),
+			MatchID:   "match-123",
+			EventType: domain.EventTypeGoal,
+			Timestamp: time.Now(),
+			TeamID:    1,
+			PlayerID:  "player-10",
+			Metadata:  map[string]interface{}{"note": strings.Repeat("x", 500)},
+		}
+	}
+
+	failureLog := func(t *testing.T, sample bool) map[string]any {
+		t.Helper()
+		var logs bytes.Buffer
+		consumer := NewBatchConsumer(BatchConsumerConfig{
+			Repository:                &mockRepository{insertErr: errors.New("insert failed")},
+			BatchSize:                 10,
+			Logger:                    slog.New(slog.NewJSONHandler(&logs, nil)),
+			LogFailedEventSample:      sample,
+			FailedEventSampleMaxBytes: 100,
+		})
+		consumer.batch = append(consumer.batch, newEvent(), newEvent())
+
+		consumer.flushWithContext(context.Background())
+
+		for _, line := range strings.Split(strings.TrimSpace(logs.String()), "\n") {
+			var record map[string]any
+			if err := json.Unmarshal([]byte(line), &record); err != nil {
+				t.Fatalf("failed to decode log line %q: %v", line, err)
+			}
+			if record["msg"] == "failed to insert batch" {
+				return record
+			}
+		}
+		t.Fatal("expected a batch failure log")
+		return nil
+	}
+
+	t.Run("enabled", func(t *testing.T) {
+		sample, ok := failureLog(t, true)["sampled_event"].(string)
+		if !ok {
+			t.Fatal("expected the failure log to include a sampled event")
+		}
+		if !strings.Contains(sample, `"matchId":"match-123"`) {
+			t.Errorf("expected the sample to be the serialized event, got %q", sample)
+		}
+		if !strings.HasSuffix(sample, "...(truncated)") || len(sample) > 100+len("...(truncated)") {
+			t.Errorf("expected the sample to be cut to 100 bytes, got %d bytes", len(sample))
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		if sample, ok := failureLog(t, false)["sampled_event"]; ok {
+			t.Errorf("expected no sampled event by default, got %v", sample)
+		}
+	})
+}
+
 func TestBatchConsumer_Stop(t *testing.T) {
 	consumer := NewBatchConsumer(BatchConsumerConfig{
 		BatchSize: 10,
@@ -283,7 +621,216 @@ func TestBatchConsumer_SendToDead_NoDeadWriter(t *testing.T) {
 	}
 
 	// Should not panic when dead writer is nil
-	consumer.sendSingleToDead(context.Background(), event)
+	consumer.sendSingleToDead(context.Background(), event, DeadLetterMaxRetriesExceeded)
+}
+
+func TestBatchConsumer_ParseErrorSentToDead(t *testing.T) {
+	raw := []byte(`{"eventId": not-json`)
+	reader := &mockReader{
+		messages: []kafka.Message{
+			{Topic: "events", Partition: 2, Offset: 42, Key: []byte("match-123"), Value: raw},
+		},
+	}
+	dead := &mockWriter{}
+	consumer := NewBatchConsumer(BatchConsumerConfig{
+		Reader:     reader,
+		Repository: &mockRepository{},
+		DeadWriter: dead,
+		BatchSize:  10,
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		consumer.Start(ctx)
+		close(stopped)
+	}()
+
+	deadline := time.After(2 * time.Second)
+	for len(reader.getCommitted()) == 0 {
+		select {
+		case <-deadline:
+			cancel()
+			t.Fatal("timed out waiting for malformed message to be committed")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	cancel()
+	<-stopped
+
+	written := dead.getMessages()
+	if len(written) != 1 {
+		t.Fatalf("expected 1 dead letter message, got %d", len(written))
+	}
+	if string(written[0].Key) != "match-123" {
+		t.Errorf("expected key match-123, got %s", written[0].Key)
+	}
+
+	var info struct {
+		RawPayload []byte `json:"raw_payload"`
+		Reason     string `json:"reason"`
+		Error      string `json:"error"`
+		Partition  int    `json:"partition"`
+		Offset     int64  `json:"offset"`
+	}
+	if err := json.Unmarshal(written[0].Value, &info); err != nil {
+		t.Fatalf("failed to decode dead letter payload: %v", err)
+	}
+	if string(info.RawPayload) != string(raw) {
+		t.Errorf("expected raw payload %q, got %q", raw, info.RawPayload)
+	}
+	if info.Reason != "parse_error" {
+		t.Errorf("expected reason parse_error, got %s", info.Reason)
+	}
+	if info.Error == "" {
+		t.Error("expected parse error to be recorded")
+	}
+	if info.Partition != 2 || info.Offset != 42 {
+		t.Errorf("expected partition 2 offset 42, got %d/%d", info.Partition, info.Offset)
+	}
+}
+
+// processingDelaySnapshot returns the sample count and sum of the processing delay histogram.
+func processingDelaySnapshot(t *testing.T) (uint64, float64) {
+	t.Helper()
+	var m dto.Metric
+	if err := eventProcessingDelay.Write(&m); err != nil {
+		t.Fatalf("failed to read histogram: %v", err)
+	}
+	return m.GetHistogram().GetSampleCount(), m.GetHistogram().GetSampleSum()
+}
+
+func TestObserveProcessingDelay(t *testing.T) {
+	now := time.Date(2024, 1, 15, 15, 0, 0, 0, time.UTC)
+	events := []*domain.Event{
+		{EventID: uuid.New(), Timestamp: now.Add(-90 * time.Second)},
+		{EventID: uuid.New(), Timestamp: now.Add(-30 * time.Second)},
+		{EventID: uuid.New(), Timestamp: now.Add(10 * time.Second)}, // clock skew, clamped to 0
+	}
+
+	countBefore, sumBefore := processingDelaySnapshot(t)
+	observeProcessingDelay(events, now)
+	countAfter, sumAfter := processingDelaySnapshot(t)
+
+	if got := countAfter - countBefore; got != 3 {
+		t.Errorf("expected 3 observations, got %d", got)
+	}
+	// The histogram is shared with other tests, so allow for float rounding
+	if got := sumAfter - sumBefore; math.Abs(got-120) > 1e-6 {
+		t.Errorf("expected total delay 120s, got %v", got)
+	}
+}
+
+func TestBatchConsumer_FlushRecordsProcessingDelay(t *testing.T) {
+	now := time.Date(2024, 1, 15, 15, 0, 0, 0, time.UTC)
+	repo := &mockRepository{}
+	consumer := NewBatchConsumer(BatchConsumerConfig{
+		Reader:     &mockReader{},
+		Repository: repo,
+		BatchSize:  10,
+		Clock:      domain.NewFixedClock(now),
+	})
+	consumer.batch = append(consumer.batch, &domain.Event{
+		EventID:   uuid.New(),
+		MatchID:   "match-123",
+		EventType: domain.EventTypeGoal,
+		Timestamp: now.Add(-2 * time.Minute),
+		TeamID:    1,
+	})
+
+	countBefore, sumBefore := processingDelaySnapshot(t)
+	consumer.flushWithContext(context.Background())
+	countAfter, sumAfter := processingDelaySnapshot(t)
+
+	if got := countAfter - countBefore; got != 1 {
+		t.Fatalf("expected 1 observation, got %d", got)
+	}
+	if got := sumAfter - sumBefore; math.Abs(got-120) > 1e-6 {
+		t.Errorf("expected delay of 120s, got %v", got)
+	}
+}
+
+func TestBatchConsumer_DryRunSkipsInsertAndCommit(t *testing.T) {
+	var messages []kafka.Message
+	for i := 0; i < 3; i++ {
+		value, err := (&domain.Event{
+			EventID:   uuid.New(),
+			MatchID:   "match-123",
+			EventType: domain.EventTypePass,
+			Timestamp: time.Now(),
+			TeamID:    1,
+		}).ToKafkaMessage()
+		if err != nil {
+			t.Fatalf("failed to serialize event: %v", err)
+		}
+		messages = append(messages, kafka.Message{Topic: "events", Offset: int64(i), Value: value})
+	}
+	messages = append(messages, kafka.Message{Topic: "events", Offset: 3, Value: []byte(`not-json`)})
+
+	reader := &mockReader{messages: messages}
+	repo := &mockRepository{}
+	dead := &mockWriter{}
+	retry := &mockWriter{}
+	consumer := NewBatchConsumer(BatchConsumerConfig{
+		Reader:      reader,
+		Repository:  repo,
+		RetryWriter: retry,
+		DeadWriter:  dead,
+		BatchSize:   2,
+		DryRun:      true,
+	})
+
+	var before dto.Metric
+	if err := kafkaEventsConsumed.WithLabelValues("would_insert").Write(&before); err != nil {
+		t.Fatalf("failed to read counter: %v", err)
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		consumer.Start(context.Background())
+		close(stopped)
+	}()
+
+	deadline := time.After(2 * time.Second)
+	for {
+		reader.mu.Lock()
+		remaining := len(reader.messages)
+		reader.mu.Unlock()
+		if remaining == 0 {
+			break
+		}
+		select {
+		case <-deadline:
+			t.Fatal("timed out waiting for messages to be fetched")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	// Stop flushes the partial batch and must still return promptly
+	consumer.Stop()
+	select {
+	case <-stopped:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected dry-run consumer to exit on shutdown")
+	}
+
+	if repo.insertCalled {
+		t.Error("expected repository not to be called in dry-run mode")
+	}
+	if committed := reader.getCommitted(); len(committed) != 0 {
+		t.Errorf("expected no commits in dry-run mode, got %d", len(committed))
+	}
+	if len(dead.getMessages()) != 0 || len(retry.getMessages()) != 0 {
+		t.Error("expected nothing written to retry or dead letter topics in dry-run mode")
+	}
+
+	var after dto.Metric
+	if err := kafkaEventsConsumed.WithLabelValues("would_insert").Write(&after); err != nil {
+		t.Fatalf("failed to read counter: %v", err)
+	}
+	if got := after.GetCounter().GetValue() - before.GetCounter().GetValue(); got != 3 {
+		t.Errorf("expected 3 would_insert events, got %v", got)
+	}
 }
 
 func BenchmarkBatchConsumer_FlushBatch(b *testing.B) {
@@ -312,3 +859,582 @@ func BenchmarkBatchConsumer_FlushBatch(b *testing.B) {
 		consumer.flushWithContext(context.Background())
 	}
 }
+
+// BenchmarkBatchConsumer_FillAndFlush compares allocating new batch slices on
+// every flush with reusing the flushed batch's arrays.
+func BenchmarkBatchConsumer_FillAndFlush(b *testing.B) {
+	const batchSize = 1000
+	events := make([]*domain.Event, batchSize)
+	for i := range events {
+		events[i] = createTestEvent()
+	}
+
+	for _, reuse := range []bool{false, true} {
+		name := "realloc"
+		if reuse {
+			name = "reuse"
+		}
+		b.Run(name, func(b *testing.B) {
+			consumer := NewBatchConsumer(BatchConsumerConfig{
+				Reader:            &discardCommitReader{},
+				Repository:        &discardRepository{},
+				BatchSize:         batchSize,
+				Logger:            slog.New(slog.NewTextHandler(io.Discard, nil)),
+				ReuseBatchBuffers: reuse,
+			})
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				for _, event := range events {
+					consumer.batch = append(consumer.batch, event)
+					consumer.messages = append(consumer.messages, kafka.Message{})
+				}
+				consumer.flushWithContext(context.Background())
+			}
+		})
+	}
+}
+
+// discardCommitReader is a mockReader that does not record commits.
+type discardCommitReader struct {
+	mockReader
+}
+
+func (r *discardCommitReader) CommitMessages(ctx context.Context, msgs ...kafka.Message) error {
+	return nil
+}
+
+// discardRepository accepts batches without keeping them.
+type discardRepository struct{}
+
+func (discardRepository) InsertBatch(ctx context.Context, events []*domain.Event) error {
+	return nil
+}
+
+// batchRecordingRepository keeps a copy of each inserted batch.
+type batchRecordingRepository struct {
+	batches [][]*domain.Event
+}
+
+func (r *batchRecordingRepository) InsertBatch(ctx context.Context, events []*domain.Event) error {
+	r.batches = append(r.batches, slices.Clone(events))
+	return nil
+}
+
+func TestBatchConsumer_ReuseBatchBuffers(t *testing.T) {
+	repo := &batchRecordingRepository{}
+	consumer := NewBatchConsumer(BatchConsumerConfig{
+		Reader:            &mockReader{},
+		Repository:        repo,
+		BatchSize:         4,
+		ReuseBatchBuffers: true,
+	})
+
+	add := func(events ...*domain.Event) {
+		for _, event := range events {
+			consumer.batch = append(consumer.batch, event)
+			consumer.messages = append(consumer.messages, kafka.Message{Key: []byte(event.EventID.String())})
+		}
+	}
+
+	first := []*domain.Event{createTestEvent(), createTestEvent(), createTestEvent()}
+	add(first...)
+	firstArray := &consumer.batch[:1][0]
+	consumer.flushWithContext(context.Background())
+
+	// The flushed array comes back as the spare, cleared of the old events
+	if consumer.spareBatch == nil || &consumer.spareBatch[:1][0] != firstArray {
+		t.Fatal("expected the flushed batch array to be kept for reuse")
+	}
+	for i, event := range consumer.spareBatch[:cap(consumer.spareBatch)] {
+		if event != nil {
+			t.Errorf("expected spare slot %d to be cleared, got %v", i, event.EventID)
+		}
+	}
+
+	second := []*domain.Event{createTestEvent()}
+	add(second...)
+	consumer.flushWithContext(context.Background())
+
+	third := []*domain.Event{createTestEvent(), createTestEvent()}
+	add(third...)
+	if &consumer.batch[:1][0] != firstArray {
+		t.Error("expected the third batch to reuse the first batch's array")
+	}
+	consumer.flushWithContext(context.Background())
+
+	want := [][]*domain.Event{first, second, third}
+	if len(repo.batches) != len(want) {
+		t.Fatalf("expected %d batches, got %d", len(want), len(repo.batches))
+	}
+	for i := range want {
+		if !slices.Equal(repo.batches[i], want[i]) {
+			t.Errorf("batch %d: expected %d events without stale entries, got %d", i, len(want[i]), len(repo.batches[i]))
+		}
+	}
+	if consumer.peakBatchLen != 3 {
+		t.Errorf("expected peak batch length 3, got %d", consumer.peakBatchLen)
+	}
+}
+
+func TestBatchConsumer_ReuseBatchBuffers_DropsGrownArrays(t *testing.T) {
+	consumer := NewBatchConsumer(BatchConsumerConfig{
+		Reader:            &mockReader{},
+		Repository:        &discardRepository{},
+		BatchSize:         2,
+		ReuseBatchBuffers: true,
+	})
+
+	for i := 0; i < 10; i++ {
+		consumer.batch = append(consumer.batch, createTestEvent())
+		consumer.messages = append(consumer.messages, kafka.Message{})
+	}
+	consumer.flushWithContext(context.Background())
+
+	if consumer.spareBatch != nil {
+		t.Errorf("expected an array grown to %d to be released, not reused", cap(consumer.spareBatch))
+	}
+}
+
+// ownerReader is a mockReader that reports partition ownership.
+type ownerReader struct {
+	mockReader
+	owned map[int]bool
+}
+
+func (r *ownerReader) Owns(partition int) bool {
+	return r.owned[partition]
+}
+
+func TestBatchConsumer_DrainCommitsOnlyOwnedPartitions(t *testing.T) {
+	reader := &ownerReader{owned: map[int]bool{0: true}}
+	repo := &mockRepository{}
+	consumer := NewBatchConsumer(BatchConsumerConfig{
+		Reader:     reader,
+		Repository: repo,
+		BatchSize:  10,
+	})
+
+	// Partition 1 was revoked after these messages were fetched
+	for i, partition := range []int{0, 1, 0, 1} {
+		event := &domain.Event{
+			EventID:   uuid.New(),
+			MatchID:   "match-123",
+			EventType: domain.EventTypePass,
+			Timestamp: time.Now(),
+			TeamID:    1,
+		}
+		consumer.batch = append(consumer.batch, event)
+		consumer.messages = append(consumer.messages, kafka.Message{Partition: partition, Offset: int64(i)})
+	}
+
+	consumer.Drain(context.Background())
+
+	if got := len(repo.getInsertedEvents()); got != 2 {
+		t.Errorf("expected 2 events from the owned partition inserted, got %d", got)
+	}
+	committed := reader.getCommitted()
+	if len(committed) != 2 {
+		t.Fatalf("expected 2 committed messages, got %d", len(committed))
+	}
+	for _, msg := range committed {
+		if msg.Partition != 0 {
+			t.Errorf("expected only partition 0 committed, got partition %d", msg.Partition)
+		}
+	}
+	if len(consumer.batch) != 0 || len(consumer.messages) != 0 {
+		t.Error("expected batch to be empty after drain")
+	}
+}
+
+func TestBatchConsumer_DrainWithoutOwnershipFlushesAll(t *testing.T) {
+	reader := &mockReader{}
+	repo := &mockRepository{}
+	consumer := NewBatchConsumer(BatchConsumerConfig{
+		Reader:     reader,
+		Repository: repo,
+	})
+
+	consumer.batch = append(consumer.batch, &domain.Event{EventID: uuid.New(), MatchID: "match-123", EventType: domain.EventTypeGoal, Timestamp: time.Now(), TeamID: 1})
+	consumer.messages = append(consumer.messages, kafka.Message{Partition: 3, Offset: 7})
+
+	consumer.Drain(context.Background())
+
+	if len(repo.getInsertedEvents()) != 1 || len(reader.getCommitted()) != 1 {
+		t.Error("expected a reader without ownership tracking to flush and commit the whole batch")
+	}
+}
+
+// revokingReader is a mockReader that, like GroupReader, skips commits for
+// partitions it no longer owns and releases its partitions after the revoke hook.
+type revokingReader struct {
+	mockReader
+	ownerMu sync.Mutex
+	owned   map[int]bool
+}
+
+func (r *revokingReader) Owns(partition int) bool {
+	r.ownerMu.Lock()
+	defer r.ownerMu.Unlock()
+	return r.owned[partition]
+}
+
+func (r *revokingReader) CommitMessages(ctx context.Context, msgs ...kafka.Message) error {
+	var kept []kafka.Message
+	for _, msg := range msgs {
+		if r.Owns(msg.Partition) {
+			kept = append(kept, msg)
+		}
+	}
+	return r.mockReader.CommitMessages(ctx, kept...)
+}
+
+func (r *revokingReader) revoke(hook func(ctx context.Context)) {
+	hook(context.Background())
+	r.ownerMu.Lock()
+	r.owned = make(map[int]bool)
+	r.ownerMu.Unlock()
+}
+
+// blockingRepository holds InsertBatch until release is closed.
+type blockingRepository struct {
+	mockRepository
+	started chan struct{}
+	release chan struct{}
+}
+
+func (m *blockingRepository) InsertBatch(ctx context.Context, events []*domain.Event) error {
+	m.started <- struct{}{}
+	<-m.release
+	return m.mockRepository.InsertBatch(ctx, events)
+}
+
+func TestBatchConsumer_DrainWaitsForInFlightFlush(t *testing.T) {
+	reader := &revokingReader{owned: map[int]bool{0: true}}
+	repo := &blockingRepository{started: make(chan struct{}, 1), release: make(chan struct{})}
+	consumer := NewBatchConsumer(BatchConsumerConfig{
+		Reader:     reader,
+		Repository: repo,
+		BatchSize:  10,
+	})
+
+	consumer.batch = append(consumer.batch, createTestEvent())
+	consumer.messages = append(consumer.messages, kafka.Message{Partition: 0, Offset: 5})
+
+	// A flush begun by Start is still inserting when the revocation arrives
+	flushed := make(chan struct{})
+	go func() {
+		consumer.flushWithContext(context.Background())
+		close(flushed)
+	}()
+	<-repo.started
+
+	revoked := make(chan struct{})
+	go func() {
+		reader.revoke(consumer.Drain)
+		close(revoked)
+	}()
+
+	select {
+	case <-revoked:
+		t.Fatal("expected the revoke to wait for the in-flight flush")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(repo.release)
+	<-flushed
+	<-revoked
+
+	committed := reader.getCommitted()
+	if len(committed) != 1 || committed[0].Offset != 5 {
+		t.Errorf("expected the in-flight batch to be committed before the revoke, got %+v", committed)
+	}
+}
+
+func TestBatchConsumer_FlushDropsRevokedPartitions(t *testing.T) {
+	reader := &revokingReader{owned: map[int]bool{0: true}}
+	repo := &mockRepository{}
+	consumer := NewBatchConsumer(BatchConsumerConfig{
+		Reader:     reader,
+		Repository: repo,
+		BatchSize:  10,
+	})
+
+	// Fetched while the revoke hook ran, after Drain took the batch
+	reader.revoke(consumer.Drain)
+	consumer.batch = append(consumer.batch, createTestEvent())
+	consumer.messages = append(consumer.messages, kafka.Message{Partition: 0, Offset: 8})
+
+	consumer.flushWithContext(context.Background())
+
+	if got := len(repo.getInsertedEvents()); got != 0 {
+		t.Errorf("expected events from revoked partitions to be dropped, got %d inserted", got)
+	}
+}
+
+func TestCommitOffsets_OwnedPartitionsOnly(t *testing.T) {
+	msgs := []kafka.Message{
+		{Partition: 0, Offset: 4},
+		{Partition: 0, Offset: 9},
+		{Partition: 2, Offset: 1},
+		{Partition: 1, Offset: 12},
+	}
+
+	offsets, skipped := commitOffsets("events", msgs, map[int]bool{0: true, 2: true})
+
+	want := map[int]int64{0: 10, 2: 2}
+	if len(offsets["events"]) != len(want) {
+		t.Fatalf("expected offsets %v, got %v", want, offsets["events"])
+	}
+	for partition, offset := range want {
+		if offsets["events"][partition] != offset {
+			t.Errorf("partition %d: expected commit offset %d, got %d", partition, offset, offsets["events"][partition])
+		}
+	}
+	if skipped != 1 {
+		t.Errorf("expected 1 skipped message from revoked partition 1, got %d", skipped)
+	}
+
+	if offsets, skipped := commitOffsets("events", msgs, nil); offsets != nil || skipped != len(msgs) {
+		t.Errorf("expected nothing to commit with no owned partitions, got %v (skipped %d)", offsets, skipped)
+	}
+}
+
+func TestBatchConsumer_CheckOrderingFlagsOutOfOrderEvents(t *testing.T) {
+	consumer := NewBatchConsumer(BatchConsumerConfig{
+		Reader:        &mockReader{},
+		Repository:    &mockRepository{},
+		BatchSize:     10,
+		CheckOrdering: true,
+	})
+
+	kickoff := time.Date(2024, 1, 15, 14, 0, 0, 0, time.UTC)
+	event := func(matchID string, offset time.Duration) *domain.Event {
+		return &domain.Event{
+			EventID:   uuid.New(),
+			MatchID:   matchID,
+			EventType: domain.EventTypePass,
+			Timestamp: kickoff.Add(offset),
+			TeamID:    1,
+		}
+	}
+
+	var before dto.Metric
+	if err := outOfOrderEvents.Write(&before); err != nil {
+		t.Fatalf("failed to read counter: %v", err)
+	}
+
+	// Batch events the way Start does
+	add := func(e *domain.Event) {
+		consumer.batchLock.Lock()
+		consumer.checkOrdering(e, kafka.Message{})
+		consumer.batch = append(consumer.batch, e)
+		consumer.batchLock.Unlock()
+	}
+	add(event("match-1", time.Minute))
+	add(event("match-2", 0))              // other match, not compared
+	add(event("match-1", 30*time.Second)) // out of order
+	add(event("match-1", 2*time.Minute))
+
+	var after dto.Metric
+	if err := outOfOrderEvents.Write(&after); err != nil {
+		t.Fatalf("failed to read counter: %v", err)
+	}
+	if got := after.GetCounter().GetValue() - before.GetCounter().GetValue(); got != 1 {
+		t.Errorf("expected 1 out-of-order event, got %v", got)
+	}
+
+	// A flush starts a new batch, so earlier timestamps are no longer compared
+	consumer.flushWithContext(context.Background())
+	consumer.batchLock.Lock()
+	_, outOfOrder := consumer.ordering.observe(event("match-1", 0))
+	consumer.batchLock.Unlock()
+	if outOfOrder {
+		t.Error("expected tracker to be reset after a flush")
+	}
+}
+
+func TestOrderingTracker_BoundsTrackedMatches(t *testing.T) {
+	tracker := newOrderingTracker(1)
+	now := time.Now()
+
+	tracker.observe(&domain.Event{MatchID: "match-1", Timestamp: now})
+	tracker.observe(&domain.Event{MatchID: "match-2", Timestamp: now})
+	if len(tracker.lastSeen) != 1 {
+		t.Errorf("expected 1 tracked match, got %d", len(tracker.lastSeen))
+	}
+	if _, outOfOrder := tracker.observe(&domain.Event{MatchID: "match-2", Timestamp: now.Add(-time.Second)}); outOfOrder {
+		t.Error("expected untracked match not to be flagged")
+	}
+}
+
+// histogramSampleCount returns the number of observations recorded by h.
+func histogramSampleCount(t *testing.T, h prometheus.Histogram) uint64 {
+	t.Helper()
+	var m dto.Metric
+	if err := h.Write(&m); err != nil {
+		t.Fatalf("failed to read histogram: %v", err)
+	}
+	return m.GetHistogram().GetSampleCount()
+}
+
+func TestBatchConsumer_ObservesFetchWaitAndInsertTime(t *testing.T) {
+	event := &domain.Event{
+		EventID:   uuid.New(),
+		MatchID:   "match-123",
+		EventType: domain.EventTypeGoal,
+		Timestamp: time.Now(),
+		TeamID:    1,
+	}
+	value, err := event.ToKafkaMessage()
+	if err != nil {
+		t.Fatalf("failed to serialize event: %v", err)
+	}
+
+	reader := &mockReader{messages: []kafka.Message{{Value: value}}}
+	repo := &mockRepository{}
+	consumer := NewBatchConsumer(BatchConsumerConfig{
+		Reader:     reader,
+		Repository: repo,
+		BatchSize:  1,
+	})
+
+	fetchesBefore := histogramSampleCount(t, kafkaFetchWait)
+	insertsBefore := histogramSampleCount(t, kafkaConsumeDuration.WithLabelValues("insert_batch").(prometheus.Histogram))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		consumer.Start(ctx)
+		close(stopped)
+	}()
+
+	deadline := time.After(2 * time.Second)
+	for len(reader.getCommitted()) == 0 {
+		select {
+		case <-deadline:
+			cancel()
+			t.Fatal("timed out waiting for the batch to be committed")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	cancel()
+	<-stopped
+
+	if got := histogramSampleCount(t, kafkaFetchWait) - fetchesBefore; got == 0 {
+		t.Error("expected fetch wait to be observed")
+	}
+	if got := histogramSampleCount(t, kafkaConsumeDuration.WithLabelValues("insert_batch").(prometheus.Histogram)) - insertsBefore; got != 1 {
+		t.Errorf("expected 1 insert observation, got %d", got)
+	}
+}
+
+func TestBatchConsumer_FlushesOnMaxBatchBytes(t *testing.T) {
+	// Each message carries about 1KB of metadata, so the third crosses 2500 bytes
+	var messages []kafka.Message
+	for i := 0; i < 4; i++ {
+		event := &domain.Event{
+			EventID:   uuid.New(),
+			MatchID:   "match-123",
+			EventType: domain.EventTypePass,
+			Timestamp: time.Now(),
+			TeamID:    1,
+			Metadata:  map[string]interface{}{"notes": strings.Repeat("x", 1000)},
+		}
+		value, err := event.ToKafkaMessage()
+		if err != nil {
+			t.Fatalf("failed to serialize event: %v", err)
+		}
+		messages = append(messages, kafka.Message{Value: value, Offset: int64(i)})
+	}
+
+	reader := &mockReader{messages: messages}
+	repo := &mockRepository{}
+	consumer := NewBatchConsumer(BatchConsumerConfig{
+		Reader:        reader,
+		Repository:    repo,
+		BatchSize:     100,
+		MaxBatchBytes: 2500,
+		FlushInterval: time.Minute,
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		consumer.Start(ctx)
+		close(stopped)
+	}()
+
+	deadline := time.After(2 * time.Second)
+	for len(reader.getCommitted()) == 0 {
+		select {
+		case <-deadline:
+			cancel()
+			t.Fatal("timed out waiting for a byte-triggered flush")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	// Let the fourth message be batched before checking it was not flushed
+	time.Sleep(50 * time.Millisecond)
+	committed := len(reader.getCommitted())
+	inserted := len(repo.getInsertedEvents())
+	consumer.batchLock.Lock()
+	pending, pendingBytes := len(consumer.batch), consumer.batchBytes
+	consumer.batchLock.Unlock()
+
+	cancel()
+	<-stopped
+
+	if committed != 3 || inserted != 3 {
+		t.Errorf("expected 3 events flushed by size, got %d committed and %d inserted", committed, inserted)
+	}
+	if pending != 1 {
+		t.Errorf("expected 1 event left in the batch, got %d", pending)
+	}
+	if pendingBytes != len(messages[3].Value) {
+		t.Errorf("expected %d pending bytes, got %d", len(messages[3].Value), pendingBytes)
+	}
+}
+
+// upsertRepository is a mockRepository that also implements Upserter.
+type upsertRepository struct {
+	mockRepository
+	upserted []*domain.Event
+}
+
+func (m *upsertRepository) UpsertBatch(ctx context.Context, events []*domain.Event) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.upserted = append(m.upserted, events...)
+	return nil
+}
+
+func TestBatchConsumer_FlushRoutesUpsertsToUpserter(t *testing.T) {
+	repo := &upsertRepository{}
+	consumer := NewBatchConsumer(BatchConsumerConfig{
+		Reader:     &mockReader{},
+		Repository: repo,
+	})
+
+	// A batch without upserts is inserted as usual
+	consumer.batch = []*domain.Event{createTestEvent()}
+	consumer.messages = []kafka.Message{{}}
+	consumer.flushWithContext(context.Background())
+	if len(repo.getInsertedEvents()) != 1 || len(repo.upserted) != 0 {
+		t.Fatalf("expected a plain insert, got %d inserted and %d upserted", len(repo.getInsertedEvents()), len(repo.upserted))
+	}
+
+	upsert := createTestEvent()
+	upsert.Op = domain.EventOpUpsert
+	consumer.batch = []*domain.Event{createTestEvent(), upsert}
+	consumer.messages = []kafka.Message{{}, {}}
+	consumer.flushWithContext(context.Background())
+	if len(repo.upserted) != 2 {
+		t.Errorf("expected the batch holding an upsert to go through UpsertBatch, got %d upserted", len(repo.upserted))
+	}
+	if len(repo.getInsertedEvents()) != 1 {
+		t.Errorf("expected no further plain inserts, got %d inserted", len(repo.getInsertedEvents()))
+	}
+}
@@ -0,0 +1,141 @@
+package kafka
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// DeadLetterReason classifies why a message was sent to the dead letter topic.
+type DeadLetterReason string
+
+// Dead letter reasons.
+const (
+	// DeadLetterParseError marks a message that could not be parsed as an event.
+	DeadLetterParseError DeadLetterReason = "parse_error"
+	// DeadLetterMaxRetriesExceeded marks an event whose insert kept failing
+	// after every retry.
+	DeadLetterMaxRetriesExceeded DeadLetterReason = "max_retries_exceeded"
+	// DeadLetterPermanentInsertError marks an event whose failed insert could
+	// not be retried, because no retry topic is configured or writing to it failed.
+	DeadLetterPermanentInsertError DeadLetterReason = "permanent_insert_error"
+	// DeadLetterUnstorableEvent marks an event the repository refused before
+	// inserting it, e.g. for metadata that cannot be serialized. Retrying
+	// cannot help, so it skips the retry topic.
+	DeadLetterUnstorableEvent DeadLetterReason = "unstorable_event"
+)
+
+// Retention classes applied by default.
+const (
+	RetentionDiscard  = "discard"
+	RetentionReview7d = "review-7d"
+)
+
+// DefaultDeadLetterRetention returns the retention class for each dead letter
+// reason. Unparseable payloads are noise that can expire; events that failed to
+// insert are kept for review.
+func DefaultDeadLetterRetention() map[DeadLetterReason]string {
+	return map[DeadLetterReason]string{
+		DeadLetterParseError:           RetentionDiscard,
+		DeadLetterMaxRetriesExceeded:   RetentionReview7d,
+		DeadLetterPermanentInsertError: RetentionReview7d,
+		DeadLetterUnstorableEvent:      RetentionReview7d,
+	}
+}
+
+// ParseDeadLetterRetention parses a comma-separated list of reason=class pairs,
+// such as "parse_error=discard,max_retries_exceeded=review-30d", over the
+// defaults. An empty string yields the defaults.
+func ParseDeadLetterRetention(s string) (map[DeadLetterReason]string, error) {
+	retention := DefaultDeadLetterRetention()
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		name, class, ok := strings.Cut(pair, "=")
+		reason := DeadLetterReason(strings.TrimSpace(name))
+		class = strings.TrimSpace(class)
+		if !ok || class == "" {
+			return nil, fmt.Errorf("invalid dead letter retention %q: want reason=class", pair)
+		}
+		if _, known := retention[reason]; !known {
+			return nil, fmt.Errorf("unknown dead letter reason %q", reason)
+		}
+		retention[reason] = class
+	}
+	return retention, nil
+}
+
+// DeadLetterEncodingHeader is the header set to "gzip" on dead letter
+// messages whose value is compressed.
+const DeadLetterEncodingHeader = "content-encoding"
+
+// encodeDeadLetter gzips a dead letter value larger than threshold bytes and
+// reports whether it did. A threshold of 0 or less never compresses, and the
+// value is kept as is if compressing fails.
+func encodeDeadLetter(value []byte, threshold int) ([]byte, bool) {
+	if threshold <= 0 || len(value) <= threshold {
+		return value, false
+	}
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(value); err != nil {
+		return value, false
+	}
+	if err := zw.Close(); err != nil {
+		return value, false
+	}
+	return buf.Bytes(), true
+}
+
+// DecodeDeadLetter returns the JSON value of a dead letter message,
+// decompressing it when its content-encoding header is gzip. Tools that
+// replay or inspect the dead letter topic should read values through it.
+func DecodeDeadLetter(msg kafka.Message) ([]byte, error) {
+	encoding := ""
+	for _, h := range msg.Headers {
+		if strings.EqualFold(h.Key, DeadLetterEncodingHeader) {
+			encoding = string(h.Value)
+		}
+	}
+	switch encoding {
+	case "", "identity":
+		return msg.Value, nil
+	case "gzip":
+		zr, err := gzip.NewReader(bytes.NewReader(msg.Value))
+		if err != nil {
+			return nil, fmt.Errorf("malformed gzip dead letter: %w", err)
+		}
+		defer zr.Close()
+		value, err := io.ReadAll(zr)
+		if err != nil {
+			return nil, fmt.Errorf("malformed gzip dead letter: %w", err)
+		}
+		return value, nil
+	default:
+		return nil, fmt.Errorf("unsupported dead letter encoding %q", encoding)
+	}
+}
+
+// deadLetterMessage builds a dead letter message, gzipping the value when it
+// exceeds the consumer's compression threshold.
+func (c *BatchConsumer) deadLetterMessage(key, value []byte, headers []kafka.Header) kafka.Message {
+	value, compressed := encodeDeadLetter(value, c.deadLetterGzipThreshold)
+	if compressed {
+		headers = append(headers, kafka.Header{Key: DeadLetterEncodingHeader, Value: []byte("gzip")})
+	}
+	return kafka.Message{Key: key, Value: value, Headers: headers}
+}
+
+// retentionFor returns the retention class configured for reason.
+func (c *BatchConsumer) retentionFor(reason DeadLetterReason) string {
+	if class, ok := c.deadLetterRetention[reason]; ok {
+		return class
+	}
+	return DefaultDeadLetterRetention()[reason]
+}
@@ -0,0 +1,309 @@
+package kafka
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/segmentio/kafka-go"
+
+	"fanfinity/internal/domain"
+)
+
+// deadLetterHeader returns the value of the named header on msg.
+func deadLetterHeader(msg kafka.Message, key string) string {
+	for _, header := range msg.Headers {
+		if header.Key == key {
+			return string(header.Value)
+		}
+	}
+	return ""
+}
+
+func TestBatchConsumer_DeadLetterRetention(t *testing.T) {
+	event := &domain.Event{
+		EventID:   uuid.New(),
+		MatchID:   "match-123",
+		EventType: domain.EventTypeGoal,
+		TeamID:    1,
+	}
+	exhausted := kafka.Message{Headers: []kafka.Header{{Key: "retry_count", Value: []byte{3}}}}
+
+	tests := []struct {
+		name          string
+		retention     map[DeadLetterReason]string
+		send          func(c *BatchConsumer)
+		wantReason    DeadLetterReason
+		wantRetention string
+	}{
+		{
+			name: "parse error",
+			send: func(c *BatchConsumer) {
+				c.sendRawToDead(context.Background(), kafka.Message{Value: []byte("{")}, errors.New("unexpected end of JSON input"))
+			},
+			wantReason:    DeadLetterParseError,
+			wantRetention: RetentionDiscard,
+		},
+		{
+			name: "max retries exceeded",
+			send: func(c *BatchConsumer) {
+				c.retryWriter = &mockWriter{}
+				c.sendToRetry(context.Background(), []*domain.Event{event}, []kafka.Message{exhausted})
+			},
+			wantReason:    DeadLetterMaxRetriesExceeded,
+			wantRetention: RetentionReview7d,
+		},
+		{
+			name: "permanent insert error",
+			send: func(c *BatchConsumer) {
+				c.sendToRetry(context.Background(), []*domain.Event{event}, nil)
+			},
+			wantReason:    DeadLetterPermanentInsertError,
+			wantRetention: RetentionReview7d,
+		},
+		{
+			name:      "configured class",
+			retention: map[DeadLetterReason]string{DeadLetterMaxRetriesExceeded: "review-30d"},
+			send: func(c *BatchConsumer) {
+				c.retryWriter = &mockWriter{}
+				c.sendToRetry(context.Background(), []*domain.Event{event}, []kafka.Message{exhausted})
+			},
+			wantReason:    DeadLetterMaxRetriesExceeded,
+			wantRetention: "review-30d",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dead := &mockWriter{}
+			consumer := NewBatchConsumer(BatchConsumerConfig{
+				Reader:              &mockReader{},
+				DeadWriter:          dead,
+				MaxRetries:          3,
+				DeadLetterRetention: tt.retention,
+			})
+
+			tt.send(consumer)
+
+			written := dead.getMessages()
+			if len(written) != 1 {
+				t.Fatalf("expected 1 dead letter message, got %d", len(written))
+			}
+			if got := deadLetterHeader(written[0], "retention"); got != tt.wantRetention {
+				t.Errorf("expected retention header %q, got %q", tt.wantRetention, got)
+			}
+			if got := deadLetterHeader(written[0], "reason"); got != string(tt.wantReason) {
+				t.Errorf("expected reason header %q, got %q", tt.wantReason, got)
+			}
+
+			var info struct {
+				Reason    string `json:"reason"`
+				Retention string `json:"retention"`
+			}
+			if err := json.Unmarshal(written[0].Value, &info); err != nil {
+				t.Fatalf("failed to decode dead letter payload: %v", err)
+			}
+			if info.Retention != tt.wantRetention {
+				t.Errorf("expected retention %q in payload, got %q", tt.wantRetention, info.Retention)
+			}
+			if info.Reason != string(tt.wantReason) {
+				t.Errorf("expected reason %q in payload, got %q", tt.wantReason, info.Reason)
+			}
+		})
+	}
+}
+
+func TestBatchConsumer_DeadLetterGzip(t *testing.T) {
+	large := &domain.Event{
+		EventID:   uuid.New(),
+		MatchID:   "match-123",
+		EventType: domain.EventTypeGoal,
+		TeamID:    1,
+		Metadata:  map[string]interface{}{"commentary": strings.Repeat("a long run down the wing ", 200)},
+	}
+	small := &domain.Event{
+		EventID:   uuid.New(),
+		MatchID:   "match-123",
+		EventType: domain.EventTypeGoal,
+		TeamID:    1,
+	}
+
+	dead := &mockWriter{}
+	consumer := NewBatchConsumer(BatchConsumerConfig{
+		Reader:                  &mockReader{},
+		DeadWriter:              dead,
+		DeadLetterGzipThreshold: 1024,
+	})
+	consumer.sendSingleToDead(context.Background(), large, DeadLetterPermanentInsertError)
+	consumer.sendSingleToDead(context.Background(), small, DeadLetterPermanentInsertError)
+
+	written := dead.getMessages()
+	if len(written) != 2 {
+		t.Fatalf("expected 2 dead letter messages, got %d", len(written))
+	}
+
+	// The large payload is compressed and round-trips through DecodeDeadLetter
+	compressed := written[0]
+	if got := deadLetterHeader(compressed, DeadLetterEncodingHeader); got != "gzip" {
+		t.Fatalf("expected content-encoding gzip on the large payload, got %q", got)
+	}
+	if json.Valid(compressed.Value) {
+		t.Error("expected the large payload to be compressed, got plain JSON")
+	}
+	value, err := DecodeDeadLetter(compressed)
+	if err != nil {
+		t.Fatalf("failed to decode compressed dead letter: %v", err)
+	}
+	if len(value) <= len(compressed.Value) {
+		t.Errorf("expected compression to shrink the payload, got %d compressed and %d decoded bytes", len(compressed.Value), len(value))
+	}
+	var info struct {
+		EventID string          `json:"event_id"`
+		Event   json.RawMessage `json:"event"`
+	}
+	if err := json.Unmarshal(value, &info); err != nil {
+		t.Fatalf("failed to decode dead letter payload: %v", err)
+	}
+	restored, err := domain.EventFromKafkaMessage(info.Event)
+	if err != nil {
+		t.Fatalf("failed to decode embedded event: %v", err)
+	}
+	if info.EventID != large.EventID.String() || restored.Metadata["commentary"] != large.Metadata["commentary"] {
+		t.Error("expected the decoded payload to match the original event")
+	}
+
+	// The small payload stays plain JSON
+	plain := written[1]
+	if got := deadLetterHeader(plain, DeadLetterEncodingHeader); got != "" {
+		t.Errorf("expected no content-encoding on the small payload, got %q", got)
+	}
+	if !json.Valid(plain.Value) {
+		t.Error("expected the small payload to stay uncompressed")
+	}
+	if value, err := DecodeDeadLetter(plain); err != nil || !bytes.Equal(value, plain.Value) {
+		t.Errorf("expected DecodeDeadLetter to return a plain payload unchanged, got err %v", err)
+	}
+}
+
+func TestDecodeDeadLetter_Errors(t *testing.T) {
+	tests := []struct {
+		name     string
+		encoding string
+		value    []byte
+	}{
+		{"truncated gzip", "gzip", []byte{0x1f, 0x8b, 0x08}},
+		{"unsupported encoding", "br", []byte("{}")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := kafka.Message{
+				Value:   tt.value,
+				Headers: []kafka.Header{{Key: DeadLetterEncodingHeader, Value: []byte(tt.encoding)}},
+			}
+			if _, err := DecodeDeadLetter(msg); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestParseDeadLetterRetention(t *testing.T) {
+	retention, err := ParseDeadLetterRetention(" max_retries_exceeded = review-30d ,parse_error=keep")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := map[DeadLetterReason]string{
+		DeadLetterParseError:           "keep",
+		DeadLetterMaxRetriesExceeded:   "review-30d",
+		DeadLetterPermanentInsertError: RetentionReview7d,
+	}
+	for reason, class := range want {
+		if retention[reason] != class {
+			t.Errorf("expected %s=%s, got %s", reason, class, retention[reason])
+		}
+	}
+
+	defaults, err := ParseDeadLetterRetention("")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if defaults[DeadLetterParseError] != RetentionDiscard {
+		t.Errorf("expected default parse_error retention %s, got %s", RetentionDiscard, defaults[DeadLetterParseError])
+	}
+
+	for _, invalid := range []string{"parse_error", "parse_error=", "timeout=discard"} {
+		if _, err := ParseDeadLetterRetention(invalid); err == nil {
+			t.Errorf("expected error for %q", invalid)
+		}
+	}
+}
+
+func TestBatchConsumer_UnstorableEventsDeadLettered(t *testing.T) {
+	good := &domain.Event{EventID: uuid.New(), MatchID: "match-123", EventType: domain.EventTypePass, Timestamp: time.Now(), TeamID: 1}
+	bad := &domain.Event{
+		EventID:   uuid.New(),
+		MatchID:   "match-123",
+		EventType: domain.EventTypeShot,
+		Timestamp: time.Now(),
+		TeamID:    1,
+		Metadata:  map[string]interface{}{"xg": math.NaN()},
+	}
+	_, storeErr := bad.StorableMetadata()
+	var ue *domain.UnstorableEventError
+	if !errors.As(storeErr, &ue) {
+		t.Fatalf("expected the NaN metadata to be unstorable, got %v", storeErr)
+	}
+
+	reader := &mockReader{}
+	retryWriter := &mockWriter{}
+	deadWriter := &mockWriter{}
+	consumer := NewBatchConsumer(BatchConsumerConfig{
+		Reader:      reader,
+		Repository:  &mockRepository{insertErr: &domain.PartialInsertError{Rejected: []*domain.UnstorableEventError{ue}}},
+		RetryWriter: retryWriter,
+		DeadWriter:  deadWriter,
+		BatchSize:   10,
+	})
+	consumer.batch = append(consumer.batch, good, bad)
+	consumer.messages = append(consumer.messages, kafka.Message{Offset: 1}, kafka.Message{Offset: 2})
+
+	consumer.flushWithContext(context.Background())
+
+	if len(retryWriter.getMessages()) != 0 {
+		t.Error("expected nothing to be sent to the retry topic")
+	}
+	if len(reader.committed) != 2 {
+		t.Errorf("expected both messages to be committed, got %d", len(reader.committed))
+	}
+
+	dead := deadWriter.getMessages()
+	if len(dead) != 1 {
+		t.Fatalf("expected 1 dead letter, got %d", len(dead))
+	}
+	if got := deadLetterHeader(dead[0], "reason"); got != string(DeadLetterUnstorableEvent) {
+		t.Errorf("expected reason %q, got %q", DeadLetterUnstorableEvent, got)
+	}
+	var payload struct {
+		EventID         string `json:"event_id"`
+		Error           string `json:"error"`
+		MetadataDropped bool   `json:"metadata_dropped"`
+	}
+	if err := json.Unmarshal(dead[0].Value, &payload); err != nil {
+		t.Fatalf("failed to decode dead letter: %v", err)
+	}
+	if payload.EventID != bad.EventID.String() {
+		t.Errorf("expected the bad event to be dead-lettered, got %s", payload.EventID)
+	}
+	if !strings.Contains(payload.Error, "unstorable metadata") {
+		t.Errorf("expected the dead letter to carry the precise reason, got %q", payload.Error)
+	}
+	if !payload.MetadataDropped {
+		t.Error("expected the unserializable metadata to be dropped from the dead letter")
+	}
+}
@@ -0,0 +1,332 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// PartitionOwner is implemented by readers that track which partitions of
+// their topic the consumer group currently assigns to this member.
+type PartitionOwner interface {
+	Owns(partition int) bool
+}
+
+// GroupReader consumes a topic as a consumer group member, like a kafka.Reader
+// with a GroupID, but surfaces partition revocation. When a rebalance ends the
+// current generation, the revoke hook runs while the partitions are still owned,
+// so in-flight work can be flushed and committed before another member takes
+// over. Commits for partitions the member no longer owns are dropped.
+//
+// Delivery is at-least-once: messages that were fetched but not committed before
+// a revocation are re-read by the partition's next owner.
+type GroupReader struct {
+	config ReaderConfig
+	group  *kafka.ConsumerGroup
+	logger *slog.Logger
+
+	messages chan generationMessage
+
+	mu         sync.RWMutex
+	generation *kafka.Generation
+	owned      map[int]bool
+	readers    map[int]*kafka.Reader
+	onRevoke   func(ctx context.Context)
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// generationMessage tags a fetched message with the generation it was read in,
+// so messages buffered across a rebalance can be discarded.
+type generationMessage struct {
+	generation int32
+	msg        kafka.Message
+}
+
+// DefaultRevokeTimeout bounds how long the revoke hook may run. It should stay
+// below the group's rebalance timeout so the member is not evicted mid-drain.
+const DefaultRevokeTimeout = 30 * time.Second
+
+// NewGroupReader joins the consumer group cfg.GroupID for cfg.Topic and starts
+// consuming in the background. CommitInterval is ignored; commits are synchronous.
+func NewGroupReader(cfg ReaderConfig, logger *slog.Logger) (*GroupReader, error) {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	if cfg.GroupID == "" {
+		return nil, errors.New("group reader requires a GroupID")
+	}
+
+	dialer := &kafka.Dialer{
+		Timeout:   10 * time.Second,
+		DualStack: true,
+	}
+	group, err := kafka.NewConsumerGroup(kafka.ConsumerGroupConfig{
+		ID:          cfg.GroupID,
+		Brokers:     cfg.Brokers,
+		Dialer:      dialer,
+		Topics:      []string{cfg.Topic},
+		StartOffset: cfg.StartOffset,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to join consumer group: %w", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &GroupReader{
+		config:   cfg,
+		group:    group,
+		logger:   logger,
+		messages: make(chan generationMessage),
+		owned:    make(map[int]bool),
+		readers:  make(map[int]*kafka.Reader),
+		cancel:   cancel,
+		done:     make(chan struct{}),
+	}
+	go r.run(ctx, dialer)
+
+	return r, nil
+}
+
+// OnRevoke sets the hook run before this member's partitions are revoked.
+// The hook may call CommitMessages; the partitions are still owned while it runs.
+func (r *GroupReader) OnRevoke(fn func(ctx context.Context)) {
+	r.mu.Lock()
+	r.onRevoke = fn
+	r.mu.Unlock()
+}
+
+// FetchMessage returns the next message from an owned partition, blocking until
+// one is available or ctx is done. Messages left over from an ended generation
+// are skipped.
+func (r *GroupReader) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	for {
+		select {
+		case <-ctx.Done():
+			return kafka.Message{}, ctx.Err()
+		case <-r.done:
+			return kafka.Message{}, io.EOF
+		case gm := <-r.messages:
+			if r.currentGeneration() == gm.generation {
+				return gm.msg, nil
+			}
+		}
+	}
+}
+
+// CommitMessages commits the highest offset per owned partition. Messages from
+// partitions this member no longer owns are skipped, since committing them would
+// move the new owner's position.
+func (r *GroupReader) CommitMessages(ctx context.Context, msgs ...kafka.Message) error {
+	r.mu.RLock()
+	gen := r.generation
+	offsets, skipped := commitOffsets(r.config.Topic, msgs, r.owned)
+	r.mu.RUnlock()
+
+	if skipped > 0 {
+		r.logger.Warn("skipping commit for revoked partitions",
+			slog.Int("message_count", skipped),
+		)
+	}
+	if gen == nil || len(offsets) == 0 {
+		return nil
+	}
+	return gen.CommitOffsets(offsets)
+}
+
+// Owns reports whether partition is assigned to this member in the current generation.
+func (r *GroupReader) Owns(partition int) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.owned[partition]
+}
+
+// Stats reports the topic and the summed lag across owned partitions.
+func (r *GroupReader) Stats() kafka.ReaderStats {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	stats := kafka.ReaderStats{Topic: r.config.Topic}
+	for _, reader := range r.readers {
+		stats.Lag += reader.Stats().Lag
+	}
+	return stats
+}
+
+// Close leaves the consumer group and stops all partition readers.
+func (r *GroupReader) Close() error {
+	r.cancel()
+	err := r.group.Close()
+	<-r.done
+	return err
+}
+
+// run joins each new generation, starts a reader per assigned partition, and
+// registers the revoke hook to run when the generation ends.
+func (r *GroupReader) run(ctx context.Context, dialer *kafka.Dialer) {
+	defer close(r.done)
+
+	for {
+		gen, err := r.group.Next(ctx)
+		if err != nil {
+			if ctx.Err() != nil || errors.Is(err, kafka.ErrGroupClosed) {
+				return
+			}
+			r.logger.Error("failed to join consumer group generation",
+				slog.String("group", r.config.GroupID),
+				slog.String("error", err.Error()),
+			)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(time.Second):
+			}
+			continue
+		}
+
+		assignments := gen.Assignments[r.config.Topic]
+		r.assign(gen, assignments)
+		r.logger.Info("partitions assigned",
+			slog.String("group", r.config.GroupID),
+			slog.Int("generation", int(gen.ID)),
+			slog.Int("partition_count", len(assignments)),
+		)
+
+		for _, assignment := range assignments {
+			assignment := assignment
+			gen.Start(func(genCtx context.Context) {
+				r.readPartition(genCtx, gen.ID, assignment, dialer)
+			})
+		}
+
+		// The group waits for every Start function before joining the next
+		// generation, so the hook finishes while the partitions are still ours
+		gen.Start(func(genCtx context.Context) {
+			<-genCtx.Done()
+			r.revoke(gen.ID)
+		})
+	}
+}
+
+// assign records the partitions owned in a new generation.
+func (r *GroupReader) assign(gen *kafka.Generation, assignments []kafka.PartitionAssignment) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.generation = gen
+	r.owned = make(map[int]bool, len(assignments))
+	for _, assignment := range assignments {
+		r.owned[assignment.ID] = true
+	}
+}
+
+// revoke runs the revoke hook, then releases the generation's partitions.
+func (r *GroupReader) revoke(generation int32) {
+	r.mu.RLock()
+	hook := r.onRevoke
+	r.mu.RUnlock()
+
+	r.logger.Info("partitions revoked, draining in-flight batch",
+		slog.String("group", r.config.GroupID),
+		slog.Int("generation", int(generation)),
+	)
+	if hook != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), DefaultRevokeTimeout)
+		hook(ctx)
+		cancel()
+	}
+
+	r.mu.Lock()
+	r.owned = make(map[int]bool)
+	r.generation = nil
+	r.mu.Unlock()
+}
+
+// readPartition reads one assigned partition until the generation ends.
+func (r *GroupReader) readPartition(ctx context.Context, generation int32, assignment kafka.PartitionAssignment, dialer *kafka.Dialer) {
+	reader := kafka.NewReader(kafka.ReaderConfig{
+		Brokers:   r.config.Brokers,
+		Topic:     r.config.Topic,
+		Partition: assignment.ID,
+		MinBytes:  r.config.MinBytes,
+		MaxBytes:  r.config.MaxBytes,
+		MaxWait:   r.config.MaxWait,
+		Dialer:    dialer,
+	})
+	defer reader.Close()
+
+	if err := reader.SetOffset(assignment.Offset); err != nil {
+		r.logger.Error("failed to set partition offset",
+			slog.Int("partition", assignment.ID),
+			slog.Int64("offset", assignment.Offset),
+			slog.String("error", err.Error()),
+		)
+		return
+	}
+
+	r.mu.Lock()
+	r.readers[assignment.ID] = reader
+	r.mu.Unlock()
+	defer func() {
+		r.mu.Lock()
+		delete(r.readers, assignment.ID)
+		r.mu.Unlock()
+	}()
+
+	for {
+		msg, err := reader.FetchMessage(ctx)
+		if err != nil {
+			if ctx.Err() == nil {
+				r.logger.Error("failed to fetch partition message",
+					slog.Int("partition", assignment.ID),
+					slog.String("error", err.Error()),
+				)
+			}
+			return
+		}
+
+		select {
+		case r.messages <- generationMessage{generation: generation, msg: msg}:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// currentGeneration returns the ID of the current generation, or -1 between generations.
+func (r *GroupReader) currentGeneration() int32 {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.generation == nil {
+		return -1
+	}
+	return r.generation.ID
+}
+
+// commitOffsets returns the offsets to commit for msgs: the highest offset plus
+// one for each owned partition, in the form Generation.CommitOffsets expects,
+// and the number of messages skipped because their partition is not owned.
+func commitOffsets(topic string, msgs []kafka.Message, owned map[int]bool) (map[string]map[int]int64, int) {
+	partitions := make(map[int]int64)
+	skipped := 0
+	for _, msg := range msgs {
+		if !owned[msg.Partition] {
+			skipped++
+			continue
+		}
+		if next := msg.Offset + 1; next > partitions[msg.Partition] {
+			partitions[msg.Partition] = next
+		}
+	}
+	if len(partitions) == 0 {
+		return nil, skipped
+	}
+	return map[string]map[int]int64{topic: partitions}, skipped
+}
@@ -0,0 +1,46 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// MetadataChecker verifies Kafka availability by fetching metadata for a topic.
+type MetadataChecker struct {
+	client *kafka.Client
+	topic  string
+}
+
+// NewMetadataChecker creates a MetadataChecker for the given brokers and topic.
+func NewMetadataChecker(brokers []string, topic string) *MetadataChecker {
+	return &MetadataChecker{
+		client: &kafka.Client{Addr: kafka.TCP(brokers...)},
+		topic:  topic,
+	}
+}
+
+// Ping fetches topic metadata and returns an error if the cluster is unreachable
+// or the topic is missing or has no partitions.
+func (c *MetadataChecker) Ping(ctx context.Context) error {
+	resp, err := c.client.Metadata(ctx, &kafka.MetadataRequest{Topics: []string{c.topic}})
+	if err != nil {
+		return fmt.Errorf("failed to fetch Kafka metadata: %w", err)
+	}
+
+	for _, topic := range resp.Topics {
+		if topic.Name != c.topic {
+			continue
+		}
+		if topic.Error != nil {
+			return fmt.Errorf("topic %s: %w", c.topic, topic.Error)
+		}
+		if len(topic.Partitions) == 0 {
+			return fmt.Errorf("topic %s has no partitions", c.topic)
+		}
+		return nil
+	}
+
+	return fmt.Errorf("topic %s not found in metadata", c.topic)
+}
@@ -0,0 +1,129 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+
+	"fanfinity/internal/domain"
+)
+
+// MaxInspectMessages caps the number of messages read by one inspection.
+const MaxInspectMessages = 1000
+
+// DefaultInspectWait is how long an inspection waits for more messages before
+// returning what it has read, e.g. on reaching the end of the partition.
+const DefaultInspectWait = 2 * time.Second
+
+// partitionReader defines the subset of kafka.Reader used to inspect a partition.
+type partitionReader interface {
+	FetchMessage(ctx context.Context) (kafka.Message, error)
+	Close() error
+}
+
+// MessageInspector reads messages from a single partition for debugging. It
+// does not join a consumer group, so it never commits offsets or triggers a
+// rebalance of the real consumers.
+type MessageInspector struct {
+	topic     string
+	wait      time.Duration
+	newReader func(partition int, offset int64) (partitionReader, error)
+}
+
+// NewMessageInspector creates a MessageInspector for the given brokers and topic.
+func NewMessageInspector(brokers []string, topic string) *MessageInspector {
+	return &MessageInspector{
+		topic: topic,
+		wait:  DefaultInspectWait,
+		newReader: func(partition int, offset int64) (partitionReader, error) {
+			reader := kafka.NewReader(kafka.ReaderConfig{
+				Brokers:   brokers,
+				Topic:     topic,
+				Partition: partition,
+				MinBytes:  1,
+				MaxBytes:  10e6, // 10MB
+				MaxWait:   500 * time.Millisecond,
+			})
+			if err := reader.SetOffset(offset); err != nil {
+				reader.Close()
+				return nil, err
+			}
+			return reader, nil
+		},
+	}
+}
+
+// InspectMessages reads up to limit messages from partition, starting at
+// offset, and decodes their payloads. Messages that fail to decode are
+// returned with their raw payload and the decode error. Reading stops early
+// when no message arrives within the inspection wait, e.g. at the end of the
+// partition.
+func (i *MessageInspector) InspectMessages(ctx context.Context, partition int, offset int64, limit int) ([]domain.InspectedMessage, error) {
+	if partition < 0 {
+		return nil, fmt.Errorf("invalid partition %d", partition)
+	}
+	if offset < 0 {
+		return nil, fmt.Errorf("invalid offset %d", offset)
+	}
+	if limit <= 0 || limit > MaxInspectMessages {
+		limit = MaxInspectMessages
+	}
+
+	reader, err := i.newReader(partition, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open partition %d of %s: %w", partition, i.topic, err)
+	}
+	defer reader.Close()
+
+	messages := make([]domain.InspectedMessage, 0, limit)
+	for len(messages) < limit {
+		fetchCtx, cancel := context.WithTimeout(ctx, i.wait)
+		msg, err := reader.FetchMessage(fetchCtx)
+		cancel()
+		if err != nil {
+			// Running out of messages before limit is expected; only the
+			// caller's own cancellation or a broker error is a failure
+			if errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil {
+				break
+			}
+			return nil, fmt.Errorf("failed to read partition %d of %s: %w", partition, i.topic, err)
+		}
+		messages = append(messages, inspectMessage(msg))
+	}
+	return messages, nil
+}
+
+// inspectMessage decodes msg, recording the raw payload and error if the
+// payload is not a valid event.
+func inspectMessage(msg kafka.Message) domain.InspectedMessage {
+	inspected := domain.InspectedMessage{
+		Partition: msg.Partition,
+		Offset:    msg.Offset,
+		Key:       string(msg.Key),
+		Time:      msg.Time,
+	}
+	if len(msg.Headers) > 0 {
+		inspected.Headers = make(map[string]string, len(msg.Headers))
+		for _, h := range msg.Headers {
+			inspected.Headers[h.Key] = string(h.Value)
+		}
+	}
+
+	if _, err := domain.EventFromKafkaMessage(msg.Value); err != nil {
+		inspected.Raw = string(msg.Value)
+		inspected.Error = err.Error()
+		return inspected
+	}
+	var event domain.KafkaMessage
+	if err := json.Unmarshal(msg.Value, &event); err != nil {
+		inspected.Raw = string(msg.Value)
+		inspected.Error = err.Error()
+		return inspected
+	}
+	inspected.Event = &event
+	return inspected
+}
@@ -0,0 +1,104 @@
+package kafka
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// closingReader adds Close to mockReader for partition inspection.
+type closingReader struct {
+	*mockReader
+	closed bool
+}
+
+func (r *closingReader) Close() error {
+	r.closed = true
+	return nil
+}
+
+func newTestInspector(reader *closingReader) (*MessageInspector, *[]int64) {
+	var offsets []int64
+	return &MessageInspector{
+		topic: "events",
+		wait:  20 * time.Millisecond,
+		newReader: func(partition int, offset int64) (partitionReader, error) {
+			offsets = append(offsets, offset)
+			return reader, nil
+		},
+	}, &offsets
+}
+
+func TestMessageInspector_DecodesMessages(t *testing.T) {
+	event := createTestEvent()
+	value, err := event.ToKafkaMessage()
+	if err != nil {
+		t.Fatalf("failed to serialize event: %v", err)
+	}
+	reader := &closingReader{mockReader: &mockReader{messages: []kafka.Message{
+		{Partition: 2, Offset: 40, Key: []byte(event.MatchID), Value: value, Headers: []kafka.Header{{Key: "event_type", Value: []byte("goal")}}},
+		{Partition: 2, Offset: 41, Value: []byte("not json")},
+	}}}
+	inspector, offsets := newTestInspector(reader)
+
+	messages, err := inspector.InspectMessages(context.Background(), 2, 40, 10)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(*offsets) != 1 || (*offsets)[0] != 40 {
+		t.Errorf("expected reader opened at offset 40, got %v", *offsets)
+	}
+	if !reader.closed {
+		t.Error("expected reader to be closed")
+	}
+	if len(messages) != 2 {
+		t.Fatalf("expected 2 messages before the partition ran dry, got %d", len(messages))
+	}
+
+	decoded := messages[0]
+	if decoded.Event == nil || decoded.Event.EventID != event.EventID.String() || decoded.Error != "" {
+		t.Errorf("expected decoded event %s, got %+v", event.EventID, decoded)
+	}
+	if decoded.Offset != 40 || decoded.Key != event.MatchID || decoded.Headers["event_type"] != "goal" {
+		t.Errorf("unexpected message envelope: %+v", decoded)
+	}
+
+	invalid := messages[1]
+	if invalid.Event != nil || invalid.Error == "" || invalid.Raw != "not json" {
+		t.Errorf("expected undecodable payload to be reported raw, got %+v", invalid)
+	}
+}
+
+func TestMessageInspector_EnforcesLimit(t *testing.T) {
+	msgs := make([]kafka.Message, 5)
+	for i := range msgs {
+		msgs[i] = kafka.Message{Offset: int64(i), Value: []byte("{}")}
+	}
+	reader := &closingReader{mockReader: &mockReader{messages: msgs}}
+	inspector, _ := newTestInspector(reader)
+
+	messages, err := inspector.InspectMessages(context.Background(), 0, 0, 3)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(messages) != 3 {
+		t.Fatalf("expected 3 messages, got %d", len(messages))
+	}
+	if messages[2].Offset != 2 {
+		t.Errorf("expected last message at offset 2, got %d", messages[2].Offset)
+	}
+}
+
+func TestMessageInspector_CallerCancellation(t *testing.T) {
+	reader := &closingReader{mockReader: &mockReader{}}
+	inspector, _ := newTestInspector(reader)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := inspector.InspectMessages(ctx, 0, 0, 3); err == nil {
+		t.Error("expected an error when the caller's context is canceled")
+	}
+}
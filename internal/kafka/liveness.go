@@ -0,0 +1,86 @@
+package kafka
+
+import (
+	"sync"
+	"time"
+)
+
+// Defaults for the producer's broker liveness tracking.
+const (
+	DefaultFailureThreshold = 5
+	DefaultProbeInterval    = 5 * time.Second
+)
+
+// livenessTracker decides whether the broker is reachable from recent write
+// outcomes. After threshold consecutive failures the broker is considered down
+// and writes fail fast; one write per probeInterval is let through as a probe,
+// and the first success marks the broker up again.
+type livenessTracker struct {
+	threshold     int
+	probeInterval time.Duration
+	now           func() time.Time
+
+	mu        sync.Mutex
+	failures  int
+	down      bool
+	nextProbe time.Time
+}
+
+// newLivenessTracker creates a tracker that starts in the up state.
+func newLivenessTracker(threshold int, probeInterval time.Duration) *livenessTracker {
+	return &livenessTracker{
+		threshold:     threshold,
+		probeInterval: probeInterval,
+		now:           time.Now,
+	}
+}
+
+// allow reports whether a write should be attempted. While the broker is down
+// only one write per probe interval is allowed through.
+func (t *livenessTracker) allow() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if !t.down {
+		return true
+	}
+	now := t.now()
+	if now.Before(t.nextProbe) {
+		return false
+	}
+	t.nextProbe = now.Add(t.probeInterval)
+	return true
+}
+
+// recordSuccess marks the broker up. It returns true if the broker was down.
+func (t *livenessTracker) recordSuccess() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	wasDown := t.down
+	t.failures = 0
+	t.down = false
+	return wasDown
+}
+
+// recordFailure counts a failed write. It returns true if this failure marked
+// the broker down.
+func (t *livenessTracker) recordFailure() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.failures++
+	if t.down || t.failures < t.threshold {
+		return false
+	}
+	t.down = true
+	t.nextProbe = t.now().Add(t.probeInterval)
+	return true
+}
+
+// isDown reports whether the broker is currently considered down.
+func (t *livenessTracker) isDown() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.down
+}
@@ -0,0 +1,25 @@
+package kafka
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"fanfinity/internal/metrics"
+)
+
+var metricSet metrics.Set
+
+func init() {
+	RegisterMetrics(prometheus.DefaultRegisterer, metrics.Options{})
+}
+
+// RegisterMetrics creates the producer and consumer metrics under opts and
+// registers them with reg, replacing any registered by an earlier call. Call it
+// at startup, before producing or consuming.
+func RegisterMetrics(reg prometheus.Registerer, opts metrics.Options) {
+	f := metricSet.Factory(reg)
+	registerProducerMetrics(f, opts)
+	registerConsumerMetrics(f, opts)
+	registerOrderingMetrics(f, opts)
+	registerAnomalyMetrics(f, opts)
+	registerSpoolMetrics(f, opts)
+}
@@ -0,0 +1,69 @@
+package kafka
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+
+	"fanfinity/internal/metrics"
+)
+
+func TestRegisterMetrics_CustomNamespace(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	RegisterMetrics(reg, metrics.Options{
+		Namespace:   "acme",
+		ConstLabels: prometheus.Labels{"tenant": "eu"},
+	})
+	t.Cleanup(func() { RegisterMetrics(prometheus.DefaultRegisterer, metrics.Options{}) })
+
+	kafkaMessagesProduced.WithLabelValues("events", "success").Inc()
+	kafkaDeadLetterEvents.Inc()
+	outOfOrderEvents.Inc()
+
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Gather() error = %v", err)
+	}
+	names := make(map[string]bool)
+	for _, mf := range families {
+		names[mf.GetName()] = true
+		if !strings.HasPrefix(mf.GetName(), "acme_") {
+			t.Errorf("metric %q does not use the acme namespace", mf.GetName())
+		}
+		for _, m := range mf.GetMetric() {
+			if !hasLabel(m.GetLabel(), "tenant", "eu") {
+				t.Errorf("metric %q is missing the tenant label", mf.GetName())
+			}
+		}
+	}
+	for _, want := range []string{
+		"acme_kafka_producer_messages_produced_total",
+		"acme_kafka_consumer_dead_letter_events_total",
+		"acme_out_of_order_events_total",
+	} {
+		if !names[want] {
+			t.Errorf("metric %q not registered", want)
+		}
+	}
+
+	defaults, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatalf("DefaultGatherer.Gather() error = %v", err)
+	}
+	for _, mf := range defaults {
+		if strings.HasPrefix(mf.GetName(), "fanfinity_kafka_") {
+			t.Errorf("metric %q is still registered under the default namespace", mf.GetName())
+		}
+	}
+}
+
+func hasLabel(labels []*dto.LabelPair, name, value string) bool {
+	for _, l := range labels {
+		if l.GetName() == name && l.GetValue() == value {
+			return true
+		}
+	}
+	return false
+}
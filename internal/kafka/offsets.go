@@ -0,0 +1,146 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+
+	"fanfinity/internal/domain"
+)
+
+// offsetClient defines the subset of kafka.Client used to reset group offsets.
+type offsetClient interface {
+	Metadata(ctx context.Context, req *kafka.MetadataRequest) (*kafka.MetadataResponse, error)
+	ListOffsets(ctx context.Context, req *kafka.ListOffsetsRequest) (*kafka.ListOffsetsResponse, error)
+	OffsetCommit(ctx context.Context, req *kafka.OffsetCommitRequest) (*kafka.OffsetCommitResponse, error)
+}
+
+// OffsetResetter moves a consumer group's committed offsets on the events
+// topic, so the consumers reprocess from a point in time when they next join.
+// Kafka only accepts the commit while the group has no active members, so
+// the consumers must be stopped first.
+type OffsetResetter struct {
+	client  offsetClient
+	groupID string
+	topic   string
+}
+
+// NewOffsetResetter creates an OffsetResetter for groupID on topic.
+func NewOffsetResetter(brokers []string, groupID, topic string) *OffsetResetter {
+	return &OffsetResetter{
+		client:  &kafka.Client{Addr: kafka.TCP(brokers...), Timeout: 10 * time.Second},
+		groupID: groupID,
+		topic:   topic,
+	}
+}
+
+// ResetOffsets commits, for every partition of the topic, the offset of the
+// first message at or after at. Partitions with no such message are set to
+// their end offset, so only newer messages are consumed. It returns the
+// committed offsets ordered by partition.
+func (o *OffsetResetter) ResetOffsets(ctx context.Context, at time.Time) ([]domain.PartitionOffset, error) {
+	partitions, err := o.partitions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	timeRequests := make([]kafka.OffsetRequest, len(partitions))
+	endRequests := make([]kafka.OffsetRequest, len(partitions))
+	for i, partition := range partitions {
+		timeRequests[i] = kafka.TimeOffsetOf(partition, at)
+		endRequests[i] = kafka.LastOffsetOf(partition)
+	}
+	// A timestamp past the last message yields offset -1, which kafka-go
+	// reports as the last offset, so the two lookups are kept apart
+	byTime, err := o.listOffsets(ctx, timeRequests)
+	if err != nil {
+		return nil, err
+	}
+	ends, err := o.listOffsets(ctx, endRequests)
+	if err != nil {
+		return nil, err
+	}
+
+	offsets := make([]domain.PartitionOffset, 0, len(partitions))
+	commits := make([]kafka.OffsetCommit, 0, len(partitions))
+	for _, partition := range partitions {
+		offset := ends[partition].LastOffset
+		for found := range byTime[partition].Offsets {
+			if found >= 0 {
+				offset = found
+			}
+		}
+		if offset < 0 {
+			return nil, fmt.Errorf("no offset found for partition %d of %s", partition, o.topic)
+		}
+		offsets = append(offsets, domain.PartitionOffset{Partition: partition, Offset: offset})
+		commits = append(commits, kafka.OffsetCommit{Partition: partition, Offset: offset})
+	}
+
+	resp, err := o.client.OffsetCommit(ctx, &kafka.OffsetCommitRequest{
+		GroupID:      o.groupID,
+		GenerationID: -1,
+		Topics:       map[string][]kafka.OffsetCommit{o.topic: commits},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to commit offsets for group %s: %w", o.groupID, err)
+	}
+	var errs []error
+	for _, p := range resp.Topics[o.topic] {
+		if p.Error != nil {
+			errs = append(errs, fmt.Errorf("partition %d: %w", p.Partition, p.Error))
+		}
+	}
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("failed to commit offsets for group %s, are its consumers stopped? %w", o.groupID, errors.Join(errs...))
+	}
+	return offsets, nil
+}
+
+// partitions returns the topic's partition IDs in ascending order.
+func (o *OffsetResetter) partitions(ctx context.Context) ([]int, error) {
+	resp, err := o.client.Metadata(ctx, &kafka.MetadataRequest{Topics: []string{o.topic}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch Kafka metadata: %w", err)
+	}
+	for _, topic := range resp.Topics {
+		if topic.Name != o.topic {
+			continue
+		}
+		if topic.Error != nil {
+			return nil, fmt.Errorf("topic %s: %w", o.topic, topic.Error)
+		}
+		partitions := make([]int, 0, len(topic.Partitions))
+		for _, p := range topic.Partitions {
+			partitions = append(partitions, p.ID)
+		}
+		if len(partitions) == 0 {
+			return nil, fmt.Errorf("topic %s has no partitions", o.topic)
+		}
+		sort.Ints(partitions)
+		return partitions, nil
+	}
+	return nil, fmt.Errorf("topic %s not found in metadata", o.topic)
+}
+
+// listOffsets runs one offset lookup for the topic, keyed by partition.
+func (o *OffsetResetter) listOffsets(ctx context.Context, requests []kafka.OffsetRequest) (map[int]kafka.PartitionOffsets, error) {
+	resp, err := o.client.ListOffsets(ctx, &kafka.ListOffsetsRequest{
+		Topics: map[string][]kafka.OffsetRequest{o.topic: requests},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list offsets for %s: %w", o.topic, err)
+	}
+	byPartition := make(map[int]kafka.PartitionOffsets, len(requests))
+	for _, p := range resp.Topics[o.topic] {
+		if p.Error != nil {
+			return nil, fmt.Errorf("failed to list offsets for partition %d of %s: %w", p.Partition, o.topic, p.Error)
+		}
+		byPartition[p.Partition] = p
+	}
+	return byPartition, nil
+}
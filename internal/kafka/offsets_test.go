@@ -0,0 +1,116 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// mockOffsetClient serves offset lookups from per-partition message times,
+// where a message's offset is its index, and records the committed offsets.
+type mockOffsetClient struct {
+	times     map[int][]time.Time
+	commitErr error
+	committed *kafka.OffsetCommitRequest
+}
+
+func (c *mockOffsetClient) Metadata(ctx context.Context, req *kafka.MetadataRequest) (*kafka.MetadataResponse, error) {
+	topic := kafka.Topic{Name: req.Topics[0]}
+	for partition := range c.times {
+		topic.Partitions = append(topic.Partitions, kafka.Partition{ID: partition})
+	}
+	return &kafka.MetadataResponse{Topics: []kafka.Topic{topic}}, nil
+}
+
+// ListOffsets mimics the broker: a time lookup returns the first message at
+// or after the time, and -1 (reported by kafka-go as LastOffset) when none is.
+func (c *mockOffsetClient) ListOffsets(ctx context.Context, req *kafka.ListOffsetsRequest) (*kafka.ListOffsetsResponse, error) {
+	resp := &kafka.ListOffsetsResponse{Topics: map[string][]kafka.PartitionOffsets{}}
+	for topic, requests := range req.Topics {
+		for _, r := range requests {
+			times := c.times[r.Partition]
+			p := kafka.PartitionOffsets{Partition: r.Partition, FirstOffset: -1, LastOffset: -1, Offsets: map[int64]time.Time{}}
+			if r.Timestamp == kafka.LastOffset {
+				p.LastOffset = int64(len(times))
+			} else {
+				for offset, at := range times {
+					if at.UnixMilli() >= r.Timestamp {
+						p.Offsets[int64(offset)] = at
+						break
+					}
+				}
+			}
+			resp.Topics[topic] = append(resp.Topics[topic], p)
+		}
+	}
+	return resp, nil
+}
+
+func (c *mockOffsetClient) OffsetCommit(ctx context.Context, req *kafka.OffsetCommitRequest) (*kafka.OffsetCommitResponse, error) {
+	c.committed = req
+	resp := &kafka.OffsetCommitResponse{Topics: map[string][]kafka.OffsetCommitPartition{}}
+	for topic, commits := range req.Topics {
+		for _, commit := range commits {
+			resp.Topics[topic] = append(resp.Topics[topic], kafka.OffsetCommitPartition{Partition: commit.Partition, Error: c.commitErr})
+		}
+	}
+	return resp, nil
+}
+
+func TestOffsetResetter_ResetOffsets(t *testing.T) {
+	base := time.Date(2024, 1, 15, 14, 0, 0, 0, time.UTC)
+	client := &mockOffsetClient{times: map[int][]time.Time{
+		0: {base, base.Add(time.Minute), base.Add(2 * time.Minute)},
+		1: {base.Add(30 * time.Second), base.Add(5 * time.Minute)},
+		2: {base},
+	}}
+	resetter := &OffsetResetter{client: client, groupID: "fanfinity-consumers", topic: "events"}
+
+	offsets, err := resetter.ResetOffsets(context.Background(), base.Add(time.Minute))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Partition 2 has nothing at or after the time, so it moves to its end
+	want := map[int]int64{0: 1, 1: 1, 2: 1}
+	if len(offsets) != len(want) {
+		t.Fatalf("expected %d partitions, got %+v", len(want), offsets)
+	}
+	for i, o := range offsets {
+		if o.Partition != i || o.Offset != want[o.Partition] {
+			t.Errorf("expected partition %d at offset %d, got %+v", i, want[i], o)
+		}
+	}
+
+	if client.committed == nil {
+		t.Fatal("expected offsets to be committed")
+	}
+	if client.committed.GroupID != "fanfinity-consumers" || client.committed.GenerationID != -1 {
+		t.Errorf("expected a commit for the idle group, got %+v", client.committed)
+	}
+	commits := client.committed.Topics["events"]
+	if len(commits) != len(want) {
+		t.Fatalf("expected %d commits, got %+v", len(want), commits)
+	}
+	for _, c := range commits {
+		if c.Offset != want[c.Partition] {
+			t.Errorf("expected commit of partition %d at %d, got %d", c.Partition, want[c.Partition], c.Offset)
+		}
+	}
+}
+
+func TestOffsetResetter_ResetOffsets_ActiveGroup(t *testing.T) {
+	client := &mockOffsetClient{
+		times:     map[int][]time.Time{0: {time.Now()}},
+		commitErr: kafka.UnknownMemberId,
+	}
+	resetter := &OffsetResetter{client: client, groupID: "fanfinity-consumers", topic: "events"}
+
+	_, err := resetter.ResetOffsets(context.Background(), time.Now().Add(-time.Hour))
+	if !errors.Is(err, kafka.UnknownMemberId) {
+		t.Fatalf("expected the broker's commit error, got: %v", err)
+	}
+}
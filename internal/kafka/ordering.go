@@ -0,0 +1,64 @@
+package kafka
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"fanfinity/internal/domain"
+	"fanfinity/internal/metrics"
+)
+
+var outOfOrderEvents prometheus.Counter
+
+// registerOrderingMetrics creates the ordering check metrics under opts.
+func registerOrderingMetrics(f promauto.Factory, opts metrics.Options) {
+	outOfOrderEvents = f.NewCounter(
+		prometheus.CounterOpts{
+			Namespace:   opts.NamespaceOrDefault(),
+			Name:        "out_of_order_events_total",
+			Help:        "Total number of events whose timestamp is earlier than the last seen for their match in the same batch",
+			ConstLabels: opts.ConstLabels,
+		},
+	)
+}
+
+// DefaultMaxTrackedMatches bounds the per-match state kept by the ordering check.
+const DefaultMaxTrackedMatches = 10000
+
+// orderingTracker remembers the latest event timestamp per match within the
+// current batch. Matches beyond maxMatches are not tracked until the next reset.
+type orderingTracker struct {
+	lastSeen   map[string]time.Time
+	maxMatches int
+}
+
+// newOrderingTracker creates a tracker holding at most maxMatches matches.
+func newOrderingTracker(maxMatches int) *orderingTracker {
+	if maxMatches <= 0 {
+		maxMatches = DefaultMaxTrackedMatches
+	}
+	return &orderingTracker{
+		lastSeen:   make(map[string]time.Time),
+		maxMatches: maxMatches,
+	}
+}
+
+// observe records event and reports whether it is earlier than the latest
+// event already seen for its match, returning that latest timestamp.
+func (t *orderingTracker) observe(event *domain.Event) (time.Time, bool) {
+	last, ok := t.lastSeen[event.MatchID]
+	if ok && event.Timestamp.Before(last) {
+		return last, true
+	}
+	if ok || len(t.lastSeen) < t.maxMatches {
+		t.lastSeen[event.MatchID] = event.Timestamp
+	}
+	return last, false
+}
+
+// reset forgets all matches, ready for the next batch.
+func (t *orderingTracker) reset() {
+	clear(t.lastSeen)
+}
@@ -0,0 +1,66 @@
+package kafka
+
+import (
+	"container/list"
+	"sync"
+)
+
+// DefaultMaxCountedMatches bounds the matches whose produced events are counted.
+const DefaultMaxCountedMatches = 10000
+
+// producedCounter counts successfully produced events per match. Beyond
+// maxMatches, the match produced to least recently is forgotten.
+type producedCounter struct {
+	mu         sync.Mutex
+	maxMatches int
+	counts     map[string]*list.Element
+	recency    *list.List // of *matchCount, most recent first
+}
+
+// matchCount is one match's produced event count.
+type matchCount struct {
+	matchID string
+	count   int64
+}
+
+// newProducedCounter creates a counter tracking at most maxMatches matches.
+func newProducedCounter(maxMatches int) *producedCounter {
+	if maxMatches <= 0 {
+		maxMatches = DefaultMaxCountedMatches
+	}
+	return &producedCounter{
+		maxMatches: maxMatches,
+		counts:     make(map[string]*list.Element),
+		recency:    list.New(),
+	}
+}
+
+// add counts n more events produced for matchID.
+func (c *producedCounter) add(matchID string, n int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.counts[matchID]; ok {
+		elem.Value.(*matchCount).count += n
+		c.recency.MoveToFront(elem)
+		return
+	}
+	if c.recency.Len() >= c.maxMatches {
+		oldest := c.recency.Back()
+		c.recency.Remove(oldest)
+		delete(c.counts, oldest.Value.(*matchCount).matchID)
+	}
+	c.counts[matchID] = c.recency.PushFront(&matchCount{matchID: matchID, count: n})
+}
+
+// get returns the produced count for matchID and whether it is tracked.
+func (c *producedCounter) get(matchID string) (int64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.counts[matchID]
+	if !ok {
+		return 0, false
+	}
+	return elem.Value.(*matchCount).count, true
+}
@@ -2,67 +2,456 @@ package kafka
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"sync"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 	"github.com/segmentio/kafka-go"
 
 	"fanfinity/internal/domain"
+	"fanfinity/internal/metrics"
 )
 
 var (
 	// Prometheus metrics for Kafka producer
-	kafkaMessagesProduced = promauto.NewCounterVec(
+	kafkaMessagesProduced *prometheus.CounterVec
+	kafkaProduceLatency   *prometheus.HistogramVec
+	kafkaMessageSize      *prometheus.HistogramVec
+	kafkaOversizeMessages *prometheus.CounterVec
+	kafkaProduceRetries   *prometheus.CounterVec
+	kafkaBrokerUp         *prometheus.GaugeVec
+	kafkaBatchDuplicates  prometheus.Counter
+)
+
+// registerProducerMetrics creates the producer metrics under opts.
+func registerProducerMetrics(f promauto.Factory, opts metrics.Options) {
+	ns := opts.NamespaceOrDefault()
+
+	kafkaMessagesProduced = f.NewCounterVec(
 		prometheus.CounterOpts{
-			Namespace: "fanfinity",
-			Subsystem: "kafka_producer",
-			Name:      "messages_produced_total",
-			Help:      "Total number of messages produced to Kafka",
+			Namespace:   ns,
+			Subsystem:   "kafka_producer",
+			Name:        "messages_produced_total",
+			Help:        "Total number of messages produced to Kafka",
+			ConstLabels: opts.ConstLabels,
 		},
 		[]string{"topic", "status"},
 	)
 
-	kafkaProduceLatency = promauto.NewHistogramVec(
+	kafkaProduceLatency = f.NewHistogramVec(
 		prometheus.HistogramOpts{
-			Namespace: "fanfinity",
-			Subsystem: "kafka_producer",
-			Name:      "produce_duration_seconds",
-			Help:      "Histogram of Kafka produce latency in seconds",
-			Buckets:   []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1.0},
+			Namespace:   ns,
+			Subsystem:   "kafka_producer",
+			Name:        "produce_duration_seconds",
+			Help:        "Histogram of Kafka produce latency in seconds",
+			ConstLabels: opts.ConstLabels,
+			Buckets:     []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1.0},
 		},
-		[]string{"topic"},
+		[]string{"topic", "event_type"},
 	)
 
-	kafkaMessageSize = promauto.NewHistogramVec(
+	kafkaMessageSize = f.NewHistogramVec(
 		prometheus.HistogramOpts{
-			Namespace: "fanfinity",
-			Subsystem: "kafka_producer",
-			Name:      "message_size_bytes",
-			Help:      "Histogram of Kafka message sizes in bytes",
-			Buckets:   []float64{100, 500, 1000, 5000, 10000, 50000, 100000},
+			Namespace:   ns,
+			Subsystem:   "kafka_producer",
+			Name:        "message_size_bytes",
+			Help:        "Histogram of Kafka message sizes in bytes",
+			ConstLabels: opts.ConstLabels,
+			Buckets:     []float64{100, 500, 1000, 5000, 10000, 50000, 100000},
+		},
+		[]string{"topic", "event_type"},
+	)
+
+	kafkaOversizeMessages = f.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace:   ns,
+			Subsystem:   "kafka",
+			Name:        "oversize_total",
+			Help:        "Total number of events rejected for exceeding the maximum message size",
+			ConstLabels: opts.ConstLabels,
 		},
 		[]string{"topic"},
 	)
+
+	kafkaProduceRetries = f.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace:   ns,
+			Subsystem:   "kafka_producer",
+			Name:        "retries_total",
+			Help:        "Total number of produce attempts retried after a transient broker error",
+			ConstLabels: opts.ConstLabels,
+		},
+		[]string{"topic"},
+	)
+
+	kafkaBrokerUp = f.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace:   ns,
+			Subsystem:   "kafka_producer",
+			Name:        "broker_up",
+			Help:        "Whether the producer considers the broker reachable (1) or down and is failing fast (0)",
+			ConstLabels: opts.ConstLabels,
+		},
+		[]string{"topic"},
+	)
+
+	kafkaBatchDuplicates = f.NewCounter(
+		prometheus.CounterOpts{
+			Namespace:   ns,
+			Subsystem:   "producer",
+			Name:        "batch_duplicates_total",
+			Help:        "Total number of events skipped by ProduceBatch for repeating an eventId earlier in the same batch",
+			ConstLabels: opts.ConstLabels,
+		},
+	)
+}
+
+// Event type label values outside the known event types, keeping label
+// cardinality bounded.
+const (
+	eventTypeLabelUnknown = "unknown"
+	eventTypeLabelMixed   = "mixed"
+)
+
+// eventTypeLabel returns the metric label for eventType: the type itself when
+// it is known, otherwise "unknown".
+func eventTypeLabel(eventType domain.EventType) string {
+	if domain.ValidEventTypes[eventType] || eventType == domain.EventTypeCorrection {
+		return string(eventType)
+	}
+	return eventTypeLabelUnknown
+}
+
+// DefaultMaxMessageBytes matches the Kafka broker default for message.max.bytes.
+const DefaultMaxMessageBytes = 1048576
+
+// Defaults for retrying transient produce errors.
+const (
+	DefaultProduceRetries      = 2
+	DefaultProduceRetryBackoff = 100 * time.Millisecond
 )
 
 // EventProducer handles producing events to Kafka.
 type EventProducer struct {
-	writer *kafka.Writer
-	logger *slog.Logger
+	writer          *kafka.Writer
+	logger          *slog.Logger
+	maxMessageBytes int
+	liveness        *livenessTracker
+
+	// messages receives writes; it is the writer unless replaced in tests.
+	messages     MessageWriter
+	maxRetries   int
+	retryBackoff time.Duration
+
+	produced *producedCounter
+
+	// topic is the default topic. router may send events elsewhere, through
+	// writers created by newWriter on first use and cached in writers.
+	topic         string
+	router        TopicRouter
+	newWriter     func(topic string) MessageWriter
+	writersMu     sync.Mutex
+	writers       map[string]MessageWriter
+	writersClosed bool
+
+	// spool, when set, holds messages that failed because the broker was down.
+	spool *Spool
+
+	// dedupBatch skips events whose eventId repeats within a ProduceBatch call.
+	dedupBatch bool
+}
+
+// ProducerConfig holds optional settings for the EventProducer.
+type ProducerConfig struct {
+	// MaxMessageBytes is the largest serialized message that will be sent to the broker.
+	MaxMessageBytes int
+
+	// FailureThreshold is the number of consecutive write failures after which
+	// the broker is considered down and writes fail fast with ErrBrokerUnavailable.
+	// While down, one write per ProbeInterval is attempted to detect recovery.
+	FailureThreshold int
+	ProbeInterval    time.Duration
+
+	// MaxRetries bounds how often a write failing with a transient broker error,
+	// such as a leader election, is retried. The backoff starts at RetryBackoff
+	// and doubles per attempt. Zero uses DefaultProduceRetries; a negative
+	// value disables retries.
+	MaxRetries   int
+	RetryBackoff time.Duration
+
+	// MaxCountedMatches bounds the matches ProducedCount tracks.
+	MaxCountedMatches int
+
+	// TopicRouter sends events to topics other than the writer's, for example
+	// to isolate a high-volume competition. Each routed topic gets its own
+	// writer, created with the default writer's settings on first use. Nil
+	// sends every event to the writer's topic.
+	TopicRouter TopicRouter
+
+	// Spool, when set, receives messages that fail because the broker is
+	// unreachable, and the produce succeeds once they are on disk. ReplaySpool
+	// writes them to Kafka when the broker recovers. Nil fails such produces.
+	Spool *Spool
+
+	// DedupBatch makes ProduceBatch skip an event whose eventId appears again
+	// later in the same batch, keeping the last occurrence so a correction
+	// upserted in the same batch wins. Duplicates across batches are not
+	// detected.
+	DedupBatch bool
+}
+
+// DefaultProducerConfig returns the default producer configuration.
+func DefaultProducerConfig() ProducerConfig {
+	return ProducerConfig{
+		MaxMessageBytes:  DefaultMaxMessageBytes,
+		FailureThreshold: DefaultFailureThreshold,
+		ProbeInterval:    DefaultProbeInterval,
+		MaxRetries:       DefaultProduceRetries,
+		RetryBackoff:     DefaultProduceRetryBackoff,
+
+		MaxCountedMatches: DefaultMaxCountedMatches,
+	}
 }
 
 // NewEventProducer creates a new EventProducer instance.
 func NewEventProducer(writer *kafka.Writer, logger *slog.Logger) *EventProducer {
+	return NewEventProducerWithConfig(writer, logger, DefaultProducerConfig())
+}
+
+// NewEventProducerWithConfig creates a new EventProducer with custom configuration.
+func NewEventProducerWithConfig(writer *kafka.Writer, logger *slog.Logger, cfg ProducerConfig) *EventProducer {
 	if logger == nil {
 		logger = slog.Default()
 	}
-	return &EventProducer{
-		writer: writer,
-		logger: logger,
+	if cfg.MaxMessageBytes <= 0 {
+		cfg.MaxMessageBytes = DefaultMaxMessageBytes
+	}
+	if cfg.FailureThreshold <= 0 {
+		cfg.FailureThreshold = DefaultFailureThreshold
+	}
+	if cfg.ProbeInterval <= 0 {
+		cfg.ProbeInterval = DefaultProbeInterval
+	}
+	switch {
+	case cfg.MaxRetries < 0:
+		cfg.MaxRetries = 0
+	case cfg.MaxRetries == 0:
+		cfg.MaxRetries = DefaultProduceRetries
+	}
+	if cfg.RetryBackoff <= 0 {
+		cfg.RetryBackoff = DefaultProduceRetryBackoff
+	}
+	p := &EventProducer{
+		writer:          writer,
+		logger:          logger,
+		maxMessageBytes: cfg.MaxMessageBytes,
+		liveness:        newLivenessTracker(cfg.FailureThreshold, cfg.ProbeInterval),
+		maxRetries:      cfg.MaxRetries,
+		retryBackoff:    cfg.RetryBackoff,
+		produced:        newProducedCounter(cfg.MaxCountedMatches),
+		router:          cfg.TopicRouter,
+		writers:         make(map[string]MessageWriter),
+		spool:           cfg.Spool,
+		dedupBatch:      cfg.DedupBatch,
+	}
+	if writer != nil {
+		p.messages = writer
+		p.topic = writer.Topic
+		p.newWriter = func(topic string) MessageWriter {
+			return topicWriter(writer, topic)
+		}
+		kafkaBrokerUp.WithLabelValues(writer.Topic).Set(1)
+	}
+	return p
+}
+
+// writeWithRetry writes msgs, retrying transient broker errors with exponential
+// backoff up to the configured number of retries. When the writer reports
+// per-message errors, only the failed messages are retried.
+//
+// Kafka offers no exactly-once guarantee here: if the broker stored an attempt
+// whose acknowledgement was lost, the retry stores it again. Retried messages
+// keep their matchId key, so they land on the same partition in order, and
+// their eventId, which downstream deduplication is keyed on.
+func (p *EventProducer) writeWithRetry(ctx context.Context, topic string, msgs ...kafka.Message) error {
+	writer, err := p.writerFor(topic)
+	if err != nil {
+		return err
+	}
+	backoff := p.retryBackoff
+	for attempt := 0; ; attempt++ {
+		err := writer.WriteMessages(ctx, msgs...)
+		if err == nil || attempt >= p.maxRetries || !isRetryableProduceError(err) {
+			return err
+		}
+		msgs = failedMessages(msgs, err)
+
+		kafkaProduceRetries.WithLabelValues(topic).Inc()
+		p.logger.Warn("retrying produce after transient broker error",
+			slog.String("topic", topic),
+			slog.Int("attempt", attempt+1),
+			slog.Int("message_count", len(msgs)),
+			slog.Duration("backoff", backoff),
+			slog.String("error", err.Error()),
+		)
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+		backoff *= 2
+	}
+}
+
+// isRetryableProduceError reports whether err is a transient broker error worth
+// retrying. For per-message write errors, every failure must be transient.
+func isRetryableProduceError(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var writeErrs kafka.WriteErrors
+	if errors.As(err, &writeErrs) {
+		if writeErrs.Count() == 0 {
+			return false
+		}
+		for _, e := range writeErrs {
+			if e != nil && !isRetryableProduceError(e) {
+				return false
+			}
+		}
+		return true
+	}
+
+	var kafkaErr kafka.Error
+	if errors.As(err, &kafkaErr) {
+		return kafkaErr.Temporary()
+	}
+	return false
+}
+
+// failedMessages returns the messages that failed according to per-message
+// write errors, or all of msgs when err does not break failures down.
+func failedMessages(msgs []kafka.Message, err error) []kafka.Message {
+	var writeErrs kafka.WriteErrors
+	if !errors.As(err, &writeErrs) || len(writeErrs) != len(msgs) {
+		return msgs
+	}
+	failed := make([]kafka.Message, 0, writeErrs.Count())
+	for i, e := range writeErrs {
+		if e != nil {
+			failed = append(failed, msgs[i])
+		}
+	}
+	return failed
+}
+
+// brokerUnavailable fails count messages fast while the broker is considered down.
+func (p *EventProducer) brokerUnavailable(topic string, count int) error {
+	kafkaMessagesProduced.WithLabelValues(topic, "unavailable").Add(float64(count))
+	return fmt.Errorf("topic %s: %w", topic, domain.ErrBrokerUnavailable)
+}
+
+// recordWrite updates broker liveness from the outcome of a write. Cancelled
+// writes, and writes refused after the routed writers closed, say nothing about
+// the broker and are ignored.
+func (p *EventProducer) recordWrite(topic string, err error) {
+	switch {
+	case err == nil:
+		if p.liveness.recordSuccess() {
+			p.logger.Info("Kafka broker reachable again, resuming produce",
+				slog.String("topic", topic),
+			)
+			kafkaBrokerUp.WithLabelValues(topic).Set(1)
+		}
+	case errors.Is(err, context.Canceled), errors.Is(err, ErrRoutedWritersClosed):
+	default:
+		if p.liveness.recordFailure() {
+			p.logger.Warn("Kafka broker considered down, failing produce fast until a probe succeeds",
+				slog.String("topic", topic),
+				slog.Int("failure_threshold", p.liveness.threshold),
+				slog.Duration("probe_interval", p.liveness.probeInterval),
+			)
+			kafkaBrokerUp.WithLabelValues(topic).Set(0)
+		}
+	}
+}
+
+// messageSize approximates the on-wire size of a message's key, value, and headers.
+func messageSize(msg kafka.Message) int {
+	size := len(msg.Key) + len(msg.Value)
+	for _, header := range msg.Headers {
+		size += len(header.Key) + len(header.Value)
 	}
+	return size
+}
+
+// checkMessageSize returns ErrMessageTooLarge if msg exceeds the configured limit.
+func (p *EventProducer) checkMessageSize(topic string, event *domain.Event, msg kafka.Message) error {
+	size := messageSize(msg)
+	if size <= p.maxMessageBytes {
+		return nil
+	}
+
+	p.logger.Warn("event exceeds maximum message size",
+		slog.String("event_id", event.EventID.String()),
+		slog.String("match_id", event.MatchID),
+		slog.Int("message_size", size),
+		slog.Int("max_message_bytes", p.maxMessageBytes),
+	)
+	kafkaOversizeMessages.WithLabelValues(topic).Inc()
+	kafkaMessagesProduced.WithLabelValues(topic, "oversize").Inc()
+	return fmt.Errorf("event %s is %d bytes (limit %d): %w", event.EventID, size, p.maxMessageBytes, domain.ErrMessageTooLarge)
+}
+
+// opHeader carries an event's domain.EventOp when it is not a plain insert.
+const opHeader = "op"
+
+// confirmHeader is set to "true" on events whose status the consumer publishes.
+const confirmHeader = "confirm"
+
+// eventHeaders returns the headers of an event's Kafka message, used for
+// filtering without decoding the payload.
+func eventHeaders(event *domain.Event) []kafka.Header {
+	headers := []kafka.Header{
+		{Key: "event_type", Value: []byte(string(event.EventType))},
+		{Key: "event_id", Value: []byte(event.EventID.String())},
+	}
+	if event.Op != "" {
+		headers = append(headers, kafka.Header{Key: opHeader, Value: []byte(event.Op)})
+	}
+	if event.Confirm {
+		headers = append(headers, kafka.Header{Key: confirmHeader, Value: []byte("true")})
+	}
+	return headers
+}
+
+// eventOp returns the domain.EventOp carried in msg's headers.
+func eventOp(msg kafka.Message) domain.EventOp {
+	for _, header := range msg.Headers {
+		if header.Key == opHeader {
+			return domain.EventOp(header.Value)
+		}
+	}
+	return ""
+}
+
+// eventConfirm reports whether msg asks for its event's status to be published.
+func eventConfirm(msg kafka.Message) bool {
+	for _, header := range msg.Headers {
+		if header.Key == confirmHeader {
+			return string(header.Value) == "true"
+		}
+	}
+	return false
 }
 
 // Produce sends an event to Kafka.
@@ -74,7 +463,7 @@ func (p *EventProducer) Produce(ctx context.Context, event *domain.Event) error
 	}
 
 	startTime := time.Now()
-	topic := p.writer.Topic
+	topic := p.topicFor(event)
 
 	// Serialize event to JSON using domain's serialization method
 	value, err := event.ToKafkaMessage()
@@ -88,25 +477,47 @@ func (p *EventProducer) Produce(ctx context.Context, event *domain.Event) error
 		return fmt.Errorf("failed to serialize event: %w", err)
 	}
 
-	// Create Kafka message with headers for efficient filtering
+	// Create Kafka message with headers for efficient filtering. Its time is
+	// when the event was accepted, which the consumer measures lag from.
 	msg := kafka.Message{
-		Key:   []byte(event.MatchID),
-		Value: value,
-		Headers: []kafka.Header{
-			{Key: "event_type", Value: []byte(string(event.EventType))},
-			{Key: "event_id", Value: []byte(event.EventID.String())},
-		},
-		Time: event.Timestamp,
+		Key:     []byte(event.MatchID),
+		Value:   value,
+		Headers: eventHeaders(event),
+		Time:    time.Now(),
+	}
+
+	// Reject oversized messages before they reach the broker
+	if err := p.checkMessageSize(topic, event, msg); err != nil {
+		return err
+	}
+
+	// Fail fast instead of waiting out the write timeout while the broker is down
+	if !p.liveness.allow() {
+		err := p.brokerUnavailable(topic, 1)
+		if p.spoolFailed(ctx, topic, []kafka.Message{msg}, err) {
+			return nil
+		}
+		return err
 	}
 
 	// Write message synchronously to ensure durability
-	err = p.writer.WriteMessages(ctx, msg)
+	err = p.writeWithRetry(ctx, topic, msg)
 	duration := time.Since(startTime)
+	p.recordWrite(topic, err)
 
 	// Record metrics
-	kafkaProduceLatency.WithLabelValues(topic).Observe(duration.Seconds())
-	kafkaMessageSize.WithLabelValues(topic).Observe(float64(len(value)))
+	eventType := eventTypeLabel(event.EventType)
+	kafkaProduceLatency.WithLabelValues(topic, eventType).Observe(duration.Seconds())
+	kafkaMessageSize.WithLabelValues(topic, eventType).Observe(float64(len(value)))
 
+	if err != nil && p.spoolFailed(ctx, topic, []kafka.Message{msg}, err) {
+		p.logger.Warn("spooled event to disk after produce failure",
+			slog.String("event_id", event.EventID.String()),
+			slog.String("match_id", event.MatchID),
+			slog.String("error", err.Error()),
+		)
+		return nil
+	}
 	if err != nil {
 		p.logger.Error("failed to produce message to Kafka",
 			slog.String("event_id", event.EventID.String()),
@@ -127,26 +538,52 @@ func (p *EventProducer) Produce(ctx context.Context, event *domain.Event) error
 		slog.Int("message_size", len(value)),
 	)
 	kafkaMessagesProduced.WithLabelValues(topic, "success").Inc()
+	p.produced.add(event.MatchID, 1)
 
 	return nil
 }
 
 // ProduceBatch sends multiple events to Kafka in a single batch.
-// All events are serialized and sent together for improved throughput.
+// All events are serialized and sent together for improved throughput. When
+// events are routed to several topics, each topic is written in turn; if a
+// write fails, events already written to earlier topics stay written.
 func (p *EventProducer) ProduceBatch(ctx context.Context, events []*domain.Event) error {
 	if len(events) == 0 {
 		return nil
 	}
 
-	startTime := time.Now()
-	topic := p.writer.Topic
-
-	messages := make([]kafka.Message, 0, len(events))
+	// Messages are grouped by topic, keeping their order within each topic
+	var topics []string
+	byTopic := make(map[string][]kafka.Message)
+
+	// Batch latency is labeled with the batch's event type when all events
+	// share one, and "mixed" otherwise
+	batchTypes := make(map[string]string)
+
+	// last holds the index of each eventId's final occurrence
+	var last map[uuid.UUID]int
+	if p.dedupBatch {
+		last = make(map[uuid.UUID]int, len(events))
+		for i, event := range events {
+			if event != nil {
+				last[event.EventID] = i
+			}
+		}
+	}
 
-	for _, event := range events {
+	for i, event := range events {
 		if event == nil {
 			continue
 		}
+		if last != nil && last[event.EventID] != i {
+			p.logger.Warn("skipping duplicate event in batch",
+				slog.String("event_id", event.EventID.String()),
+				slog.String("match_id", event.MatchID),
+			)
+			kafkaBatchDuplicates.Inc()
+			continue
+		}
+		topic := p.topicFor(event)
 
 		value, err := event.ToKafkaMessage()
 		if err != nil {
@@ -159,30 +596,76 @@ func (p *EventProducer) ProduceBatch(ctx context.Context, events []*domain.Event
 		}
 
 		msg := kafka.Message{
-			Key:   []byte(event.MatchID),
-			Value: value,
-			Headers: []kafka.Header{
-				{Key: "event_type", Value: []byte(string(event.EventType))},
-				{Key: "event_id", Value: []byte(event.EventID.String())},
-			},
-			Time: event.Timestamp,
+			Key:     []byte(event.MatchID),
+			Value:   value,
+			Headers: eventHeaders(event),
+			Time:    time.Now(),
 		}
 
-		messages = append(messages, msg)
-		kafkaMessageSize.WithLabelValues(topic).Observe(float64(len(value)))
+		// A single oversized event fails the batch before anything is written
+		if err := p.checkMessageSize(topic, event, msg); err != nil {
+			return err
+		}
+
+		if _, ok := byTopic[topic]; !ok {
+			topics = append(topics, topic)
+		}
+		byTopic[topic] = append(byTopic[topic], msg)
+
+		eventType := eventTypeLabel(event.EventType)
+		kafkaMessageSize.WithLabelValues(topic, eventType).Observe(float64(len(value)))
+		if batchType, ok := batchTypes[topic]; !ok {
+			batchTypes[topic] = eventType
+		} else if batchType != eventType {
+			batchTypes[topic] = eventTypeLabelMixed
+		}
 	}
 
-	if len(messages) == 0 {
+	if len(topics) == 0 {
 		return nil
 	}
 
-	err := p.writer.WriteMessages(ctx, messages...)
+	if !p.liveness.allow() {
+		var failed error
+		for _, topic := range topics {
+			err := p.brokerUnavailable(topic, len(byTopic[topic]))
+			if !p.spoolFailed(ctx, topic, byTopic[topic], err) {
+				failed = err
+			}
+		}
+		return failed
+	}
+
+	for _, topic := range topics {
+		if err := p.writeBatch(ctx, topic, byTopic[topic], batchTypes[topic]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeBatch writes one topic's messages from a batch and records the outcome.
+func (p *EventProducer) writeBatch(ctx context.Context, topic string, messages []kafka.Message, batchType string) error {
+	startTime := time.Now()
+	err := p.writeWithRetry(ctx, topic, messages...)
 	duration := time.Since(startTime)
+	p.recordWrite(topic, err)
+
+	kafkaProduceLatency.WithLabelValues(topic, batchType).Observe(duration.Seconds())
 
-	kafkaProduceLatency.WithLabelValues(topic).Observe(duration.Seconds())
+	// Messages the writer did not report as failed were written
+	if err != nil && p.spoolFailed(ctx, topic, failedMessages(messages, err), err) {
+		p.logger.Warn("spooled batch to disk after produce failure",
+			slog.String("topic", topic),
+			slog.Int("batch_size", len(messages)),
+			slog.String("error", err.Error()),
+		)
+		return nil
+	}
 
 	if err != nil {
 		p.logger.Error("failed to produce batch to Kafka",
+			slog.String("topic", topic),
 			slog.Int("batch_size", len(messages)),
 			slog.Duration("duration", duration),
 			slog.String("error", err.Error()),
@@ -192,15 +675,28 @@ func (p *EventProducer) ProduceBatch(ctx context.Context, events []*domain.Event
 	}
 
 	p.logger.Debug("successfully produced batch to Kafka",
+		slog.String("topic", topic),
 		slog.Int("batch_size", len(messages)),
 		slog.Duration("duration", duration),
 	)
 	kafkaMessagesProduced.WithLabelValues(topic, "success").Add(float64(len(messages)))
+	for _, msg := range messages {
+		p.produced.add(string(msg.Key), 1)
+	}
 
 	return nil
 }
 
-// Close closes the Kafka writer and releases resources.
+// ProducedCount returns how many events this producer has written for matchID
+// since it started, and false if the match is not tracked: nothing was produced
+// for it, or it was evicted after MaxCountedMatches more recent matches. Counts
+// are per process, so with several API instances each sees only its share.
+func (p *EventProducer) ProducedCount(matchID string) (int64, bool) {
+	return p.produced.get(matchID)
+}
+
+// Close closes the Kafka writer, and any writers created for routed topics,
+// and releases resources.
 func (p *EventProducer) Close() error {
 	if p.writer == nil {
 		return nil
@@ -208,12 +704,16 @@ func (p *EventProducer) Close() error {
 
 	p.logger.Info("closing Kafka producer")
 
+	routedErr := p.CloseRoutedWriters()
 	if err := p.writer.Close(); err != nil {
 		p.logger.Error("failed to close Kafka writer",
 			slog.String("error", err.Error()),
 		)
 		return fmt.Errorf("failed to close Kafka writer: %w", err)
 	}
+	if routedErr != nil {
+		return routedErr
+	}
 
 	p.logger.Info("Kafka producer closed successfully")
 	return nil
@@ -2,10 +2,14 @@ package kafka
 
 import (
 	"context"
+	"errors"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
 	"github.com/segmentio/kafka-go"
 
 	"fanfinity/internal/domain"
@@ -169,6 +173,367 @@ func TestEventProducer_ProduceBatch_NilEvents(t *testing.T) {
 	_ = producer.ProduceBatch(context.Background(), events)
 }
 
+func TestEventProducer_Produce_OversizedMessage(t *testing.T) {
+	writer := &kafka.Writer{Topic: "test-topic"}
+	producer := NewEventProducerWithConfig(writer, nil, ProducerConfig{MaxMessageBytes: 1024})
+
+	event := createTestEvent()
+	event.Metadata = map[string]interface{}{
+		"commentary": strings.Repeat("x", 2048),
+	}
+
+	err := producer.Produce(context.Background(), event)
+	if err == nil {
+		t.Fatal("expected error for oversized message")
+	}
+	if !errors.Is(err, domain.ErrMessageTooLarge) {
+		t.Errorf("expected ErrMessageTooLarge, got: %v", err)
+	}
+}
+
+func TestEventProducer_ProduceBatch_OversizedMessage(t *testing.T) {
+	writer := &kafka.Writer{Topic: "test-topic"}
+	producer := NewEventProducerWithConfig(writer, nil, ProducerConfig{MaxMessageBytes: 1024})
+
+	oversized := createTestEvent()
+	oversized.Metadata = map[string]interface{}{
+		"commentary": strings.Repeat("x", 2048),
+	}
+
+	err := producer.ProduceBatch(context.Background(), []*domain.Event{createTestEvent(), oversized})
+	if !errors.Is(err, domain.ErrMessageTooLarge) {
+		t.Errorf("expected ErrMessageTooLarge, got: %v", err)
+	}
+}
+
+func TestNewEventProducerWithConfig_DefaultMaxMessageBytes(t *testing.T) {
+	producer := NewEventProducerWithConfig(&kafka.Writer{Topic: "test-topic"}, nil, ProducerConfig{})
+
+	if producer.maxMessageBytes != DefaultMaxMessageBytes {
+		t.Errorf("expected default max message bytes %d, got %d", DefaultMaxMessageBytes, producer.maxMessageBytes)
+	}
+}
+
+func TestEventProducer_Produce_FailsFastWhenBrokerDown(t *testing.T) {
+	// Nothing listens on port 1, so every write fails immediately
+	writer := NewWriterWithConfig(WriterConfig{
+		Brokers:      []string{"127.0.0.1:1"},
+		Topic:        "test-topic",
+		BatchSize:    1,
+		BatchTimeout: time.Millisecond,
+		WriteTimeout: time.Second,
+		MaxAttempts:  1,
+	})
+	defer writer.Close()
+	producer := NewEventProducerWithConfig(writer, nil, ProducerConfig{
+		FailureThreshold: 2,
+		ProbeInterval:    time.Hour,
+	})
+
+	for i := 0; i < 2; i++ {
+		err := producer.Produce(context.Background(), createTestEvent())
+		if err == nil {
+			t.Fatal("expected write to unreachable broker to fail")
+		}
+		if errors.Is(err, domain.ErrBrokerUnavailable) {
+			t.Fatalf("write %d: expected a broker write error before the threshold, got: %v", i+1, err)
+		}
+	}
+	if !producer.liveness.isDown() {
+		t.Fatal("expected broker to be considered down after reaching the failure threshold")
+	}
+
+	start := time.Now()
+	err := producer.Produce(context.Background(), createTestEvent())
+	if !errors.Is(err, domain.ErrBrokerUnavailable) {
+		t.Fatalf("expected ErrBrokerUnavailable, got: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("expected fast failure, took %v", elapsed)
+	}
+	if err := producer.ProduceBatch(context.Background(), []*domain.Event{createTestEvent()}); !errors.Is(err, domain.ErrBrokerUnavailable) {
+		t.Errorf("expected ErrBrokerUnavailable for batch, got: %v", err)
+	}
+
+	// Once the probe interval elapses a single write is attempted again
+	producer.liveness.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	err = producer.Produce(context.Background(), createTestEvent())
+	if err == nil || errors.Is(err, domain.ErrBrokerUnavailable) {
+		t.Errorf("expected probe write to reach the broker and fail, got: %v", err)
+	}
+}
+
+func TestLivenessTracker_RecoversOnSuccess(t *testing.T) {
+	now := time.Now()
+	tracker := newLivenessTracker(1, time.Second)
+	tracker.now = func() time.Time { return now }
+
+	if !tracker.recordFailure() {
+		t.Fatal("expected failure at threshold to mark broker down")
+	}
+	if tracker.allow() {
+		t.Error("expected writes to be rejected before the probe interval")
+	}
+
+	now = now.Add(time.Second)
+	if !tracker.allow() {
+		t.Error("expected one probe write after the probe interval")
+	}
+	if tracker.allow() {
+		t.Error("expected only one probe write per interval")
+	}
+
+	if !tracker.recordSuccess() {
+		t.Error("expected success to report recovery")
+	}
+	if tracker.isDown() || !tracker.allow() {
+		t.Error("expected writes to be allowed after recovery")
+	}
+}
+
+// flakyWriter fails the first failures writes with err, then succeeds.
+type flakyWriter struct {
+	failures int
+	err      error
+	calls    int
+	written  []kafka.Message
+}
+
+func (w *flakyWriter) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	w.calls++
+	if w.calls <= w.failures {
+		return w.err
+	}
+	w.written = append(w.written, msgs...)
+	return nil
+}
+
+func newRetryTestProducer(w MessageWriter, maxRetries int) *EventProducer {
+	producer := NewEventProducerWithConfig(&kafka.Writer{Topic: "test-topic"}, nil, ProducerConfig{
+		MaxRetries:   maxRetries,
+		RetryBackoff: time.Millisecond,
+	})
+	producer.messages = w
+	return producer
+}
+
+func TestEventProducer_Produce_RetriesTransientErrors(t *testing.T) {
+	writer := &flakyWriter{failures: 2, err: kafka.LeaderNotAvailable}
+	producer := newRetryTestProducer(writer, 3)
+
+	event := createTestEvent()
+	if err := producer.Produce(context.Background(), event); err != nil {
+		t.Fatalf("expected produce to succeed after retries, got: %v", err)
+	}
+	if writer.calls != 3 {
+		t.Errorf("expected 3 write attempts, got %d", writer.calls)
+	}
+	if len(writer.written) != 1 || string(writer.written[0].Key) != event.MatchID {
+		t.Errorf("expected one message keyed by matchId, got %v", writer.written)
+	}
+}
+
+func TestEventProducer_Produce_GivesUpAfterMaxRetries(t *testing.T) {
+	writer := &flakyWriter{failures: 10, err: kafka.NotLeaderForPartition}
+	producer := newRetryTestProducer(writer, 2)
+
+	err := producer.Produce(context.Background(), createTestEvent())
+	if !errors.Is(err, kafka.NotLeaderForPartition) {
+		t.Fatalf("expected NotLeaderForPartition, got: %v", err)
+	}
+	if writer.calls != 3 {
+		t.Errorf("expected 1 attempt plus 2 retries, got %d", writer.calls)
+	}
+}
+
+func TestEventProducer_Produce_NegativeMaxRetriesDisablesRetries(t *testing.T) {
+	writer := &flakyWriter{failures: 1, err: kafka.LeaderNotAvailable}
+	producer := newRetryTestProducer(writer, -1)
+
+	if err := producer.Produce(context.Background(), createTestEvent()); !errors.Is(err, kafka.LeaderNotAvailable) {
+		t.Fatalf("expected LeaderNotAvailable, got: %v", err)
+	}
+	if writer.calls != 1 {
+		t.Errorf("expected a single write attempt, got %d", writer.calls)
+	}
+}
+
+func TestEventProducer_Produce_DoesNotRetryPermanentErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+	}{
+		{"message too large", kafka.MessageSizeTooLarge},
+		{"invalid topic", kafka.InvalidTopic},
+		{"non-kafka error", errors.New("connection refused")},
+		{"context canceled", context.Canceled},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			writer := &flakyWriter{failures: 1, err: tt.err}
+			producer := newRetryTestProducer(writer, 3)
+
+			if err := producer.Produce(context.Background(), createTestEvent()); err == nil {
+				t.Fatal("expected error")
+			}
+			if writer.calls != 1 {
+				t.Errorf("expected a single write attempt, got %d", writer.calls)
+			}
+		})
+	}
+}
+
+func TestEventProducer_ProduceBatch_RetriesOnlyFailedMessages(t *testing.T) {
+	writer := &flakyWriter{failures: 1, err: kafka.WriteErrors{nil, kafka.LeaderNotAvailable, nil}}
+	producer := newRetryTestProducer(writer, 2)
+
+	events := []*domain.Event{createTestEvent(), createTestEvent(), createTestEvent()}
+	if err := producer.ProduceBatch(context.Background(), events); err != nil {
+		t.Fatalf("expected batch to succeed after retry, got: %v", err)
+	}
+	if len(writer.written) != 1 {
+		t.Fatalf("expected only the failed message to be retried, got %d", len(writer.written))
+	}
+	if got := string(writer.written[0].Headers[1].Value); got != events[1].EventID.String() {
+		t.Errorf("expected retried message for event %s, got %s", events[1].EventID, got)
+	}
+}
+
+func TestEventProducer_ProduceBatch_DedupBatch(t *testing.T) {
+	writer := &flakyWriter{}
+	producer := NewEventProducerWithConfig(&kafka.Writer{Topic: "test-topic"}, nil, ProducerConfig{DedupBatch: true})
+	producer.messages = writer
+
+	first, second := createTestEvent(), createTestEvent()
+	duplicate := *first
+
+	var before dto.Metric
+	if err := kafkaBatchDuplicates.Write(&before); err != nil {
+		t.Fatalf("failed to read counter: %v", err)
+	}
+
+	if err := producer.ProduceBatch(context.Background(), []*domain.Event{first, second, &duplicate}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(writer.written) != 2 {
+		t.Fatalf("expected the duplicate to be skipped, got %d messages", len(writer.written))
+	}
+	// The last occurrence wins, so a correction later in the batch is kept
+	if got := string(writer.written[0].Headers[1].Value); got != second.EventID.String() {
+		t.Errorf("expected the earlier occurrence to be skipped, got event %s first", got)
+	}
+	if got := string(writer.written[1].Headers[1].Value); got != first.EventID.String() {
+		t.Errorf("expected the last occurrence to be kept, got event %s second", got)
+	}
+
+	var after dto.Metric
+	if err := kafkaBatchDuplicates.Write(&after); err != nil {
+		t.Fatalf("failed to read counter: %v", err)
+	}
+	if got := after.GetCounter().GetValue() - before.GetCounter().GetValue(); got != 1 {
+		t.Errorf("expected 1 duplicate counted, got %v", got)
+	}
+}
+
+func TestEventProducer_ProducedCount(t *testing.T) {
+	writer := &flakyWriter{}
+	producer := newRetryTestProducer(writer, 0)
+
+	first, second := createTestEvent(), createTestEvent()
+	second.MatchID = "match-456"
+	if err := producer.Produce(context.Background(), first); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := producer.ProduceBatch(context.Background(), []*domain.Event{first, second, first}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got, ok := producer.ProducedCount(first.MatchID); !ok || got != 3 {
+		t.Errorf("expected 3 events for %s, got %d (tracked %v)", first.MatchID, got, ok)
+	}
+	if got, ok := producer.ProducedCount("match-456"); !ok || got != 1 {
+		t.Errorf("expected 1 event for match-456, got %d (tracked %v)", got, ok)
+	}
+	if _, ok := producer.ProducedCount("unknown"); ok {
+		t.Error("expected an unproduced match not to be tracked")
+	}
+
+	// Failed writes are not counted
+	writer.failures, writer.calls, writer.err = 1, 0, kafka.InvalidTopic
+	producer.Produce(context.Background(), first)
+	if got, _ := producer.ProducedCount(first.MatchID); got != 3 {
+		t.Errorf("expected a failed write not to be counted, got %d", got)
+	}
+}
+
+func TestProducedCounter_EvictsLeastRecentMatch(t *testing.T) {
+	counter := newProducedCounter(2)
+	counter.add("a", 1)
+	counter.add("b", 1)
+	counter.add("a", 1)
+	counter.add("c", 1)
+
+	if _, ok := counter.get("b"); ok {
+		t.Error("expected the least recently produced match to be evicted")
+	}
+	if got, ok := counter.get("a"); !ok || got != 2 {
+		t.Errorf("expected a=2, got %d (tracked %v)", got, ok)
+	}
+	if got, ok := counter.get("c"); !ok || got != 1 {
+		t.Errorf("expected c=1, got %d (tracked %v)", got, ok)
+	}
+}
+
+// histogramCount returns the number of observations for the given labels.
+func histogramCount(t *testing.T, vec *prometheus.HistogramVec, labels ...string) uint64 {
+	t.Helper()
+	var m dto.Metric
+	if err := vec.WithLabelValues(labels...).(prometheus.Histogram).Write(&m); err != nil {
+		t.Fatalf("failed to read histogram: %v", err)
+	}
+	return m.GetHistogram().GetSampleCount()
+}
+
+func TestEventProducer_ObservesEventTypeLabels(t *testing.T) {
+	const topic = "event-type-labels"
+	producer := NewEventProducer(&kafka.Writer{Topic: topic}, nil)
+	producer.messages = &flakyWriter{}
+
+	goal := createTestEvent()
+	goal.EventType = domain.EventTypeGoal
+	pass := createTestEvent()
+	pass.EventType = domain.EventTypePass
+	unknown := createTestEvent()
+	unknown.EventType = domain.EventType("bicycle_kick")
+
+	if err := producer.Produce(context.Background(), goal); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := producer.Produce(context.Background(), unknown); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := producer.ProduceBatch(context.Background(), []*domain.Event{pass, pass}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := producer.ProduceBatch(context.Background(), []*domain.Event{goal, pass}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	latency := map[string]uint64{"goal": 1, "pass": 1, "mixed": 1, "unknown": 1, "bicycle_kick": 0}
+	for eventType, want := range latency {
+		if got := histogramCount(t, kafkaProduceLatency, topic, eventType); got != want {
+			t.Errorf("latency[%s]: expected %d observations, got %d", eventType, want, got)
+		}
+	}
+	sizes := map[string]uint64{"goal": 2, "pass": 3, "unknown": 1, "mixed": 0}
+	for eventType, want := range sizes {
+		if got := histogramCount(t, kafkaMessageSize, topic, eventType); got != want {
+			t.Errorf("size[%s]: expected %d observations, got %d", eventType, want, got)
+		}
+	}
+}
+
 func TestWriterConfig_DefaultValues(t *testing.T) {
 	cfg := WriterConfig{}
 
@@ -199,3 +564,53 @@ func BenchmarkEventSerialization(b *testing.B) {
 		}
 	}
 }
+
+func TestEventProducer_Produce_UpsertOpHeader(t *testing.T) {
+	writer := &mockWriter{}
+	producer := newRetryTestProducer(writer, 0)
+
+	plain := createTestEvent()
+	upsert := createTestEvent()
+	upsert.Op = domain.EventOpUpsert
+	for _, event := range []*domain.Event{plain, upsert} {
+		if err := producer.Produce(context.Background(), event); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	messages := writer.getMessages()
+	if len(messages) != 2 {
+		t.Fatalf("expected 2 messages, got %d", len(messages))
+	}
+	if op := eventOp(messages[0]); op != "" {
+		t.Errorf("expected no op header on a plain event, got %q", op)
+	}
+	if op := eventOp(messages[1]); op != domain.EventOpUpsert {
+		t.Errorf("expected op header %q, got %q", domain.EventOpUpsert, op)
+	}
+}
+
+func TestEventProducer_Produce_ConfirmHeader(t *testing.T) {
+	writer := &mockWriter{}
+	producer := newRetryTestProducer(writer, 0)
+
+	plain := createTestEvent()
+	confirmed := createTestEvent()
+	confirmed.Confirm = true
+	for _, event := range []*domain.Event{plain, confirmed} {
+		if err := producer.Produce(context.Background(), event); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	messages := writer.getMessages()
+	if len(messages) != 2 {
+		t.Fatalf("expected 2 messages, got %d", len(messages))
+	}
+	if eventConfirm(messages[0]) {
+		t.Error("expected no confirm header on a plain event")
+	}
+	if !eventConfirm(messages[1]) {
+		t.Error("expected a confirm header on a confirmed event")
+	}
+}
@@ -0,0 +1,165 @@
+package kafka
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+
+	"github.com/segmentio/kafka-go"
+
+	"fanfinity/internal/domain"
+)
+
+// RouteByMatchID routes events on their match ID rather than a metadata key.
+const RouteByMatchID = "matchId"
+
+// TopicRouter picks the topic an event is produced to. An empty result sends
+// the event to the producer's default topic.
+type TopicRouter func(event *domain.Event) string
+
+// MatchTopicRouter routes the events of each listed match to its mapped topic.
+func MatchTopicRouter(topics map[string]string) TopicRouter {
+	return func(event *domain.Event) string {
+		return topics[event.MatchID]
+	}
+}
+
+// MetadataTopicRouter routes events on the string value of metadata[key], such
+// as a competition ID, through topics. Events without the key, or whose value
+// is not a string, are not routed.
+func MetadataTopicRouter(key string, topics map[string]string) TopicRouter {
+	return func(event *domain.Event) string {
+		value, _ := event.MetadataString(key)
+		return topics[value]
+	}
+}
+
+// NewTopicRouter returns a router over routes keyed on routeBy: RouteByMatchID
+// or a metadata key. It returns nil when there are no routes.
+func NewTopicRouter(routeBy string, routes map[string]string) TopicRouter {
+	if len(routes) == 0 {
+		return nil
+	}
+	if routeBy == "" || routeBy == RouteByMatchID {
+		return MatchTopicRouter(routes)
+	}
+	return MetadataTopicRouter(routeBy, routes)
+}
+
+// ParseTopicRoutes parses a comma-separated list of value=topic pairs, such as
+// "ucl=fanfinity.events.ucl,epl=fanfinity.events.epl".
+func ParseTopicRoutes(s string) (map[string]string, error) {
+	routes := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		value, topic, ok := strings.Cut(pair, "=")
+		value, topic = strings.TrimSpace(value), strings.TrimSpace(topic)
+		if !ok || value == "" || topic == "" {
+			return nil, fmt.Errorf("invalid topic route %q: want value=topic", pair)
+		}
+		if _, dup := routes[value]; dup {
+			return nil, fmt.Errorf("duplicate topic route for %q", value)
+		}
+		routes[value] = topic
+	}
+	return routes, nil
+}
+
+// topicFor returns the topic event is produced to.
+func (p *EventProducer) topicFor(event *domain.Event) string {
+	if p.router != nil {
+		if topic := p.router(event); topic != "" {
+			return topic
+		}
+	}
+	return p.topic
+}
+
+// ErrRoutedWritersClosed is returned for writes to a routed topic after
+// CloseRoutedWriters, which never recreates the writers it closed.
+var ErrRoutedWritersClosed = errors.New("routed Kafka writers are closed")
+
+// writerFor returns the writer for topic, creating and caching one on first use
+// for topics other than the default.
+func (p *EventProducer) writerFor(topic string) (MessageWriter, error) {
+	if topic == p.topic {
+		return p.messages, nil
+	}
+
+	p.writersMu.Lock()
+	defer p.writersMu.Unlock()
+
+	if p.writersClosed {
+		return nil, fmt.Errorf("topic %s: %w", topic, ErrRoutedWritersClosed)
+	}
+	if w, ok := p.writers[topic]; ok {
+		return w, nil
+	}
+	w := p.newWriter(topic)
+	p.writers[topic] = w
+	kafkaBrokerUp.WithLabelValues(topic).Set(1)
+	p.logger.Info("created Kafka writer for routed topic",
+		slog.String("topic", topic),
+	)
+	return w, nil
+}
+
+// CloseRoutedWriters closes the writers created for routed topics; later
+// writes to routed topics fail with ErrRoutedWritersClosed. Close calls it
+// too; it is exported for callers that close the default writer themselves.
+func (p *EventProducer) CloseRoutedWriters() error {
+	p.writersMu.Lock()
+	defer p.writersMu.Unlock()
+
+	p.writersClosed = true
+
+	var errs []error
+	for topic, w := range p.writers {
+		closer, ok := w.(io.Closer)
+		if !ok {
+			continue
+		}
+		if err := closer.Close(); err != nil {
+			p.logger.Error("failed to close Kafka writer",
+				slog.String("topic", topic),
+				slog.String("error", err.Error()),
+			)
+			errs = append(errs, fmt.Errorf("topic %s: %w", topic, err))
+		}
+	}
+	clear(p.writers)
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to close Kafka writers: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// topicWriter returns a writer for topic with the same settings as base.
+func topicWriter(base *kafka.Writer, topic string) *kafka.Writer {
+	return &kafka.Writer{
+		Addr:                   base.Addr,
+		Topic:                  topic,
+		Balancer:               base.Balancer,
+		MaxAttempts:            base.MaxAttempts,
+		WriteBackoffMin:        base.WriteBackoffMin,
+		WriteBackoffMax:        base.WriteBackoffMax,
+		BatchSize:              base.BatchSize,
+		BatchBytes:             base.BatchBytes,
+		BatchTimeout:           base.BatchTimeout,
+		ReadTimeout:            base.ReadTimeout,
+		WriteTimeout:           base.WriteTimeout,
+		RequiredAcks:           base.RequiredAcks,
+		Async:                  base.Async,
+		Completion:             base.Completion,
+		Compression:            base.Compression,
+		Logger:                 base.Logger,
+		ErrorLogger:            base.ErrorLogger,
+		Transport:              base.Transport,
+		AllowAutoTopicCreation: base.AllowAutoTopicCreation,
+	}
+}
@@ -0,0 +1,190 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+
+	"fanfinity/internal/domain"
+)
+
+// routedWriter records messages written to one routed topic and whether it was closed.
+type routedWriter struct {
+	mockWriter
+	closed bool
+}
+
+func (w *routedWriter) Close() error {
+	w.closed = true
+	return nil
+}
+
+// newRoutingTestProducer returns a producer whose default topic writes to
+// defaultWriter and whose routed topics write to writers created in routed.
+func newRoutingTestProducer(router TopicRouter, defaultWriter *mockWriter, routed map[string]*routedWriter) *EventProducer {
+	producer := NewEventProducerWithConfig(&kafka.Writer{Topic: "events"}, nil, ProducerConfig{
+		RetryBackoff: time.Millisecond,
+		TopicRouter:  router,
+	})
+	producer.messages = defaultWriter
+	producer.newWriter = func(topic string) MessageWriter {
+		w := &routedWriter{}
+		routed[topic] = w
+		return w
+	}
+	return producer
+}
+
+func routingTestEvent(matchID string, metadata map[string]interface{}) *domain.Event {
+	event := createTestEvent()
+	event.MatchID = matchID
+	event.Metadata = metadata
+	return event
+}
+
+func TestEventProducer_Produce_RoutesByMatch(t *testing.T) {
+	defaultWriter := &mockWriter{}
+	routed := make(map[string]*routedWriter)
+	producer := newRoutingTestProducer(MatchTopicRouter(map[string]string{"final": "events.final"}), defaultWriter, routed)
+
+	ctx := context.Background()
+	for _, matchID := range []string{"final", "group-stage", "final"} {
+		if err := producer.Produce(ctx, routingTestEvent(matchID, nil)); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	if len(routed) != 1 || routed["events.final"] == nil {
+		t.Fatalf("expected one cached writer for events.final, got %v", routed)
+	}
+	if got := len(routed["events.final"].getMessages()); got != 2 {
+		t.Errorf("expected 2 messages on events.final, got %d", got)
+	}
+	written := defaultWriter.getMessages()
+	if len(written) != 1 || string(written[0].Key) != "group-stage" {
+		t.Errorf("expected the unmapped match on the default topic, got %d messages", len(written))
+	}
+
+	if err := producer.CloseRoutedWriters(); err != nil {
+		t.Fatalf("unexpected error closing routed writers: %v", err)
+	}
+	if !routed["events.final"].closed {
+		t.Error("expected routed writer to be closed")
+	}
+}
+
+func TestEventProducer_CloseRoutedWriters_RefusesNewWriters(t *testing.T) {
+	defaultWriter := &mockWriter{}
+	routed := make(map[string]*routedWriter)
+	producer := newRoutingTestProducer(MatchTopicRouter(map[string]string{"final": "events.final"}), defaultWriter, routed)
+
+	ctx := context.Background()
+	if err := producer.Produce(ctx, routingTestEvent("final", nil)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := producer.CloseRoutedWriters(); err != nil {
+		t.Fatalf("unexpected error closing routed writers: %v", err)
+	}
+	delete(routed, "events.final")
+
+	if err := producer.Produce(ctx, routingTestEvent("final", nil)); !errors.Is(err, ErrRoutedWritersClosed) {
+		t.Fatalf("expected ErrRoutedWritersClosed, got %v", err)
+	}
+	if len(routed) != 0 {
+		t.Errorf("expected no writer to be recreated after close, got %v", routed)
+	}
+	if err := producer.Produce(ctx, routingTestEvent("group-stage", nil)); err != nil {
+		t.Errorf("expected the default topic to keep working, got %v", err)
+	}
+}
+
+func TestEventProducer_ProduceBatch_RoutesByMetadata(t *testing.T) {
+	defaultWriter := &mockWriter{}
+	routed := make(map[string]*routedWriter)
+	router := MetadataTopicRouter("competition_id", map[string]string{
+		"ucl": "events.ucl",
+		"epl": "events.epl",
+	})
+	producer := newRoutingTestProducer(router, defaultWriter, routed)
+
+	events := []*domain.Event{
+		routingTestEvent("m1", map[string]interface{}{"competition_id": "ucl"}),
+		routingTestEvent("m2", map[string]interface{}{"competition_id": "epl"}),
+		routingTestEvent("m3", map[string]interface{}{"competition_id": "serie-a"}),
+		routingTestEvent("m4", nil),
+		routingTestEvent("m5", map[string]interface{}{"competition_id": "ucl"}),
+	}
+	if err := producer.ProduceBatch(context.Background(), events); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	keys := func(msgs []kafka.Message) []string {
+		out := make([]string, len(msgs))
+		for i, msg := range msgs {
+			out[i] = string(msg.Key)
+		}
+		return out
+	}
+	tests := []struct {
+		topic string
+		got   []kafka.Message
+		want  []string
+	}{
+		{"events.ucl", routed["events.ucl"].getMessages(), []string{"m1", "m5"}},
+		{"events.epl", routed["events.epl"].getMessages(), []string{"m2"}},
+		{"default", defaultWriter.getMessages(), []string{"m3", "m4"}},
+	}
+	for _, tt := range tests {
+		got := keys(tt.got)
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: expected %v, got %v", tt.topic, tt.want, got)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("%s: expected %v, got %v", tt.topic, tt.want, got)
+				break
+			}
+		}
+	}
+}
+
+func TestNewTopicRouter(t *testing.T) {
+	routes := map[string]string{"final": "events.final"}
+
+	if NewTopicRouter(RouteByMatchID, nil) != nil {
+		t.Error("expected no router without routes")
+	}
+	if got := NewTopicRouter(RouteByMatchID, routes)(routingTestEvent("final", nil)); got != "events.final" {
+		t.Errorf("expected events.final when routing by match, got %q", got)
+	}
+	byMetadata := NewTopicRouter("stage", routes)
+	if got := byMetadata(routingTestEvent("m1", map[string]interface{}{"stage": "final"})); got != "events.final" {
+		t.Errorf("expected events.final when routing by metadata, got %q", got)
+	}
+	if got := byMetadata(routingTestEvent("final", map[string]interface{}{"stage": 3})); got != "" {
+		t.Errorf("expected a non-string metadata value to fall back, got %q", got)
+	}
+}
+
+func TestParseTopicRoutes(t *testing.T) {
+	routes, err := ParseTopicRoutes(" ucl = events.ucl ,epl=events.epl,")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(routes) != 2 || routes["ucl"] != "events.ucl" || routes["epl"] != "events.epl" {
+		t.Errorf("unexpected routes: %v", routes)
+	}
+
+	if routes, err := ParseTopicRoutes(""); err != nil || len(routes) != 0 {
+		t.Errorf("expected no routes for empty input, got %v, %v", routes, err)
+	}
+	for _, invalid := range []string{"ucl", "ucl=", "=events.ucl", "ucl=a,ucl=b"} {
+		if _, err := ParseTopicRoutes(invalid); err == nil {
+			t.Errorf("expected error for %q", invalid)
+		}
+	}
+}
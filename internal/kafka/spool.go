@@ -0,0 +1,495 @@
+package kafka
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/segmentio/kafka-go"
+
+	"fanfinity/internal/domain"
+	"fanfinity/internal/metrics"
+)
+
+var (
+	kafkaSpoolDepth prometheus.Gauge
+	kafkaSpoolBytes prometheus.Gauge
+)
+
+// registerSpoolMetrics creates the produce spool metrics under opts.
+func registerSpoolMetrics(f promauto.Factory, opts metrics.Options) {
+	ns := opts.NamespaceOrDefault()
+
+	kafkaSpoolDepth = f.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace:   ns,
+			Subsystem:   "kafka_producer",
+			Name:        "spool_depth",
+			Help:        "Number of messages spooled to disk while the broker was unreachable, waiting to be replayed",
+			ConstLabels: opts.ConstLabels,
+		},
+	)
+
+	kafkaSpoolBytes = f.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace:   ns,
+			Subsystem:   "kafka_producer",
+			Name:        "spool_bytes",
+			Help:        "Size of the produce spool file in bytes",
+			ConstLabels: opts.ConstLabels,
+		},
+	)
+}
+
+// Defaults for the produce spool.
+const (
+	DefaultSpoolMaxBytes       = 256 << 20
+	DefaultSpoolReplayInterval = 5 * time.Second
+
+	// spoolReplayBatch bounds the messages written per replay write.
+	spoolReplayBatch = 100
+)
+
+// ErrSpoolFull is returned when appending would grow the spool past its limit.
+var ErrSpoolFull = errors.New("produce spool is full")
+
+// spoolRecord is one spooled message, stored as a line of JSON.
+type spoolRecord struct {
+	Topic   string         `json:"topic"`
+	Key     []byte         `json:"key"`
+	Value   []byte         `json:"value"`
+	Headers []kafka.Header `json:"headers,omitempty"`
+	Time    time.Time      `json:"time"`
+}
+
+// Spool is an append-only file of messages that could not be produced because
+// the broker was unreachable. EventProducer appends to it instead of failing
+// and replays it once the broker recovers; see ReplaySpool. Each append is
+// synced to disk before it is acknowledged, and a spool reopened after a
+// restart keeps its messages. Delivery is at least once: a crash during replay
+// may replay messages again.
+type Spool struct {
+	path     string
+	maxBytes int64
+
+	// lock guards the fields below. It is a channel rather than a mutex so
+	// Append can give up when its context is done.
+	lock  chan struct{}
+	file  *os.File
+	size  int64
+	depth int
+
+	// drainMu serializes drains, which send without holding lock.
+	drainMu sync.Mutex
+}
+
+// OpenSpool opens or creates the spool file at path, holding at most maxBytes.
+// A non-positive maxBytes uses DefaultSpoolMaxBytes.
+func OpenSpool(path string, maxBytes int64) (*Spool, error) {
+	if maxBytes <= 0 {
+		maxBytes = DefaultSpoolMaxBytes
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create spool directory: %w", err)
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open spool: %w", err)
+	}
+
+	s := &Spool{path: path, maxBytes: maxBytes, lock: make(chan struct{}, 1), file: file}
+	if err := s.scan(); err != nil {
+		file.Close()
+		return nil, err
+	}
+	s.updateMetrics()
+	return s, nil
+}
+
+// scan counts the messages already in the spool and measures it. A line cut
+// short by a crash is truncated away so the next append starts a fresh line;
+// other lines that fail to decode are kept in the file but not counted.
+func (s *Spool) scan() error {
+	f, err := os.Open(s.path)
+	if err != nil {
+		return fmt.Errorf("failed to read spool: %w", err)
+	}
+	defer f.Close()
+
+	sr := &spoolReader{r: bufio.NewReader(f)}
+	depth := 0
+	for {
+		r, err := sr.peek()
+		if err != nil {
+			return err
+		}
+		if r == nil {
+			break
+		}
+		depth++
+		sr.offset += int64(sr.nextLen)
+		sr.next = nil
+	}
+
+	info, err := s.file.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to read spool: %w", err)
+	}
+	if info.Size() > sr.offset {
+		if err := s.file.Truncate(sr.offset); err != nil {
+			return fmt.Errorf("failed to truncate partial spool line: %w", err)
+		}
+	}
+	s.size, s.depth = sr.offset, depth
+	return nil
+}
+
+// acquire takes the spool lock, giving up with ctx's error if ctx is done first.
+func (s *Spool) acquire(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	select {
+	case s.lock <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// release releases the spool lock.
+func (s *Spool) release() {
+	<-s.lock
+}
+
+// Append spools msgs for topic. It returns ErrSpoolFull if the spool would grow
+// past its limit, or ctx's error if ctx is done before the spool is free.
+func (s *Spool) Append(ctx context.Context, topic string, msgs ...kafka.Message) error {
+	var buf bytes.Buffer
+	for _, msg := range msgs {
+		line, err := json.Marshal(spoolRecord{
+			Topic:   topic,
+			Key:     msg.Key,
+			Value:   msg.Value,
+			Headers: msg.Headers,
+			Time:    msg.Time,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to encode spooled message: %w", err)
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+
+	if err := s.acquire(ctx); err != nil {
+		return err
+	}
+	defer s.release()
+
+	if s.size+int64(buf.Len()) > s.maxBytes {
+		return ErrSpoolFull
+	}
+	_, err := s.file.Write(buf.Bytes())
+	if err == nil {
+		err = s.file.Sync()
+	}
+	if err != nil {
+		// Drop what was written, so a half-written line is not joined onto
+		// by the next append and the failed messages are not replayed
+		if truncErr := s.file.Truncate(s.size); truncErr != nil {
+			err = errors.Join(err, truncErr)
+			if info, statErr := s.file.Stat(); statErr == nil {
+				s.size = info.Size()
+			}
+		}
+		return fmt.Errorf("failed to write spool: %w", err)
+	}
+	s.size += int64(buf.Len())
+	s.depth += len(msgs)
+	s.updateMetrics()
+	return nil
+}
+
+// Depth returns the number of spooled messages.
+func (s *Spool) Depth() int {
+	_ = s.acquire(context.Background())
+	defer s.release()
+	return s.depth
+}
+
+// Close closes the spool file. Spooled messages stay on disk. Stop ReplaySpool
+// before closing.
+func (s *Spool) Close() error {
+	_ = s.acquire(context.Background())
+	defer s.release()
+	return s.file.Close()
+}
+
+// drain sends spooled messages in order through send, in runs of consecutive
+// messages for the same topic. Sent messages are removed from the spool; on
+// the first failed send the rest are kept for the next drain. It returns the
+// number of messages sent.
+//
+// The file is read a run at a time and send is called without holding the
+// lock, so appends continue during a replay. Only the messages spooled when
+// the drain started are sent; later appends are left for the next drain.
+func (s *Spool) drain(send func(topic string, msgs []kafka.Message) error) (int, error) {
+	s.drainMu.Lock()
+	defer s.drainMu.Unlock()
+
+	_ = s.acquire(context.Background())
+	depth, size := s.depth, s.size
+	s.release()
+	if depth == 0 {
+		return 0, nil
+	}
+	f, err := os.Open(s.path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read spool: %w", err)
+	}
+	defer f.Close()
+
+	sr := &spoolReader{r: bufio.NewReader(io.LimitReader(f, size))}
+	sent := 0
+	var consumed int64
+	var drainErr error
+	for {
+		topic, msgs, err := sr.nextRun()
+		if err != nil {
+			drainErr = err
+			break
+		}
+		if len(msgs) == 0 {
+			consumed = sr.offset
+			break
+		}
+		if err := send(topic, msgs); err != nil {
+			drainErr = err
+			break
+		}
+		sent += len(msgs)
+		consumed = sr.offset
+	}
+
+	if consumed > 0 {
+		if err := s.discard(consumed, sent); err != nil {
+			return sent, errors.Join(drainErr, err)
+		}
+	}
+	return sent, drainErr
+}
+
+// spoolReader reads spooled records one at a time, tracking the bytes consumed.
+type spoolReader struct {
+	r      *bufio.Reader
+	offset int64
+
+	next    *spoolRecord
+	nextLen int
+}
+
+// peek returns the next record without consuming it, or nil at the end of the
+// complete lines. A line without a newline is still being appended, or was cut
+// short by a crash, and is left for a later drain. Other lines that fail to
+// decode are skipped.
+func (sr *spoolReader) peek() (*spoolRecord, error) {
+	for sr.next == nil {
+		line, err := sr.r.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read spool: %w", err)
+		}
+		var r spoolRecord
+		if len(bytes.TrimSpace(line)) == 0 || json.Unmarshal(line, &r) != nil {
+			sr.offset += int64(len(line))
+			continue
+		}
+		sr.next, sr.nextLen = &r, len(line)
+	}
+	return sr.next, nil
+}
+
+// nextRun reads up to spoolReplayBatch consecutive records for one topic. It
+// returns no messages once every complete line has been read.
+func (sr *spoolReader) nextRun() (string, []kafka.Message, error) {
+	var topic string
+	var msgs []kafka.Message
+	for len(msgs) < spoolReplayBatch {
+		r, err := sr.peek()
+		if err != nil {
+			return "", nil, err
+		}
+		if r == nil || (len(msgs) > 0 && r.Topic != topic) {
+			break
+		}
+		topic = r.Topic
+		msgs = append(msgs, kafka.Message{Key: r.Key, Value: r.Value, Headers: r.Headers, Time: r.Time})
+		sr.offset += int64(sr.nextLen)
+		sr.next = nil
+	}
+	return topic, msgs, nil
+}
+
+// discard removes the first n bytes, holding the sent messages and any lines
+// skipped as undecodable, from the spool.
+// The rest, including anything appended during the drain, is copied to a
+// temporary file renamed over the spool so a crash leaves either the old or
+// new contents.
+func (s *Spool) discard(n int64, sent int) error {
+	_ = s.acquire(context.Background())
+	defer s.release()
+
+	src, err := os.Open(s.path)
+	if err != nil {
+		return fmt.Errorf("failed to rewrite spool: %w", err)
+	}
+	defer src.Close()
+	if _, err := src.Seek(n, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to rewrite spool: %w", err)
+	}
+
+	tmpPath := s.path + ".tmp"
+	tmp, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to rewrite spool: %w", err)
+	}
+	size, err := io.Copy(tmp, src)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to rewrite spool: %w", err)
+	}
+	if err := os.Rename(tmpPath, s.path); err != nil {
+		return fmt.Errorf("failed to rewrite spool: %w", err)
+	}
+
+	file, err := os.OpenFile(s.path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to reopen spool: %w", err)
+	}
+	s.file.Close()
+	s.file = file
+	s.size = size
+	s.depth -= sent
+	s.updateMetrics()
+	return nil
+}
+
+// updateMetrics publishes the spool depth and size. The caller must hold the
+// spool lock.
+func (s *Spool) updateMetrics() {
+	kafkaSpoolDepth.Set(float64(s.depth))
+	kafkaSpoolBytes.Set(float64(s.size))
+}
+
+// isBrokerDownError reports whether a produce failed because the broker could
+// not be reached, rather than because of the message or the caller's context.
+func isBrokerDownError(err error) bool {
+	if errors.Is(err, domain.ErrBrokerUnavailable) {
+		return true
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if isRetryableProduceError(err) {
+		return true
+	}
+	// kafka.Error satisfies net.Error but reports a broker-side rejection
+	var kafkaErr kafka.Error
+	if errors.As(err, &kafkaErr) {
+		return false
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, io.EOF)
+}
+
+// spoolFailed spools msgs for topic after a produce failed with err, reporting
+// whether they were spooled. Only broker-down failures are spooled.
+func (p *EventProducer) spoolFailed(ctx context.Context, topic string, msgs []kafka.Message, err error) bool {
+	if p.spool == nil || !isBrokerDownError(err) {
+		return false
+	}
+	if spoolErr := p.spool.Append(ctx, topic, msgs...); spoolErr != nil {
+		p.logger.Error("failed to spool messages while broker is down",
+			slog.String("topic", topic),
+			slog.Int("message_count", len(msgs)),
+			slog.String("error", spoolErr.Error()),
+		)
+		return false
+	}
+	kafkaMessagesProduced.WithLabelValues(topic, "spooled").Add(float64(len(msgs)))
+	return true
+}
+
+// ReplaySpool drains the spool back to Kafka every interval while the broker is
+// considered reachable, until ctx is done. While the broker is down a replay is
+// attempted at most once per probe interval, doubling as the liveness probe.
+// It returns immediately when no spool is configured.
+func (p *EventProducer) ReplaySpool(ctx context.Context, interval time.Duration) {
+	if p.spool == nil {
+		return
+	}
+	if interval <= 0 {
+		interval = DefaultSpoolReplayInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if p.spool.Depth() == 0 || !p.liveness.allow() {
+				continue
+			}
+			p.replaySpool(ctx)
+		}
+	}
+}
+
+// replaySpool makes one pass over the spool.
+func (p *EventProducer) replaySpool(ctx context.Context) {
+	sent, err := p.spool.drain(func(topic string, msgs []kafka.Message) error {
+		err := p.writeWithRetry(ctx, topic, msgs...)
+		p.recordWrite(topic, err)
+		if err == nil {
+			kafkaMessagesProduced.WithLabelValues(topic, "replayed").Add(float64(len(msgs)))
+			for _, msg := range msgs {
+				p.produced.add(string(msg.Key), 1)
+			}
+		}
+		return err
+	})
+	if err != nil {
+		p.logger.Warn("spool replay stopped, remaining messages kept",
+			slog.Int("replayed", sent),
+			slog.Int("remaining", p.spool.Depth()),
+			slog.String("error", err.Error()),
+		)
+		return
+	}
+	if sent > 0 {
+		p.logger.Info("replayed spooled messages to Kafka",
+			slog.Int("replayed", sent),
+		)
+	}
+}
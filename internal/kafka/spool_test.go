@@ -0,0 +1,338 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+
+	"fanfinity/internal/domain"
+)
+
+// outageWriter fails every write with a connection error while down.
+type outageWriter struct {
+	mu      sync.Mutex
+	down    bool
+	written []kafka.Message
+}
+
+func (w *outageWriter) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.down {
+		return &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+	}
+	w.written = append(w.written, msgs...)
+	return nil
+}
+
+func (w *outageWriter) setDown(down bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.down = down
+}
+
+func (w *outageWriter) getWritten() []kafka.Message {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([]kafka.Message(nil), w.written...)
+}
+
+func newSpoolTestProducer(t *testing.T, w MessageWriter, maxBytes int64) (*EventProducer, *Spool) {
+	t.Helper()
+	spool, err := OpenSpool(filepath.Join(t.TempDir(), "spool.jsonl"), maxBytes)
+	if err != nil {
+		t.Fatalf("failed to open spool: %v", err)
+	}
+	t.Cleanup(func() { spool.Close() })
+
+	producer := NewEventProducerWithConfig(&kafka.Writer{Topic: "test-topic"}, nil, ProducerConfig{
+		MaxRetries:   1,
+		RetryBackoff: time.Millisecond,
+		Spool:        spool,
+	})
+	producer.messages = w
+	return producer, spool
+}
+
+func TestEventProducer_SpoolsWhenBrokerDown(t *testing.T) {
+	writer := &outageWriter{down: true}
+	producer, spool := newSpoolTestProducer(t, writer, 0)
+
+	events := []*domain.Event{createTestEvent(), createTestEvent()}
+	if err := producer.Produce(context.Background(), events[0]); err != nil {
+		t.Fatalf("expected produce to succeed by spooling, got: %v", err)
+	}
+	if err := producer.ProduceBatch(context.Background(), events[1:]); err != nil {
+		t.Fatalf("expected batch to succeed by spooling, got: %v", err)
+	}
+
+	if got := spool.Depth(); got != 2 {
+		t.Errorf("expected 2 spooled messages, got %d", got)
+	}
+	if len(writer.getWritten()) != 0 {
+		t.Error("expected nothing written while the broker is down")
+	}
+}
+
+func TestEventProducer_DoesNotSpoolMessageErrors(t *testing.T) {
+	writer := &flakyWriter{failures: 1, err: kafka.MessageSizeTooLarge}
+	producer, spool := newSpoolTestProducer(t, writer, 0)
+
+	if err := producer.Produce(context.Background(), createTestEvent()); err == nil {
+		t.Fatal("expected a rejected message to fail the produce")
+	}
+	if got := spool.Depth(); got != 0 {
+		t.Errorf("expected nothing spooled, got %d", got)
+	}
+}
+
+func TestEventProducer_SpoolFullFailsProduce(t *testing.T) {
+	writer := &outageWriter{down: true}
+	producer, spool := newSpoolTestProducer(t, writer, 10)
+
+	if err := producer.Produce(context.Background(), createTestEvent()); err == nil {
+		t.Fatal("expected produce to fail when the spool is full")
+	}
+	if got := spool.Depth(); got != 0 {
+		t.Errorf("expected nothing spooled, got %d", got)
+	}
+}
+
+func TestEventProducer_ReplaySpoolDrainsOnRecovery(t *testing.T) {
+	writer := &outageWriter{down: true}
+	producer, spool := newSpoolTestProducer(t, writer, 0)
+
+	events := []*domain.Event{createTestEvent(), createTestEvent(), createTestEvent()}
+	for _, event := range events {
+		if err := producer.Produce(context.Background(), event); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	// Still down: nothing is lost and the spool is kept
+	producer.replaySpool(context.Background())
+	if got := spool.Depth(); got != len(events) {
+		t.Fatalf("expected %d messages kept after a failed replay, got %d", len(events), got)
+	}
+
+	writer.setDown(false)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		producer.ReplaySpool(ctx, 5*time.Millisecond)
+		close(done)
+	}()
+
+	deadline := time.After(2 * time.Second)
+	for spool.Depth() > 0 {
+		select {
+		case <-deadline:
+			cancel()
+			t.Fatal("timed out waiting for the spool to drain")
+		case <-time.After(5 * time.Millisecond):
+		}
+	}
+	cancel()
+	<-done
+
+	written := writer.getWritten()
+	if len(written) != len(events) {
+		t.Fatalf("expected %d replayed messages, got %d", len(events), len(written))
+	}
+	for i, msg := range written {
+		if got := eventIDHeader(msg); got != events[i].EventID.String() {
+			t.Errorf("message %d: expected event %s in order, got %s", i, events[i].EventID, got)
+		}
+	}
+
+	info, err := os.Stat(spool.path)
+	if err != nil {
+		t.Fatalf("failed to stat spool: %v", err)
+	}
+	if info.Size() != 0 {
+		t.Errorf("expected an empty spool file after draining, got %d bytes", info.Size())
+	}
+}
+
+func TestOpenSpool_KeepsMessagesAcrossRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "spool.jsonl")
+	spool, err := OpenSpool(path, 0)
+	if err != nil {
+		t.Fatalf("failed to open spool: %v", err)
+	}
+	if err := spool.Append(context.Background(), "test-topic", kafka.Message{Key: []byte("match-1"), Value: []byte(`{}`)}, kafka.Message{Key: []byte("match-2"), Value: []byte(`{}`)}); err != nil {
+		t.Fatalf("failed to append: %v", err)
+	}
+	spool.Close()
+
+	reopened, err := OpenSpool(path, 0)
+	if err != nil {
+		t.Fatalf("failed to reopen spool: %v", err)
+	}
+	defer reopened.Close()
+	if got := reopened.Depth(); got != 2 {
+		t.Errorf("expected 2 messages after reopening, got %d", got)
+	}
+}
+
+func TestOpenSpool_TruncatesPartialLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "spool.jsonl")
+	spool, err := OpenSpool(path, 0)
+	if err != nil {
+		t.Fatalf("failed to open spool: %v", err)
+	}
+	if err := spool.Append(context.Background(), "test-topic", kafka.Message{Key: []byte("match-1"), Value: []byte(`{}`)}); err != nil {
+		t.Fatalf("failed to append: %v", err)
+	}
+	spool.Close()
+
+	// A crash cut the next append short
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatalf("failed to open spool file: %v", err)
+	}
+	if _, err := f.WriteString(`{"topic":"test-topic","key":`); err != nil {
+		t.Fatalf("failed to write partial line: %v", err)
+	}
+	f.Close()
+
+	reopened, err := OpenSpool(path, 0)
+	if err != nil {
+		t.Fatalf("failed to reopen spool: %v", err)
+	}
+	defer reopened.Close()
+	if err := reopened.Append(context.Background(), "test-topic", kafka.Message{Key: []byte("match-2"), Value: []byte(`{}`)}); err != nil {
+		t.Fatalf("failed to append: %v", err)
+	}
+
+	var sentKeys []string
+	if _, err := reopened.drain(func(topic string, msgs []kafka.Message) error {
+		for _, msg := range msgs {
+			sentKeys = append(sentKeys, string(msg.Key))
+		}
+		return nil
+	}); err != nil {
+		t.Fatalf("unexpected drain error: %v", err)
+	}
+	if len(sentKeys) != 2 || sentKeys[1] != "match-2" {
+		t.Errorf("expected the append after the partial line to be replayed, got %v", sentKeys)
+	}
+}
+
+func TestSpool_DepthIgnoresUndecodableLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "spool.jsonl")
+	if err := os.WriteFile(path, []byte("not json\n"), 0o644); err != nil {
+		t.Fatalf("failed to write spool file: %v", err)
+	}
+	spool, err := OpenSpool(path, 0)
+	if err != nil {
+		t.Fatalf("failed to open spool: %v", err)
+	}
+	defer spool.Close()
+	if err := spool.Append(context.Background(), "test-topic", kafka.Message{Key: []byte("match-1"), Value: []byte(`{}`)}); err != nil {
+		t.Fatalf("failed to append: %v", err)
+	}
+	if got := spool.Depth(); got != 1 {
+		t.Fatalf("expected only the decodable message counted, got depth %d", got)
+	}
+
+	sent, err := spool.drain(func(topic string, msgs []kafka.Message) error { return nil })
+	if err != nil {
+		t.Fatalf("unexpected drain error: %v", err)
+	}
+	if sent != 1 {
+		t.Errorf("expected 1 message sent, got %d", sent)
+	}
+	if got := spool.Depth(); got != 0 {
+		t.Errorf("expected an empty spool, got depth %d", got)
+	}
+	if info, err := os.Stat(path); err != nil || info.Size() != 0 {
+		t.Errorf("expected the skipped line removed from the file, got %v, %v", info, err)
+	}
+}
+
+func TestSpool_AppendHonoursContext(t *testing.T) {
+	spool, err := OpenSpool(filepath.Join(t.TempDir(), "spool.jsonl"), 0)
+	if err != nil {
+		t.Fatalf("failed to open spool: %v", err)
+	}
+	defer spool.Close()
+
+	// Hold the spool as a long rewrite would
+	spool.lock <- struct{}{}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err = spool.Append(ctx, "test-topic", kafka.Message{Key: []byte("match-1"), Value: []byte(`{}`)})
+	spool.release()
+
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the append to give up with its context, got %v", err)
+	}
+	if got := spool.Depth(); got != 0 {
+		t.Errorf("expected nothing spooled, got %d", got)
+	}
+}
+
+func TestSpool_AppendDuringDrainIsKept(t *testing.T) {
+	spool, err := OpenSpool(filepath.Join(t.TempDir(), "spool.jsonl"), 0)
+	if err != nil {
+		t.Fatalf("failed to open spool: %v", err)
+	}
+	defer spool.Close()
+
+	ctx := context.Background()
+	if err := spool.Append(ctx, "test-topic", kafka.Message{Key: []byte("match-1"), Value: []byte(`{}`)}); err != nil {
+		t.Fatalf("failed to append: %v", err)
+	}
+
+	var sentKeys []string
+	sent, err := spool.drain(func(topic string, msgs []kafka.Message) error {
+		for _, msg := range msgs {
+			sentKeys = append(sentKeys, string(msg.Key))
+		}
+		// Spooling continues while a replay is sending
+		return spool.Append(ctx, "test-topic", kafka.Message{Key: []byte("match-2"), Value: []byte(`{}`)})
+	})
+	if err != nil {
+		t.Fatalf("unexpected drain error: %v", err)
+	}
+	if sent != 1 || len(sentKeys) != 1 || sentKeys[0] != "match-1" {
+		t.Fatalf("expected only match-1 sent, got %d messages %v", sent, sentKeys)
+	}
+	if got := spool.Depth(); got != 1 {
+		t.Fatalf("expected the message appended during the drain to stay spooled, got depth %d", got)
+	}
+
+	sentKeys = nil
+	if _, err := spool.drain(func(topic string, msgs []kafka.Message) error {
+		for _, msg := range msgs {
+			sentKeys = append(sentKeys, string(msg.Key))
+		}
+		return nil
+	}); err != nil {
+		t.Fatalf("unexpected drain error: %v", err)
+	}
+	if len(sentKeys) != 1 || sentKeys[0] != "match-2" {
+		t.Errorf("expected match-2 on the next drain, got %v", sentKeys)
+	}
+	if got := spool.Depth(); got != 0 {
+		t.Errorf("expected an empty spool, got depth %d", got)
+	}
+}
+
+// eventIDHeader returns the event_id header of msg.
+func eventIDHeader(msg kafka.Message) string {
+	for _, header := range msg.Headers {
+		if header.Key == "event_id" {
+			return string(header.Value)
+		}
+	}
+	return ""
+}
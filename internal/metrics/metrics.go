@@ -0,0 +1,77 @@
+// Package metrics holds the naming options shared by the packages that
+// export Prometheus metrics.
+package metrics
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// DefaultNamespace prefixes metric names when no namespace is configured.
+const DefaultNamespace = "fanfinity"
+
+// Options controls how metric vectors are named and labelled at registration.
+type Options struct {
+	// Namespace prefixes metric names. Empty uses DefaultNamespace.
+	Namespace string
+	// ConstLabels are added to every metric, e.g. a deployment or tenant label.
+	ConstLabels prometheus.Labels
+}
+
+// NamespaceOrDefault returns the configured namespace, or DefaultNamespace if unset.
+func (o Options) NamespaceOrDefault() string {
+	if o.Namespace == "" {
+		return DefaultNamespace
+	}
+	return o.Namespace
+}
+
+// Set tracks the collectors a package registered so that registering again,
+// for example under a different namespace at startup, replaces them rather
+// than leaving the old names exported.
+type Set struct {
+	mu         sync.Mutex
+	reg        prometheus.Registerer
+	collectors []prometheus.Collector
+}
+
+// Factory unregisters any collectors previously registered through s and
+// returns a factory that registers new ones with reg.
+func (s *Set) Factory(reg prometheus.Registerer) promauto.Factory {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, c := range s.collectors {
+		s.reg.Unregister(c)
+	}
+	s.reg = reg
+	s.collectors = nil
+	return promauto.With(&trackingRegisterer{Registerer: reg, set: s})
+}
+
+// trackingRegisterer records successful registrations in its Set.
+type trackingRegisterer struct {
+	prometheus.Registerer
+	set *Set
+}
+
+func (r *trackingRegisterer) Register(c prometheus.Collector) error {
+	if err := r.Registerer.Register(c); err != nil {
+		return err
+	}
+	r.track(c)
+	return nil
+}
+
+func (r *trackingRegisterer) MustRegister(cs ...prometheus.Collector) {
+	r.Registerer.MustRegister(cs...)
+	r.track(cs...)
+}
+
+func (r *trackingRegisterer) track(cs ...prometheus.Collector) {
+	r.set.mu.Lock()
+	defer r.set.mu.Unlock()
+	r.set.collectors = append(r.set.collectors, cs...)
+}
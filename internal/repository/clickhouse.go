@@ -2,82 +2,420 @@ package repository
 
 import (
 	"context"
+	"crypto/sha256"
+	"database/sql"
+	sqldriver "database/sql/driver"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
+	"net"
+	"regexp"
+	"slices"
+	"sort"
 	"strconv"
+	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/ClickHouse/clickhouse-go/v2"
 	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+	"github.com/google/uuid"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 
 	"fanfinity/internal/domain"
+	"fanfinity/internal/metrics"
 )
 
 var (
 	// Prometheus metrics for ClickHouse repository
-	clickhouseQueryDuration = promauto.NewHistogramVec(
+	clickhouseQueryDuration  *prometheus.HistogramVec
+	clickhouseQueryErrors    *prometheus.CounterVec
+	clickhouseBatchSize      *prometheus.HistogramVec
+	clickhouseEventsInserted prometheus.Counter
+	clickhouseStaleVersions  prometheus.Counter
+)
+
+var metricSet metrics.Set
+
+func init() {
+	RegisterMetrics(prometheus.DefaultRegisterer, metrics.Options{})
+}
+
+// RegisterMetrics creates the ClickHouse metrics under opts and registers them
+// with reg, replacing any registered by an earlier call. Call it at startup,
+// before the repository is used.
+func RegisterMetrics(reg prometheus.Registerer, opts metrics.Options) {
+	registerMetrics(metricSet.Factory(reg), opts)
+}
+
+// registerMetrics creates the repository metrics under opts.
+func registerMetrics(f promauto.Factory, opts metrics.Options) {
+	ns := opts.NamespaceOrDefault()
+
+	clickhouseQueryDuration = f.NewHistogramVec(
 		prometheus.HistogramOpts{
-			Namespace: "fanfinity",
-			Subsystem: "clickhouse",
-			Name:      "query_duration_seconds",
-			Help:      "Histogram of ClickHouse query latency in seconds",
-			Buckets:   []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1.0, 2.5, 5.0},
+			Namespace:   ns,
+			Subsystem:   "clickhouse",
+			Name:        "query_duration_seconds",
+			Help:        "Histogram of ClickHouse query latency in seconds",
+			ConstLabels: opts.ConstLabels,
+			Buckets:     []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1.0, 2.5, 5.0},
 		},
 		[]string{"operation"},
 	)
 
-	clickhouseQueryErrors = promauto.NewCounterVec(
+	clickhouseQueryErrors = f.NewCounterVec(
 		prometheus.CounterOpts{
-			Namespace: "fanfinity",
-			Subsystem: "clickhouse",
-			Name:      "query_errors_total",
-			Help:      "Total number of ClickHouse query errors",
+			Namespace:   ns,
+			Subsystem:   "clickhouse",
+			Name:        "query_errors_total",
+			Help:        "Total number of ClickHouse query errors",
+			ConstLabels: opts.ConstLabels,
 		},
 		[]string{"operation"},
 	)
 
-	clickhouseBatchSize = promauto.NewHistogramVec(
+	clickhouseBatchSize = f.NewHistogramVec(
 		prometheus.HistogramOpts{
-			Namespace: "fanfinity",
-			Subsystem: "clickhouse",
-			Name:      "batch_size",
-			Help:      "Histogram of batch insert sizes",
-			Buckets:   []float64{1, 10, 50, 100, 500, 1000, 5000, 10000},
+			Namespace:   ns,
+			Subsystem:   "clickhouse",
+			Name:        "batch_size",
+			Help:        "Histogram of batch insert sizes",
+			ConstLabels: opts.ConstLabels,
+			Buckets:     []float64{1, 10, 50, 100, 500, 1000, 5000, 10000},
 		},
 		[]string{},
 	)
 
-	clickhouseEventsInserted = promauto.NewCounter(
+	clickhouseEventsInserted = f.NewCounter(
 		prometheus.CounterOpts{
-			Namespace: "fanfinity",
-			Subsystem: "clickhouse",
-			Name:      "events_inserted_total",
-			Help:      "Total number of events inserted into ClickHouse",
+			Namespace:   ns,
+			Subsystem:   "clickhouse",
+			Name:        "events_inserted_total",
+			Help:        "Total number of events inserted into ClickHouse",
+			ConstLabels: opts.ConstLabels,
 		},
 	)
-)
+
+	clickhouseStaleVersions = f.NewCounter(
+		prometheus.CounterOpts{
+			Namespace:   ns,
+			Subsystem:   "clickhouse",
+			Name:        "upsert_stale_versions_total",
+			Help:        "Upserted events whose replaced versions could not be deleted after the new version was stored",
+			ConstLabels: opts.ConstLabels,
+		},
+	)
+}
+
+// ErrNotConnected is returned by repository methods called without a ClickHouse
+// connection, e.g. on a repository built with NewClickHouseRepository(nil, nil).
+// It is a *domain.RepositoryError in the connection category.
+var ErrNotConnected error = domain.NewRepositoryError(domain.RepositoryErrorConnection, "", errors.New("repository not connected"))
+
+// queryError wraps the failure of a query as a *domain.RepositoryError.
+func queryError(op string, err error) error {
+	return repositoryError(domain.RepositoryErrorQuery, op, err)
+}
+
+// scanError wraps a result that could not be decoded as a *domain.RepositoryError.
+func scanError(op string, err error) error {
+	return repositoryError(domain.RepositoryErrorScan, op, err)
+}
+
+// repositoryError wraps err as a *domain.RepositoryError. Deadline and
+// connection failures are recognized from err itself and take precedence over
+// category, since any call can fail that way.
+func repositoryError(category domain.RepositoryErrorCategory, op string, err error) error {
+	switch {
+	case isTimeoutError(err):
+		category = domain.RepositoryErrorTimeout
+	case isConnectionError(err):
+		category = domain.RepositoryErrorConnection
+	}
+	return domain.NewRepositoryError(category, op, err)
+}
+
+// isTimeoutError reports whether err is a passed deadline or a network timeout.
+func isTimeoutError(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// isConnectionError reports whether err means ClickHouse could not be reached
+// or dropped the connection, as opposed to rejecting the query.
+func isConnectionError(err error) bool {
+	if errors.Is(err, clickhouse.ErrAcquireConnTimeout) || errors.Is(err, sqldriver.ErrBadConn) ||
+		errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+	var opErr *net.OpError
+	var dnsErr *net.DNSError
+	return errors.As(err, &opErr) || errors.As(err, &dnsErr)
+}
+
+// unknownTableCode is ClickHouse's UNKNOWN_TABLE error code.
+const unknownTableCode = 60
+
+// isUnknownTableError reports whether ClickHouse rejected a query because the
+// table it reads does not exist.
+func isUnknownTableError(err error) bool {
+	var ex *clickhouse.Exception
+	return errors.As(err, &ex) && ex.Code == unknownTableCode
+}
 
 // ClickHouseRepository handles ClickHouse database operations.
 type ClickHouseRepository struct {
 	conn   driver.Conn
 	logger *slog.Logger
+	config RepositoryConfig
+	table  string // qualified database.table identifier, validated at construction
+
+	matchInfoTable   string // qualified database.table for registered match details
+	matchFinalTable  string // qualified database.table for final snapshots of closed matches
+	matchLagTable    string // qualified database.table for per-match consumer lag
+	eventStatusTable string // qualified database.table for statuses of confirmed events
+
+	// optionalMu guards the configured optional columns last found in the
+	// events table, looked up again once optionalCheckedAt is older than
+	// OptionalColumnsRecheck, or optionalFailedAt older than
+	// optionalColumnsRetry after a failed lookup. optionalLookup is set while
+	// a lookup runs, which it does without holding optionalMu.
+	optionalMu        sync.Mutex
+	optionalPresent   []string
+	optionalCheckedAt time.Time
+	optionalFailedAt  time.Time
+	optionalLookup    bool
+}
+
+// Default database and tables holding match events and match details.
+const (
+	DefaultDatabase         = "fanfinity"
+	DefaultTable            = "match_events"
+	DefaultMatchInfoTable   = "match_info"
+	DefaultMatchFinalTable  = "match_final"
+	DefaultMatchLagTable    = "match_lag"
+	DefaultEventStatusTable = "event_status"
+)
+
+// EventSchemaVersion is the version of the event row layout, written to the
+// optional schema_version column.
+const EventSchemaVersion = 1
+
+// optionalEventColumns are the events table columns InsertBatch can write
+// besides the required ones, with the value written for each event.
+var optionalEventColumns = map[string]func(*domain.Event) any{
+	"schema_version": func(*domain.Event) any { return uint16(EventSchemaVersion) },
+}
+
+// OptionalColumnsRecheck is how long InsertBatch trusts its lookup of which
+// optional columns the events table has, so a column added by a migration is
+// written without restarting the consumer.
+const OptionalColumnsRecheck = time.Minute
+
+// optionalColumnsRetry is how long a failed optional columns lookup is not
+// retried, so an unreachable system.columns does not add a query to every insert.
+const optionalColumnsRetry = 10 * time.Second
+
+// MatchLagMaxAge is how long a published match lag is trusted. Lag published
+// longer ago is ignored, so a match is not throttled forever once the consumer
+// stops reporting it.
+const MatchLagMaxAge = 2 * time.Minute
+
+// identifierPattern matches ClickHouse identifiers that are safe to interpolate into SQL.
+var identifierPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// RepositoryConfig holds query-level settings for the ClickHouse repository.
+type RepositoryConfig struct {
+	// EngagementWeights weights each event type when computing the peak minute.
+	EngagementWeights domain.EngagementWeights
+
+	// Phases are the match phases live metrics are split into, by offset from
+	// the match's first event.
+	Phases domain.MatchPhases
+
+	// Database and Table name the events table, e.g. a staging or Distributed table.
+	Database string
+	Table    string
+
+	// MatchInfoTable names the table of registered match details, in Database.
+	MatchInfoTable string
+
+	// MatchFinalTable names the table of final metrics snapshots of closed matches, in Database.
+	MatchFinalTable string
+
+	// MatchLagTable names the table of per-match consumer lag, in Database.
+	MatchLagTable string
+
+	// EventStatusTable names the table of statuses of events sent with
+	// X-Confirm, in Database.
+	EventStatusTable string
+
+	// Insert holds ClickHouse settings attached to every InsertBatch call.
+	Insert InsertSettings
+
+	// OptionalColumns are events table columns InsertBatch writes only when
+	// the table has them, so a migration adding one need not be deployed in
+	// lockstep with the consumers. Supported: schema_version.
+	OptionalColumns []string
+
+	// InsertMaxExecutionTime and ReadMaxExecutionTime bound how long writes and
+	// dashboard reads may run, so a runaway analytical read can be killed early
+	// while inserts keep the full budget. Zero leaves the connection's
+	// max_execution_time in place. StreamEvents, used for replays, is not bounded.
+	InsertMaxExecutionTime time.Duration
+	ReadMaxExecutionTime   time.Duration
+
+	// UseJSONNumber decodes stored metadata numbers as json.Number rather than
+	// float64, so integers read back exactly.
+	UseJSONNumber bool
+
+	// ReadFinal adds FINAL to the events table in the match metrics and
+	// events-per-minute queries, so a ReplacingMergeTree table is read with
+	// duplicates already merged away. FINAL merges the match's parts at query
+	// time, which makes these reads markedly slower and more memory hungry on
+	// tables with many unmerged parts; leave it off unless the table relies on
+	// merge-time deduplication.
+	ReadFinal bool
+
+	// Clock stamps the closing time of closed matches. Nil means
+	// domain.SystemClock.
+	Clock domain.Clock
+}
+
+// InsertSettings configures per-insert ClickHouse settings for replicated tables.
+// The zero value attaches no settings and leaves the server defaults in place.
+type InsertSettings struct {
+	// Quorum sets insert_quorum, the number of replicas that must acknowledge a write.
+	// Zero leaves the setting unset.
+	Quorum int
+
+	// Deduplicate sets insert_deduplicate=1, dropping inserts whose block was already written.
+	Deduplicate bool
+
+	// DeduplicationToken attaches an insert_deduplication_token derived from the batch's
+	// event IDs, so a retried batch is deduplicated regardless of how it was re-split
+	// into blocks. Implies Deduplicate.
+	DeduplicationToken bool
+}
+
+// DefaultRepositoryConfig returns the default repository configuration.
+func DefaultRepositoryConfig() RepositoryConfig {
+	return RepositoryConfig{
+		EngagementWeights: domain.DefaultEngagementWeights(),
+		Phases:            domain.DefaultMatchPhases(),
+		Database:          DefaultDatabase,
+		Table:             DefaultTable,
+		MatchInfoTable:    DefaultMatchInfoTable,
+		MatchFinalTable:   DefaultMatchFinalTable,
+		MatchLagTable:     DefaultMatchLagTable,
+		EventStatusTable:  DefaultEventStatusTable,
+	}
+}
+
+// ValidateIdentifier returns an error unless name is a plain ClickHouse identifier
+// (letters, digits and underscores, not starting with a digit).
+func ValidateIdentifier(name string) error {
+	if !identifierPattern.MatchString(name) {
+		return fmt.Errorf("invalid ClickHouse identifier %q", name)
+	}
+	return nil
 }
 
 // NewClickHouseRepository creates a new ClickHouseRepository instance.
 func NewClickHouseRepository(conn driver.Conn, logger *slog.Logger) *ClickHouseRepository {
+	repo, _ := NewClickHouseRepositoryWithConfig(conn, logger, DefaultRepositoryConfig())
+	return repo
+}
+
+// NewClickHouseRepositoryWithConfig creates a new ClickHouseRepository with custom configuration.
+// Returns an error if the configured database or table name is not a safe identifier.
+func NewClickHouseRepositoryWithConfig(conn driver.Conn, logger *slog.Logger, cfg RepositoryConfig) (*ClickHouseRepository, error) {
 	if logger == nil {
 		logger = slog.Default()
 	}
-	return &ClickHouseRepository{
-		conn:   conn,
-		logger: logger,
+	if cfg.EngagementWeights == nil {
+		cfg.EngagementWeights = domain.DefaultEngagementWeights()
+	}
+	if len(cfg.Phases) == 0 {
+		cfg.Phases = domain.DefaultMatchPhases()
+	}
+	if err := cfg.Phases.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid match phases: %w", err)
+	}
+	if cfg.Database == "" {
+		cfg.Database = DefaultDatabase
+	}
+	if cfg.Table == "" {
+		cfg.Table = DefaultTable
+	}
+	if cfg.MatchInfoTable == "" {
+		cfg.MatchInfoTable = DefaultMatchInfoTable
+	}
+	if cfg.MatchFinalTable == "" {
+		cfg.MatchFinalTable = DefaultMatchFinalTable
+	}
+	if cfg.MatchLagTable == "" {
+		cfg.MatchLagTable = DefaultMatchLagTable
+	}
+	if cfg.EventStatusTable == "" {
+		cfg.EventStatusTable = DefaultEventStatusTable
+	}
+	cfg.Clock = domain.ClockOrSystem(cfg.Clock)
+	if err := ValidateIdentifier(cfg.Database); err != nil {
+		return nil, fmt.Errorf("invalid database name: %w", err)
+	}
+	if err := ValidateIdentifier(cfg.Table); err != nil {
+		return nil, fmt.Errorf("invalid table name: %w", err)
 	}
+	if err := ValidateIdentifier(cfg.MatchInfoTable); err != nil {
+		return nil, fmt.Errorf("invalid match info table name: %w", err)
+	}
+	if err := ValidateIdentifier(cfg.MatchFinalTable); err != nil {
+		return nil, fmt.Errorf("invalid match final table name: %w", err)
+	}
+	if err := ValidateIdentifier(cfg.MatchLagTable); err != nil {
+		return nil, fmt.Errorf("invalid match lag table name: %w", err)
+	}
+	if err := ValidateIdentifier(cfg.EventStatusTable); err != nil {
+		return nil, fmt.Errorf("invalid event status table name: %w", err)
+	}
+	for i, column := range cfg.OptionalColumns {
+		if _, ok := optionalEventColumns[column]; !ok {
+			return nil, fmt.Errorf("unsupported optional column %q", column)
+		}
+		if slices.Contains(cfg.OptionalColumns[:i], column) {
+			return nil, fmt.Errorf("duplicate optional column %q", column)
+		}
+	}
+
+	return &ClickHouseRepository{
+		conn:             conn,
+		logger:           logger,
+		config:           cfg,
+		table:            cfg.Database + "." + cfg.Table,
+		matchInfoTable:   cfg.Database + "." + cfg.MatchInfoTable,
+		matchFinalTable:  cfg.Database + "." + cfg.MatchFinalTable,
+		matchLagTable:    cfg.Database + "." + cfg.MatchLagTable,
+		eventStatusTable: cfg.Database + "." + cfg.EventStatusTable,
+	}, nil
 }
 
 // Ping performs a health check on the ClickHouse connection.
 func (r *ClickHouseRepository) Ping(ctx context.Context) error {
+	if r.conn == nil {
+		return ErrNotConnected
+	}
+
 	startTime := time.Now()
 	err := r.conn.Ping(ctx)
 	duration := time.Since(startTime)
@@ -90,7 +428,7 @@ func (r *ClickHouseRepository) Ping(ctx context.Context) error {
 			slog.String("error", err.Error()),
 		)
 		clickhouseQueryErrors.WithLabelValues("ping").Inc()
-		return fmt.Errorf("ClickHouse ping failed: %w", err)
+		return repositoryError(domain.RepositoryErrorConnection, "ClickHouse ping failed", err)
 	}
 
 	r.logger.Debug("ClickHouse ping successful",
@@ -99,41 +437,97 @@ func (r *ClickHouseRepository) Ping(ctx context.Context) error {
 	return nil
 }
 
-// InsertBatch inserts a batch of events into the fanfinity.match_events table.
+// CheckSchema runs a zero-row SELECT against the events table. ClickHouse can
+// answer pings before the table is queryable during startup, so this is the
+// check that decides the service is warmed up.
+func (r *ClickHouseRepository) CheckSchema(ctx context.Context) error {
+	if r.conn == nil {
+		return ErrNotConnected
+	}
+
+	ctx, cancel := r.readContext(ctx)
+	defer cancel()
+
+	startTime := time.Now()
+	rows, err := r.conn.Query(ctx, fmt.Sprintf("SELECT 1 FROM %s LIMIT 0", r.table))
+	if err == nil {
+		err = rows.Close()
+	}
+	clickhouseQueryDuration.WithLabelValues("check_schema").Observe(time.Since(startTime).Seconds())
+	if err != nil {
+		clickhouseQueryErrors.WithLabelValues("check_schema").Inc()
+		return queryError(fmt.Sprintf("events table %s is not queryable", r.table), err)
+	}
+	return nil
+}
+
+// InsertBatch inserts a batch of events into the configured events table.
 // Uses ClickHouse batch insert for optimal performance.
 func (r *ClickHouseRepository) InsertBatch(ctx context.Context, events []*domain.Event) error {
 	if len(events) == 0 {
 		return nil
 	}
 
+	if r.conn == nil {
+		return ErrNotConnected
+	}
+
 	startTime := time.Now()
 
+	if r.config.InsertMaxExecutionTime > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.config.InsertMaxExecutionTime)
+		defer cancel()
+	}
+	if settings := r.insertSettings(events); len(settings) > 0 {
+		ctx = withSettings(ctx, settings)
+	}
+
 	// Prepare batch insert
-	batch, err := r.conn.PrepareBatch(ctx, `
-		INSERT INTO fanfinity.match_events (
-			event_id,
-			match_id,
-			event_type,
-			team_id,
-			player_id,
-			metadata,
-			timestamp
-		) VALUES (?, ?, ?, ?, ?, ?, ?)
-	`)
+	optional := r.presentOptionalColumns(ctx)
+	columns := append([]string{
+		"event_id",
+		"match_id",
+		"event_type",
+		"team_id",
+		"player_id",
+		"metadata",
+		"timestamp",
+	}, optional...)
+	batch, err := r.conn.PrepareBatch(ctx, fmt.Sprintf(`
+		INSERT INTO %s (%s) VALUES (%s)
+	`, r.table, strings.Join(columns, ", "), strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", ")))
 	if err != nil {
 		r.logger.Error("failed to prepare batch insert",
 			slog.String("error", err.Error()),
 		)
 		clickhouseQueryErrors.WithLabelValues("insert_batch_prepare").Inc()
-		return fmt.Errorf("failed to prepare batch insert: %w", err)
+		return queryError("failed to prepare batch insert", err)
 	}
 
-	// Append each event to the batch
+	// Append each event to the batch, setting aside events that cannot be
+	// stored so the caller can dead-letter them
+	var rejected []*domain.UnstorableEventError
 	for _, event := range events {
 		if event == nil {
 			continue
 		}
 
+		metadataJSON, err := event.StorableMetadata()
+		if err != nil {
+			var ue *domain.UnstorableEventError
+			if !errors.As(err, &ue) {
+				ue = &domain.UnstorableEventError{Event: event, Field: "metadata", Err: err}
+			}
+			r.logger.Warn("rejecting unstorable event",
+				slog.String("event_id", event.EventID.String()),
+				slog.String("field", ue.Field),
+				slog.String("error", ue.Err.Error()),
+			)
+			rejected = append(rejected, ue)
+			continue
+		}
+
 		// Convert TeamID to string for ClickHouse schema
 		teamIDStr := strconv.Itoa(event.TeamID)
 
@@ -143,10 +537,7 @@ func (r *ClickHouseRepository) InsertBatch(ctx context.Context, events []*domain
 			playerID = &event.PlayerID
 		}
 
-		// Serialize metadata to JSON string
-		metadataJSON := event.MetadataJSON()
-
-		err = batch.Append(
+		values := []any{
 			event.EventID,
 			event.MatchID,
 			string(event.EventType),
@@ -154,13 +545,18 @@ func (r *ClickHouseRepository) InsertBatch(ctx context.Context, events []*domain
 			playerID,
 			metadataJSON,
 			event.Timestamp,
-		)
+		}
+		for _, column := range optional {
+			values = append(values, optionalEventColumns[column](event))
+		}
+		err = batch.Append(values...)
 		if err != nil {
 			r.logger.Warn("failed to append event to batch",
 				slog.String("event_id", event.EventID.String()),
 				slog.String("error", err.Error()),
 			)
-			// Continue with other events rather than failing the entire batch
+			// Reject the event rather than failing the entire batch
+			rejected = append(rejected, &domain.UnstorableEventError{Event: event, Field: "row", Err: err})
 			continue
 		}
 	}
@@ -180,47 +576,378 @@ func (r *ClickHouseRepository) InsertBatch(ctx context.Context, events []*domain
 			slog.String("error", err.Error()),
 		)
 		clickhouseQueryErrors.WithLabelValues("insert_batch_send").Inc()
-		return fmt.Errorf("failed to send batch insert: %w", err)
+		return queryError("failed to send batch insert", err)
 	}
 
 	r.logger.Debug("successfully inserted batch",
 		slog.Int("batch_size", len(events)),
 		slog.Duration("duration", duration),
 	)
-	clickhouseEventsInserted.Add(float64(len(events)))
+	clickhouseEventsInserted.Add(float64(len(events) - len(rejected)))
+
+	if len(rejected) > 0 {
+		return &domain.PartialInsertError{Rejected: rejected}
+	}
+	return nil
+}
+
+// presentOptionalColumns returns the configured optional columns the events
+// table has, in configured order. The lookup is cached for
+// OptionalColumnsRecheck, and inserts that arrive while it runs use the
+// previous result. If it fails, no optional columns are written, which any
+// version of the table accepts, until a retry after optionalColumnsRetry.
+func (r *ClickHouseRepository) presentOptionalColumns(ctx context.Context) []string {
+	if len(r.config.OptionalColumns) == 0 {
+		return nil
+	}
+
+	r.optionalMu.Lock()
+	fresh := !r.optionalCheckedAt.IsZero() && time.Since(r.optionalCheckedAt) < OptionalColumnsRecheck
+	backingOff := !r.optionalFailedAt.IsZero() && time.Since(r.optionalFailedAt) < optionalColumnsRetry
+	if fresh || r.optionalLookup || backingOff {
+		var present []string
+		if !backingOff {
+			present = r.optionalPresent
+		}
+		r.optionalMu.Unlock()
+		return present
+	}
+	r.optionalLookup = true
+	r.optionalMu.Unlock()
+
+	present, err := r.lookupOptionalColumns(ctx)
+
+	r.optionalMu.Lock()
+	defer r.optionalMu.Unlock()
+	r.optionalLookup = false
+	if err != nil {
+		clickhouseQueryErrors.WithLabelValues("optional_columns").Inc()
+		r.logger.Warn("failed to look up optional columns, writing required columns only",
+			slog.String("error", err.Error()),
+		)
+		r.optionalFailedAt = time.Now()
+		return nil
+	}
+	if !slices.Equal(present, r.optionalPresent) || r.optionalCheckedAt.IsZero() {
+		r.logger.Info("optional event columns resolved",
+			slog.Any("present", present),
+			slog.Any("configured", r.config.OptionalColumns),
+		)
+	}
+	r.optionalPresent = present
+	r.optionalCheckedAt = time.Now()
+	r.optionalFailedAt = time.Time{}
+	return present
+}
+
+// lookupOptionalColumns reads which configured optional columns the events
+// table has from system.columns, in configured order.
+func (r *ClickHouseRepository) lookupOptionalColumns(ctx context.Context) ([]string, error) {
+	startTime := time.Now()
+	rows, err := r.conn.Query(ctx, `
+		SELECT name FROM system.columns
+		WHERE database = ? AND table = ? AND name IN (?)
+	`, r.config.Database, r.config.Table, r.config.OptionalColumns)
+	clickhouseQueryDuration.WithLabelValues("optional_columns").Observe(time.Since(startTime).Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	found := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		found[name] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	present := make([]string, 0, len(found))
+	for _, column := range r.config.OptionalColumns {
+		if found[column] {
+			present = append(present, column)
+		}
+	}
+	return present, nil
+}
+
+// UpsertBatch inserts events, keeping only the latest version of each upserted
+// event. Within the batch the last occurrence of an upserted event ID wins;
+// once inserted, older stored rows with that ID are removed with a lightweight
+// DELETE, which the events table must support (a local MergeTree, not a
+// Distributed table). Rows already summed into events_per_minute stay counted.
+//
+// Once the insert has succeeded the batch is stored, so a failing DELETE does
+// not fail it: the DELETE alone is retried, and if it still fails the older
+// versions are left in place, logged and counted, until the event is upserted
+// again.
+func (r *ClickHouseRepository) UpsertBatch(ctx context.Context, events []*domain.Event) error {
+	latest, upserted := latestVersions(events)
+	// Older versions of the other events are still removed when some events
+	// are rejected; a rejected upsert keeps its stored version
+	insertErr := r.InsertBatch(ctx, latest)
+	partial := domain.AsPartialInsertError(insertErr)
+	if insertErr != nil && partial == nil {
+		return insertErr
+	}
+	if partial != nil {
+		upserted = slices.DeleteFunc(upserted, func(id string) bool {
+			return slices.ContainsFunc(partial.Rejected, func(ue *domain.UnstorableEventError) bool {
+				return ue.Event.EventID.String() == id
+			})
+		})
+	}
+	if len(upserted) == 0 {
+		return insertErr
+	}
+
+	backoff := upsertDeleteBackoff
+	for attempt := 1; ; attempt++ {
+		err := r.deleteReplacedVersions(ctx, upserted)
+		if err == nil {
+			return insertErr
+		}
+		if attempt == upsertDeleteAttempts || ctx.Err() != nil {
+			clickhouseStaleVersions.Add(float64(len(upserted)))
+			r.logger.Error("leaving replaced event versions in place",
+				slog.Int("event_count", len(upserted)),
+				slog.Int("attempts", attempt),
+				slog.String("error", err.Error()),
+			)
+			return insertErr
+		}
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+		case <-timer.C:
+		}
+		backoff *= 2
+	}
+}
 
+// Retries of the DELETE that removes replaced versions after an upsert.
+const (
+	upsertDeleteAttempts = 3
+	upsertDeleteBackoff  = 200 * time.Millisecond
+)
+
+// deleteReplacedVersions removes every stored row of the upserted event IDs
+// except the most recently ingested one.
+func (r *ClickHouseRepository) deleteReplacedVersions(ctx context.Context, upserted []string) error {
+	ctx, cancel := r.insertContext(ctx)
+	defer cancel()
+
+	startTime := time.Now()
+	err := r.conn.Exec(ctx, fmt.Sprintf(`
+		DELETE FROM %s
+		WHERE event_id IN (?)
+		AND (event_id, ingested_at) NOT IN (
+			SELECT event_id, max(ingested_at)
+			FROM %s
+			WHERE event_id IN (?)
+			GROUP BY event_id
+		)
+	`, r.table, r.table), upserted, upserted)
+	duration := time.Since(startTime)
+	clickhouseQueryDuration.WithLabelValues("upsert_delete").Observe(duration.Seconds())
+
+	if err != nil {
+		r.logger.Error("failed to remove replaced event versions",
+			slog.Int("event_count", len(upserted)),
+			slog.Duration("duration", duration),
+			slog.String("error", err.Error()),
+		)
+		clickhouseQueryErrors.WithLabelValues("upsert_delete").Inc()
+		return queryError("failed to remove replaced event versions", err)
+	}
 	return nil
 }
 
+// latestVersions drops every event superseded by a later upsert of the same
+// event ID in events, returning the remaining events in order and the IDs of
+// the upserted events.
+func latestVersions(events []*domain.Event) ([]*domain.Event, []string) {
+	lastUpsert := make(map[uuid.UUID]int)
+	for i, event := range events {
+		if event != nil && event.IsUpsert() {
+			lastUpsert[event.EventID] = i
+		}
+	}
+	if len(lastUpsert) == 0 {
+		return events, nil
+	}
+
+	latest := make([]*domain.Event, 0, len(events))
+	upserted := make([]string, 0, len(lastUpsert))
+	for i, event := range events {
+		if event == nil {
+			continue
+		}
+		if last, ok := lastUpsert[event.EventID]; ok {
+			if i < last {
+				continue
+			}
+			if i == last {
+				upserted = append(upserted, event.EventID.String())
+			}
+		}
+		latest = append(latest, event)
+	}
+	return latest, upserted
+}
+
+// insertSettings returns the ClickHouse settings configured for inserting events,
+// or nil when none are configured.
+func (r *ClickHouseRepository) insertSettings(events []*domain.Event) clickhouse.Settings {
+	cfg := r.config.Insert
+	settings := clickhouse.Settings{}
+	if cfg.Quorum > 0 {
+		settings["insert_quorum"] = cfg.Quorum
+	}
+	if cfg.Deduplicate || cfg.DeduplicationToken {
+		settings["insert_deduplicate"] = 1
+	}
+	if cfg.DeduplicationToken {
+		settings["insert_deduplication_token"] = deduplicationToken(events)
+	}
+	if limit := r.config.InsertMaxExecutionTime; limit > 0 {
+		settings["max_execution_time"] = executionSeconds(limit)
+	}
+	if len(settings) == 0 {
+		return nil
+	}
+	return settings
+}
+
+// readContext bounds a read query by ReadMaxExecutionTime.
+func (r *ClickHouseRepository) readContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return executionContext(ctx, r.config.ReadMaxExecutionTime)
+}
+
+// insertContext bounds a write other than InsertBatch by InsertMaxExecutionTime.
+func (r *ClickHouseRepository) insertContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return executionContext(ctx, r.config.InsertMaxExecutionTime)
+}
+
+// executionContext attaches max_execution_time for limit and caps ctx's deadline
+// at limit. The driver raises max_execution_time to the deadline plus a few
+// seconds whenever a deadline is set, so the capped deadline, which cancels
+// the query on the server when it expires, is what enforces the limit.
+func executionContext(ctx context.Context, limit time.Duration) (context.Context, context.CancelFunc) {
+	if limit <= 0 {
+		return ctx, func() {}
+	}
+	ctx, cancel := context.WithTimeout(ctx, limit)
+	return withSettings(ctx, clickhouse.Settings{
+		"max_execution_time": executionSeconds(limit),
+	}), cancel
+}
+
+// settingsKey is the context key of the settings attached by withSettings.
+type settingsKey struct{}
+
+// withSettings attaches settings to the queries run with ctx, replacing any
+// attached earlier. The driver keeps its copy unexported, so the settings are
+// also stored under settingsKey where a test's connection can read them.
+func withSettings(ctx context.Context, settings clickhouse.Settings) context.Context {
+	ctx = context.WithValue(ctx, settingsKey{}, settings)
+	return clickhouse.Context(ctx, clickhouse.WithSettings(settings))
+}
+
+// executionSeconds converts limit to whole seconds for max_execution_time,
+// rounding up so a sub-second limit is not read as unlimited.
+func executionSeconds(limit time.Duration) int {
+	return int((limit + time.Second - 1) / time.Second)
+}
+
+// deduplicationToken hashes the batch's event IDs in order, so the same batch
+// always yields the same token.
+func deduplicationToken(events []*domain.Event) string {
+	h := sha256.New()
+	for _, event := range events {
+		if event == nil {
+			continue
+		}
+		h.Write(event.EventID[:])
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
 // GetMatchMetrics retrieves aggregated metrics for a specific match.
-// Queries the fanfinity.match_metrics materialized view and aggregates events by type.
+// A closed match returns its final snapshot, so events stored after it was
+// closed do not change its official metrics. Otherwise the metrics are
+// computed from the events table; see liveMatchMetrics.
 func (r *ClickHouseRepository) GetMatchMetrics(ctx context.Context, matchID string) (*domain.MatchMetrics, error) {
+	return r.GetMatchMetricsWithOptions(ctx, matchID, domain.MetricsOptions{})
+}
+
+// GetMatchMetricsWithOptions is GetMatchMetrics with optional parts of the live
+// metrics skipped per opts. A closed match's snapshot is returned whole.
+func (r *ClickHouseRepository) GetMatchMetricsWithOptions(ctx context.Context, matchID string, opts domain.MetricsOptions) (*domain.MatchMetrics, error) {
 	if matchID == "" {
 		return nil, fmt.Errorf("matchID cannot be empty")
 	}
 
+	if r.conn == nil {
+		return nil, ErrNotConnected
+	}
+
+	ctx, cancel := r.readContext(ctx)
+	defer cancel()
+
+	// Before the final table is created live metrics are still served; any
+	// other lookup failure is returned rather than masked by live metrics that
+	// may differ from the frozen snapshot
+	final, err := r.GetFinalMetrics(ctx, matchID)
+	if err != nil {
+		if !isUnknownTableError(err) {
+			return nil, err
+		}
+		r.logger.Warn("final metrics table missing, serving live metrics",
+			slog.String("match_id", matchID),
+			slog.String("error", err.Error()),
+		)
+	}
+	if final != nil {
+		return final, nil
+	}
+
+	return r.liveMatchMetrics(ctx, matchID, opts)
+}
+
+// liveMatchMetrics aggregates a match's metrics from the events table.
+// Correction events and the events they retract are excluded from every count.
+// Details registered in the match info table are merged in when present.
+func (r *ClickHouseRepository) liveMatchMetrics(ctx context.Context, matchID string, opts domain.MetricsOptions) (*domain.MatchMetrics, error) {
 	startTime := time.Now()
 
 	// Query for basic metrics from aggregated view
 	metrics := domain.NewMatchMetrics(matchID)
 
-	// Query total events, goals, yellow cards, red cards, and time range
-	row := r.conn.QueryRow(ctx, `
+	// Query total events, goals, yellow cards, red cards, distinct players, and time range
+	row := r.conn.QueryRow(ctx, fmt.Sprintf(`
 		SELECT
 			count(*) as total_events,
 			countIf(event_type = 'goal') as goals,
 			countIf(event_type = 'yellow_card') as yellow_cards,
 			countIf(event_type = 'red_card') as red_cards,
+			uniqExactIf(player_id, isNotNull(player_id) AND player_id != '') as distinct_players,
 			min(timestamp) as first_event_at,
 			max(timestamp) as last_event_at
-		FROM fanfinity.match_events
-		WHERE match_id = ?
-	`, matchID)
+		FROM %s
+		WHERE match_id = ? %s
+	`, r.readTable(), r.validEventsFilter()), matchID, matchID)
 
-	var totalEvents, goals, yellowCards, redCards uint64
+	var totalEvents, goals, yellowCards, redCards, distinctPlayers uint64
 	var firstEventAt, lastEventAt time.Time
 
-	err := row.Scan(&totalEvents, &goals, &yellowCards, &redCards, &firstEventAt, &lastEventAt)
+	err := row.Scan(&totalEvents, &goals, &yellowCards, &redCards, &distinctPlayers, &firstEventAt, &lastEventAt)
 	if err != nil {
 		duration := time.Since(startTime)
 		r.logger.Error("failed to query match metrics",
@@ -230,20 +957,21 @@ func (r *ClickHouseRepository) GetMatchMetrics(ctx context.Context, matchID stri
 		)
 		clickhouseQueryErrors.WithLabelValues("get_match_metrics").Inc()
 		clickhouseQueryDuration.WithLabelValues("get_match_metrics").Observe(duration.Seconds())
-		return nil, fmt.Errorf("failed to query match metrics: %w", err)
+		return nil, queryError("failed to query match metrics", err)
 	}
 
-	// If no events found, return nil
+	// A match without events is unknown to us
 	if totalEvents == 0 {
 		duration := time.Since(startTime)
 		clickhouseQueryDuration.WithLabelValues("get_match_metrics").Observe(duration.Seconds())
-		return nil, nil
+		return nil, fmt.Errorf("match %s: %w", matchID, domain.ErrMatchNotFound)
 	}
 
 	metrics.TotalEvents = int64(totalEvents)
 	metrics.Goals = int64(goals)
 	metrics.YellowCards = int64(yellowCards)
 	metrics.RedCards = int64(redCards)
+	metrics.DistinctPlayers = int64(distinctPlayers)
 
 	// Set time pointers only if we have events
 	if !firstEventAt.IsZero() {
@@ -252,15 +980,16 @@ func (r *ClickHouseRepository) GetMatchMetrics(ctx context.Context, matchID stri
 	if !lastEventAt.IsZero() {
 		metrics.LastEventAt = &lastEventAt
 	}
+	metrics.AvgEventsPerMinute = domain.AvgEventsPerMinute(metrics.TotalEvents, firstEventAt, lastEventAt)
 
 	// Query events by type
-	rows, err := r.conn.Query(ctx, `
+	rows, err := r.conn.Query(ctx, fmt.Sprintf(`
 		SELECT event_type, count(*) as event_count
-		FROM fanfinity.match_events
-		WHERE match_id = ?
+		FROM %s
+		WHERE match_id = ? %s
 		GROUP BY event_type
 		ORDER BY event_count DESC
-	`, matchID)
+	`, r.readTable(), r.validEventsFilter()), matchID, matchID)
 	if err != nil {
 		duration := time.Since(startTime)
 		r.logger.Error("failed to query events by type",
@@ -270,7 +999,7 @@ func (r *ClickHouseRepository) GetMatchMetrics(ctx context.Context, matchID stri
 		)
 		clickhouseQueryErrors.WithLabelValues("get_match_metrics_by_type").Inc()
 		clickhouseQueryDuration.WithLabelValues("get_match_metrics").Observe(duration.Seconds())
-		return nil, fmt.Errorf("failed to query events by type: %w", err)
+		return nil, queryError("failed to query events by type", err)
 	}
 	defer rows.Close()
 
@@ -294,62 +1023,829 @@ func (r *ClickHouseRepository) GetMatchMetrics(ctx context.Context, matchID stri
 		)
 		clickhouseQueryErrors.WithLabelValues("get_match_metrics_by_type").Inc()
 		clickhouseQueryDuration.WithLabelValues("get_match_metrics").Observe(duration.Seconds())
-		return nil, fmt.Errorf("error iterating events by type: %w", err)
+		return nil, queryError("error iterating events by type", err)
 	}
 
-	// Query for peak engagement minute
-	peakRow := r.conn.QueryRow(ctx, `
-		SELECT
-			toStartOfMinute(timestamp) as minute,
-			count(*) as event_count
-		FROM fanfinity.match_events
-		WHERE match_id = ?
-		GROUP BY minute
-		ORDER BY event_count DESC
-		LIMIT 1
-	`, matchID)
-
-	var peakMinute time.Time
-	var peakCount uint64
-	err = peakRow.Scan(&peakMinute, &peakCount)
-	if err == nil && peakCount > 0 {
-		metrics.PeakMinute = &domain.PeakEngagement{
-			Minute:     peakMinute,
-			EventCount: int64(peakCount),
-		}
+	if !opts.SkipPeak {
+		metrics.PeakMinute = r.peakEngagement(ctx, matchID)
 	}
 
-	duration := time.Since(startTime)
+	// Like the peak minute, the phase split is optional and omitted if the query fails
+	byPhase, err := r.phaseMetrics(ctx, matchID, firstEventAt, lastEventAt)
+	if err != nil {
+		r.logger.Warn("omitting phase metrics from metrics",
+			slog.String("match_id", matchID),
+			slog.String("error", err.Error()),
+		)
+	}
+	metrics.ByPhase = byPhase
+
+	// The scoreless streak needs at least two goals; like the peak minute it is
+	// optional and omitted if the query fails
+	if goals >= 2 {
+		streak, err := r.longestScorelessStreak(ctx, matchID)
+		if err != nil {
+			r.logger.Warn("omitting scoreless streak from metrics",
+				slog.String("match_id", matchID),
+				slog.String("error", err.Error()),
+			)
+		}
+		metrics.LongestScorelessStreakSeconds = streak
+	}
+
+	// Registered match details are optional; metrics are still returned without them
+	info, err := r.GetMatchInfo(ctx, matchID)
+	if err != nil {
+		r.logger.Warn("omitting match info from metrics",
+			slog.String("match_id", matchID),
+			slog.String("error", err.Error()),
+		)
+	}
+	metrics.ApplyMatchInfo(info)
+
+	duration := time.Since(startTime)
 	clickhouseQueryDuration.WithLabelValues("get_match_metrics").Observe(duration.Seconds())
 
-	r.logger.Debug("successfully retrieved match metrics",
-		slog.String("match_id", matchID),
-		slog.Int64("total_events", int64(totalEvents)),
-		slog.Duration("duration", duration),
-	)
+	r.logger.Debug("successfully retrieved match metrics",
+		slog.String("match_id", matchID),
+		slog.Int64("total_events", int64(totalEvents)),
+		slog.Duration("duration", duration),
+	)
+
+	return metrics, nil
+}
+
+// peakEngagement returns the minute with the highest weighted engagement score,
+// or nil if the query fails or the match has no events.
+func (r *ClickHouseRepository) peakEngagement(ctx context.Context, matchID string) *domain.PeakEngagement {
+	row := r.conn.QueryRow(ctx, fmt.Sprintf(`
+		SELECT
+			toStartOfMinute(timestamp) as minute,
+			count(*) as event_count,
+			sum(%s) as score
+		FROM %s
+		WHERE match_id = ? %s
+		GROUP BY minute
+		ORDER BY score DESC, minute ASC
+		LIMIT 1
+	`, engagementScoreExpr(r.config.EngagementWeights), r.readTable(), r.validEventsFilter()), matchID, matchID)
+
+	var minute time.Time
+	var count uint64
+	var score float64
+	if err := row.Scan(&minute, &count, &score); err != nil || count == 0 {
+		return nil
+	}
+	return &domain.PeakEngagement{
+		Minute:     minute,
+		EventCount: int64(count),
+		Score:      score,
+	}
+}
+
+// phaseMetrics totals a match's events per phase, with kickoff taken as its
+// first event. Every phase the match has reached by its last event is present,
+// with zero totals if it has no events.
+func (r *ClickHouseRepository) phaseMetrics(ctx context.Context, matchID string, kickoff, last time.Time) (map[string]domain.PhaseMetrics, error) {
+	rows, err := r.conn.Query(ctx, fmt.Sprintf(`
+		SELECT
+			%s as phase,
+			count(*) as total_events,
+			countIf(event_type = 'goal') as goals,
+			countIf(event_type = 'yellow_card') as yellow_cards,
+			countIf(event_type = 'red_card') as red_cards
+		FROM (
+			SELECT event_type, toUnixTimestamp64Milli(timestamp) - ? as offset_ms
+			FROM %s
+			WHERE match_id = ? %s
+		)
+		GROUP BY phase
+	`, phaseExpr(r.config.Phases), r.readTable(), r.validEventsFilter()), kickoff.UnixMilli(), matchID, matchID)
+	if err != nil {
+		clickhouseQueryErrors.WithLabelValues("get_phase_metrics").Inc()
+		return nil, queryError("failed to query phase metrics", err)
+	}
+	defer rows.Close()
+
+	byPhase := make(map[string]domain.PhaseMetrics)
+	for _, phase := range r.config.Phases.Reached(last.Sub(kickoff)) {
+		byPhase[phase.Name] = domain.PhaseMetrics{}
+	}
+	for rows.Next() {
+		var phase string
+		var total, goals, yellowCards, redCards uint64
+		if err := rows.Scan(&phase, &total, &goals, &yellowCards, &redCards); err != nil {
+			clickhouseQueryErrors.WithLabelValues("get_phase_metrics").Inc()
+			return nil, scanError("failed to scan phase metrics", err)
+		}
+		byPhase[phase] = domain.PhaseMetrics{
+			TotalEvents: int64(total),
+			Goals:       int64(goals),
+			YellowCards: int64(yellowCards),
+			RedCards:    int64(redCards),
+		}
+	}
+	if err := rows.Err(); err != nil {
+		clickhouseQueryErrors.WithLabelValues("get_phase_metrics").Inc()
+		return nil, queryError("error iterating phase metrics", err)
+	}
+	return byPhase, nil
+}
+
+// phaseExpr builds a multiIf expression naming the phase of offset_ms, checking
+// the latest phase first; offsets before every later phase fall in the first.
+// Phase names are validated when the repository is created, so they are safe to inline.
+func phaseExpr(phases domain.MatchPhases) string {
+	if len(phases) == 1 {
+		return fmt.Sprintf("'%s'", phases[0].Name)
+	}
+	var b strings.Builder
+	b.WriteString("multiIf(")
+	for i := len(phases) - 1; i > 0; i-- {
+		fmt.Fprintf(&b, "offset_ms >= %d, '%s', ", phases[i].Start.Milliseconds(), phases[i].Name)
+	}
+	fmt.Fprintf(&b, "'%s')", phases[0].Name)
+	return b.String()
+}
+
+// CloseMatch computes the match's metrics and stores them as its final,
+// immutable snapshot, which GetMatchMetrics returns from then on. Events for
+// the match are still stored after it is closed but are ignored by its
+// official metrics. Closing a closed match returns the existing snapshot with
+// ErrMatchClosed. When concurrent closes both store a snapshot, the one
+// GetFinalMetrics serves wins and the other close gets it with ErrMatchClosed.
+func (r *ClickHouseRepository) CloseMatch(ctx context.Context, matchID string) (*domain.MatchMetrics, error) {
+	if matchID == "" {
+		return nil, fmt.Errorf("matchID cannot be empty")
+	}
+
+	if r.conn == nil {
+		return nil, ErrNotConnected
+	}
+
+	existing, err := r.GetFinalMetrics(ctx, matchID)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return existing, fmt.Errorf("match %s: %w", matchID, domain.ErrMatchClosed)
+	}
+
+	metrics, err := r.liveMatchMetrics(ctx, matchID, domain.MetricsOptions{})
+	if err != nil {
+		return nil, err
+	}
+	// closed_at keeps milliseconds; truncate so the snapshot matches its row
+	closedAt := r.config.Clock.Now().UTC().Truncate(time.Millisecond)
+	metrics.ClosedAt = &closedAt
+	metrics.ResponseTimePercentiles = nil
+
+	snapshot, err := json.Marshal(metrics)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode final metrics: %w", err)
+	}
+
+	insertCtx, cancel := r.insertContext(ctx)
+	defer cancel()
+
+	startTime := time.Now()
+	err = r.conn.Exec(insertCtx, fmt.Sprintf(`
+		INSERT INTO %s (match_id, metrics, closed_at)
+		VALUES (?, ?, ?)
+	`, r.matchFinalTable), matchID, string(snapshot), closedAt)
+	duration := time.Since(startTime)
+	clickhouseQueryDuration.WithLabelValues("close_match").Observe(duration.Seconds())
+
+	if err != nil {
+		r.logger.Error("failed to store final metrics",
+			slog.String("match_id", matchID),
+			slog.Duration("duration", duration),
+			slog.String("error", err.Error()),
+		)
+		clickhouseQueryErrors.WithLabelValues("close_match").Inc()
+		return nil, queryError("failed to store final metrics", err)
+	}
+
+	// Another close may have stored its snapshot after our check; whichever
+	// GetFinalMetrics serves is the official one. A read that does not see any
+	// snapshot yet leaves ours in place.
+	winner, err := r.finalSnapshot(ctx, matchID)
+	if err != nil {
+		return nil, err
+	}
+	if winner != "" && winner != string(snapshot) {
+		official, err := decodeFinalMetrics(winner)
+		if err != nil {
+			return nil, err
+		}
+		return official, fmt.Errorf("match %s: %w", matchID, domain.ErrMatchClosed)
+	}
+
+	r.logger.Info("match closed",
+		slog.String("match_id", matchID),
+		slog.Int64("total_events", metrics.TotalEvents),
+	)
+	return metrics, nil
+}
+
+// GetFinalMetrics returns the final snapshot of a closed match, or nil if the
+// match is not closed. Should two closes race, the earliest snapshot wins.
+func (r *ClickHouseRepository) GetFinalMetrics(ctx context.Context, matchID string) (*domain.MatchMetrics, error) {
+	if matchID == "" {
+		return nil, fmt.Errorf("matchID cannot be empty")
+	}
+
+	if r.conn == nil {
+		return nil, ErrNotConnected
+	}
+
+	snapshot, err := r.finalSnapshot(ctx, matchID)
+	if err != nil || snapshot == "" {
+		return nil, err
+	}
+	return decodeFinalMetrics(snapshot)
+}
+
+// finalSnapshot returns the JSON of a closed match's official snapshot, or ""
+// if the match is not closed. Snapshots closed in the same millisecond are
+// ordered by their content, so every reader picks the same one.
+func (r *ClickHouseRepository) finalSnapshot(ctx context.Context, matchID string) (string, error) {
+	ctx, cancel := r.readContext(ctx)
+	defer cancel()
+
+	startTime := time.Now()
+
+	row := r.conn.QueryRow(ctx, fmt.Sprintf(`
+		SELECT metrics
+		FROM %s
+		WHERE match_id = ?
+		ORDER BY closed_at ASC, metrics ASC
+		LIMIT 1
+	`, r.matchFinalTable), matchID)
+
+	var snapshot string
+	err := row.Scan(&snapshot)
+	duration := time.Since(startTime)
+	clickhouseQueryDuration.WithLabelValues("get_final_metrics").Observe(duration.Seconds())
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	if err != nil {
+		clickhouseQueryErrors.WithLabelValues("get_final_metrics").Inc()
+		return "", queryError("failed to query final metrics", err)
+	}
+	return snapshot, nil
+}
+
+// decodeFinalMetrics decodes a stored final snapshot.
+func decodeFinalMetrics(snapshot string) (*domain.MatchMetrics, error) {
+	var metrics domain.MatchMetrics
+	if err := json.Unmarshal([]byte(snapshot), &metrics); err != nil {
+		return nil, scanError("failed to decode final metrics", err)
+	}
+	if metrics.EventsByType == nil {
+		metrics.EventsByType = make(map[string]int64)
+	}
+	return &metrics, nil
+}
+
+// longestScorelessStreak returns the longest gap in seconds between consecutive
+// goals in a match. Matches have few goals, so their timestamps are fetched and
+// compared in Go.
+func (r *ClickHouseRepository) longestScorelessStreak(ctx context.Context, matchID string) (int64, error) {
+	rows, err := r.conn.Query(ctx, fmt.Sprintf(`
+		SELECT timestamp
+		FROM %s
+		WHERE match_id = ? AND event_type = 'goal' %s
+		ORDER BY timestamp
+	`, r.readTable(), r.validEventsFilter()), matchID, matchID)
+	if err != nil {
+		clickhouseQueryErrors.WithLabelValues("get_scoreless_streak").Inc()
+		return 0, queryError("failed to query goal timestamps", err)
+	}
+	defer rows.Close()
+
+	var goals []time.Time
+	for rows.Next() {
+		var at time.Time
+		if err := rows.Scan(&at); err != nil {
+			clickhouseQueryErrors.WithLabelValues("get_scoreless_streak").Inc()
+			return 0, scanError("failed to scan goal timestamp", err)
+		}
+		goals = append(goals, at)
+	}
+	if err := rows.Err(); err != nil {
+		clickhouseQueryErrors.WithLabelValues("get_scoreless_streak").Inc()
+		return 0, queryError("error iterating goal timestamps", err)
+	}
+
+	return domain.LongestScorelessStreakSeconds(goals), nil
+}
+
+// engagementScoreExpr builds a multiIf expression mapping each event type to its weight.
+// Event types are iterated in sorted order so the generated SQL is deterministic.
+// Only valid event types are emitted, so the expression is safe to inline.
+func engagementScoreExpr(weights domain.EngagementWeights) string {
+	types := make([]string, 0, len(weights))
+	for eventType := range weights {
+		if domain.ValidEventTypes[eventType] {
+			types = append(types, string(eventType))
+		}
+	}
+	sort.Strings(types)
+
+	var b strings.Builder
+	b.WriteString("multiIf(")
+	for _, eventType := range types {
+		weight := strconv.FormatFloat(weights[domain.EventType(eventType)], 'f', -1, 64)
+		fmt.Fprintf(&b, "event_type = '%s', toFloat64(%s), ", eventType, weight)
+	}
+	b.WriteString("toFloat64(1))")
+	return b.String()
+}
+
+// correctedEventsFilter is appended to a match query's WHERE clause to drop
+// correction rows and every event a correction references. The subquery takes
+// the match ID as its only argument.
+const correctedEventsFilter = `
+		AND event_type != 'correction'
+		AND event_id NOT IN (
+			SELECT toUUIDOrZero(JSONExtractString(metadata, 'correctsEventId'))
+			FROM %s
+			WHERE match_id = ? AND event_type = 'correction'
+		)`
+
+// readTable returns the events table for the metrics reads, with FINAL when
+// ReadFinal is set. The corrections subquery reads the plain table: duplicate
+// correction rows do not change which events it excludes.
+func (r *ClickHouseRepository) readTable() string {
+	if r.config.ReadFinal {
+		return r.table + " FINAL"
+	}
+	return r.table
+}
+
+// validEventsFilter returns correctedEventsFilter for the configured table.
+func (r *ClickHouseRepository) validEventsFilter() string {
+	return fmt.Sprintf(correctedEventsFilter, r.table)
+}
+
+// MatchExists reports whether the match has any events its metrics count, so
+// a match whose events were all retracted by corrections does not exist.
+// It runs a cheap LIMIT 1 probe instead of computing full metrics.
+func (r *ClickHouseRepository) MatchExists(ctx context.Context, matchID string) (bool, error) {
+	if matchID == "" {
+		return false, fmt.Errorf("matchID cannot be empty")
+	}
+
+	if r.conn == nil {
+		return false, ErrNotConnected
+	}
+
+	ctx, cancel := r.readContext(ctx)
+	defer cancel()
+
+	startTime := time.Now()
+
+	row := r.conn.QueryRow(ctx, fmt.Sprintf(`
+		SELECT 1
+		FROM %s
+		WHERE match_id = ? %s
+		LIMIT 1
+	`, r.table, r.validEventsFilter()), matchID, matchID)
+
+	var found uint8
+	err := row.Scan(&found)
+	duration := time.Since(startTime)
+	clickhouseQueryDuration.WithLabelValues("match_exists").Observe(duration.Seconds())
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		r.logger.Error("failed to check match existence",
+			slog.String("match_id", matchID),
+			slog.Duration("duration", duration),
+			slog.String("error", err.Error()),
+		)
+		clickhouseQueryErrors.WithLabelValues("match_exists").Inc()
+		return false, queryError("failed to check match existence", err)
+	}
+
+	return found == 1, nil
+}
+
+// CountEvents returns the number of rows stored for a match, including
+// corrections and the events they correct, for comparison with the number of
+// events produced.
+func (r *ClickHouseRepository) CountEvents(ctx context.Context, matchID string) (int64, error) {
+	if matchID == "" {
+		return 0, fmt.Errorf("matchID cannot be empty")
+	}
+
+	if r.conn == nil {
+		return 0, ErrNotConnected
+	}
+
+	ctx, cancel := r.readContext(ctx)
+	defer cancel()
+
+	startTime := time.Now()
+
+	row := r.conn.QueryRow(ctx, fmt.Sprintf(`
+		SELECT count()
+		FROM %s
+		WHERE match_id = ?
+	`, r.table), matchID)
+
+	var count uint64
+	err := row.Scan(&count)
+	duration := time.Since(startTime)
+	clickhouseQueryDuration.WithLabelValues("count_events").Observe(duration.Seconds())
+
+	if err != nil {
+		r.logger.Error("failed to count events",
+			slog.String("match_id", matchID),
+			slog.Duration("duration", duration),
+			slog.String("error", err.Error()),
+		)
+		clickhouseQueryErrors.WithLabelValues("count_events").Inc()
+		return 0, queryError("failed to count events", err)
+	}
+
+	return int64(count), nil
+}
+
+// UpsertMatchInfo stores the details registered for a match. The match_info table
+// is a ReplacingMergeTree keyed by match_id, so the latest registration wins.
+func (r *ClickHouseRepository) UpsertMatchInfo(ctx context.Context, info *domain.MatchInfo) error {
+	if info == nil || info.MatchID == "" {
+		return fmt.Errorf("matchID cannot be empty")
+	}
+
+	if r.conn == nil {
+		return ErrNotConnected
+	}
+
+	ctx, cancel := r.insertContext(ctx)
+	defer cancel()
+
+	teamNames := make(map[string]string, len(info.TeamNames))
+	for teamID, name := range info.TeamNames {
+		teamNames[strconv.Itoa(teamID)] = name
+	}
+
+	startTime := time.Now()
+	err := r.conn.Exec(ctx, fmt.Sprintf(`
+		INSERT INTO %s (match_id, competition, team_names, updated_at)
+		VALUES (?, ?, ?, ?)
+	`, r.matchInfoTable), info.MatchID, info.Competition, teamNames, time.Now().UTC())
+	duration := time.Since(startTime)
+	clickhouseQueryDuration.WithLabelValues("upsert_match_info").Observe(duration.Seconds())
+
+	if err != nil {
+		r.logger.Error("failed to upsert match info",
+			slog.String("match_id", info.MatchID),
+			slog.Duration("duration", duration),
+			slog.String("error", err.Error()),
+		)
+		clickhouseQueryErrors.WithLabelValues("upsert_match_info").Inc()
+		return queryError("failed to upsert match info", err)
+	}
+
+	return nil
+}
+
+// GetMatchInfo returns the latest details registered for a match, or nil if none are.
+func (r *ClickHouseRepository) GetMatchInfo(ctx context.Context, matchID string) (*domain.MatchInfo, error) {
+	if matchID == "" {
+		return nil, fmt.Errorf("matchID cannot be empty")
+	}
+
+	if r.conn == nil {
+		return nil, ErrNotConnected
+	}
+
+	ctx, cancel := r.readContext(ctx)
+	defer cancel()
+
+	startTime := time.Now()
+
+	row := r.conn.QueryRow(ctx, fmt.Sprintf(`
+		SELECT competition, team_names
+		FROM %s
+		WHERE match_id = ?
+		ORDER BY updated_at DESC
+		LIMIT 1
+	`, r.matchInfoTable), matchID)
+
+	var competition string
+	var teamNames map[string]string
+	err := row.Scan(&competition, &teamNames)
+	duration := time.Since(startTime)
+	clickhouseQueryDuration.WithLabelValues("get_match_info").Observe(duration.Seconds())
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		clickhouseQueryErrors.WithLabelValues("get_match_info").Inc()
+		return nil, queryError("failed to query match info", err)
+	}
+
+	info := &domain.MatchInfo{
+		MatchID:     matchID,
+		Competition: competition,
+	}
+	for key, name := range teamNames {
+		teamID, err := strconv.Atoi(key)
+		if err != nil {
+			continue
+		}
+		if info.TeamNames == nil {
+			info.TeamNames = make(map[int]string, len(teamNames))
+		}
+		info.TeamNames[teamID] = name
+	}
+
+	return info, nil
+}
+
+// PublishMatchLag records the current consumer lag of each match. The
+// match_lag table is a ReplacingMergeTree keyed by match_id, so the latest
+// report per match wins.
+func (r *ClickHouseRepository) PublishMatchLag(ctx context.Context, lags map[string]time.Duration) error {
+	if len(lags) == 0 {
+		return nil
+	}
+
+	if r.conn == nil {
+		return ErrNotConnected
+	}
+
+	ctx, cancel := r.insertContext(ctx)
+	defer cancel()
+
+	startTime := time.Now()
+	batch, err := r.conn.PrepareBatch(ctx, fmt.Sprintf(`
+		INSERT INTO %s (match_id, lag_ms, updated_at) VALUES (?, ?, ?)
+	`, r.matchLagTable))
+	if err != nil {
+		clickhouseQueryErrors.WithLabelValues("publish_match_lag").Inc()
+		return queryError("failed to prepare match lag insert", err)
+	}
+
+	now := time.Now().UTC()
+	for matchID, lag := range lags {
+		if lag < 0 {
+			lag = 0
+		}
+		if err := batch.Append(matchID, uint64(lag.Milliseconds()), now); err != nil {
+			clickhouseQueryErrors.WithLabelValues("publish_match_lag").Inc()
+			return queryError("failed to append match lag", err)
+		}
+	}
+
+	err = batch.Send()
+	clickhouseQueryDuration.WithLabelValues("publish_match_lag").Observe(time.Since(startTime).Seconds())
+	if err != nil {
+		clickhouseQueryErrors.WithLabelValues("publish_match_lag").Inc()
+		return queryError("failed to send match lag insert", err)
+	}
+	return nil
+}
+
+// LaggingMatches returns each match whose latest published lag is at least
+// minLag. Lag published more than MatchLagMaxAge ago is ignored.
+func (r *ClickHouseRepository) LaggingMatches(ctx context.Context, minLag time.Duration) (map[string]time.Duration, error) {
+	if r.conn == nil {
+		return nil, ErrNotConnected
+	}
+
+	ctx, cancel := r.readContext(ctx)
+	defer cancel()
+
+	startTime := time.Now()
+	rows, err := r.conn.Query(ctx, fmt.Sprintf(`
+		SELECT match_id, argMax(lag_ms, updated_at) AS lag
+		FROM %s
+		WHERE updated_at >= ?
+		GROUP BY match_id
+		HAVING lag >= ?
+	`, r.matchLagTable), time.Now().UTC().Add(-MatchLagMaxAge), uint64(minLag.Milliseconds()))
+	if err != nil {
+		clickhouseQueryErrors.WithLabelValues("lagging_matches").Inc()
+		return nil, queryError("failed to query match lag", err)
+	}
+	defer rows.Close()
+
+	lagging := make(map[string]time.Duration)
+	for rows.Next() {
+		var matchID string
+		var lagMs uint64
+		if err := rows.Scan(&matchID, &lagMs); err != nil {
+			clickhouseQueryErrors.WithLabelValues("lagging_matches").Inc()
+			return nil, scanError("failed to scan match lag", err)
+		}
+		lagging[matchID] = time.Duration(lagMs) * time.Millisecond
+	}
+	if err := rows.Err(); err != nil {
+		clickhouseQueryErrors.WithLabelValues("lagging_matches").Inc()
+		return nil, queryError("failed to read match lag", err)
+	}
+	clickhouseQueryDuration.WithLabelValues("lagging_matches").Observe(time.Since(startTime).Seconds())
+	return lagging, nil
+}
+
+// PublishEventStatus records the status of events sent with X-Confirm. The
+// event_status table is a ReplacingMergeTree keyed by event_id, so the latest
+// status per event wins.
+func (r *ClickHouseRepository) PublishEventStatus(ctx context.Context, updates []domain.EventStatusUpdate) error {
+	if len(updates) == 0 {
+		return nil
+	}
+
+	if r.conn == nil {
+		return ErrNotConnected
+	}
+
+	ctx, cancel := r.insertContext(ctx)
+	defer cancel()
+
+	startTime := time.Now()
+	batch, err := r.conn.PrepareBatch(ctx, fmt.Sprintf(`
+		INSERT INTO %s (event_id, match_id, status, updated_at) VALUES (?, ?, ?, ?)
+	`, r.eventStatusTable))
+	if err != nil {
+		clickhouseQueryErrors.WithLabelValues("publish_event_status").Inc()
+		return queryError("failed to prepare event status insert", err)
+	}
+
+	now := time.Now().UTC()
+	for _, update := range updates {
+		if err := batch.Append(update.EventID, update.MatchID, string(update.Status), now); err != nil {
+			clickhouseQueryErrors.WithLabelValues("publish_event_status").Inc()
+			return queryError("failed to append event status", err)
+		}
+	}
+
+	err = batch.Send()
+	clickhouseQueryDuration.WithLabelValues("publish_event_status").Observe(time.Since(startTime).Seconds())
+	if err != nil {
+		clickhouseQueryErrors.WithLabelValues("publish_event_status").Inc()
+		return queryError("failed to send event status insert", err)
+	}
+	return nil
+}
+
+// EventStatus returns the latest published status of an event, or an empty
+// status if the consumer has published none.
+func (r *ClickHouseRepository) EventStatus(ctx context.Context, eventID uuid.UUID) (domain.EventStatus, error) {
+	if r.conn == nil {
+		return "", ErrNotConnected
+	}
+
+	ctx, cancel := r.readContext(ctx)
+	defer cancel()
+
+	startTime := time.Now()
+	var status string
+	err := r.conn.QueryRow(ctx, fmt.Sprintf(`
+		SELECT argMax(status, updated_at)
+		FROM %s
+		WHERE event_id = ?
+	`, r.eventStatusTable), eventID).Scan(&status)
+	clickhouseQueryDuration.WithLabelValues("event_status").Observe(time.Since(startTime).Seconds())
+	if err != nil {
+		clickhouseQueryErrors.WithLabelValues("event_status").Inc()
+		return "", queryError("failed to query event status", err)
+	}
+	return domain.EventStatus(status), nil
+}
+
+// GetEventsPerMinute retrieves events aggregated by minute for a specific match.
+// Uses the fanfinity.events_per_minute materialized view if available.
+func (r *ClickHouseRepository) GetEventsPerMinute(ctx context.Context, matchID string) ([]domain.EventsPerMinute, error) {
+	if matchID == "" {
+		return nil, fmt.Errorf("matchID cannot be empty")
+	}
+
+	return r.queryEventsPerMinute(ctx, "get_events_per_minute", matchID, "")
+}
+
+// GetTeamEventsPerMinute retrieves events aggregated by minute for one team in a match.
+func (r *ClickHouseRepository) GetTeamEventsPerMinute(ctx context.Context, matchID string, teamID int) ([]domain.EventsPerMinute, error) {
+	if matchID == "" {
+		return nil, fmt.Errorf("matchID cannot be empty")
+	}
+	if teamID < 1 {
+		return nil, fmt.Errorf("teamID must be positive, got %d", teamID)
+	}
+
+	// team_id is stored as a string in the ClickHouse schema
+	return r.queryEventsPerMinute(ctx, "get_team_events_per_minute", matchID, "AND team_id = ?", strconv.Itoa(teamID))
+}
+
+// GetEventsPerSecond retrieves a match's event counts per second for events
+// timestamped at or after since. Seconds without events are omitted.
+// Corrected events are excluded.
+func (r *ClickHouseRepository) GetEventsPerSecond(ctx context.Context, matchID string, since time.Time) ([]domain.EventsPerSecond, error) {
+	if matchID == "" {
+		return nil, fmt.Errorf("matchID cannot be empty")
+	}
+	if since.IsZero() {
+		return nil, fmt.Errorf("since cannot be zero")
+	}
+
+	if r.conn == nil {
+		return nil, ErrNotConnected
+	}
+
+	ctx, cancel := r.readContext(ctx)
+	defer cancel()
+
+	const operation = "get_events_per_second"
+	startTime := time.Now()
+
+	rows, err := r.conn.Query(ctx, fmt.Sprintf(`
+		SELECT
+			toStartOfSecond(timestamp) as second,
+			count(*) as event_count
+		FROM %s
+		WHERE match_id = ? AND timestamp >= ? %s
+		GROUP BY second
+		ORDER BY second ASC
+	`, r.table, r.validEventsFilter()), matchID, since, matchID)
+	if err != nil {
+		duration := time.Since(startTime)
+		r.logger.Error("failed to query events per second",
+			slog.String("match_id", matchID),
+			slog.Duration("duration", duration),
+			slog.String("error", err.Error()),
+		)
+		clickhouseQueryErrors.WithLabelValues(operation).Inc()
+		clickhouseQueryDuration.WithLabelValues(operation).Observe(duration.Seconds())
+		return nil, queryError("failed to query events per second", err)
+	}
+	defer rows.Close()
+
+	var results []domain.EventsPerSecond
+	for rows.Next() {
+		var second time.Time
+		var eventCount uint64
+		if err := rows.Scan(&second, &eventCount); err != nil {
+			r.logger.Warn("failed to scan events per second row",
+				slog.String("error", err.Error()),
+			)
+			continue
+		}
+		results = append(results, domain.EventsPerSecond{
+			Second:     second,
+			EventCount: int64(eventCount),
+		})
+	}
+
+	if err := rows.Err(); err != nil {
+		duration := time.Since(startTime)
+		r.logger.Error("error iterating events per second rows",
+			slog.String("match_id", matchID),
+			slog.String("error", err.Error()),
+		)
+		clickhouseQueryErrors.WithLabelValues(operation).Inc()
+		clickhouseQueryDuration.WithLabelValues(operation).Observe(duration.Seconds())
+		return nil, queryError("error iterating events per second", err)
+	}
 
-	return metrics, nil
+	clickhouseQueryDuration.WithLabelValues(operation).Observe(time.Since(startTime).Seconds())
+	return results, nil
 }
 
-// GetEventsPerMinute retrieves events aggregated by minute for a specific match.
-// Uses the fanfinity.events_per_minute materialized view if available.
-func (r *ClickHouseRepository) GetEventsPerMinute(ctx context.Context, matchID string) ([]domain.EventsPerMinute, error) {
-	if matchID == "" {
-		return nil, fmt.Errorf("matchID cannot be empty")
+// queryEventsPerMinute runs the per-minute aggregation for a match, with an
+// optional extra WHERE clause and its arguments. operation labels the query metrics.
+// Corrected events are excluded.
+func (r *ClickHouseRepository) queryEventsPerMinute(ctx context.Context, operation, matchID, filter string, filterArgs ...any) ([]domain.EventsPerMinute, error) {
+	if r.conn == nil {
+		return nil, ErrNotConnected
 	}
 
+	ctx, cancel := r.readContext(ctx)
+	defer cancel()
+
 	startTime := time.Now()
+	args := append([]any{matchID}, filterArgs...)
+	args = append(args, matchID)
 
-	rows, err := r.conn.Query(ctx, `
+	rows, err := r.conn.Query(ctx, fmt.Sprintf(`
 		SELECT
 			toStartOfMinute(timestamp) as minute,
 			event_type,
 			count(*) as event_count
-		FROM fanfinity.match_events
-		WHERE match_id = ?
+		FROM %s
+		WHERE match_id = ? %s %s
 		GROUP BY minute, event_type
 		ORDER BY minute ASC, event_type ASC
-	`, matchID)
+	`, r.readTable(), filter, r.validEventsFilter()), args...)
 	if err != nil {
 		duration := time.Since(startTime)
 		r.logger.Error("failed to query events per minute",
@@ -357,9 +1853,9 @@ func (r *ClickHouseRepository) GetEventsPerMinute(ctx context.Context, matchID s
 			slog.Duration("duration", duration),
 			slog.String("error", err.Error()),
 		)
-		clickhouseQueryErrors.WithLabelValues("get_events_per_minute").Inc()
-		clickhouseQueryDuration.WithLabelValues("get_events_per_minute").Observe(duration.Seconds())
-		return nil, fmt.Errorf("failed to query events per minute: %w", err)
+		clickhouseQueryErrors.WithLabelValues(operation).Inc()
+		clickhouseQueryDuration.WithLabelValues(operation).Observe(duration.Seconds())
+		return nil, queryError("failed to query events per minute", err)
 	}
 	defer rows.Close()
 
@@ -387,13 +1883,13 @@ func (r *ClickHouseRepository) GetEventsPerMinute(ctx context.Context, matchID s
 			slog.String("match_id", matchID),
 			slog.String("error", err.Error()),
 		)
-		clickhouseQueryErrors.WithLabelValues("get_events_per_minute").Inc()
-		clickhouseQueryDuration.WithLabelValues("get_events_per_minute").Observe(duration.Seconds())
-		return nil, fmt.Errorf("error iterating events per minute: %w", err)
+		clickhouseQueryErrors.WithLabelValues(operation).Inc()
+		clickhouseQueryDuration.WithLabelValues(operation).Observe(duration.Seconds())
+		return nil, queryError("error iterating events per minute", err)
 	}
 
 	duration := time.Since(startTime)
-	clickhouseQueryDuration.WithLabelValues("get_events_per_minute").Observe(duration.Seconds())
+	clickhouseQueryDuration.WithLabelValues(operation).Observe(duration.Seconds())
 
 	r.logger.Debug("successfully retrieved events per minute",
 		slog.String("match_id", matchID),
@@ -404,6 +1900,374 @@ func (r *ClickHouseRepository) GetEventsPerMinute(ctx context.Context, matchID s
 	return results, nil
 }
 
+// GetEventMatrix returns a dense minute-by-event-type count matrix for a match.
+// Returns domain.ErrMatchNotFound if the match has no events.
+func (r *ClickHouseRepository) GetEventMatrix(ctx context.Context, matchID string) (domain.EventMatrix, error) {
+	perMinute, err := r.GetEventsPerMinute(ctx, matchID)
+	if err != nil {
+		return domain.EventMatrix{}, err
+	}
+	if len(perMinute) == 0 {
+		return domain.EventMatrix{}, fmt.Errorf("match %s: %w", matchID, domain.ErrMatchNotFound)
+	}
+	return domain.NewEventMatrix(matchID, perMinute), nil
+}
+
+// GetConversionStats counts a match's shots, shots whose metadata has
+// on_target set to true, and goals. Returns domain.ErrMatchNotFound if the
+// match has no events.
+func (r *ClickHouseRepository) GetConversionStats(ctx context.Context, matchID string) (domain.ConversionStats, error) {
+	if matchID == "" {
+		return domain.ConversionStats{}, fmt.Errorf("matchID cannot be empty")
+	}
+
+	if r.conn == nil {
+		return domain.ConversionStats{}, ErrNotConnected
+	}
+
+	ctx, cancel := r.readContext(ctx)
+	defer cancel()
+
+	startTime := time.Now()
+
+	row := r.conn.QueryRow(ctx, fmt.Sprintf(`
+		SELECT
+			count(*) as total_events,
+			countIf(event_type = 'shot') as shots,
+			countIf(event_type = 'shot' AND JSONExtractBool(metadata, 'on_target')) as shots_on_target,
+			countIf(event_type = 'goal') as goals
+		FROM %s
+		WHERE match_id = ? %s
+	`, r.table, r.validEventsFilter()), matchID, matchID)
+
+	var totalEvents, shots, shotsOnTarget, goals uint64
+	err := row.Scan(&totalEvents, &shots, &shotsOnTarget, &goals)
+	duration := time.Since(startTime)
+	clickhouseQueryDuration.WithLabelValues("get_conversion_stats").Observe(duration.Seconds())
+
+	if err != nil {
+		r.logger.Error("failed to query conversion stats",
+			slog.String("match_id", matchID),
+			slog.Duration("duration", duration),
+			slog.String("error", err.Error()),
+		)
+		clickhouseQueryErrors.WithLabelValues("get_conversion_stats").Inc()
+		return domain.ConversionStats{}, queryError("failed to query conversion stats", err)
+	}
+	if totalEvents == 0 {
+		return domain.ConversionStats{}, fmt.Errorf("match %s: %w", matchID, domain.ErrMatchNotFound)
+	}
+
+	return domain.NewConversionStats(matchID, int64(shots), int64(shotsOnTarget), int64(goals)), nil
+}
+
+// GetLeaderboard ranks players by their events of eventType timestamped in
+// [from, to), across all matches, returning at most limit players with the
+// highest counts first. Ties are ordered by player ID. Corrected events and
+// events without a player are excluded.
+func (r *ClickHouseRepository) GetLeaderboard(ctx context.Context, eventType string, from, to time.Time, limit int) ([]domain.PlayerStat, error) {
+	if err := domain.ValidateLeaderboardFetch(eventType, from, to, limit); err != nil {
+		return nil, err
+	}
+
+	if r.conn == nil {
+		return nil, ErrNotConnected
+	}
+
+	ctx, cancel := r.readContext(ctx)
+	defer cancel()
+
+	const operation = "get_leaderboard"
+	startTime := time.Now()
+
+	// A correction may be logged after the range ends, so the corrections
+	// subquery is not bounded by it.
+	rows, err := r.conn.Query(ctx, fmt.Sprintf(`
+		SELECT
+			assumeNotNull(player_id) as player,
+			count(*) as event_count,
+			uniqExact(match_id) as matches
+		FROM %s
+		WHERE event_type = ?
+			AND timestamp >= ? AND timestamp < ?
+			AND player_id IS NOT NULL AND player_id != ''
+			AND event_id NOT IN (
+				SELECT toUUIDOrZero(JSONExtractString(metadata, 'correctsEventId'))
+				FROM %s
+				WHERE event_type = 'correction'
+			)
+		GROUP BY player
+		ORDER BY event_count DESC, player ASC
+		LIMIT ?
+	`, r.readTable(), r.table), eventType, from, to, limit)
+	if err != nil {
+		duration := time.Since(startTime)
+		r.logger.Error("failed to query leaderboard",
+			slog.String("event_type", eventType),
+			slog.Duration("duration", duration),
+			slog.String("error", err.Error()),
+		)
+		clickhouseQueryErrors.WithLabelValues(operation).Inc()
+		clickhouseQueryDuration.WithLabelValues(operation).Observe(duration.Seconds())
+		return nil, queryError("failed to query leaderboard", err)
+	}
+	defer rows.Close()
+
+	var results []domain.PlayerStat
+	for rows.Next() {
+		var playerID string
+		var eventCount, matches uint64
+		if err := rows.Scan(&playerID, &eventCount, &matches); err != nil {
+			r.logger.Warn("failed to scan leaderboard row",
+				slog.String("error", err.Error()),
+			)
+			continue
+		}
+		results = append(results, domain.PlayerStat{
+			PlayerID: playerID,
+			Count:    int64(eventCount),
+			Matches:  int64(matches),
+		})
+	}
+
+	if err := rows.Err(); err != nil {
+		duration := time.Since(startTime)
+		r.logger.Error("error iterating leaderboard rows",
+			slog.String("event_type", eventType),
+			slog.String("error", err.Error()),
+		)
+		clickhouseQueryErrors.WithLabelValues(operation).Inc()
+		clickhouseQueryDuration.WithLabelValues(operation).Observe(duration.Seconds())
+		return nil, queryError("error iterating leaderboard", err)
+	}
+
+	clickhouseQueryDuration.WithLabelValues(operation).Observe(time.Since(startTime).Seconds())
+	return results, nil
+}
+
+// StreamEvents reads all stored events for a match in timestamp order and
+// invokes fn for each one without loading the full result set into memory.
+// Iteration stops at the first error returned by fn.
+func (r *ClickHouseRepository) StreamEvents(ctx context.Context, matchID string, fn func(*domain.Event) error) error {
+	if matchID == "" {
+		return fmt.Errorf("matchID cannot be empty")
+	}
+
+	if r.conn == nil {
+		return ErrNotConnected
+	}
+
+	startTime := time.Now()
+
+	rows, err := r.conn.Query(ctx, fmt.Sprintf(`
+		SELECT
+			event_id,
+			match_id,
+			event_type,
+			team_id,
+			player_id,
+			metadata,
+			timestamp
+		FROM %s
+		WHERE match_id = ?
+		ORDER BY timestamp ASC
+	`, r.table), matchID)
+	if err != nil {
+		duration := time.Since(startTime)
+		r.logger.Error("failed to query events for streaming",
+			slog.String("match_id", matchID),
+			slog.Duration("duration", duration),
+			slog.String("error", err.Error()),
+		)
+		clickhouseQueryErrors.WithLabelValues("stream_events").Inc()
+		clickhouseQueryDuration.WithLabelValues("stream_events").Observe(duration.Seconds())
+		return queryError("failed to query events", err)
+	}
+	defer rows.Close()
+
+	var streamed int
+	for rows.Next() {
+		event, err := scanEvent(rows, r.config.UseJSONNumber)
+		if err != nil {
+			r.logger.Warn("failed to scan event row",
+				slog.String("match_id", matchID),
+				slog.String("error", err.Error()),
+			)
+			continue
+		}
+		if err := fn(event); err != nil {
+			clickhouseQueryDuration.WithLabelValues("stream_events").Observe(time.Since(startTime).Seconds())
+			return err
+		}
+		streamed++
+	}
+
+	if err := rows.Err(); err != nil {
+		duration := time.Since(startTime)
+		r.logger.Error("error iterating streamed event rows",
+			slog.String("match_id", matchID),
+			slog.String("error", err.Error()),
+		)
+		clickhouseQueryErrors.WithLabelValues("stream_events").Inc()
+		clickhouseQueryDuration.WithLabelValues("stream_events").Observe(duration.Seconds())
+		return queryError("error iterating events", err)
+	}
+
+	duration := time.Since(startTime)
+	clickhouseQueryDuration.WithLabelValues("stream_events").Observe(duration.Seconds())
+
+	r.logger.Debug("successfully streamed events",
+		slog.String("match_id", matchID),
+		slog.Int("event_count", streamed),
+		slog.Duration("duration", duration),
+	)
+
+	return nil
+}
+
+// SearchEvents returns a match's events whose metadata matches every filter,
+// in timestamp order. At most domain.MaxSearchResults+1 events are returned so
+// callers can tell when results were cut off. A filter matches a
+// string value exactly, or the JSON text of a number or boolean (e.g. "45",
+// "true"). Corrected events are excluded.
+func (r *ClickHouseRepository) SearchEvents(ctx context.Context, matchID string, metadataFilters map[string]string) ([]*domain.Event, error) {
+	if matchID == "" {
+		return nil, fmt.Errorf("matchID cannot be empty")
+	}
+	if err := domain.ValidateMetadataFilters(metadataFilters); err != nil {
+		return nil, err
+	}
+
+	if r.conn == nil {
+		return nil, ErrNotConnected
+	}
+
+	ctx, cancel := r.readContext(ctx)
+	defer cancel()
+
+	// Sort keys so the same filters always produce the same query
+	keys := make([]string, 0, len(metadataFilters))
+	for key := range metadataFilters {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var filter strings.Builder
+	args := []any{matchID}
+	for _, key := range keys {
+		filter.WriteString(" AND (JSONExtractString(metadata, ?) = ? OR JSONExtractRaw(metadata, ?) = ?)")
+		args = append(args, key, metadataFilters[key], key, metadataFilters[key])
+	}
+	args = append(args, matchID)
+
+	startTime := time.Now()
+
+	rows, err := r.conn.Query(ctx, fmt.Sprintf(`
+		SELECT
+			event_id,
+			match_id,
+			event_type,
+			team_id,
+			player_id,
+			metadata,
+			timestamp
+		FROM %s
+		WHERE match_id = ?%s %s
+		ORDER BY timestamp ASC
+		LIMIT %d
+	`, r.table, filter.String(), r.validEventsFilter(), domain.MaxSearchResults+1), args...)
+	if err != nil {
+		duration := time.Since(startTime)
+		r.logger.Error("failed to search events",
+			slog.String("match_id", matchID),
+			slog.Duration("duration", duration),
+			slog.String("error", err.Error()),
+		)
+		clickhouseQueryErrors.WithLabelValues("search_events").Inc()
+		clickhouseQueryDuration.WithLabelValues("search_events").Observe(duration.Seconds())
+		return nil, queryError("failed to search events", err)
+	}
+	defer rows.Close()
+
+	events := []*domain.Event{}
+	for rows.Next() {
+		event, err := scanEvent(rows, r.config.UseJSONNumber)
+		if err != nil {
+			r.logger.Warn("failed to scan event row",
+				slog.String("match_id", matchID),
+				slog.String("error", err.Error()),
+			)
+			continue
+		}
+		events = append(events, event)
+	}
+
+	if err := rows.Err(); err != nil {
+		duration := time.Since(startTime)
+		r.logger.Error("error iterating searched event rows",
+			slog.String("match_id", matchID),
+			slog.String("error", err.Error()),
+		)
+		clickhouseQueryErrors.WithLabelValues("search_events").Inc()
+		clickhouseQueryDuration.WithLabelValues("search_events").Observe(duration.Seconds())
+		return nil, queryError("error iterating events", err)
+	}
+
+	duration := time.Since(startTime)
+	clickhouseQueryDuration.WithLabelValues("search_events").Observe(duration.Seconds())
+
+	r.logger.Debug("successfully searched events",
+		slog.String("match_id", matchID),
+		slog.Int("filter_count", len(keys)),
+		slog.Int("result_count", len(events)),
+		slog.Duration("duration", duration),
+	)
+
+	return events, nil
+}
+
+// scanEvent converts a match_events row into a domain Event. With useNumber,
+// metadata numbers decode as json.Number.
+func scanEvent(rows driver.Rows, useNumber bool) (*domain.Event, error) {
+	var (
+		eventID   uuid.UUID
+		matchID   string
+		eventType string
+		teamID    string
+		playerID  *string
+		metadata  string
+		timestamp time.Time
+	)
+	if err := rows.Scan(&eventID, &matchID, &eventType, &teamID, &playerID, &metadata, &timestamp); err != nil {
+		return nil, err
+	}
+
+	event := &domain.Event{
+		EventID:   eventID,
+		MatchID:   matchID,
+		EventType: domain.EventType(eventType),
+		Timestamp: timestamp,
+	}
+
+	teamIDInt, err := strconv.Atoi(teamID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid team_id %q: %w", teamID, err)
+	}
+	event.TeamID = teamIDInt
+
+	if playerID != nil {
+		event.PlayerID = *playerID
+	}
+
+	if metadata != "" && metadata != "{}" {
+		if err := domain.DecodeJSON([]byte(metadata), &event.Metadata, useNumber); err != nil {
+			return nil, fmt.Errorf("invalid metadata: %w", err)
+		}
+	}
+
+	return event, nil
+}
+
 // Close closes the ClickHouse connection.
 func (r *ClickHouseRepository) Close() error {
 	if r.conn == nil {
@@ -412,9 +2276,41 @@ func (r *ClickHouseRepository) Close() error {
 	return r.conn.Close()
 }
 
+// Supported ClickHouse wire protocols.
+const (
+	ProtocolNative = "native"
+	ProtocolHTTP   = "http"
+)
+
+// ParseProtocol converts a protocol name into a clickhouse.Protocol.
+// An empty name selects the native protocol.
+func ParseProtocol(name string) (clickhouse.Protocol, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", ProtocolNative:
+		return clickhouse.Native, nil
+	case ProtocolHTTP:
+		return clickhouse.HTTP, nil
+	default:
+		return clickhouse.Native, fmt.Errorf("unsupported ClickHouse protocol %q (expected %s or %s)", name, ProtocolNative, ProtocolHTTP)
+	}
+}
+
+// ValidateProtocolPort rejects protocol/port combinations that are obviously
+// wrong, such as the native protocol pointed at the default HTTP port.
+func ValidateProtocolPort(protocol clickhouse.Protocol, port int) error {
+	switch {
+	case protocol == clickhouse.Native && (port == 8123 || port == 8443):
+		return fmt.Errorf("port %d is a ClickHouse HTTP port but protocol is %s", port, ProtocolNative)
+	case protocol == clickhouse.HTTP && (port == 9000 || port == 9440):
+		return fmt.Errorf("port %d is a ClickHouse native port but protocol is %s", port, ProtocolHTTP)
+	}
+	return nil
+}
+
 // ConnectionConfig holds configuration for ClickHouse connection.
 type ConnectionConfig struct {
 	Hosts           []string
+	Protocol        string
 	Database        string
 	Username        string
 	Password        string
@@ -430,6 +2326,7 @@ type ConnectionConfig struct {
 func DefaultConnectionConfig() ConnectionConfig {
 	return ConnectionConfig{
 		Hosts:           []string{"localhost:9000"},
+		Protocol:        ProtocolNative,
 		Database:        "fanfinity",
 		Username:        "default",
 		Password:        "",
@@ -444,8 +2341,43 @@ func DefaultConnectionConfig() ConnectionConfig {
 
 // NewConnection creates a new ClickHouse connection with the given configuration.
 func NewConnection(cfg ConnectionConfig) (driver.Conn, error) {
-	opts := &clickhouse.Options{
-		Addr: cfg.Hosts,
+	opts, err := connectionOptions(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := clickhouse.Open(opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open ClickHouse connection: %w", err)
+	}
+
+	return conn, nil
+}
+
+// connectionOptions builds driver options from the connection configuration,
+// validating the protocol against each host's port.
+func connectionOptions(cfg ConnectionConfig) (*clickhouse.Options, error) {
+	protocol, err := ParseProtocol(cfg.Protocol)
+	if err != nil {
+		return nil, err
+	}
+	for _, host := range cfg.Hosts {
+		_, portStr, err := net.SplitHostPort(host)
+		if err != nil {
+			continue
+		}
+		port, err := strconv.Atoi(portStr)
+		if err != nil {
+			continue
+		}
+		if err := ValidateProtocolPort(protocol, port); err != nil {
+			return nil, fmt.Errorf("invalid ClickHouse host %s: %w", host, err)
+		}
+	}
+
+	return &clickhouse.Options{
+		Protocol: protocol,
+		Addr:     cfg.Hosts,
 		Auth: clickhouse.Auth{
 			Database: cfg.Database,
 			Username: cfg.Username,
@@ -459,14 +2391,7 @@ func NewConnection(cfg ConnectionConfig) (driver.Conn, error) {
 		MaxIdleConns:    cfg.MaxIdleConns,
 		ConnMaxLifetime: cfg.ConnMaxLifetime,
 		Debug:           cfg.Debug,
-	}
-
-	conn, err := clickhouse.Open(opts)
-	if err != nil {
-		return nil, fmt.Errorf("failed to open ClickHouse connection: %w", err)
-	}
-
-	return conn, nil
+	}, nil
 }
 
 // NewConnectionFromDSN creates a new ClickHouse connection from a DSN string.
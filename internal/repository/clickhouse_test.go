@@ -2,16 +2,149 @@ package repository
 
 import (
 	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"reflect"
+	"strings"
+	"syscall"
 	"testing"
 	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"fanfinity/internal/domain"
 )
 
+// mockConn implements driver.Conn for testing repository queries.
+// Only the methods exercised by the repository are implemented; calling any
+// other method panics via the nil embedded interface.
+type mockConn struct {
+	driver.Conn
+	queryRowFunc func(ctx context.Context, query string, args ...any) driver.Row
+	queryFunc    func(ctx context.Context, query string, args ...any) (driver.Rows, error)
+	prepareFunc  func(ctx context.Context, query string) (driver.Batch, error)
+	execFunc     func(ctx context.Context, query string, args ...any) error
+}
+
+func (m *mockConn) Exec(ctx context.Context, query string, args ...any) error {
+	if m.execFunc != nil {
+		return m.execFunc(ctx, query, args...)
+	}
+	return nil
+}
+
+func (m *mockConn) PrepareBatch(ctx context.Context, query string, opts ...driver.PrepareBatchOption) (driver.Batch, error) {
+	if m.prepareFunc != nil {
+		return m.prepareFunc(ctx, query)
+	}
+	return &mockBatch{}, nil
+}
+
+func (m *mockConn) QueryRow(ctx context.Context, query string, args ...any) driver.Row {
+	if m.queryRowFunc != nil {
+		return m.queryRowFunc(ctx, query, args...)
+	}
+	return &mockRow{err: fmt.Errorf("unexpected QueryRow")}
+}
+
+func (m *mockConn) Query(ctx context.Context, query string, args ...any) (driver.Rows, error) {
+	if m.queryFunc != nil {
+		return m.queryFunc(ctx, query, args...)
+	}
+	return &mockRows{}, nil
+}
+
+// mockBatch implements driver.Batch, recording appended rows.
+type mockBatch struct {
+	driver.Batch
+	rows [][]any
+	sent bool
+}
+
+func (b *mockBatch) Append(v ...any) error {
+	b.rows = append(b.rows, v)
+	return nil
+}
+
+func (b *mockBatch) Send() error {
+	b.sent = true
+	return nil
+}
+
+// contextSettings returns the ClickHouse settings the repository attached to
+// the ctx a query was issued with.
+func contextSettings(ctx context.Context) clickhouse.Settings {
+	settings, _ := ctx.Value(settingsKey{}).(clickhouse.Settings)
+	return settings
+}
+
+// mockRow implements driver.Row, scanning the configured values into dest.
+type mockRow struct {
+	driver.Row
+	values []any
+	err    error
+}
+
+func (r *mockRow) Err() error {
+	return r.err
+}
+
+func (r *mockRow) Scan(dest ...any) error {
+	if r.err != nil {
+		return r.err
+	}
+	return scanValues(r.values, dest)
+}
+
+// mockRows implements driver.Rows over a fixed set of rows.
+type mockRows struct {
+	driver.Rows
+	rows [][]any
+	pos  int
+}
+
+func (r *mockRows) Next() bool {
+	if r.pos >= len(r.rows) {
+		return false
+	}
+	r.pos++
+	return true
+}
+
+func (r *mockRows) Scan(dest ...any) error {
+	return scanValues(r.rows[r.pos-1], dest)
+}
+
+func (r *mockRows) Close() error { return nil }
+
+func (r *mockRows) Err() error { return nil }
+
+// scanValues assigns each value to the pointer at the same position in dest.
+func scanValues(values []any, dest []any) error {
+	if len(values) != len(dest) {
+		return fmt.Errorf("scan: expected %d destinations, got %d", len(values), len(dest))
+	}
+	for i, v := range values {
+		reflect.ValueOf(dest[i]).Elem().Set(reflect.ValueOf(v))
+	}
+	return nil
+}
+
 func TestDefaultConnectionConfig(t *testing.T) {
 	cfg := DefaultConnectionConfig()
 
 	if len(cfg.Hosts) != 1 || cfg.Hosts[0] != "localhost:9000" {
 		t.Errorf("unexpected hosts: %v", cfg.Hosts)
 	}
+	if cfg.Protocol != ProtocolNative {
+		t.Errorf("expected protocol native, got %s", cfg.Protocol)
+	}
 	if cfg.Database != "fanfinity" {
 		t.Errorf("expected database fanfinity, got %s", cfg.Database)
 	}
@@ -41,6 +174,46 @@ func TestDefaultConnectionConfig(t *testing.T) {
 	}
 }
 
+func TestConnectionOptions_Protocol(t *testing.T) {
+	tests := []struct {
+		name     string
+		protocol string
+		hosts    []string
+		expected clickhouse.Protocol
+		wantErr  bool
+	}{
+		{name: "default is native", protocol: "", hosts: []string{"ch:9000"}, expected: clickhouse.Native},
+		{name: "native", protocol: "native", hosts: []string{"ch:9000"}, expected: clickhouse.Native},
+		{name: "http", protocol: "http", hosts: []string{"lb:8123"}, expected: clickhouse.HTTP},
+		{name: "http case insensitive", protocol: "HTTP", hosts: []string{"lb:80"}, expected: clickhouse.HTTP},
+		{name: "unknown protocol", protocol: "grpc", hosts: []string{"ch:9000"}, wantErr: true},
+		{name: "native on http port", protocol: "native", hosts: []string{"ch:8123"}, wantErr: true},
+		{name: "http on native port", protocol: "http", hosts: []string{"ch1:8123", "ch2:9000"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultConnectionConfig()
+			cfg.Protocol = tt.protocol
+			cfg.Hosts = tt.hosts
+
+			opts, err := connectionOptions(cfg)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if opts.Protocol != tt.expected {
+				t.Errorf("expected protocol %v, got %v", tt.expected, opts.Protocol)
+			}
+		})
+	}
+}
+
 func TestConnectionConfig_CustomValues(t *testing.T) {
 	cfg := ConnectionConfig{
 		Hosts:           []string{"ch1:9000", "ch2:9000"},
@@ -110,6 +283,207 @@ func TestClickHouseRepository_Close_NilConnection(t *testing.T) {
 	}
 }
 
+func TestClickHouseRepository_NilConnection(t *testing.T) {
+	repo := NewClickHouseRepository(nil, nil)
+	ctx := context.Background()
+	event := &domain.Event{EventID: uuid.New(), MatchID: "match-123", EventType: domain.EventTypeGoal, TeamID: 1}
+
+	calls := map[string]func() error{
+		"Ping":        func() error { return repo.Ping(ctx) },
+		"CheckSchema": func() error { return repo.CheckSchema(ctx) },
+		"InsertBatch": func() error {
+			return repo.InsertBatch(ctx, []*domain.Event{event})
+		},
+		"GetMatchMetrics": func() error {
+			_, err := repo.GetMatchMetrics(ctx, "match-123")
+			return err
+		},
+		"GetEventsPerMinute": func() error {
+			_, err := repo.GetEventsPerMinute(ctx, "match-123")
+			return err
+		},
+		"GetTeamEventsPerMinute": func() error {
+			_, err := repo.GetTeamEventsPerMinute(ctx, "match-123", 1)
+			return err
+		},
+		"GetEventsPerSecond": func() error {
+			_, err := repo.GetEventsPerSecond(ctx, "match-123", time.Now())
+			return err
+		},
+		"GetEventMatrix": func() error {
+			_, err := repo.GetEventMatrix(ctx, "match-123")
+			return err
+		},
+		"MatchExists": func() error {
+			_, err := repo.MatchExists(ctx, "match-123")
+			return err
+		},
+		"GetMatchInfo": func() error {
+			_, err := repo.GetMatchInfo(ctx, "match-123")
+			return err
+		},
+		"UpsertMatchInfo": func() error {
+			return repo.UpsertMatchInfo(ctx, &domain.MatchInfo{MatchID: "match-123", Competition: "Cup"})
+		},
+		"StreamEvents": func() error {
+			return repo.StreamEvents(ctx, "match-123", func(*domain.Event) error { return nil })
+		},
+		"CountEvents": func() error {
+			_, err := repo.CountEvents(ctx, "match-123")
+			return err
+		},
+		"SearchEvents": func() error {
+			_, err := repo.SearchEvents(ctx, "match-123", map[string]string{"position": "penalty"})
+			return err
+		},
+		"PublishMatchLag": func() error {
+			return repo.PublishMatchLag(ctx, map[string]time.Duration{"match-123": time.Minute})
+		},
+		"LaggingMatches": func() error {
+			_, err := repo.LaggingMatches(ctx, time.Second)
+			return err
+		},
+		"PublishEventStatus": func() error {
+			return repo.PublishEventStatus(ctx, []domain.EventStatusUpdate{{EventID: uuid.New(), Status: domain.EventStatusPersisted}})
+		},
+		"EventStatus": func() error {
+			_, err := repo.EventStatus(ctx, uuid.New())
+			return err
+		},
+	}
+
+	for name, call := range calls {
+		t.Run(name, func(t *testing.T) {
+			if err := call(); !errors.Is(err, ErrNotConnected) {
+				t.Errorf("expected ErrNotConnected, got: %v", err)
+			}
+		})
+	}
+}
+
+func TestClickHouseRepository_ErrorCategories(t *testing.T) {
+	refused := &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}
+	tests := []struct {
+		name string
+		conn *mockConn
+		want domain.RepositoryErrorCategory
+	}{
+		{
+			name: "connection refused",
+			conn: &mockConn{queryFunc: func(ctx context.Context, query string, args ...any) (driver.Rows, error) {
+				return nil, refused
+			}},
+			want: domain.RepositoryErrorConnection,
+		},
+		{
+			name: "pool exhausted",
+			conn: &mockConn{queryFunc: func(ctx context.Context, query string, args ...any) (driver.Rows, error) {
+				return nil, clickhouse.ErrAcquireConnTimeout
+			}},
+			want: domain.RepositoryErrorConnection,
+		},
+		{
+			name: "deadline exceeded",
+			conn: &mockConn{queryFunc: func(ctx context.Context, query string, args ...any) (driver.Rows, error) {
+				return nil, fmt.Errorf("read: %w", context.DeadlineExceeded)
+			}},
+			want: domain.RepositoryErrorTimeout,
+		},
+		{
+			name: "query rejected",
+			conn: &mockConn{queryFunc: func(ctx context.Context, query string, args ...any) (driver.Rows, error) {
+				return nil, errors.New("code: 47, message: Missing columns: 'lag_ms'")
+			}},
+			want: domain.RepositoryErrorQuery,
+		},
+		{
+			name: "row does not scan",
+			conn: &mockConn{queryFunc: func(ctx context.Context, query string, args ...any) (driver.Rows, error) {
+				return &mockRows{rows: [][]any{{"match-123"}}}, nil
+			}},
+			want: domain.RepositoryErrorScan,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := NewClickHouseRepository(tt.conn, nil)
+			_, err := repo.LaggingMatches(context.Background(), time.Second)
+
+			var repoErr *domain.RepositoryError
+			if !errors.As(err, &repoErr) {
+				t.Fatalf("expected a *domain.RepositoryError, got %T: %v", err, err)
+			}
+			if repoErr.Category != tt.want {
+				t.Errorf("expected category %s, got %s", tt.want, repoErr.Category)
+			}
+		})
+	}
+}
+
+func TestClickHouseRepository_ErrorCategories_QueryRow(t *testing.T) {
+	repo := NewClickHouseRepository(&mockConn{
+		queryRowFunc: func(ctx context.Context, query string, args ...any) driver.Row {
+			return &mockRow{err: fmt.Errorf("read: %w", context.DeadlineExceeded)}
+		},
+	}, nil)
+
+	_, err := repo.GetMatchMetrics(context.Background(), "match-123")
+	if re := domain.AsRepositoryError(err); re == nil || re.Category != domain.RepositoryErrorTimeout {
+		t.Errorf("expected a timeout RepositoryError, got %v", err)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Error("expected the underlying error to stay reachable with errors.Is")
+	}
+}
+
+func TestClickHouseRepository_NotConnectedIsConnectionError(t *testing.T) {
+	_, err := NewClickHouseRepository(nil, nil).CountEvents(context.Background(), "match-123")
+	if re := domain.AsRepositoryError(err); re == nil || re.Category != domain.RepositoryErrorConnection {
+		t.Errorf("expected a connection RepositoryError, got %v", err)
+	}
+}
+
+func TestClickHouseRepository_EventStatus(t *testing.T) {
+	eventID := uuid.New()
+	batch := &mockBatch{}
+	var statusQuery string
+	var statusArgs []any
+	repo := NewClickHouseRepository(&mockConn{
+		prepareFunc: func(ctx context.Context, query string) (driver.Batch, error) {
+			return batch, nil
+		},
+		queryRowFunc: func(ctx context.Context, query string, args ...any) driver.Row {
+			statusQuery, statusArgs = query, args
+			return &mockRow{values: []any{"persisted"}}
+		},
+	}, nil)
+
+	err := repo.PublishEventStatus(context.Background(), []domain.EventStatusUpdate{
+		{EventID: eventID, MatchID: "match-123", Status: domain.EventStatusPersisted},
+	})
+	if err != nil {
+		t.Fatalf("unexpected publish error: %v", err)
+	}
+	if !batch.sent || len(batch.rows) != 1 {
+		t.Fatalf("expected one sent status row, got %d (sent=%v)", len(batch.rows), batch.sent)
+	}
+	if row := batch.rows[0]; row[0] != eventID || row[1] != "match-123" || row[2] != "persisted" {
+		t.Errorf("unexpected status row: %v", row)
+	}
+
+	status, err := repo.EventStatus(context.Background(), eventID)
+	if err != nil {
+		t.Fatalf("unexpected read error: %v", err)
+	}
+	if status != domain.EventStatusPersisted {
+		t.Errorf("expected status persisted, got %q", status)
+	}
+	if !strings.Contains(statusQuery, "fanfinity.event_status") || len(statusArgs) != 1 || statusArgs[0] != eventID {
+		t.Errorf("unexpected status query %q with args %v", statusQuery, statusArgs)
+	}
+}
+
 func TestClickHouseRepository_InsertBatch_EmptyBatch(t *testing.T) {
 	repo := NewClickHouseRepository(nil, nil)
 
@@ -120,6 +494,392 @@ func TestClickHouseRepository_InsertBatch_EmptyBatch(t *testing.T) {
 	}
 }
 
+func TestClickHouseRepository_InsertBatch_UnserializableMetadata(t *testing.T) {
+	good := &domain.Event{EventID: uuid.New(), MatchID: "match-1", EventType: domain.EventTypePass, TeamID: 1, Timestamp: time.Now()}
+	bad := &domain.Event{
+		EventID:   uuid.New(),
+		MatchID:   "match-1",
+		EventType: domain.EventTypeShot,
+		TeamID:    2,
+		Timestamp: time.Now(),
+		Metadata:  map[string]interface{}{"feed": make(chan int)},
+	}
+
+	batch := &mockBatch{}
+	repo := NewClickHouseRepository(&mockConn{
+		prepareFunc: func(ctx context.Context, query string) (driver.Batch, error) {
+			return batch, nil
+		},
+	}, nil)
+
+	err := repo.InsertBatch(context.Background(), []*domain.Event{good, bad})
+	pe := domain.AsPartialInsertError(err)
+	if pe == nil {
+		t.Fatalf("expected a PartialInsertError, got %v", err)
+	}
+	if len(pe.Rejected) != 1 || pe.Rejected[0].Event != bad || pe.Rejected[0].Field != "metadata" {
+		t.Fatalf("expected only the bad event rejected for its metadata, got %v", err)
+	}
+	if !batch.sent || len(batch.rows) != 1 || batch.rows[0][0] != good.EventID {
+		t.Errorf("expected only the good event to be sent, got %d rows (sent=%v)", len(batch.rows), batch.sent)
+	}
+}
+
+func TestClickHouseRepository_InsertBatch_Settings(t *testing.T) {
+	events := []*domain.Event{
+		{EventID: uuid.New(), MatchID: "match-1", EventType: domain.EventTypePass, TeamID: 1, Timestamp: time.Now()},
+		{EventID: uuid.New(), MatchID: "match-1", EventType: domain.EventTypeShot, TeamID: 2, Timestamp: time.Now()},
+	}
+
+	tests := []struct {
+		name     string
+		insert   InsertSettings
+		expected clickhouse.Settings
+	}{
+		{
+			name:     "default attaches no settings",
+			expected: nil,
+		},
+		{
+			name:   "quorum and dedup",
+			insert: InsertSettings{Quorum: 2, Deduplicate: true},
+			expected: clickhouse.Settings{
+				"insert_quorum":      2,
+				"insert_deduplicate": 1,
+			},
+		},
+		{
+			name:   "token implies dedup",
+			insert: InsertSettings{DeduplicationToken: true},
+			expected: clickhouse.Settings{
+				"insert_deduplicate":         1,
+				"insert_deduplication_token": deduplicationToken(events),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var captured clickhouse.Settings
+			batch := &mockBatch{}
+			conn := &mockConn{
+				prepareFunc: func(ctx context.Context, query string) (driver.Batch, error) {
+					captured = contextSettings(ctx)
+					return batch, nil
+				},
+			}
+
+			cfg := DefaultRepositoryConfig()
+			cfg.Insert = tt.insert
+			repo, err := NewClickHouseRepositoryWithConfig(conn, nil, cfg)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if err := repo.InsertBatch(context.Background(), events); err != nil {
+				t.Fatalf("InsertBatch failed: %v", err)
+			}
+			if !batch.sent || len(batch.rows) != len(events) {
+				t.Errorf("expected %d rows sent, got %d (sent=%v)", len(events), len(batch.rows), batch.sent)
+			}
+			if len(captured) != len(tt.expected) || (len(tt.expected) > 0 && !reflect.DeepEqual(captured, tt.expected)) {
+				t.Errorf("expected settings %v, got %v", tt.expected, captured)
+			}
+		})
+	}
+}
+
+func TestClickHouseRepository_InsertBatch_OptionalColumns(t *testing.T) {
+	events := []*domain.Event{
+		{EventID: uuid.New(), MatchID: "match-1", EventType: domain.EventTypePass, TeamID: 1, Timestamp: time.Now()},
+	}
+
+	tests := []struct {
+		name        string
+		optional    []string
+		tableHas    []string
+		wantColumn  bool
+		wantValues  int
+		wantLookups int
+	}{
+		{name: "not configured", tableHas: []string{"schema_version"}, wantValues: 7},
+		{name: "configured and present", optional: []string{"schema_version"}, tableHas: []string{"schema_version"}, wantColumn: true, wantValues: 8, wantLookups: 1},
+		{name: "configured but absent", optional: []string{"schema_version"}, wantValues: 7, wantLookups: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var query string
+			var lookups int
+			batch := &mockBatch{}
+			conn := &mockConn{
+				queryFunc: func(ctx context.Context, q string, args ...any) (driver.Rows, error) {
+					lookups++
+					if !strings.Contains(q, "system.columns") {
+						t.Errorf("unexpected query %q", q)
+					}
+					rows := &mockRows{}
+					for _, name := range tt.tableHas {
+						rows.rows = append(rows.rows, []any{name})
+					}
+					return rows, nil
+				},
+				prepareFunc: func(ctx context.Context, q string) (driver.Batch, error) {
+					query = q
+					return batch, nil
+				},
+			}
+
+			cfg := DefaultRepositoryConfig()
+			cfg.OptionalColumns = tt.optional
+			repo, err := NewClickHouseRepositoryWithConfig(conn, nil, cfg)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			// The second batch reuses the cached lookup
+			for i := 0; i < 2; i++ {
+				if err := repo.InsertBatch(context.Background(), events); err != nil {
+					t.Fatalf("InsertBatch failed: %v", err)
+				}
+			}
+
+			if got := strings.Contains(query, "schema_version"); got != tt.wantColumn {
+				t.Errorf("expected schema_version in INSERT to be %v, got query %q", tt.wantColumn, query)
+			}
+			if got := strings.Count(query, "?"); got != tt.wantValues {
+				t.Errorf("expected %d placeholders, got %d in %q", tt.wantValues, got, query)
+			}
+			if len(batch.rows[0]) != tt.wantValues {
+				t.Errorf("expected %d values per row, got %d", tt.wantValues, len(batch.rows[0]))
+			}
+			if tt.wantColumn && batch.rows[0][7] != uint16(EventSchemaVersion) {
+				t.Errorf("expected schema version %d, got %v", EventSchemaVersion, batch.rows[0][7])
+			}
+			if lookups != tt.wantLookups {
+				t.Errorf("expected %d column lookups, got %d", tt.wantLookups, lookups)
+			}
+		})
+	}
+}
+
+func TestClickHouseRepository_InsertBatch_OptionalColumnsLookupFailureBacksOff(t *testing.T) {
+	events := []*domain.Event{
+		{EventID: uuid.New(), MatchID: "match-1", EventType: domain.EventTypePass, TeamID: 1, Timestamp: time.Now()},
+	}
+	var lookups int
+	batch := &mockBatch{}
+	conn := &mockConn{
+		queryFunc: func(ctx context.Context, q string, args ...any) (driver.Rows, error) {
+			lookups++
+			return nil, errors.New("system.columns unavailable")
+		},
+		prepareFunc: func(ctx context.Context, q string) (driver.Batch, error) {
+			return batch, nil
+		},
+	}
+
+	cfg := DefaultRepositoryConfig()
+	cfg.OptionalColumns = []string{"schema_version"}
+	repo, err := NewClickHouseRepositoryWithConfig(conn, nil, cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for i := 0; i < 3; i++ {
+		if err := repo.InsertBatch(context.Background(), events); err != nil {
+			t.Fatalf("InsertBatch failed: %v", err)
+		}
+	}
+	if lookups != 1 {
+		t.Errorf("expected the failed lookup not to be retried at once, got %d lookups", lookups)
+	}
+	if got := len(batch.rows[len(batch.rows)-1]); got != 7 {
+		t.Errorf("expected only the required columns after a failed lookup, got %d values", got)
+	}
+}
+
+func TestClickHouseRepository_InsertBatch_OptionalColumnsLookupDoesNotBlockInserts(t *testing.T) {
+	events := []*domain.Event{
+		{EventID: uuid.New(), MatchID: "match-1", EventType: domain.EventTypePass, TeamID: 1, Timestamp: time.Now()},
+	}
+	started := make(chan struct{})
+	release := make(chan struct{})
+	conn := &mockConn{
+		queryFunc: func(ctx context.Context, q string, args ...any) (driver.Rows, error) {
+			close(started)
+			<-release
+			return &mockRows{rows: [][]any{{"schema_version"}}}, nil
+		},
+		prepareFunc: func(ctx context.Context, q string) (driver.Batch, error) {
+			return &mockBatch{}, nil
+		},
+	}
+
+	cfg := DefaultRepositoryConfig()
+	cfg.OptionalColumns = []string{"schema_version"}
+	repo, err := NewClickHouseRepositoryWithConfig(conn, nil, cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	first := make(chan error, 1)
+	go func() { first <- repo.InsertBatch(context.Background(), events) }()
+	<-started
+
+	// A second insert does not wait behind the slow lookup
+	done := make(chan error, 1)
+	go func() { done <- repo.InsertBatch(context.Background(), events) }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("InsertBatch failed: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Error("expected the insert not to wait for the optional columns lookup")
+	}
+
+	close(release)
+	if err := <-first; err != nil {
+		t.Errorf("InsertBatch failed: %v", err)
+	}
+}
+
+func TestNewClickHouseRepositoryWithConfig_RejectsUnknownOptionalColumn(t *testing.T) {
+	for _, optional := range [][]string{{"shoe_size"}, {"schema_version", "schema_version"}} {
+		cfg := DefaultRepositoryConfig()
+		cfg.OptionalColumns = optional
+		if _, err := NewClickHouseRepositoryWithConfig(nil, nil, cfg); err == nil {
+			t.Errorf("expected optional columns %v to be rejected", optional)
+		}
+	}
+}
+
+func TestClickHouseRepository_UpsertBatch_KeepsLatestVersion(t *testing.T) {
+	eventID := uuid.New()
+	first := &domain.Event{EventID: eventID, MatchID: "match-1", EventType: domain.EventTypeShot, TeamID: 1, Timestamp: time.Now(), Op: domain.EventOpUpsert}
+	other := &domain.Event{EventID: uuid.New(), MatchID: "match-1", EventType: domain.EventTypePass, TeamID: 1, Timestamp: time.Now()}
+	second := &domain.Event{EventID: eventID, MatchID: "match-1", EventType: domain.EventTypeGoal, TeamID: 1, Timestamp: time.Now(), Op: domain.EventOpUpsert}
+
+	batch := &mockBatch{}
+	var deleteQuery string
+	var deleteArgs []any
+	conn := &mockConn{
+		prepareFunc: func(ctx context.Context, query string) (driver.Batch, error) {
+			return batch, nil
+		},
+		execFunc: func(ctx context.Context, query string, args ...any) error {
+			deleteQuery, deleteArgs = query, args
+			return nil
+		},
+	}
+	repo := NewClickHouseRepository(conn, nil)
+
+	if err := repo.UpsertBatch(context.Background(), []*domain.Event{first, other, second}); err != nil {
+		t.Fatalf("UpsertBatch failed: %v", err)
+	}
+
+	// Only the last version of the upserted event is inserted
+	if len(batch.rows) != 2 {
+		t.Fatalf("expected 2 rows inserted, got %d", len(batch.rows))
+	}
+	if batch.rows[0][0] != other.EventID || batch.rows[1][0] != eventID || batch.rows[1][2] != string(domain.EventTypeGoal) {
+		t.Errorf("expected the other event then the latest goal version, got %v", batch.rows)
+	}
+
+	// Older stored versions are removed
+	if !strings.Contains(deleteQuery, "DELETE FROM fanfinity.match_events") || !strings.Contains(deleteQuery, "max(ingested_at)") {
+		t.Errorf("expected a delete of older versions, got: %s", deleteQuery)
+	}
+	want := []string{eventID.String()}
+	if len(deleteArgs) != 2 || !reflect.DeepEqual(deleteArgs[0], want) {
+		t.Errorf("expected delete args %v, got %v", want, deleteArgs)
+	}
+}
+
+func TestClickHouseRepository_UpsertBatch_DeleteFailureKeepsInsert(t *testing.T) {
+	tests := []struct {
+		name      string
+		failures  int
+		wantExecs int
+		wantStale float64
+	}{
+		{"delete retried until it succeeds", 1, 2, 0},
+		{"delete gives up after its attempts", upsertDeleteAttempts, upsertDeleteAttempts, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event := &domain.Event{EventID: uuid.New(), MatchID: "match-1", EventType: domain.EventTypeGoal, TeamID: 1, Timestamp: time.Now(), Op: domain.EventOpUpsert}
+
+			batch := &mockBatch{}
+			var prepares, execs int
+			conn := &mockConn{
+				prepareFunc: func(ctx context.Context, query string) (driver.Batch, error) {
+					prepares++
+					return batch, nil
+				},
+				execFunc: func(ctx context.Context, query string, args ...any) error {
+					execs++
+					if execs <= tt.failures {
+						return errors.New("too many parts")
+					}
+					return nil
+				},
+			}
+			repo := NewClickHouseRepository(conn, nil)
+			staleBefore := testutil.ToFloat64(clickhouseStaleVersions)
+
+			if err := repo.UpsertBatch(context.Background(), []*domain.Event{event}); err != nil {
+				t.Fatalf("expected the stored batch to succeed, got %v", err)
+			}
+			if prepares != 1 || len(batch.rows) != 1 {
+				t.Errorf("expected one insert of one row, got %d inserts and %d rows", prepares, len(batch.rows))
+			}
+			if execs != tt.wantExecs {
+				t.Errorf("expected %d delete attempts, got %d", tt.wantExecs, execs)
+			}
+			if got := testutil.ToFloat64(clickhouseStaleVersions) - staleBefore; got != tt.wantStale {
+				t.Errorf("expected %v stale versions counted, got %v", tt.wantStale, got)
+			}
+		})
+	}
+}
+
+func TestClickHouseRepository_UpsertBatch_WithoutUpsertsOnlyInserts(t *testing.T) {
+	batch := &mockBatch{}
+	conn := &mockConn{
+		prepareFunc: func(ctx context.Context, query string) (driver.Batch, error) {
+			return batch, nil
+		},
+		execFunc: func(ctx context.Context, query string, args ...any) error {
+			t.Errorf("unexpected query: %s", query)
+			return nil
+		},
+	}
+	repo := NewClickHouseRepository(conn, nil)
+
+	events := []*domain.Event{{EventID: uuid.New(), MatchID: "match-1", EventType: domain.EventTypePass, TeamID: 1, Timestamp: time.Now()}}
+	if err := repo.UpsertBatch(context.Background(), events); err != nil {
+		t.Fatalf("UpsertBatch failed: %v", err)
+	}
+	if len(batch.rows) != 1 {
+		t.Errorf("expected 1 row inserted, got %d", len(batch.rows))
+	}
+}
+
+func TestDeduplicationToken_StablePerBatch(t *testing.T) {
+	a := &domain.Event{EventID: uuid.New()}
+	b := &domain.Event{EventID: uuid.New()}
+
+	if deduplicationToken([]*domain.Event{a, b}) != deduplicationToken([]*domain.Event{a, b}) {
+		t.Error("expected identical batches to share a token")
+	}
+	if deduplicationToken([]*domain.Event{a, b}) == deduplicationToken([]*domain.Event{b, a}) {
+		t.Error("expected differently ordered batches to differ")
+	}
+}
+
 func TestClickHouseRepository_GetMatchMetrics_EmptyMatchID(t *testing.T) {
 	repo := NewClickHouseRepository(nil, nil)
 
@@ -150,6 +910,1443 @@ func TestClickHouseRepository_GetEventsPerMinute_EmptyMatchID(t *testing.T) {
 	}
 }
 
+func TestClickHouseRepository_GetMatchMetrics_DistinctPlayers(t *testing.T) {
+	first := time.Date(2024, 1, 15, 14, 0, 0, 0, time.UTC)
+	last := first.Add(90 * time.Minute)
+
+	conn := &mockConn{
+		queryRowFunc: func(ctx context.Context, query string, args ...any) driver.Row {
+			if strings.Contains(query, "match_final") {
+				return &mockRow{err: sql.ErrNoRows}
+			}
+			if strings.Contains(query, "uniqExactIf(player_id") {
+				return &mockRow{values: []any{uint64(120), uint64(3), uint64(2), uint64(0), uint64(17), first, last}}
+			}
+			return &mockRow{values: []any{first, uint64(12), float64(12)}}
+		},
+		queryFunc: func(ctx context.Context, query string, args ...any) (driver.Rows, error) {
+			return &mockRows{rows: [][]any{{"pass", uint64(100)}, {"goal", uint64(3)}}}, nil
+		},
+	}
+	repo := NewClickHouseRepository(conn, nil)
+
+	metrics, err := repo.GetMatchMetrics(context.Background(), "match-123")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if metrics.DistinctPlayers != 17 {
+		t.Errorf("expected 17 distinct players, got %d", metrics.DistinctPlayers)
+	}
+
+	data, err := json.Marshal(metrics)
+	if err != nil {
+		t.Fatalf("failed to marshal metrics: %v", err)
+	}
+	if !strings.Contains(string(data), `"distinctPlayers":17`) {
+		t.Errorf("expected distinctPlayers in JSON, got %s", data)
+	}
+}
+
+func TestClickHouseRepository_ReadFinal(t *testing.T) {
+	first := time.Date(2024, 1, 15, 14, 0, 0, 0, time.UTC)
+
+	for _, final := range []bool{false, true} {
+		t.Run(fmt.Sprintf("final=%v", final), func(t *testing.T) {
+			var queries []string
+			conn := &mockConn{
+				queryRowFunc: func(ctx context.Context, query string, args ...any) driver.Row {
+					queries = append(queries, query)
+					if strings.Contains(query, "match_final") {
+						return &mockRow{err: sql.ErrNoRows}
+					}
+					if strings.Contains(query, "uniqExactIf(player_id") {
+						return &mockRow{values: []any{uint64(10), uint64(0), uint64(0), uint64(0), uint64(2), first, first}}
+					}
+					return &mockRow{values: []any{first, uint64(10), float64(10)}}
+				},
+				queryFunc: func(ctx context.Context, query string, args ...any) (driver.Rows, error) {
+					queries = append(queries, query)
+					return &mockRows{}, nil
+				},
+			}
+			cfg := DefaultRepositoryConfig()
+			cfg.ReadFinal = final
+			repo, err := NewClickHouseRepositoryWithConfig(conn, nil, cfg)
+			if err != nil {
+				t.Fatalf("unexpected config error: %v", err)
+			}
+
+			if _, err := repo.GetMatchMetrics(context.Background(), "match-123"); err != nil {
+				t.Fatalf("unexpected metrics error: %v", err)
+			}
+			if _, err := repo.GetEventsPerMinute(context.Background(), "match-123"); err != nil {
+				t.Fatalf("unexpected events per minute error: %v", err)
+			}
+
+			var eventReads int
+			for _, query := range queries {
+				if !strings.Contains(query, "FROM fanfinity.match_events\n") && !strings.Contains(query, "FROM fanfinity.match_events FINAL") {
+					continue
+				}
+				eventReads++
+				if got := strings.Contains(query, "FROM fanfinity.match_events FINAL"); got != final {
+					t.Errorf("expected FINAL=%v in query:\n%s", final, query)
+				}
+			}
+			// Metrics totals, events by type, peak minute, phases and events per minute
+			if eventReads != 5 {
+				t.Errorf("expected 5 events table reads, got %d", eventReads)
+			}
+		})
+	}
+}
+
+func TestClickHouseRepository_GetMatchMetrics_AvgEventsPerMinute(t *testing.T) {
+	first := time.Date(2024, 1, 15, 14, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		total    uint64
+		last     time.Time
+		expected float64
+	}{
+		{"multi-minute match", 180, first.Add(90 * time.Minute), 2},
+		{"single instant", 5, first, 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := &mockConn{
+				queryRowFunc: func(ctx context.Context, query string, args ...any) driver.Row {
+					if strings.Contains(query, "uniqExactIf(player_id") {
+						return &mockRow{values: []any{tt.total, uint64(0), uint64(0), uint64(0), uint64(0), first, tt.last}}
+					}
+					return &mockRow{err: sql.ErrNoRows}
+				},
+			}
+			repo := NewClickHouseRepository(conn, nil)
+
+			metrics, err := repo.GetMatchMetrics(context.Background(), "match-123")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if metrics.AvgEventsPerMinute != tt.expected {
+				t.Errorf("expected %v events per minute, got %v", tt.expected, metrics.AvgEventsPerMinute)
+			}
+		})
+	}
+}
+
+func TestClickHouseRepository_GetMatchMetrics_UnknownMatch(t *testing.T) {
+	conn := &mockConn{
+		queryRowFunc: func(ctx context.Context, query string, args ...any) driver.Row {
+			if strings.Contains(query, "match_final") {
+				return &mockRow{err: sql.ErrNoRows}
+			}
+			return &mockRow{values: []any{uint64(0), uint64(0), uint64(0), uint64(0), uint64(0), time.Time{}, time.Time{}}}
+		},
+	}
+	repo := NewClickHouseRepository(conn, nil)
+
+	metrics, err := repo.GetMatchMetrics(context.Background(), "unknown-match")
+	if !errors.Is(err, domain.ErrMatchNotFound) {
+		t.Fatalf("expected ErrMatchNotFound, got %v", err)
+	}
+	if metrics != nil {
+		t.Errorf("expected nil metrics, got %+v", metrics)
+	}
+}
+
+func TestClickHouseRepository_GetMatchMetrics_FinalLookupFailure(t *testing.T) {
+	first := time.Date(2024, 1, 15, 14, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		finalErr  error
+		wantError bool
+	}{
+		{"missing final table serves live metrics", &clickhouse.Exception{Code: unknownTableCode, Message: "Table fanfinity.match_final does not exist"}, false},
+		{"other failure is returned", &clickhouse.Exception{Code: 241, Message: "Memory limit exceeded"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := &mockConn{
+				queryRowFunc: func(ctx context.Context, query string, args ...any) driver.Row {
+					if strings.Contains(query, "match_final") {
+						return &mockRow{err: tt.finalErr}
+					}
+					if strings.Contains(query, "match_info") {
+						return &mockRow{err: sql.ErrNoRows}
+					}
+					if strings.Contains(query, "uniqExactIf(player_id") {
+						return &mockRow{values: []any{uint64(10), uint64(1), uint64(0), uint64(0), uint64(2), first, first}}
+					}
+					return &mockRow{values: []any{first, uint64(10), float64(10)}}
+				},
+				queryFunc: func(ctx context.Context, query string, args ...any) (driver.Rows, error) {
+					return &mockRows{}, nil
+				},
+			}
+			repo := NewClickHouseRepository(conn, nil)
+
+			metrics, err := repo.GetMatchMetrics(context.Background(), "match-123")
+			if tt.wantError {
+				if err == nil {
+					t.Fatalf("expected the final metrics error, got metrics %+v", metrics)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if metrics.TotalEvents != 10 {
+				t.Errorf("expected live metrics with 10 events, got %d", metrics.TotalEvents)
+			}
+		})
+	}
+}
+
+func TestClickHouseRepository_GetMatchMetrics_DeleteCorrection(t *testing.T) {
+	first := time.Date(2024, 1, 15, 14, 0, 0, 0, time.UTC)
+	last := first.Add(80 * time.Minute)
+
+	// Stored rows: two goals and a delete correction retracting the second one.
+	// The mock answers like ClickHouse would, depending on whether the query
+	// applies the corrected-events filter.
+	conn := &mockConn{
+		queryRowFunc: func(ctx context.Context, query string, args ...any) driver.Row {
+			filtered := strings.Contains(query, "event_type != 'correction'") &&
+				strings.Contains(query, "JSONExtractString(metadata, 'correctsEventId')")
+			if strings.Contains(query, "uniqExactIf(player_id") {
+				if filtered {
+					if len(args) != 2 || args[1] != "match-123" {
+						t.Errorf("expected match ID for the correction subquery, got %v", args)
+					}
+					return &mockRow{values: []any{uint64(1), uint64(1), uint64(0), uint64(0), uint64(1), first, first}}
+				}
+				return &mockRow{values: []any{uint64(3), uint64(2), uint64(0), uint64(0), uint64(2), first, last}}
+			}
+			return &mockRow{err: sql.ErrNoRows}
+		},
+		queryFunc: func(ctx context.Context, query string, args ...any) (driver.Rows, error) {
+			if !strings.Contains(query, "event_type != 'correction'") {
+				return &mockRows{rows: [][]any{{"goal", uint64(2)}, {"correction", uint64(1)}}}, nil
+			}
+			return &mockRows{rows: [][]any{{"goal", uint64(1)}}}, nil
+		},
+	}
+	repo := NewClickHouseRepository(conn, nil)
+
+	metrics, err := repo.GetMatchMetrics(context.Background(), "match-123")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if metrics.Goals != 1 {
+		t.Errorf("expected deleted goal to be excluded, got %d goals", metrics.Goals)
+	}
+	if metrics.TotalEvents != 1 {
+		t.Errorf("expected 1 valid event, got %d", metrics.TotalEvents)
+	}
+	if _, ok := metrics.EventsByType["correction"]; ok {
+		t.Error("expected correction rows to be excluded from eventsByType")
+	}
+	if metrics.EventsByType["goal"] != 1 {
+		t.Errorf("expected 1 goal in eventsByType, got %d", metrics.EventsByType["goal"])
+	}
+}
+
+func TestClickHouseRepository_GetMatchMetrics_WeightedPeak(t *testing.T) {
+	first := time.Date(2024, 1, 15, 14, 0, 0, 0, time.UTC)
+	goalMinute := first.Add(30 * time.Minute)
+
+	weights := domain.DefaultEngagementWeights()
+	weights[domain.EventTypeGoal] = 10
+
+	var peakQuery string
+	conn := &mockConn{
+		queryRowFunc: func(ctx context.Context, query string, args ...any) driver.Row {
+			if strings.Contains(query, "match_final") {
+				return &mockRow{err: sql.ErrNoRows}
+			}
+			if strings.Contains(query, "uniqExactIf(player_id") {
+				return &mockRow{values: []any{uint64(50), uint64(1), uint64(0), uint64(0), uint64(10), first, goalMinute}}
+			}
+			if strings.Contains(query, "match_info") {
+				return &mockRow{err: sql.ErrNoRows}
+			}
+			peakQuery = query
+			return &mockRow{values: []any{goalMinute, uint64(3), float64(12)}}
+		},
+	}
+	repo, err := NewClickHouseRepositoryWithConfig(conn, nil, RepositoryConfig{EngagementWeights: weights})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	metrics, err := repo.GetMatchMetrics(context.Background(), "match-123")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !strings.Contains(peakQuery, "sum(multiIf(") {
+		t.Errorf("expected weighted multiIf in peak query, got: %s", peakQuery)
+	}
+	if !strings.Contains(peakQuery, "event_type = 'goal', toFloat64(10)") {
+		t.Errorf("expected goal weight in peak query, got: %s", peakQuery)
+	}
+	if !strings.Contains(peakQuery, "ORDER BY score DESC") {
+		t.Errorf("expected peak query to order by score, got: %s", peakQuery)
+	}
+
+	if metrics.PeakMinute == nil {
+		t.Fatal("expected peak minute to be set")
+	}
+	if !metrics.PeakMinute.Minute.Equal(goalMinute) {
+		t.Errorf("expected peak minute %v, got %v", goalMinute, metrics.PeakMinute.Minute)
+	}
+	if metrics.PeakMinute.Score != 12 {
+		t.Errorf("expected peak score 12, got %f", metrics.PeakMinute.Score)
+	}
+}
+
+func TestClickHouseRepository_GetMatchMetricsWithOptions_SkipPeak(t *testing.T) {
+	first := time.Date(2024, 1, 15, 14, 0, 0, 0, time.UTC)
+
+	peakQueried := false
+	conn := &mockConn{
+		queryRowFunc: func(ctx context.Context, query string, args ...any) driver.Row {
+			if strings.Contains(query, "match_final") {
+				return &mockRow{err: sql.ErrNoRows}
+			}
+			if strings.Contains(query, "uniqExactIf(player_id") {
+				return &mockRow{values: []any{uint64(50), uint64(1), uint64(0), uint64(0), uint64(10), first, first.Add(30 * time.Minute)}}
+			}
+			if strings.Contains(query, "match_info") {
+				return &mockRow{err: sql.ErrNoRows}
+			}
+			if strings.Contains(query, "toStartOfMinute") {
+				peakQueried = true
+			}
+			return &mockRow{values: []any{first, uint64(3), float64(3)}}
+		},
+	}
+	repo := NewClickHouseRepository(conn, nil)
+
+	metrics, err := repo.GetMatchMetricsWithOptions(context.Background(), "match-123", domain.MetricsOptions{SkipPeak: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if peakQueried {
+		t.Error("expected the peak query not to be issued")
+	}
+	if metrics.PeakMinute != nil {
+		t.Errorf("expected no peak minute, got %+v", metrics.PeakMinute)
+	}
+	if metrics.TotalEvents != 50 {
+		t.Errorf("expected 50 total events, got %d", metrics.TotalEvents)
+	}
+}
+
+func TestClickHouseRepository_GetMatchMetrics_ByPhase(t *testing.T) {
+	kickoff := time.Date(2024, 1, 15, 14, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		elapsed   time.Duration
+		phaseRows [][]any
+		want      map[string]domain.PhaseMetrics
+	}{
+		{
+			name:    "events spanning both halves",
+			elapsed: 80 * time.Minute,
+			phaseRows: [][]any{
+				{"first_half", uint64(3), uint64(1), uint64(0), uint64(0)},
+				{"second_half", uint64(2), uint64(0), uint64(1), uint64(1)},
+			},
+			want: map[string]domain.PhaseMetrics{
+				"first_half":  {TotalEvents: 3, Goals: 1},
+				"second_half": {TotalEvents: 2, YellowCards: 1, RedCards: 1},
+			},
+		},
+		{
+			name:      "match shorter than the first half",
+			elapsed:   10 * time.Minute,
+			phaseRows: [][]any{{"first_half", uint64(5), uint64(0), uint64(0), uint64(0)}},
+			want:      map[string]domain.PhaseMetrics{"first_half": {TotalEvents: 5}},
+		},
+		{
+			name:    "reached phase without events",
+			elapsed: 100 * time.Minute,
+			phaseRows: [][]any{
+				{"first_half", uint64(4), uint64(0), uint64(0), uint64(0)},
+				{"extra_time", uint64(1), uint64(1), uint64(0), uint64(0)},
+			},
+			want: map[string]domain.PhaseMetrics{
+				"first_half":  {TotalEvents: 4},
+				"second_half": {},
+				"extra_time":  {TotalEvents: 1, Goals: 1},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var phaseQuery string
+			var phaseArgs []any
+			conn := &mockConn{
+				queryRowFunc: func(ctx context.Context, query string, args ...any) driver.Row {
+					if strings.Contains(query, "uniqExactIf(player_id") {
+						return &mockRow{values: []any{uint64(5), uint64(1), uint64(1), uint64(1), uint64(0), kickoff, kickoff.Add(tt.elapsed)}}
+					}
+					return &mockRow{err: sql.ErrNoRows}
+				},
+				queryFunc: func(ctx context.Context, query string, args ...any) (driver.Rows, error) {
+					if !strings.Contains(query, "as phase") {
+						return &mockRows{}, nil
+					}
+					phaseQuery, phaseArgs = query, args
+					return &mockRows{rows: tt.phaseRows}, nil
+				},
+			}
+			repo := NewClickHouseRepository(conn, nil)
+
+			metrics, err := repo.GetMatchMetrics(context.Background(), "match-123")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(metrics.ByPhase, tt.want) {
+				t.Errorf("expected phases %v, got %v", tt.want, metrics.ByPhase)
+			}
+
+			// Offsets are measured from the first event, latest phase checked first
+			if len(phaseArgs) == 0 || phaseArgs[0] != kickoff.UnixMilli() {
+				t.Errorf("expected kickoff %d as the first argument, got %v", kickoff.UnixMilli(), phaseArgs)
+			}
+			wantExpr := "multiIf(offset_ms >= 5400000, 'extra_time', offset_ms >= 2700000, 'second_half', 'first_half')"
+			if !strings.Contains(phaseQuery, wantExpr) {
+				t.Errorf("expected phase expression %s in query:\n%s", wantExpr, phaseQuery)
+			}
+		})
+	}
+}
+
+func TestNewClickHouseRepositoryWithConfig_InvalidPhases(t *testing.T) {
+	cfg := DefaultRepositoryConfig()
+	cfg.Phases = domain.MatchPhases{{Name: "first_half", Start: 0}, {Name: "x'; DROP TABLE t; --", Start: time.Minute}}
+	if _, err := NewClickHouseRepositoryWithConfig(nil, nil, cfg); err == nil {
+		t.Error("expected an error for an unsafe phase name")
+	}
+}
+
+func TestClickHouseRepository_GetMatchMetrics_ScorelessStreak(t *testing.T) {
+	kickoff := time.Date(2024, 1, 15, 14, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		goals      []time.Time
+		wantQuery  bool
+		wantStreak int64
+	}{
+		{"no goals", nil, false, 0},
+		{"one goal", []time.Time{kickoff.Add(10 * time.Minute)}, false, 0},
+		{"several goals", []time.Time{kickoff.Add(5 * time.Minute), kickoff.Add(20 * time.Minute), kickoff.Add(80 * time.Minute)}, true, 60 * 60},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			queried := false
+			conn := &mockConn{
+				queryRowFunc: func(ctx context.Context, query string, args ...any) driver.Row {
+					if strings.Contains(query, "uniqExactIf(player_id") {
+						return &mockRow{values: []any{uint64(50), uint64(len(tt.goals)), uint64(0), uint64(0), uint64(0), kickoff, kickoff.Add(90 * time.Minute)}}
+					}
+					return &mockRow{err: sql.ErrNoRows}
+				},
+				queryFunc: func(ctx context.Context, query string, args ...any) (driver.Rows, error) {
+					if !strings.Contains(query, "AND event_type = 'goal'") {
+						return &mockRows{}, nil
+					}
+					queried = true
+					rows := make([][]any, 0, len(tt.goals))
+					for _, at := range tt.goals {
+						rows = append(rows, []any{at})
+					}
+					return &mockRows{rows: rows}, nil
+				},
+			}
+			repo := NewClickHouseRepository(conn, nil)
+
+			metrics, err := repo.GetMatchMetrics(context.Background(), "match-123")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if queried != tt.wantQuery {
+				t.Errorf("expected goal timestamp query %v, got %v", tt.wantQuery, queried)
+			}
+			if metrics.LongestScorelessStreakSeconds != tt.wantStreak {
+				t.Errorf("expected streak of %d seconds, got %d", tt.wantStreak, metrics.LongestScorelessStreakSeconds)
+			}
+		})
+	}
+}
+
+func TestClickHouseRepository_GetEventMatrix(t *testing.T) {
+	minute := time.Date(2024, 1, 15, 14, 0, 0, 0, time.UTC)
+
+	conn := &mockConn{
+		queryFunc: func(ctx context.Context, query string, args ...any) (driver.Rows, error) {
+			if args[0] == "unknown" {
+				return &mockRows{}, nil
+			}
+			return &mockRows{rows: [][]any{
+				{minute, "pass", uint64(4)},
+				{minute.Add(2 * time.Minute), "goal", uint64(1)},
+			}}, nil
+		},
+	}
+	repo := NewClickHouseRepository(conn, nil)
+
+	matrix, err := repo.GetEventMatrix(context.Background(), "match-123")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(matrix.Minutes) != 3 {
+		t.Errorf("expected 3 zero-filled rows, got %d", len(matrix.Minutes))
+	}
+
+	if _, err := repo.GetEventMatrix(context.Background(), "unknown"); !errors.Is(err, domain.ErrMatchNotFound) {
+		t.Errorf("expected ErrMatchNotFound, got %v", err)
+	}
+}
+
+func TestClickHouseRepository_ConfiguredTable(t *testing.T) {
+	first := time.Date(2024, 1, 15, 14, 0, 0, 0, time.UTC)
+
+	var queries []string
+	conn := &mockConn{
+		queryRowFunc: func(ctx context.Context, query string, args ...any) driver.Row {
+			queries = append(queries, query)
+			if strings.Contains(query, "match_final") {
+				return &mockRow{err: sql.ErrNoRows}
+			}
+			if strings.Contains(query, "uniqExactIf(player_id") {
+				return &mockRow{values: []any{uint64(1), uint64(0), uint64(0), uint64(0), uint64(1), first, first}}
+			}
+			if strings.Contains(query, "SELECT 1") {
+				return &mockRow{values: []any{uint8(1)}}
+			}
+			return &mockRow{values: []any{first, uint64(1), float64(1)}}
+		},
+		queryFunc: func(ctx context.Context, query string, args ...any) (driver.Rows, error) {
+			queries = append(queries, query)
+			return &mockRows{}, nil
+		},
+	}
+
+	repo, err := NewClickHouseRepositoryWithConfig(conn, nil, RepositoryConfig{
+		Database: "analytics_staging",
+		Table:    "match_events_dist",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ctx := context.Background()
+	if _, err := repo.GetMatchMetrics(ctx, "match-123"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := repo.GetEventsPerMinute(ctx, "match-123"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := repo.MatchExists(ctx, "match-123"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := repo.StreamEvents(ctx, "match-123", func(*domain.Event) error { return nil }); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(queries) < 6 {
+		t.Fatalf("expected at least 6 queries, got %d", len(queries))
+	}
+	for _, query := range queries {
+		if strings.Contains(query, "match_info") {
+			if !strings.Contains(query, "FROM analytics_staging.match_info") {
+				t.Errorf("expected match info query to use configured database, got:\n%s", query)
+			}
+			continue
+		}
+		if strings.Contains(query, "match_final") {
+			if !strings.Contains(query, "FROM analytics_staging.match_final") {
+				t.Errorf("expected final metrics query to use configured database, got:\n%s", query)
+			}
+			continue
+		}
+		if !strings.Contains(query, "FROM analytics_staging.match_events_dist") {
+			t.Errorf("expected query to reference configured table, got:\n%s", query)
+		}
+		if strings.Contains(query, "fanfinity.match_events") {
+			t.Errorf("expected no hardcoded table, got:\n%s", query)
+		}
+	}
+}
+
+// finalTableConn serves live metrics from totalEvents and stores final
+// snapshots written to the match_final table, like ClickHouse would.
+type finalTableConn struct {
+	totalEvents uint64
+	snapshots   []string
+
+	// rival, when set, is stored ahead of the next snapshot inserted, as if a
+	// concurrent close with an earlier closed_at had won
+	rival string
+}
+
+func (f *finalTableConn) conn() *mockConn {
+	first := time.Date(2024, 1, 15, 14, 0, 0, 0, time.UTC)
+	return &mockConn{
+		queryRowFunc: func(ctx context.Context, query string, args ...any) driver.Row {
+			switch {
+			case strings.Contains(query, "match_final"):
+				if len(f.snapshots) == 0 {
+					return &mockRow{err: sql.ErrNoRows}
+				}
+				return &mockRow{values: []any{f.snapshots[0]}}
+			case strings.Contains(query, "uniqExactIf(player_id"):
+				return &mockRow{values: []any{f.totalEvents, uint64(0), uint64(0), uint64(0), uint64(1), first, first}}
+			case strings.Contains(query, "match_info"):
+				return &mockRow{err: sql.ErrNoRows}
+			}
+			return &mockRow{values: []any{first, f.totalEvents, float64(f.totalEvents)}}
+		},
+		queryFunc: func(ctx context.Context, query string, args ...any) (driver.Rows, error) {
+			return &mockRows{rows: [][]any{{"pass", f.totalEvents}}}, nil
+		},
+		execFunc: func(ctx context.Context, query string, args ...any) error {
+			if strings.Contains(query, "INSERT INTO fanfinity.match_final") {
+				if f.rival != "" {
+					f.snapshots = append(f.snapshots, f.rival)
+					f.rival = ""
+				}
+				f.snapshots = append(f.snapshots, args[1].(string))
+			}
+			return nil
+		},
+	}
+}
+
+func TestClickHouseRepository_CloseMatch_FreezesMetrics(t *testing.T) {
+	store := &finalTableConn{totalEvents: 10}
+	repo := NewClickHouseRepository(store.conn(), nil)
+	ctx := context.Background()
+
+	closed, err := repo.CloseMatch(ctx, "match-123")
+	if err != nil {
+		t.Fatalf("CloseMatch failed: %v", err)
+	}
+	if closed.ClosedAt == nil || closed.TotalEvents != 10 {
+		t.Fatalf("expected closed snapshot of 10 events, got %+v", closed)
+	}
+	if len(store.snapshots) != 1 {
+		t.Fatalf("expected one snapshot stored, got %d", len(store.snapshots))
+	}
+
+	// Late events change the live aggregates but not the official metrics
+	store.totalEvents = 25
+
+	metrics, err := repo.GetMatchMetrics(ctx, "match-123")
+	if err != nil {
+		t.Fatalf("GetMatchMetrics failed: %v", err)
+	}
+	if metrics.TotalEvents != 10 || metrics.EventsByType["pass"] != 10 {
+		t.Errorf("expected the snapshot's 10 events, got total=%d byType=%v", metrics.TotalEvents, metrics.EventsByType)
+	}
+	if metrics.ClosedAt == nil || !metrics.ClosedAt.Equal(*closed.ClosedAt) {
+		t.Errorf("expected closedAt %v, got %v", closed.ClosedAt, metrics.ClosedAt)
+	}
+
+	// Closing again keeps the original snapshot
+	again, err := repo.CloseMatch(ctx, "match-123")
+	if !errors.Is(err, domain.ErrMatchClosed) {
+		t.Fatalf("expected ErrMatchClosed, got %v", err)
+	}
+	if again.TotalEvents != 10 || len(store.snapshots) != 1 {
+		t.Errorf("expected the original snapshot to be kept, got total=%d snapshots=%d", again.TotalEvents, len(store.snapshots))
+	}
+}
+
+func TestClickHouseRepository_CloseMatch_UsesClock(t *testing.T) {
+	store := &finalTableConn{totalEvents: 10}
+	closedAt := time.Date(2024, 1, 15, 16, 0, 0, 123_456_789, time.UTC)
+	cfg := DefaultRepositoryConfig()
+	cfg.Clock = domain.NewFixedClock(closedAt)
+	repo, err := NewClickHouseRepositoryWithConfig(store.conn(), nil, cfg)
+	if err != nil {
+		t.Fatalf("unexpected config error: %v", err)
+	}
+
+	closed, err := repo.CloseMatch(context.Background(), "match-123")
+	if err != nil {
+		t.Fatalf("CloseMatch failed: %v", err)
+	}
+	want := closedAt.Truncate(time.Millisecond)
+	if closed.ClosedAt == nil || !closed.ClosedAt.Equal(want) {
+		t.Errorf("expected closedAt %v from the clock, got %v", want, closed.ClosedAt)
+	}
+}
+
+func TestClickHouseRepository_CloseMatch_ConcurrentCloseLoses(t *testing.T) {
+	rivalAt := time.Date(2024, 1, 15, 15, 0, 0, 0, time.UTC)
+	rival, err := json.Marshal(domain.MatchMetrics{MatchID: "match-123", TotalEvents: 8, ClosedAt: &rivalAt})
+	if err != nil {
+		t.Fatalf("failed to encode rival snapshot: %v", err)
+	}
+	store := &finalTableConn{totalEvents: 10, rival: string(rival)}
+	repo := NewClickHouseRepository(store.conn(), nil)
+
+	// Both closes found the match open; the rival's snapshot is served
+	closed, err := repo.CloseMatch(context.Background(), "match-123")
+	if !errors.Is(err, domain.ErrMatchClosed) {
+		t.Fatalf("expected ErrMatchClosed for the losing close, got %v", err)
+	}
+	if closed.TotalEvents != 8 || closed.ClosedAt == nil || !closed.ClosedAt.Equal(rivalAt) {
+		t.Errorf("expected the winning snapshot, got %+v", closed)
+	}
+}
+
+func TestClickHouseRepository_GetMatchMetrics_OpenMatchIsLive(t *testing.T) {
+	store := &finalTableConn{totalEvents: 10}
+	repo := NewClickHouseRepository(store.conn(), nil)
+
+	store.totalEvents = 12
+	metrics, err := repo.GetMatchMetrics(context.Background(), "match-123")
+	if err != nil {
+		t.Fatalf("GetMatchMetrics failed: %v", err)
+	}
+	if metrics.TotalEvents != 12 || metrics.ClosedAt != nil {
+		t.Errorf("expected live metrics for an open match, got total=%d closedAt=%v", metrics.TotalEvents, metrics.ClosedAt)
+	}
+}
+
+func TestClickHouseRepository_GetMatchMetrics_MatchInfo(t *testing.T) {
+	first := time.Date(2024, 1, 15, 14, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name            string
+		infoRow         *mockRow
+		wantCompetition string
+		wantTeamNames   map[int]string
+	}{
+		{
+			name:            "registered info",
+			infoRow:         &mockRow{values: []any{"Saudi Pro League", map[string]string{"1": "Al Hilal", "2": "Al Nassr"}}},
+			wantCompetition: "Saudi Pro League",
+			wantTeamNames:   map[int]string{1: "Al Hilal", 2: "Al Nassr"},
+		},
+		{
+			name:    "no registered info",
+			infoRow: &mockRow{err: sql.ErrNoRows},
+		},
+		{
+			name:    "match info query fails",
+			infoRow: &mockRow{err: errors.New("table fanfinity.match_info does not exist")},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := &mockConn{
+				queryRowFunc: func(ctx context.Context, query string, args ...any) driver.Row {
+					switch {
+					case strings.Contains(query, "uniqExactIf(player_id"):
+						return &mockRow{values: []any{uint64(10), uint64(0), uint64(0), uint64(0), uint64(0), first, first}}
+					case strings.Contains(query, "FROM fanfinity.match_info"):
+						return tt.infoRow
+					}
+					return &mockRow{err: sql.ErrNoRows}
+				},
+			}
+			repo := NewClickHouseRepository(conn, nil)
+
+			metrics, err := repo.GetMatchMetrics(context.Background(), "match-123")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if metrics.TotalEvents != 10 {
+				t.Errorf("expected 10 events, got %d", metrics.TotalEvents)
+			}
+			if metrics.Competition != tt.wantCompetition {
+				t.Errorf("expected competition %q, got %q", tt.wantCompetition, metrics.Competition)
+			}
+			if !reflect.DeepEqual(metrics.TeamNames, tt.wantTeamNames) {
+				t.Errorf("expected team names %v, got %v", tt.wantTeamNames, metrics.TeamNames)
+			}
+		})
+	}
+}
+
+func TestClickHouseRepository_UpsertMatchInfo(t *testing.T) {
+	var gotQuery string
+	var gotArgs []any
+	conn := &mockConn{
+		execFunc: func(ctx context.Context, query string, args ...any) error {
+			gotQuery = query
+			gotArgs = args
+			return nil
+		},
+	}
+	repo := NewClickHouseRepository(conn, nil)
+
+	err := repo.UpsertMatchInfo(context.Background(), &domain.MatchInfo{
+		MatchID:     "match-123",
+		Competition: "Saudi Pro League",
+		TeamNames:   map[int]string{1: "Al Hilal"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(gotQuery, "INSERT INTO fanfinity.match_info") {
+		t.Errorf("expected insert into match_info, got:\n%s", gotQuery)
+	}
+	if len(gotArgs) != 4 || gotArgs[0] != "match-123" || gotArgs[1] != "Saudi Pro League" {
+		t.Fatalf("unexpected args: %v", gotArgs)
+	}
+	if !reflect.DeepEqual(gotArgs[2], map[string]string{"1": "Al Hilal"}) {
+		t.Errorf("expected team names keyed by string team ID, got %v", gotArgs[2])
+	}
+}
+
+func TestNewClickHouseRepositoryWithConfig_RejectsUnsafeNames(t *testing.T) {
+	tests := []struct {
+		name     string
+		database string
+		table    string
+	}{
+		{name: "injection in table", database: "fanfinity", table: "match_events; DROP TABLE users"},
+		{name: "quoted table", database: "fanfinity", table: "`match_events`"},
+		{name: "dotted database", database: "fanfinity.other", table: "match_events"},
+		{name: "leading digit", database: "fanfinity", table: "1events"},
+		{name: "whitespace", database: "fanfinity ", table: "match_events"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo, err := NewClickHouseRepositoryWithConfig(&mockConn{}, nil, RepositoryConfig{
+				Database: tt.database,
+				Table:    tt.table,
+			})
+			if err == nil {
+				t.Fatal("expected error for unsafe identifier")
+			}
+			if repo != nil {
+				t.Error("expected nil repository")
+			}
+		})
+	}
+}
+
+func TestNewClickHouseRepository_DefaultTable(t *testing.T) {
+	repo := NewClickHouseRepository(&mockConn{}, nil)
+
+	if repo.table != "fanfinity.match_events" {
+		t.Errorf("expected default table fanfinity.match_events, got %s", repo.table)
+	}
+}
+
+func TestClickHouseRepository_GetTeamEventsPerMinute(t *testing.T) {
+	var gotQuery string
+	var gotArgs []any
+	conn := &mockConn{
+		queryFunc: func(ctx context.Context, query string, args ...any) (driver.Rows, error) {
+			gotQuery, gotArgs = query, args
+			return &mockRows{rows: [][]any{{time.Now(), "pass", uint64(3)}}}, nil
+		},
+	}
+	repo := NewClickHouseRepository(conn, nil)
+
+	results, err := repo.GetTeamEventsPerMinute(context.Background(), "match-123", 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(results) != 1 {
+		t.Errorf("expected 1 row, got %d", len(results))
+	}
+	if !strings.Contains(gotQuery, "AND team_id = ?") {
+		t.Errorf("expected team filter in query, got:\n%s", gotQuery)
+	}
+	// The trailing match ID feeds the corrected-events subquery
+	if len(gotArgs) != 3 || gotArgs[0] != "match-123" || gotArgs[1] != "2" || gotArgs[2] != "match-123" {
+		t.Errorf("unexpected args %v", gotArgs)
+	}
+
+	if _, err := repo.GetTeamEventsPerMinute(context.Background(), "match-123", 0); err == nil {
+		t.Error("expected error for invalid team")
+	}
+}
+
+func TestClickHouseRepository_CheckSchema(t *testing.T) {
+	var gotQuery string
+	queryErr := errors.New("Table fanfinity.match_events does not exist")
+	conn := &mockConn{
+		queryFunc: func(ctx context.Context, query string, args ...any) (driver.Rows, error) {
+			gotQuery = query
+			if queryErr != nil {
+				return nil, queryErr
+			}
+			return &mockRows{}, nil
+		},
+	}
+	repo := NewClickHouseRepository(conn, nil)
+
+	if err := repo.CheckSchema(context.Background()); !errors.Is(err, queryErr) {
+		t.Errorf("expected the query error, got: %v", err)
+	}
+	if gotQuery != "SELECT 1 FROM fanfinity.match_events LIMIT 0" {
+		t.Errorf("unexpected schema check query: %q", gotQuery)
+	}
+
+	queryErr = nil
+	if err := repo.CheckSchema(context.Background()); err != nil {
+		t.Errorf("unexpected error once the table is queryable: %v", err)
+	}
+}
+
+func TestClickHouseRepository_GetEventsPerSecond(t *testing.T) {
+	since := time.Date(2024, 1, 15, 14, 30, 0, 0, time.UTC)
+	var gotQuery string
+	var gotArgs []any
+	conn := &mockConn{
+		queryFunc: func(ctx context.Context, query string, args ...any) (driver.Rows, error) {
+			gotQuery, gotArgs = query, args
+			return &mockRows{rows: [][]any{
+				{since, uint64(3)},
+				{since.Add(2 * time.Second), uint64(1)},
+			}}, nil
+		},
+	}
+	repo := NewClickHouseRepository(conn, nil)
+
+	results, err := repo.GetEventsPerSecond(context.Background(), "match-123", since)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(results) != 2 || results[0].EventCount != 3 || !results[1].Second.Equal(since.Add(2*time.Second)) {
+		t.Errorf("unexpected results %+v", results)
+	}
+	if !strings.Contains(gotQuery, "toStartOfSecond(timestamp)") || !strings.Contains(gotQuery, "timestamp >= ?") {
+		t.Errorf("expected per-second bucketing over a window, got:\n%s", gotQuery)
+	}
+	// The trailing match ID feeds the corrected-events subquery
+	if len(gotArgs) != 3 || gotArgs[0] != "match-123" || gotArgs[1] != since || gotArgs[2] != "match-123" {
+		t.Errorf("unexpected args %v", gotArgs)
+	}
+
+	if _, err := repo.GetEventsPerSecond(context.Background(), "match-123", time.Time{}); err == nil {
+		t.Error("expected error for zero since")
+	}
+}
+
+func TestEngagementScoreExpr_DefaultWeights(t *testing.T) {
+	expr := engagementScoreExpr(domain.DefaultEngagementWeights())
+
+	if !strings.HasPrefix(expr, "multiIf(") || !strings.HasSuffix(expr, "toFloat64(1))") {
+		t.Errorf("unexpected expression: %s", expr)
+	}
+	if !strings.Contains(expr, "event_type = 'pass', toFloat64(1)") {
+		t.Errorf("expected default weight for pass, got: %s", expr)
+	}
+}
+
+func TestClickHouseRepository_GetLeaderboard(t *testing.T) {
+	from := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 1, 0)
+
+	var gotQuery string
+	var gotArgs []any
+	conn := &mockConn{
+		queryFunc: func(ctx context.Context, query string, args ...any) (driver.Rows, error) {
+			gotQuery, gotArgs = query, args
+			return &mockRows{rows: [][]any{
+				{"player-9", uint64(7), uint64(4)},
+				{"player-10", uint64(5), uint64(5)},
+			}}, nil
+		},
+	}
+	repo := NewClickHouseRepository(conn, nil)
+
+	players, err := repo.GetLeaderboard(context.Background(), "goal", from, to, 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []domain.PlayerStat{
+		{PlayerID: "player-9", Count: 7, Matches: 4},
+		{PlayerID: "player-10", Count: 5, Matches: 5},
+	}
+	if !reflect.DeepEqual(players, want) {
+		t.Errorf("expected %+v, got %+v", want, players)
+	}
+
+	// The range bounds the events but not the corrections that may exclude them
+	if got := strings.Count(gotQuery, "timestamp >= ? AND timestamp < ?"); got != 1 {
+		t.Errorf("expected only the events to be filtered on the range, got %s", gotQuery)
+	}
+	wantArgs := []any{"goal", from, to, 2}
+	if !reflect.DeepEqual(gotArgs, wantArgs) {
+		t.Errorf("expected args %v, got %v", wantArgs, gotArgs)
+	}
+	if !strings.Contains(gotQuery, "ORDER BY event_count DESC, player ASC") || !strings.Contains(gotQuery, "LIMIT ?") {
+		t.Errorf("expected the query to rank and limit players, got %s", gotQuery)
+	}
+
+	// With ReadFinal only the events are read through FINAL; the corrections
+	// subquery reads the plain table
+	cfg := DefaultRepositoryConfig()
+	cfg.ReadFinal = true
+	finalRepo, err := NewClickHouseRepositoryWithConfig(conn, nil, cfg)
+	if err != nil {
+		t.Fatalf("unexpected config error: %v", err)
+	}
+	if _, err := finalRepo.GetLeaderboard(context.Background(), "goal", from, to, 2); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := strings.Count(gotQuery, "FROM fanfinity.match_events FINAL"); got != 1 {
+		t.Errorf("expected only the events read to use FINAL, got %s", gotQuery)
+	}
+}
+
+func TestClickHouseRepository_GetLeaderboard_RejectsInvalidQueries(t *testing.T) {
+	from := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 1, 0)
+
+	tests := []struct {
+		name      string
+		eventType string
+		from, to  time.Time
+		limit     int
+		field     string
+	}{
+		{name: "unknown type", eventType: "header", from: from, to: to, limit: 10, field: "type"},
+		{name: "empty range", eventType: "goal", from: to, to: from, limit: 10, field: "to"},
+		{name: "zero limit", eventType: "goal", from: from, to: to, limit: 0, field: "limit"},
+		{name: "limit over max", eventType: "goal", from: from, to: to, limit: domain.MaxLeaderboardLimit + 2, field: "limit"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := &mockConn{
+				queryFunc: func(ctx context.Context, query string, args ...any) (driver.Rows, error) {
+					t.Error("expected no query for an invalid leaderboard request")
+					return &mockRows{}, nil
+				},
+			}
+			repo := NewClickHouseRepository(conn, nil)
+
+			_, err := repo.GetLeaderboard(context.Background(), tt.eventType, tt.from, tt.to, tt.limit)
+			ve := domain.AsValidationError(err)
+			if ve == nil || ve.Field != tt.field {
+				t.Errorf("expected a validation error on %s, got %v", tt.field, err)
+			}
+		})
+	}
+}
+
+func TestClickHouseRepository_StreamEvents(t *testing.T) {
+	ts := time.Date(2024, 1, 15, 14, 0, 0, 0, time.UTC)
+	player := "player-7"
+	id1, id2 := uuid.New(), uuid.New()
+
+	conn := &mockConn{
+		queryFunc: func(ctx context.Context, query string, args ...any) (driver.Rows, error) {
+			return &mockRows{rows: [][]any{
+				{id1, "match-123", "goal", "1", &player, `{"minute":45}`, ts},
+				{id2, "match-123", "pass", "2", (*string)(nil), "{}", ts.Add(time.Second)},
+			}}, nil
+		},
+	}
+	repo := NewClickHouseRepository(conn, nil)
+
+	var events []*domain.Event
+	err := repo.StreamEvents(context.Background(), "match-123", func(event *domain.Event) error {
+		events = append(events, event)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(events) != 2 {
+		t.Fatalf("expected 2 events, got %d", len(events))
+	}
+	if events[0].EventID != id1 || events[0].TeamID != 1 || events[0].PlayerID != "player-7" {
+		t.Errorf("unexpected first event: %+v", events[0])
+	}
+	if events[0].Metadata["minute"] != float64(45) {
+		t.Errorf("expected metadata minute 45, got %v", events[0].Metadata["minute"])
+	}
+	if events[1].PlayerID != "" || events[1].Metadata != nil {
+		t.Errorf("expected empty player and metadata for second event, got %+v", events[1])
+	}
+}
+
+func TestClickHouseRepository_StreamEvents_UseJSONNumber(t *testing.T) {
+	conn := &mockConn{
+		queryFunc: func(ctx context.Context, query string, args ...any) (driver.Rows, error) {
+			return &mockRows{rows: [][]any{
+				{uuid.New(), "match-123", "goal", "1", (*string)(nil), `{"minute":45}`, time.Now().UTC()},
+			}}, nil
+		},
+	}
+	repo, err := NewClickHouseRepositoryWithConfig(conn, nil, RepositoryConfig{UseJSONNumber: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var events []*domain.Event
+	err = repo.StreamEvents(context.Background(), "match-123", func(event *domain.Event) error {
+		events = append(events, event)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(events) != 1 || events[0].Metadata["minute"] != json.Number("45") {
+		t.Fatalf("expected metadata minute as json.Number 45, got %+v", events)
+	}
+	if got := events[0].MetadataJSON(); got != `{"minute":45}` {
+		t.Errorf("expected integer metadata to serialize unchanged, got %s", got)
+	}
+}
+
+func TestClickHouseRepository_StreamEvents_StopsOnCallbackError(t *testing.T) {
+	ts := time.Now().UTC()
+	conn := &mockConn{
+		queryFunc: func(ctx context.Context, query string, args ...any) (driver.Rows, error) {
+			return &mockRows{rows: [][]any{
+				{uuid.New(), "match-123", "goal", "1", (*string)(nil), "{}", ts},
+				{uuid.New(), "match-123", "pass", "1", (*string)(nil), "{}", ts},
+			}}, nil
+		},
+	}
+	repo := NewClickHouseRepository(conn, nil)
+
+	stopErr := fmt.Errorf("stop")
+	calls := 0
+	err := repo.StreamEvents(context.Background(), "match-123", func(event *domain.Event) error {
+		calls++
+		return stopErr
+	})
+	if err != stopErr {
+		t.Errorf("expected callback error to be returned, got: %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("expected iteration to stop after 1 call, got %d", calls)
+	}
+}
+
+func TestClickHouseRepository_SearchEvents_SingleFilter(t *testing.T) {
+	ts := time.Date(2024, 1, 15, 14, 0, 0, 0, time.UTC)
+	id := uuid.New()
+
+	var gotQuery string
+	var gotArgs []any
+	conn := &mockConn{
+		queryFunc: func(ctx context.Context, query string, args ...any) (driver.Rows, error) {
+			gotQuery, gotArgs = query, args
+			return &mockRows{rows: [][]any{
+				{id, "match-123", "goal", "1", (*string)(nil), `{"position":"penalty"}`, ts},
+			}}, nil
+		},
+	}
+	repo := NewClickHouseRepository(conn, nil)
+
+	events, err := repo.SearchEvents(context.Background(), "match-123", map[string]string{"position": "penalty"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(events) != 1 || events[0].EventID != id || events[0].Metadata["position"] != "penalty" {
+		t.Fatalf("unexpected events: %+v", events)
+	}
+	if strings.Count(gotQuery, "JSONExtractString(metadata, ?) = ?") != 1 {
+		t.Errorf("expected one metadata filter, got:\n%s", gotQuery)
+	}
+	if !strings.Contains(gotQuery, fmt.Sprintf("LIMIT %d", domain.MaxSearchResults+1)) {
+		t.Errorf("expected results to be capped, got:\n%s", gotQuery)
+	}
+	want := []any{"match-123", "position", "penalty", "position", "penalty", "match-123"}
+	if !reflect.DeepEqual(gotArgs, want) {
+		t.Errorf("expected args %v, got %v", want, gotArgs)
+	}
+}
+
+func TestClickHouseRepository_SearchEvents_MultipleFilters(t *testing.T) {
+	var gotQuery string
+	var gotArgs []any
+	conn := &mockConn{
+		queryFunc: func(ctx context.Context, query string, args ...any) (driver.Rows, error) {
+			gotQuery, gotArgs = query, args
+			return &mockRows{}, nil
+		},
+	}
+	repo := NewClickHouseRepository(conn, nil)
+
+	events, err := repo.SearchEvents(context.Background(), "match-123", map[string]string{
+		"position": "penalty",
+		"minute":   "45",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if events == nil || len(events) != 0 {
+		t.Errorf("expected an empty, non-nil result, got %v", events)
+	}
+	if strings.Count(gotQuery, "JSONExtractString(metadata, ?) = ?") != 2 {
+		t.Errorf("expected two metadata filters, got:\n%s", gotQuery)
+	}
+	// Filters are applied in key order
+	want := []any{"match-123", "minute", "45", "minute", "45", "position", "penalty", "position", "penalty", "match-123"}
+	if !reflect.DeepEqual(gotArgs, want) {
+		t.Errorf("expected args %v, got %v", want, gotArgs)
+	}
+}
+
+func TestClickHouseRepository_SearchEvents_InvalidFilters(t *testing.T) {
+	conn := &mockConn{
+		queryFunc: func(ctx context.Context, query string, args ...any) (driver.Rows, error) {
+			t.Fatal("query should not run for invalid filters")
+			return nil, nil
+		},
+	}
+	repo := NewClickHouseRepository(conn, nil)
+
+	tooMany := make(map[string]string)
+	for i := 0; i <= domain.MaxMetadataFilters; i++ {
+		tooMany[fmt.Sprintf("key%d", i)] = "v"
+	}
+	for name, filters := range map[string]map[string]string{
+		"bad key":   {"position')": "penalty"},
+		"too many":  tooMany,
+		"empty key": {"": "penalty"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := repo.SearchEvents(context.Background(), "match-123", filters); !domain.IsValidationError(err) {
+				t.Errorf("expected a validation error, got: %v", err)
+			}
+		})
+	}
+}
+
+func TestClickHouseRepository_CountEvents(t *testing.T) {
+	var gotQuery string
+	conn := &mockConn{
+		queryRowFunc: func(ctx context.Context, query string, args ...any) driver.Row {
+			gotQuery = query
+			return &mockRow{values: []any{uint64(42)}}
+		},
+	}
+	repo := NewClickHouseRepository(conn, nil)
+
+	count, err := repo.CountEvents(context.Background(), "match-123")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if count != 42 {
+		t.Errorf("expected 42, got %d", count)
+	}
+	if strings.Contains(gotQuery, "correction") {
+		t.Errorf("expected a raw row count including corrections, got:\n%s", gotQuery)
+	}
+}
+
+func TestClickHouseRepository_GetConversionStats(t *testing.T) {
+	tests := []struct {
+		name         string
+		row          *mockRow
+		wantOnTarget float64
+		wantPerShot  float64
+		wantErr      error
+	}{
+		{
+			name:         "shots and goals",
+			row:          &mockRow{values: []any{uint64(120), uint64(8), uint64(6), uint64(2)}},
+			wantOnTarget: 75,
+			wantPerShot:  0.25,
+		},
+		{
+			name: "no shots",
+			row:  &mockRow{values: []any{uint64(40), uint64(0), uint64(0), uint64(0)}},
+		},
+		{
+			name:    "unknown match",
+			row:     &mockRow{values: []any{uint64(0), uint64(0), uint64(0), uint64(0)}},
+			wantErr: domain.ErrMatchNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var captured string
+			conn := &mockConn{
+				queryRowFunc: func(ctx context.Context, query string, args ...any) driver.Row {
+					captured = query
+					return tt.row
+				},
+			}
+			repo := NewClickHouseRepository(conn, nil)
+
+			stats, err := repo.GetConversionStats(context.Background(), "match-123")
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("expected %v, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if stats.OnTargetPercentage != tt.wantOnTarget || stats.GoalsPerShot != tt.wantPerShot {
+				t.Errorf("expected %.2f%% on target and %.2f goals per shot, got %+v", tt.wantOnTarget, tt.wantPerShot, stats)
+			}
+			if !strings.Contains(captured, "JSONExtractBool(metadata, 'on_target')") {
+				t.Errorf("expected on_target to be read with JSONExtractBool, got:\n%s", captured)
+			}
+		})
+	}
+}
+
+func TestClickHouseRepository_MatchExists(t *testing.T) {
+	tests := []struct {
+		name     string
+		row      *mockRow
+		expected bool
+		wantErr  bool
+	}{
+		{"existing match", &mockRow{values: []any{uint8(1)}}, true, false},
+		{"unknown match", &mockRow{err: sql.ErrNoRows}, false, false},
+		{"query error", &mockRow{err: fmt.Errorf("connection reset")}, false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var captured string
+			var capturedArgs []any
+			conn := &mockConn{
+				queryRowFunc: func(ctx context.Context, query string, args ...any) driver.Row {
+					captured, capturedArgs = query, args
+					return tt.row
+				},
+			}
+			repo := NewClickHouseRepository(conn, nil)
+
+			exists, err := repo.MatchExists(context.Background(), "match-123")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unexpected error state: %v", err)
+			}
+			if exists != tt.expected {
+				t.Errorf("expected exists=%v, got %v", tt.expected, exists)
+			}
+			if !strings.Contains(captured, "LIMIT 1") {
+				t.Errorf("expected LIMIT 1 probe, got: %s", captured)
+			}
+			// Corrections and the events they retract do not make a match exist
+			if !strings.Contains(captured, "event_type != 'correction'") || len(capturedArgs) != 2 {
+				t.Errorf("expected the corrections filter, got: %s with args %v", captured, capturedArgs)
+			}
+		})
+	}
+}
+
+func TestClickHouseRepository_MaxExecutionTimePerQueryClass(t *testing.T) {
+	const (
+		readLimit   = 2 * time.Second
+		insertLimit = 30 * time.Second
+	)
+
+	// remaining returns how long ctx had left when the query was issued, and
+	// whether max_execution_time was attached
+	type observed struct {
+		remaining time.Duration
+		limited   bool
+	}
+	observe := func(ctx context.Context) observed {
+		deadline, ok := ctx.Deadline()
+		if !ok {
+			return observed{}
+		}
+		_, limited := contextSettings(ctx)["max_execution_time"]
+		return observed{remaining: time.Until(deadline), limited: limited}
+	}
+
+	var read, schema, insert, upsert observed
+	conn := &mockConn{
+		queryRowFunc: func(ctx context.Context, query string, args ...any) driver.Row {
+			read = observe(ctx)
+			return &mockRow{values: []any{uint8(1)}}
+		},
+		queryFunc: func(ctx context.Context, query string, args ...any) (driver.Rows, error) {
+			schema = observe(ctx)
+			return &mockRows{}, nil
+		},
+		prepareFunc: func(ctx context.Context, query string) (driver.Batch, error) {
+			insert = observe(ctx)
+			return &mockBatch{}, nil
+		},
+		execFunc: func(ctx context.Context, query string, args ...any) error {
+			upsert = observe(ctx)
+			return nil
+		},
+	}
+	cfg := DefaultRepositoryConfig()
+	cfg.ReadMaxExecutionTime = readLimit
+	cfg.InsertMaxExecutionTime = insertLimit
+	repo, err := NewClickHouseRepositoryWithConfig(conn, nil, cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err := repo.MatchExists(context.Background(), "match-123"); err != nil {
+		t.Fatalf("MatchExists failed: %v", err)
+	}
+	if err := repo.CheckSchema(context.Background()); err != nil {
+		t.Fatalf("CheckSchema failed: %v", err)
+	}
+	events := []*domain.Event{{EventID: uuid.New(), MatchID: "match-123", EventType: domain.EventTypePass, TeamID: 1, Timestamp: time.Now()}}
+	if err := repo.InsertBatch(context.Background(), events); err != nil {
+		t.Fatalf("InsertBatch failed: %v", err)
+	}
+	info := &domain.MatchInfo{MatchID: "match-123", TeamNames: map[int]string{1: "Home", 2: "Away"}}
+	if err := repo.UpsertMatchInfo(context.Background(), info); err != nil {
+		t.Fatalf("UpsertMatchInfo failed: %v", err)
+	}
+
+	for name, got := range map[string]observed{"read": read, "schema check": schema} {
+		if !got.limited || got.remaining > readLimit || got.remaining < readLimit-time.Second {
+			t.Errorf("expected %s bounded by %v, got %+v", name, readLimit, got)
+		}
+	}
+	for name, got := range map[string]observed{"insert": insert, "upsert": upsert} {
+		if !got.limited || got.remaining > insertLimit || got.remaining < insertLimit-time.Second {
+			t.Errorf("expected %s bounded by %v, got %+v", name, insertLimit, got)
+		}
+	}
+	if got := repo.insertSettings(events)["max_execution_time"]; got != 30 {
+		t.Errorf("expected insert max_execution_time 30, got %v", got)
+	}
+}
+
+func TestExecutionContext_Unbounded(t *testing.T) {
+	ctx, cancel := executionContext(context.Background(), 0)
+	defer cancel()
+
+	if _, ok := ctx.Deadline(); ok {
+		t.Error("expected no deadline without a limit")
+	}
+	if executionSeconds(1500*time.Millisecond) != 2 {
+		t.Error("expected sub-second remainders to round up")
+	}
+}
+
 func BenchmarkDefaultConnectionConfig(b *testing.B) {
 	for i := 0; i < b.N; i++ {
 		_ = DefaultConnectionConfig()
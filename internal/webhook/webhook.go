@@ -0,0 +1,260 @@
+// Package webhook forwards ingested events to a partner's HTTP endpoint.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"fanfinity/internal/domain"
+	"fanfinity/internal/metrics"
+)
+
+var webhookDeliveries *prometheus.CounterVec
+
+var metricSet metrics.Set
+
+func init() {
+	RegisterMetrics(prometheus.DefaultRegisterer, metrics.Options{})
+}
+
+// RegisterMetrics creates the webhook metrics under opts and registers them
+// with reg, replacing any registered by an earlier call. Call it at startup,
+// before any sink is created.
+func RegisterMetrics(reg prometheus.Registerer, opts metrics.Options) {
+	registerMetrics(metricSet.Factory(reg), opts)
+}
+
+// registerMetrics creates the webhook metrics under opts.
+func registerMetrics(f promauto.Factory, opts metrics.Options) {
+	webhookDeliveries = f.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace:   opts.NamespaceOrDefault(),
+			Subsystem:   "webhook",
+			Name:        "deliveries_total",
+			Help:        "Total number of events forwarded to the webhook, by outcome (delivered, failed, dropped)",
+			ConstLabels: opts.ConstLabels,
+		},
+		[]string{"outcome"},
+	)
+}
+
+// Defaults for the webhook sink.
+const (
+	DefaultQueueSize    = 1000
+	DefaultMaxRetries   = 3
+	DefaultRetryBackoff = 500 * time.Millisecond
+	DefaultTimeout      = 5 * time.Second
+)
+
+// Config holds webhook sink settings.
+type Config struct {
+	// QueueSize bounds the events waiting for delivery. Events sent while the
+	// queue is full are dropped.
+	QueueSize int
+
+	// MaxRetries bounds retries of a failed delivery, backing off from
+	// RetryBackoff and doubling each attempt.
+	MaxRetries   int
+	RetryBackoff time.Duration
+
+	// Timeout bounds each POST.
+	Timeout time.Duration
+}
+
+// DefaultConfig returns the default webhook sink configuration.
+func DefaultConfig() Config {
+	return Config{
+		QueueSize:    DefaultQueueSize,
+		MaxRetries:   DefaultMaxRetries,
+		RetryBackoff: DefaultRetryBackoff,
+		Timeout:      DefaultTimeout,
+	}
+}
+
+// Sink POSTs events as JSON to a webhook URL from a background worker, so a
+// slow or failing endpoint never blocks ingestion. Each request carries the
+// event ID in the Idempotency-Key header, since retries may deliver an event
+// more than once.
+type Sink struct {
+	url    string
+	client *http.Client
+	logger *slog.Logger
+	config Config
+
+	queue chan *domain.Event
+	done  chan struct{}
+
+	// ctx is cancelled when Close gives up, aborting in-flight deliveries.
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu     sync.RWMutex
+	closed bool
+}
+
+// NewSink creates a webhook sink with default configuration.
+func NewSink(url string, logger *slog.Logger) *Sink {
+	return NewSinkWithConfig(url, logger, DefaultConfig())
+}
+
+// NewSinkWithConfig creates a webhook sink and starts its delivery worker.
+// Zero config values are replaced with defaults.
+func NewSinkWithConfig(url string, logger *slog.Logger, cfg Config) *Sink {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = DefaultQueueSize
+	}
+	if cfg.MaxRetries < 0 {
+		cfg.MaxRetries = 0
+	}
+	if cfg.RetryBackoff <= 0 {
+		cfg.RetryBackoff = DefaultRetryBackoff
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = DefaultTimeout
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &Sink{
+		url:    url,
+		client: &http.Client{Timeout: cfg.Timeout},
+		logger: logger,
+		config: cfg,
+		queue:  make(chan *domain.Event, cfg.QueueSize),
+		done:   make(chan struct{}),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+	go s.run()
+	return s
+}
+
+// Send queues event for delivery without blocking. The event is dropped if the
+// queue is full or the sink is closed.
+func (s *Sink) Send(event *domain.Event) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.closed {
+		webhookDeliveries.WithLabelValues("dropped").Inc()
+		return
+	}
+	select {
+	case s.queue <- event:
+	default:
+		webhookDeliveries.WithLabelValues("dropped").Inc()
+		s.logger.Warn("webhook queue full, dropping event",
+			slog.String("event_id", event.EventID.String()),
+			slog.Int("queue_size", s.config.QueueSize),
+		)
+	}
+}
+
+// Close stops accepting events and waits for queued events to be delivered.
+// If ctx is done first, in-flight deliveries are aborted, the rest of the queue
+// is abandoned and ctx's error is returned.
+func (s *Sink) Close(ctx context.Context) error {
+	s.mu.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.queue)
+	}
+	s.mu.Unlock()
+
+	select {
+	case <-s.done:
+		s.cancel()
+		return nil
+	case <-ctx.Done():
+		s.cancel()
+		return fmt.Errorf("webhook sink closed with %d events undelivered: %w", len(s.queue), ctx.Err())
+	}
+}
+
+// run delivers queued events in order until the queue is closed and drained.
+func (s *Sink) run() {
+	defer close(s.done)
+	for event := range s.queue {
+		if s.ctx.Err() != nil {
+			continue
+		}
+		if err := s.deliver(event); err != nil {
+			webhookDeliveries.WithLabelValues("failed").Inc()
+			s.logger.Error("failed to deliver event to webhook",
+				slog.String("event_id", event.EventID.String()),
+				slog.String("match_id", event.MatchID),
+				slog.String("error", err.Error()),
+			)
+			continue
+		}
+		webhookDeliveries.WithLabelValues("delivered").Inc()
+	}
+}
+
+// deliver POSTs event, retrying network errors, 429 and 5xx responses.
+func (s *Sink) deliver(event *domain.Event) error {
+	body, err := json.Marshal(event.AsKafkaMessage())
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	backoff := s.config.RetryBackoff
+	for attempt := 0; ; attempt++ {
+		retryable, err := s.post(event, body)
+		if err == nil {
+			return nil
+		}
+		if !retryable || attempt >= s.config.MaxRetries {
+			return err
+		}
+
+		s.logger.Warn("retrying webhook delivery",
+			slog.String("event_id", event.EventID.String()),
+			slog.Int("attempt", attempt+1),
+			slog.Duration("backoff", backoff),
+			slog.String("error", err.Error()),
+		)
+		select {
+		case <-time.After(backoff):
+		case <-s.ctx.Done():
+			return s.ctx.Err()
+		}
+		backoff *= 2
+	}
+}
+
+// post makes one delivery attempt, reporting whether a failure may be retried.
+func (s *Sink) post(event *domain.Event, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(s.ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Idempotency-Key", event.EventID.String())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return s.ctx.Err() == nil, err
+	}
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode < 300:
+		return false, nil
+	case resp.StatusCode == http.StatusTooManyRequests, resp.StatusCode >= 500:
+		return true, fmt.Errorf("webhook returned %d", resp.StatusCode)
+	default:
+		return false, fmt.Errorf("webhook returned %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,142 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"fanfinity/internal/domain"
+)
+
+func testEvent() *domain.Event {
+	return &domain.Event{
+		EventID:   uuid.New(),
+		MatchID:   "match-123",
+		EventType: domain.EventTypeGoal,
+		Timestamp: time.Date(2024, 1, 15, 14, 30, 0, 0, time.UTC),
+		TeamID:    1,
+		Metadata:  map[string]interface{}{"scorer": "player-9"},
+	}
+}
+
+func TestSink_DeliversEvent(t *testing.T) {
+	var mu sync.Mutex
+	var got domain.KafkaMessage
+	var idempotencyKey string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		idempotencyKey = r.Header.Get("Idempotency-Key")
+		json.NewDecoder(r.Body).Decode(&got)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	sink := NewSink(server.URL, nil)
+	event := testEvent()
+	sink.Send(event)
+
+	if err := sink.Close(context.Background()); err != nil {
+		t.Fatalf("unexpected close error: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if got.EventID != event.EventID.String() || got.EventType != "goal" || got.Metadata["scorer"] != "player-9" {
+		t.Errorf("unexpected payload: %+v", got)
+	}
+	if idempotencyKey != event.EventID.String() {
+		t.Errorf("expected Idempotency-Key %s, got %q", event.EventID, idempotencyKey)
+	}
+}
+
+func TestSink_RetriesServerErrors(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	before := testutil.ToFloat64(webhookDeliveries.WithLabelValues("delivered"))
+	sink := NewSinkWithConfig(server.URL, nil, Config{MaxRetries: 3, RetryBackoff: time.Millisecond})
+	sink.Send(testEvent())
+	if err := sink.Close(context.Background()); err != nil {
+		t.Fatalf("unexpected close error: %v", err)
+	}
+
+	if calls.Load() != 3 {
+		t.Errorf("expected 3 attempts, got %d", calls.Load())
+	}
+	if got := testutil.ToFloat64(webhookDeliveries.WithLabelValues("delivered")) - before; got != 1 {
+		t.Errorf("expected 1 delivered event, got %v", got)
+	}
+}
+
+func TestSink_DoesNotRetryClientErrors(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	before := testutil.ToFloat64(webhookDeliveries.WithLabelValues("failed"))
+	sink := NewSinkWithConfig(server.URL, nil, Config{MaxRetries: 3, RetryBackoff: time.Millisecond})
+	sink.Send(testEvent())
+	sink.Close(context.Background())
+
+	if calls.Load() != 1 {
+		t.Errorf("expected a single attempt, got %d", calls.Load())
+	}
+	if got := testutil.ToFloat64(webhookDeliveries.WithLabelValues("failed")) - before; got != 1 {
+		t.Errorf("expected 1 failed event, got %v", got)
+	}
+}
+
+func TestSink_SendNeverBlocks(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	before := testutil.ToFloat64(webhookDeliveries.WithLabelValues("dropped"))
+	sink := NewSinkWithConfig(server.URL, nil, Config{QueueSize: 2})
+
+	start := time.Now()
+	for i := 0; i < 10; i++ {
+		sink.Send(testEvent())
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("expected Send to return immediately with a stalled webhook, took %s", elapsed)
+	}
+	// One event is in flight and two are queued; the rest are dropped
+	if got := testutil.ToFloat64(webhookDeliveries.WithLabelValues("dropped")) - before; got < 7 {
+		t.Errorf("expected at least 7 dropped events, got %v", got)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := sink.Close(ctx); err == nil {
+		t.Error("expected Close to report undelivered events when its context expires")
+	}
+
+	// Sends after Close are dropped rather than panicking
+	sink.Send(testEvent())
+}